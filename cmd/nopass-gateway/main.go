@@ -1,37 +1,1594 @@
+// Command nopass-gateway is the NoPass safety gateway.
+//
+// Built with -tags minimal (CGO_ENABLED=0 for a static binary) it runs no
+// external programs: no Docker sandbox and no git policy sync, only model
+// providers and the in-process risk and review engines, for platforms that
+// cannot start containers. See Dockerfile.minimal.
 package main
 
 import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
 	"log"
+	"net"
 	"net/http"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
 
+	"github.com/shivansh-source/nopass/internal/admin"
+	"github.com/shivansh-source/nopass/internal/answercache"
+	"github.com/shivansh-source/nopass/internal/approval"
+	"github.com/shivansh-source/nopass/internal/artifacts"
+	"github.com/shivansh-source/nopass/internal/audit"
+	"github.com/shivansh-source/nopass/internal/auth"
+	"github.com/shivansh-source/nopass/internal/breaker"
+	"github.com/shivansh-source/nopass/internal/canary"
+	"github.com/shivansh-source/nopass/internal/config"
+	"github.com/shivansh-source/nopass/internal/datastore"
+	"github.com/shivansh-source/nopass/internal/directory"
+	"github.com/shivansh-source/nopass/internal/dlp"
+	"github.com/shivansh-source/nopass/internal/events"
+	"github.com/shivansh-source/nopass/internal/explain"
+	"github.com/shivansh-source/nopass/internal/features"
 	"github.com/shivansh-source/nopass/internal/gateway"
+	"github.com/shivansh-source/nopass/internal/grpcapi"
+	"github.com/shivansh-source/nopass/internal/ingest"
+	"github.com/shivansh-source/nopass/internal/jobs"
+	"github.com/shivansh-source/nopass/internal/kube"
+	"github.com/shivansh-source/nopass/internal/logging"
+	"github.com/shivansh-source/nopass/internal/maintenance"
+	"github.com/shivansh-source/nopass/internal/masksample"
+	"github.com/shivansh-source/nopass/internal/memory"
 	"github.com/shivansh-source/nopass/internal/orchestrator"
+	nopassv1 "github.com/shivansh-source/nopass/internal/pb/nopass/v1"
+	"github.com/shivansh-source/nopass/internal/pii"
+	"github.com/shivansh-source/nopass/internal/policy"
+	"github.com/shivansh-source/nopass/internal/postprocess"
+	"github.com/shivansh-source/nopass/internal/profanity"
+	"github.com/shivansh-source/nopass/internal/ratelimit"
+	"github.com/shivansh-source/nopass/internal/receipts"
+	"github.com/shivansh-source/nopass/internal/refusalcache"
+	"github.com/shivansh-source/nopass/internal/rescan"
+	"github.com/shivansh-source/nopass/internal/residency"
+	"github.com/shivansh-source/nopass/internal/retrieval"
+	"github.com/shivansh-source/nopass/internal/review"
+	"github.com/shivansh-source/nopass/internal/risk"
+	"github.com/shivansh-source/nopass/internal/riskledger"
+	"github.com/shivansh-source/nopass/internal/scanledger"
+	"github.com/shivansh-source/nopass/internal/scheduler"
+	"github.com/shivansh-source/nopass/internal/scim"
+	"github.com/shivansh-source/nopass/internal/storage"
+	"github.com/shivansh-source/nopass/internal/topic"
+	"github.com/shivansh-source/nopass/internal/tracing"
+	"github.com/shivansh-source/nopass/internal/tuning"
+	"github.com/shivansh-source/nopass/internal/types"
+	"github.com/shivansh-source/nopass/internal/vault"
+	"github.com/shivansh-source/nopass/internal/warmup"
+	"google.golang.org/grpc"
 )
 
 func main() {
-	riskURL := os.Getenv("NOPASS_RISK_URL")
-	if riskURL == "" {
-		riskURL = "http://localhost:8001" // default for local dev
+	// NOPASS_LOG_FORMAT (json, the default, or text) and NOPASS_LOG_LEVEL
+	// (debug, info, warn, error) set up structured logging. Chat request
+	// log lines carry the request ID, returned to clients in X-Request-ID,
+	// and the tenant, user, session, risk level and path.
+	if err := logging.Setup(os.Stderr, os.Getenv("NOPASS_LOG_FORMAT"), os.Getenv("NOPASS_LOG_LEVEL")); err != nil {
+		log.Fatalf("invalid logging config: %v", err)
 	}
 
-	outputURL := os.Getenv("NOPASS_OUTPUT_URL")
-	if outputURL == "" {
-		outputURL = "http://localhost:8002"
+	// NOPASS_CONFIG points at a YAML config file (listen address, service
+	// URLs, timeouts, sandbox image, masking and path settings); NOPASS_*
+	// variables override it. SIGHUP reloads it.
+	configPath := os.Getenv("NOPASS_CONFIG")
+	cfg, err := config.Load(configPath)
+	if err != nil {
+		log.Fatalf("load config: %v", err)
 	}
 
-	riskClient := gateway.NewRiskClient(riskURL)
-	llmRunner := orchestrator.NewLLMRunner()
-	outputClient := gateway.NewOutputSafetyClient(outputURL)
+	// tracing.endpoint (NOPASS_OTLP_ENDPOINT) exports OpenTelemetry spans
+	// for every chat request and its risk, sandbox and output safety calls;
+	// the risk and output safety services get the trace context in the
+	// traceparent header.
+	if cfg.Tracing.Endpoint != "" {
+		exporter := tracing.NewExporter(cfg.Tracing.Endpoint, cfg.Tracing.ServiceName)
+		go exporter.Run(context.Background())
+		tracing.Enable(tracing.NewTracer(exporter, cfg.Tracing.SampleRatio))
+		log.Printf("exporting traces to %s (sample ratio %g)", cfg.Tracing.Endpoint, cfg.Tracing.SampleRatio)
+	}
 
-	handler := gateway.NewHandler(riskClient, llmRunner, outputClient)
+	// resilience.* retries transient risk and output safety failures and
+	// opens a circuit breaker on a service that keeps failing, so requests
+	// fail fast (or take the failure policy) instead of queueing on it.
+	riskClient := gateway.NewRiskClient(cfg.RiskURL)
+	riskClient.HTTPClient.Timeout = cfg.Timeouts.Risk
+	riskClient.Breaker = newBreaker("risk", cfg.Resilience)
+	riskScorer := withRiskEngine(riskClient, cfg.RiskEngine)
+	outputClient := gateway.NewOutputSafetyClient(cfg.OutputURL)
+	outputClient.HTTPClient.Timeout = cfg.Timeouts.OutputSafety
+	outputClient.Breaker = newBreaker("output_safety", cfg.Resilience)
+	var outputReviewer review.OutputReviewer = outputClient
+
+	// NOPASS_OUTPUT_REVIEWERS="primary=http://a:8002,secondary=http://b:8002"
+	// replaces the single output safety service with a voting panel.
+	if v := os.Getenv("NOPASS_OUTPUT_REVIEWERS"); v != "" {
+		panel, err := buildReviewPanel(v, cfg.Timeouts.OutputSafety, cfg.Resilience)
+		if err != nil {
+			log.Fatalf("invalid output reviewer config: %v", err)
+		}
+		outputReviewer = panel
+	}
 
 	mux := http.NewServeMux()
-	mux.HandleFunc("/v1/chat", handler.ChatHandler)
 
-	addr := ":8082"
-	log.Printf("NoPass Gateway listening on %s", addr)
-	if err := http.ListenAndServe(addr, mux); err != nil {
+	// Sandbox mode "fleet" schedules sandbox runs onto remote nopass-runner
+	// hosts instead of the local Docker daemon. The local daemon is reached
+	// through its API as the docker CLI would: DOCKER_HOST,
+	// DOCKER_TLS_VERIFY, DOCKER_CERT_PATH; the CLI needn't be installed.
+	localRunner := orchestrator.NewLLMRunnerWithConfig(orchestrator.SandboxConfig{
+		ImageName: cfg.Sandbox.Image,
+		Timeout:   cfg.Timeouts.Sandbox,
+		InputMode: cfg.Sandbox.InputMode,
+		TempDir:   cfg.Sandbox.TempDir,
+		Output:    cfg.Sandbox.Output,
+		Limits:    sandboxLimits(cfg.Sandbox.Limits),
+		Runtime:   cfg.Sandbox.Runtime,
+	})
+	// NOPASS_SANDBOX_IMAGE_OUTPUTS="registry/old-llm:1.4=text" pins the
+	// stdout protocol of particular images while they are migrated to
+	// output frames.
+	if v := os.Getenv("NOPASS_SANDBOX_IMAGE_OUTPUTS"); v != "" {
+		outputs, err := orchestrator.ParseImageOutputs(v)
+		if err != nil {
+			log.Fatalf("invalid NOPASS_SANDBOX_IMAGE_OUTPUTS: %v", err)
+		}
+		localRunner.SetImageOutputs(outputs)
+	}
+	// NOPASS_TENANT_IMAGES="acme=registry/acme-llm@sha256:…" gives tenants
+	// private sandbox images that no other tenant's requests may use.
+	var tenantImages map[string]orchestrator.TenantImage
+	if v := os.Getenv("NOPASS_TENANT_IMAGES"); v != "" {
+		images, err := orchestrator.ParseTenantImages(v)
+		if err != nil {
+			log.Fatalf("invalid NOPASS_TENANT_IMAGES: %v", err)
+		}
+		if err := localRunner.SetTenantImages(&orchestrator.ImagePolicy{Tenants: images}); err != nil {
+			log.Fatalf("invalid tenant image policy: %v", err)
+		}
+		tenantImages = images
+	}
+
+	// sandbox.pool_size keeps warm containers of the shared image, each
+	// used for a single run, so requests skip the container start.
+	if cfg.Sandbox.Mode == "local" && cfg.Sandbox.PoolSize > 0 {
+		localRunner.SetPool(cfg.Sandbox.PoolSize, cfg.Sandbox.PoolMaxAge)
+		go localRunner.RunPool(context.Background())
+		log.Printf("keeping %d warm sandbox containers", cfg.Sandbox.PoolSize)
+	}
+
+	var llmRunner orchestrator.Runner = localRunner
+	// Sandbox mode "kubernetes" runs each call as a Job in the gateway's
+	// cluster, for clusters with no Docker socket to mount. The gateway's
+	// service account needs to create and delete Jobs and Secrets, list
+	// pods, read their logs, and get and create NetworkPolicies.
+	var kubeRunner *orchestrator.KubeRunner
+	if cfg.Sandbox.Mode == "kubernetes" {
+		kc, err := kube.InCluster()
+		if err != nil {
+			log.Fatalf("sandbox mode kubernetes: %v", err)
+		}
+		kubeRunner = orchestrator.NewKubeRunner(orchestrator.KubeConfig{
+			Namespace:    cfg.Sandbox.Kubernetes.Namespace,
+			ImageName:    cfg.Sandbox.Image,
+			Timeout:      cfg.Timeouts.Sandbox,
+			Limits:       sandboxLimits(cfg.Sandbox.Limits),
+			RuntimeClass: cfg.Sandbox.Kubernetes.RuntimeClass,
+		}, kc)
+		if tenantImages != nil {
+			if err := kubeRunner.SetTenantImages(&orchestrator.ImagePolicy{Tenants: tenantImages}); err != nil {
+				log.Fatalf("invalid tenant image policy: %v", err)
+			}
+		}
+		npCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		if err := kubeRunner.EnsureNetworkPolicy(npCtx); err != nil {
+			log.Fatalf("sandbox network policy: %v", err)
+		}
+		cancel()
+		llmRunner = kubeRunner
+		log.Printf("sandbox kubernetes mode: runs are Jobs in namespace %s", kc.Namespace())
+	}
+	// Fleet mode sends runs to nopass-runner hosts. Runners register by
+	// heartbeat on NOPASS_FLEET_LISTEN (default :8084), a listener of its
+	// own that never serves clients; NOPASS_RUNNER_TOKENS
+	// ("runner-a=<secret>,...") gives each runner ID the secret its
+	// heartbeats must carry and its runs are sent with.
+	// NOPASS_FLEET_DEDICATED ("runner-a=acme,globex;runner-b=initech")
+	// dedicates runners to tenants: those tenants only ever run there and
+	// the runners serve no one else.
+	var sched *scheduler.Scheduler
+	if cfg.Sandbox.Mode == "fleet" {
+		sched = scheduler.New(15 * time.Second)
+		tokens, err := scheduler.ParseTokens(os.Getenv("NOPASS_RUNNER_TOKENS"))
+		if err != nil {
+			log.Fatalf("invalid NOPASS_RUNNER_TOKENS: %v", err)
+		}
+		sched.Tokens = tokens
+		if sched.Dedicated, err = scheduler.ParseDedicated(os.Getenv("NOPASS_FLEET_DEDICATED")); err != nil {
+			log.Fatalf("invalid NOPASS_FLEET_DEDICATED: %v", err)
+		}
+		llmRunner = orchestrator.NewFleetRunner(sched)
+		fleetAddr := os.Getenv("NOPASS_FLEET_LISTEN")
+		if fleetAddr == "" {
+			fleetAddr = ":8084"
+		}
+		fleetMux := http.NewServeMux()
+		fleetMux.HandleFunc("/internal/runners", sched.HeartbeatHandler)
+		go func() {
+			if err := http.ListenAndServe(fleetAddr, fleetMux); err != nil {
+				log.Fatalf("fleet listener failed: %v", err)
+			}
+		}()
+		log.Printf("sandbox fleet mode enabled; waiting for runner heartbeats on %s", fleetAddr)
+	}
+	// Configured model providers can be picked per request with
+	// generation.provider; sandbox mode "provider" makes one the default, so
+	// the gateway runs without Docker.
+	if len(cfg.Providers) > 0 {
+		providers := make(map[string]orchestrator.Runner, len(cfg.Providers))
+		for name, p := range cfg.Providers {
+			pr, err := orchestrator.NewProviderRunner(orchestrator.ProviderConfig{
+				Kind:    p.Kind,
+				URL:     p.URL,
+				APIKey:  os.Getenv(p.APIKeyEnv),
+				Model:   p.Model,
+				Timeout: cfg.Timeouts.Sandbox,
+			})
+			if err != nil {
+				log.Fatalf("invalid provider %s: %v", name, err)
+			}
+			providers[name] = pr
+		}
+		router := &orchestrator.ProviderRouter{Default: llmRunner, Providers: providers}
+		if cfg.Sandbox.Mode == "provider" {
+			router.Default, router.DefaultName = providers[cfg.Sandbox.Provider], cfg.Sandbox.Provider
+			log.Printf("sandbox provider mode: model calls go to provider %s", cfg.Sandbox.Provider)
+		}
+		llmRunner = router
+	}
+
+	// Canary comparison: a stable gateway with NOPASS_CANARY_MIRROR_URL sends
+	// NOPASS_CANARY_SAMPLE (default 0.01) of its requests, with the downstream
+	// responses they got, to a canary started with NOPASS_CANARY_MODE=1. The
+	// canary replays them instead of calling the live services and reports
+	// disposition and latency differences at /admin/api/canary. Both sides
+	// authenticate with NOPASS_CANARY_SECRET.
+	mirrorURL := os.Getenv("NOPASS_CANARY_MIRROR_URL")
+	canaryMode := os.Getenv("NOPASS_CANARY_MODE") == "1"
+	canarySecret := os.Getenv("NOPASS_CANARY_SECRET")
+	if (mirrorURL != "" || canaryMode) && canarySecret == "" {
+		log.Fatal("canary comparison requires NOPASS_CANARY_SECRET")
+	}
+	if mirrorURL != "" || canaryMode {
+		// Only calls made for a mirrored request are recorded or replayed.
+		http.DefaultTransport = &canary.Transport{Base: http.DefaultTransport}
+		llmRunner = canary.Runner{Runner: llmRunner}
+	}
+
+	handler := gateway.NewHandler(riskScorer, llmRunner, outputReviewer)
+	// API keys allowed to set their own deadline (nopass apikey create
+	// -deadlines) keep the output review's timeout out of the sandbox's
+	// share; max_client_deadline (NOPASS_MAX_CLIENT_DEADLINE) caps them.
+	handler.ReviewReserve = cfg.Timeouts.OutputSafety
+	if mirrorURL != "" {
+		sample := 0.01
+		if v := os.Getenv("NOPASS_CANARY_SAMPLE"); v != "" {
+			f, err := strconv.ParseFloat(v, 64)
+			if err != nil || f < 0 || f > 1 {
+				log.Fatalf("invalid NOPASS_CANARY_SAMPLE %q", v)
+			}
+			sample = f
+		}
+		handler.Mirror = canary.NewMirror(strings.TrimSuffix(mirrorURL, "/"), canarySecret, sample)
+		go handler.Mirror.Run(context.Background())
+		log.Printf("mirroring %.2f%% of chat requests to canary %s", sample*100, mirrorURL)
+	}
+	handler.Settings = config.NewLive(cfg.Runtime)
+	// Maintenance switches, flipped at /admin/api/maintenance: maintenance
+	// mode turns new chat traffic away with a 503, read-only mode refuses
+	// config and policy reloads, policy syncs, SCIM provisioning changes
+	// and tuning changes. They are shared through the storage backend.
+	maint := &maintenance.Switch{}
+	go reloadOnSIGHUP(configPath, cfg, handler.Settings, maint)
+
+	// NOPASS_STORAGE_BACKEND (memory, sqlite, postgres, redis) and
+	// NOPASS_STORAGE_DSN select where sessions, audit records, quarantine
+	// entries, vault entries and quota counters are kept.
+	store, err := storage.Open(context.Background(), storage.Config{
+		Backend: os.Getenv("NOPASS_STORAGE_BACKEND"),
+		DSN:     os.Getenv("NOPASS_STORAGE_DSN"),
+		Driver:  os.Getenv("NOPASS_STORAGE_DRIVER"),
+		// NOPASS_STORAGE_AUTO_MIGRATE=1 upgrades the schema at startup;
+		// otherwise run `nopass migrate` first.
+		AutoMigrate: os.Getenv("NOPASS_STORAGE_AUTO_MIGRATE") == "1",
+	})
+	if err != nil {
+		log.Fatalf("open storage: %v", err)
+	}
+	defer store.Close()
+	maint.Records = store.Records()
+	if err := maint.Refresh(context.Background()); err != nil {
+		log.Printf("load maintenance state: %v", err)
+	}
+	go maint.Run(context.Background(), 5*time.Second)
+	handler.Quarantine = store.Quarantine()
+	handler.Audit = store.Audit()
+
+	// audit.sink (NOPASS_AUDIT_SINK) keeps the compliance log of every chat
+	// transaction; S3 sinks take AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY.
+	// Collector sinks spool to audit.spool_dir only under
+	// NOPASS_AUDIT_SPOOL_KEY (base64, 32 bytes), so nothing is kept on the
+	// host in the clear.
+	if spec := cfg.Audit.Sink; spec != "" {
+		var sink audit.Sink = store.Audit()
+		if spec != "storage" {
+			collector := audit.CollectorConfig{
+				CertFile:    cfg.Audit.TLSCert,
+				KeyFile:     cfg.Audit.TLSKey,
+				CAFile:      cfg.Audit.TLSCA,
+				SpoolDir:    cfg.Audit.SpoolDir,
+				ServiceName: cfg.Tracing.ServiceName,
+			}
+			if v := os.Getenv("NOPASS_AUDIT_SPOOL_KEY"); v != "" {
+				if collector.SpoolKey, err = vault.ParseKey(v); err != nil || len(collector.SpoolKey) != 32 {
+					log.Fatalf("invalid NOPASS_AUDIT_SPOOL_KEY: want 32 base64 bytes")
+				}
+			}
+			sink, err = audit.Open(context.Background(), spec, audit.S3Config{
+				Region:   cfg.Audit.S3Region,
+				Endpoint: cfg.Audit.S3Endpoint,
+				KeyID:    os.Getenv("AWS_ACCESS_KEY_ID"),
+				Secret:   os.Getenv("AWS_SECRET_ACCESS_KEY"),
+			}, collector)
+			if err != nil {
+				log.Fatalf("open audit sink: %v", err)
+			}
+			if c, ok := sink.(io.Closer); ok {
+				defer c.Close()
+			}
+		}
+		handler.AuditLog = &audit.Log{Sink: sink, Retention: cfg.Audit.Retention}
+		go handler.AuditLog.Run(context.Background())
+		// Only the sink's kind: a DSN may hold a password.
+		kind, _, _ := strings.Cut(spec, ":")
+		log.Printf("audit log enabled (%s sink, retention %s)", kind, cfg.Audit.Retention)
+	}
+
+	// NOPASS_VAULT_KEY (base64, at least 32 bytes) enables the token
+	// vault: the values behind each session's masking tokens are kept,
+	// sealed under a per-tenant key derived from it, so answers can
+	// restore them (policy.yaml restore_tokens) in later turns.
+	// NOPASS_VAULT_TENANT_KEYS ("acme=<base64>,...") gives tenants their
+	// own 32-byte keys instead; NOPASS_VAULT_TTL (default 24h, 0 for none)
+	// bounds how long values are kept.
+	var vaultKeys *vault.Keyring
+	if v, byok := os.Getenv("NOPASS_VAULT_KEY"), os.Getenv("NOPASS_VAULT_TENANT_KEYS"); v != "" || byok != "" {
+		var master []byte
+		if v != "" {
+			if master, err = vault.ParseKey(v); err != nil {
+				log.Fatalf("invalid NOPASS_VAULT_KEY: %v", err)
+			}
+		}
+		keys, err := vault.NewKeyring(master)
+		if err != nil {
+			log.Fatalf("invalid NOPASS_VAULT_KEY: %v", err)
+		}
+		vaultKeys = keys
+		if err := keys.ParseTenantKeys(byok); err != nil {
+			log.Fatalf("invalid NOPASS_VAULT_TENANT_KEYS: %v", err)
+		}
+		ttl := 24 * time.Hour
+		if v := os.Getenv("NOPASS_VAULT_TTL"); v != "" {
+			if ttl, err = time.ParseDuration(v); err != nil || ttl < 0 {
+				log.Fatalf("invalid NOPASS_VAULT_TTL %q", v)
+			}
+		}
+		handler.Vault = vault.New(keys, store.Vault(), ttl)
+	}
+
+	// mask_samples.rate (NOPASS_MASK_SAMPLE_RATE) keeps a share of user
+	// messages for labeling in the admin UI's Masking samples tab; see
+	// config.MaskSamples. Samples are sealed under the vault keys above,
+	// so the vault must be enabled. Opening and labeling a sample takes
+	// NOPASS_MASK_SAMPLE_TOKEN rather than the admin token, so access to
+	// message content can be limited to the reviewers.
+	var maskSamples *masksample.Store
+	if ms := cfg.MaskSamples; ms.Rate > 0 {
+		if vaultKeys == nil {
+			log.Fatal("mask_samples.rate needs NOPASS_VAULT_KEY or NOPASS_VAULT_TENANT_KEYS to seal samples")
+		}
+		if maskSamples, err = masksample.New(ms.Dir, vaultKeys, ms.Rate, ms.TTL); err != nil {
+			log.Fatalf("mask sampling: %v", err)
+		}
+		go maskSamples.Run(context.Background())
+		handler.MaskSamples = maskSamples
+		log.Printf("mask sampling enabled (rate %v, kept %s)", ms.Rate, ms.TTL)
+	}
+
+	// NOPASS_SCIM_TOKEN enables the SCIM 2.0 provisioning API under
+	// /scim/v2/ so an IdP can create and deactivate tenants (as Groups) and
+	// users. NOPASS_SCIM_BASE_URL is the externally visible base, e.g.
+	// "https://nopass.example.com/scim/v2", used in resource locations.
+	var scimSrv *scim.Server
+	if token := os.Getenv("NOPASS_SCIM_TOKEN"); token != "" {
+		handler.Directory = &directory.Directory{Records: store.Records()}
+		scimSrv = &scim.Server{
+			Directory: handler.Directory,
+			Token:     token,
+			BaseURL:   strings.TrimSuffix(os.Getenv("NOPASS_SCIM_BASE_URL"), "/"),
+		}
+		if scimSrv.BaseURL == "" {
+			scimSrv.BaseURL = "/scim/v2"
+		}
+		log.Printf("SCIM provisioning enabled")
+	}
+
+	// NOPASS_PROMPT_SOURCE=sanitized sends the risk service's sanitized
+	// prompt to the model instead of the raw message.
+	handler.PromptSource = os.Getenv("NOPASS_PROMPT_SOURCE")
+	if err := gateway.ValidatePromptSource(handler.PromptSource); err != nil {
+		log.Fatalf("invalid NOPASS_PROMPT_SOURCE: %v", err)
+	}
+
+	handler.PromptCanary = cfg.PromptCanary
+
+	// sessions.serialize (NOPASS_SESSION_QUEUE) runs each session's turns
+	// one at a time, in arrival order, with up to sessions.queue waiting
+	// behind the running one; see config.Sessions.
+	if cfg.Sessions.Serialize {
+		handler.Sessions = scheduler.NewSessionQueue(cfg.Sessions.Queue)
+	}
+
+	// NOPASS_ARTIFACT_DIR lets the sandboxed model write files to
+	// /app/output; they are kept there and returned as download URLs
+	// signed with NOPASS_ARTIFACT_KEY (base64, at least 32 bytes; random
+	// per process if unset) that expire after NOPASS_ARTIFACT_TTL (default
+	// 1h). NOPASS_ARTIFACT_BASE_URL is the externally visible base of
+	// those URLs. With NOPASS_CLAMD_ADDR ("host:port" or a unix socket
+	// path) each file is scanned by clamd first and dropped if it fails.
+	// Text files are reviewed like the answer; binary files are dropped
+	// unless NOPASS_ARTIFACT_ALLOW_BINARY=1.
+	if dir := os.Getenv("NOPASS_ARTIFACT_DIR"); dir != "" {
+		var key []byte
+		if v := os.Getenv("NOPASS_ARTIFACT_KEY"); v != "" {
+			if key, err = vault.ParseKey(v); err != nil {
+				log.Fatalf("invalid NOPASS_ARTIFACT_KEY: %v", err)
+			}
+		}
+		ttl := time.Hour
+		if v := os.Getenv("NOPASS_ARTIFACT_TTL"); v != "" {
+			if ttl, err = time.ParseDuration(v); err != nil || ttl <= 0 {
+				log.Fatalf("invalid NOPASS_ARTIFACT_TTL %q", v)
+			}
+		}
+		arts, err := artifacts.NewStore(dir, key, ttl)
+		if err != nil {
+			log.Fatalf("artifact store: %v", err)
+		}
+		arts.BaseURL = os.Getenv("NOPASS_ARTIFACT_BASE_URL")
+		arts.AllowBinary = os.Getenv("NOPASS_ARTIFACT_ALLOW_BINARY") == "1"
+		if addr := os.Getenv("NOPASS_CLAMD_ADDR"); addr != "" {
+			network := "tcp"
+			if strings.HasPrefix(addr, "/") {
+				network = "unix"
+			}
+			arts.Scanner = artifacts.ClamAV{Network: network, Addr: addr}
+		}
+		handler.Artifacts = arts
+		go arts.Run(context.Background())
+	}
+
+	// NOPASS_SANDBOX_SLOTS caps concurrent sandbox runs per gateway; queued
+	// interactive requests are always admitted ahead of batch ones.
+	if v := os.Getenv("NOPASS_SANDBOX_SLOTS"); v != "" {
+		slots, err := strconv.Atoi(v)
+		if err != nil || slots <= 0 {
+			log.Fatalf("invalid NOPASS_SANDBOX_SLOTS %q", v)
+		}
+		reserved := 0
+		if rv := os.Getenv("NOPASS_INTERACTIVE_RESERVED_SLOTS"); rv != "" {
+			reserved, err = strconv.Atoi(rv)
+			if err != nil || reserved < 0 {
+				log.Fatalf("invalid NOPASS_INTERACTIVE_RESERVED_SLOTS %q", rv)
+			}
+		}
+		handler.Admission = scheduler.NewAdmission(slots, reserved)
+
+		// NOPASS_COLD_PATH_WINDOWS="mon-fri 09:00-18:00 20%" caps batch/eval
+		// traffic to a share of the slots during the given windows.
+		if wv := os.Getenv("NOPASS_COLD_PATH_WINDOWS"); wv != "" {
+			windows, err := scheduler.ParseWindows(wv)
+			if err != nil {
+				log.Fatalf("invalid NOPASS_COLD_PATH_WINDOWS: %v", err)
+			}
+			policy := &scheduler.ColdPathPolicy{Windows: windows}
+			if tz := os.Getenv("NOPASS_COLD_PATH_TZ"); tz != "" {
+				loc, err := time.LoadLocation(tz)
+				if err != nil {
+					log.Fatalf("invalid NOPASS_COLD_PATH_TZ: %v", err)
+				}
+				policy.Location = loc
+			}
+			handler.Admission.ColdPath = policy
+		}
+	}
+
+	// A request's class comes from its API key (`nopass apikey create
+	// -priority`); requests without a key are batch.
+	if os.Getenv("NOPASS_KEY_PRIORITIES") != "" {
+		log.Fatal("NOPASS_KEY_PRIORITIES is replaced by API key priorities (nopass apikey create -priority)")
+	}
+
+	// NOPASS_DATA_REGISTRATION=1 enables POST /v1/data so documents can be
+	// registered once and referenced by ID from chat requests.
+	dataRegistration := os.Getenv("NOPASS_DATA_REGISTRATION") == "1"
+	if dataRegistration {
+		handler.DataStore = datastore.NewMemoryStore()
+
+		// Resumable uploads are spooled under NOPASS_UPLOAD_DIR, up to
+		// NOPASS_UPLOAD_MAX_BYTES (default 256 MiB) per file; uploads idle
+		// for an hour are discarded.
+		dir := os.Getenv("NOPASS_UPLOAD_DIR")
+		if dir == "" {
+			dir = filepath.Join(os.TempDir(), "nopass-uploads")
+		}
+		maxBytes := int64(256 << 20)
+		if v := os.Getenv("NOPASS_UPLOAD_MAX_BYTES"); v != "" {
+			n, err := strconv.ParseInt(v, 10, 64)
+			if err != nil || n <= 0 {
+				log.Fatalf("invalid NOPASS_UPLOAD_MAX_BYTES %q", v)
+			}
+			maxBytes = n
+		}
+		spool, err := ingest.NewSpool(dir, maxBytes, time.Hour)
+		if err != nil {
+			log.Fatalf("upload spool: %v", err)
+		}
+		handler.Uploads = spool
+		go spool.Run(context.Background())
+	}
+
+	// NOPASS_EVENTS_WEBHOOK receives security events such as documents that
+	// became flagged after a policy change.
+	sinks := events.Multi{events.LogSink{}}
+	if v := os.Getenv("NOPASS_EVENTS_WEBHOOK"); v != "" {
+		sinks = append(sinks, events.NewWebhookSink(v))
+	}
+
+	// NOPASS_MAX_MESSAGE_BYTES truncates oversized user messages (default
+	// 64 KiB); NOPASS_MESSAGE_OVERFLOW_TO_DATA=1 keeps the remainder as a
+	// registered document.
+	handler.MaxMessageBytes = 64 << 10
+	if v := os.Getenv("NOPASS_MAX_MESSAGE_BYTES"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			log.Fatalf("invalid NOPASS_MAX_MESSAGE_BYTES %q", v)
+		}
+		handler.MaxMessageBytes = n
+	}
+	handler.OverflowToData = os.Getenv("NOPASS_MESSAGE_OVERFLOW_TO_DATA") == "1"
+
+	// NOPASS_STREAM_REVIEW_BYTES sets how much of a streamed answer is held
+	// back between incremental output-safety reviews (default 256).
+	if v := os.Getenv("NOPASS_STREAM_REVIEW_BYTES"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			log.Fatalf("invalid NOPASS_STREAM_REVIEW_BYTES %q", v)
+		}
+		handler.StreamReviewBytes = n
+	}
+
+	// NOPASS_POSTPROCESSORS="default=disclaimer;acme=markdown_to_slack,citations"
+	// configures per-tenant response transforms; NOPASS_DISCLAIMER_TEXT
+	// enables the "disclaimer" processor.
+	if v := os.Getenv("NOPASS_POSTPROCESSORS"); v != "" {
+		reg := postprocess.NewRegistry()
+		if text := os.Getenv("NOPASS_DISCLAIMER_TEXT"); text != "" {
+			reg.Register("disclaimer", postprocess.Disclaimer(text))
+		}
+		chains, err := postprocess.ParseChains(v)
+		if err != nil {
+			log.Fatalf("invalid NOPASS_POSTPROCESSORS: %v", err)
+		}
+		for tenant, names := range chains {
+			if err := reg.SetChain(tenant, names); err != nil {
+				log.Fatalf("invalid NOPASS_POSTPROCESSORS: %v", err)
+			}
+		}
+		handler.PostProcessors = reg
+	}
+
+	// NOPASS_TOPICS_FILE restricts tenants to their domain: an allowed-topic
+	// description and denied topics per tenant, checked by keyword and, if
+	// the file names an embedding endpoint, by embedding similarity.
+	if v := os.Getenv("NOPASS_TOPICS_FILE"); v != "" {
+		file, err := topic.LoadFile(v)
+		if err != nil {
+			log.Fatalf("invalid NOPASS_TOPICS_FILE: %v", err)
+		}
+		var emb topic.Embedder
+		if file.EmbeddingURL != "" {
+			emb = retrieval.NewHTTPEmbedder(file.EmbeddingURL, file.EmbeddingModel, os.Getenv(file.EmbeddingKeyEnv))
+		}
+		handler.Topics = topic.New(file.Tenants, emb)
+	}
+
+	// NOPASS_PROFANITY="default=medium:mask;kids=low:refuse" filters
+	// profanity and harassment at or above a severity (low, medium, high)
+	// out of answers per tenant: mask, remove or refuse.
+	if v := os.Getenv("NOPASS_PROFANITY"); v != "" {
+		tenants, err := profanity.ParseTenants(v)
+		if err != nil {
+			log.Fatalf("invalid NOPASS_PROFANITY: %v", err)
+		}
+		handler.Profanity = &profanity.Filter{Tenants: tenants}
+	}
+
+	// pii.detector_url (NOPASS_PII_DETECTOR_URL) points at a
+	// Presidio-compatible entity recognition service; see config.PII.
+	if c := cfg.PII; c.DetectorURL != "" {
+		d := pii.NewHTTPDetector(c.DetectorURL)
+		d.Language, d.Entities, d.MinScore = c.Language, c.Entities, c.MinScore
+		handler.PII = d
+	}
+
+	// refusal_cache.ttl (NOPASS_REFUSAL_CACHE_TTL, e.g. "15m") turns on
+	// the refusal cache; see config.RefusalCache.
+	if rc := cfg.RefusalCache; rc.TTL > 0 {
+		handler.Refusals = refusalcache.New(rc.Size, rc.TTL)
+	}
+
+	// answer_cache.ttl (NOPASS_ANSWER_CACHE_TTL, e.g. "1h") turns on the
+	// answer cache; see config.AnswerCache.
+	if ac := cfg.AnswerCache; ac.TTL > 0 {
+		answers := answercache.New(ac.Size, ac.TTL)
+		answers.Tenants = ac.Tenants
+		if cs, ok := store.(storage.CacheStore); ok {
+			answers.Shared = cs
+		}
+		handler.Answers = answers
+	}
+
+	// explanations.enabled (NOPASS_EXPLANATIONS) serves GET
+	// /v1/requests/{id}/explanation; see config.Explanations.
+	explanations := cfg.Explanations.Enabled
+	if explanations {
+		templates := explain.DefaultTemplates()
+		if v := cfg.Explanations.Templates; v != "" {
+			if templates, err = explain.LoadTemplates(v); err != nil {
+				log.Fatalf("invalid explanations.templates: %v", err)
+			}
+		}
+		handler.Explanations = &templates
+		handler.RecentOutcomes = explain.NewRecent(100000, cfg.Explanations.TTL)
+	}
+
+	// NOPASS_FEATURES_SINK ("file:/path.jsonl" or an http(s) URL) exports an
+	// anonymized feature record per request, and per POST /v1/feedback
+	// label, for model retraining (schema: internal/features).
+	// NOPASS_FEATURES_SALT keys the ID hashes; without it they change on
+	// every restart.
+	if v := os.Getenv("NOPASS_FEATURES_SINK"); v != "" {
+		sink, err := features.ParseSink(v)
+		if err != nil {
+			log.Fatalf("invalid NOPASS_FEATURES_SINK: %v", err)
+		}
+		salt := []byte(os.Getenv("NOPASS_FEATURES_SALT"))
+		if len(salt) == 0 {
+			log.Printf("NOPASS_FEATURES_SALT not set; feature IDs are hashed with a random salt")
+			salt = make([]byte, 32)
+			_, _ = rand.Read(salt)
+		}
+		handler.Features = features.NewExporter(sink, salt)
+		go handler.Features.Run(context.Background())
+	}
+
+	// NOPASS_TUNING ("memory", or "file:/path.json" to keep approved
+	// adjustments across restarts) tracks refusal reasons and
+	// false-positive feedback per tenant and suggests threshold
+	// adjustments under /admin/api/tuning once NOPASS_TUNING_FP_RATE
+	// (default 0.1) of NOPASS_TUNING_MIN_REFUSALS (default 20) refusals in
+	// the last week are labelled false. Needs NOPASS_FEATURES_SINK.
+	if v := os.Getenv("NOPASS_TUNING"); v != "" {
+		if handler.Features == nil {
+			log.Fatalf("NOPASS_TUNING needs NOPASS_FEATURES_SINK: feedback cites feature IDs")
+		}
+		tuner := &tuning.Tuner{}
+		switch {
+		case v == "memory":
+		case strings.HasPrefix(v, "file:") && len(v) > len("file:"):
+			tuner.StatePath = strings.TrimPrefix(v, "file:")
+		default:
+			log.Fatalf("invalid NOPASS_TUNING %q", v)
+		}
+		if v := os.Getenv("NOPASS_TUNING_FP_RATE"); v != "" {
+			rate, err := strconv.ParseFloat(v, 64)
+			if err != nil || rate <= 0 || rate > 1 {
+				log.Fatalf("invalid NOPASS_TUNING_FP_RATE %q", v)
+			}
+			tuner.Rate = rate
+		}
+		if v := os.Getenv("NOPASS_TUNING_MIN_REFUSALS"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n <= 0 {
+				log.Fatalf("invalid NOPASS_TUNING_MIN_REFUSALS %q", v)
+			}
+			tuner.MinRefusals = n
+		}
+		if err := tuner.Load(); err != nil {
+			log.Fatalf("load tuning state: %v", err)
+		}
+		handler.Tuning = tuner
+	}
+
+	// NOPASS_MEMORY_MAX_HISTORY_BYTES enables summarization of older turns
+	// once a session's history grows past the given size.
+	if v := os.Getenv("NOPASS_MEMORY_MAX_HISTORY_BYTES"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			log.Fatalf("invalid NOPASS_MEMORY_MAX_HISTORY_BYTES %q", v)
+		}
+		handler.Memory = &memory.Compactor{
+			Runner:          llmRunner,
+			Store:           memory.SessionStore{Sessions: store.Sessions()},
+			MaxHistoryBytes: n,
+			KeepRecent:      6,
+		}
+	}
+
+	// NOPASS_SESSION_HISTORY=1 keeps each session's turns (masked) in the
+	// storage backend, so clients can send only the new message; at most
+	// NOPASS_SESSION_MAX_TURNS (default 100) are kept per session.
+	if os.Getenv("NOPASS_SESSION_HISTORY") == "1" {
+		handler.History = &memory.History{Sessions: store.Sessions(), MaxTurns: 100}
+		if v := os.Getenv("NOPASS_SESSION_MAX_TURNS"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 0 {
+				log.Fatalf("invalid NOPASS_SESSION_MAX_TURNS %q", v)
+			}
+			handler.History.MaxTurns = n
+		}
+	}
+
+	// NOPASS_SESSION_RISK=1 tracks risk across each session's turns and
+	// escalates probing sessions; NOPASS_SESSION_RISK_LIMITS overrides the
+	// thresholds, e.g. "window=10,slow=3,block=6".
+	if os.Getenv("NOPASS_SESSION_RISK") == "1" {
+		handler.RiskLedger = riskledger.New(store.Sessions())
+		if err := handler.RiskLedger.Configure(os.Getenv("NOPASS_SESSION_RISK_LIMITS")); err != nil {
+			log.Fatalf("invalid NOPASS_SESSION_RISK_LIMITS: %v", err)
+		}
+	}
+
+	// NOPASS_CONNECTORS_FILE configures per-tenant retrieval connectors.
+	if v := os.Getenv("NOPASS_CONNECTORS_FILE"); v != "" {
+		reg, err := retrieval.LoadFile(v)
+		if err != nil {
+			log.Fatalf("invalid NOPASS_CONNECTORS_FILE: %v", err)
+		}
+		handler.Retrieval = reg
+	}
+
+	// NOPASS_DLP_PACKS enables DLP labelling with built-in packs
+	// ("source_code", "financial", "health"); NOPASS_DLP_PACKS_FILE adds
+	// custom ones. NOPASS_DLP_RULES acts on the labels, e.g.
+	// "health:output:fast=block,source_code:input=slow".
+	if names, file := os.Getenv("NOPASS_DLP_PACKS"), os.Getenv("NOPASS_DLP_PACKS_FILE"); names != "" || file != "" {
+		packs, err := dlp.Select(names)
+		if err != nil {
+			log.Fatalf("invalid NOPASS_DLP_PACKS: %v", err)
+		}
+		if file != "" {
+			custom, err := dlp.LoadPacks(file)
+			if err != nil {
+				log.Fatalf("invalid NOPASS_DLP_PACKS_FILE: %v", err)
+			}
+			packs = append(packs, custom...)
+		}
+		rules, err := dlp.ParseRules(os.Getenv("NOPASS_DLP_RULES"))
+		if err != nil {
+			log.Fatalf("invalid NOPASS_DLP_RULES: %v", err)
+		}
+		if handler.DLP, err = dlp.New(packs, rules); err != nil {
+			log.Fatalf("invalid DLP configuration: %v", err)
+		}
+	}
+
+	// NOPASS_POLICY_VERSION tags every scan verdict; bump it whenever
+	// quarantine rules or the risk model change to force re-scans.
+	handler.PolicyVersion = os.Getenv("NOPASS_POLICY_VERSION")
+	if handler.PolicyVersion == "" {
+		handler.PolicyVersion = "v1"
+	}
+	handler.ScanLedger = scanledger.NewMemoryLedger(10000)
+
+	// NOPASS_POLICY_GIT_REPO syncs the policy files (policy.yaml with the
+	// prompt rules, path thresholds, refusals and per-tenant overrides, the
+	// system prompt, masking rules and blocklist) from a Git branch (NOPASS_POLICY_GIT_BRANCH, default main;
+	// files under NOPASS_POLICY_GIT_PATH). It is polled every
+	// NOPASS_POLICY_SYNC_INTERVAL (default 1m, 0 for webhook only) and
+	// POST /internal/policy/sync pulls immediately, authenticated with
+	// NOPASS_POLICY_WEBHOOK_SECRET.
+	//
+	// NOPASS_POLICY_PUBLIC_KEYS (comma-separated minisign public keys)
+	// restricts policy to signed bundles: the Git sync then loads only
+	// policy.tar.gz and its .minisig, and NOPASS_POLICY_BUNDLE names a local
+	// bundle to load at startup. A bundle that fails verification is never
+	// applied.
+	//
+	// Without Git, NOPASS_POLICY_DIR loads the policy files from a local
+	// directory, reloaded on SIGHUP.
+	var policyKeys []policy.PublicKey
+	if v := os.Getenv("NOPASS_POLICY_PUBLIC_KEYS"); v != "" {
+		keys, err := policy.ParsePublicKeys(v)
+		if err != nil {
+			log.Fatalf("invalid NOPASS_POLICY_PUBLIC_KEYS: %v", err)
+		}
+		policyKeys = keys
+	}
+	if path := os.Getenv("NOPASS_POLICY_BUNDLE"); path != "" {
+		if len(policyKeys) == 0 {
+			log.Fatal("NOPASS_POLICY_BUNDLE requires NOPASS_POLICY_PUBLIC_KEYS")
+		}
+		set, err := policy.LoadBundle(path, policyKeys)
+		if err != nil {
+			log.Fatalf("policy bundle %s rejected: %v", path, err)
+		}
+		handler.Policies = &policy.Store{}
+		handler.Policies.Apply(set)
+	}
+	if dir := os.Getenv("NOPASS_POLICY_DIR"); dir != "" {
+		if len(policyKeys) > 0 || handler.Policies != nil {
+			log.Fatal("NOPASS_POLICY_DIR can't be combined with signed policy bundles")
+		}
+		set, err := policy.LoadDir(dir, time.Now().UTC().Format("dir-20060102T150405Z"))
+		if err != nil {
+			log.Fatalf("policy directory %s rejected: %v", dir, err)
+		}
+		handler.Policies = &policy.Store{}
+		handler.Policies.Apply(set)
+		go reloadPolicyOnSIGHUP(dir, handler.Policies, maint)
+	}
+	var policySync *policy.GitSyncer
+	if repo := os.Getenv("NOPASS_POLICY_GIT_REPO"); repo != "" {
+		if os.Getenv("NOPASS_POLICY_DIR") != "" {
+			log.Fatal("set NOPASS_POLICY_DIR or NOPASS_POLICY_GIT_REPO, not both")
+		}
+		policySync = &policy.GitSyncer{
+			Store:    &policy.Store{},
+			Repo:     repo,
+			Branch:   os.Getenv("NOPASS_POLICY_GIT_BRANCH"),
+			Path:     os.Getenv("NOPASS_POLICY_GIT_PATH"),
+			Dir:      os.Getenv("NOPASS_POLICY_GIT_DIR"),
+			Interval: time.Minute,
+			Secret:   os.Getenv("NOPASS_POLICY_WEBHOOK_SECRET"),
+			Keys:     policyKeys,
+			Hold:     maint.ReadOnly,
+		}
+		if handler.Policies != nil {
+			// The local bundle stays active until a commit verifies.
+			policySync.Store = handler.Policies
+		}
+		if policySync.Dir == "" {
+			policySync.Dir = filepath.Join(os.TempDir(), "nopass-policy")
+		}
+		if v := os.Getenv("NOPASS_POLICY_SYNC_INTERVAL"); v != "" {
+			d, err := time.ParseDuration(v)
+			if err != nil || d < 0 {
+				log.Fatalf("invalid NOPASS_POLICY_SYNC_INTERVAL %q", v)
+			}
+			policySync.Interval = d
+		}
+		// Until the first sync succeeds the built-in defaults apply.
+		if err := policySync.Sync(context.Background()); err != nil {
+			log.Printf("initial policy sync failed, using built-in policy: %v", err)
+		}
+		handler.Policies = policySync.Store
+		go policySync.Run(context.Background())
+	}
+	// The in-process review engine checks answers against the synced
+	// policy set, so it is wired once that is in place.
+	handler.OutputReviewer = withOutputEngine(handler.OutputReviewer, cfg, handler.Policies)
+
+	// NOPASS_APPROVAL_GATES="legal_sensitive=https://approvals/hook" holds
+	// answers carrying those flags until the webhook approves them, waiting
+	// at most NOPASS_APPROVAL_TIMEOUT (default 5s) before applying
+	// NOPASS_APPROVAL_DEFAULT (default deny).
+	if v := os.Getenv("NOPASS_APPROVAL_GATES"); v != "" {
+		hooks, err := approval.ParseHooks(v)
+		if err != nil {
+			log.Fatalf("invalid NOPASS_APPROVAL_GATES: %v", err)
+		}
+		timeout := 5 * time.Second
+		if tv := os.Getenv("NOPASS_APPROVAL_TIMEOUT"); tv != "" {
+			if timeout, err = time.ParseDuration(tv); err != nil || timeout <= 0 {
+				log.Fatalf("invalid NOPASS_APPROVAL_TIMEOUT %q", tv)
+			}
+		}
+		def := approval.Deny
+		if dv := os.Getenv("NOPASS_APPROVAL_DEFAULT"); dv != "" {
+			if def, err = approval.ParseDecision(dv); err != nil {
+				log.Fatalf("invalid NOPASS_APPROVAL_DEFAULT: %v", err)
+			}
+		}
+		handler.Approvals = approval.NewGate(hooks, timeout, def)
+	}
+
+	// Sandbox receipts (measured usage per run) for cost accounting.
+	handler.Receipts = receipts.NewMemoryStore(100000)
+
+	// NOPASS_EXCLUDE_ON_SCAN_FAILURE=1 leaves external data that couldn't be
+	// scanned out of the prompt entirely (failure.external_scan fail_closed).
+	handler.ExcludeOnScanFailure = os.Getenv("NOPASS_EXCLUDE_ON_SCAN_FAILURE") == "1"
+
+	handler.ScanConcurrency = cfg.Scan.Concurrency
+	handler.ScanTimeout = cfg.Scan.Timeout
+
+	// NOPASS_REGION names the region this instance runs in. With
+	// NOPASS_TENANT_REGIONS="acme=eu,globex=us" set, tenants pinned to a
+	// region are only processed there; NOPASS_SERVED_REGIONS lists extra
+	// regions this instance may process, each with its own
+	// NOPASS_RISK_URL_<REGION> and NOPASS_OUTPUT_URL_<REGION> services,
+	// its own NOPASS_STORAGE_BACKEND_<REGION> and NOPASS_STORAGE_DSN_<REGION>
+	// storage and, when enabled, NOPASS_FEATURES_SINK_<REGION>,
+	// NOPASS_UPLOAD_DIR_<REGION> and NOPASS_PII_DETECTOR_URL_<REGION>. The
+	// audit log must use the storage sink, neither artifacts nor mask
+	// samples can be kept for other regions, and only the local region's
+	// requests are mirrored to a canary.
+	handlers := map[string]*gateway.Handler{"": handler}
+	stores := map[string]storage.Store{"": store}
+	var residencyPolicy *residency.Policy
+	if v := os.Getenv("NOPASS_TENANT_REGIONS"); v != "" {
+		tenants, err := residency.ParseTenantRegions(v)
+		if err != nil {
+			log.Fatalf("invalid NOPASS_TENANT_REGIONS: %v", err)
+		}
+		residencyPolicy = &residency.Policy{Local: os.Getenv("NOPASS_REGION"), Tenants: tenants}
+		if residencyPolicy.Local == "" {
+			log.Fatalf("NOPASS_REGION is required with NOPASS_TENANT_REGIONS")
+		}
+		for _, region := range strings.Split(os.Getenv("NOPASS_SERVED_REGIONS"), ",") {
+			if region = strings.TrimSpace(region); region != "" {
+				residencyPolicy.Served = append(residencyPolicy.Served, region)
+			}
+		}
+		handlers = map[string]*gateway.Handler{residencyPolicy.Local: handler}
+		stores = map[string]storage.Store{residencyPolicy.Local: store}
+		for _, region := range residencyPolicy.Regions() {
+			if region == residencyPolicy.Local {
+				continue
+			}
+			h, s, err := regionalHandler(handler, region, cfg)
+			if err != nil {
+				log.Fatalf("region %s: %v", region, err)
+			}
+			defer s.Close()
+			handlers[region], stores[region] = h, s
+		}
+		log.Printf("data residency enabled: local region %s, serving %s",
+			residencyPolicy.Local, strings.Join(residencyPolicy.Regions(), ", "))
+	}
+
+	for _, h := range handlers {
+		if h.DataStore == nil {
+			continue
+		}
+		h := h
+		rescanner := &rescan.Rescanner{
+			Store:         h.DataStore,
+			Scan:          h.RescanContent,
+			PolicyVersion: func() string { return h.PolicyVersion },
+			Events:        sinks,
+			Interval:      10 * time.Minute,
+			Workers:       2,
+		}
+		go rescanner.Run(context.Background())
+	}
+
+	// NOPASS_API_KEYS_FILE (a static JSON file of key hashes) or
+	// NOPASS_API_KEYS=storage (keys issued with `nopass apikey`) requires
+	// an API key on every /v1 endpoint; the key pins the tenant.
+	// NOPASS_OIDC_ISSUER requires a bearer JWT from that issuer instead:
+	// its NOPASS_OIDC_USER_CLAIM (default sub) must match the request's
+	// user_id, and its NOPASS_OIDC_TENANT_CLAIM (required) pins the tenant.
+	var authn auth.Middleware
+	if v := os.Getenv("NOPASS_API_KEYS_FILE"); v != "" {
+		keys, err := auth.LoadFile(v)
+		if err != nil {
+			log.Fatalf("invalid NOPASS_API_KEYS_FILE: %v", err)
+		}
+		authn = &auth.Authenticator{Keys: keys, Quotas: store.Quotas()}
+	} else if v := os.Getenv("NOPASS_API_KEYS"); v == "storage" {
+		authn = &auth.Authenticator{Keys: auth.RecordStore{Records: store.Records()}, Quotas: store.Quotas()}
+	} else if v != "" {
+		log.Fatalf("invalid NOPASS_API_KEYS %q", v)
+	}
+	if v := os.Getenv("NOPASS_OIDC_ISSUER"); v != "" {
+		if authn != nil {
+			log.Fatal("NOPASS_OIDC_ISSUER and API keys are alternative auth modes; set one")
+		}
+		if os.Getenv("NOPASS_OIDC_TENANT_CLAIM") == "" {
+			log.Fatal("NOPASS_OIDC_ISSUER requires NOPASS_OIDC_TENANT_CLAIM")
+		}
+		authn = &auth.OIDC{
+			Issuer:      v,
+			Audience:    os.Getenv("NOPASS_OIDC_AUDIENCE"),
+			JWKSURL:     os.Getenv("NOPASS_OIDC_JWKS_URL"),
+			UserClaim:   os.Getenv("NOPASS_OIDC_USER_CLAIM"),
+			TenantClaim: os.Getenv("NOPASS_OIDC_TENANT_CLAIM"),
+		}
+	}
+
+	// NOPASS_RATE_LIMITS="user=60/m:20,session=20/m,key=600/m" limits
+	// request rates with token buckets per user_id, session_id and API key
+	// (count per s, m or h, then an optional burst). Buckets are shared
+	// through Redis when that is the storage backend, and per instance
+	// otherwise.
+	var limiter *ratelimit.Limiter
+	if v := os.Getenv("NOPASS_RATE_LIMITS"); v != "" {
+		limits, err := ratelimit.ParseLimits(v)
+		if err != nil {
+			log.Fatalf("invalid NOPASS_RATE_LIMITS: %v", err)
+		}
+		var buckets storage.BucketStore = &ratelimit.Memory{}
+		if bs, ok := store.(storage.BucketStore); ok {
+			buckets = bs
+		}
+		limiter = &ratelimit.Limiter{Buckets: buckets, Limits: limits, Caller: gateway.RequestCaller}
+	}
+
+	// trusted serves the same /v1 routes without API key checks, for
+	// in-process callers (the canary comparer replaying mirrored requests).
+	trusted := http.NewServeMux()
+	// Maintenance mode closes these to clients; in-process callers, such
+	// as jobs already accepted, carry on.
+	chatRoutes := map[string]bool{"/v1/chat": true, "/v1/chat/completions": true, "/v1/jobs": true}
+	route := func(pattern string, fn func(*gateway.Handler) http.HandlerFunc) {
+		var next http.Handler = fn(handler)
+		if residencyPolicy != nil {
+			rt := &residency.Router{
+				Policy:   residencyPolicy,
+				Handlers: make(map[string]http.Handler),
+				Tenant:   gateway.RequestTenant,
+			}
+			for region, h := range handlers {
+				rt.Handlers[region] = fn(h)
+			}
+			next = rt
+		}
+		trusted.Handle(pattern, next)
+		if limiter != nil {
+			next = limiter.Wrap(next)
+		}
+		if chatRoutes[pattern] {
+			next = maint.Wrap(next)
+		}
+		if authn != nil {
+			next = authn.Wrap(next)
+		}
+		mux.Handle(pattern, next)
+	}
+	route("/v1/chat", func(h *gateway.Handler) http.HandlerFunc { return h.ChatHandler })
+	route("/v1/chat/completions", func(h *gateway.Handler) http.HandlerFunc { return h.CompletionsHandler })
+	route("/v1/receipts", func(h *gateway.Handler) http.HandlerFunc { return h.ReceiptsHandler })
+	route("/v1/feedback", func(h *gateway.Handler) http.HandlerFunc { return h.FeedbackHandler })
+	if explanations {
+		route("/v1/requests/{id}/explanation", func(h *gateway.Handler) http.HandlerFunc { return h.ExplanationHandler })
+	}
+	if handler.Artifacts != nil {
+		// The signed URL is the credential, so downloads skip the API key.
+		mux.HandleFunc("/v1/artifacts/{id}", handler.Artifacts.Handler)
+	}
+	if dataRegistration {
+		route("/v1/data", func(h *gateway.Handler) http.HandlerFunc { return h.DataHandler })
+		route("/v1/data/{id}", func(h *gateway.Handler) http.HandlerFunc { return h.DataItemHandler })
+		route("/v1/data/{id}/rescan", func(h *gateway.Handler) http.HandlerFunc { return h.DataRescanHandler })
+		route("/v1/uploads", func(h *gateway.Handler) http.HandlerFunc { return h.UploadHandler })
+		route("/v1/uploads/{id}", func(h *gateway.Handler) http.HandlerFunc { return h.UploadItemHandler })
+		route("/v1/uploads/{id}/complete", func(h *gateway.Handler) http.HandlerFunc { return h.UploadCompleteHandler })
+	}
+
+	if scimSrv != nil {
+		mux.Handle("/scim/v2/", maint.GuardWrites(scimSrv.Handler()))
+	}
+	if policySync != nil {
+		mux.Handle("/internal/policy/", maint.Guard(policySync.Handler()))
+	}
+	// NOPASS_JOBS=1 enables batch jobs at /v1/jobs, answered in the
+	// background through the same pipeline and checkpointed item by item
+	// in the storage backend; jobs left unfinished by a restart resume.
+	// NOPASS_JOBS_CONCURRENCY (default 4) is how many items of a job run
+	// at once.
+	if os.Getenv("NOPASS_JOBS") == "1" {
+		concurrency := 0
+		if v := os.Getenv("NOPASS_JOBS_CONCURRENCY"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n <= 0 {
+				log.Fatalf("invalid NOPASS_JOBS_CONCURRENCY %q", v)
+			}
+			concurrency = n
+		}
+		// Each region's jobs are checkpointed in its own storage.
+		for region, h := range handlers {
+			h.Jobs = &jobs.Runner{Chat: trusted, Records: stores[region].Records(), Concurrency: concurrency}
+			if err := h.Jobs.Resume(context.Background()); err != nil {
+				log.Fatalf("resume batch jobs: %v", err)
+			}
+		}
+		route("/v1/jobs", func(h *gateway.Handler) http.HandlerFunc { return h.JobsHandler })
+		route("/v1/jobs/{id}", func(h *gateway.Handler) http.HandlerFunc { return h.JobHandler })
+	}
+
+	var comparer *canary.Comparer
+	if canaryMode {
+		comparer = &canary.Comparer{Chat: trusted, Secret: canarySecret}
+		mux.Handle("/internal/canary/", comparer.Handler())
+		log.Printf("canary mode: comparing mirrored requests")
+	}
+
+	// NOPASS_ADMIN_LISTEN serves the operator UI, admin APIs and Prometheus
+	// /metrics on their own port (default 127.0.0.1:8083, "off" to
+	// disable), protected by NOPASS_ADMIN_TOKEN when set.
+	adminAddr := os.Getenv("NOPASS_ADMIN_LISTEN")
+	if adminAddr == "" {
+		adminAddr = "127.0.0.1:8083"
+	}
+	if adminAddr != "off" {
+		adminSrv := &admin.Server{
+			Store: store,
+			Token: os.Getenv("NOPASS_ADMIN_TOKEN"),
+			Config: func() any {
+				return map[string]any{
+					"policy_version":          handler.PolicyVersion,
+					"policy_set":              policySetVersion(handler.Policies),
+					"prompt_source":           handler.PromptSource,
+					"risk_engine":             cfg.RiskEngine,
+					"output_engine":           cfg.OutputEngine,
+					"sandbox_mode":            cfg.Sandbox.Mode,
+					"sandbox_image":           cfg.Sandbox.Image,
+					"runtime":                 handler.Settings.Load(),
+					"storage_backend":         os.Getenv("NOPASS_STORAGE_BACKEND"),
+					"max_message_bytes":       handler.MaxMessageBytes,
+					"exclude_on_scan_failure": handler.ExcludeOnScanFailure,
+					"scan_concurrency":        handler.ScanConcurrency,
+					"data_registration":       handler.DataStore != nil,
+					"retrieval":               handler.Retrieval != nil,
+					"memory":                  handler.Memory != nil,
+					"mask_sampling":           maskSamples != nil,
+					"regions":                 residencyRegions(residencyPolicy),
+				}
+			},
+		}
+		if handler.AuditLog != nil {
+			adminSrv.AuditLog, _ = handler.AuditLog.Sink.(audit.Source)
+		}
+		adminSrv.Tuning = handler.Tuning
+		adminSrv.MaskSamples = maskSamples
+		adminSrv.SampleToken = os.Getenv("NOPASS_MASK_SAMPLE_TOKEN")
+		// Session exports (/admin/api/sessions/{id}/export) redact fully or
+		// partially for admin token holders; NOPASS_EXPORT_AUDITOR_TOKEN is
+		// the privileged auditors' token for unredacted ones.
+		adminSrv.Vault = handler.Vault
+		adminSrv.AuditorToken = os.Getenv("NOPASS_EXPORT_AUDITOR_TOKEN")
+		adminSrv.Maintenance = maint
+		if comparer != nil {
+			adminSrv.Canary = func() any { return comparer.Report() }
+		}
+		if sched != nil {
+			adminSrv.Runners = func() any { return sched.Snapshot() }
+		}
+		if adminSrv.Token == "" {
+			log.Printf("warning: NOPASS_ADMIN_TOKEN is not set; admin APIs are unauthenticated")
+		}
+		go func() {
+			log.Printf("NoPass admin UI listening on %s", adminAddr)
+			if err := http.ListenAndServe(adminAddr, gateway.Recover(adminSrv.Handler())); err != nil {
+				log.Fatalf("admin server failed: %v", err)
+			}
+		}()
+	}
+
+	// /healthz reports liveness and /readyz readiness. NOPASS_WARMUP=1
+	// holds readiness back until downstream connections are open, the
+	// local sandbox image has been pulled and run once and topic policy
+	// embeddings are cached, or NOPASS_WARMUP_TIMEOUT (default 2m) passes.
+	warmer := &warmup.Warmer{}
+	mux.HandleFunc("/healthz", warmup.HealthHandler)
+	mux.HandleFunc("/readyz", warmer.ReadyHandler)
+	if os.Getenv("NOPASS_WARMUP") == "1" {
+		if v := os.Getenv("NOPASS_WARMUP_TIMEOUT"); v != "" {
+			d, err := time.ParseDuration(v)
+			if err != nil || d <= 0 {
+				log.Fatalf("invalid NOPASS_WARMUP_TIMEOUT %q", v)
+			}
+			warmer.Timeout = d
+		}
+		urls := []string{cfg.RiskURL, cfg.OutputURL}
+		for _, p := range cfg.Providers {
+			urls = append(urls, p.URL)
+		}
+		warmer.Steps = append(warmer.Steps, warmup.Connect(&http.Client{}, urls...))
+		if cfg.Sandbox.Mode == "local" {
+			warmer.Steps = append(warmer.Steps, warmup.Step{Name: "sandbox", Run: localRunner.Warm})
+		}
+		if kubeRunner != nil {
+			warmer.Steps = append(warmer.Steps, warmup.Step{Name: "sandbox", Run: kubeRunner.Ping})
+		}
+		if handler.Topics != nil {
+			warmer.Steps = append(warmer.Steps, warmup.Step{Name: "topics", Run: handler.Topics.Warm})
+		}
+		go warmer.Run(context.Background())
+	} else {
+		warmer.MarkReady()
+	}
+
+	// grpc_listen serves the chat API over gRPC as well, through the same
+	// handler, so every check on /v1/chat applies to it.
+	var grpcSrv *grpc.Server
+	if cfg.GRPCListen != "" {
+		lis, err := net.Listen("tcp", cfg.GRPCListen)
+		if err != nil {
+			log.Fatalf("grpc listen: %v", err)
+		}
+		grpcSrv = grpc.NewServer()
+		nopassv1.RegisterChatServiceServer(grpcSrv, &grpcapi.Server{HTTP: gateway.Recover(mux)})
+		go func() {
+			log.Printf("NoPass Gateway gRPC listening on %s", cfg.GRPCListen)
+			if err := grpcSrv.Serve(lis); err != nil {
+				log.Fatalf("grpc server failed: %v", err)
+			}
+		}()
+	}
+
+	// SIGTERM or an interrupt stops the gateway gracefully: requests in
+	// flight, gRPC calls included, get 30s to finish, then the audit
+	// records still queued get 30s to be written before the sinks close.
+	srv := &http.Server{Addr: cfg.Listen, Handler: gateway.Recover(mux)}
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		sig := make(chan os.Signal, 1)
+		signal.Notify(sig, syscall.SIGTERM, os.Interrupt)
+		<-sig
+		log.Printf("shutting down")
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		if grpcSrv != nil {
+			go func() {
+				<-ctx.Done()
+				grpcSrv.Stop()
+			}()
+			grpcSrv.GracefulStop()
+		}
+		if err := srv.Shutdown(ctx); err != nil {
+			log.Printf("shutdown: %v", err)
+		}
+		ctx, cancel = context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		for _, h := range handlers {
+			if err := h.AuditLog.Close(ctx); err != nil {
+				log.Printf("shutdown: %v", err)
+			}
+		}
+	}()
+
+	log.Printf("NoPass Gateway listening on %s", cfg.Listen)
+	if err := srv.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
 		log.Fatalf("server failed: %v", err)
 	}
+	<-stopped
+}
+
+func residencyRegions(p *residency.Policy) []string {
+	if p == nil {
+		return nil
+	}
+	return p.Regions()
+}
+
+// regionalHandler copies base for another region, swapping in that region's
+// risk and output safety services and its own backends for everything
+// that keeps request data: the storage backend (sessions, audit records,
+// quarantine, vault, jobs), the audit log, caches, feature export and
+// upload spool, and its own PII detector. Every one that base uses must have a regional backend:
+// falling back to the local one would ship the data across the residency
+// boundary. The region's storage is returned for the caller to close.
+func regionalHandler(base *gateway.Handler, region string, cfg config.Config) (*gateway.Handler, storage.Store, error) {
+	suffix := strings.ToUpper(strings.ReplaceAll(region, "-", "_"))
+	riskURL := os.Getenv("NOPASS_RISK_URL_" + suffix)
+	outputURL := os.Getenv("NOPASS_OUTPUT_URL_" + suffix)
+	if riskURL == "" || outputURL == "" {
+		return nil, nil, fmt.Errorf("NOPASS_RISK_URL_%s and NOPASS_OUTPUT_URL_%s are required", suffix, suffix)
+	}
+	backend := os.Getenv("NOPASS_STORAGE_BACKEND_" + suffix)
+	if backend == "" {
+		return nil, nil, fmt.Errorf("NOPASS_STORAGE_BACKEND_%s is required", suffix)
+	}
+	if base.Artifacts != nil {
+		return nil, nil, errors.New("NOPASS_ARTIFACT_DIR has no regional backend; artifacts can't be kept for other regions")
+	}
+	if base.MaskSamples != nil {
+		return nil, nil, errors.New("mask_samples.rate has no regional backend; other regions' messages can't be sampled")
+	}
+	var featureSink features.Sink
+	if base.Features != nil {
+		v := os.Getenv("NOPASS_FEATURES_SINK_" + suffix)
+		if v == "" {
+			return nil, nil, fmt.Errorf("NOPASS_FEATURES_SINK_%s is required with NOPASS_FEATURES_SINK", suffix)
+		}
+		sink, err := features.ParseSink(v)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid NOPASS_FEATURES_SINK_%s: %w", suffix, err)
+		}
+		featureSink = sink
+	}
+	var piiDetector pii.Detector
+	if d, ok := base.PII.(*pii.HTTPDetector); ok {
+		v := os.Getenv("NOPASS_PII_DETECTOR_URL_" + suffix)
+		if v == "" {
+			return nil, nil, fmt.Errorf("NOPASS_PII_DETECTOR_URL_%s is required with pii.detector_url", suffix)
+		}
+		rd := *d
+		rd.URL = strings.TrimSuffix(v, "/")
+		piiDetector = &rd
+	}
+	uploadDir := os.Getenv("NOPASS_UPLOAD_DIR_" + suffix)
+	if base.Uploads != nil && uploadDir == "" {
+		return nil, nil, fmt.Errorf("NOPASS_UPLOAD_DIR_%s is required with NOPASS_DATA_REGISTRATION", suffix)
+	}
+	if base.AuditLog != nil && cfg.Audit.Sink != "storage" {
+		return nil, nil, fmt.Errorf("audit sink %q has no regional backend; use the storage sink", cfg.Audit.Sink)
+	}
+
+	store, err := storage.Open(context.Background(), storage.Config{
+		Backend:     backend,
+		DSN:         os.Getenv("NOPASS_STORAGE_DSN_" + suffix),
+		Driver:      os.Getenv("NOPASS_STORAGE_DRIVER_" + suffix),
+		AutoMigrate: os.Getenv("NOPASS_STORAGE_AUTO_MIGRATE") == "1",
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("open storage: %w", err)
+	}
+
+	h := *base
+	riskClient := gateway.NewRiskClient(riskURL)
+	riskClient.HTTPClient.Timeout = cfg.Timeouts.Risk
+	riskClient.Breaker = newBreaker("risk_"+region, cfg.Resilience)
+	h.Risk = withRiskEngine(riskClient, cfg.RiskEngine)
+	outputClient := gateway.NewOutputSafetyClient(outputURL)
+	outputClient.HTTPClient.Timeout = cfg.Timeouts.OutputSafety
+	outputClient.Breaker = newBreaker("output_safety_"+region, cfg.Resilience)
+	h.OutputReviewer = withOutputEngine(outputClient, cfg, base.Policies)
+	if piiDetector != nil {
+		h.PII = piiDetector
+	}
+	// The canary is a single deployment in the home region: other regions'
+	// requests aren't mirrored to it.
+	h.Mirror = nil
+	h.Quarantine = store.Quarantine()
+	h.Audit = store.Audit()
+	if base.AuditLog != nil {
+		h.AuditLog = &audit.Log{Sink: store.Audit(), Retention: base.AuditLog.Retention}
+		go h.AuditLog.Run(context.Background())
+	}
+	if base.Vault != nil {
+		h.Vault = vault.New(base.Vault.Keys, store.Vault(), base.Vault.TTL)
+	}
+	var shared storage.CacheStore
+	if cs, ok := store.(storage.CacheStore); ok {
+		shared = cs
+	}
+	h.Answers = base.Answers.Empty(shared)
+	h.Refusals = base.Refusals.Empty()
+	h.Receipts = receipts.NewMemoryStore(100000)
+	if base.Features != nil {
+		h.Features = features.NewExporter(featureSink, base.Features.Salt)
+		go h.Features.Run(context.Background())
+	}
+	if base.DataStore != nil {
+		h.DataStore = datastore.NewMemoryStore()
+	}
+	if base.Uploads != nil {
+		spool, err := ingest.NewSpool(uploadDir, base.Uploads.MaxBytes, base.Uploads.TTL)
+		if err != nil {
+			store.Close()
+			return nil, nil, err
+		}
+		h.Uploads = spool
+		go spool.Run(context.Background())
+	}
+	// Session summaries and stored turns are user data too.
+	sessions := store.Sessions()
+	if base.Memory != nil {
+		mem := *base.Memory
+		mem.Store = memory.SessionStore{Sessions: sessions}
+		h.Memory = &mem
+	}
+	if base.History != nil {
+		hist := *base.History
+		hist.Sessions = sessions
+		h.History = &hist
+	}
+	if base.RiskLedger != nil {
+		ledger := *base.RiskLedger
+		ledger.Sessions = sessions
+		h.RiskLedger = &ledger
+	}
+	return &h, store, nil
+}
+
+// buildReviewPanel parses NOPASS_OUTPUT_REVIEWERS plus the strategy
+// settings: NOPASS_REVIEW_STRATEGY is the default and
+// NOPASS_REVIEW_STRATEGY_<LEVEL> overrides it for one risk level. Each
+// reviewer gets the output safety timeout.
+func buildReviewPanel(spec string, timeout time.Duration, resilience config.Resilience) (*review.Panel, error) {
+	panel := &review.Panel{
+		Default: review.StrategyStrictestWins,
+		ByRisk:  make(map[types.RiskLevel]review.Strategy),
+	}
+	for _, entry := range strings.Split(spec, ",") {
+		name, url, ok := strings.Cut(strings.TrimSpace(entry), "=")
+		if !ok || name == "" || url == "" {
+			return nil, fmt.Errorf("reviewer entry %q: want name=url", entry)
+		}
+		client := gateway.NewOutputSafetyClient(url)
+		client.HTTPClient.Timeout = timeout
+		client.Breaker = newBreaker("output_safety_"+name, resilience)
+		panel.Reviewers = append(panel.Reviewers, review.Named{
+			Name:     name,
+			Reviewer: client,
+		})
+	}
+
+	if v := os.Getenv("NOPASS_REVIEW_STRATEGY"); v != "" {
+		st, err := review.ParseStrategy(v)
+		if err != nil {
+			return nil, err
+		}
+		panel.Default = st
+	}
+	for _, level := range []types.RiskLevel{types.RiskLow, types.RiskMedium, types.RiskHigh} {
+		if v := os.Getenv("NOPASS_REVIEW_STRATEGY_" + string(level)); v != "" {
+			st, err := review.ParseStrategy(v)
+			if err != nil {
+				return nil, err
+			}
+			panel.ByRisk[level] = st
+		}
+	}
+	return panel, nil
+}
+
+// policySetVersion reports the synced policy set in use, if any.
+func policySetVersion(s *policy.Store) string {
+	if set := s.Current(); set != nil {
+		return set.Version
+	}
+	return ""
+}
+
+// reloadOnSIGHUP re-reads the config on every SIGHUP. A config that fails
+// to load or validate is rejected and the running settings are kept;
+// settings that need a restart are reported but not applied. Nothing is
+// reloaded in read-only mode.
+func reloadOnSIGHUP(path string, running config.Config, live *config.Live, maint *maintenance.Switch) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	for range hup {
+		if maint.ReadOnly() {
+			log.Printf("config reload refused: read-only mode")
+			continue
+		}
+		cfg, err := config.Load(path)
+		if err != nil {
+			log.Printf("config reload rejected: %v", err)
+			continue
+		}
+		live.Store(cfg.Runtime)
+		if changed := config.RestartRequired(running, cfg); len(changed) > 0 {
+			log.Printf("config reloaded; restart to apply changes to: %s", strings.Join(changed, ", "))
+		} else {
+			log.Printf("config reloaded")
+		}
+	}
+}
+
+// reloadPolicyOnSIGHUP reloads the policy directory into store on SIGHUP.
+// A directory that fails to load leaves the active set in place, as does
+// read-only mode.
+func reloadPolicyOnSIGHUP(dir string, store *policy.Store, maint *maintenance.Switch) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	for range hup {
+		if maint.ReadOnly() {
+			log.Printf("policy reload refused: read-only mode")
+			continue
+		}
+		set, err := policy.LoadDir(dir, time.Now().UTC().Format("dir-20060102T150405Z"))
+		if err != nil {
+			log.Printf("policy reload rejected: %v", err)
+			continue
+		}
+		store.Apply(set)
+	}
+}
+
+// withRiskEngine applies the risk_engine setting to the risk service
+// client.
+func withRiskEngine(client *gateway.RiskClient, engine string) risk.Scorer {
+	switch engine {
+	case "builtin":
+		return risk.Engine{}
+	case "fallback":
+		return &risk.Fallback{Primary: client, Secondary: risk.Engine{}}
+	}
+	return client
+}
+
+// newBreaker returns the circuit breaker for downstream service name.
+func newBreaker(name string, r config.Resilience) *breaker.Breaker {
+	b := breaker.New(name)
+	b.Retries, b.Backoff = r.Retries, r.Backoff
+	b.FailureRate, b.Window, b.MinCalls, b.Cooldown = r.FailureRate, r.Window, r.MinCalls, r.Cooldown
+	return b
+}
+
+// sandboxLimits converts the configured container limits to the runner's.
+func sandboxLimits(l config.SandboxLimits) orchestrator.Limits {
+	return orchestrator.Limits{
+		Memory:          int64(l.MemoryMB) << 20,
+		CPUs:            l.CPUs,
+		Pids:            int64(l.Pids),
+		NoNewPrivileges: l.NoNewPrivileges,
+		ReadOnlyRootfs:  l.ReadOnlyRootfs,
+		TmpfsSize:       int64(l.TmpfsMB) << 20,
+	}
+}
+
+// withOutputEngine applies the output_engine setting to the output safety
+// reviewer. The moderation model runs in the local Docker sandbox whatever
+// the sandbox mode.
+func withOutputEngine(reviewer review.OutputReviewer, cfg config.Config, policies *policy.Store) review.OutputReviewer {
+	switch cfg.OutputEngine {
+	case "builtin":
+		return review.Engine{Policies: policies}
+	case "fallback":
+		return &review.Fallback{Primary: reviewer, Secondary: review.Engine{Policies: policies}}
+	case "moderation":
+		// The moderation image reads its input from files.
+		inputMode := cfg.Sandbox.InputMode
+		if inputMode == orchestrator.InputStdin {
+			inputMode = orchestrator.InputBind
+		}
+		return &review.Moderator{
+			Runner: orchestrator.NewLLMRunnerWithConfig(orchestrator.SandboxConfig{
+				ImageName: cfg.Sandbox.ModerationImage,
+				Timeout:   cfg.Timeouts.OutputSafety,
+				InputMode: inputMode,
+				TempDir:   cfg.Sandbox.TempDir,
+				// The moderation image prints its verdict as plain text.
+				Output:  orchestrator.OutputText,
+				Limits:  sandboxLimits(cfg.Sandbox.Limits),
+				Runtime: cfg.Sandbox.Runtime,
+			}),
+			Checks: review.Engine{Policies: policies},
+		}
+	}
+	return reviewer
 }
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/shivansh-source/nopass/internal/sdnotify"
+)
+
+// probeInterval is how often the Docker daemon is checked.
+const probeInterval = 15 * time.Second
+
+// probe checks the Docker daemon and records the outcome, logging
+// changes.
+func (s *runnerServer) probe(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	err := s.llm.Ping(ctx)
+	var prev error
+	if p := s.dockerErr.Swap(&err); p != nil {
+		prev = *p
+	}
+	switch {
+	case err != nil && (prev == nil || prev.Error() != err.Error()):
+		log.Printf("runner unhealthy: %v", err)
+	case err == nil && prev != nil:
+		log.Printf("runner healthy again")
+	default:
+		return
+	}
+	notify(sdnotify.Status(s.status()))
+}
+
+func (s *runnerServer) probeLoop() {
+	ticker := time.NewTicker(probeInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		s.probe(context.Background())
+	}
+}
+
+// healthErr is why the runner can't run sandboxes, or nil.
+func (s *runnerServer) healthErr() error {
+	if p := s.dockerErr.Load(); p != nil {
+		return *p
+	}
+	return nil
+}
+
+// status is the one-line state shown by systemctl status.
+func (s *runnerServer) status() string {
+	switch err := s.healthErr(); {
+	case s.draining.Load():
+		return "draining"
+	case err != nil:
+		return "unhealthy: " + err.Error()
+	default:
+		return fmt.Sprintf("serving, capacity %d", s.capacity)
+	}
+}
+
+// HealthHandler serves /healthz: 200 while the Docker daemon answers,
+// 503 when it doesn't. It is what a Docker HEALTHCHECK or a liveness
+// probe should watch.
+func (s *runnerServer) HealthHandler(w http.ResponseWriter, r *http.Request) {
+	if err := s.healthErr(); err != nil {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	w.Write([]byte("ok\n"))
+}
+
+// ReadyHandler serves /readyz: 200 while the runner takes new runs, 503
+// when it is unhealthy or draining.
+func (s *runnerServer) ReadyHandler(w http.ResponseWriter, r *http.Request) {
+	if s.draining.Load() {
+		http.Error(w, "draining", http.StatusServiceUnavailable)
+		return
+	}
+	s.HealthHandler(w, r)
+}
+
+// watchdog pings the systemd watchdog, if the unit has one, for as long
+// as the runner's own HTTP server answers at addr; a wedged runner is
+// then killed and restarted. Docker being down doesn't stop the pings,
+// as restarting the runner wouldn't bring it back.
+func (s *runnerServer) watchdog(addr string) {
+	interval := sdnotify.WatchdogInterval()
+	if interval == 0 {
+		return
+	}
+	client := &http.Client{Timeout: interval / 2}
+	ticker := time.NewTicker(interval / 2)
+	defer ticker.Stop()
+	for range ticker.C {
+		resp, err := client.Get("http://" + loopback(addr) + "/healthz")
+		if err != nil {
+			log.Printf("watchdog: runner not answering: %v", err)
+			continue
+		}
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+		notify(sdnotify.Watchdog)
+	}
+}
+
+// notify sends states to systemd, logging failures.
+func notify(states ...string) {
+	if _, err := sdnotify.Notify(strings.Join(states, "\n")); err != nil {
+		log.Printf("sd_notify: %v", err)
+	}
+}
+
+// healthcheck queries the /healthz of the runner listening on addr and
+// returns the exit status for a Docker HEALTHCHECK: 0 healthy, 1 not.
+func healthcheck(addr string) int {
+	client := &http.Client{Timeout: 5 * time.Second}
+	resp, err := client.Get("http://" + loopback(addr) + "/healthz")
+	if err != nil {
+		log.Printf("healthcheck: %v", err)
+		return 1
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	if resp.StatusCode != http.StatusOK {
+		log.Printf("healthcheck: %s: %s", resp.Status, strings.TrimSpace(string(body)))
+		return 1
+	}
+	return 0
+}
+
+// loopback returns listen address addr with an unspecified host replaced
+// by 127.0.0.1, for connecting to ourselves.
+func loopback(addr string) string {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return addr
+	}
+	if ip := net.ParseIP(host); host == "" || (ip != nil && ip.IsUnspecified()) {
+		host = "127.0.0.1"
+	}
+	return net.JoinHostPort(host, port)
+}
@@ -0,0 +1,297 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"slices"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/shivansh-source/nopass/internal/orchestrator"
+	"github.com/shivansh-source/nopass/internal/scheduler"
+	"github.com/shivansh-source/nopass/internal/sdnotify"
+	"github.com/shivansh-source/nopass/internal/types"
+)
+
+// nopass-runner exposes the local Docker sandbox to a fleet of gateways.
+// It heartbeats its capacity and load to every gateway listed in
+// NOPASS_COORDINATOR_URLS (their fleet listeners), which schedule work
+// onto it. NOPASS_RUNNER_TOKEN is the secret it shares with the gateways,
+// listed under its ID in their NOPASS_RUNNER_TOKENS: heartbeats carry it
+// and /v1/run refuses requests without it.
+//
+// Under systemd (Type=notify, see nopass-runner.service) it reports
+// readiness once the Docker daemon answers and pings the watchdog while
+// its HTTP server responds. SIGTERM drains it: gateways are told to stop
+// scheduling onto it and in-flight runs get NOPASS_RUNNER_DRAIN_TIMEOUT
+// (default 60s) to finish before it exits. "nopass-runner healthcheck"
+// queries /healthz and exits 0 or 1, for a Docker HEALTHCHECK in images
+// without curl.
+func main() {
+	addr := os.Getenv("NOPASS_RUNNER_LISTEN")
+	if addr == "" {
+		addr = ":8090"
+	}
+	if len(os.Args) > 1 && os.Args[1] == "healthcheck" {
+		os.Exit(healthcheck(addr))
+	}
+
+	advertise := os.Getenv("NOPASS_RUNNER_ADVERTISE_URL")
+	if advertise == "" {
+		advertise = "http://localhost" + addr
+	}
+
+	id := os.Getenv("NOPASS_RUNNER_ID")
+	if id == "" {
+		id, _ = os.Hostname()
+	}
+
+	token := os.Getenv("NOPASS_RUNNER_TOKEN")
+	if token == "" {
+		log.Fatalf("NOPASS_RUNNER_TOKEN is required")
+	}
+
+	capacity := 4
+	if v := os.Getenv("NOPASS_RUNNER_CAPACITY"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			log.Fatalf("invalid NOPASS_RUNNER_CAPACITY %q", v)
+		}
+		capacity = n
+	}
+
+	var coordinators []string
+	for _, u := range strings.Split(os.Getenv("NOPASS_COORDINATOR_URLS"), ",") {
+		if u = strings.TrimSpace(u); u != "" {
+			coordinators = append(coordinators, u)
+		}
+	}
+
+	llm := orchestrator.NewLLMRunner()
+	// NOPASS_SANDBOX_OUTPUT (frames, text or auto, the default) and
+	// NOPASS_SANDBOX_IMAGE_OUTPUTS ("image=text,...") select the stdout
+	// protocol images are expected to speak.
+	if v := os.Getenv("NOPASS_SANDBOX_OUTPUT"); v != "" {
+		if err := llm.SetOutput(v); err != nil {
+			log.Fatalf("invalid NOPASS_SANDBOX_OUTPUT %q", v)
+		}
+	}
+	if v := os.Getenv("NOPASS_SANDBOX_IMAGE_OUTPUTS"); v != "" {
+		outputs, err := orchestrator.ParseImageOutputs(v)
+		if err != nil {
+			log.Fatalf("invalid NOPASS_SANDBOX_IMAGE_OUTPUTS: %v", err)
+		}
+		llm.SetImageOutputs(outputs)
+	}
+	// NOPASS_SANDBOX_RUNTIME runs the sandbox under another OCI runtime
+	// registered with the daemon, e.g. runsc (gVisor) or kata.
+	llm.SetRuntime(os.Getenv("NOPASS_SANDBOX_RUNTIME"))
+	if v := os.Getenv("NOPASS_TENANT_IMAGES"); v != "" {
+		images, err := orchestrator.ParseTenantImages(v)
+		if err != nil {
+			log.Fatalf("invalid NOPASS_TENANT_IMAGES: %v", err)
+		}
+		if err := llm.SetTenantImages(&orchestrator.ImagePolicy{Tenants: images}); err != nil {
+			log.Fatalf("invalid tenant image policy: %v", err)
+		}
+	}
+
+	// NOPASS_RUNNER_TENANTS dedicates this host to the listed tenants: runs
+	// for anyone else are refused. Gateways schedule by their own
+	// assignment (NOPASS_FLEET_DEDICATED), which should match.
+	var tenants []string
+	for _, t := range strings.Split(os.Getenv("NOPASS_RUNNER_TENANTS"), ",") {
+		if t = strings.TrimSpace(t); t != "" {
+			tenants = append(tenants, t)
+		}
+	}
+
+	drainTimeout := 60 * time.Second
+	if v := os.Getenv("NOPASS_RUNNER_DRAIN_TIMEOUT"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d < 0 {
+			log.Fatalf("invalid NOPASS_RUNNER_DRAIN_TIMEOUT %q", v)
+		}
+		drainTimeout = d
+	}
+
+	srv := &runnerServer{
+		llm:          llm,
+		capacity:     int64(capacity),
+		coordinators: coordinators,
+		token:        token,
+		heartbeat: types.RunnerHeartbeat{
+			SchemaVersion: types.SchemaVersion,
+			ID:            id,
+			Addr:          advertise,
+			Capacity:      capacity,
+			Tenants:       tenants,
+		},
+	}
+
+	// The first probe settles health before anything is reported.
+	srv.probe(context.Background())
+	go srv.probeLoop()
+	go srv.heartbeatLoop()
+
+	mux := http.NewServeMux()
+	mux.Handle("/v1/run", scheduler.RequireToken(token, http.HandlerFunc(srv.RunHandler)))
+	mux.HandleFunc("/healthz", srv.HealthHandler)
+	mux.HandleFunc("/readyz", srv.ReadyHandler)
+
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		log.Fatalf("listen on %s: %v", addr, err)
+	}
+	httpSrv := &http.Server{Handler: mux}
+	serveErr := make(chan error, 1)
+	go func() { serveErr <- httpSrv.Serve(ln) }()
+
+	log.Printf("NoPass runner %s listening on %s (capacity=%d)", id, ln.Addr(), capacity)
+	notify(sdnotify.Ready, sdnotify.Status(srv.status()))
+	go srv.watchdog(ln.Addr().String())
+
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, syscall.SIGTERM, syscall.SIGINT)
+	select {
+	case err := <-serveErr:
+		// Exit non-zero so the service manager restarts us.
+		log.Fatalf("server failed: %v", err)
+	case sig := <-stop:
+		log.Printf("%s: draining (%d runs in flight, timeout %s)", sig, srv.inFlight.Load(), drainTimeout)
+	}
+	notify(sdnotify.Stopping, sdnotify.Status("draining"))
+	srv.draining.Store(true)
+	srv.sendHeartbeats()
+
+	ctx, cancel := context.WithTimeout(context.Background(), drainTimeout)
+	defer cancel()
+	if err := httpSrv.Shutdown(ctx); err != nil {
+		log.Printf("drain incomplete, %d runs abandoned: %v", srv.inFlight.Load(), err)
+		os.Exit(1)
+	}
+	log.Printf("drained; exiting")
+}
+
+type runnerServer struct {
+	llm          *orchestrator.LLMRunner
+	capacity     int64
+	inFlight     atomic.Int64
+	coordinators []string
+	token        string
+	heartbeat    types.RunnerHeartbeat
+
+	// draining is set on SIGTERM: new runs are refused and gateways are
+	// told to schedule elsewhere.
+	draining atomic.Bool
+	// dockerErr is the last Docker probe's failure, nil when it passed.
+	dockerErr atomic.Pointer[error]
+}
+
+func (s *runnerServer) RunHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if s.draining.Load() {
+		http.Error(w, "runner draining", http.StatusServiceUnavailable)
+		return
+	}
+	if s.inFlight.Add(1) > s.capacity {
+		s.inFlight.Add(-1)
+		http.Error(w, "runner at capacity", http.StatusTooManyRequests)
+		return
+	}
+	defer s.inFlight.Add(-1)
+
+	var req types.RunRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid JSON body", http.StatusBadRequest)
+		return
+	}
+	if err := types.CheckSchemaVersion(req.SchemaVersion); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if len(s.heartbeat.Tenants) > 0 && !slices.Contains(s.heartbeat.Tenants, req.TenantID) {
+		http.Error(w, "runner not dedicated to this tenant", http.StatusForbidden)
+		return
+	}
+
+	ctx := orchestrator.WithTenant(r.Context(), req.TenantID)
+	ctx = orchestrator.WithPromptCacheKey(ctx, req.CacheKey)
+	ctx = orchestrator.WithGeneration(ctx, req.Generation)
+	if req.Echo != nil {
+		ctx = orchestrator.WithEcho(ctx, req.Echo)
+	}
+	receipt := &types.SandboxReceipt{}
+	ctx = orchestrator.WithReceipt(ctx, receipt)
+	answer, err := s.llm.RunInSandbox(ctx, req.SystemPrompt, req.UserContent)
+	if err != nil {
+		log.Printf("sandbox run error: %v", err)
+		http.Error(w, "sandbox run failed", http.StatusBadGateway)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(types.RunResponse{SchemaVersion: types.SchemaVersion, Answer: answer, Receipt: receipt}); err != nil {
+		log.Printf("encode response error: %v", err)
+	}
+}
+
+func (s *runnerServer) heartbeatLoop() {
+	if len(s.coordinators) == 0 {
+		log.Printf("NOPASS_COORDINATOR_URLS not set; runner will not register with any gateway")
+		return
+	}
+
+	ticker := time.NewTicker(5 * time.Second)
+	defer ticker.Stop()
+	for {
+		s.sendHeartbeats()
+		<-ticker.C
+	}
+}
+
+var heartbeatClient = &http.Client{Timeout: 2 * time.Second}
+
+// sendHeartbeats reports the runner's current load and state to every
+// coordinator.
+func (s *runnerServer) sendHeartbeats() {
+	hb := s.heartbeat
+	hb.InFlight = int(s.inFlight.Load())
+	hb.Draining = s.draining.Load()
+	data, _ := json.Marshal(hb)
+	for _, c := range s.coordinators {
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, c+"/internal/runners", bytes.NewReader(data))
+		if err == nil {
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("Authorization", "Bearer "+s.token)
+			var resp *http.Response
+			resp, err = heartbeatClient.Do(req)
+			if err == nil {
+				resp.Body.Close()
+				if resp.StatusCode != http.StatusNoContent {
+					err = fmt.Errorf("status %d", resp.StatusCode)
+				}
+			}
+		}
+		cancel()
+		if err != nil {
+			log.Printf("heartbeat to %s failed: %v", c, err)
+		}
+	}
+}
@@ -0,0 +1,339 @@
+// Command nopass is the operator CLI.
+//
+//	nopass migrate [up|down <version>|version|force <version>]
+//	nopass policy bundle <dir> <out.tar.gz>
+//	nopass policy verify <bundle.tar.gz> <minisign.pub>
+//	nopass apikey create [-name n] [-rate n] [-models a,b] [-profile p] [-expires d] [-mask-spans] [-deadlines] [-priority p] [-priorities] <tenant>
+//	nopass apikey list
+//	nopass apikey revoke <id>
+//	nopass image build [-base b] [-weights ref] [-protocol frames|text] [-source dir] [-entrypoint f] <repo>
+//	nopass image update [-config f] [-evals f] [-image ref] [build flags] <repo>
+//
+// A policy bundle is signed with minisign after it is built
+// (minisign -Sm policy.tar.gz); verify checks it the way the gateway will.
+//
+// API keys are kept in the storage backend for gateways running with
+// NOPASS_API_KEYS=storage; create prints the key once.
+//
+// image build builds a sandbox image from its template (see package
+// sandboximage) through DOCKER_HOST and prints its digest-tagged
+// reference. image update builds one too, or takes -image, runs the eval
+// cases against it (-evals, JSON Lines; a built-in smoke set by default)
+// and, only if all pass, sets sandbox.image in the config file; gateways
+// run it from their next restart.
+//
+// Storage is selected like the gateway's: NOPASS_STORAGE_BACKEND,
+// NOPASS_STORAGE_DSN and NOPASS_STORAGE_DRIVER.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/shivansh-source/nopass/internal/auth"
+	"github.com/shivansh-source/nopass/internal/config"
+	"github.com/shivansh-source/nopass/internal/docker"
+	"github.com/shivansh-source/nopass/internal/orchestrator"
+	"github.com/shivansh-source/nopass/internal/policy"
+	"github.com/shivansh-source/nopass/internal/sandboximage"
+	"github.com/shivansh-source/nopass/internal/scheduler"
+	"github.com/shivansh-source/nopass/internal/storage"
+)
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+	}
+	var err error
+	switch os.Args[1] {
+	case "migrate":
+		err = migrate(os.Args[2:])
+	case "policy":
+		err = policyCmd(os.Args[2:])
+	case "apikey":
+		err = apikeyCmd(os.Args[2:])
+	case "image":
+		err = imageCmd(os.Args[2:])
+	default:
+		usage()
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "nopass:", err)
+		os.Exit(1)
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, `usage: nopass migrate [up|down <version>|version|force <version>]
+       nopass policy bundle <dir> <out.tar.gz>
+       nopass policy verify <bundle.tar.gz> <minisign.pub>
+       nopass apikey create [-name n] [-rate n] [-models a,b] [-profile p] [-expires d] [-mask-spans] [-deadlines] [-priority p] [-priorities] <tenant>
+       nopass apikey list
+       nopass apikey revoke <id>
+       nopass image build [-base b] [-weights ref] [-protocol frames|text] [-source dir] [-entrypoint f] <repo>
+       nopass image update [-config f] [-evals f] [-image ref] [build flags] <repo>`)
+	os.Exit(2)
+}
+
+func storageConfig() storage.Config {
+	return storage.Config{
+		Backend: os.Getenv("NOPASS_STORAGE_BACKEND"),
+		DSN:     os.Getenv("NOPASS_STORAGE_DSN"),
+		Driver:  os.Getenv("NOPASS_STORAGE_DRIVER"),
+	}
+}
+
+func migrate(args []string) error {
+	cfg := storageConfig()
+	if cfg.Backend != "sqlite" && cfg.Backend != "postgres" {
+		fmt.Printf("backend %q has no schema to migrate\n", cfg.Backend)
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+	s, err := storage.OpenSQLUnchecked(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+
+	cmd := "up"
+	if len(args) > 0 {
+		cmd = args[0]
+	}
+	switch cmd {
+	case "up":
+		err = s.Migrate(ctx, -1)
+	case "down", "force":
+		if len(args) != 2 {
+			usage()
+		}
+		v, perr := strconv.Atoi(args[1])
+		if perr != nil || v < 0 {
+			return fmt.Errorf("invalid version %q", args[1])
+		}
+		if cmd == "down" {
+			err = s.Migrate(ctx, v)
+		} else {
+			err = s.Force(ctx, v)
+		}
+	case "version":
+	default:
+		usage()
+	}
+	if err != nil {
+		return err
+	}
+
+	current, dirty, err := s.SchemaVersion(ctx)
+	if err != nil {
+		return err
+	}
+	latest, err := s.LatestSchemaVersion()
+	if err != nil {
+		return err
+	}
+	fmt.Printf("schema version %d (latest %d)", current, latest)
+	if dirty {
+		fmt.Print(", dirty")
+	}
+	fmt.Println()
+	return nil
+}
+
+func policyCmd(args []string) error {
+	if len(args) != 3 {
+		usage()
+	}
+	switch args[0] {
+	case "bundle":
+		f, err := os.Create(args[2])
+		if err != nil {
+			return err
+		}
+		if err := policy.WriteBundle(f, args[1]); err != nil {
+			f.Close()
+			os.Remove(args[2])
+			return err
+		}
+		if err := f.Close(); err != nil {
+			return err
+		}
+		fmt.Printf("wrote %s; sign it with: minisign -Sm %s\n", args[2], args[2])
+	case "verify":
+		pub, err := os.ReadFile(args[2])
+		if err != nil {
+			return err
+		}
+		key, err := policy.ParsePublicKey(string(pub))
+		if err != nil {
+			return err
+		}
+		set, err := policy.LoadBundle(args[1], []policy.PublicKey{key})
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%s: signature ok, version %s, %d masking rules, %d blocklist terms\n",
+			args[1], set.Version, len(set.Masking), len(set.Blocklist))
+	default:
+		usage()
+	}
+	return nil
+}
+
+func apikeyCmd(args []string) error {
+	if len(args) == 0 {
+		usage()
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	st, err := storage.Open(ctx, storageConfig())
+	if err != nil {
+		return err
+	}
+	defer st.Close()
+	keys := auth.RecordStore{Records: st.Records()}
+
+	switch args[0] {
+	case "create":
+		fs := flag.NewFlagSet("apikey create", flag.ExitOnError)
+		name := fs.String("name", "", "label for the key")
+		rate := fs.Int("rate", 0, "requests per minute (0 = unlimited)")
+		models := fs.String("models", "", "comma-separated providers/models the key may select")
+		profile := fs.String("profile", "", "policy profile")
+		expires := fs.Duration("expires", 0, "lifetime of the key (0 = no expiry)")
+		maskSpans := fs.Bool("mask-spans", false, "let the key ask where its messages were masked")
+		deadlines := fs.Bool("deadlines", false, "let the key set its requests' deadlines (X-Request-Deadline)")
+		priority := fs.String("priority", "", "sandbox scheduling class: interactive, batch or eval (default batch)")
+		priorities := fs.Bool("priorities", false, "let the key pick each request's class (X-NoPass-Priority)")
+		fs.Parse(args[1:])
+		if fs.NArg() != 1 {
+			usage()
+		}
+		secret, key := auth.NewKey(fs.Arg(0))
+		key.Name, key.RateLimit, key.PolicyProfile, key.MaskSpans = *name, *rate, *profile, *maskSpans
+		key.Deadlines, key.Priority, key.Priorities = *deadlines, *priority, *priorities
+		if _, err := scheduler.ParsePriority(key.Priority); err != nil {
+			return err
+		}
+		if *models != "" {
+			key.Models = strings.Split(*models, ",")
+		}
+		if *expires > 0 {
+			t := key.CreatedAt.Add(*expires)
+			key.ExpiresAt = &t
+		}
+		if err := keys.Put(ctx, key); err != nil {
+			return err
+		}
+		fmt.Printf("created %s for tenant %s\n%s\n", key.ID, key.TenantID, secret)
+	case "list":
+		list, err := keys.List(ctx)
+		if err != nil {
+			return err
+		}
+		for _, k := range list {
+			fmt.Printf("%s\ttenant=%s\tname=%s\trate=%d\tmodels=%s\tprofile=%s\tmask_spans=%t\tdeadlines=%t\tpriority=%s\tpriorities=%t\n",
+				k.ID, k.TenantID, k.Name, k.RateLimit, strings.Join(k.Models, ","), k.PolicyProfile, k.MaskSpans, k.Deadlines, k.Priority, k.Priorities)
+		}
+	case "revoke":
+		if len(args) != 2 {
+			usage()
+		}
+		if err := keys.Revoke(ctx, args[1]); err != nil {
+			return err
+		}
+		fmt.Printf("revoked %s\n", args[1])
+	default:
+		usage()
+	}
+	return nil
+}
+
+func imageCmd(args []string) error {
+	if len(args) == 0 {
+		usage()
+	}
+	fs := flag.NewFlagSet("image "+args[0], flag.ExitOnError)
+	var t sandboximage.Template
+	fs.StringVar(&t.Base, "base", "", "base image (default python:3.11-slim)")
+	fs.StringVar(&t.Weights, "weights", "", "model weights reference, passed as NOPASS_MODEL_WEIGHTS")
+	fs.StringVar(&t.Protocol, "protocol", orchestrator.OutputFrames, "stdout protocol the entrypoint speaks: frames or text")
+	fs.StringVar(&t.Source, "source", "python/llm_sandbox", "directory holding the entrypoint")
+	fs.StringVar(&t.Entrypoint, "entrypoint", "run_llm.py", "entrypoint script in the source directory")
+	configPath := fs.String("config", os.Getenv("NOPASS_CONFIG"), "config file to update (update)")
+	evals := fs.String("evals", "", "eval cases, JSON Lines (update; default a built-in smoke set)")
+	image := fs.String("image", "", "evaluate and activate this image instead of building one (update)")
+	timeout := fs.Duration("timeout", 2*time.Minute, "timeout of each eval run (update)")
+	fs.Parse(args[1:])
+
+	ctx := context.Background()
+	dc := docker.FromEnv()
+	build := func() (string, error) {
+		if fs.NArg() != 1 {
+			usage()
+		}
+		bctx, cancel := context.WithTimeout(ctx, 30*time.Minute)
+		defer cancel()
+		ref, err := sandboximage.Build(bctx, dc, fs.Arg(0), t)
+		if err != nil {
+			return "", err
+		}
+		fmt.Printf("built %s\n", ref)
+		return ref, nil
+	}
+
+	switch args[0] {
+	case "build":
+		_, err := build()
+		return err
+	case "update":
+		if *configPath == "" {
+			return fmt.Errorf("image update needs -config or NOPASS_CONFIG")
+		}
+		cases := sandboximage.DefaultCases
+		if *evals != "" {
+			var err error
+			if cases, err = sandboximage.LoadCases(*evals); err != nil {
+				return err
+			}
+		}
+		ref := *image
+		if ref == "" {
+			var err error
+			if ref, err = build(); err != nil {
+				return err
+			}
+		}
+		runner := orchestrator.NewLLMRunnerWithConfig(orchestrator.SandboxConfig{
+			ImageName: ref,
+			Timeout:   *timeout,
+			Limits:    orchestrator.DefaultLimits(),
+		})
+		failed := 0
+		for _, r := range sandboximage.Eval(ctx, runner, cases) {
+			if r.Err != nil {
+				failed++
+				fmt.Printf("FAIL\t%s\t%v\n", r.Case.Name, r.Err)
+			} else {
+				fmt.Printf("ok\t%s\n", r.Case.Name)
+			}
+		}
+		if failed > 0 {
+			return fmt.Errorf("%d of %d evals failed; %s not activated", failed, len(cases), ref)
+		}
+		if err := config.SetSandboxImage(*configPath, ref); err != nil {
+			return err
+		}
+		fmt.Printf("%s: sandbox.image is now %s; restart the gateways to run it\n", *configPath, ref)
+	default:
+		usage()
+	}
+	return nil
+}
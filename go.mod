@@ -1,3 +1,17 @@
 module github.com/shivansh-source/nopass
 
 go 1.24.5
+
+require (
+	golang.org/x/crypto v0.48.0
+	golang.org/x/text v0.34.0
+	google.golang.org/grpc v1.71.0
+	google.golang.org/protobuf v1.36.12
+	gopkg.in/yaml.v3 v3.0.1
+)
+
+require (
+	golang.org/x/net v0.49.0 // indirect
+	golang.org/x/sys v0.41.0 // indirect
+	google.golang.org/genproto/googleapis/rpc v0.0.0-20250115164207-1a7da9e5054f // indirect
+)
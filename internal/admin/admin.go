@@ -0,0 +1,397 @@
+// Package admin serves the operator UI and the JSON APIs behind it on a
+// separate listener, so nothing admin-facing is reachable through the
+// public chat port.
+package admin
+
+import (
+	"crypto/subtle"
+	"embed"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/shivansh-source/nopass/internal/audit"
+	"github.com/shivansh-source/nopass/internal/maintenance"
+	"github.com/shivansh-source/nopass/internal/masksample"
+	"github.com/shivansh-source/nopass/internal/metrics"
+	"github.com/shivansh-source/nopass/internal/storage"
+	"github.com/shivansh-source/nopass/internal/tuning"
+	"github.com/shivansh-source/nopass/internal/types"
+	"github.com/shivansh-source/nopass/internal/vault"
+)
+
+//go:embed ui
+var uiFS embed.FS
+
+// Server is the admin HTTP server.
+type Server struct {
+	Store storage.Store
+	// Config returns the live, non-secret configuration for display.
+	Config func() any
+	// Canary, if set, returns the canary comparison report; only a gateway
+	// running in canary mode has one.
+	Canary func() any
+	// Runners, if set, returns the sandbox fleet's live runners; only a
+	// gateway in fleet mode has them.
+	Runners func() any
+	// AuditLog, if set, is searched by /admin/audit.
+	AuditLog audit.Source
+	// Tuning, if set, serves refusal analytics and threshold suggestions
+	// under /admin/api/tuning.
+	Tuning *tuning.Tuner
+	// Token, if set, must be presented as "Authorization: Bearer <token>"
+	// on every API call.
+	Token string
+	// MaskSamples, if set, lists sampled messages and the masking
+	// precision and recall their labels give under
+	// /admin/api/mask-samples.
+	MaskSamples *masksample.Store
+	// SampleToken is the bearer token of the people allowed to open and
+	// label mask samples, which hold personal data. The admin token
+	// doesn't open them; without a SampleToken no one can.
+	SampleToken string
+	// Vault, if set, holds the values behind stored sessions' masking
+	// tokens, which partial and unredacted session exports show.
+	Vault *vault.Vault
+	// AuditorToken is the bearer token of the privileged auditors allowed
+	// to export sessions unredacted (profile none); without one no one
+	// can.
+	AuditorToken string
+	// Maintenance, if set, is switched under /admin/api/maintenance, and
+	// its read-only mode refuses tuning adjustments.
+	Maintenance *maintenance.Switch
+}
+
+// Handler returns the admin mux: the UI at /, the APIs under /admin/api/
+// and Prometheus metrics at /metrics.
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	static, _ := fs.Sub(uiFS, "ui")
+	mux.Handle("/", http.FileServer(http.FS(static)))
+	mux.Handle("/admin/api/audit", s.auth(s.auditHandler))
+	mux.Handle("/admin/audit", s.auth(s.auditSearchHandler))
+	mux.Handle("/admin/api/quarantine", s.auth(s.quarantineHandler))
+	mux.Handle("/admin/api/review-queue", s.auth(s.reviewQueueHandler))
+	mux.Handle("/admin/api/config", s.auth(s.configHandler))
+	mux.Handle("/admin/api/canary", s.auth(s.canaryHandler))
+	mux.Handle("/admin/api/runners", s.auth(s.runnersHandler))
+	mux.Handle("/admin/api/tuning", s.auth(s.tuningHandler))
+	mux.Handle("/admin/api/tuning/apply", s.Maintenance.Guard(s.authMethod(http.MethodPost, s.tuningApplyHandler)))
+	mux.Handle("/admin/api/tuning/revert", s.Maintenance.Guard(s.authMethod(http.MethodPost, s.tuningRevertHandler)))
+	mux.Handle("/admin/api/mask-samples", s.auth(s.maskSamplesHandler))
+	mux.Handle("/admin/api/mask-samples/{id}", s.sampleAuth(http.MethodGet, s.maskSampleHandler))
+	mux.Handle("/admin/api/mask-samples/{id}/label", s.sampleAuth(http.MethodPost, s.maskSampleLabelHandler))
+	mux.HandleFunc("/admin/api/sessions/{id}/export", s.sessionExportHandler)
+	if s.Maintenance != nil {
+		mux.Handle("GET /admin/api/maintenance", s.auth(s.maintenanceHandler))
+		mux.Handle("POST /admin/api/maintenance", s.authMethod(http.MethodPost, s.maintenanceSetHandler))
+	}
+	mux.Handle("/metrics", s.auth(metrics.Handler))
+	return mux
+}
+
+func (s *Server) auth(next http.HandlerFunc) http.Handler {
+	return s.authMethod(http.MethodGet, next)
+}
+
+// authMethod is auth for an API that takes method instead of GET.
+func (s *Server) authMethod(method string, next http.HandlerFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != method {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if s.Token != "" {
+			got := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if subtle.ConstantTimeCompare([]byte(got), []byte(s.Token)) != 1 {
+				http.Error(w, "unauthorized", http.StatusUnauthorized)
+				return
+			}
+		}
+		next(w, r)
+	})
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("admin: encode response error: %v", err)
+	}
+}
+
+// auditHandler serves GET /admin/api/audit?tenant_id=&kind=&since=&limit=.
+func (s *Server) auditHandler(w http.ResponseWriter, r *http.Request) {
+	q := storage.AuditQuery{
+		TenantID: r.URL.Query().Get("tenant_id"),
+		Kind:     r.URL.Query().Get("kind"),
+		Limit:    100,
+	}
+	if v := r.URL.Query().Get("since"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			http.Error(w, "since must be an RFC 3339 timestamp", http.StatusBadRequest)
+			return
+		}
+		q.Since = t
+	}
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 || n > 1000 {
+			http.Error(w, "limit must be 1-1000", http.StatusBadRequest)
+			return
+		}
+		q.Limit = n
+	}
+	records, err := s.Store.Audit().Query(r.Context(), q)
+	if err != nil {
+		log.Printf("admin: audit query error: %v", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, map[string]any{"records": records})
+}
+
+// auditSearchHandler serves GET /admin/audit: chat transactions from the
+// audit log, newest first, filtered by tenant_id, user_id, session_id,
+// risk_level, flag, since and until (RFC 3339). A page holds limit
+// transactions (default 100, at most 1000); pass the returned next as
+// cursor for the following one. format=jsonl exports every match as JSON
+// Lines instead.
+func (s *Server) auditSearchHandler(w http.ResponseWriter, r *http.Request) {
+	if s.AuditLog == nil {
+		http.Error(w, "audit log not enabled", http.StatusNotFound)
+		return
+	}
+	params := r.URL.Query()
+	f := audit.Filter{
+		TenantID:  params.Get("tenant_id"),
+		UserID:    params.Get("user_id"),
+		SessionID: params.Get("session_id"),
+		Flag:      params.Get("flag"),
+	}
+	if v := params.Get("risk_level"); v != "" {
+		f.RiskLevel = types.RiskLevel(strings.ToUpper(v))
+		if f.RiskLevel.Rank() > types.RiskHigh.Rank() {
+			http.Error(w, "risk_level must be LOW, MEDIUM or HIGH", http.StatusBadRequest)
+			return
+		}
+	}
+	for name, dst := range map[string]*time.Time{"since": &f.Since, "until": &f.Until} {
+		if v := params.Get(name); v != "" {
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				http.Error(w, name+" must be an RFC 3339 timestamp", http.StatusBadRequest)
+				return
+			}
+			*dst = t
+		}
+	}
+
+	if params.Get("format") == "jsonl" {
+		s.exportAudit(w, r, f)
+		return
+	}
+	limit := 100
+	if v := params.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 || n > 1000 {
+			http.Error(w, "limit must be 1-1000", http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+	entries, next, err := audit.Search(r.Context(), s.AuditLog, f, limit, params.Get("cursor"))
+	if errors.Is(err, audit.ErrBadCursor) {
+		http.Error(w, "invalid cursor", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		log.Printf("admin: audit search error: %v", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	if entries == nil {
+		entries = []audit.Entry{}
+	}
+	writeJSON(w, map[string]any{"transactions": entries, "next": next})
+}
+
+// exportAudit writes every transaction matching f as JSON Lines, a page
+// at a time.
+func (s *Server) exportAudit(w http.ResponseWriter, r *http.Request, f audit.Filter) {
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.Header().Set("Content-Disposition", `attachment; filename="audit-`+time.Now().UTC().Format("20060102T150405Z")+`.jsonl"`)
+	enc := json.NewEncoder(w)
+	cursor := ""
+	for {
+		entries, next, err := audit.Search(r.Context(), s.AuditLog, f, 1000, cursor)
+		if err != nil {
+			// The status line is gone; a truncated file is all we can
+			// signal.
+			log.Printf("admin: audit export error: %v", err)
+			return
+		}
+		for _, e := range entries {
+			if err := enc.Encode(e); err != nil {
+				return
+			}
+		}
+		if next == "" {
+			return
+		}
+		cursor = next
+	}
+}
+
+// quarantineHandler serves GET /admin/api/quarantine?tenant_id=.
+func (s *Server) quarantineHandler(w http.ResponseWriter, r *http.Request) {
+	entries, err := s.Store.Quarantine().ListQuarantine(r.Context(), r.URL.Query().Get("tenant_id"))
+	if err != nil {
+		log.Printf("admin: quarantine list error: %v", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, map[string]any{"entries": entries})
+}
+
+// reviewQueueHandler serves GET /admin/api/review-queue: audit records of
+// answers that output safety blocked or modified.
+func (s *Server) reviewQueueHandler(w http.ResponseWriter, r *http.Request) {
+	records, err := s.Store.Audit().Query(r.Context(), storage.AuditQuery{
+		TenantID: r.URL.Query().Get("tenant_id"),
+		Kind:     "review",
+		Limit:    100,
+	})
+	if err != nil {
+		log.Printf("admin: review queue error: %v", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, map[string]any{"items": records})
+}
+
+func (s *Server) canaryHandler(w http.ResponseWriter, r *http.Request) {
+	if s.Canary == nil {
+		http.Error(w, "not running in canary mode", http.StatusNotFound)
+		return
+	}
+	writeJSON(w, s.Canary())
+}
+
+// runnersHandler serves GET /admin/api/runners: the fleet's live runners,
+// their addresses, load and tenants.
+func (s *Server) runnersHandler(w http.ResponseWriter, r *http.Request) {
+	if s.Runners == nil {
+		http.Error(w, "not running in fleet mode", http.StatusNotFound)
+		return
+	}
+	writeJSON(w, s.Runners())
+}
+
+func (s *Server) configHandler(w http.ResponseWriter, r *http.Request) {
+	var cfg any
+	if s.Config != nil {
+		cfg = s.Config()
+	}
+	writeJSON(w, cfg)
+}
+
+// tuningHandler serves GET /admin/api/tuning?tenant_id=&since=&hours=:
+// refusal and modification reasons per tenant with their false-positive
+// feedback and attack technique counts (hour by hour with hours=true),
+// the threshold adjustments suggested and those applied.
+func (s *Server) tuningHandler(w http.ResponseWriter, r *http.Request) {
+	if s.Tuning == nil {
+		http.Error(w, "tuning not enabled", http.StatusNotFound)
+		return
+	}
+	params := r.URL.Query()
+	var since time.Time
+	if v := params.Get("since"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			http.Error(w, "since must be an RFC 3339 timestamp", http.StatusBadRequest)
+			return
+		}
+		since = t
+	}
+	tenant := params.Get("tenant_id")
+	stats := s.Tuning.Stats(tenant, since, params.Get("hours") == "true")
+	if stats == nil {
+		stats = []tuning.TenantStats{}
+	}
+	suggestions := s.Tuning.Suggestions(tenant)
+	if suggestions == nil {
+		suggestions = []tuning.Suggestion{}
+	}
+	writeJSON(w, map[string]any{
+		"tenants":     stats,
+		"suggestions": suggestions,
+		"applied":     s.Tuning.Applied(tenant),
+	})
+}
+
+type tuningRequest struct {
+	ID         string `json:"id"`
+	ApprovedBy string `json:"approved_by"`
+}
+
+// tuningApplyHandler serves POST /admin/api/tuning/apply {"id",
+// "approved_by"}: puts a current suggestion into effect.
+func (s *Server) tuningApplyHandler(w http.ResponseWriter, r *http.Request) {
+	req, ok := s.tuningRequest(w, r)
+	if !ok {
+		return
+	}
+	a, err := s.Tuning.Apply(req.ID, req.ApprovedBy)
+	switch {
+	case errors.Is(err, tuning.ErrNoApprover):
+		http.Error(w, "approved_by is required", http.StatusBadRequest)
+	case errors.Is(err, tuning.ErrNoSuggestion):
+		http.Error(w, "no such suggestion", http.StatusNotFound)
+	case err != nil:
+		log.Printf("admin: tuning apply error: %v", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+	default:
+		log.Printf("tuning adjustment %s applied for tenant %s by %s: %s", a.ID, a.Tenant, a.ApprovedBy, a.Change)
+		writeJSON(w, a)
+	}
+}
+
+// tuningRevertHandler serves POST /admin/api/tuning/revert {"id"}: takes
+// an applied adjustment out of effect.
+func (s *Server) tuningRevertHandler(w http.ResponseWriter, r *http.Request) {
+	req, ok := s.tuningRequest(w, r)
+	if !ok {
+		return
+	}
+	a, err := s.Tuning.Revert(req.ID)
+	switch {
+	case errors.Is(err, tuning.ErrNoAdjustment):
+		http.Error(w, "no such adjustment", http.StatusNotFound)
+	case err != nil:
+		log.Printf("admin: tuning revert error: %v", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+	default:
+		log.Printf("tuning adjustment %s reverted for tenant %s", a.ID, a.Tenant)
+		writeJSON(w, a)
+	}
+}
+
+func (s *Server) tuningRequest(w http.ResponseWriter, r *http.Request) (tuningRequest, bool) {
+	var req tuningRequest
+	if s.Tuning == nil {
+		http.Error(w, "tuning not enabled", http.StatusNotFound)
+		return req, false
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 4<<10)).Decode(&req); err != nil || req.ID == "" {
+		http.Error(w, "body must be JSON with an id", http.StatusBadRequest)
+		return req, false
+	}
+	return req, true
+}
@@ -0,0 +1,120 @@
+package admin
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/shivansh-source/nopass/internal/audit"
+	"github.com/shivansh-source/nopass/internal/redact"
+	"github.com/shivansh-source/nopass/internal/storage"
+	"github.com/shivansh-source/nopass/internal/types"
+	"github.com/shivansh-source/nopass/internal/vault"
+)
+
+// SessionExport is a conversation as an export profile shows it.
+type SessionExport struct {
+	TenantID   string         `json:"tenant_id"`
+	SessionID  string         `json:"session_id"`
+	Profile    redact.Profile `json:"profile"`
+	ExportedAt time.Time      `json:"exported_at"`
+	Summary    string         `json:"summary,omitempty"`
+	Turns      []types.Turn   `json:"turns"`
+}
+
+// sessionExportHandler serves GET
+// /admin/api/sessions/{id}/export?tenant_id=&profile=: the session's
+// stored turns and summary under redaction profile full (the default),
+// partial or none. Profile none takes AuditorToken instead of the admin
+// token. Every export is written to the audit store.
+func (s *Server) sessionExportHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	profile, err := redact.ParseProfile(r.URL.Query().Get("profile"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	token := s.Token
+	if profile == redact.None {
+		if s.AuditorToken == "" {
+			http.Error(w, "unredacted export not enabled", http.StatusNotFound)
+			return
+		}
+		token = s.AuditorToken
+	}
+	if token != "" {
+		got := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+	}
+
+	ctx := r.Context()
+	tenant, id := r.URL.Query().Get("tenant_id"), r.PathValue("id")
+	sess, err := s.Store.Sessions().GetSession(ctx, tenant, id)
+	if errors.Is(err, storage.ErrNotFound) {
+		http.Error(w, "session not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("admin: export session %s: %v", id, err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	texts := []string{sess.Summary}
+	for _, t := range sess.Turns {
+		texts = append(texts, t.Content)
+	}
+	var lookup redact.Lookup
+	if s.Vault != nil {
+		lookup = func(token string) (string, bool) {
+			v, err := s.Vault.Get(ctx, tenant, sess.UserID, id, token)
+			if err != nil && !errors.Is(err, vault.ErrNotFound) {
+				log.Printf("admin: export session %s: vault get %s: %v", id, token, err)
+			}
+			return v, err == nil
+		}
+	}
+	red := redact.New(profile, lookup, texts...)
+	out := SessionExport{
+		TenantID:   tenant,
+		SessionID:  id,
+		Profile:    profile,
+		ExportedAt: time.Now().UTC(),
+		Turns:      make([]types.Turn, len(sess.Turns)),
+	}
+	if sess.Summary != "" {
+		out.Summary = red.Apply(sess.Summary)
+	}
+	for i, t := range sess.Turns {
+		out.Turns[i] = types.Turn{Role: t.Role, Content: red.Apply(t.Content)}
+	}
+
+	data, _ := json.Marshal(map[string]string{"session_id": id, "profile": string(profile)})
+	rec := storage.AuditRecord{
+		ID:       audit.NewID(),
+		TenantID: tenant,
+		Time:     out.ExportedAt,
+		Kind:     "session_export",
+		Actor:    r.RemoteAddr,
+		Data:     data,
+	}
+	if err := s.Store.Audit().Append(context.WithoutCancel(ctx), rec); err != nil {
+		// An export nobody can account for doesn't go out.
+		log.Printf("admin: audit session export %s: %v", id, err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	log.Printf("session %s of tenant %q exported with profile %s from %s", id, tenant, profile, r.RemoteAddr)
+	writeJSON(w, out)
+}
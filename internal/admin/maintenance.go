@@ -0,0 +1,51 @@
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"github.com/shivansh-source/nopass/internal/audit"
+	"github.com/shivansh-source/nopass/internal/maintenance"
+	"github.com/shivansh-source/nopass/internal/storage"
+)
+
+// maintenanceHandler serves GET /admin/api/maintenance: the maintenance
+// switches' state.
+func (s *Server) maintenanceHandler(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, s.Maintenance.Load())
+}
+
+// maintenanceSetHandler serves POST /admin/api/maintenance
+// {"maintenance", "message", "read_only", "changed_by"}: sets both
+// switches. It works in read-only mode, which it is the way out of. The
+// change is written to the audit store.
+func (s *Server) maintenanceSetHandler(w http.ResponseWriter, r *http.Request) {
+	var st maintenance.State
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<16)).Decode(&st); err != nil {
+		http.Error(w, "invalid JSON body", http.StatusBadRequest)
+		return
+	}
+	if st.ChangedBy == "" {
+		st.ChangedBy = r.RemoteAddr
+	}
+	if err := s.Maintenance.Set(r.Context(), st); err != nil {
+		log.Printf("admin: set maintenance: %v", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	st = s.Maintenance.Load()
+	data, _ := json.Marshal(st)
+	rec := storage.AuditRecord{
+		ID:    audit.NewID(),
+		Time:  st.ChangedAt,
+		Kind:  "maintenance",
+		Actor: st.ChangedBy,
+		Data:  data,
+	}
+	if err := s.Store.Audit().Append(context.WithoutCancel(r.Context()), rec); err != nil {
+		log.Printf("admin: audit maintenance change: %v", err)
+	}
+	writeJSON(w, st)
+}
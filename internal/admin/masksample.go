@@ -0,0 +1,100 @@
+package admin
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/shivansh-source/nopass/internal/masksample"
+)
+
+// sampleAuth admits method requests bearing SampleToken.
+func (s *Server) sampleAuth(method string, next http.HandlerFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != method {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if s.MaskSamples == nil || s.SampleToken == "" {
+			http.Error(w, "mask sample review not enabled", http.StatusNotFound)
+			return
+		}
+		got := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(got), []byte(s.SampleToken)) != 1 {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next(w, r)
+	})
+}
+
+// maskSamplesHandler serves GET /admin/api/mask-samples?tenant_id=: the
+// samples, unopened, and the precision and recall per kind their labels
+// give.
+func (s *Server) maskSamplesHandler(w http.ResponseWriter, r *http.Request) {
+	if s.MaskSamples == nil {
+		http.Error(w, "mask sampling not enabled", http.StatusNotFound)
+		return
+	}
+	tenant := r.URL.Query().Get("tenant_id")
+	list, err := s.MaskSamples.List(tenant)
+	if err == nil {
+		var est []masksample.Estimate
+		if est, err = s.MaskSamples.Estimates(tenant); err == nil {
+			if list == nil {
+				list = []masksample.Summary{}
+			}
+			writeJSON(w, map[string]any{"samples": list, "estimates": est})
+			return
+		}
+	}
+	log.Printf("admin: mask samples error: %v", err)
+	http.Error(w, "internal error", http.StatusInternalServerError)
+}
+
+// maskSampleHandler serves GET /admin/api/mask-samples/{id}?tenant_id=:
+// the opened sample. Every opening is logged.
+func (s *Server) maskSampleHandler(w http.ResponseWriter, r *http.Request) {
+	tenant, id := r.URL.Query().Get("tenant_id"), r.PathValue("id")
+	sample, err := s.MaskSamples.Get(r.Context(), tenant, id)
+	if errors.Is(err, masksample.ErrNotFound) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("admin: open mask sample %s: %v", id, err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	log.Printf("mask sample %s of tenant %q opened from %s", id, tenant, r.RemoteAddr)
+	writeJSON(w, sample)
+}
+
+// maskSampleLabelHandler serves POST
+// /admin/api/mask-samples/{id}/label?tenant_id= {"labeled_by",
+// "verdicts": [true, false, ...], "missed": [{"kind", "start", "end"}]}:
+// one verdict per masked span, and the values masking missed.
+func (s *Server) maskSampleLabelHandler(w http.ResponseWriter, r *http.Request) {
+	var l masksample.Label
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 64<<10)).Decode(&l); err != nil {
+		http.Error(w, "body must be a JSON label", http.StatusBadRequest)
+		return
+	}
+	tenant, id := r.URL.Query().Get("tenant_id"), r.PathValue("id")
+	summary, err := s.MaskSamples.Label(r.Context(), tenant, id, l)
+	switch {
+	case errors.Is(err, masksample.ErrNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, masksample.ErrBadLabel):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case err != nil:
+		log.Printf("admin: label mask sample %s: %v", id, err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+	default:
+		log.Printf("mask sample %s of tenant %q labeled by %s", id, tenant, l.LabeledBy)
+		writeJSON(w, summary)
+	}
+}
@@ -0,0 +1,158 @@
+// Minimal admin UI over /admin/api/*. No framework, no build step.
+(function () {
+  const endpoints = {
+    audit: { url: "/admin/api/audit", key: "records", cols: ["time", "tenant_id", "kind", "actor", "id"] },
+    review: { url: "/admin/api/review-queue", key: "items", cols: ["time", "tenant_id", "actor", "id"] },
+    quarantine: { url: "/admin/api/quarantine", key: "entries", cols: ["created_at", "tenant_id", "id", "source", "reason", "flags"] },
+    config: { url: "/admin/api/config" },
+    canary: { url: "/admin/api/canary" },
+    tuning: { url: "/admin/api/tuning" },
+    masking: { url: "/admin/api/mask-samples", key: "samples", cols: ["created_at", "tenant_id", "id", "spans", "labeled", "labeled_by"] },
+  };
+  let tab = "audit";
+  const form = document.getElementById("filters");
+  const table = document.getElementById("table");
+  const raw = document.getElementById("raw");
+  const status = document.getElementById("status");
+  const labeler = document.getElementById("labeler");
+
+  form.token.value = sessionStorage.getItem("nopass-admin-token") || "";
+
+  document.querySelectorAll("nav button").forEach((b) =>
+    b.addEventListener("click", () => {
+      document.querySelectorAll("nav button").forEach((x) => x.classList.remove("active"));
+      b.classList.add("active");
+      tab = b.dataset.tab;
+      load();
+    })
+  );
+  form.addEventListener("submit", (e) => {
+    e.preventDefault();
+    sessionStorage.setItem("nopass-admin-token", form.token.value);
+    load();
+  });
+
+  function cell(v) {
+    const td = document.createElement("td");
+    td.textContent = Array.isArray(v) ? v.join(", ") : v == null ? "" : String(v);
+    return td;
+  }
+
+  async function load() {
+    const ep = endpoints[tab];
+    const params = new URLSearchParams();
+    if (form.tenant_id.value) params.set("tenant_id", form.tenant_id.value);
+    if (tab === "audit" && form.kind.value) params.set("kind", form.kind.value);
+    const headers = form.token.value ? { Authorization: "Bearer " + form.token.value } : {};
+    status.textContent = "";
+    labeler.hidden = true;
+    try {
+      const resp = await fetch(ep.url + "?" + params, { headers });
+      if (!resp.ok) throw new Error(resp.status + " " + (await resp.text()));
+      const data = await resp.json();
+      render(ep, data);
+    } catch (err) {
+      status.textContent = String(err);
+    }
+  }
+
+  function render(ep, data) {
+    const thead = table.tHead, tbody = table.tBodies[0];
+    thead.replaceChildren();
+    tbody.replaceChildren();
+    if (!ep.key) {
+      table.hidden = true;
+      raw.hidden = false;
+      raw.textContent = JSON.stringify(data, null, 2);
+      return;
+    }
+    table.hidden = false;
+    raw.hidden = true;
+    const hr = document.createElement("tr");
+    ep.cols.forEach((c) => {
+      const th = document.createElement("th");
+      th.textContent = c;
+      hr.appendChild(th);
+    });
+    thead.appendChild(hr);
+    const rows = data[ep.key] || [];
+    if (rows.length === 0) status.textContent = "Nothing to show.";
+    rows.forEach((row) => {
+      const tr = document.createElement("tr");
+      ep.cols.forEach((c) => tr.appendChild(cell(row[c])));
+      tr.title = row.data ? atob(row.data) : "";
+      if (tab === "masking") tr.addEventListener("click", () => openSample(row));
+      tbody.appendChild(tr);
+    });
+    if (data.estimates) {
+      raw.hidden = false;
+      raw.textContent = "Estimates per kind:\n" + JSON.stringify(data.estimates, null, 2);
+    }
+  }
+
+  // Mask samples hold personal data: opening and labeling one takes the
+  // sample reviewers' token, not the admin token.
+  async function openSample(row) {
+    let token = sessionStorage.getItem("nopass-sample-token");
+    if (!token) {
+      token = prompt("Sample reviewer token") || "";
+      sessionStorage.setItem("nopass-sample-token", token);
+    }
+    const headers = { Authorization: "Bearer " + token };
+    const url = "/admin/api/mask-samples/" + row.id + "?" + new URLSearchParams({ tenant_id: row.tenant_id });
+    const resp = await fetch(url, { headers });
+    if (!resp.ok) {
+      if (resp.status === 401) sessionStorage.removeItem("nopass-sample-token");
+      status.textContent = resp.status + " " + (await resp.text());
+      return;
+    }
+    const sample = await resp.json();
+    const text = Array.from(sample.text);
+    labeler.replaceChildren();
+    const shown = document.createElement("pre");
+    let pos = 0;
+    sample.masked_spans.forEach((sp, i) => {
+      shown.append(text.slice(pos, sp.start).join(""));
+      const mark = document.createElement("mark");
+      mark.textContent = text.slice(sp.start, sp.end).join("") + " [" + i + ":" + sp.kind + "]";
+      shown.append(mark);
+      pos = sp.end;
+    });
+    shown.append(text.slice(pos).join(""));
+    labeler.append(shown);
+    const boxes = sample.masked_spans.map((sp, i) => {
+      const label = document.createElement("label");
+      const box = document.createElement("input");
+      box.type = "checkbox";
+      box.checked = sample.verdicts ? sample.verdicts[i] : true;
+      label.append(box, " " + i + ": " + sp.kind + " rightly masked");
+      labeler.append(label, document.createElement("br"));
+      return box;
+    });
+    const missed = document.createElement("textarea");
+    missed.placeholder = "Missed values, one per line: KIND start-end (code point offsets)";
+    missed.value = (sample.missed || []).map((m) => m.kind + " " + m.start + "-" + m.end).join("\n");
+    const by = document.createElement("input");
+    by.placeholder = "labeled by";
+    by.value = sample.labeled_by || "";
+    const save = document.createElement("button");
+    save.textContent = "Save label";
+    save.addEventListener("click", async () => {
+      const body = {
+        labeled_by: by.value,
+        verdicts: boxes.map((b) => b.checked),
+        missed: missed.value.split("\n").filter((l) => l.trim()).map((l) => {
+          const [kind, range] = l.trim().split(/\s+/);
+          const [start, end] = (range || "").split("-").map(Number);
+          return { kind, start, end };
+        }),
+      };
+      const r = await fetch(url.replace("?", "/label?"), { method: "POST", headers, body: JSON.stringify(body) });
+      status.textContent = r.ok ? "Label saved." : r.status + " " + (await r.text());
+    });
+    labeler.append(missed, document.createElement("br"), by, save);
+    labeler.hidden = false;
+  }
+
+  load();
+})();
@@ -0,0 +1,210 @@
+// Package answercache remembers the final answers given to low-risk
+// requests that passed output review, so an identical request (same
+// tenant, masked prompt, external data and policy) is answered again
+// without another sandbox run. Answers are kept in an in-process LRU and,
+// with a shared store, for every gateway using it. Because the policy is
+// part of the key, a policy change re-runs every prompt.
+package answercache
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/shivansh-source/nopass/internal/storage"
+	"github.com/shivansh-source/nopass/internal/types"
+)
+
+// DefaultTenant is the key in Tenants for tenants without their own
+// setting.
+const DefaultTenant = "default"
+
+// Entry is a cached answer.
+type Entry struct {
+	Answer    string          `json:"answer"`
+	RiskLevel types.RiskLevel `json:"risk_level"`
+	Path      types.Path      `json:"path"`
+	Flags     []string        `json:"flags,omitempty"`
+	Expires   time.Time       `json:"expires"`
+}
+
+// Cache is a bounded answer cache. When full, the least recently used
+// entry is evicted. A nil Cache caches nothing.
+type Cache struct {
+	// Shared, if set, keeps answers for every gateway too; a hit there is
+	// copied into this gateway's LRU.
+	Shared storage.CacheStore
+	// Tenants turns caching on or off per tenant, falling back to
+	// DefaultTenant's setting; with neither, caching is on.
+	Tenants map[string]bool
+
+	mu      sync.Mutex
+	max     int
+	ttl     time.Duration
+	entries map[string]*list.Element
+	lru     *list.List
+}
+
+type item struct {
+	key   string
+	entry Entry
+}
+
+// New creates a cache holding at most max answers for ttl each.
+func New(max int, ttl time.Duration) *Cache {
+	return &Cache{max: max, ttl: ttl, entries: make(map[string]*list.Element), lru: list.New()}
+}
+
+// Empty returns a new, empty cache with c's size, TTL and tenant settings,
+// sharing answers through shared (nil for none).
+func (c *Cache) Empty(shared storage.CacheStore) *Cache {
+	if c == nil {
+		return nil
+	}
+	e := New(c.max, c.ttl)
+	e.Shared, e.Tenants = shared, c.Tenants
+	return e
+}
+
+// Key identifies a request by its tenant, the full ID of the policy it is
+// answered under, its masked prompt and its masked external data. The
+// prompt is compared ignoring case and runs of whitespace.
+func Key(tenantID, policyID, maskedPrompt string, data []types.ExternalData) string {
+	h := sha256.New()
+	for _, s := range []string{tenantID, policyID, strings.ToLower(strings.Join(strings.Fields(maskedPrompt), " "))} {
+		h.Write([]byte(s))
+		h.Write([]byte{0})
+	}
+	for _, d := range data {
+		h.Write([]byte(d.Source + "\x00" + d.Type + "\x00" + d.Content + "\x00" + strconv.FormatBool(d.IsDangerous) + "\x00"))
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Enabled reports whether the tenant's answers are cached.
+func (c *Cache) Enabled(tenantID string) bool {
+	if c == nil {
+		return false
+	}
+	if on, ok := c.Tenants[tenantID]; ok {
+		return on
+	}
+	if on, ok := c.Tenants[DefaultTenant]; ok {
+		return on
+	}
+	return true
+}
+
+// Get returns the answer cached for key. An error reading the shared
+// store is returned with a miss.
+func (c *Cache) Get(ctx context.Context, key string) (Entry, bool, error) {
+	if c == nil || key == "" {
+		return Entry{}, false, nil
+	}
+	if e, ok := c.local(key); ok {
+		return e, true, nil
+	}
+	if c.Shared == nil {
+		return Entry{}, false, nil
+	}
+	data, err := c.Shared.GetCached(ctx, "answer:"+key)
+	if errors.Is(err, storage.ErrNotFound) {
+		return Entry{}, false, nil
+	}
+	if err != nil {
+		return Entry{}, false, err
+	}
+	var e Entry
+	if err := json.Unmarshal(data, &e); err != nil {
+		return Entry{}, false, fmt.Errorf("answercache: %w", err)
+	}
+	if !time.Now().Before(e.Expires) {
+		return Entry{}, false, nil
+	}
+	c.store(key, e)
+	return e, true, nil
+}
+
+// Put caches an answer for key. The entry is kept locally even if storing
+// it in the shared store fails.
+func (c *Cache) Put(ctx context.Context, key string, e Entry) error {
+	if c == nil || key == "" {
+		return nil
+	}
+	e.Expires = time.Now().Add(c.ttl)
+	c.store(key, e)
+	if c.Shared == nil {
+		return nil
+	}
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	return c.Shared.PutCached(ctx, "answer:"+key, data, c.ttl)
+}
+
+func (c *Cache) local(key string) (Entry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.entries[key]
+	if !ok {
+		return Entry{}, false
+	}
+	it := el.Value.(*item)
+	if !time.Now().Before(it.entry.Expires) {
+		c.lru.Remove(el)
+		delete(c.entries, key)
+		return Entry{}, false
+	}
+	c.lru.MoveToFront(el)
+	return it.entry, true
+}
+
+func (c *Cache) store(key string, e Entry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.entries[key]; ok {
+		el.Value.(*item).entry = e
+		c.lru.MoveToFront(el)
+		return
+	}
+	c.entries[key] = c.lru.PushFront(&item{key: key, entry: e})
+	for c.lru.Len() > c.max && c.max > 0 {
+		oldest := c.lru.Back()
+		c.lru.Remove(oldest)
+		delete(c.entries, oldest.Value.(*item).key)
+	}
+}
+
+// ParseTenants parses "default=off,acme=on": each tenant's caching on or
+// off.
+func ParseTenants(spec string) (map[string]bool, error) {
+	out := make(map[string]bool)
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		tenant, setting, ok := strings.Cut(entry, "=")
+		if !ok || tenant == "" {
+			return nil, fmt.Errorf("answer cache entry %q: want tenant=on or tenant=off", entry)
+		}
+		switch strings.TrimSpace(setting) {
+		case "on":
+			out[tenant] = true
+		case "off":
+			out[tenant] = false
+		default:
+			return nil, fmt.Errorf("answer cache entry %q: want on or off, got %q", entry, setting)
+		}
+	}
+	return out, nil
+}
@@ -0,0 +1,199 @@
+// Package approval holds answers back until an external service approves
+// them. Specific reason flags (e.g. "legal_sensitive") are routed to an
+// approval webhook that must answer within a timeout; if it doesn't, the
+// configured default decision applies.
+package approval
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/shivansh-source/nopass/internal/types"
+)
+
+// Decision is the default disposition when an approver doesn't answer.
+type Decision string
+
+const (
+	Approve Decision = "approve"
+	Deny    Decision = "deny"
+)
+
+// ParseDecision parses "approve" or "deny".
+func ParseDecision(s string) (Decision, error) {
+	switch d := Decision(strings.ToLower(strings.TrimSpace(s))); d {
+	case Approve, Deny:
+		return d, nil
+	}
+	return "", fmt.Errorf("unknown approval decision %q (want approve or deny)", s)
+}
+
+// Request is POSTed to the approval webhook.
+type Request struct {
+	SchemaVersion int             `json:"schema_version"`
+	TenantID      string          `json:"tenant_id,omitempty"`
+	UserID        string          `json:"user_id,omitempty"`
+	SessionID     string          `json:"session_id,omitempty"`
+	Flags         []string        `json:"flags"` // the gated flags that triggered this call
+	RiskLevel     types.RiskLevel `json:"risk_level"`
+	UserPrompt    string          `json:"user_prompt"`  // masked
+	DraftAnswer   string          `json:"draft_answer"` // after output safety
+}
+
+// Response is the approver's verdict.
+type Response struct {
+	Approved bool   `json:"approved"`
+	Reason   string `json:"reason,omitempty"`
+}
+
+// Result is the outcome of Gate.Check.
+type Result struct {
+	Approved bool
+	// Flags are the gated flags that were checked.
+	Flags []string
+	// Reason explains a denial, e.g. "legal_sensitive: denied by approver".
+	Reason string
+}
+
+// Gate maps reason flags to approval webhooks.
+type Gate struct {
+	// Hooks maps flag -> webhook URL.
+	Hooks   map[string]string
+	Timeout time.Duration
+	Default Decision
+
+	HTTPClient *http.Client
+}
+
+// NewGate creates a Gate. timeout bounds each approval call.
+func NewGate(hooks map[string]string, timeout time.Duration, def Decision) *Gate {
+	return &Gate{Hooks: hooks, Timeout: timeout, Default: def, HTTPClient: &http.Client{}}
+}
+
+// ParseHooks parses "legal_sensitive=https://approvals/hook,flag2=url".
+func ParseHooks(spec string) (map[string]string, error) {
+	hooks := make(map[string]string)
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		flag, url, ok := strings.Cut(entry, "=")
+		if !ok || flag == "" || url == "" {
+			return nil, fmt.Errorf("approval gate entry %q: want flag=url", entry)
+		}
+		hooks[flag] = url
+	}
+	return hooks, nil
+}
+
+// Gated reports whether any of flags has an approval webhook.
+func (g *Gate) Gated(flags []string) bool {
+	for _, f := range flags {
+		if _, ok := g.Hooks[f]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Check calls the approval webhook of every gated flag in flags, in
+// parallel, and approves only if all of them do. Flags without a gate are
+// ignored; with none gated the answer is approved without any call.
+func (g *Gate) Check(ctx context.Context, req Request, flags []string) Result {
+	byURL := make(map[string][]string)
+	for _, f := range flags {
+		if url, ok := g.Hooks[f]; ok {
+			byURL[url] = append(byURL[url], f)
+		}
+	}
+	res := Result{Approved: true}
+	if len(byURL) == 0 {
+		return res
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for url, gated := range byURL {
+		res.Flags = append(res.Flags, gated...)
+		wg.Add(1)
+		go func(url string, gated []string) {
+			defer wg.Done()
+			r := req
+			r.SchemaVersion = types.SchemaVersion
+			r.Flags = gated
+			approved, reason := g.ask(ctx, url, r)
+			if !approved {
+				mu.Lock()
+				res.Approved = false
+				res.Reason = strings.Join(gated, ",") + ": " + reason
+				mu.Unlock()
+			}
+		}(url, gated)
+	}
+	wg.Wait()
+	sort.Strings(res.Flags)
+	return res
+}
+
+// ask calls one webhook and applies the default decision if it fails or
+// times out.
+func (g *Gate) ask(ctx context.Context, url string, req Request) (bool, string) {
+	ctx, cancel := context.WithTimeout(ctx, g.Timeout)
+	defer cancel()
+
+	resp, err := g.call(ctx, url, req)
+	if err != nil {
+		log.Printf("approval webhook %s failed (flags=%v), applying default %q: %v", url, req.Flags, g.Default, err)
+		if g.Default == Approve {
+			return true, ""
+		}
+		return false, "approval unavailable"
+	}
+	if !resp.Approved {
+		reason := resp.Reason
+		if reason == "" {
+			reason = "denied by approver"
+		}
+		return false, reason
+	}
+	return true, ""
+}
+
+func (g *Gate) call(ctx context.Context, url string, req Request) (*Response, error) {
+	data, err := json.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("marshal approval request: %w", err)
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("create approval request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	resp, err := g.HTTPClient.Do(httpReq)
+	if err != nil {
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return nil, fmt.Errorf("timed out after %s", g.Timeout)
+		}
+		return nil, fmt.Errorf("call approval webhook: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("approval webhook returned status %d", resp.StatusCode)
+	}
+	var out Response
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return nil, fmt.Errorf("decode approval response: %w", err)
+	}
+	return &out, nil
+}
@@ -0,0 +1,77 @@
+package artifacts
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"time"
+)
+
+// Scanner checks a file for malware. It returns an error wrapping
+// ErrInfected for malware and any other error when it couldn't tell.
+type Scanner interface {
+	Scan(ctx context.Context, name string, data []byte) error
+}
+
+// ClamAV scans with a clamd daemon over its INSTREAM command.
+type ClamAV struct {
+	// Network and Addr locate clamd, e.g. "tcp" and "clamav:3310" or
+	// "unix" and "/run/clamav/clamd.ctl".
+	Network string
+	Addr    string
+	Timeout time.Duration // default 30s
+}
+
+// chunkSize is the INSTREAM chunk length; clamd's StreamMaxLength bounds
+// the whole file.
+const chunkSize = 64 << 10
+
+func (c ClamAV) Scan(ctx context.Context, name string, data []byte) error {
+	timeout := c.Timeout
+	if timeout <= 0 {
+		timeout = 30 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, c.Network, c.Addr)
+	if err != nil {
+		return fmt.Errorf("clamd: %w", err)
+	}
+	defer conn.Close()
+	if dl, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(dl)
+	}
+
+	var req bytes.Buffer
+	req.WriteString("zINSTREAM\x00")
+	for rest := data; len(rest) > 0; {
+		n := min(len(rest), chunkSize)
+		binary.Write(&req, binary.BigEndian, uint32(n))
+		req.Write(rest[:n])
+		rest = rest[n:]
+	}
+	req.Write([]byte{0, 0, 0, 0})
+	if _, err := conn.Write(req.Bytes()); err != nil {
+		return fmt.Errorf("clamd: send %s: %w", name, err)
+	}
+	reply, err := io.ReadAll(io.LimitReader(conn, 4096))
+	if err != nil {
+		return fmt.Errorf("clamd: read verdict: %w", err)
+	}
+	// "stream: OK", "stream: <signature> FOUND" or "<message> ERROR".
+	verdict := strings.TrimSpace(strings.TrimRight(string(reply), "\x00"))
+	switch {
+	case strings.HasSuffix(verdict, " OK"):
+		return nil
+	case strings.HasSuffix(verdict, " FOUND"):
+		sig := strings.TrimSuffix(strings.TrimPrefix(verdict, "stream: "), " FOUND")
+		return fmt.Errorf("%w: %s (%s)", ErrInfected, name, sig)
+	default:
+		return fmt.Errorf("clamd: %s: %s", name, verdict)
+	}
+}
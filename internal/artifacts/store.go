@@ -0,0 +1,269 @@
+// Package artifacts keeps the files a sandbox run produced and serves
+// them through signed, expiring download URLs. Files are scanned for
+// malware before they are kept; nothing is served without a valid
+// signature, and nothing outlives its TTL.
+package artifacts
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/shivansh-source/nopass/internal/metrics"
+)
+
+// Artifact is the metadata of one kept file.
+type Artifact struct {
+	ID          string    `json:"id"`
+	TenantID    string    `json:"tenant_id"`
+	Name        string    `json:"name"`
+	ContentType string    `json:"content_type"`
+	Size        int64     `json:"size"`
+	SHA256      string    `json:"sha256"`
+	CreatedAt   time.Time `json:"created_at"`
+	ExpiresAt   time.Time `json:"expires_at"`
+}
+
+// Store keeps artifacts as files under Dir, each beside a JSON file of
+// its metadata.
+type Store struct {
+	Dir string
+	// TTL is how long an artifact and its URL live.
+	TTL time.Duration
+	// BaseURL prefixes download paths, e.g. https://nopass.example.com;
+	// empty gives paths relative to the gateway.
+	BaseURL string
+	// Scanner, if set, checks each file before it is kept.
+	Scanner Scanner
+	// MaxFiles and MaxBytes bound what one run may produce.
+	MaxFiles int
+	MaxBytes int64
+	// AllowBinary keeps files that aren't text. Output review and the
+	// data policy can't read them, so they are dropped otherwise.
+	AllowBinary bool
+
+	key []byte
+}
+
+var (
+	// ErrNotFound means the artifact doesn't exist or has expired.
+	ErrNotFound = errors.New("artifact not found")
+	// ErrInfected means the scanner found malware in the file.
+	ErrInfected = errors.New("artifact failed the malware scan")
+)
+
+var stored = metrics.NewCounterVec(
+	"nopass_artifacts_total",
+	"Sandbox artifacts by outcome (stored, infected, scan_failed, dropped, blocked, binary or error).",
+	"result",
+)
+
+// Count records n artifacts with result, for outcomes decided outside
+// the store (e.g. files a run produced past the limits).
+func Count(n int, result string) {
+	stored.Add(uint64(n), result)
+}
+
+// NewStore creates dir if needed. URLs are signed with key, which must be
+// at least 32 bytes; a nil key is replaced by a random one, so URLs die
+// with the process.
+func NewStore(dir string, key []byte, ttl time.Duration) (*Store, error) {
+	if key == nil {
+		key = make([]byte, 32)
+		if _, err := rand.Read(key); err != nil {
+			return nil, err
+		}
+	}
+	if len(key) < 32 {
+		return nil, errors.New("artifacts: the signing key must be at least 32 bytes")
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("artifacts: %w", err)
+	}
+	return &Store{Dir: dir, TTL: ttl, MaxFiles: 16, MaxBytes: 25 << 20, key: key}, nil
+}
+
+var validID = regexp.MustCompile(`^art_[0-9a-f]{24}$`)
+
+func newID() string {
+	var b [12]byte
+	_, _ = rand.Read(b[:])
+	return "art_" + hex.EncodeToString(b[:])
+}
+
+// Put scans data and keeps it as tenantID's artifact name. It returns
+// ErrInfected for malware, or the scanner's error if the scan failed;
+// either way nothing is kept.
+func (s *Store) Put(ctx context.Context, tenantID, name string, data []byte) (Artifact, error) {
+	if s.Scanner != nil {
+		if err := s.Scanner.Scan(ctx, name, data); err != nil {
+			if errors.Is(err, ErrInfected) {
+				stored.Inc("infected")
+			} else {
+				stored.Inc("scan_failed")
+			}
+			return Artifact{}, err
+		}
+	}
+	sum := sha256.Sum256(data)
+	now := time.Now().UTC()
+	a := Artifact{
+		ID:          newID(),
+		TenantID:    tenantID,
+		Name:        name,
+		ContentType: contentType(name, data),
+		Size:        int64(len(data)),
+		SHA256:      hex.EncodeToString(sum[:]),
+		CreatedAt:   now,
+		ExpiresAt:   now.Add(s.TTL),
+	}
+	meta, err := json.Marshal(a)
+	if err != nil {
+		return Artifact{}, err
+	}
+	if err := os.WriteFile(s.path(a.ID, ".bin"), data, 0o600); err != nil {
+		stored.Inc("error")
+		return Artifact{}, fmt.Errorf("artifacts: %w", err)
+	}
+	// The metadata is written last: an artifact without it doesn't exist.
+	if err := os.WriteFile(s.path(a.ID, ".json"), meta, 0o600); err != nil {
+		os.Remove(s.path(a.ID, ".bin"))
+		stored.Inc("error")
+		return Artifact{}, fmt.Errorf("artifacts: %w", err)
+	}
+	stored.Inc("stored")
+	return a, nil
+}
+
+func (s *Store) path(id, ext string) string {
+	return filepath.Join(s.Dir, id+ext)
+}
+
+// contentType guesses the type from the name, then the content.
+func contentType(name string, data []byte) string {
+	if t := mime.TypeByExtension(filepath.Ext(name)); t != "" {
+		return t
+	}
+	return http.DetectContentType(data)
+}
+
+// Get returns an unexpired artifact's metadata.
+func (s *Store) Get(id string) (Artifact, error) {
+	if !validID.MatchString(id) {
+		return Artifact{}, ErrNotFound
+	}
+	b, err := os.ReadFile(s.path(id, ".json"))
+	if err != nil {
+		return Artifact{}, ErrNotFound
+	}
+	var a Artifact
+	if err := json.Unmarshal(b, &a); err != nil || time.Now().After(a.ExpiresAt) {
+		return Artifact{}, ErrNotFound
+	}
+	return a, nil
+}
+
+// URL returns a's download URL, valid until it expires.
+func (s *Store) URL(a Artifact) string {
+	exp := strconv.FormatInt(a.ExpiresAt.Unix(), 10)
+	q := url.Values{"expires": {exp}, "sig": {s.sign(a.ID, exp)}}
+	return strings.TrimSuffix(s.BaseURL, "/") + "/v1/artifacts/" + a.ID + "?" + q.Encode()
+}
+
+func (s *Store) sign(id, expires string) string {
+	mac := hmac.New(sha256.New, s.key)
+	mac.Write([]byte(id + "\n" + expires))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Handler serves GET /v1/artifacts/{id}?expires=&sig=. The signature is
+// the only credential, so the route needs no API key. Files are always
+// served as attachments, never rendered by the browser.
+func (s *Store) Handler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	id, exp, sig := r.PathValue("id"), r.URL.Query().Get("expires"), r.URL.Query().Get("sig")
+	unix, err := strconv.ParseInt(exp, 10, 64)
+	if err != nil || !hmac.Equal([]byte(sig), []byte(s.sign(id, exp))) {
+		http.Error(w, "invalid signature", http.StatusForbidden)
+		return
+	}
+	if time.Now().Unix() > unix {
+		http.Error(w, "link expired", http.StatusGone)
+		return
+	}
+	a, err := s.Get(id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	f, err := os.Open(s.path(id, ".bin"))
+	if err != nil {
+		http.Error(w, ErrNotFound.Error(), http.StatusNotFound)
+		return
+	}
+	defer f.Close()
+	w.Header().Set("Content-Type", a.ContentType)
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": a.Name}))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Content-Security-Policy", "sandbox")
+	w.Header().Set("Cache-Control", "private, no-store")
+	http.ServeContent(w, r, "", a.CreatedAt, f)
+}
+
+// Run removes expired artifacts every few minutes until ctx is done.
+func (s *Store) Run(ctx context.Context) {
+	ticker := time.NewTicker(5 * time.Minute)
+	defer ticker.Stop()
+	for {
+		s.prune()
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (s *Store) prune() {
+	// Files whose metadata was never written (a crash mid-Put) go too.
+	bins, _ := filepath.Glob(filepath.Join(s.Dir, "art_*.bin"))
+	for _, b := range bins {
+		info, err := os.Stat(b)
+		if _, merr := os.Stat(strings.TrimSuffix(b, ".bin") + ".json"); err == nil && os.IsNotExist(merr) && time.Since(info.ModTime()) > s.TTL {
+			os.Remove(b)
+		}
+	}
+	metas, err := filepath.Glob(filepath.Join(s.Dir, "art_*.json"))
+	if err != nil {
+		return
+	}
+	for _, m := range metas {
+		id := strings.TrimSuffix(filepath.Base(m), ".json")
+		if _, err := s.Get(id); err == nil {
+			continue
+		}
+		for _, p := range []string{s.path(id, ".bin"), m} {
+			if err := os.Remove(p); err != nil && !os.IsNotExist(err) {
+				log.Printf("remove expired artifact %s: %v", id, err)
+			}
+		}
+	}
+}
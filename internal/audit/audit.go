@@ -0,0 +1,282 @@
+// Package audit keeps the compliance record of chat transactions: for
+// each request, the masked prompt, the risk verdict, the path taken, the
+// verdicts on external data, what output review changed and a hash of the
+// final answer. Records are only ever appended — to daily JSON Lines
+// files, to a SQL table, to S3 objects, or to an external collector — and
+// pruned once they are older than the retention period.
+package audit
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/shivansh-source/nopass/internal/metrics"
+	"github.com/shivansh-source/nopass/internal/storage"
+	"github.com/shivansh-source/nopass/internal/types"
+)
+
+// Kind is the storage.AuditRecord kind of a chat transaction.
+const Kind = "chat"
+
+// Transaction is the Data of one chat transaction's record. It holds no
+// unmasked prompt text and only a hash of the answer.
+type Transaction struct {
+	RequestID    string `json:"request_id"`
+	UserID       string `json:"user_id,omitempty"`
+	SessionID    string `json:"session_id,omitempty"`
+	Disposition  string `json:"disposition"`
+	MaskedPrompt string `json:"masked_prompt,omitempty"`
+	// PromptSource is which version of the message went to the model
+	// ("raw" or "sanitized"); the hex SHA-256 of both versions tie the
+	// masked prompt to them without keeping either.
+	PromptSource          string        `json:"prompt_source,omitempty"`
+	RawPromptSHA256       string        `json:"raw_prompt_sha256,omitempty"`
+	SanitizedPromptSHA256 string        `json:"sanitized_prompt_sha256,omitempty"`
+	Risk                  *Risk         `json:"risk,omitempty"`
+	Path                  types.Path    `json:"path,omitempty"`
+	ExternalData          []DataVerdict `json:"external_data,omitempty"`
+	Output                *Output       `json:"output,omitempty"`
+	// Techniques are the attack techniques the request showed (see
+	// internal/taxonomy).
+	Techniques []string `json:"techniques,omitempty"`
+	// AnswerSHA256 is the hex SHA-256 of the answer returned, if any.
+	AnswerSHA256 string `json:"answer_sha256,omitempty"`
+}
+
+// Risk is the risk stage's verdict.
+type Risk struct {
+	Level             types.RiskLevel `json:"level"`
+	Flags             []string        `json:"flags,omitempty"`
+	SelfCheckRequired bool            `json:"self_check_required,omitempty"`
+}
+
+// DataVerdict is what happened to one external data block.
+type DataVerdict struct {
+	ID        string           `json:"id"`
+	Source    string           `json:"source,omitempty"`
+	Dangerous bool             `json:"dangerous"`
+	Status    types.DataStatus `json:"status"`
+	Reason    string           `json:"reason,omitempty"`
+}
+
+// Output is what output review and the later answer filters did.
+type Output struct {
+	Modified bool     `json:"modified"`
+	Blocked  bool     `json:"blocked"`
+	Withheld bool     `json:"withheld"`
+	Flags    []string `json:"flags,omitempty"`
+}
+
+// HashAnswer returns the hex SHA-256 of answer.
+func HashAnswer(answer string) string {
+	sum := sha256.Sum256([]byte(answer))
+	return hex.EncodeToString(sum[:])
+}
+
+// Sink is an append-only destination for audit records.
+// storage.AuditStore is one.
+type Sink interface {
+	Append(ctx context.Context, r storage.AuditRecord) error
+}
+
+// Pruner is implemented by sinks that can delete records older than
+// before. PruneAudit returns how many records it deleted (files, for
+// FileSink).
+type Pruner interface {
+	PruneAudit(ctx context.Context, before time.Time) (int64, error)
+}
+
+// Log records chat transactions to a Sink. A nil *Log records nothing.
+//
+// Records are appended by a background writer under its own context, so
+// a transaction is recorded however its request ended: cancelled by the
+// client, past its deadline or panicking. Close drains the writer.
+type Log struct {
+	Sink Sink
+	// Retention is how long records are kept; zero keeps them forever.
+	// It is enforced by Run on sinks that are Pruners.
+	Retention time.Duration
+
+	once    sync.Once
+	mu      sync.RWMutex // held for writing to close queue
+	closed  bool
+	queue   chan storage.AuditRecord
+	drained chan struct{}
+}
+
+// The writer's queue length, how long one append may take and how often
+// a failed one is retried.
+const (
+	queueSize     = 1024
+	appendTimeout = 10 * time.Second
+	appendRetries = 3
+)
+
+var recorded = metrics.NewCounterVec(
+	"nopass_audit_records_total",
+	"Chat transaction audit records by result.",
+	"result",
+)
+
+// Record queues tx for tenantID for the background writer; if the queue
+// is full, or the log closed, it is appended before Record returns rather
+// than dropped. ctx is not used for the append. Failures are retried,
+// then logged and counted; the chat request is not failed for them.
+func (l *Log) Record(ctx context.Context, tenantID string, tx Transaction) {
+	if l == nil {
+		return
+	}
+	data, err := json.Marshal(tx)
+	if err != nil {
+		log.Printf("encode audit transaction: %v", err)
+		recorded.Inc("failed")
+		return
+	}
+	rec := storage.AuditRecord{
+		ID:       NewID(),
+		TenantID: tenantID,
+		Time:     time.Now().UTC(),
+		Kind:     Kind,
+		Actor:    tx.UserID,
+		Data:     data,
+	}
+	l.once.Do(l.start)
+	l.mu.RLock()
+	queued := false
+	if !l.closed {
+		select {
+		case l.queue <- rec:
+			queued = true
+		default:
+		}
+	}
+	l.mu.RUnlock()
+	if !queued {
+		l.append(rec)
+	}
+}
+
+func (l *Log) start() {
+	l.queue = make(chan storage.AuditRecord, queueSize)
+	l.drained = make(chan struct{})
+	go func() {
+		defer close(l.drained)
+		for rec := range l.queue {
+			l.append(rec)
+		}
+	}()
+}
+
+// append writes rec to the sink, retrying with backoff.
+func (l *Log) append(rec storage.AuditRecord) {
+	var err error
+	for attempt := 0; attempt <= appendRetries; attempt++ {
+		if attempt > 0 {
+			time.Sleep(time.Duration(attempt) * 200 * time.Millisecond)
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), appendTimeout)
+		err = l.Sink.Append(ctx, rec)
+		cancel()
+		if err == nil {
+			recorded.Inc("ok")
+			return
+		}
+	}
+	log.Printf("append audit record %s (tenant=%s): %v", rec.ID, rec.TenantID, err)
+	recorded.Inc("failed")
+}
+
+// Close stops queueing and waits until the queued records are written or
+// ctx is done. Records made after Close are appended inline.
+func (l *Log) Close(ctx context.Context) error {
+	if l == nil {
+		return nil
+	}
+	l.once.Do(l.start)
+	l.mu.Lock()
+	if !l.closed {
+		l.closed = true
+		close(l.queue)
+	}
+	l.mu.Unlock()
+	select {
+	case <-l.drained:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("audit: %d records not written: %w", len(l.queue), ctx.Err())
+	}
+}
+
+// Run prunes records past retention hourly until ctx is done. It returns
+// at once when there is no retention or the sink can't prune.
+func (l *Log) Run(ctx context.Context) {
+	p, ok := l.Sink.(Pruner)
+	if !ok || l.Retention <= 0 {
+		return
+	}
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+	for {
+		n, err := p.PruneAudit(ctx, time.Now().Add(-l.Retention))
+		if err != nil {
+			log.Printf("prune audit records: %v", err)
+		} else if n > 0 {
+			log.Printf("audit retention %s: pruned %d", l.Retention, n)
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// NewID returns a new audit record ID.
+func NewID() string {
+	var b [12]byte
+	_, _ = rand.Read(b[:])
+	return "aud_" + hex.EncodeToString(b[:])
+}
+
+// Open opens the sink named by spec:
+//   - "file:<dir>" appends to daily JSON Lines files in dir;
+//   - "sqlite:<path>" or "postgres:<dsn>" inserts into a database's
+//     nopass_audit table, creating it if needed;
+//   - "s3://<bucket>/<prefix>" uploads batches as objects under prefix,
+//     with region and credentials from s3;
+//   - "otlp:<url>" or "collector:<url>" streams batches to an external
+//     collector (see CollectorSink), with TLS and spooling from collector.
+func Open(ctx context.Context, spec string, s3 S3Config, collector CollectorConfig) (Sink, error) {
+	for _, protocol := range []string{CollectorOTLP, CollectorNDJSON} {
+		if url, ok := strings.CutPrefix(spec, protocol+":"); ok {
+			return NewCollectorSink(protocol, url, collector)
+		}
+	}
+	if dir, ok := strings.CutPrefix(spec, "file:"); ok && dir != "" {
+		return NewFileSink(dir)
+	}
+	if rest, ok := strings.CutPrefix(spec, "s3://"); ok {
+		s3.Bucket, s3.Prefix, _ = strings.Cut(rest, "/")
+		if s3.Bucket == "" {
+			return nil, fmt.Errorf("audit: no bucket in %q", spec)
+		}
+		return NewS3Sink(s3), nil
+	}
+	backend, dsn, _ := strings.Cut(spec, ":")
+	if (backend != "sqlite" && backend != "postgres") || dsn == "" {
+		return nil, fmt.Errorf("audit: unknown sink %q", spec)
+	}
+	store, err := storage.OpenSQL(ctx, storage.Config{Backend: backend, DSN: dsn, AutoMigrate: true})
+	if err != nil {
+		return nil, err
+	}
+	return store, nil
+}
@@ -0,0 +1,458 @@
+package audit
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/shivansh-source/nopass/internal/storage"
+)
+
+// Collector protocols, the scheme of a collector sink's spec.
+const (
+	// CollectorOTLP posts batches to an OTLP/HTTP collector's /v1/logs as
+	// JSON log records, one per audit record, the record as the body.
+	CollectorOTLP = "otlp"
+	// CollectorNDJSON posts batches to the URL as JSON Lines, the same
+	// lines a file sink writes, for custom collectors.
+	CollectorNDJSON = "collector"
+)
+
+// CollectorConfig configures a CollectorSink.
+type CollectorConfig struct {
+	// CertFile and KeyFile are the client certificate the gateway presents
+	// and CAFile the roots that verify the collector, all PEM; set for
+	// mutual TLS.
+	CertFile string
+	KeyFile  string
+	CAFile   string
+	// SpoolDir keeps batches the collector could not take, sealed under
+	// SpoolKey (32 bytes, AES-256-GCM), until it takes them. Without it,
+	// failed batches wait in memory only.
+	SpoolDir string
+	SpoolKey []byte
+	// ServiceName is the OTLP resource's service.name.
+	ServiceName string
+}
+
+// CollectorSink streams records to an external collector and keeps none
+// of them on the gateway host in the clear, for deployments that may not
+// store conversation data locally. Like S3Sink it sends a batch once it
+// has MaxBatch records or its first record is FlushInterval old.
+//
+// A batch the collector refuses is sealed into SpoolDir, if set, and
+// resent oldest first before any newer batch; past MaxSpool batches the
+// oldest are dropped. Without a spool, failed batches are kept in memory
+// and retried, up to MaxPending records.
+type CollectorSink struct {
+	protocol      string
+	url           string
+	cfg           CollectorConfig
+	aead          cipher.AEAD
+	HTTPClient    *http.Client
+	MaxBatch      int
+	FlushInterval time.Duration
+	MaxPending    int
+	MaxSpool      int
+
+	mu      sync.Mutex
+	pending [][]byte
+	timer   *time.Timer
+	sends   sync.Mutex // one send at a time keeps batches in order
+}
+
+// NewCollectorSink creates a CollectorSink posting to url with protocol
+// (CollectorOTLP or CollectorNDJSON).
+func NewCollectorSink(protocol, url string, cfg CollectorConfig) (*CollectorSink, error) {
+	if protocol != CollectorOTLP && protocol != CollectorNDJSON {
+		return nil, fmt.Errorf("audit: unknown collector protocol %q", protocol)
+	}
+	if !strings.HasPrefix(url, "https://") && !strings.HasPrefix(url, "http://") {
+		return nil, fmt.Errorf("audit: collector URL %q is not http(s)", url)
+	}
+	if cfg.ServiceName == "" {
+		cfg.ServiceName = "nopass-gateway"
+	}
+	tlsConfig, err := cfg.tlsConfig()
+	if err != nil {
+		return nil, err
+	}
+	s := &CollectorSink{
+		protocol: protocol,
+		url:      strings.TrimSuffix(url, "/"),
+		cfg:      cfg,
+		HTTPClient: &http.Client{
+			Timeout:   30 * time.Second,
+			Transport: &http.Transport{TLSClientConfig: tlsConfig, Proxy: http.ProxyFromEnvironment},
+		},
+		MaxBatch:      500,
+		FlushInterval: 5 * time.Second,
+		MaxPending:    50000,
+		MaxSpool:      10000,
+	}
+	if cfg.SpoolDir != "" {
+		if s.aead, err = spoolCipher(cfg.SpoolKey); err != nil {
+			return nil, err
+		}
+		if err := os.MkdirAll(cfg.SpoolDir, 0o700); err != nil {
+			return nil, fmt.Errorf("audit: %w", err)
+		}
+		if files, _ := s.spooled(); len(files) > 0 {
+			log.Printf("audit collector: %d spooled batches to resend", len(files))
+			s.timer = time.AfterFunc(s.FlushInterval, func() { s.Flush(context.Background()) })
+		}
+	}
+	return s, nil
+}
+
+func (c CollectorConfig) tlsConfig() (*tls.Config, error) {
+	if c.CertFile == "" && c.KeyFile == "" && c.CAFile == "" {
+		return nil, nil
+	}
+	cfg := &tls.Config{MinVersion: tls.VersionTLS12}
+	if c.CertFile != "" || c.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("audit: collector client certificate: %w", err)
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+	if c.CAFile != "" {
+		pem, err := os.ReadFile(c.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("audit: collector CA: %w", err)
+		}
+		cfg.RootCAs = x509.NewCertPool()
+		if !cfg.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("audit: collector CA %s holds no certificates", c.CAFile)
+		}
+	}
+	return cfg, nil
+}
+
+func spoolCipher(key []byte) (cipher.AEAD, error) {
+	if len(key) != 32 {
+		return nil, errors.New("audit: a collector spool needs a 32-byte key")
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// Append implements Sink. The record is buffered, not yet sent.
+func (s *CollectorSink) Append(_ context.Context, r storage.AuditRecord) error {
+	b, err := encodeLine(r)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.pending) >= s.MaxPending {
+		recorded.Inc("dropped")
+		s.pending = s.pending[1:]
+	}
+	s.pending = append(s.pending, b)
+	switch {
+	case len(s.pending) >= s.MaxBatch:
+		go s.Flush(context.Background())
+	case s.timer == nil:
+		s.timer = time.AfterFunc(s.FlushInterval, func() { s.Flush(context.Background()) })
+	}
+	return nil
+}
+
+// Flush resends spooled batches, then sends the buffered records.
+func (s *CollectorSink) Flush(ctx context.Context) error {
+	s.sends.Lock()
+	defer s.sends.Unlock()
+
+	s.mu.Lock()
+	batch := s.pending
+	s.pending = nil
+	if s.timer != nil {
+		s.timer.Stop()
+		s.timer = nil
+	}
+	s.mu.Unlock()
+
+	err := s.resend(ctx)
+	if err == nil && len(batch) > 0 {
+		err = s.send(ctx, batch)
+	}
+	if err == nil {
+		return nil
+	}
+	if len(batch) > 0 {
+		log.Printf("send %d audit records to %s collector: %v", len(batch), s.protocol, err)
+	}
+	if len(batch) > 0 && s.aead != nil {
+		if serr := s.spool(batch); serr != nil {
+			log.Printf("spool %d audit records: %v", len(batch), serr)
+		} else {
+			batch = nil
+		}
+	}
+	s.mu.Lock()
+	s.pending = append(batch, s.pending...)
+	if over := len(s.pending) - s.MaxPending; over > 0 {
+		recorded.Add(uint64(over), "dropped")
+		s.pending = s.pending[over:]
+	}
+	if s.timer == nil {
+		s.timer = time.AfterFunc(s.FlushInterval, func() { s.Flush(context.Background()) })
+	}
+	s.mu.Unlock()
+	return err
+}
+
+// Close sends what is buffered, spooling it if the collector is down.
+func (s *CollectorSink) Close() error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	err := s.Flush(ctx)
+	s.mu.Lock()
+	if s.timer != nil {
+		s.timer.Stop()
+		s.timer = nil
+	}
+	lost := len(s.pending)
+	s.mu.Unlock()
+	if lost > 0 {
+		recorded.Add(uint64(lost), "dropped")
+	}
+	return err
+}
+
+// send posts one batch of lines.
+func (s *CollectorSink) send(ctx context.Context, batch [][]byte) error {
+	url, contentType := s.url, "application/x-ndjson"
+	body := bytes.Join(batch, nil)
+	if s.protocol == CollectorOTLP {
+		url, contentType = s.url+"/v1/logs", "application/json"
+		var err error
+		if body, err = s.otlpLogs(batch); err != nil {
+			return err
+		}
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("create collector request: %w", err)
+	}
+	req.Header.Set("Content-Type", contentType)
+	resp, err := s.HTTPClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("deliver audit records: %w", err)
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 1<<16))
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("collector returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// Spooled batches are files <unix nanos>-<records>.spool holding a GCM
+// nonce and the sealed JSON Lines; the name is bound as additional data
+// so files can't be swapped.
+const spoolSuffix = ".spool"
+
+func (s *CollectorSink) spool(batch [][]byte) error {
+	name := strconv.FormatInt(time.Now().UnixNano(), 10) + "-" + strconv.Itoa(len(batch)) + spoolSuffix
+	nonce := make([]byte, s.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+	sealed := s.aead.Seal(nonce, nonce, bytes.Join(batch, nil), []byte(name))
+	tmp := filepath.Join(s.cfg.SpoolDir, "."+name)
+	if err := os.WriteFile(tmp, sealed, 0o600); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, filepath.Join(s.cfg.SpoolDir, name)); err != nil {
+		return err
+	}
+	files, err := s.spooled()
+	if err != nil {
+		return nil
+	}
+	for len(files) > s.MaxSpool {
+		s.drop(files[0])
+		files = files[1:]
+	}
+	return nil
+}
+
+// spooled lists the spool's batches, oldest first.
+func (s *CollectorSink) spooled() ([]string, error) {
+	entries, err := os.ReadDir(s.cfg.SpoolDir)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, e := range entries {
+		if name := e.Name(); !e.IsDir() && !strings.HasPrefix(name, ".") && strings.HasSuffix(name, spoolSuffix) {
+			names = append(names, name)
+		}
+	}
+	slices.SortFunc(names, cmpSpooled)
+	return names, nil
+}
+
+// cmpSpooled orders spool names by their timestamp.
+func cmpSpooled(a, b string) int {
+	at, _, _ := strings.Cut(a, "-")
+	bt, _, _ := strings.Cut(b, "-")
+	if len(at) != len(bt) {
+		return len(at) - len(bt)
+	}
+	return strings.Compare(a, b)
+}
+
+// drop removes a spooled batch, counting its records as dropped.
+func (s *CollectorSink) drop(name string) {
+	if err := os.Remove(filepath.Join(s.cfg.SpoolDir, name)); err != nil {
+		log.Printf("remove spooled audit batch %s: %v", name, err)
+		return
+	}
+	_, n, _ := strings.Cut(strings.TrimSuffix(name, spoolSuffix), "-")
+	if count, err := strconv.Atoi(n); err == nil {
+		recorded.Add(uint64(count), "dropped")
+	}
+}
+
+// resend sends spooled batches oldest first, stopping at the first the
+// collector refuses.
+func (s *CollectorSink) resend(ctx context.Context) error {
+	if s.aead == nil {
+		return nil
+	}
+	files, err := s.spooled()
+	if err != nil {
+		return fmt.Errorf("audit: read spool: %w", err)
+	}
+	for _, name := range files {
+		path := filepath.Join(s.cfg.SpoolDir, name)
+		sealed, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("audit: read spool: %w", err)
+		}
+		n := s.aead.NonceSize()
+		var data []byte
+		if len(sealed) >= n {
+			data, err = s.aead.Open(nil, sealed[:n], sealed[n:], []byte(name))
+		}
+		if len(sealed) < n || err != nil {
+			log.Printf("spooled audit batch %s does not open under the spool key; dropping it", name)
+			s.drop(name)
+			continue
+		}
+		if err := s.send(ctx, splitLines(data)); err != nil {
+			return err
+		}
+		if err := os.Remove(path); err != nil {
+			return fmt.Errorf("audit: remove spooled batch: %w", err)
+		}
+		log.Printf("resent spooled audit batch %s", name)
+	}
+	return nil
+}
+
+func splitLines(data []byte) [][]byte {
+	var out [][]byte
+	for len(data) > 0 {
+		i := bytes.IndexByte(data, '\n')
+		if i < 0 {
+			out = append(out, append(data, '\n'))
+			break
+		}
+		out = append(out, data[:i+1])
+		data = data[i+1:]
+	}
+	return out
+}
+
+// OTLP JSON encoding of ExportLogsServiceRequest; times are decimal
+// strings of Unix nanoseconds.
+type (
+	otlpLogsRequest struct {
+		ResourceLogs []otlpResourceLogs `json:"resourceLogs"`
+	}
+	otlpResourceLogs struct {
+		Resource  otlpResource    `json:"resource"`
+		ScopeLogs []otlpScopeLogs `json:"scopeLogs"`
+	}
+	otlpResource struct {
+		Attributes []otlpAttr `json:"attributes"`
+	}
+	otlpScopeLogs struct {
+		Scope      otlpScope       `json:"scope"`
+		LogRecords []otlpLogRecord `json:"logRecords"`
+	}
+	otlpScope struct {
+		Name string `json:"name"`
+	}
+	otlpLogRecord struct {
+		TimeUnixNano         string     `json:"timeUnixNano"`
+		ObservedTimeUnixNano string     `json:"observedTimeUnixNano"`
+		SeverityNumber       int        `json:"severityNumber"` // 9 is INFO
+		SeverityText         string     `json:"severityText"`
+		Body                 otlpValue  `json:"body"`
+		Attributes           []otlpAttr `json:"attributes"`
+	}
+	otlpAttr struct {
+		Key   string    `json:"key"`
+		Value otlpValue `json:"value"`
+	}
+	otlpValue struct {
+		StringValue string `json:"stringValue"`
+	}
+)
+
+func (s *CollectorSink) otlpLogs(batch [][]byte) ([]byte, error) {
+	now := strconv.FormatInt(time.Now().UnixNano(), 10)
+	records := make([]otlpLogRecord, 0, len(batch))
+	for _, b := range batch {
+		var l line
+		if err := json.Unmarshal(b, &l); err != nil {
+			return nil, fmt.Errorf("audit: decode batched record: %w", err)
+		}
+		attrs := []otlpAttr{
+			{Key: "audit.id", Value: otlpValue{l.ID}},
+			{Key: "audit.kind", Value: otlpValue{l.Kind}},
+			{Key: "tenant.id", Value: otlpValue{l.TenantID}},
+		}
+		if l.Actor != "" {
+			attrs = append(attrs, otlpAttr{Key: "audit.actor", Value: otlpValue{l.Actor}})
+		}
+		records = append(records, otlpLogRecord{
+			TimeUnixNano:         strconv.FormatInt(l.Time.UnixNano(), 10),
+			ObservedTimeUnixNano: now,
+			SeverityNumber:       9,
+			SeverityText:         "INFO",
+			Body:                 otlpValue{string(bytes.TrimSuffix(b, []byte("\n")))},
+			Attributes:           attrs,
+		})
+	}
+	return json.Marshal(otlpLogsRequest{ResourceLogs: []otlpResourceLogs{{
+		Resource:  otlpResource{Attributes: []otlpAttr{{Key: "service.name", Value: otlpValue{s.cfg.ServiceName}}}},
+		ScopeLogs: []otlpScopeLogs{{Scope: otlpScope{Name: "github.com/shivansh-source/nopass/audit"}, LogRecords: records}},
+	}}})
+}
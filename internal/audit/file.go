@@ -0,0 +1,205 @@
+package audit
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/shivansh-source/nopass/internal/storage"
+)
+
+// line is a record as written to files and S3 objects: one JSON object
+// per line, with Data inline rather than base64.
+type line struct {
+	ID       string          `json:"id"`
+	TenantID string          `json:"tenant_id"`
+	Time     time.Time       `json:"time"`
+	Kind     string          `json:"kind"`
+	Actor    string          `json:"actor,omitempty"`
+	Data     json.RawMessage `json:"data,omitempty"`
+}
+
+func encodeLine(r storage.AuditRecord) ([]byte, error) {
+	l := line{ID: r.ID, TenantID: r.TenantID, Time: r.Time, Kind: r.Kind, Actor: r.Actor}
+	if len(r.Data) > 0 {
+		if !json.Valid(r.Data) {
+			return nil, fmt.Errorf("audit: record %s data is not JSON", r.ID)
+		}
+		l.Data = r.Data
+	}
+	b, err := json.Marshal(l)
+	if err != nil {
+		return nil, err
+	}
+	return append(b, '\n'), nil
+}
+
+// FileSink appends records to one JSON Lines file per UTC day,
+// audit-YYYY-MM-DD.jsonl. Files are opened append-only and never
+// rewritten; retention removes whole days.
+type FileSink struct {
+	dir string
+
+	mu   sync.Mutex
+	day  string
+	file *os.File
+}
+
+const filePrefix, fileSuffix = "audit-", ".jsonl"
+
+// NewFileSink creates dir if needed and returns a sink writing there.
+func NewFileSink(dir string) (*FileSink, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("audit: %w", err)
+	}
+	return &FileSink{dir: dir}, nil
+}
+
+// Append implements Sink. The line is synced to disk before it returns.
+func (s *FileSink) Append(_ context.Context, r storage.AuditRecord) error {
+	b, err := encodeLine(r)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	day := r.Time.UTC().Format(time.DateOnly)
+	if s.file == nil || s.day != day {
+		if s.file != nil {
+			s.file.Close()
+		}
+		f, err := os.OpenFile(filepath.Join(s.dir, filePrefix+day+fileSuffix), os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+		if err != nil {
+			s.file = nil
+			return fmt.Errorf("audit: %w", err)
+		}
+		s.file, s.day = f, day
+	}
+	if _, err := s.file.Write(b); err != nil {
+		return fmt.Errorf("audit: write %s: %w", s.file.Name(), err)
+	}
+	return s.file.Sync()
+}
+
+// PruneAudit implements Pruner, removing the files of days that ended
+// before before. It returns how many files it removed.
+func (s *FileSink) PruneAudit(_ context.Context, before time.Time) (int64, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return 0, fmt.Errorf("audit: %w", err)
+	}
+	var n int64
+	for _, e := range entries {
+		name := e.Name()
+		date, ok := strings.CutPrefix(strings.TrimSuffix(name, fileSuffix), filePrefix)
+		if !ok || !strings.HasSuffix(name, fileSuffix) {
+			continue
+		}
+		day, err := time.Parse(time.DateOnly, date)
+		if err != nil || day.Add(24*time.Hour).After(before) {
+			continue
+		}
+		if err := os.Remove(filepath.Join(s.dir, name)); err != nil {
+			return n, fmt.Errorf("audit: %w", err)
+		}
+		n++
+	}
+	return n, nil
+}
+
+// Query implements Source, reading the files of the days in q's range
+// from the newest.
+func (s *FileSink) Query(ctx context.Context, q storage.AuditQuery) ([]storage.AuditRecord, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, fmt.Errorf("audit: %w", err)
+	}
+	var days []string
+	for _, e := range entries {
+		date, ok := strings.CutPrefix(strings.TrimSuffix(e.Name(), fileSuffix), filePrefix)
+		if !ok || !strings.HasSuffix(e.Name(), fileSuffix) {
+			continue
+		}
+		if (!q.Since.IsZero() && date < q.Since.UTC().Format(time.DateOnly)) ||
+			(!q.Until.IsZero() && date > q.Until.UTC().Format(time.DateOnly)) {
+			continue
+		}
+		days = append(days, date)
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(days)))
+
+	var out []storage.AuditRecord
+	for _, day := range days {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		records, err := readLines(filepath.Join(s.dir, filePrefix+day+fileSuffix))
+		if err != nil {
+			return nil, err
+		}
+		// Lines are in append order; records may be a little out of time
+		// order across concurrent requests.
+		sort.SliceStable(records, func(i, j int) bool { return records[i].Time.After(records[j].Time) })
+		for _, r := range records {
+			if !matches(q, r) {
+				continue
+			}
+			out = append(out, r)
+			if q.Limit > 0 && len(out) == q.Limit {
+				return out, nil
+			}
+		}
+	}
+	return out, nil
+}
+
+// readLines reads the records in a JSON Lines file. A torn last line,
+// left by a crash mid-write, is skipped.
+func readLines(name string) ([]storage.AuditRecord, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, fmt.Errorf("audit: %w", err)
+	}
+	defer f.Close()
+	var out []storage.AuditRecord
+	sc := bufio.NewScanner(f)
+	sc.Buffer(nil, 16<<20)
+	for sc.Scan() {
+		var l line
+		if json.Unmarshal(sc.Bytes(), &l) != nil {
+			continue
+		}
+		out = append(out, storage.AuditRecord{ID: l.ID, TenantID: l.TenantID, Time: l.Time, Kind: l.Kind, Actor: l.Actor, Data: l.Data})
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("audit: read %s: %w", name, err)
+	}
+	return out, nil
+}
+
+// matches applies q's filters to r, as the storage backends do.
+func matches(q storage.AuditQuery, r storage.AuditRecord) bool {
+	return (q.TenantID == "" || r.TenantID == q.TenantID) &&
+		(q.Kind == "" || r.Kind == q.Kind) &&
+		(q.Since.IsZero() || !r.Time.Before(q.Since)) &&
+		(q.Until.IsZero() || r.Time.Before(q.Until))
+}
+
+// Close closes the current file.
+func (s *FileSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.file == nil {
+		return nil
+	}
+	err := s.file.Close()
+	s.file = nil
+	return err
+}
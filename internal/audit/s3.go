@@ -0,0 +1,366 @@
+package audit
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/shivansh-source/nopass/internal/storage"
+)
+
+// S3Config configures an S3Sink.
+type S3Config struct {
+	Bucket string
+	Prefix string
+	Region string // default us-east-1
+	KeyID  string
+	Secret string
+	// Endpoint overrides the bucket's URL, for S3-compatible stores; the
+	// bucket is then the first path element.
+	Endpoint string
+}
+
+// S3Sink uploads records in batches, each a new JSON Lines object under
+// <prefix>/YYYY/MM/DD/. Objects are never overwritten, so the bucket
+// holds an append-only log; expire old objects with a lifecycle rule on
+// the prefix, and use Object Lock if records must be immutable.
+//
+// A batch is uploaded once it has MaxBatch records or its first record is
+// FlushInterval old. Failed batches are kept and retried with the next
+// one, up to MaxPending records; past that the oldest are dropped.
+type S3Sink struct {
+	cfg           S3Config
+	HTTPClient    *http.Client
+	MaxBatch      int
+	FlushInterval time.Duration
+	MaxPending    int
+
+	mu      sync.Mutex
+	pending [][]byte
+	timer   *time.Timer
+	cache   map[string][]storage.AuditRecord // object key to its records
+	uploads sync.Mutex                       // one upload at a time keeps retries in order
+}
+
+// NewS3Sink creates an S3Sink with the default batching.
+func NewS3Sink(cfg S3Config) *S3Sink {
+	if cfg.Region == "" {
+		cfg.Region = "us-east-1"
+	}
+	return &S3Sink{
+		cfg:           cfg,
+		HTTPClient:    &http.Client{Timeout: 30 * time.Second},
+		MaxBatch:      500,
+		FlushInterval: time.Minute,
+		MaxPending:    50000,
+	}
+}
+
+// Append implements Sink. The record is buffered, not yet uploaded.
+func (s *S3Sink) Append(_ context.Context, r storage.AuditRecord) error {
+	b, err := encodeLine(r)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.pending) >= s.MaxPending {
+		recorded.Inc("dropped")
+		s.pending = s.pending[1:]
+	}
+	s.pending = append(s.pending, b)
+	switch {
+	case len(s.pending) >= s.MaxBatch:
+		go s.Flush(context.Background())
+	case s.timer == nil:
+		s.timer = time.AfterFunc(s.FlushInterval, func() { s.Flush(context.Background()) })
+	}
+	return nil
+}
+
+// Flush uploads the buffered records now.
+func (s *S3Sink) Flush(ctx context.Context) error {
+	s.uploads.Lock()
+	defer s.uploads.Unlock()
+
+	s.mu.Lock()
+	batch := s.pending
+	s.pending = nil
+	if s.timer != nil {
+		s.timer.Stop()
+		s.timer = nil
+	}
+	s.mu.Unlock()
+	if len(batch) == 0 {
+		return nil
+	}
+
+	now := time.Now().UTC()
+	key := path.Join(s.cfg.Prefix, now.Format("2006/01/02"), strconv.FormatInt(now.UnixNano(), 10)+"-"+NewID()+".jsonl")
+	err := s.put(ctx, key, bytes.Join(batch, nil))
+	if err == nil {
+		return nil
+	}
+	log.Printf("upload %d audit records to s3://%s/%s: %v", len(batch), s.cfg.Bucket, key, err)
+	s.mu.Lock()
+	s.pending = append(batch, s.pending...)
+	if over := len(s.pending) - s.MaxPending; over > 0 {
+		recorded.Add(uint64(over), "dropped")
+		s.pending = s.pending[over:]
+	}
+	if s.timer == nil {
+		s.timer = time.AfterFunc(s.FlushInterval, func() { s.Flush(context.Background()) })
+	}
+	s.mu.Unlock()
+	return err
+}
+
+// Close uploads what is buffered.
+func (s *S3Sink) Close() error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	return s.Flush(ctx)
+}
+
+func (s *S3Sink) put(ctx context.Context, key string, body []byte) error {
+	req, err := s.request(ctx, http.MethodPut, key, nil, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-ndjson")
+	// Refuse to replace an object that somehow exists already.
+	req.Header.Set("If-None-Match", "*")
+	_, err = s.do(req, 0)
+	return err
+}
+
+// Query implements Source. Every object that may hold records in q's
+// range is read — objects hold batches that overlap in time, so there is
+// no stopping early — which makes narrow time ranges much cheaper to
+// search. Objects never change, so recently read ones are kept in memory
+// for the next page. Records not yet uploaded are not seen.
+func (s *S3Sink) Query(ctx context.Context, q storage.AuditQuery) ([]storage.AuditRecord, error) {
+	keys, err := s.list(ctx, q.Since, q.Until)
+	if err != nil {
+		return nil, err
+	}
+	var out []storage.AuditRecord
+	for _, key := range keys {
+		records, err := s.object(ctx, key)
+		if err != nil {
+			return nil, err
+		}
+		for _, r := range records {
+			if matches(q, r) {
+				out = append(out, r)
+			}
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].Time.After(out[j].Time) })
+	if q.Limit > 0 && len(out) > q.Limit {
+		out = out[:q.Limit]
+	}
+	return out, nil
+}
+
+// maxCachedObjects bounds the objects Query keeps in memory.
+const maxCachedObjects = 256
+
+// object returns the records in the object at key.
+func (s *S3Sink) object(ctx context.Context, key string) ([]storage.AuditRecord, error) {
+	s.mu.Lock()
+	records, ok := s.cache[key]
+	s.mu.Unlock()
+	if ok {
+		return records, nil
+	}
+
+	req, err := s.request(ctx, http.MethodGet, key, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	data, err := s.do(req, 64<<20)
+	if err != nil {
+		return nil, fmt.Errorf("audit: get %s: %w", key, err)
+	}
+	for _, b := range bytes.Split(data, []byte("\n")) {
+		var l line
+		if json.Unmarshal(b, &l) == nil {
+			records = append(records, storage.AuditRecord{ID: l.ID, TenantID: l.TenantID, Time: l.Time, Kind: l.Kind, Actor: l.Actor, Data: l.Data})
+		}
+	}
+
+	s.mu.Lock()
+	if s.cache == nil || len(s.cache) >= maxCachedObjects {
+		s.cache = make(map[string][]storage.AuditRecord)
+	}
+	s.cache[key] = records
+	s.mu.Unlock()
+	return records, nil
+}
+
+// list returns, oldest first, the keys of objects that may hold records
+// from since to until. An object is uploaded after the records in it, so
+// it may sit in the folder of the day after its last record's.
+func (s *S3Sink) list(ctx context.Context, since, until time.Time) ([]string, error) {
+	prefix := strings.TrimSuffix(s.cfg.Prefix, "/")
+	if prefix != "" {
+		prefix += "/"
+	}
+	query := url.Values{"list-type": {"2"}, "prefix": {prefix}}
+	if !since.IsZero() {
+		query.Set("start-after", prefix+since.UTC().Format("2006/01/02"))
+	}
+	var last string
+	if !until.IsZero() {
+		last = prefix + until.UTC().Add(24*time.Hour).Format("2006/01/02") + "/~"
+	}
+
+	var keys []string
+	for {
+		req, err := s.request(ctx, http.MethodGet, "", query, nil)
+		if err != nil {
+			return nil, err
+		}
+		data, err := s.do(req, 16<<20)
+		if err != nil {
+			return nil, fmt.Errorf("audit: list s3://%s/%s: %w", s.cfg.Bucket, prefix, err)
+		}
+		var res struct {
+			Contents []struct {
+				Key string `xml:"Key"`
+			} `xml:"Contents"`
+			IsTruncated           bool   `xml:"IsTruncated"`
+			NextContinuationToken string `xml:"NextContinuationToken"`
+		}
+		if err := xml.Unmarshal(data, &res); err != nil {
+			return nil, fmt.Errorf("audit: decode object list: %w", err)
+		}
+		for _, c := range res.Contents {
+			if last != "" && c.Key > last {
+				return keys, nil
+			}
+			if strings.HasSuffix(c.Key, ".jsonl") {
+				keys = append(keys, c.Key)
+			}
+		}
+		if !res.IsTruncated || res.NextContinuationToken == "" {
+			return keys, nil
+		}
+		query.Set("continuation-token", res.NextContinuationToken)
+	}
+}
+
+// request creates a signed request for key (the bucket itself if empty).
+func (s *S3Sink) request(ctx context.Context, method, key string, query url.Values, body []byte) (*http.Request, error) {
+	u := url.URL{Scheme: "https", Host: fmt.Sprintf("%s.s3.%s.amazonaws.com", s.cfg.Bucket, s.cfg.Region), Path: "/" + key}
+	if s.cfg.Endpoint != "" {
+		base, err := url.Parse(s.cfg.Endpoint)
+		if err != nil {
+			return nil, fmt.Errorf("invalid endpoint: %w", err)
+		}
+		u = url.URL{Scheme: base.Scheme, Host: base.Host, Path: "/" + s.cfg.Bucket + "/" + key}
+	}
+	u.RawQuery = canonicalQuery(query)
+	var r io.Reader
+	if body != nil {
+		r = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), r)
+	if err != nil {
+		return nil, err
+	}
+	s.sign(req, body, time.Now().UTC())
+	return req, nil
+}
+
+// do sends req and returns up to max bytes of a 2xx response's body.
+func (s *S3Sink) do(req *http.Request, max int64) ([]byte, error) {
+	resp, err := s.HTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return nil, fmt.Errorf("s3 returned status %d", resp.StatusCode)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, max))
+}
+
+// sign adds an AWS Signature Version 4 Authorization header covering
+// body.
+func (s *S3Sink) sign(req *http.Request, body []byte, now time.Time) {
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	sum := sha256.Sum256(body)
+	payloadHash := hex.EncodeToString(sum[:])
+
+	req.Header.Set("x-amz-date", amzDate)
+	req.Header.Set("x-amz-content-sha256", payloadHash)
+
+	signedHeaders := "host;x-amz-content-sha256;x-amz-date"
+	canonicalHeaders := "host:" + req.URL.Host + "\n" +
+		"x-amz-content-sha256:" + payloadHash + "\n" +
+		"x-amz-date:" + amzDate + "\n"
+
+	canonicalRequest := req.Method + "\n" +
+		req.URL.EscapedPath() + "\n" +
+		req.URL.RawQuery + "\n" +
+		canonicalHeaders + "\n" +
+		signedHeaders + "\n" +
+		payloadHash
+
+	scope := date + "/" + s.cfg.Region + "/s3/aws4_request"
+	crHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(crHash[:])
+
+	key := hmacSHA256([]byte("AWS4"+s.cfg.Secret), date)
+	key = hmacSHA256(key, s.cfg.Region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	sig := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.cfg.KeyID, scope, signedHeaders, sig))
+}
+
+// canonicalQuery encodes q sorted by key, as SigV4 signs it.
+func canonicalQuery(q url.Values) string {
+	keys := make([]string, 0, len(q))
+	for k := range q {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var parts []string
+	for _, k := range keys {
+		for _, v := range q[k] {
+			parts = append(parts, awsEscape(k)+"="+awsEscape(v))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+func awsEscape(s string) string {
+	return strings.ReplaceAll(url.QueryEscape(s), "+", "%20")
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	m := hmac.New(sha256.New, key)
+	m.Write([]byte(data))
+	return m.Sum(nil)
+}
@@ -0,0 +1,151 @@
+package audit
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"slices"
+	"time"
+
+	"github.com/shivansh-source/nopass/internal/storage"
+	"github.com/shivansh-source/nopass/internal/types"
+)
+
+// Source is a sink whose records can be read back. Every sink Open
+// returns is one, as is every storage.AuditStore.
+type Source interface {
+	// Query returns matching records, newest first.
+	Query(ctx context.Context, q storage.AuditQuery) ([]storage.AuditRecord, error)
+}
+
+// Filter selects chat transactions; zero fields match everything.
+type Filter struct {
+	TenantID  string
+	RequestID string
+	UserID    string
+	SessionID string
+	RiskLevel types.RiskLevel
+	// Flag matches a risk or output flag.
+	Flag  string
+	Since time.Time
+	Until time.Time
+}
+
+func (f Filter) matches(tx *Transaction) bool {
+	if (f.RequestID != "" && tx.RequestID != f.RequestID) || (f.UserID != "" && tx.UserID != f.UserID) || (f.SessionID != "" && tx.SessionID != f.SessionID) {
+		return false
+	}
+	if f.RiskLevel != "" && (tx.Risk == nil || tx.Risk.Level != f.RiskLevel) {
+		return false
+	}
+	if f.Flag != "" {
+		var flags []string
+		if tx.Risk != nil {
+			flags = append(flags, tx.Risk.Flags...)
+		}
+		if tx.Output != nil {
+			flags = append(flags, tx.Output.Flags...)
+		}
+		if !slices.Contains(flags, f.Flag) {
+			return false
+		}
+	}
+	return true
+}
+
+// Entry is one transaction found by Search.
+type Entry struct {
+	ID          string       `json:"id"`
+	TenantID    string       `json:"tenant_id"`
+	Time        time.Time    `json:"time"`
+	Transaction *Transaction `json:"transaction"`
+}
+
+// ErrBadCursor is returned for a cursor Search did not produce.
+var ErrBadCursor = errors.New("audit: invalid cursor")
+
+// cursor marks where a page ended: records before Until, plus those at
+// Until itself that weren't returned yet.
+type cursor struct {
+	Until int64    `json:"u"` // Unix milliseconds
+	Seen  []string `json:"s,omitempty"`
+}
+
+// searchBatch is how many records Search reads from the source at once.
+const searchBatch = 500
+
+// Search returns up to limit (no limit if 0) transactions matching f, newest first,
+// starting after the page that returned after ("" for the first page).
+// next is the cursor for the following page, or "" after the last.
+// Records whose data can't be decoded are skipped.
+func Search(ctx context.Context, src Source, f Filter, limit int, after string) (entries []Entry, next string, err error) {
+	var c cursor
+	if after != "" {
+		data, err := base64.RawURLEncoding.DecodeString(after)
+		if err != nil || json.Unmarshal(data, &c) != nil || c.Until == 0 {
+			return nil, "", ErrBadCursor
+		}
+	}
+	until := f.Until
+	seen := make(map[string]bool)
+	if c.Until != 0 {
+		// Records sharing the cursor's millisecond are read again and
+		// the ones already returned skipped.
+		if t := time.UnixMilli(c.Until + 1); until.IsZero() || t.Before(until) {
+			until = t
+		}
+		for _, id := range c.Seen {
+			seen[id] = true
+		}
+	}
+
+	for {
+		records, err := src.Query(ctx, storage.AuditQuery{
+			TenantID: f.TenantID,
+			Kind:     Kind,
+			Since:    f.Since,
+			Until:    until,
+			Limit:    searchBatch,
+		})
+		if err != nil {
+			return nil, "", err
+		}
+		for _, r := range records {
+			if seen[r.ID] {
+				continue
+			}
+			seen[r.ID] = true
+			var tx Transaction
+			if json.Unmarshal(r.Data, &tx) != nil || !f.matches(&tx) {
+				continue
+			}
+			if limit > 0 && len(entries) == limit {
+				return entries, pageCursor(entries), nil
+			}
+			entries = append(entries, Entry{ID: r.ID, TenantID: r.TenantID, Time: r.Time, Transaction: &tx})
+		}
+		if len(records) < searchBatch {
+			return entries, "", nil
+		}
+		// The next batch starts at the oldest record's millisecond, unless
+		// the whole batch was in it: then move past it rather than read it
+		// forever.
+		oldest := records[len(records)-1].Time.Truncate(time.Millisecond)
+		until = oldest.Add(time.Millisecond)
+		if records[0].Time.Truncate(time.Millisecond).Equal(oldest) {
+			until = oldest
+		}
+	}
+}
+
+// pageCursor returns the cursor continuing after the last of entries.
+func pageCursor(entries []Entry) string {
+	last := entries[len(entries)-1].Time.UnixMilli()
+	c := cursor{Until: last}
+	for i := len(entries) - 1; i >= 0 && entries[i].Time.UnixMilli() == last; i-- {
+		c.Seen = append(c.Seen, entries[i].ID)
+	}
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
@@ -0,0 +1,280 @@
+// Package auth authenticates API clients by key. Keys are issued per
+// tenant and stored only as SHA-256 hashes, with per-key metadata: a rate
+// limit, the models the key may select and a policy profile. The
+// middleware resolves the key, enforces its rate limit and pins the
+// request to the key's tenant. OIDC verifies bearer JWTs instead, for
+// deployments with an identity provider.
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/shivansh-source/nopass/internal/metrics"
+	"github.com/shivansh-source/nopass/internal/scheduler"
+	"github.com/shivansh-source/nopass/internal/storage"
+)
+
+// ErrUnknownKey is returned by Store.Lookup for a key that was never
+// issued or has been revoked.
+var ErrUnknownKey = errors.New("unknown API key")
+
+// Key is the metadata of one issued API key.
+type Key struct {
+	ID       string `json:"id"`
+	Hash     string `json:"hash"` // hex SHA-256 of the key
+	TenantID string `json:"tenant_id"`
+	Name     string `json:"name,omitempty"`
+	// RateLimit is the requests per minute the key may make (0 = no
+	// limit).
+	RateLimit int `json:"rate_limit,omitempty"`
+	// Models lists the providers or models the key may select through
+	// generation parameters; empty allows any.
+	Models []string `json:"models,omitempty"`
+	// PolicyProfile names the policy profile applied to the key's
+	// requests.
+	PolicyProfile string `json:"policy_profile,omitempty"`
+	// MaskSpans lets the key's requests ask where their messages were
+	// masked, for trusted internal callers whose UIs show it.
+	MaskSpans bool `json:"mask_spans,omitempty"`
+	// Deadlines lets the key's requests set their own deadline
+	// (X-Request-Deadline), for callers that budget their time: an
+	// interactive UI asking for 10s, a batch job for 2m.
+	Deadlines bool `json:"deadlines,omitempty"`
+	// Priority is the sandbox scheduling class of the key's requests:
+	// "interactive", "batch" or "eval" (empty = batch).
+	Priority string `json:"priority,omitempty"`
+	// Priorities lets the key's requests pick their own class
+	// (X-NoPass-Priority or the body's priority), for callers that mix
+	// interactive and background work; Priority is their default.
+	Priorities bool       `json:"priorities,omitempty"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// AllowsModel reports whether the key may select provider and model; the
+// deployment default ("" or the "sandbox" provider) is always allowed.
+func (k *Key) AllowsModel(provider, model string) bool {
+	if len(k.Models) == 0 {
+		return true
+	}
+	return (provider == "" || provider == "sandbox" || slices.Contains(k.Models, provider)) &&
+		(model == "" || slices.Contains(k.Models, model))
+}
+
+// Store looks keys up by hash.
+type Store interface {
+	Lookup(ctx context.Context, hash string) (*Key, error)
+}
+
+// Hash returns the hex SHA-256 a key is stored under.
+func Hash(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// NewKey generates a key for tenantID and returns it with its metadata.
+// The key is shown once; only its hash is kept.
+func NewKey(tenantID string) (string, Key) {
+	var b [24]byte
+	var id [4]byte
+	_, _ = rand.Read(b[:])
+	_, _ = rand.Read(id[:])
+	secret := "np_" + hex.EncodeToString(b[:])
+	return secret, Key{
+		ID:        "key_" + hex.EncodeToString(id[:]),
+		Hash:      Hash(secret),
+		TenantID:  tenantID,
+		CreatedAt: time.Now().UTC(),
+	}
+}
+
+// FileStore is a static set of keys loaded from a JSON file:
+// {"keys": [Key, ...]}.
+type FileStore struct {
+	keys map[string]*Key
+}
+
+// LoadFile reads a keys file.
+func LoadFile(path string) (*FileStore, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read API keys file: %w", err)
+	}
+	var file struct {
+		Keys []Key `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("parse API keys file: %w", err)
+	}
+	s := &FileStore{keys: make(map[string]*Key)}
+	for i := range file.Keys {
+		k := &file.Keys[i]
+		if k.Hash == "" || k.TenantID == "" {
+			return nil, fmt.Errorf("API key %q: hash and tenant_id are required", k.ID)
+		}
+		if _, err := scheduler.ParsePriority(k.Priority); err != nil {
+			return nil, fmt.Errorf("API key %q: %w", k.ID, err)
+		}
+		s.keys[strings.ToLower(k.Hash)] = k
+	}
+	return s, nil
+}
+
+// Lookup implements Store.
+func (s *FileStore) Lookup(_ context.Context, hash string) (*Key, error) {
+	if k, ok := s.keys[hash]; ok {
+		return k, nil
+	}
+	return nil, ErrUnknownKey
+}
+
+// RecordStore keeps keys in the storage backend's records (SQL, Redis or
+// in-memory), so they can be issued and revoked without a restart.
+type RecordStore struct {
+	Records storage.RecordStore
+}
+
+// keysCollection is the record collection keys live in, by hash.
+const keysCollection = "api_keys"
+
+// Lookup implements Store.
+func (s RecordStore) Lookup(ctx context.Context, hash string) (*Key, error) {
+	data, err := s.Records.GetRecord(ctx, keysCollection, hash)
+	if errors.Is(err, storage.ErrNotFound) {
+		return nil, ErrUnknownKey
+	}
+	if err != nil {
+		return nil, err
+	}
+	var k Key
+	if err := json.Unmarshal(data, &k); err != nil {
+		return nil, fmt.Errorf("decode API key: %w", err)
+	}
+	return &k, nil
+}
+
+// Put stores (or replaces) a key.
+func (s RecordStore) Put(ctx context.Context, k Key) error {
+	data, err := json.Marshal(k)
+	if err != nil {
+		return err
+	}
+	return s.Records.PutRecord(ctx, keysCollection, k.Hash, data)
+}
+
+// List returns every stored key.
+func (s RecordStore) List(ctx context.Context) ([]Key, error) {
+	raw, err := s.Records.ListRecords(ctx, keysCollection)
+	if err != nil {
+		return nil, err
+	}
+	keys := make([]Key, 0, len(raw))
+	for _, data := range raw {
+		var k Key
+		if err := json.Unmarshal(data, &k); err != nil {
+			return nil, fmt.Errorf("decode API key: %w", err)
+		}
+		keys = append(keys, k)
+	}
+	return keys, nil
+}
+
+// Revoke deletes the key with the given ID.
+func (s RecordStore) Revoke(ctx context.Context, id string) error {
+	keys, err := s.List(ctx)
+	if err != nil {
+		return err
+	}
+	for _, k := range keys {
+		if k.ID == id {
+			return s.Records.DeleteRecord(ctx, keysCollection, k.Hash)
+		}
+	}
+	return ErrUnknownKey
+}
+
+type ctxKey struct{}
+
+// WithKey returns ctx carrying the authenticated key.
+func WithKey(ctx context.Context, k *Key) context.Context {
+	return context.WithValue(ctx, ctxKey{}, k)
+}
+
+// KeyFrom returns the authenticated key of a request, or nil.
+func KeyFrom(ctx context.Context) *Key {
+	k, _ := ctx.Value(ctxKey{}).(*Key)
+	return k
+}
+
+// Authenticator is the middleware that requires a valid key.
+type Authenticator struct {
+	Keys Store
+	// Quotas, if set, enforces Key.RateLimit.
+	Quotas storage.QuotaStore
+}
+
+var authResults = metrics.NewCounterVec(
+	"nopass_auth_requests_total",
+	"API requests by authentication result.",
+	"result",
+)
+
+// Wrap requires every request to next to carry a valid key, in X-API-Key
+// or as a bearer token. The key's tenant replaces any X-NoPass-Tenant the
+// client sent, so a key can never act for another tenant.
+func (a *Authenticator) Wrap(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		secret := r.Header.Get("X-API-Key")
+		if secret == "" {
+			secret, _ = strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		}
+		if secret == "" {
+			authResults.Inc("missing")
+			w.Header().Set("WWW-Authenticate", `Bearer realm="nopass"`)
+			http.Error(w, "API key required", http.StatusUnauthorized)
+			return
+		}
+		key, err := a.Keys.Lookup(r.Context(), Hash(secret))
+		if errors.Is(err, ErrUnknownKey) || (err == nil && key.ExpiresAt != nil && time.Now().After(*key.ExpiresAt)) {
+			authResults.Inc("invalid")
+			http.Error(w, "invalid API key", http.StatusUnauthorized)
+			return
+		}
+		if err != nil {
+			slog.ErrorContext(r.Context(), "API key lookup error", "err", err)
+			authResults.Inc("error")
+			http.Error(w, "internal error (auth)", http.StatusInternalServerError)
+			return
+		}
+
+		if key.RateLimit > 0 && a.Quotas != nil {
+			n, err := a.Quotas.Incr(r.Context(), key.TenantID, "apikey:"+key.ID, 1, time.Minute)
+			if err != nil {
+				// An unavailable counter store doesn't lock every client out.
+				slog.WarnContext(r.Context(), "API key rate limit error", "key", key.ID, "err", err)
+			} else if n > int64(key.RateLimit) {
+				authResults.Inc("rate_limited")
+				w.Header().Set("Retry-After", strconv.Itoa(60-time.Now().Second()))
+				http.Error(w, "rate limit exceeded", http.StatusTooManyRequests)
+				return
+			}
+		}
+
+		authResults.Inc("ok")
+		r.Header.Set("X-NoPass-Tenant", key.TenantID)
+		next.ServeHTTP(w, r.WithContext(WithKey(r.Context(), key)))
+	})
+}
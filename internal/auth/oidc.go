@@ -0,0 +1,407 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// OIDC authenticates requests by bearer JWT, as an alternative to API
+// keys. Tokens are verified against the issuer's JWKS, which is fetched
+// (via discovery unless JWKSURL is set), cached and refetched when a token
+// names an unknown key.
+type OIDC struct {
+	Issuer   string
+	Audience string
+	// JWKSURL, if set, skips discovery.
+	JWKSURL string
+	// UserClaim is mapped to the request's user ID ("" = "sub").
+	UserClaim string
+	// TenantClaim names the claim that pins the request to a tenant. It is
+	// required: without it a token holder could pick any tenant.
+	TenantClaim string
+	// CacheTTL is how long fetched keys are used (0 = one hour).
+	CacheTTL   time.Duration
+	HTTPClient *http.Client
+
+	mu       sync.Mutex
+	keys     map[string]crypto.PublicKey
+	fetched  time.Time
+	fetching *jwksFetch // the JWKS fetch in progress, if any
+}
+
+// jwksFetch is a JWKS fetch that requests for a missing key wait on.
+type jwksFetch struct {
+	done chan struct{}
+	err  error // set before done is closed
+}
+
+// Middleware is what the gateway wraps its /v1 endpoints with: an
+// Authenticator or an OIDC verifier.
+type Middleware interface {
+	Wrap(next http.Handler) http.Handler
+}
+
+// Identity is who a verified token was issued to.
+type Identity struct {
+	UserID   string
+	TenantID string
+	Claims   map[string]any
+}
+
+type identityKey struct{}
+
+// WithIdentity returns ctx carrying a verified identity.
+func WithIdentity(ctx context.Context, id *Identity) context.Context {
+	return context.WithValue(ctx, identityKey{}, id)
+}
+
+// IdentityFrom returns the verified identity of a request, or nil.
+func IdentityFrom(ctx context.Context) *Identity {
+	id, _ := ctx.Value(identityKey{}).(*Identity)
+	return id
+}
+
+// clockSkew is how far exp and nbf may be off.
+const clockSkew = time.Minute
+
+// minRefetch limits JWKS refetches for unknown key IDs, so tokens with
+// made-up kids can't hammer the issuer.
+const minRefetch = time.Minute
+
+// fetchTimeout bounds a JWKS fetch, whatever HTTPClient's timeout.
+const fetchTimeout = 10 * time.Second
+
+// Wrap requires every request to next to carry a valid bearer token. The
+// token's tenant claim replaces any X-NoPass-Tenant the client sent, so a
+// token can never act for another tenant.
+func (o *OIDC) Wrap(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || token == "" {
+			authResults.Inc("missing")
+			w.Header().Set("WWW-Authenticate", `Bearer realm="nopass"`)
+			http.Error(w, "bearer token required", http.StatusUnauthorized)
+			return
+		}
+		id, err := o.Verify(r.Context(), token)
+		if err != nil {
+			authResults.Inc("invalid")
+			w.Header().Set("WWW-Authenticate", `Bearer realm="nopass", error="invalid_token"`)
+			http.Error(w, "invalid token: "+err.Error(), http.StatusUnauthorized)
+			return
+		}
+		authResults.Inc("ok")
+		r.Header.Set("X-NoPass-Tenant", id.TenantID)
+		next.ServeHTTP(w, r.WithContext(WithIdentity(r.Context(), id)))
+	})
+}
+
+// Verify checks a token's signature, issuer, audience and validity window
+// and maps its claims to an Identity.
+func (o *OIDC) Verify(ctx context.Context, token string) (*Identity, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("malformed token")
+	}
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, fmt.Errorf("header: %w", err)
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, errors.New("malformed signature")
+	}
+	key, err := o.key(ctx, header.Kid)
+	if err != nil {
+		return nil, err
+	}
+	if err := verifySignature(header.Alg, key, parts[0]+"."+parts[1], sig); err != nil {
+		return nil, err
+	}
+
+	var claims map[string]any
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, fmt.Errorf("claims: %w", err)
+	}
+	now := time.Now()
+	if iss, _ := claims["iss"].(string); iss != o.Issuer {
+		return nil, fmt.Errorf("issuer %q not accepted", iss)
+	}
+	if o.Audience != "" && !hasAudience(claims["aud"], o.Audience) {
+		return nil, errors.New("audience not accepted")
+	}
+	exp, ok := claims["exp"].(float64)
+	if !ok || now.After(time.Unix(int64(exp), 0).Add(clockSkew)) {
+		return nil, errors.New("token expired")
+	}
+	if nbf, ok := claims["nbf"].(float64); ok && now.Add(clockSkew).Before(time.Unix(int64(nbf), 0)) {
+		return nil, errors.New("token not yet valid")
+	}
+
+	userClaim := o.UserClaim
+	if userClaim == "" {
+		userClaim = "sub"
+	}
+	id := &Identity{Claims: claims}
+	if id.UserID, _ = claims[userClaim].(string); id.UserID == "" {
+		return nil, fmt.Errorf("token has no %s claim", userClaim)
+	}
+	if o.TenantClaim == "" {
+		return nil, errors.New("no tenant claim configured")
+	}
+	if id.TenantID, _ = claims[o.TenantClaim].(string); id.TenantID == "" {
+		return nil, fmt.Errorf("token has no %s claim", o.TenantClaim)
+	}
+	return id, nil
+}
+
+func decodeSegment(seg string, v any) error {
+	data, err := base64.RawURLEncoding.DecodeString(seg)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+func hasAudience(aud any, want string) bool {
+	switch a := aud.(type) {
+	case string:
+		return a == want
+	case []any:
+		return slices.Contains(a, any(want))
+	}
+	return false
+}
+
+func verifySignature(alg string, key crypto.PublicKey, signed string, sig []byte) error {
+	if len(alg) != 5 {
+		return fmt.Errorf("unsupported algorithm %q", alg)
+	}
+	var hash crypto.Hash
+	switch alg[2:] {
+	case "256":
+		hash = crypto.SHA256
+	case "384":
+		hash = crypto.SHA384
+	case "512":
+		hash = crypto.SHA512
+	}
+	if hash == 0 {
+		return fmt.Errorf("unsupported algorithm %q", alg)
+	}
+	h := hash.New()
+	h.Write([]byte(signed))
+	digest := h.Sum(nil)
+
+	switch k := key.(type) {
+	case *rsa.PublicKey:
+		var err error
+		switch alg[:2] {
+		case "RS":
+			err = rsa.VerifyPKCS1v15(k, hash, digest, sig)
+		case "PS":
+			err = rsa.VerifyPSS(k, hash, digest, sig, nil)
+		default:
+			return fmt.Errorf("algorithm %s does not match the RSA key", alg)
+		}
+		if err != nil {
+			return errors.New("bad signature")
+		}
+	case *ecdsa.PublicKey:
+		size := (k.Curve.Params().BitSize + 7) / 8
+		if alg[:2] != "ES" || len(sig) != 2*size {
+			return fmt.Errorf("algorithm %s does not match the EC key", alg)
+		}
+		r := new(big.Int).SetBytes(sig[:size])
+		s := new(big.Int).SetBytes(sig[size:])
+		if !ecdsa.Verify(k, digest, r, s) {
+			return errors.New("bad signature")
+		}
+	default:
+		return errors.New("unsupported key type")
+	}
+	return nil
+}
+
+// key returns the signing key kid, fetching the JWKS when the cache is
+// stale or doesn't have it. The fetch runs outside o.mu, once however many
+// requests need it: a cached key, even a stale one, is used meanwhile, and
+// only requests for a key the cache lacks wait for it.
+func (o *OIDC) key(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	ttl := o.CacheTTL
+	if ttl <= 0 {
+		ttl = time.Hour
+	}
+	o.mu.Lock()
+	k, ok := o.lookup(kid)
+	stale := time.Since(o.fetched) > ttl
+	if ok && !stale {
+		o.mu.Unlock()
+		return k, nil
+	}
+	if !stale && time.Since(o.fetched) <= minRefetch {
+		o.mu.Unlock()
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+	f := o.fetching
+	if f == nil {
+		f = &jwksFetch{done: make(chan struct{})}
+		o.fetching = f
+		go o.refresh(context.WithoutCancel(ctx), f)
+	}
+	o.mu.Unlock()
+	if ok {
+		return k, nil // keep using the stale key until the new set is in
+	}
+
+	select {
+	case <-f.done:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	if f.err != nil {
+		return nil, errors.New("signing keys unavailable")
+	}
+	o.mu.Lock()
+	k, ok = o.lookup(kid)
+	o.mu.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+	return k, nil
+}
+
+// refresh runs fetch f and installs the key set it gets. On error the
+// cached keys stay in use.
+func (o *OIDC) refresh(ctx context.Context, f *jwksFetch) {
+	ctx, cancel := context.WithTimeout(ctx, fetchTimeout)
+	defer cancel()
+	keys, err := o.fetchKeys(ctx)
+	if err != nil {
+		slog.WarnContext(ctx, "fetch JWKS error", "issuer", o.Issuer, "err", err)
+	}
+	o.mu.Lock()
+	if err == nil {
+		o.keys, o.fetched = keys, time.Now()
+	}
+	f.err = err
+	o.fetching = nil
+	o.mu.Unlock()
+	close(f.done)
+}
+
+// lookup finds kid in the cache; a token without kid may use the only key.
+func (o *OIDC) lookup(kid string) (crypto.PublicKey, bool) {
+	if kid == "" && len(o.keys) == 1 {
+		for _, k := range o.keys {
+			return k, true
+		}
+	}
+	k, ok := o.keys[kid]
+	return k, ok
+}
+
+func (o *OIDC) fetchKeys(ctx context.Context) (map[string]crypto.PublicKey, error) {
+	jwksURL := o.JWKSURL
+	if jwksURL == "" {
+		var disc struct {
+			JWKSURI string `json:"jwks_uri"`
+		}
+		if err := o.getJSON(ctx, strings.TrimSuffix(o.Issuer, "/")+"/.well-known/openid-configuration", &disc); err != nil {
+			return nil, fmt.Errorf("discovery: %w", err)
+		}
+		if disc.JWKSURI == "" {
+			return nil, errors.New("discovery document has no jwks_uri")
+		}
+		jwksURL = disc.JWKSURI
+	}
+	var set struct {
+		Keys []struct {
+			Kty string `json:"kty"`
+			Kid string `json:"kid"`
+			Use string `json:"use"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+			Crv string `json:"crv"`
+			X   string `json:"x"`
+			Y   string `json:"y"`
+		} `json:"keys"`
+	}
+	if err := o.getJSON(ctx, jwksURL, &set); err != nil {
+		return nil, err
+	}
+	keys := make(map[string]crypto.PublicKey)
+	for _, jwk := range set.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		switch jwk.Kty {
+		case "RSA":
+			n, err1 := base64.RawURLEncoding.DecodeString(jwk.N)
+			e, err2 := base64.RawURLEncoding.DecodeString(jwk.E)
+			if err1 != nil || err2 != nil {
+				continue
+			}
+			keys[jwk.Kid] = &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+		case "EC":
+			var curve elliptic.Curve
+			switch jwk.Crv {
+			case "P-256":
+				curve = elliptic.P256()
+			case "P-384":
+				curve = elliptic.P384()
+			case "P-521":
+				curve = elliptic.P521()
+			default:
+				continue
+			}
+			x, err1 := base64.RawURLEncoding.DecodeString(jwk.X)
+			y, err2 := base64.RawURLEncoding.DecodeString(jwk.Y)
+			if err1 != nil || err2 != nil {
+				continue
+			}
+			keys[jwk.Kid] = &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		}
+	}
+	if len(keys) == 0 {
+		return nil, errors.New("JWKS has no usable signing keys")
+	}
+	return keys, nil
+}
+
+func (o *OIDC) getJSON(ctx context.Context, url string, v any) error {
+	client := o.HTTPClient
+	if client == nil {
+		client = &http.Client{Timeout: 5 * time.Second}
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: status %d", url, resp.StatusCode)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
@@ -0,0 +1,279 @@
+// Package breaker guards calls to a downstream service with retries and a
+// circuit breaker. Transient failures are retried with jittered backoff;
+// when too many recent calls fail the breaker opens and calls fail fast
+// until a cooldown passes, after which a few probe calls decide whether
+// it closes again.
+package breaker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"sync"
+	"time"
+
+	"github.com/shivansh-source/nopass/internal/metrics"
+)
+
+// State is a breaker's position.
+type State int
+
+const (
+	// Closed lets every call through.
+	Closed State = iota
+	// HalfOpen lets Probes calls through to test the service.
+	HalfOpen
+	// Open fails every call until Cooldown has passed.
+	Open
+)
+
+func (s State) String() string {
+	switch s {
+	case HalfOpen:
+		return "half_open"
+	case Open:
+		return "open"
+	}
+	return "closed"
+}
+
+// ErrOpen is returned, wrapped with the breaker's name, for calls refused
+// because the breaker is open.
+var ErrOpen = errors.New("circuit breaker open")
+
+var (
+	breakerState = metrics.NewGaugeVec(
+		"nopass_breaker_state",
+		"Circuit breaker state by downstream service: 0 closed, 1 half-open, 2 open.",
+		"service",
+	)
+	breakerTransitions = metrics.NewCounterVec(
+		"nopass_breaker_transitions_total",
+		"Circuit breaker state changes by downstream service and new state.",
+		"service", "state",
+	)
+	retries = metrics.NewCounterVec(
+		"nopass_downstream_retries_total",
+		"Downstream calls retried after a transient failure, by service.",
+		"service",
+	)
+)
+
+// Breaker is one downstream service's circuit breaker and retry policy.
+// A nil *Breaker runs calls once, unguarded.
+type Breaker struct {
+	// Name labels the breaker's metrics and errors, e.g. "risk".
+	Name string
+	// Retries is how many times a transiently failed call is retried;
+	// Backoff is the first wait, doubled (with jitter) for each retry up
+	// to MaxBackoff. A retry is skipped when the wait would outlast the
+	// call's context.
+	Retries    int
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+	// FailureRate opens the breaker when at least this share of the last
+	// Window calls failed, once MinCalls calls are in the window.
+	FailureRate float64
+	Window      int
+	MinCalls    int
+	// Cooldown is how long the breaker stays open before probing.
+	Cooldown time.Duration
+	// Probes is how many calls a half-open breaker lets through at once;
+	// one success closes it, one failure opens it again.
+	Probes int
+
+	mu       sync.Mutex
+	shown    bool // state published to the metric
+	state    State
+	outcomes []bool // ring of recent calls, true for a failure
+	next     int
+	failures int
+	openedAt time.Time
+	probing  int
+}
+
+// New returns a breaker for service name with the default settings.
+func New(name string) *Breaker {
+	return &Breaker{
+		Name:        name,
+		Retries:     2,
+		Backoff:     50 * time.Millisecond,
+		MaxBackoff:  time.Second,
+		FailureRate: 0.5,
+		Window:      20,
+		MinCalls:    10,
+		Cooldown:    30 * time.Second,
+		Probes:      1,
+	}
+}
+
+// permanent marks an error that retrying won't fix.
+type permanent struct{ err error }
+
+func (p permanent) Error() string { return p.err.Error() }
+func (p permanent) Unwrap() error { return p.err }
+
+// Permanent marks err as not worth retrying, e.g. a 4xx response or a
+// malformed body. The service did answer, so it doesn't count against
+// the breaker either. Do returns the unmarked error.
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return permanent{err}
+}
+
+// Do runs fn, retrying transient failures, unless the breaker is open.
+// Failures caused by ctx ending are returned as they are and not held
+// against the service.
+func (b *Breaker) Do(ctx context.Context, fn func(context.Context) error) error {
+	if b == nil {
+		err := fn(ctx)
+		if p, ok := err.(permanent); ok {
+			return p.err
+		}
+		return err
+	}
+	wait := b.Backoff
+	var err error
+	for attempt := 0; ; attempt++ {
+		probe, open := b.allow()
+		if open != nil {
+			if err != nil {
+				// Opened by the failures being retried; report those.
+				return err
+			}
+			return open
+		}
+		err = fn(ctx)
+		if p, ok := err.(permanent); ok {
+			b.record(probe, false)
+			return p.err
+		}
+		if err != nil && ctx.Err() != nil {
+			b.release(probe)
+			return err
+		}
+		b.record(probe, err != nil)
+		if err == nil || attempt >= b.Retries {
+			return err
+		}
+
+		// Full jitter: anywhere up to the backoff, so callers that failed
+		// together don't retry together.
+		d := time.Duration(rand.Int64N(int64(wait) + 1))
+		if dl, ok := ctx.Deadline(); ok && time.Until(dl) <= d {
+			return err
+		}
+		select {
+		case <-time.After(d):
+		case <-ctx.Done():
+			return err
+		}
+		retries.Inc(b.Name)
+		if wait *= 2; b.MaxBackoff > 0 && wait > b.MaxBackoff {
+			wait = b.MaxBackoff
+		}
+	}
+}
+
+// State returns the breaker's current state.
+func (b *Breaker) State() State {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state == Open && time.Since(b.openedAt) >= b.Cooldown {
+		return HalfOpen
+	}
+	return b.state
+}
+
+// allow admits a call, reporting whether it is a half-open probe.
+func (b *Breaker) allow() (bool, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !b.shown {
+		b.shown = true
+		breakerState.Set(float64(b.state), b.Name)
+	}
+	if b.state == Open && time.Since(b.openedAt) >= b.Cooldown {
+		b.setState(HalfOpen)
+	}
+	switch b.state {
+	case Open:
+		return false, fmt.Errorf("%s: %w", b.Name, ErrOpen)
+	case HalfOpen:
+		if b.probing >= max(b.Probes, 1) {
+			return false, fmt.Errorf("%s: %w", b.Name, ErrOpen)
+		}
+		b.probing++
+		return true, nil
+	}
+	return false, nil
+}
+
+// release returns a probe slot without an outcome.
+func (b *Breaker) release(probe bool) {
+	if !probe {
+		return
+	}
+	b.mu.Lock()
+	b.probing--
+	b.mu.Unlock()
+}
+
+func (b *Breaker) record(probe, failed bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if probe {
+		b.probing--
+		if b.state != HalfOpen {
+			return
+		}
+		if failed {
+			b.trip()
+		} else {
+			b.reset()
+			b.setState(Closed)
+		}
+		return
+	}
+	if b.state != Closed {
+		// A call admitted before the breaker opened.
+		return
+	}
+	if len(b.outcomes) < max(b.Window, 1) {
+		b.outcomes = append(b.outcomes, failed)
+	} else {
+		if b.outcomes[b.next] {
+			b.failures--
+		}
+		b.outcomes[b.next] = failed
+		b.next = (b.next + 1) % len(b.outcomes)
+	}
+	if failed {
+		b.failures++
+	}
+	if n := len(b.outcomes); n >= b.MinCalls && float64(b.failures) >= b.FailureRate*float64(n) && b.failures > 0 {
+		b.trip()
+	}
+}
+
+func (b *Breaker) trip() {
+	b.reset()
+	b.openedAt = time.Now()
+	b.setState(Open)
+}
+
+func (b *Breaker) reset() {
+	b.outcomes, b.next, b.failures = b.outcomes[:0], 0, 0
+}
+
+func (b *Breaker) setState(s State) {
+	if b.state == s {
+		return
+	}
+	b.state = s
+	breakerState.Set(float64(s), b.Name)
+	breakerTransitions.Inc(b.Name, s.String())
+}
@@ -0,0 +1,230 @@
+package canary
+
+import (
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/shivansh-source/nopass/internal/metrics"
+)
+
+// Comparer runs on the canary: it replays mirrored captures through the
+// canary's own chat handler and keeps the differences for operators.
+type Comparer struct {
+	// Chat is the canary's native /v1/chat handler.
+	Chat http.Handler
+	// Secret authenticates the stable gateways' mirror calls.
+	Secret string
+	// Timeout bounds one replay (0 = 1 minute).
+	Timeout time.Duration
+	// Keep is how many recent mismatches the report lists (0 = 100).
+	Keep int
+
+	mu           sync.Mutex
+	compared     int
+	mismatched   int
+	latencyDelta int64 // sum of canary minus stable latency, ms
+	byField      map[string]int
+	recent       []Diff
+}
+
+// Change is one field that differs between the builds.
+type Change struct {
+	Field  string `json:"field"`
+	Stable string `json:"stable"`
+	Canary string `json:"canary"`
+}
+
+// Diff is the comparison of one mirrored request.
+type Diff struct {
+	ID             string    `json:"id"`
+	Time           time.Time `json:"time"`
+	Changes        []Change  `json:"changes,omitempty"`
+	LatencyDeltaMs int64     `json:"latency_delta_ms"` // canary minus stable
+	Stable         Result    `json:"stable"`
+	Canary         Result    `json:"canary"`
+}
+
+// Report summarizes every comparison since startup.
+type Report struct {
+	Compared           int            `json:"compared"`
+	Mismatched         int            `json:"mismatched"`
+	MeanLatencyDeltaMs float64        `json:"mean_latency_delta_ms"`
+	MismatchesByField  map[string]int `json:"mismatches_by_field,omitempty"`
+	Recent             []Diff         `json:"recent_mismatches,omitempty"` // newest first
+}
+
+var comparisons = metrics.NewCounterVec(
+	"nopass_canary_comparisons_total",
+	"Mirrored requests replayed on the canary by result.",
+	"result",
+)
+
+// Compare replays c through Chat and diffs the outcome against the stable
+// build's.
+func (cm *Comparer) Compare(ctx context.Context, c Capture) (Diff, error) {
+	timeout := cm.Timeout
+	if timeout <= 0 {
+		timeout = time.Minute
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	tape := NewReplay(c.Exchanges)
+	var got *Result
+	tape.finish = func(_ *Tape, res Result) { got = &res }
+
+	req, err := http.NewRequestWithContext(WithTape(ctx, tape), http.MethodPost, "/v1/chat", bytes.NewReader(c.Request))
+	if err != nil {
+		return Diff{}, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	cm.Chat.ServeHTTP(&discardWriter{header: make(http.Header)}, req)
+	if got == nil {
+		return Diff{}, errors.New("canary: chat handler finished without a result")
+	}
+
+	d := Diff{
+		ID:             c.ID,
+		Time:           time.Now().UTC(),
+		Changes:        changes(c.Stable, *got),
+		LatencyDeltaMs: got.LatencyMs - c.Stable.LatencyMs,
+		Stable:         c.Stable,
+		Canary:         *got,
+	}
+	if n := tape.Misses(); n > 0 {
+		d.Changes = append(d.Changes, Change{Field: "downstream_calls", Stable: "recorded", Canary: strconv.Itoa(n) + " unrecorded"})
+	}
+	cm.record(d)
+	return d, nil
+}
+
+func changes(a, b Result) []Change {
+	var out []Change
+	add := func(field, x, y string) {
+		if x != y {
+			out = append(out, Change{Field: field, Stable: x, Canary: y})
+		}
+	}
+	add("disposition", a.Disposition, b.Disposition)
+	add("risk_level", string(a.RiskLevel), string(b.RiskLevel))
+	add("path", string(a.Path), string(b.Path))
+	add("withheld", strconv.FormatBool(a.Withheld), strconv.FormatBool(b.Withheld))
+	add("flags", flagSet(a.Flags), flagSet(b.Flags))
+	add("answer", a.AnswerHash, b.AnswerHash)
+	return out
+}
+
+func flagSet(flags []string) string {
+	s := slices.Clone(flags)
+	slices.Sort(s)
+	return strings.Join(slices.Compact(s), ",")
+}
+
+func (cm *Comparer) record(d Diff) {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+	cm.compared++
+	cm.latencyDelta += d.LatencyDeltaMs
+	if len(d.Changes) == 0 {
+		comparisons.Inc("match")
+		return
+	}
+	comparisons.Inc("mismatch")
+	cm.mismatched++
+	if cm.byField == nil {
+		cm.byField = make(map[string]int)
+	}
+	for _, ch := range d.Changes {
+		cm.byField[ch.Field]++
+	}
+	keep := cm.Keep
+	if keep <= 0 {
+		keep = 100
+	}
+	cm.recent = append(cm.recent, d)
+	if len(cm.recent) > keep {
+		cm.recent = cm.recent[len(cm.recent)-keep:]
+	}
+	log.Printf("canary mismatch %s: %s", d.ID, describe(d.Changes))
+}
+
+func describe(changes []Change) string {
+	parts := make([]string, len(changes))
+	for i, ch := range changes {
+		parts[i] = fmt.Sprintf("%s %s -> %s", ch.Field, ch.Stable, ch.Canary)
+	}
+	return strings.Join(parts, "; ")
+}
+
+// Report returns the comparison summary.
+func (cm *Comparer) Report() Report {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+	r := Report{
+		Compared:          cm.compared,
+		Mismatched:        cm.mismatched,
+		MismatchesByField: make(map[string]int, len(cm.byField)),
+	}
+	if cm.compared > 0 {
+		r.MeanLatencyDeltaMs = float64(cm.latencyDelta) / float64(cm.compared)
+	}
+	for k, v := range cm.byField {
+		r.MismatchesByField[k] = v
+	}
+	for i := len(cm.recent) - 1; i >= 0; i-- {
+		r.Recent = append(r.Recent, cm.recent[i])
+	}
+	return r
+}
+
+// Handler serves POST /internal/canary/compare, which the stable gateways'
+// Mirror calls, and answers with the Diff.
+func (cm *Comparer) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /internal/canary/compare", cm.compareHandler)
+	return mux
+}
+
+func (cm *Comparer) compareHandler(w http.ResponseWriter, r *http.Request) {
+	got := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if cm.Secret == "" || subtle.ConstantTimeCompare([]byte(got), []byte(cm.Secret)) != 1 {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	var c Capture
+	if err := json.NewDecoder(io.LimitReader(r.Body, 16<<20)).Decode(&c); err != nil {
+		http.Error(w, "invalid capture", http.StatusBadRequest)
+		return
+	}
+	d, err := cm.Compare(r.Context(), c)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(d); err != nil {
+		log.Printf("canary: encode diff error: %v", err)
+	}
+}
+
+// discardWriter swallows the canary's response; only the Result reported
+// through the tape is compared.
+type discardWriter struct {
+	header http.Header
+}
+
+func (d *discardWriter) Header() http.Header         { return d.header }
+func (d *discardWriter) Write(b []byte) (int, error) { return len(b), nil }
+func (d *discardWriter) WriteHeader(int)             {}
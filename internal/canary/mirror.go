@@ -0,0 +1,123 @@
+package canary
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	mrand "math/rand/v2"
+	"net/http"
+	"time"
+
+	"github.com/shivansh-source/nopass/internal/metrics"
+	"github.com/shivansh-source/nopass/internal/types"
+)
+
+// Capture is one mirrored request as sent to the canary.
+type Capture struct {
+	ID        string          `json:"id"`
+	Request   json.RawMessage `json:"request"` // the ChatRequest as the stable build decoded it
+	Exchanges []Exchange      `json:"exchanges"`
+	Stable    Result          `json:"stable"`
+}
+
+// Mirror runs on the stable gateway and sends a sample of its requests to
+// a canary. Captures are sent in the background after the client has its
+// response; when the canary falls behind they are dropped, never queued
+// against client traffic.
+type Mirror struct {
+	URL        string  // canary base URL
+	Secret     string  // presented as "Authorization: Bearer <secret>"
+	Sample     float64 // fraction of requests mirrored, 0..1
+	HTTPClient *http.Client
+
+	queue chan Capture
+}
+
+// mirrored counts captures by what happened to them: sent, dropped, failed.
+var mirrored = metrics.NewCounterVec(
+	"nopass_canary_mirrored_total",
+	"Requests mirrored to the canary by outcome.",
+	"outcome",
+)
+
+func NewMirror(url, secret string, sample float64) *Mirror {
+	return &Mirror{
+		URL:        url,
+		Secret:     secret,
+		Sample:     sample,
+		HTTPClient: &http.Client{Timeout: time.Minute},
+		queue:      make(chan Capture, 64),
+	}
+}
+
+// Start returns a recording tape if req is picked for mirroring, or nil.
+// It must be called before the pipeline changes req. A nil Mirror mirrors
+// nothing.
+func (m *Mirror) Start(req *types.ChatRequest) *Tape {
+	if m == nil || mrand.Float64() >= m.Sample {
+		return nil
+	}
+	body, err := json.Marshal(req)
+	if err != nil {
+		return nil
+	}
+	return &Tape{finish: func(t *Tape, res Result) {
+		c := Capture{ID: newID(), Request: body, Exchanges: t.Exchanges(), Stable: res}
+		select {
+		case m.queue <- c:
+		default:
+			mirrored.Inc("dropped")
+		}
+	}}
+}
+
+// Run sends queued captures until ctx is done.
+func (m *Mirror) Run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case c := <-m.queue:
+			if err := m.send(ctx, c); err != nil {
+				mirrored.Inc("failed")
+				log.Printf("canary mirror error: %v", err)
+				continue
+			}
+			mirrored.Inc("sent")
+		}
+	}
+}
+
+func (m *Mirror) send(ctx context.Context, c Capture) error {
+	body, err := json.Marshal(c)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, m.URL+"/internal/canary/compare", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if m.Secret != "" {
+		req.Header.Set("Authorization", "Bearer "+m.Secret)
+	}
+	resp, err := m.HTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("canary returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+func newID() string {
+	var b [8]byte
+	_, _ = rand.Read(b[:])
+	return "mir_" + hex.EncodeToString(b[:])
+}
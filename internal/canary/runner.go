@@ -0,0 +1,62 @@
+package canary
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/shivansh-source/nopass/internal/orchestrator"
+)
+
+// Runner records or replays sandbox runs made with a tape in the context
+// and passes every other run through to the wrapped runner.
+type Runner struct {
+	orchestrator.Runner
+}
+
+func (r Runner) RunInSandbox(ctx context.Context, systemPrompt, userContent string) (string, error) {
+	tape := TapeFrom(ctx)
+	if tape == nil {
+		return r.Runner.RunInSandbox(ctx, systemPrompt, userContent)
+	}
+	key := callKey("sandbox", []byte(systemPrompt+"\x00"+userContent))
+
+	if tape.Replaying() {
+		e, err := tape.Next(key)
+		if err != nil {
+			return "", err
+		}
+		if err := wait(ctx, e); err != nil {
+			return "", err
+		}
+		if e.Error != "" {
+			return "", errors.New(e.Error)
+		}
+		return string(e.Body), nil
+	}
+
+	start := time.Now()
+	answer, err := r.Runner.RunInSandbox(ctx, systemPrompt, userContent)
+	e := Exchange{Key: key, Body: []byte(answer), Duration: time.Since(start)}
+	if err != nil {
+		e.Error = err.Error()
+	}
+	tape.Record(e)
+	return answer, err
+}
+
+// RunInSandboxStream keeps the wrapped runner's streaming mode. Streamed
+// requests aren't mirrored, so a taped run is delivered as one chunk.
+func (r Runner) RunInSandboxStream(ctx context.Context, systemPrompt, userContent string, onChunk func(string) error) (string, error) {
+	if TapeFrom(ctx) == nil {
+		return orchestrator.RunStream(ctx, r.Runner, systemPrompt, userContent, onChunk)
+	}
+	return orchestrator.RunStream(ctx, runOnly{r}, systemPrompt, userContent, onChunk)
+}
+
+// runOnly hides RunInSandboxStream so RunStream falls back to RunInSandbox.
+type runOnly struct{ r Runner }
+
+func (o runOnly) RunInSandbox(ctx context.Context, systemPrompt, userContent string) (string, error) {
+	return o.r.RunInSandbox(ctx, systemPrompt, userContent)
+}
@@ -0,0 +1,180 @@
+// Package canary verifies a new gateway build on real traffic before
+// cutover. The stable gateway mirrors a sample of chat requests to the
+// canary together with a tape of every downstream response it received
+// (risk scoring, output safety, the sandbox run, webhooks); the canary
+// runs the same request against the tape instead of the live services and
+// reports where its disposition or latency differs from the stable build.
+//
+// Replaying the tape keeps the comparison about the gateway itself: the
+// model and the scoring services answer exactly as they did for the stable
+// build, and the canary never reaches them.
+package canary
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/shivansh-source/nopass/internal/types"
+)
+
+// ErrReplayMiss means the canary made a downstream call the stable build
+// didn't make, so there is nothing to replay.
+var ErrReplayMiss = errors.New("canary: no recorded response for this call")
+
+// Exchange is one recorded downstream call.
+type Exchange struct {
+	Key      string        `json:"key"`
+	Status   int           `json:"status,omitempty"` // HTTP calls only
+	Body     []byte        `json:"body,omitempty"`
+	Error    string        `json:"error,omitempty"`
+	Duration time.Duration `json:"duration_ns"`
+}
+
+// Result is what one gateway build did with a request.
+type Result struct {
+	Disposition string          `json:"disposition"`
+	RiskLevel   types.RiskLevel `json:"risk_level,omitempty"`
+	Path        types.Path      `json:"path,omitempty"`
+	Withheld    bool            `json:"withheld,omitempty"`
+	Flags       []string        `json:"flags,omitempty"`
+	AnswerHash  string          `json:"answer_hash,omitempty"`
+	LatencyMs   int64           `json:"latency_ms"`
+}
+
+// ResultOf fills the response-derived fields of a Result. The answer is
+// kept only as a hash; the comparison needs to know whether it changed,
+// not what it said.
+func ResultOf(resp *types.ChatResponse, withheld bool, flags []string) Result {
+	sum := sha256.Sum256([]byte(resp.Answer))
+	return Result{
+		RiskLevel:  resp.RiskLevel,
+		Path:       resp.Path,
+		Withheld:   withheld,
+		Flags:      flags,
+		AnswerHash: hex.EncodeToString(sum[:8]),
+	}
+}
+
+// Tape collects the downstream calls of one request on the stable build,
+// or serves them back on the canary.
+type Tape struct {
+	replay bool
+	finish func(*Tape, Result)
+
+	mu        sync.Mutex
+	exchanges []Exchange
+	used      []bool
+	misses    int
+}
+
+// NewReplay returns a tape that answers calls from exchanges.
+func NewReplay(exchanges []Exchange) *Tape {
+	return &Tape{replay: true, exchanges: exchanges, used: make([]bool, len(exchanges))}
+}
+
+// Replaying reports whether calls should be answered from the tape.
+func (t *Tape) Replaying() bool { return t.replay }
+
+// Record appends an exchange made against the live service.
+func (t *Tape) Record(e Exchange) {
+	t.mu.Lock()
+	t.exchanges = append(t.exchanges, e)
+	t.mu.Unlock()
+}
+
+// Next returns the first unused exchange with key. The same call made
+// twice is answered in recorded order.
+func (t *Tape) Next(key string) (Exchange, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for i, e := range t.exchanges {
+		if !t.used[i] && e.Key == key {
+			t.used[i] = true
+			return e, nil
+		}
+	}
+	t.misses++
+	return Exchange{}, ErrReplayMiss
+}
+
+// Exchanges returns a copy of the recorded calls.
+func (t *Tape) Exchanges() []Exchange {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]Exchange(nil), t.exchanges...)
+}
+
+// Misses is the number of calls that found nothing to replay.
+func (t *Tape) Misses() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.misses
+}
+
+// Finish reports the request's outcome. The gateway calls it once the
+// response has been written.
+func (t *Tape) Finish(res Result) {
+	if t.finish != nil {
+		t.finish(t, res)
+	}
+}
+
+// wait sleeps for the recorded duration of e, so canary latencies stay
+// comparable with the stable build's.
+func wait(ctx context.Context, e Exchange) error {
+	if e.Duration <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(e.Duration)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// requestIDLine matches the request ID and policy version lines of a
+// sandbox prompt's <context> block, as sent and JSON-escaped.
+var requestIDLine = regexp.MustCompile(`(?:request_id|policy_version): [^\s"\\]*(?:\\n|\n)`)
+
+// callKey identifies a downstream call by what was sent. Deadline budgets
+// and request IDs differ on every run, so deadline_ms is dropped from JSON
+// (and NDJSON) bodies first, as are the request ID lines of prompts.
+func callKey(kind string, body []byte) string {
+	body = requestIDLine.ReplaceAll(body, nil)
+	lines := bytes.Split(body, []byte("\n"))
+	for i, line := range lines {
+		var obj map[string]any
+		if json.Unmarshal(line, &obj) != nil {
+			continue
+		}
+		delete(obj, "deadline_ms")
+		if b, err := json.Marshal(obj); err == nil {
+			lines[i] = b
+		}
+	}
+	sum := sha256.Sum256(bytes.Join(lines, []byte("\n")))
+	return kind + " " + hex.EncodeToString(sum[:16])
+}
+
+type tapeKey struct{}
+
+// WithTape attaches t to ctx; downstream calls made with ctx go through it.
+func WithTape(ctx context.Context, t *Tape) context.Context {
+	return context.WithValue(ctx, tapeKey{}, t)
+}
+
+// TapeFrom returns the tape attached to ctx, or nil.
+func TapeFrom(ctx context.Context) *Tape {
+	t, _ := ctx.Value(tapeKey{}).(*Tape)
+	return t
+}
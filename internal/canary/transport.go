@@ -0,0 +1,83 @@
+package canary
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// Transport records or replays HTTP calls made with a tape in the request
+// context and passes every other call through to Base.
+type Transport struct {
+	Base http.RoundTripper
+}
+
+func (t *Transport) base() http.RoundTripper {
+	if t.Base == nil {
+		return http.DefaultTransport
+	}
+	return t.Base
+}
+
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	tape := TapeFrom(req.Context())
+	if tape == nil {
+		return t.base().RoundTrip(req)
+	}
+
+	var body []byte
+	if req.Body != nil {
+		b, err := io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		body = b
+	}
+	key := callKey(req.Method+" "+req.URL.Host+req.URL.Path, body)
+
+	if tape.Replaying() {
+		e, err := tape.Next(key)
+		if err != nil {
+			return nil, fmt.Errorf("%w (%s %s)", err, req.Method, req.URL.Path)
+		}
+		if err := wait(req.Context(), e); err != nil {
+			return nil, err
+		}
+		if e.Error != "" {
+			return nil, errors.New(e.Error)
+		}
+		return &http.Response{
+			Status:        http.StatusText(e.Status),
+			StatusCode:    e.Status,
+			Proto:         "HTTP/1.1",
+			ProtoMajor:    1,
+			ProtoMinor:    1,
+			Header:        make(http.Header),
+			Body:          io.NopCloser(bytes.NewReader(e.Body)),
+			ContentLength: int64(len(e.Body)),
+			Request:       req,
+		}, nil
+	}
+
+	out := req.Clone(req.Context())
+	out.Body = io.NopCloser(bytes.NewReader(body))
+	out.ContentLength = int64(len(body))
+	start := time.Now()
+	resp, err := t.base().RoundTrip(out)
+	if err != nil {
+		tape.Record(Exchange{Key: key, Error: err.Error(), Duration: time.Since(start)})
+		return nil, err
+	}
+	respBody, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	tape.Record(Exchange{Key: key, Status: resp.StatusCode, Body: respBody, Duration: time.Since(start)})
+	resp.Body = io.NopCloser(bytes.NewReader(respBody))
+	return resp, nil
+}
@@ -0,0 +1,954 @@
+// Package config loads the gateway's configuration file. The file is YAML
+// (JSON works too, being valid YAML); every setting can be overridden by
+// its NOPASS_* environment variable, so existing env-only deployments keep
+// working without a file.
+//
+// Example:
+//
+//	listen: ":8082"
+//	risk_url: http://risk:8001
+//	risk_engine: fallback
+//	output_url: http://output-safety:8002
+//	output_engine: fallback
+//	sandbox:
+//	  mode: local
+//	  image: "nopass-llm-sandbox:latest"
+//	  moderation_image: "nopass-moderation:latest"
+//	  runtime: runsc
+//	  limits: {memory_mb: 512, cpus: 1, pids: 128, no_new_privileges: true, read_only_rootfs: true, tmpfs_mb: 64}
+//	timeouts: {risk: 2s, output_safety: 3s, sandbox: 15s}
+//	request_timeout: 30s
+//	masking: {cards: true, emails: true, phones: false}
+//	paths: {slow_risk_level: MEDIUM, self_check_slow: true, candidates: 3}
+//	providers:
+//	  openai: {kind: openai, url: "https://api.openai.com", model: gpt-4o-mini, api_key_env: OPENAI_API_KEY}
+//	  local: {kind: ollama, url: "http://ollama:11434", model: llama3.1}
+//	tracing: {endpoint: "http://otel-collector:4318", sample_ratio: 0.1}
+//	audit: {sink: "file:/var/lib/nopass/audit", retention: 2160h}
+//	resilience: {retries: 2, backoff: 50ms, failure_rate: 0.5, window: 20, min_calls: 10, cooldown: 30s}
+//
+// A reload (SIGHUP) re-reads the file and the environment. Settings in
+// Runtime take effect immediately; the others need a restart, which
+// RestartRequired reports.
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/shivansh-source/nopass/internal/answercache"
+	"github.com/shivansh-source/nopass/internal/pii"
+	"github.com/shivansh-source/nopass/internal/types"
+)
+
+// Config is the gateway configuration.
+type Config struct {
+	Listen string `yaml:"listen"` // NOPASS_LISTEN
+	// GRPCListen is the address the chat API is also served on over gRPC
+	// (nopass.v1.ChatService); empty disables it (NOPASS_GRPC_LISTEN).
+	GRPCListen string `yaml:"grpc_listen"`
+	RiskURL    string `yaml:"risk_url"` // NOPASS_RISK_URL
+	// RiskEngine is "remote" (the risk service only), "fallback" (the
+	// in-process rules engine when the service fails) or "builtin" (the
+	// rules engine only) (NOPASS_RISK_ENGINE).
+	RiskEngine string `yaml:"risk_engine"`
+	OutputURL  string `yaml:"output_url"` // NOPASS_OUTPUT_URL
+	// OutputEngine is "remote", "fallback" or "builtin" like RiskEngine,
+	// for output safety and the in-process review engine, or "moderation":
+	// the review engine followed by the moderation model in
+	// sandbox.moderation_image (NOPASS_OUTPUT_ENGINE).
+	OutputEngine string   `yaml:"output_engine"`
+	Sandbox      Sandbox  `yaml:"sandbox"`
+	Timeouts     Timeouts `yaml:"timeouts"`
+	Runtime      Runtime  `yaml:",inline"`
+	// Providers are model APIs that requests can select by name
+	// (generation.provider) and that sandbox mode "provider" uses instead
+	// of Docker.
+	Providers map[string]Provider `yaml:"providers"`
+	Tracing   Tracing             `yaml:"tracing"`
+	Audit     Audit               `yaml:"audit"`
+	// Resilience is how calls to the risk and output safety services are
+	// retried and when their circuit breakers open.
+	Resilience Resilience `yaml:"resilience"`
+	Sessions   Sessions   `yaml:"sessions"`
+	// PromptCanary hides a random marker in each request's system prompt
+	// and withholds answers that repeat it, flagged system_prompt_leak.
+	// The prompt then differs per request, so backends can no longer
+	// cache it (NOPASS_PROMPT_CANARY).
+	PromptCanary bool         `yaml:"prompt_canary"`
+	RefusalCache RefusalCache `yaml:"refusal_cache"`
+	PII          PII          `yaml:"pii"`
+	AnswerCache  AnswerCache  `yaml:"answer_cache"`
+	Scan         Scan         `yaml:"scan"`
+	Explanations Explanations `yaml:"explanations"`
+	MaskSamples  MaskSamples  `yaml:"mask_samples"`
+}
+
+// MaskSamples keeps Rate (e.g. 0.001) of user messages, with what masking
+// found in them, for people to label in the admin UI; the labels give
+// per-kind masking precision and recall. Samples are sealed under the
+// vault keys, kept under Dir for TTL. A zero Rate disables sampling
+// (NOPASS_MASK_SAMPLE_RATE, NOPASS_MASK_SAMPLE_DIR,
+// NOPASS_MASK_SAMPLE_TTL). The token that opens samples stays in the
+// environment (NOPASS_MASK_SAMPLE_TOKEN).
+type MaskSamples struct {
+	Rate float64       `yaml:"rate"`
+	Dir  string        `yaml:"dir"`
+	TTL  time.Duration `yaml:"ttl"`
+}
+
+// Explanations serves GET /v1/requests/{id}/explanation: a user-safe
+// reason (category and appeal instructions, never flags) for a blocked or
+// modified request, from outcomes kept in process for TTL or else the
+// audit log (NOPASS_EXPLANATIONS, NOPASS_EXPLANATION_TTL).
+type Explanations struct {
+	Enabled bool          `yaml:"enabled"`
+	TTL     time.Duration `yaml:"ttl"`
+	// Templates is a YAML file rewording the categories and appeal text,
+	// per tenant if need be (see package explain); empty uses the
+	// built-in wording (NOPASS_EXPLANATION_TEMPLATES).
+	Templates string `yaml:"templates"`
+}
+
+// Scan sets how a request's external data blocks are scanned.
+type Scan struct {
+	// Concurrency is how many blocks are scanned at once
+	// (NOPASS_SCAN_CONCURRENCY).
+	Concurrency int `yaml:"concurrency"`
+	// Timeout bounds each block's scan, after which it counts as failed;
+	// 0 leaves only the request's deadline (NOPASS_SCAN_TIMEOUT).
+	Timeout time.Duration `yaml:"timeout"`
+}
+
+// AnswerCache keeps the reviewed answers to low-risk, single-turn
+// fast-path requests for TTL and answers identical requests (same masked
+// prompt, external data and policy) without a sandbox run. Each instance
+// keeps at most Size; answers are shared through Redis when that is the
+// storage backend. A zero TTL disables it (NOPASS_ANSWER_CACHE_TTL,
+// NOPASS_ANSWER_CACHE_SIZE).
+type AnswerCache struct {
+	TTL  time.Duration `yaml:"ttl"`
+	Size int           `yaml:"size"`
+	// Tenants turns caching on or off per tenant, "default" for the rest;
+	// it is on for every tenant otherwise (NOPASS_ANSWER_CACHE_TENANTS,
+	// e.g. "default=off,acme=on").
+	Tenants map[string]bool `yaml:"tenants"`
+}
+
+// PII configures an entity recognition service speaking Presidio's
+// analyzer API, whose findings (names, addresses, national IDs, ...) are
+// masked along with the built-in patterns.
+type PII struct {
+	// DetectorURL is the service's base URL; empty disables it
+	// (NOPASS_PII_DETECTOR_URL).
+	DetectorURL string `yaml:"detector_url"`
+	// Entities limits it to some entity types (NOPASS_PII_ENTITIES,
+	// comma-separated).
+	Entities []string `yaml:"entities"`
+	// MinScore is its confidence floor; 0 means 0.5 (NOPASS_PII_MIN_SCORE).
+	MinScore float64 `yaml:"min_score"`
+	// Language is the text language; empty means en (NOPASS_PII_LANGUAGE).
+	Language string `yaml:"language"`
+}
+
+// RefusalCache remembers refused requests per user or session for TTL and
+// refuses exact repeats again without risk scoring or a sandbox run,
+// keeping at most Size; a zero TTL disables it
+// (NOPASS_REFUSAL_CACHE_TTL, NOPASS_REFUSAL_CACHE_SIZE).
+type RefusalCache struct {
+	TTL  time.Duration `yaml:"ttl"`
+	Size int           `yaml:"size"`
+}
+
+// Sessions sets how a session's concurrent turns are handled.
+type Sessions struct {
+	// Serialize runs each session's turns one at a time, in arrival
+	// order, with up to Queue waiting behind the running one; more are
+	// refused with 429. Ordering holds per gateway, so replicas need
+	// session affinity. NOPASS_SESSION_QUEUE sets Queue and turns it on.
+	Serialize bool `yaml:"serialize"`
+	Queue     int  `yaml:"queue"`
+}
+
+// Resilience configures the downstream services' retries and circuit
+// breakers (see package breaker).
+type Resilience struct {
+	// Retries is how often a call that failed transiently (a connection
+	// error, a timeout, 429 or 5xx) is retried, after a jittered backoff
+	// starting at Backoff (NOPASS_RETRIES, NOPASS_RETRY_BACKOFF).
+	Retries int           `yaml:"retries"`
+	Backoff time.Duration `yaml:"backoff"`
+	// A breaker opens when FailureRate of the last Window calls failed,
+	// once MinCalls were made, and probes the service again after
+	// Cooldown (NOPASS_BREAKER_FAILURE_RATE, NOPASS_BREAKER_WINDOW,
+	// NOPASS_BREAKER_MIN_CALLS, NOPASS_BREAKER_COOLDOWN).
+	FailureRate float64       `yaml:"failure_rate"`
+	Window      int           `yaml:"window"`
+	MinCalls    int           `yaml:"min_calls"`
+	Cooldown    time.Duration `yaml:"cooldown"`
+}
+
+// Audit configures the compliance log of chat transactions.
+type Audit struct {
+	// Sink is where records go: "storage" (the storage backend's audit
+	// table), "file:<dir>", "sqlite:<path>", "postgres:<dsn>" or
+	// "s3://<bucket>/<prefix>", or a collector, "otlp:<url>" (OTLP/HTTP
+	// logs) or "collector:<url>" (JSON Lines); empty disables the log
+	// (NOPASS_AUDIT_SINK).
+	Sink string `yaml:"sink"`
+	// Retention is how long records are kept before they are pruned; zero
+	// keeps them forever. S3 sinks leave expiry to a bucket lifecycle rule
+	// (NOPASS_AUDIT_RETENTION).
+	Retention  time.Duration `yaml:"retention"`
+	S3Region   string        `yaml:"s3_region"`   // NOPASS_AUDIT_S3_REGION
+	S3Endpoint string        `yaml:"s3_endpoint"` // NOPASS_AUDIT_S3_ENDPOINT, for S3-compatible stores
+	// TLSCert and TLSKey are the client certificate presented to a
+	// collector and TLSCA the roots that verify it, PEM files
+	// (NOPASS_AUDIT_TLS_CERT, NOPASS_AUDIT_TLS_KEY, NOPASS_AUDIT_TLS_CA).
+	TLSCert string `yaml:"tls_cert"`
+	TLSKey  string `yaml:"tls_key"`
+	TLSCA   string `yaml:"tls_ca"`
+	// SpoolDir keeps batches a collector could not take, encrypted under
+	// NOPASS_AUDIT_SPOOL_KEY, until it recovers; empty keeps them in
+	// memory only (NOPASS_AUDIT_SPOOL_DIR).
+	SpoolDir string `yaml:"spool_dir"`
+}
+
+// Tracing exports OpenTelemetry spans to an OTLP/HTTP collector.
+type Tracing struct {
+	// Endpoint is the collector's base URL, e.g.
+	// http://otel-collector:4318; empty disables tracing
+	// (NOPASS_OTLP_ENDPOINT).
+	Endpoint    string  `yaml:"endpoint"`
+	ServiceName string  `yaml:"service_name"` // NOPASS_OTLP_SERVICE_NAME
+	SampleRatio float64 `yaml:"sample_ratio"` // NOPASS_TRACE_SAMPLE_RATIO
+}
+
+// Sandbox selects where and how sandbox runs execute.
+type Sandbox struct {
+	Mode  string `yaml:"mode"`  // NOPASS_SANDBOX_MODE: "local", "kubernetes", "fleet" or "provider"
+	Image string `yaml:"image"` // NOPASS_SANDBOX_IMAGE
+	// Provider names the entry of Providers that mode "provider" runs on
+	// (NOPASS_SANDBOX_PROVIDER).
+	Provider string `yaml:"provider"`
+	// ModerationImage is the moderation model output_engine "moderation"
+	// runs (NOPASS_MODERATION_IMAGE).
+	ModerationImage string `yaml:"moderation_image"`
+	// InputMode is how prompts reach local sandbox containers: "bind"
+	// mounts a temp dir of files, "volume" copies it into a named volume,
+	// for setups where bind mounts from temp dirs fail, and "stdin" writes
+	// a JSON request envelope to the container's stdin, for images that
+	// read one (NOPASS_SANDBOX_INPUT_MODE).
+	InputMode string `yaml:"input_mode"`
+	// TempDir is where sandbox input dirs are created; with Docker
+	// Desktop and bind mounts it must be shared with the Docker VM
+	// (NOPASS_SANDBOX_TEMP_DIR).
+	TempDir string `yaml:"temp_dir"`
+	// Output is the sandbox images' stdout protocol: "frames" (JSON
+	// frames carrying the answer, model, token usage and warnings apart
+	// from the image's logs), "text" (stdout is the answer) for older
+	// images, or "auto" to detect it per run (NOPASS_SANDBOX_OUTPUT).
+	// Images labelled io.nopass.output, or listed in
+	// NOPASS_SANDBOX_IMAGE_OUTPUTS, override it.
+	Output string `yaml:"output"`
+	// PoolSize containers of the shared image are kept started in mode
+	// local, each used for one run, to save the container start; 0
+	// disables the pool. An idle one is replaced after PoolMaxAge
+	// (NOPASS_SANDBOX_POOL_SIZE, NOPASS_SANDBOX_POOL_MAX_AGE).
+	PoolSize   int           `yaml:"pool_size"`
+	PoolMaxAge time.Duration `yaml:"pool_max_age"`
+	// Limits bound every local sandbox container, the moderation model's
+	// included.
+	Limits SandboxLimits `yaml:"limits"`
+	// Runtime is the OCI runtime those containers run under, e.g. runsc
+	// (gVisor) or kata, as registered with the Docker daemon; empty uses
+	// its default, runc (NOPASS_SANDBOX_RUNTIME).
+	Runtime string `yaml:"runtime"`
+	// Kubernetes configures mode "kubernetes", which runs each sandbox
+	// call as a Job in the cluster the gateway runs in, under the same
+	// Limits.
+	Kubernetes Kubernetes `yaml:"kubernetes"`
+}
+
+// Kubernetes is where and how sandbox Jobs run.
+type Kubernetes struct {
+	// Namespace is where the Jobs run; empty is the gateway's own
+	// (NOPASS_SANDBOX_KUBE_NAMESPACE).
+	Namespace string `yaml:"namespace"`
+	// RuntimeClass is the RuntimeClass sandbox pods run under, e.g. gvisor;
+	// empty uses the cluster's default (NOPASS_SANDBOX_RUNTIME_CLASS).
+	RuntimeClass string `yaml:"runtime_class"`
+}
+
+// SandboxLimits are the resource limits and security options of sandbox
+// containers; zero lifts a limit.
+type SandboxLimits struct {
+	MemoryMB int     `yaml:"memory_mb"` // NOPASS_SANDBOX_MEMORY_MB, swap disabled
+	CPUs     float64 `yaml:"cpus"`      // NOPASS_SANDBOX_CPUS, e.g. 0.5
+	Pids     int     `yaml:"pids"`      // NOPASS_SANDBOX_PIDS_LIMIT
+	// NoNewPrivileges stops setuid binaries from gaining privileges
+	// (NOPASS_SANDBOX_NO_NEW_PRIVILEGES).
+	NoNewPrivileges bool `yaml:"no_new_privileges"`
+	// ReadOnlyRootfs mounts the image read-only, leaving the input and
+	// output mounts and a TmpfsMB tmpfs at /tmp writable
+	// (NOPASS_SANDBOX_READ_ONLY_ROOTFS, NOPASS_SANDBOX_TMPFS_MB).
+	ReadOnlyRootfs bool `yaml:"read_only_rootfs"`
+	TmpfsMB        int  `yaml:"tmpfs_mb"`
+}
+
+// Provider is one model API. The key itself never goes in the file, only
+// the name of the environment variable holding it.
+type Provider struct {
+	Kind      string `yaml:"kind"` // "openai", "anthropic", "ollama" or "vllm"
+	URL       string `yaml:"url"`
+	Model     string `yaml:"model"`
+	APIKeyEnv string `yaml:"api_key_env"`
+}
+
+// Timeouts bound each downstream call.
+type Timeouts struct {
+	Risk         time.Duration `yaml:"risk"`          // NOPASS_RISK_TIMEOUT
+	OutputSafety time.Duration `yaml:"output_safety"` // NOPASS_OUTPUT_TIMEOUT
+	Sandbox      time.Duration `yaml:"sandbox"`       // NOPASS_SANDBOX_TIMEOUT
+}
+
+// Runtime holds the settings a reload applies without a restart.
+type Runtime struct {
+	// RequestTimeout bounds a whole chat request (NOPASS_REQUEST_TIMEOUT).
+	RequestTimeout time.Duration `yaml:"request_timeout"`
+	// MaxClientDeadline is the longest deadline an API key allowed to set
+	// its own (auth.Key.Deadlines) may ask for; 0 means request_timeout
+	// (NOPASS_MAX_CLIENT_DEADLINE).
+	MaxClientDeadline time.Duration `yaml:"max_client_deadline"`
+	Masking           Masking       `yaml:"masking"`
+	Paths             Paths         `yaml:"paths"`
+	Normalize         Normalize     `yaml:"normalize"`
+	Failure           Failure       `yaml:"failure"`
+}
+
+// Failure policies: what a stage does when its service fails.
+const (
+	// FailClosed refuses the request (risk scoring, output safety) or
+	// quarantines the data (external scanning).
+	FailClosed = "fail_closed"
+	// FailOpen carries on as if the stage had passed.
+	FailOpen = "fail_open"
+	// DegradeToLocal redoes the stage with the in-process rules engine.
+	DegradeToLocal = "degrade_to_local"
+)
+
+// Failure sets each stage's failure policy; what was applied is reported
+// in ChatResponse.StageFailures.
+type Failure struct {
+	Risk         string `yaml:"risk"`          // NOPASS_FAIL_RISK
+	ExternalScan string `yaml:"external_scan"` // NOPASS_FAIL_EXTERNAL_SCAN
+	OutputSafety string `yaml:"output_safety"` // NOPASS_FAIL_OUTPUT_SAFETY
+}
+
+// Normalize selects the passes that rewrite user messages and external
+// data before risk scoring and the sandbox prompt (see package normalize).
+type Normalize struct {
+	NFKC       bool `yaml:"nfkc"`       // NOPASS_NORMALIZE_NFKC
+	Homoglyphs bool `yaml:"homoglyphs"` // NOPASS_NORMALIZE_HOMOGLYPHS
+	Invisible  bool `yaml:"invisible"`  // NOPASS_NORMALIZE_INVISIBLE
+	// Decode replaces base64 and hex payloads with the text they decode
+	// to; off, they are only flagged (NOPASS_NORMALIZE_DECODE).
+	Decode bool `yaml:"decode"`
+}
+
+// Masking toggles the built-in masking of the sandbox prompt.
+type Masking struct {
+	Cards  bool `yaml:"cards"`  // NOPASS_MASK_CARDS
+	Emails bool `yaml:"emails"` // NOPASS_MASK_EMAILS
+	Phones bool `yaml:"phones"` // NOPASS_MASK_PHONES
+	// Secrets masks API keys, tokens, private keys and passwords.
+	Secrets bool `yaml:"secrets"` // NOPASS_MASK_SECRETS
+	// Locale, e.g. de-DE, is how numbers are written in requests that
+	// don't set their own locale: card and phone masking then skip the
+	// locale's dates and amounts and know its phone grouping. Empty uses
+	// the rough patterns alone (NOPASS_MASK_LOCALE).
+	Locale string `yaml:"locale"`
+}
+
+// MaxCandidates bounds Paths.Candidates: each candidate is a sandbox run
+// and an output review.
+const MaxCandidates = 8
+
+// Paths sets when a request takes the slow path.
+type Paths struct {
+	// SlowRiskLevel is the lowest risk level sent down the slow path
+	// (NOPASS_SLOW_RISK_LEVEL).
+	SlowRiskLevel types.RiskLevel `yaml:"slow_risk_level"`
+	// SelfCheckSlow also sends requests the risk service marks
+	// self_check_required down the slow path (NOPASS_SELF_CHECK_SLOW).
+	SelfCheckSlow bool `yaml:"self_check_slow"`
+	// Candidates answers are drafted in parallel sandboxes for HIGH-risk
+	// slow-path requests; each is reviewed by output safety and the best
+	// one it lets through is returned. 0 or 1 drafts a single answer
+	// (NOPASS_SLOW_CANDIDATES).
+	Candidates int `yaml:"candidates"`
+}
+
+// Default returns the built-in configuration. Minimal builds default to
+// sandbox mode "provider" and the in-process risk and review engines,
+// since they have no Docker and are usually deployed without the Python
+// services.
+func Default() Config {
+	c := Config{
+		Listen:       ":8082",
+		RiskURL:      "http://localhost:8001",
+		RiskEngine:   "remote",
+		OutputEngine: "remote",
+		OutputURL:    "http://localhost:8002",
+		Sandbox: Sandbox{
+			Mode:  "local",
+			Image: "nopass-llm-sandbox:latest",
+
+			ModerationImage: "nopass-moderation:latest",
+			InputMode:       "bind",
+			Output:          "auto",
+			PoolMaxAge:      10 * time.Minute,
+			Limits: SandboxLimits{
+				MemoryMB:        512,
+				CPUs:            1,
+				Pids:            128,
+				NoNewPrivileges: true,
+				ReadOnlyRootfs:  true,
+				TmpfsMB:         64,
+			},
+		},
+		Timeouts: Timeouts{
+			Risk:         2 * time.Second,
+			OutputSafety: 3 * time.Second,
+			Sandbox:      15 * time.Second,
+		},
+		Tracing:      Tracing{ServiceName: "nopass-gateway", SampleRatio: 1},
+		RefusalCache: RefusalCache{Size: 10000},
+		AnswerCache:  AnswerCache{Size: 10000},
+		Scan:         Scan{Concurrency: 4},
+		Explanations: Explanations{TTL: 24 * time.Hour},
+		MaskSamples:  MaskSamples{Dir: filepath.Join("data", "mask-samples"), TTL: 7 * 24 * time.Hour},
+		Resilience: Resilience{
+			Retries:     2,
+			Backoff:     50 * time.Millisecond,
+			FailureRate: 0.5,
+			Window:      20,
+			MinCalls:    10,
+			Cooldown:    30 * time.Second,
+		},
+		Runtime: Runtime{
+			RequestTimeout: 30 * time.Second,
+			Masking:        Masking{Cards: true, Emails: true, Phones: true, Secrets: true},
+			Paths:          Paths{SlowRiskLevel: types.RiskHigh, SelfCheckSlow: true},
+			Normalize:      Normalize{NFKC: true, Homoglyphs: true, Invisible: true},
+			Failure:        Failure{Risk: FailClosed, ExternalScan: FailClosed, OutputSafety: FailClosed},
+		},
+	}
+	if !dockerSupported {
+		c.Sandbox.Mode = "provider"
+		c.RiskEngine, c.OutputEngine = "builtin", "builtin"
+	}
+	return c
+}
+
+// Load reads path (if not empty) over the defaults, applies environment
+// overrides and validates the result. Unknown keys in the file are errors,
+// so a typo can't silently leave a default in place.
+func Load(path string) (Config, error) {
+	cfg := Default()
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return Config{}, fmt.Errorf("config: %w", err)
+		}
+		dec := yaml.NewDecoder(bytes.NewReader(data))
+		dec.KnownFields(true)
+		if err := dec.Decode(&cfg); err != nil && !errors.Is(err, io.EOF) {
+			return Config{}, fmt.Errorf("config: parse %s: %w", path, err)
+		}
+	}
+	if err := cfg.applyEnv(); err != nil {
+		return Config{}, err
+	}
+	if err := cfg.Validate(); err != nil {
+		return Config{}, err
+	}
+	return cfg, nil
+}
+
+// SetSandboxImage points sandbox.image in the config file at path to
+// image, keeping the rest of the file, comments included, and checks the
+// result still loads. NOPASS_SANDBOX_IMAGE, if set, still overrides it.
+func SetSandboxImage(path, image string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("config: %w", err)
+	}
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return fmt.Errorf("config: parse %s: %w", path, err)
+	}
+	if doc.Kind == 0 {
+		doc = yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{{Kind: yaml.MappingNode}}}
+	}
+	root := doc.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return fmt.Errorf("config: %s is not a mapping", path)
+	}
+	sb := mappingValue(root, "sandbox", yaml.MappingNode)
+	if sb.Kind != yaml.MappingNode {
+		return fmt.Errorf("config: %s: sandbox is not a mapping", path)
+	}
+	img := mappingValue(sb, "image", yaml.ScalarNode)
+	img.Kind, img.Tag, img.Value, img.Style = yaml.ScalarNode, "!!str", image, yaml.DoubleQuotedStyle
+
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(&doc); err != nil {
+		return fmt.Errorf("config: encode %s: %w", path, err)
+	}
+	var check Config
+	dec := yaml.NewDecoder(bytes.NewReader(buf.Bytes()))
+	dec.KnownFields(true)
+	if err := dec.Decode(&check); err != nil {
+		return fmt.Errorf("config: parse %s: %w", path, err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return fmt.Errorf("config: %w", err)
+	}
+	// Written beside the file and renamed over it, so a gateway starting
+	// meanwhile never reads half of it.
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, buf.Bytes(), info.Mode().Perm()); err != nil {
+		return fmt.Errorf("config: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("config: %w", err)
+	}
+	return nil
+}
+
+// mappingValue returns the value of key in the mapping m, adding it as an
+// empty node of kind if missing.
+func mappingValue(m *yaml.Node, key string, kind yaml.Kind) *yaml.Node {
+	for i := 0; i+1 < len(m.Content); i += 2 {
+		if m.Content[i].Value == key {
+			return m.Content[i+1]
+		}
+	}
+	v := &yaml.Node{Kind: kind}
+	m.Content = append(m.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: key}, v)
+	return v
+}
+
+func (c *Config) applyEnv() error {
+	str := func(name string, dst *string) {
+		if v := os.Getenv(name); v != "" {
+			*dst = v
+		}
+	}
+	dur := func(name string, dst *time.Duration) error {
+		if v := os.Getenv(name); v != "" {
+			d, err := time.ParseDuration(v)
+			if err != nil {
+				return fmt.Errorf("config: invalid %s %q", name, v)
+			}
+			*dst = d
+		}
+		return nil
+	}
+	boolean := func(name string, dst *bool) error {
+		if v := os.Getenv(name); v != "" {
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				return fmt.Errorf("config: invalid %s %q", name, v)
+			}
+			*dst = b
+		}
+		return nil
+	}
+
+	str("NOPASS_LISTEN", &c.Listen)
+	str("NOPASS_GRPC_LISTEN", &c.GRPCListen)
+	str("NOPASS_RISK_URL", &c.RiskURL)
+	str("NOPASS_RISK_ENGINE", &c.RiskEngine)
+	str("NOPASS_OUTPUT_URL", &c.OutputURL)
+	str("NOPASS_OUTPUT_ENGINE", &c.OutputEngine)
+	str("NOPASS_SANDBOX_MODE", &c.Sandbox.Mode)
+	str("NOPASS_SANDBOX_IMAGE", &c.Sandbox.Image)
+	str("NOPASS_SANDBOX_PROVIDER", &c.Sandbox.Provider)
+	str("NOPASS_MODERATION_IMAGE", &c.Sandbox.ModerationImage)
+	str("NOPASS_SANDBOX_INPUT_MODE", &c.Sandbox.InputMode)
+	str("NOPASS_SANDBOX_TEMP_DIR", &c.Sandbox.TempDir)
+	str("NOPASS_SANDBOX_OUTPUT", &c.Sandbox.Output)
+	str("NOPASS_SANDBOX_RUNTIME", &c.Sandbox.Runtime)
+	str("NOPASS_SANDBOX_KUBE_NAMESPACE", &c.Sandbox.Kubernetes.Namespace)
+	str("NOPASS_SANDBOX_RUNTIME_CLASS", &c.Sandbox.Kubernetes.RuntimeClass)
+	str("NOPASS_OTLP_ENDPOINT", &c.Tracing.Endpoint)
+	str("NOPASS_OTLP_SERVICE_NAME", &c.Tracing.ServiceName)
+	str("NOPASS_AUDIT_SINK", &c.Audit.Sink)
+	str("NOPASS_AUDIT_S3_REGION", &c.Audit.S3Region)
+	str("NOPASS_AUDIT_S3_ENDPOINT", &c.Audit.S3Endpoint)
+	str("NOPASS_AUDIT_TLS_CERT", &c.Audit.TLSCert)
+	str("NOPASS_AUDIT_TLS_KEY", &c.Audit.TLSKey)
+	str("NOPASS_AUDIT_TLS_CA", &c.Audit.TLSCA)
+	str("NOPASS_AUDIT_SPOOL_DIR", &c.Audit.SpoolDir)
+	str("NOPASS_MASK_LOCALE", &c.Runtime.Masking.Locale)
+	str("NOPASS_FAIL_RISK", &c.Runtime.Failure.Risk)
+	str("NOPASS_FAIL_EXTERNAL_SCAN", &c.Runtime.Failure.ExternalScan)
+	str("NOPASS_FAIL_OUTPUT_SAFETY", &c.Runtime.Failure.OutputSafety)
+	str("NOPASS_EXPLANATION_TEMPLATES", &c.Explanations.Templates)
+	str("NOPASS_MASK_SAMPLE_DIR", &c.MaskSamples.Dir)
+	str("NOPASS_PII_DETECTOR_URL", &c.PII.DetectorURL)
+	str("NOPASS_PII_LANGUAGE", &c.PII.Language)
+	if v := os.Getenv("NOPASS_PII_ENTITIES"); v != "" {
+		c.PII.Entities = nil
+		for _, t := range strings.Split(v, ",") {
+			if t = strings.TrimSpace(t); t != "" {
+				c.PII.Entities = append(c.PII.Entities, t)
+			}
+		}
+	}
+	if v := os.Getenv("NOPASS_ANSWER_CACHE_TENANTS"); v != "" {
+		tenants, err := answercache.ParseTenants(v)
+		if err != nil {
+			return fmt.Errorf("config: invalid NOPASS_ANSWER_CACHE_TENANTS: %w", err)
+		}
+		c.AnswerCache.Tenants = tenants
+	}
+	if v := os.Getenv("NOPASS_PII_MIN_SCORE"); v != "" {
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return fmt.Errorf("config: invalid NOPASS_PII_MIN_SCORE %q", v)
+		}
+		c.PII.MinScore = f
+	}
+	if v := os.Getenv("NOPASS_TRACE_SAMPLE_RATIO"); v != "" {
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return fmt.Errorf("config: invalid NOPASS_TRACE_SAMPLE_RATIO %q", v)
+		}
+		c.Tracing.SampleRatio = f
+	}
+	if v := os.Getenv("NOPASS_MASK_SAMPLE_RATE"); v != "" {
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return fmt.Errorf("config: invalid NOPASS_MASK_SAMPLE_RATE %q", v)
+		}
+		c.MaskSamples.Rate = f
+	}
+	if v := os.Getenv("NOPASS_BREAKER_FAILURE_RATE"); v != "" {
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return fmt.Errorf("config: invalid NOPASS_BREAKER_FAILURE_RATE %q", v)
+		}
+		c.Resilience.FailureRate = f
+	}
+	if v := os.Getenv("NOPASS_SANDBOX_CPUS"); v != "" {
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return fmt.Errorf("config: invalid NOPASS_SANDBOX_CPUS %q", v)
+		}
+		c.Sandbox.Limits.CPUs = f
+	}
+	for name, dst := range map[string]*int{
+		"NOPASS_RETRIES":            &c.Resilience.Retries,
+		"NOPASS_BREAKER_WINDOW":     &c.Resilience.Window,
+		"NOPASS_BREAKER_MIN_CALLS":  &c.Resilience.MinCalls,
+		"NOPASS_SANDBOX_POOL_SIZE":  &c.Sandbox.PoolSize,
+		"NOPASS_SLOW_CANDIDATES":    &c.Runtime.Paths.Candidates,
+		"NOPASS_SANDBOX_MEMORY_MB":  &c.Sandbox.Limits.MemoryMB,
+		"NOPASS_SANDBOX_PIDS_LIMIT": &c.Sandbox.Limits.Pids,
+		"NOPASS_SANDBOX_TMPFS_MB":   &c.Sandbox.Limits.TmpfsMB,
+		"NOPASS_REFUSAL_CACHE_SIZE": &c.RefusalCache.Size,
+		"NOPASS_ANSWER_CACHE_SIZE":  &c.AnswerCache.Size,
+		"NOPASS_SCAN_CONCURRENCY":   &c.Scan.Concurrency,
+	} {
+		if v := os.Getenv(name); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				return fmt.Errorf("config: invalid %s %q", name, v)
+			}
+			*dst = n
+		}
+	}
+	if v := os.Getenv("NOPASS_SESSION_QUEUE"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return fmt.Errorf("config: invalid NOPASS_SESSION_QUEUE %q", v)
+		}
+		c.Sessions.Serialize, c.Sessions.Queue = true, n
+	}
+	if v := os.Getenv("NOPASS_SLOW_RISK_LEVEL"); v != "" {
+		c.Runtime.Paths.SlowRiskLevel = types.RiskLevel(v)
+	}
+	for _, err := range []error{
+		dur("NOPASS_RISK_TIMEOUT", &c.Timeouts.Risk),
+		dur("NOPASS_OUTPUT_TIMEOUT", &c.Timeouts.OutputSafety),
+		dur("NOPASS_SANDBOX_TIMEOUT", &c.Timeouts.Sandbox),
+		dur("NOPASS_SANDBOX_POOL_MAX_AGE", &c.Sandbox.PoolMaxAge),
+		dur("NOPASS_REQUEST_TIMEOUT", &c.Runtime.RequestTimeout),
+		dur("NOPASS_MAX_CLIENT_DEADLINE", &c.Runtime.MaxClientDeadline),
+		dur("NOPASS_AUDIT_RETENTION", &c.Audit.Retention),
+		dur("NOPASS_RETRY_BACKOFF", &c.Resilience.Backoff),
+		dur("NOPASS_BREAKER_COOLDOWN", &c.Resilience.Cooldown),
+		dur("NOPASS_REFUSAL_CACHE_TTL", &c.RefusalCache.TTL),
+		dur("NOPASS_ANSWER_CACHE_TTL", &c.AnswerCache.TTL),
+		dur("NOPASS_SCAN_TIMEOUT", &c.Scan.Timeout),
+		dur("NOPASS_EXPLANATION_TTL", &c.Explanations.TTL),
+		dur("NOPASS_MASK_SAMPLE_TTL", &c.MaskSamples.TTL),
+		boolean("NOPASS_EXPLANATIONS", &c.Explanations.Enabled),
+		boolean("NOPASS_MASK_CARDS", &c.Runtime.Masking.Cards),
+		boolean("NOPASS_MASK_EMAILS", &c.Runtime.Masking.Emails),
+		boolean("NOPASS_MASK_PHONES", &c.Runtime.Masking.Phones),
+		boolean("NOPASS_MASK_SECRETS", &c.Runtime.Masking.Secrets),
+		boolean("NOPASS_SELF_CHECK_SLOW", &c.Runtime.Paths.SelfCheckSlow),
+		boolean("NOPASS_SANDBOX_NO_NEW_PRIVILEGES", &c.Sandbox.Limits.NoNewPrivileges),
+		boolean("NOPASS_SANDBOX_READ_ONLY_ROOTFS", &c.Sandbox.Limits.ReadOnlyRootfs),
+		boolean("NOPASS_NORMALIZE_NFKC", &c.Runtime.Normalize.NFKC),
+		boolean("NOPASS_NORMALIZE_HOMOGLYPHS", &c.Runtime.Normalize.Homoglyphs),
+		boolean("NOPASS_NORMALIZE_INVISIBLE", &c.Runtime.Normalize.Invisible),
+		boolean("NOPASS_NORMALIZE_DECODE", &c.Runtime.Normalize.Decode),
+		boolean("NOPASS_PROMPT_CANARY", &c.PromptCanary),
+	} {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Validate checks every setting and normalizes the risk level's casing.
+func (c *Config) Validate() error {
+	if c.Listen == "" {
+		return errors.New("config: listen is required")
+	}
+	if c.GRPCListen != "" && c.GRPCListen == c.Listen {
+		return errors.New("config: grpc_listen must differ from listen")
+	}
+	for name, u := range map[string]string{"risk_url": c.RiskURL, "output_url": c.OutputURL} {
+		parsed, err := url.Parse(u)
+		if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+			return fmt.Errorf("config: %s must be an http(s) URL, got %q", name, u)
+		}
+	}
+	switch c.RiskEngine {
+	case "remote", "fallback", "builtin":
+	default:
+		return fmt.Errorf("config: risk_engine must be remote, fallback or builtin, got %q", c.RiskEngine)
+	}
+	switch c.OutputEngine {
+	case "remote", "fallback", "builtin":
+	case "moderation":
+		if c.Sandbox.ModerationImage == "" {
+			return errors.New("config: output_engine moderation needs sandbox.moderation_image")
+		}
+	default:
+		return fmt.Errorf("config: output_engine must be remote, fallback, builtin or moderation, got %q", c.OutputEngine)
+	}
+	if !dockerSupported && (c.Sandbox.Mode == "local" || c.OutputEngine == "moderation") {
+		return errors.New("config: this minimal build has no Docker sandbox; use sandbox.mode provider or kubernetes and an output_engine other than moderation")
+	}
+	switch c.Sandbox.Mode {
+	case "local", "kubernetes", "fleet":
+	case "provider":
+		if _, ok := c.Providers[c.Sandbox.Provider]; !ok {
+			return fmt.Errorf("config: sandbox.provider %q is not in providers", c.Sandbox.Provider)
+		}
+	default:
+		return fmt.Errorf("config: sandbox.mode must be local, kubernetes, fleet or provider, got %q", c.Sandbox.Mode)
+	}
+	for name, p := range c.Providers {
+		if name == "sandbox" {
+			return errors.New(`config: provider name "sandbox" is reserved`)
+		}
+		switch p.Kind {
+		case "openai", "anthropic", "ollama", "vllm":
+		default:
+			return fmt.Errorf("config: providers.%s.kind must be openai, anthropic, ollama or vllm, got %q", name, p.Kind)
+		}
+		parsed, err := url.Parse(p.URL)
+		if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+			return fmt.Errorf("config: providers.%s.url must be an http(s) URL, got %q", name, p.URL)
+		}
+		if p.Model == "" {
+			return fmt.Errorf("config: providers.%s.model is required", name)
+		}
+	}
+	if c.Sandbox.Image == "" {
+		return errors.New("config: sandbox.image is required")
+	}
+	switch c.Sandbox.InputMode {
+	case "bind", "volume", "stdin":
+	default:
+		return fmt.Errorf("config: sandbox.input_mode must be bind, volume or stdin, got %q", c.Sandbox.InputMode)
+	}
+	switch c.Sandbox.Output {
+	case "frames", "text", "auto":
+	default:
+		return fmt.Errorf("config: sandbox.output must be frames, text or auto, got %q", c.Sandbox.Output)
+	}
+	if c.Sandbox.PoolSize < 0 {
+		return errors.New("config: sandbox.pool_size must not be negative")
+	}
+	if c.Sandbox.PoolSize > 0 && c.Sandbox.PoolMaxAge <= 0 {
+		return errors.New("config: sandbox.pool_max_age must be positive")
+	}
+	if l := c.Sandbox.Limits; l.MemoryMB < 0 || l.CPUs < 0 || l.Pids < 0 || l.TmpfsMB < 0 {
+		return errors.New("config: sandbox.limits must not be negative")
+	} else if l.MemoryMB > 0 && l.MemoryMB < 6 {
+		// Docker's own minimum.
+		return fmt.Errorf("config: sandbox.limits.memory_mb must be 0 or at least 6, got %d", l.MemoryMB)
+	}
+	if c.Tracing.Endpoint != "" {
+		parsed, err := url.Parse(c.Tracing.Endpoint)
+		if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+			return fmt.Errorf("config: tracing.endpoint must be an http(s) URL, got %q", c.Tracing.Endpoint)
+		}
+	}
+	if c.PII.DetectorURL != "" {
+		parsed, err := url.Parse(c.PII.DetectorURL)
+		if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+			return fmt.Errorf("config: pii.detector_url must be an http(s) URL, got %q", c.PII.DetectorURL)
+		}
+	}
+	if c.PII.MinScore < 0 || c.PII.MinScore > 1 {
+		return fmt.Errorf("config: pii.min_score must be between 0 and 1, got %v", c.PII.MinScore)
+	}
+	if c.Tracing.SampleRatio < 0 || c.Tracing.SampleRatio > 1 {
+		return fmt.Errorf("config: tracing.sample_ratio must be between 0 and 1, got %v", c.Tracing.SampleRatio)
+	}
+	if s := c.Audit.Sink; s != "" && s != "storage" && !slices.ContainsFunc([]string{"file:", "sqlite:", "postgres:", "s3://", "otlp:http", "collector:http"}, func(p string) bool {
+		return strings.HasPrefix(s, p) && len(s) > len(p)
+	}) {
+		return fmt.Errorf("config: audit.sink must be storage, file:<dir>, sqlite:<path>, postgres:<dsn>, s3://<bucket>/<prefix>, otlp:<url> or collector:<url>, got %q", s)
+	}
+	if (c.Audit.TLSCert == "") != (c.Audit.TLSKey == "") {
+		return errors.New("config: audit.tls_cert and audit.tls_key must be set together")
+	}
+	if c.Audit.Retention < 0 {
+		return errors.New("config: audit.retention must not be negative")
+	}
+	for name, d := range map[string]time.Duration{
+		"timeouts.risk":          c.Timeouts.Risk,
+		"timeouts.output_safety": c.Timeouts.OutputSafety,
+		"timeouts.sandbox":       c.Timeouts.Sandbox,
+		"request_timeout":        c.Runtime.RequestTimeout,
+	} {
+		if d <= 0 {
+			return fmt.Errorf("config: %s must be positive", name)
+		}
+	}
+	r := c.Resilience
+	switch {
+	case r.Retries < 0 || r.Backoff < 0:
+		return errors.New("config: resilience.retries and resilience.backoff must not be negative")
+	case r.FailureRate <= 0 || r.FailureRate > 1:
+		return fmt.Errorf("config: resilience.failure_rate must be above 0 and at most 1, got %v", r.FailureRate)
+	case r.Window < 1 || r.MinCalls < 1 || r.MinCalls > r.Window:
+		return errors.New("config: resilience.min_calls must be between 1 and resilience.window")
+	case r.Cooldown <= 0:
+		return errors.New("config: resilience.cooldown must be positive")
+	}
+	if c.RefusalCache.TTL < 0 || c.RefusalCache.TTL > 0 && c.RefusalCache.Size <= 0 {
+		return errors.New("config: refusal_cache.ttl must not be negative and refusal_cache.size must be positive")
+	}
+	if a := c.AnswerCache; a.TTL < 0 || a.TTL > 0 && a.TTL < time.Millisecond {
+		return fmt.Errorf("config: answer_cache.ttl must be 0 or at least 1ms, got %s", a.TTL)
+	} else if a.TTL > 0 && a.Size <= 0 {
+		return errors.New("config: answer_cache.size must be positive")
+	}
+	if m := c.MaskSamples; m.Rate < 0 || m.Rate > 1 {
+		return fmt.Errorf("config: mask_samples.rate must be between 0 and 1, got %v", m.Rate)
+	} else if m.Rate > 0 && (m.TTL <= 0 || m.Dir == "") {
+		return errors.New("config: mask_samples.ttl must be positive and mask_samples.dir set")
+	}
+	if c.Explanations.Enabled && c.Explanations.TTL <= 0 {
+		return errors.New("config: explanations.ttl must be positive")
+	}
+	if c.Scan.Concurrency < 1 {
+		return errors.New("config: scan.concurrency must be at least 1")
+	}
+	if c.Scan.Timeout < 0 {
+		return errors.New("config: scan.timeout must not be negative")
+	}
+	if c.Sessions.Queue < 0 {
+		return errors.New("config: sessions.queue must not be negative")
+	}
+	if l := c.Runtime.Masking.Locale; l != "" && !pii.ValidLocale(l) {
+		return fmt.Errorf("config: masking.locale must be a locale like de-DE, got %q", l)
+	}
+	if c.Runtime.MaxClientDeadline < 0 {
+		return errors.New("config: max_client_deadline must not be negative")
+	}
+	if c.Runtime.RequestTimeout < c.Timeouts.Sandbox {
+		return errors.New("config: request_timeout must be at least timeouts.sandbox")
+	}
+	level := types.RiskLevel(strings.ToUpper(string(c.Runtime.Paths.SlowRiskLevel)))
+	if level.Rank() > types.RiskHigh.Rank() {
+		return fmt.Errorf("config: paths.slow_risk_level must be LOW, MEDIUM or HIGH, got %q", c.Runtime.Paths.SlowRiskLevel)
+	}
+	c.Runtime.Paths.SlowRiskLevel = level
+	if n := c.Runtime.Paths.Candidates; n < 0 || n > MaxCandidates {
+		return fmt.Errorf("config: paths.candidates must be between 0 and %d, got %d", MaxCandidates, n)
+	}
+	for name, v := range map[string]string{
+		"failure.risk":          c.Runtime.Failure.Risk,
+		"failure.external_scan": c.Runtime.Failure.ExternalScan,
+		"failure.output_safety": c.Runtime.Failure.OutputSafety,
+	} {
+		if v != FailClosed && v != FailOpen && v != DegradeToLocal {
+			return fmt.Errorf("config: %s must be fail_closed, fail_open or degrade_to_local, got %q", name, v)
+		}
+	}
+	return nil
+}
+
+// RestartRequired lists the settings that differ between old and new but
+// only take effect on restart.
+func RestartRequired(old, new Config) []string {
+	var changed []string
+	check := func(name string, differs bool) {
+		if differs {
+			changed = append(changed, name)
+		}
+	}
+	check("listen", old.Listen != new.Listen)
+	check("grpc_listen", old.GRPCListen != new.GRPCListen)
+	check("risk_url", old.RiskURL != new.RiskURL)
+	check("risk_engine", old.RiskEngine != new.RiskEngine)
+	check("output_engine", old.OutputEngine != new.OutputEngine)
+	check("output_url", old.OutputURL != new.OutputURL)
+	check("sandbox", old.Sandbox != new.Sandbox)
+	check("timeouts", old.Timeouts != new.Timeouts)
+	check("providers", !maps.Equal(old.Providers, new.Providers))
+	check("tracing", old.Tracing != new.Tracing)
+	check("audit", old.Audit != new.Audit)
+	check("resilience", old.Resilience != new.Resilience)
+	check("sessions", old.Sessions != new.Sessions)
+	check("prompt_canary", old.PromptCanary != new.PromptCanary)
+	check("refusal_cache", old.RefusalCache != new.RefusalCache)
+	check("scan", old.Scan != new.Scan)
+	check("explanations", old.Explanations != new.Explanations)
+	check("mask_samples", old.MaskSamples != new.MaskSamples)
+	check("answer_cache", old.AnswerCache.TTL != new.AnswerCache.TTL || old.AnswerCache.Size != new.AnswerCache.Size ||
+		!maps.Equal(old.AnswerCache.Tenants, new.AnswerCache.Tenants))
+	check("pii", old.PII.DetectorURL != new.PII.DetectorURL || old.PII.MinScore != new.PII.MinScore ||
+		old.PII.Language != new.PII.Language || !slices.Equal(old.PII.Entities, new.PII.Entities))
+	return changed
+}
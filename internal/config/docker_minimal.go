@@ -0,0 +1,7 @@
+//go:build minimal
+
+package config
+
+// dockerSupported reports whether this build can run Docker sandboxes;
+// minimal builds (-tags minimal) cannot.
+const dockerSupported = false
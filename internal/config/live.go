@@ -0,0 +1,29 @@
+package config
+
+import "sync/atomic"
+
+// Live holds the Runtime settings in effect. Each request reads one
+// snapshot; a reload replaces it in a single step.
+type Live struct {
+	cur atomic.Pointer[Runtime]
+}
+
+// NewLive returns a Live holding rt.
+func NewLive(rt Runtime) *Live {
+	l := &Live{}
+	l.Store(rt)
+	return l
+}
+
+// Load returns the current settings. A nil Live returns the defaults.
+func (l *Live) Load() Runtime {
+	if l == nil {
+		return Default().Runtime
+	}
+	return *l.cur.Load()
+}
+
+// Store replaces the current settings.
+func (l *Live) Store(rt Runtime) {
+	l.cur.Store(&rt)
+}
@@ -0,0 +1,105 @@
+// Package datastore keeps external documents that were registered once via
+// POST /v1/data, already scanned and masked, so chat requests can reference
+// them by ID instead of re-uploading them.
+package datastore
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/shivansh-source/nopass/internal/types"
+)
+
+// ErrNotFound is returned for unknown IDs or IDs owned by another tenant.
+var ErrNotFound = errors.New("document not found")
+
+// Document is a registered external data block. Content is stored masked;
+// the original is never kept, only its hash.
+type Document struct {
+	ID          string          `json:"id"`
+	TenantID    string          `json:"tenant_id,omitempty"`
+	Source      string          `json:"source"`
+	Type        string          `json:"type"`
+	Content     string          `json:"-"`
+	ContentHash string          `json:"content_hash"` // sha256 of the original, unmasked content
+	RiskLevel   types.RiskLevel `json:"risk_level"`
+	Flags       []string        `json:"flags,omitempty"`
+	IsDangerous bool            `json:"is_dangerous"`
+	CreatedAt   time.Time       `json:"created_at"`
+	// PolicyVersion and ScannedAt describe the latest scan.
+	PolicyVersion string    `json:"policy_version"`
+	ScannedAt     time.Time `json:"scanned_at"`
+}
+
+// Store persists registered documents.
+type Store interface {
+	Put(doc *Document) error
+	// Get returns the document if it exists and belongs to tenantID.
+	Get(tenantID, id string) (*Document, error)
+	// List returns every stored document across tenants.
+	List() ([]*Document, error)
+}
+
+// HashContent returns the hex sha256 of content.
+func HashContent(content string) string {
+	sum := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(sum[:])
+}
+
+// NewID returns a random document ID.
+func NewID() string {
+	var b [12]byte
+	_, _ = rand.Read(b[:])
+	return "doc_" + hex.EncodeToString(b[:])
+}
+
+// MemoryStore is an in-process Store, suitable for a single gateway.
+type MemoryStore struct {
+	mu   sync.RWMutex
+	docs map[string]*Document
+}
+
+// NewMemoryStore creates an empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{docs: make(map[string]*Document)}
+}
+
+// Put implements Store.
+func (s *MemoryStore) Put(doc *Document) error {
+	if doc.ID == "" {
+		return errors.New("document id is required")
+	}
+	cp := *doc
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.docs[doc.ID] = &cp
+	return nil
+}
+
+// Get implements Store.
+func (s *MemoryStore) Get(tenantID, id string) (*Document, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	doc, ok := s.docs[id]
+	if !ok || doc.TenantID != tenantID {
+		return nil, ErrNotFound
+	}
+	cp := *doc
+	return &cp, nil
+}
+
+// List implements Store.
+func (s *MemoryStore) List() ([]*Document, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	out := make([]*Document, 0, len(s.docs))
+	for _, doc := range s.docs {
+		cp := *doc
+		out = append(out, &cp)
+	}
+	return out, nil
+}
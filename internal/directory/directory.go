@@ -0,0 +1,229 @@
+// Package directory holds the tenants and users provisioned by the
+// customer's identity provider (see package scim), with the trust level and
+// policy assigned to each. Deactivated tenants and users are refused by the
+// gateway.
+package directory
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/shivansh-source/nopass/internal/storage"
+)
+
+// ErrNotFound is returned for unknown tenants and users.
+var ErrNotFound = storage.ErrNotFound
+
+// ErrInactive is returned by CheckActive for deactivated tenants or users.
+var ErrInactive = errors.New("directory: deactivated")
+
+// TrustLevel grades how much a user is trusted; policies may relax or
+// tighten checks by trust level.
+type TrustLevel string
+
+const (
+	TrustLow      TrustLevel = "low"
+	TrustStandard TrustLevel = "standard"
+	TrustElevated TrustLevel = "elevated"
+)
+
+// ParseTrustLevel validates a trust level; empty means TrustStandard.
+func ParseTrustLevel(s string) (TrustLevel, error) {
+	switch t := TrustLevel(s); t {
+	case "":
+		return TrustStandard, nil
+	case TrustLow, TrustStandard, TrustElevated:
+		return t, nil
+	}
+	return "", fmt.Errorf("unknown trust level %q (want low, standard or elevated)", s)
+}
+
+// Tenant is a provisioned customer organisation.
+type Tenant struct {
+	ID         string    `json:"id"`
+	ExternalID string    `json:"external_id,omitempty"`
+	Name       string    `json:"name"`
+	Active     bool      `json:"active"`
+	PolicyID   string    `json:"policy_id,omitempty"`
+	Created    time.Time `json:"created"`
+	Modified   time.Time `json:"modified"`
+}
+
+// User is a provisioned end user. UserName is what clients send as
+// user_id in chat requests.
+type User struct {
+	ID          string     `json:"id"`
+	ExternalID  string     `json:"external_id,omitempty"`
+	UserName    string     `json:"user_name"`
+	DisplayName string     `json:"display_name,omitempty"`
+	Email       string     `json:"email,omitempty"`
+	TenantID    string     `json:"tenant_id"`
+	Active      bool       `json:"active"`
+	TrustLevel  TrustLevel `json:"trust_level"`
+	PolicyID    string     `json:"policy_id,omitempty"`
+	Created     time.Time  `json:"created"`
+	Modified    time.Time  `json:"modified"`
+}
+
+// Record collections.
+const (
+	tenantsCollection   = "directory.tenants"
+	usersCollection     = "directory.users"
+	userNamesCollection = "directory.user_names" // tenant/userName -> user ID
+)
+
+// Directory stores tenants and users in the shared record store.
+type Directory struct {
+	Records storage.RecordStore
+}
+
+func (d *Directory) put(ctx context.Context, collection, id string, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return d.Records.PutRecord(ctx, collection, id, data)
+}
+
+func (d *Directory) get(ctx context.Context, collection, id string, v any) error {
+	data, err := d.Records.GetRecord(ctx, collection, id)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// PutTenant creates or replaces a tenant.
+func (d *Directory) PutTenant(ctx context.Context, t *Tenant) error {
+	return d.put(ctx, tenantsCollection, t.ID, t)
+}
+
+// GetTenant returns a tenant by ID.
+func (d *Directory) GetTenant(ctx context.Context, id string) (*Tenant, error) {
+	var t Tenant
+	if err := d.get(ctx, tenantsCollection, id, &t); err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// ListTenants returns every tenant, ordered by ID.
+func (d *Directory) ListTenants(ctx context.Context) ([]Tenant, error) {
+	raw, err := d.Records.ListRecords(ctx, tenantsCollection)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]Tenant, 0, len(raw))
+	for _, data := range raw {
+		var t Tenant
+		if err := json.Unmarshal(data, &t); err != nil {
+			return nil, err
+		}
+		out = append(out, t)
+	}
+	return out, nil
+}
+
+// DeleteTenant removes a tenant. Its users are not removed; IdPs
+// deprovision them separately.
+func (d *Directory) DeleteTenant(ctx context.Context, id string) error {
+	return d.Records.DeleteRecord(ctx, tenantsCollection, id)
+}
+
+func userNameKey(tenantID, userName string) string {
+	return tenantID + "/" + userName
+}
+
+// PutUser creates or replaces a user, keeping the user-name index in sync.
+// User names are unique per tenant.
+func (d *Directory) PutUser(ctx context.Context, u *User) error {
+	if existing, err := d.UserByName(ctx, u.TenantID, u.UserName); err == nil && existing.ID != u.ID {
+		return fmt.Errorf("directory: user name %q already exists in tenant %q", u.UserName, u.TenantID)
+	}
+	if old, err := d.GetUser(ctx, u.ID); err == nil &&
+		(old.UserName != u.UserName || old.TenantID != u.TenantID) {
+		if err := d.Records.DeleteRecord(ctx, userNamesCollection, userNameKey(old.TenantID, old.UserName)); err != nil {
+			return err
+		}
+	}
+	if err := d.put(ctx, usersCollection, u.ID, u); err != nil {
+		return err
+	}
+	return d.Records.PutRecord(ctx, userNamesCollection, userNameKey(u.TenantID, u.UserName), []byte(u.ID))
+}
+
+// GetUser returns a user by ID.
+func (d *Directory) GetUser(ctx context.Context, id string) (*User, error) {
+	var u User
+	if err := d.get(ctx, usersCollection, id, &u); err != nil {
+		return nil, err
+	}
+	return &u, nil
+}
+
+// UserByName returns the tenant's user with the given user name.
+func (d *Directory) UserByName(ctx context.Context, tenantID, userName string) (*User, error) {
+	id, err := d.Records.GetRecord(ctx, userNamesCollection, userNameKey(tenantID, userName))
+	if err != nil {
+		return nil, err
+	}
+	return d.GetUser(ctx, string(id))
+}
+
+// ListUsers returns every user, ordered by ID.
+func (d *Directory) ListUsers(ctx context.Context) ([]User, error) {
+	raw, err := d.Records.ListRecords(ctx, usersCollection)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]User, 0, len(raw))
+	for _, data := range raw {
+		var u User
+		if err := json.Unmarshal(data, &u); err != nil {
+			return nil, err
+		}
+		out = append(out, u)
+	}
+	return out, nil
+}
+
+// DeleteUser removes a user.
+func (d *Directory) DeleteUser(ctx context.Context, id string) error {
+	u, err := d.GetUser(ctx, id)
+	if err != nil {
+		return err
+	}
+	if err := d.Records.DeleteRecord(ctx, userNamesCollection, userNameKey(u.TenantID, u.UserName)); err != nil {
+		return err
+	}
+	return d.Records.DeleteRecord(ctx, usersCollection, id)
+}
+
+// CheckActive returns ErrInactive if the tenant or the user (by user name)
+// was provisioned and is now deactivated. Tenants and users the IdP never
+// provisioned are not restricted here.
+func (d *Directory) CheckActive(ctx context.Context, tenantID, userName string) error {
+	t, err := d.GetTenant(ctx, tenantID)
+	switch {
+	case err == nil && !t.Active:
+		return fmt.Errorf("%w: tenant %q", ErrInactive, tenantID)
+	case err != nil && !errors.Is(err, ErrNotFound):
+		return err
+	}
+	if userName == "" {
+		return nil
+	}
+	u, err := d.UserByName(ctx, tenantID, userName)
+	switch {
+	case errors.Is(err, ErrNotFound):
+		return nil
+	case err != nil:
+		return err
+	case !u.Active:
+		return fmt.Errorf("%w: user %q", ErrInactive, userName)
+	}
+	return nil
+}
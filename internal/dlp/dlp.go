@@ -0,0 +1,376 @@
+// Package dlp labels text with data classes (source code, financial
+// records, health data, ...) using regex and dictionary packs, and decides
+// what the configured rules require for the classes found.
+package dlp
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/shivansh-source/nopass/internal/types"
+)
+
+// Class is one data class. Text is labelled with it when its patterns and
+// keywords together match at least Threshold times.
+type Class struct {
+	Name      string   `json:"name"`
+	Patterns  []string `json:"patterns,omitempty"` // regular expressions
+	Keywords  []string `json:"keywords,omitempty"` // matched case-insensitively on word boundaries
+	Threshold int      `json:"threshold,omitempty"`
+	// Validate, if set, must accept a pattern match for it to count.
+	Validate func(match string) bool `json:"-"`
+}
+
+// Pack is a named set of classes, enabled as a whole.
+type Pack struct {
+	Name    string  `json:"name"`
+	Classes []Class `json:"classes"`
+}
+
+// Built-in pack names.
+const (
+	PackSourceCode = "source_code"
+	PackFinancial  = "financial"
+	PackHealth     = "health"
+)
+
+// Builtin returns the built-in packs by name.
+func Builtin() map[string]Pack {
+	return map[string]Pack{
+		PackSourceCode: {Name: PackSourceCode, Classes: []Class{{
+			Name: "source_code",
+			Patterns: []string{
+				`(?m)^\s*(?:func|def|class|import|package|public|private|static|#include|#!/)\b`,
+				`(?m)[{};]\s*$`,
+				`(?:=>|::|->|!==|===|\+\+|&&|\|\|)`,
+				`\b(?:return|const|var|let|void|int|fn)\s+\w+`,
+				`-----BEGIN [A-Z ]*PRIVATE KEY-----`,
+			},
+			Threshold: 4,
+		}}},
+		PackFinancial: {Name: PackFinancial, Classes: []Class{{
+			Name: "financial",
+			Patterns: []string{
+				`\b(?:\d[ -]?){12,18}\d\b`,                // payment card number
+				`\b[A-Z]{2}\d{2}(?: ?[A-Z0-9]{4}){2,7}\b`, // IBAN
+			},
+			Keywords: []string{
+				"account number", "routing number", "sort code", "iban", "swift",
+				"wire transfer", "balance sheet", "income statement", "invoice",
+				"tax return", "payroll", "salary", "credit limit", "ledger",
+			},
+			Threshold: 2,
+			Validate:  luhnOrIBAN,
+		}}},
+		PackHealth: {Name: PackHealth, Classes: []Class{{
+			Name: "health",
+			Patterns: []string{
+				`\b[A-TV-Z]\d{2}\.\d{1,4}\b`, // ICD-10 code
+				`\bMRN[:#]?\s*\d{6,}\b`,      // medical record number
+			},
+			Keywords: []string{
+				"diagnosis", "diagnosed", "patient", "prescription", "prescribed",
+				"medical record", "medication", "dosage", "blood pressure",
+				"chemotherapy", "hiv", "mental health", "psychiatric", "lab results",
+				"icd-10", "treatment plan",
+			},
+			Threshold: 2,
+		}}},
+	}
+}
+
+// LoadPacks reads custom packs from a JSON file: {"packs": [Pack, ...]}.
+func LoadPacks(path string) ([]Pack, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read DLP packs file: %w", err)
+	}
+	var file struct {
+		Packs []Pack `json:"packs"`
+	}
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("parse DLP packs file: %w", err)
+	}
+	return file.Packs, nil
+}
+
+// Direction is which side of the model a rule applies to.
+type Direction string
+
+const (
+	Input  Direction = "input"  // the user message, history and external data
+	Output Direction = "output" // the final answer
+)
+
+// Action is what a rule requires.
+type Action string
+
+const (
+	ActionNone  Action = ""
+	ActionSlow  Action = "slow"  // force the slow path (input only)
+	ActionBlock Action = "block" // refuse the request, or withhold the answer
+)
+
+// Rule applies Action when Class is found in Direction. An empty Path
+// matches both paths.
+type Rule struct {
+	Class     string
+	Direction Direction
+	Path      types.Path
+	Action    Action
+}
+
+// ParseRules parses a comma-separated list of class:direction[:path]=action
+// entries, e.g. "health:output:fast=block,source_code:input=slow".
+func ParseRules(spec string) ([]Rule, error) {
+	var rules []Rule
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		lhs, action, ok := strings.Cut(entry, "=")
+		parts := strings.Split(lhs, ":")
+		if !ok || len(parts) < 2 || len(parts) > 3 || parts[0] == "" {
+			return nil, fmt.Errorf("DLP rule %q: want class:direction[:path]=action", entry)
+		}
+		r := Rule{Class: parts[0], Direction: Direction(parts[1]), Action: Action(action)}
+		if len(parts) == 3 {
+			r.Path = types.Path(parts[2])
+		}
+		switch r.Direction {
+		case Input, Output:
+		default:
+			return nil, fmt.Errorf("DLP rule %q: unknown direction %q", entry, parts[1])
+		}
+		switch r.Path {
+		case "", types.PathFast, types.PathSlow:
+		default:
+			return nil, fmt.Errorf("DLP rule %q: unknown path %q", entry, r.Path)
+		}
+		switch r.Action {
+		case ActionBlock:
+		case ActionSlow:
+			if r.Direction != Input {
+				return nil, fmt.Errorf("DLP rule %q: slow applies to input only", entry)
+			}
+		default:
+			return nil, fmt.Errorf("DLP rule %q: unknown action %q", entry, action)
+		}
+		rules = append(rules, r)
+	}
+	return rules, nil
+}
+
+type class struct {
+	name      string
+	patterns  []*regexp.Regexp
+	keywords  *regexp.Regexp
+	threshold int
+	validate  func(string) bool
+}
+
+// Classifier labels text with the classes of its packs and applies rules to
+// the labels. A nil Classifier finds nothing.
+type Classifier struct {
+	classes []class
+	rules   []Rule
+}
+
+// New compiles packs into a Classifier. Every rule must name a class of one
+// of the packs.
+func New(packs []Pack, rules []Rule) (*Classifier, error) {
+	c := &Classifier{rules: rules}
+	known := map[string]bool{}
+	for _, p := range packs {
+		for _, cl := range p.Classes {
+			if cl.Name == "" {
+				return nil, fmt.Errorf("pack %s: class name is required", p.Name)
+			}
+			if len(cl.Patterns) == 0 && len(cl.Keywords) == 0 {
+				return nil, fmt.Errorf("pack %s class %s: no patterns or keywords", p.Name, cl.Name)
+			}
+			cc := class{name: cl.Name, threshold: max(cl.Threshold, 1), validate: cl.Validate}
+			for _, pat := range cl.Patterns {
+				re, err := regexp.Compile(pat)
+				if err != nil {
+					return nil, fmt.Errorf("pack %s class %s: %w", p.Name, cl.Name, err)
+				}
+				cc.patterns = append(cc.patterns, re)
+			}
+			if len(cl.Keywords) > 0 {
+				quoted := make([]string, len(cl.Keywords))
+				for i, k := range cl.Keywords {
+					quoted[i] = regexp.QuoteMeta(strings.ToLower(k))
+				}
+				cc.keywords = regexp.MustCompile(`(?i)\b(?:` + strings.Join(quoted, "|") + `)\b`)
+			}
+			c.classes = append(c.classes, cc)
+			known[cl.Name] = true
+		}
+	}
+	for _, r := range rules {
+		if !known[r.Class] {
+			return nil, fmt.Errorf("DLP rule for unknown class %q", r.Class)
+		}
+	}
+	return c, nil
+}
+
+// maxMatches caps how many matches of one pattern are counted; a class
+// needs only Threshold of them.
+const maxMatches = 16
+
+// Classify returns the sorted names of the classes found in texts.
+func (c *Classifier) Classify(texts ...string) []string {
+	if c == nil {
+		return nil
+	}
+	var found []string
+	for _, cl := range c.classes {
+		if cl.matches(texts) {
+			found = append(found, cl.name)
+		}
+	}
+	sort.Strings(found)
+	return found
+}
+
+func (cl class) matches(texts []string) bool {
+	score := 0
+	for _, t := range texts {
+		for _, re := range cl.patterns {
+			for _, m := range re.FindAllString(t, maxMatches) {
+				if cl.validate == nil || cl.validate(m) {
+					score++
+				}
+			}
+		}
+		if cl.keywords != nil {
+			// Each distinct keyword counts once.
+			seen := map[string]bool{}
+			for _, m := range cl.keywords.FindAllString(t, -1) {
+				seen[strings.ToLower(m)] = true
+			}
+			score += len(seen)
+		}
+		if score >= cl.threshold {
+			return true
+		}
+	}
+	return false
+}
+
+// Decide returns the strictest action the rules require for classes found
+// in dir on path, with the classes that triggered it.
+func (c *Classifier) Decide(dir Direction, path types.Path, classes []string) (Action, []string) {
+	if c == nil {
+		return ActionNone, nil
+	}
+	act, why := ActionNone, []string(nil)
+	for _, r := range c.rules {
+		if r.Direction != dir || (r.Path != "" && r.Path != path) || !contains(classes, r.Class) {
+			continue
+		}
+		switch {
+		case r.Action == act:
+			if !contains(why, r.Class) {
+				why = append(why, r.Class)
+			}
+		case act == ActionNone || r.Action == ActionBlock:
+			act, why = r.Action, []string{r.Class}
+		}
+	}
+	return act, why
+}
+
+// Gates reports whether any rule could act on dir for path, i.e. whether
+// text there must be classified before it is released.
+func (c *Classifier) Gates(dir Direction, path types.Path) bool {
+	if c == nil {
+		return false
+	}
+	for _, r := range c.rules {
+		if r.Direction == dir && (r.Path == "" || r.Path == path) {
+			return true
+		}
+	}
+	return false
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// ErrUnknownPack is returned by Select for a pack name that isn't built in.
+var ErrUnknownPack = errors.New("unknown DLP pack")
+
+// Select returns the named built-in packs from a comma-separated list.
+func Select(names string) ([]Pack, error) {
+	builtin := Builtin()
+	var packs []Pack
+	for _, n := range strings.Split(names, ",") {
+		n = strings.TrimSpace(n)
+		if n == "" {
+			continue
+		}
+		p, ok := builtin[n]
+		if !ok {
+			return nil, fmt.Errorf("%w %q", ErrUnknownPack, n)
+		}
+		packs = append(packs, p)
+	}
+	return packs, nil
+}
+
+// luhnOrIBAN accepts card numbers passing the Luhn check and IBANs passing
+// the mod-97 check.
+func luhnOrIBAN(m string) bool {
+	s := strings.NewReplacer(" ", "", "-", "").Replace(m)
+	if s == "" {
+		return false
+	}
+	if s[0] >= 'A' && s[0] <= 'Z' {
+		return ibanValid(s)
+	}
+	sum, double := 0, false
+	for i := len(s) - 1; i >= 0; i-- {
+		d := int(s[i] - '0')
+		if double {
+			if d *= 2; d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		double = !double
+	}
+	return sum%10 == 0
+}
+
+func ibanValid(s string) bool {
+	if len(s) < 15 || len(s) > 34 {
+		return false
+	}
+	rem := 0
+	for _, r := range s[4:] + s[:4] {
+		switch {
+		case r >= '0' && r <= '9':
+			rem = (rem*10 + int(r-'0')) % 97
+		case r >= 'A' && r <= 'Z':
+			rem = (rem*100 + int(r-'A'+10)) % 97
+		default:
+			return false
+		}
+	}
+	return rem == 1
+}
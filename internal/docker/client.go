@@ -0,0 +1,215 @@
+// Package docker is a client for the Docker Engine API covering what the
+// sandbox needs: images, volumes, containers, their archives and exec. It
+// talks to the daemon's socket directly, so the gateway needs neither the
+// docker CLI installed nor the Docker SDK's dependency tree.
+package docker
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// APIVersion is the Engine API version requested unless
+// DOCKER_API_VERSION says otherwise: Docker 20.10's, so every daemon
+// since then understands the client.
+const APIVersion = "1.41"
+
+// DefaultHost is the daemon's address when DOCKER_HOST is unset.
+const DefaultHost = "unix:///var/run/docker.sock"
+
+// Client is a connection to one Docker daemon. It is safe for concurrent
+// use.
+type Client struct {
+	hc   *http.Client
+	base string // e.g. "http://docker/v1.41"
+	// auths are registry credentials from the CLI's config file.
+	auths map[string]string
+	// err, if set, fails every call: the settings were unusable.
+	err error
+}
+
+// FromEnv returns a client for the daemon the docker CLI would use in
+// the same environment: DOCKER_HOST (unix:// or tcp://, default
+// DefaultHost), DOCKER_TLS_VERIFY with DOCKER_CERT_PATH (default
+// ~/.docker) for TLS, DOCKER_API_VERSION, and registry logins in
+// DOCKER_CONFIG's (default ~/.docker) config.json. CLI contexts and
+// credential helpers aren't read. A malformed setting fails every call
+// with its error.
+func FromEnv() *Client {
+	host := os.Getenv("DOCKER_HOST")
+	if host == "" {
+		host = DefaultHost
+	}
+	version := os.Getenv("DOCKER_API_VERSION")
+	if version == "" {
+		version = APIVersion
+	}
+	home, _ := os.UserHomeDir()
+	var tlsConf *tls.Config
+	if os.Getenv("DOCKER_TLS_VERIFY") != "" {
+		dir := os.Getenv("DOCKER_CERT_PATH")
+		if dir == "" {
+			dir = filepath.Join(home, ".docker")
+		}
+		var err error
+		if tlsConf, err = loadTLS(dir); err != nil {
+			return &Client{err: fmt.Errorf("docker: %w", err)}
+		}
+	}
+	c, err := New(host, version, tlsConf)
+	if err != nil {
+		return &Client{err: err}
+	}
+	configDir := os.Getenv("DOCKER_CONFIG")
+	if configDir == "" {
+		configDir = filepath.Join(home, ".docker")
+	}
+	c.auths = loadAuths(filepath.Join(configDir, "config.json"))
+	return c
+}
+
+// New returns a client for the daemon at host, a unix:// socket path or
+// a tcp:// address, speaking API version. A nil tlsConf means plain HTTP
+// over TCP.
+func New(host, version string, tlsConf *tls.Config) (*Client, error) {
+	scheme, addr, ok := strings.Cut(host, "://")
+	if !ok || addr == "" {
+		return nil, fmt.Errorf("docker: invalid host %q", host)
+	}
+	tr := &http.Transport{
+		TLSClientConfig:     tlsConf,
+		MaxIdleConnsPerHost: 16,
+		IdleConnTimeout:     90 * time.Second,
+	}
+	base := "http://docker"
+	switch scheme {
+	case "unix":
+		tr.DialContext = func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "unix", addr)
+		}
+	case "tcp":
+		base = "http://" + addr
+		if tlsConf != nil {
+			base = "https://" + addr
+		}
+	default:
+		return nil, fmt.Errorf("docker: unsupported host %q: use unix:// or tcp://", host)
+	}
+	return &Client{hc: &http.Client{Transport: tr}, base: base + "/v" + strings.TrimPrefix(version, "v")}, nil
+}
+
+// loadTLS reads the CLI's ca.pem, cert.pem and key.pem from dir.
+func loadTLS(dir string) (*tls.Config, error) {
+	ca, err := os.ReadFile(filepath.Join(dir, "ca.pem"))
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(ca) {
+		return nil, fmt.Errorf("no certificates in %s", filepath.Join(dir, "ca.pem"))
+	}
+	cert, err := tls.LoadX509KeyPair(filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem"))
+	if err != nil {
+		return nil, err
+	}
+	return &tls.Config{RootCAs: pool, Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12}, nil
+}
+
+// Error is a request the daemon refused.
+type Error struct {
+	Status  int
+	Message string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("docker: %s (HTTP %d)", e.Message, e.Status)
+}
+
+// IsNotFound reports whether err is the daemon saying the image,
+// container, exec or volume doesn't exist.
+func IsNotFound(err error) bool {
+	var e *Error
+	return errors.As(err, &e) && e.Status == http.StatusNotFound
+}
+
+// do sends a request and decodes the JSON response into out, if not nil.
+func (c *Client) do(ctx context.Context, method, path string, query url.Values, body, out any) error {
+	resp, err := c.send(ctx, method, path, query, body, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if out == nil {
+		_, _ = io.Copy(io.Discard, resp.Body)
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("docker: decode %s %s: %w", method, path, err)
+	}
+	return nil
+}
+
+// send sends a request with body, an io.Reader sent as a tar archive or
+// anything else sent as JSON, and returns the response if it succeeded.
+func (c *Client) send(ctx context.Context, method, path string, query url.Values, body any, header http.Header) (*http.Response, error) {
+	if c.err != nil {
+		return nil, c.err
+	}
+	var rd io.Reader
+	var contentType string
+	switch b := body.(type) {
+	case nil:
+	case io.Reader:
+		rd, contentType = b, "application/x-tar"
+	default:
+		data, err := json.Marshal(b)
+		if err != nil {
+			return nil, fmt.Errorf("docker: encode %s %s: %w", method, path, err)
+		}
+		rd, contentType = bytes.NewReader(data), "application/json"
+	}
+	u := c.base + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u, rd)
+	if err != nil {
+		return nil, fmt.Errorf("docker: %w", err)
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	resp, err := c.hc.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("docker: %w", err)
+	}
+	if resp.StatusCode >= 400 {
+		defer resp.Body.Close()
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+		var e struct {
+			Message string `json:"message"`
+		}
+		if json.Unmarshal(data, &e) != nil || e.Message == "" {
+			e.Message = strings.TrimSpace(string(data))
+		}
+		return nil, &Error{Status: resp.StatusCode, Message: e.Message}
+	}
+	return resp, nil
+}
@@ -0,0 +1,209 @@
+package docker
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+)
+
+// ContainerConfig is a container to create. Field names are the Engine
+// API's.
+type ContainerConfig struct {
+	Image        string
+	Entrypoint   []string          `json:",omitempty"`
+	Cmd          []string          `json:",omitempty"`
+	Env          []string          `json:",omitempty"`
+	User         string            `json:",omitempty"`
+	Labels       map[string]string `json:",omitempty"`
+	AttachStdout bool
+	AttachStderr bool
+	// OpenStdin gives the container a stdin, which StdinOnce closes when
+	// the first attach to it ends; AttachStdin attaches to it.
+	AttachStdin bool `json:",omitempty"`
+	OpenStdin   bool `json:",omitempty"`
+	StdinOnce   bool `json:",omitempty"`
+	HostConfig  HostConfig
+}
+
+// HostConfig is how a container runs: its mounts, network, resource
+// limits and security options. Zero values leave the daemon's defaults.
+type HostConfig struct {
+	NetworkMode string  `json:",omitempty"`
+	Mounts      []Mount `json:",omitempty"`
+	// Memory limits the container's memory in bytes; MemorySwap is memory
+	// plus swap, equal to Memory for no swap.
+	Memory     int64 `json:",omitempty"`
+	MemorySwap int64 `json:",omitempty"`
+	// NanoCPUs is CPU time in billionths of a CPU: 5e8 for half a CPU.
+	NanoCPUs  int64 `json:"NanoCpus,omitempty"`
+	PidsLimit int64 `json:",omitempty"`
+	// ReadonlyRootfs mounts the image's filesystem read-only; mounts and
+	// Tmpfs stay writable.
+	ReadonlyRootfs bool `json:",omitempty"`
+	// SecurityOpt holds e.g. "no-new-privileges" and "seccomp=<profile
+	// JSON>".
+	SecurityOpt []string `json:",omitempty"`
+	CapDrop     []string `json:",omitempty"`
+	UsernsMode  string   `json:",omitempty"`
+	// Tmpfs maps paths to tmpfs mount options, e.g. "size=64m".
+	Tmpfs map[string]string `json:",omitempty"`
+	// Runtime is the OCI runtime, e.g. "runsc"; empty for the default.
+	Runtime string `json:",omitempty"`
+}
+
+// Mount is a bind mount, a named volume, or an anonymous volume (a
+// volume without Source, removed with its container).
+type Mount struct {
+	Type     string // "bind" or "volume"
+	Source   string `json:",omitempty"`
+	Target   string
+	ReadOnly bool `json:",omitempty"`
+}
+
+// ContainerCreate creates a container and returns its ID.
+func (c *Client) ContainerCreate(ctx context.Context, cfg ContainerConfig) (string, error) {
+	var created struct {
+		ID string `json:"Id"`
+	}
+	err := c.do(ctx, http.MethodPost, "/containers/create", nil, cfg, &created)
+	return created.ID, err
+}
+
+// ContainerStart starts a created container.
+func (c *Client) ContainerStart(ctx context.Context, id string) error {
+	return c.do(ctx, http.MethodPost, "/containers/"+id+"/start", nil, nil, nil)
+}
+
+// ContainerAttach returns the output of a container created with
+// AttachStdout and AttachStderr and, with stdin, a stream that writes to
+// its stdin (see ContainerConfig.OpenStdin). Attach before starting the
+// container to get all of it.
+func (c *Client) ContainerAttach(ctx context.Context, id string, stdin bool) (*Stream, error) {
+	q := url.Values{"stream": {"1"}, "stdout": {"1"}, "stderr": {"1"}}
+	if stdin {
+		q.Set("stdin", "1")
+	}
+	return c.hijack(ctx, "/containers/"+id+"/attach", q, nil)
+}
+
+// ContainerWait waits for a started container to stop and returns its
+// exit code.
+func (c *Client) ContainerWait(ctx context.Context, id string) (int, error) {
+	var res struct {
+		StatusCode int
+	}
+	err := c.do(ctx, http.MethodPost, "/containers/"+id+"/wait", nil, nil, &res)
+	return res.StatusCode, err
+}
+
+// Container is a container's inspected state.
+type Container struct {
+	ID    string `json:"Id"`
+	State ContainerState
+}
+
+// ContainerState is how a container is running, or how it ended.
+type ContainerState struct {
+	Status    string // "created", "running", "exited", ...
+	Running   bool
+	ExitCode  int
+	OOMKilled bool
+	// Error is the daemon's error starting or running the container.
+	Error      string
+	StartedAt  string
+	FinishedAt string
+}
+
+// ContainerInspect returns the container id.
+func (c *Client) ContainerInspect(ctx context.Context, id string) (Container, error) {
+	var ct Container
+	err := c.do(ctx, http.MethodGet, "/containers/"+id+"/json", nil, nil, &ct)
+	return ct, err
+}
+
+// ContainerRemove removes the container id, stopping it if need be, and
+// its anonymous volumes.
+func (c *Client) ContainerRemove(ctx context.Context, id string) error {
+	return c.do(ctx, http.MethodDelete, "/containers/"+id, url.Values{"force": {"1"}, "v": {"1"}}, nil, nil)
+}
+
+// ContainerList returns the IDs of the containers, running or not,
+// carrying label ("key=value" or "key").
+func (c *Client) ContainerList(ctx context.Context, label string) ([]string, error) {
+	filters, _ := json.Marshal(map[string][]string{"label": {label}})
+	var list []struct {
+		ID string `json:"Id"`
+	}
+	if err := c.do(ctx, http.MethodGet, "/containers/json", url.Values{"all": {"1"}, "filters": {string(filters)}}, nil, &list); err != nil {
+		return nil, err
+	}
+	ids := make([]string, len(list))
+	for i, ct := range list {
+		ids[i] = ct.ID
+	}
+	return ids, nil
+}
+
+// CopyTo extracts the tar archive into the directory path of container
+// id, which must exist (in the image or as a mount).
+func (c *Client) CopyTo(ctx context.Context, id, path string, archive io.Reader) error {
+	return c.do(ctx, http.MethodPut, "/containers/"+id+"/archive", url.Values{"path": {path}}, archive, nil)
+}
+
+// CopyFrom returns path of container id as a tar archive whose entries
+// start with path's last element.
+func (c *Client) CopyFrom(ctx context.Context, id, path string) (io.ReadCloser, error) {
+	resp, err := c.send(ctx, http.MethodGet, "/containers/"+id+"/archive", url.Values{"path": {path}}, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// ExecConfig is a command to run in a container.
+type ExecConfig struct {
+	Cmd []string
+	Env []string `json:",omitempty"`
+	// AttachStdin makes the exec's stream write to the command's stdin.
+	AttachStdin  bool `json:",omitempty"`
+	AttachStdout bool
+	AttachStderr bool
+}
+
+// ExecCreate prepares cfg to run in the running container id, with its
+// output attached, and returns the exec's ID.
+func (c *Client) ExecCreate(ctx context.Context, id string, cfg ExecConfig) (string, error) {
+	var created struct {
+		ID string `json:"Id"`
+	}
+	cfg.AttachStdout, cfg.AttachStderr = true, true
+	err := c.do(ctx, http.MethodPost, "/containers/"+id+"/exec", nil, cfg, &created)
+	return created.ID, err
+}
+
+// ExecStart runs the exec and returns its output.
+func (c *Client) ExecStart(ctx context.Context, execID string) (*Stream, error) {
+	return c.hijack(ctx, "/exec/"+execID+"/start", nil, map[string]bool{"Detach": false, "Tty": false})
+}
+
+// ExecInspect returns an exec's exit code, once it has finished.
+func (c *Client) ExecInspect(ctx context.Context, execID string) (running bool, exitCode int, err error) {
+	var res struct {
+		Running  bool
+		ExitCode int
+	}
+	err = c.do(ctx, http.MethodGet, "/exec/"+execID+"/json", nil, nil, &res)
+	return res.Running, res.ExitCode, err
+}
+
+// VolumeCreate creates the named volume.
+func (c *Client) VolumeCreate(ctx context.Context, name string) error {
+	return c.do(ctx, http.MethodPost, "/volumes/create", nil, map[string]string{"Name": name}, nil)
+}
+
+// VolumeRemove removes the named volume.
+func (c *Client) VolumeRemove(ctx context.Context, name string) error {
+	return c.do(ctx, http.MethodDelete, "/volumes/"+url.PathEscape(name), url.Values{"force": {"1"}}, nil, nil)
+}
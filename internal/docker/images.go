@@ -0,0 +1,209 @@
+package docker
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// Version is the daemon's version.
+type Version struct {
+	Version    string
+	APIVersion string `json:"ApiVersion"`
+	Os         string
+	Arch       string
+}
+
+// Version asks the daemon its version, which doubles as a health check.
+func (c *Client) Version(ctx context.Context) (Version, error) {
+	var v Version
+	err := c.do(ctx, http.MethodGet, "/version", nil, nil, &v)
+	return v, err
+}
+
+// Info is the daemon's system information, as far as the sandbox needs
+// it.
+type Info struct {
+	// Runtimes are the OCI runtimes containers can be created with, by
+	// name: runc and any configured, such as runsc (gVisor) or kata.
+	Runtimes       map[string]json.RawMessage
+	DefaultRuntime string
+}
+
+// Info returns the daemon's system information.
+func (c *Client) Info(ctx context.Context) (Info, error) {
+	var info Info
+	err := c.do(ctx, http.MethodGet, "/info", nil, nil, &info)
+	return info, err
+}
+
+// Image is a local image.
+type Image struct {
+	ID     string `json:"Id"`
+	Config ImageConfig
+}
+
+// ImageConfig is what an image runs by default.
+type ImageConfig struct {
+	Entrypoint []string
+	Cmd        []string
+	Labels     map[string]string
+}
+
+// ImageInspect returns the local image ref.
+func (c *Client) ImageInspect(ctx context.Context, ref string) (Image, error) {
+	var img Image
+	err := c.do(ctx, http.MethodGet, "/images/"+url.PathEscape(ref)+"/json", nil, nil, &img)
+	return img, err
+}
+
+// ImagePull pulls ref (name:tag or name@digest; the tag defaults to
+// latest), with the CLI's saved login for its registry if there is one.
+func (c *Client) ImagePull(ctx context.Context, ref string) error {
+	name, tag := splitRef(ref)
+	var header http.Header
+	if auth := c.auths[registryOf(name)]; auth != "" {
+		header = http.Header{"X-Registry-Auth": {auth}}
+	}
+	resp, err := c.send(ctx, http.MethodPost, "/images/create", url.Values{"fromImage": {name}, "tag": {tag}}, nil, header)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	// The daemon streams progress messages; a failure is one of them.
+	dec := json.NewDecoder(resp.Body)
+	for {
+		var msg struct {
+			Error string `json:"error"`
+		}
+		if err := dec.Decode(&msg); errors.Is(err, io.EOF) {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("docker: pull %s: %w", ref, err)
+		}
+		if msg.Error != "" {
+			return fmt.Errorf("docker: pull %s: %s", ref, msg.Error)
+		}
+	}
+}
+
+// splitRef splits an image reference into the repository and the tag
+// or digest to pull.
+func splitRef(ref string) (name, tag string) {
+	name, digest, pinned := strings.Cut(ref, "@")
+	if i := strings.LastIndex(name, ":"); i > strings.LastIndex(name, "/") {
+		name, tag = name[:i], name[i+1:]
+	}
+	if pinned {
+		return name, digest
+	}
+	if tag == "" {
+		tag = "latest"
+	}
+	return name, tag
+}
+
+// hubAuthKey is the config.json key of Docker Hub's login.
+const hubAuthKey = "https://index.docker.io/v1/"
+
+// registryOf returns the config.json key of the registry holding name.
+func registryOf(name string) string {
+	first, _, ok := strings.Cut(name, "/")
+	if ok && (strings.ContainsAny(first, ".:") || first == "localhost") {
+		if first == "docker.io" || first == "index.docker.io" {
+			return hubAuthKey
+		}
+		return first
+	}
+	return hubAuthKey
+}
+
+// loadAuths reads the registry logins saved by docker login in the CLI
+// config file at path, as X-Registry-Auth header values. Logins kept by
+// a credential helper aren't in the file: images needing one must be
+// pulled beforehand.
+func loadAuths(path string) map[string]string {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	var conf struct {
+		Auths map[string]struct {
+			Auth string `json:"auth"`
+		} `json:"auths"`
+	}
+	if json.Unmarshal(data, &conf) != nil {
+		return nil
+	}
+	auths := make(map[string]string)
+	for registry, a := range conf.Auths {
+		raw, err := base64.StdEncoding.DecodeString(a.Auth)
+		if err != nil {
+			continue
+		}
+		user, pass, ok := strings.Cut(string(raw), ":")
+		if !ok {
+			continue
+		}
+		key := strings.TrimSuffix(strings.TrimPrefix(strings.TrimPrefix(registry, "https://"), "http://"), "/")
+		if key == "index.docker.io/v1" || key == "docker.io" || key == "index.docker.io" {
+			key = hubAuthKey
+		}
+		header, _ := json.Marshal(map[string]string{"username": user, "password": pass, "serveraddress": registry})
+		auths[key] = base64.URLEncoding.EncodeToString(header)
+	}
+	return auths
+}
+
+// ImageBuild builds an image from buildContext, a tar archive holding a
+// Dockerfile, and tags it tag. It returns the image's ID.
+func (c *Client) ImageBuild(ctx context.Context, buildContext io.Reader, tag string) (string, error) {
+	resp, err := c.send(ctx, http.MethodPost, "/build", url.Values{"t": {tag}, "rm": {"1"}, "forcerm": {"1"}}, buildContext, nil)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	// As for pulls, progress and failures are streamed; the ID comes in
+	// an aux message.
+	var id string
+	dec := json.NewDecoder(resp.Body)
+	for {
+		var msg struct {
+			Error string `json:"error"`
+			Aux   struct {
+				ID string `json:"ID"`
+			} `json:"aux"`
+		}
+		if err := dec.Decode(&msg); errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return "", fmt.Errorf("docker: build %s: %w", tag, err)
+		}
+		if msg.Error != "" {
+			return "", fmt.Errorf("docker: build %s: %s", tag, msg.Error)
+		}
+		if msg.Aux.ID != "" {
+			id = msg.Aux.ID
+		}
+	}
+	if id == "" {
+		img, err := c.ImageInspect(ctx, tag)
+		if err != nil {
+			return "", err
+		}
+		id = img.ID
+	}
+	return id, nil
+}
+
+// ImageTag tags the local image ref as repo:tag.
+func (c *Client) ImageTag(ctx context.Context, ref, repo, tag string) error {
+	return c.do(ctx, http.MethodPost, "/images/"+url.PathEscape(ref)+"/tag", url.Values{"repo": {repo}, "tag": {tag}}, nil, nil)
+}
@@ -0,0 +1,125 @@
+package docker
+
+import (
+	"archive/tar"
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+)
+
+// Stream is the output of an attached container or exec, stdout and
+// stderr multiplexed as the daemon sends them for containers without a
+// TTY.
+type Stream struct {
+	body io.ReadCloser
+}
+
+// hijack sends a request the daemon answers by turning the connection
+// into a raw stream.
+func (c *Client) hijack(ctx context.Context, path string, query url.Values, body any) (*Stream, error) {
+	header := http.Header{"Connection": {"Upgrade"}, "Upgrade": {"tcp"}}
+	resp, err := c.send(ctx, http.MethodPost, path, query, body, header)
+	if err != nil {
+		return nil, err
+	}
+	return &Stream{body: resp.Body}, nil
+}
+
+// Copy writes the stream's stdout and stderr to the writers until the
+// stream ends, ctx is done or a writer fails.
+func (s *Stream) Copy(ctx context.Context, stdout, stderr io.Writer) error {
+	// A hijacked connection no longer watches the request's context.
+	stop := context.AfterFunc(ctx, func() { s.body.Close() })
+	defer stop()
+	var hdr [8]byte
+	for {
+		if _, err := io.ReadFull(s.body, hdr[:]); err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+		w := stdout
+		if hdr[0] == 2 {
+			w = stderr
+		}
+		if _, err := io.CopyN(w, s.body, int64(binary.BigEndian.Uint32(hdr[4:]))); err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return err
+		}
+	}
+}
+
+// Write writes p to the stdin of a container or exec attached with stdin.
+// The daemon hands the connection over with a 101 response, whose body
+// net/http makes writable.
+func (s *Stream) Write(p []byte) (int, error) {
+	w, ok := s.body.(io.Writer)
+	if !ok {
+		return 0, errors.New("docker: stream has no stdin")
+	}
+	return w.Write(p)
+}
+
+// Close ends the stream.
+func (s *Stream) Close() error {
+	return s.body.Close()
+}
+
+// TarDir returns the contents of dir, its regular files and directories,
+// as a tar archive for CopyTo.
+func TarDir(dir string) io.ReadCloser {
+	pr, pw := io.Pipe()
+	go func() {
+		tw := tar.NewWriter(pw)
+		err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+			if err != nil || path == dir {
+				return err
+			}
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+			if !info.Mode().IsRegular() && !info.IsDir() {
+				return nil
+			}
+			rel, err := filepath.Rel(dir, path)
+			if err != nil {
+				return err
+			}
+			hdr, err := tar.FileInfoHeader(info, "")
+			if err != nil {
+				return err
+			}
+			hdr.Name = filepath.ToSlash(rel)
+			// The files are for whatever user the image runs as.
+			hdr.Uid, hdr.Gid, hdr.Uname, hdr.Gname = 0, 0, "", ""
+			if err := tw.WriteHeader(hdr); err != nil || info.IsDir() {
+				return err
+			}
+			f, err := os.Open(path)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			_, err = io.Copy(tw, f)
+			return err
+		})
+		if err == nil {
+			err = tw.Close()
+		}
+		pw.CloseWithError(err)
+	}()
+	return pr
+}
@@ -0,0 +1,83 @@
+// Package events publishes security-relevant events (e.g. a stored document
+// newly flagged as dangerous) to operators and downstream systems.
+package events
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+)
+
+// Event is a single notification.
+type Event struct {
+	Type     string            `json:"type"` // e.g. "document.flagged"
+	TenantID string            `json:"tenant_id,omitempty"`
+	Subject  string            `json:"subject"` // e.g. the document ID
+	Time     time.Time         `json:"time"`
+	Details  map[string]string `json:"details,omitempty"`
+}
+
+// Sink receives events. Emit must not block the caller for long.
+type Sink interface {
+	Emit(ctx context.Context, e Event) error
+}
+
+// LogSink writes events to the standard logger.
+type LogSink struct{}
+
+// Emit implements Sink.
+func (LogSink) Emit(_ context.Context, e Event) error {
+	log.Printf("event %s tenant=%s subject=%s details=%v", e.Type, e.TenantID, e.Subject, e.Details)
+	return nil
+}
+
+// WebhookSink POSTs each event as JSON to a URL.
+type WebhookSink struct {
+	URL        string
+	HTTPClient *http.Client
+}
+
+// NewWebhookSink creates a WebhookSink.
+func NewWebhookSink(url string) *WebhookSink {
+	return &WebhookSink{URL: url, HTTPClient: &http.Client{Timeout: 3 * time.Second}}
+}
+
+// Emit implements Sink.
+func (s *WebhookSink) Emit(ctx context.Context, e Event) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("marshal event: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.URL, bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("create event request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := s.HTTPClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("deliver event: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("event webhook returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// Multi fans an event out to several sinks, logging individual failures.
+type Multi []Sink
+
+// Emit implements Sink.
+func (m Multi) Emit(ctx context.Context, e Event) error {
+	for _, s := range m {
+		if err := s.Emit(ctx, e); err != nil {
+			log.Printf("event sink error (%s): %v", e.Type, err)
+		}
+	}
+	return nil
+}
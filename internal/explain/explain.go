@@ -0,0 +1,348 @@
+// Package explain tells end users why a request of theirs was blocked or
+// its answer changed, in terms safe to show them. A request's risk and
+// output flags are mapped to a few broad categories, each worded by a
+// template, with appeal instructions quoting the request ID. Flags,
+// scores, detector names and policy rules never appear in an
+// explanation, so it can't be used to probe the detectors.
+package explain
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/shivansh-source/nopass/internal/audit"
+	"github.com/shivansh-source/nopass/internal/types"
+)
+
+// Outcomes of a request.
+const (
+	OutcomeBlocked  = "blocked"
+	OutcomeModified = "modified"
+	OutcomeAllowed  = "allowed"
+)
+
+// Category codes. They are part of the API: clients may key their own UI
+// on them.
+const (
+	CategoryManipulation     = "manipulation"      // prompt injection, jailbreak attempts
+	CategoryHarmful          = "harmful_content"   // violence, weapons, crime, hate...
+	CategorySelfHarm         = "self_harm"         // answered with care rather than content
+	CategorySensitiveData    = "sensitive_data"    // personal or regulated data
+	CategoryCredentials      = "credentials"       // secrets removed from the answer
+	CategoryConfidential     = "confidential"      // the assistant's own instructions
+	CategoryOffTopic         = "off_topic"         // outside what the assistant is for
+	CategoryTone             = "tone"              // profanity, harassment
+	CategoryUntrustedContent = "untrusted_content" // documents or tool output left out
+	CategoryPendingReview    = "pending_review"    // held for a person to approve
+	CategoryUnavailable      = "unavailable"       // a safety check couldn't run
+	CategoryPolicy           = "policy"            // anything else the policy refuses
+)
+
+// Category is one reason, worded for the end user.
+type Category struct {
+	Code    string `json:"code" yaml:"-"`
+	Title   string `json:"title" yaml:"title"`
+	Message string `json:"message" yaml:"message"`
+}
+
+// Explanation is the user-safe account of one request.
+type Explanation struct {
+	RequestID  string     `json:"request_id"`
+	Outcome    string     `json:"outcome"`
+	Categories []Category `json:"categories,omitempty"`
+	// Appeal tells the user how to contest a block or change.
+	Appeal string    `json:"appeal,omitempty"`
+	Time   time.Time `json:"time"`
+}
+
+// Templates word the categories and appeal instructions. Appeal is a
+// text/template over {{.RequestID}}, {{.Outcome}} and {{.TenantID}}.
+// Tenants override either for their own users.
+type Templates struct {
+	Categories map[string]Category `yaml:"categories"`
+	Appeal     string              `yaml:"appeal"`
+	Tenants    map[string]struct {
+		Categories map[string]Category `yaml:"categories"`
+		Appeal     string              `yaml:"appeal"`
+	} `yaml:"tenants"`
+}
+
+// DefaultTemplates are used for whatever a template file leaves out.
+func DefaultTemplates() Templates {
+	return Templates{
+		Categories: map[string]Category{
+			CategoryManipulation:     {Title: "Instructions we can't follow", Message: "Your message looked like an attempt to change how the assistant works or to get around its safeguards."},
+			CategoryHarmful:          {Title: "Potentially harmful content", Message: "The request or answer involved content that could cause harm, so it wasn't provided."},
+			CategorySelfHarm:         {Title: "Support is available", Message: "We can't help with this, but if you are struggling, please reach out to someone you trust or a local helpline."},
+			CategorySensitiveData:    {Title: "Sensitive data", Message: "The answer would have included personal or confidential data that can't be shared here."},
+			CategoryCredentials:      {Title: "Secrets removed", Message: "Passwords, keys or tokens were removed from the answer."},
+			CategoryConfidential:     {Title: "Confidential instructions", Message: "The answer would have revealed the assistant's internal instructions."},
+			CategoryOffTopic:         {Title: "Outside this assistant's scope", Message: "This assistant can't help with that topic."},
+			CategoryTone:             {Title: "Language", Message: "Wording in the answer didn't meet this service's guidelines."},
+			CategoryUntrustedContent: {Title: "Content left out", Message: "Some documents or tool results couldn't be used because they contained instructions aimed at the assistant."},
+			CategoryPendingReview:    {Title: "Waiting for review", Message: "The answer needs a person's approval before it can be shown."},
+			CategoryUnavailable:      {Title: "Temporarily unavailable", Message: "A safety check couldn't run, so the answer was held back. Please try again shortly."},
+			CategoryPolicy:           {Title: "Not allowed by policy", Message: "This request isn't allowed by the service's usage policy."},
+		},
+		Appeal: "If you think this is a mistake, contact support and quote reference {{.RequestID}}.",
+	}
+}
+
+// LoadTemplates reads templates from a YAML file, over the defaults.
+func LoadTemplates(path string) (Templates, error) {
+	t := DefaultTemplates()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return t, fmt.Errorf("explanation templates: %w", err)
+	}
+	var file Templates
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return t, fmt.Errorf("explanation templates %s: %w", path, err)
+	}
+	for code, c := range file.Categories {
+		if _, ok := t.Categories[code]; !ok {
+			return t, fmt.Errorf("explanation templates %s: unknown category %q", path, code)
+		}
+		t.Categories[code] = c
+	}
+	if file.Appeal != "" {
+		t.Appeal = file.Appeal
+	}
+	t.Tenants = file.Tenants
+	for tenant, tt := range t.Tenants {
+		for code := range tt.Categories {
+			if _, ok := t.Categories[code]; !ok {
+				return t, fmt.Errorf("explanation templates %s: tenant %s: unknown category %q", path, tenant, code)
+			}
+		}
+	}
+	for tenant, appeal := range t.appeals() {
+		if _, err := template.New("appeal").Option("missingkey=error").Parse(appeal); err != nil {
+			return t, fmt.Errorf("explanation templates %s: appeal of %q: %w", path, tenant, err)
+		}
+	}
+	return t, nil
+}
+
+// appeals returns every appeal template, by tenant ("" for the default).
+func (t Templates) appeals() map[string]string {
+	m := map[string]string{"": t.Appeal}
+	for tenant, tt := range t.Tenants {
+		if tt.Appeal != "" {
+			m[tenant] = tt.Appeal
+		}
+	}
+	return m
+}
+
+// Outcome is what the gateway did with a request: all an explanation is
+// made from.
+type Outcome struct {
+	TenantID  string
+	RequestID string
+	UserID    string
+	Time      time.Time
+	Blocked   bool
+	Modified  bool
+	// RiskFlags and OutputFlags are the request's reason flags.
+	RiskFlags   []string
+	OutputFlags []string
+	// ExcludedData is how many external data blocks were left out.
+	ExcludedData int
+}
+
+// FromTransaction reads an outcome from a request's audit transaction.
+func FromTransaction(tenantID string, t time.Time, tx *audit.Transaction) Outcome {
+	o := Outcome{TenantID: tenantID, RequestID: tx.RequestID, UserID: tx.UserID, Time: t}
+	if tx.Risk != nil {
+		o.RiskFlags = tx.Risk.Flags
+	}
+	if tx.Output != nil {
+		o.Blocked = tx.Output.Blocked || tx.Output.Withheld
+		o.Modified = tx.Output.Modified
+		o.OutputFlags = tx.Output.Flags
+	}
+	for _, d := range tx.ExternalData {
+		if d.Status == types.DataExcluded {
+			o.ExcludedData++
+		}
+	}
+	return o
+}
+
+// categoryOf maps a reason flag to its category, or "" for flags that
+// explain nothing to a user.
+func categoryOf(flag string) string {
+	name, detail, _ := strings.Cut(flag, ":")
+	switch name {
+	case "dlp":
+		return CategorySensitiveData
+	case "off_topic":
+		return CategoryOffTopic
+	case "tone":
+		return CategoryTone
+	case "policy_refusal":
+		return CategoryPolicy
+	case "moderation":
+		switch detail {
+		case "self_harm":
+			return CategorySelfHarm
+		case "privacy":
+			return CategorySensitiveData
+		}
+		return CategoryHarmful
+	}
+	switch {
+	case flag == "self_harm_content":
+		return CategorySelfHarm
+	case flag == "violence_or_explosives", flag == "illegal_instructions", flag == "dangerous_context",
+		flag == "self_check_refusal", flag == "moderation_unsafe":
+		return CategoryHarmful
+	case flag == "secret_redacted":
+		return CategoryCredentials
+	case flag == "system_prompt_leak":
+		return CategoryConfidential
+	case strings.HasPrefix(flag, "regex_"), flag == "embedding_jailbreak_similar", flag == "session_escalated":
+		return CategoryManipulation
+	case flag == "risk_unscored", flag == "output_unreviewed":
+		return CategoryUnavailable
+	case flag == "approval_pending":
+		return CategoryPendingReview
+	}
+	return ""
+}
+
+// Explain words o for its user.
+func (t Templates) Explain(o Outcome) (Explanation, error) {
+	e := Explanation{RequestID: o.RequestID, Outcome: OutcomeAllowed, Time: o.Time}
+	switch {
+	case o.Blocked:
+		e.Outcome = OutcomeBlocked
+	case o.Modified || o.ExcludedData > 0:
+		e.Outcome = OutcomeModified
+	default:
+		return e, nil
+	}
+
+	codes := make(map[string]bool)
+	for _, f := range o.OutputFlags {
+		if c := categoryOf(f); c != "" {
+			codes[c] = true
+		}
+	}
+	if o.Blocked {
+		// What the request itself was flagged for explains a refusal
+		// made before or regardless of the answer.
+		for _, f := range o.RiskFlags {
+			if c := categoryOf(f); c != "" {
+				codes[c] = true
+			}
+		}
+		if len(codes) == 0 {
+			codes[CategoryPolicy] = true
+		}
+	}
+	if o.ExcludedData > 0 {
+		codes[CategoryUntrustedContent] = true
+	}
+	if codes[CategoryPolicy] && len(codes) > 1 {
+		// A more specific reason says more than the generic one.
+		delete(codes, CategoryPolicy)
+	}
+
+	tenant := t.Tenants[o.TenantID]
+	for code := range codes {
+		c := t.Categories[code]
+		if tc, ok := tenant.Categories[code]; ok {
+			c = tc
+		}
+		c.Code = code
+		e.Categories = append(e.Categories, c)
+	}
+	sort.Slice(e.Categories, func(i, j int) bool { return e.Categories[i].Code < e.Categories[j].Code })
+
+	appeal := t.Appeal
+	if tenant.Appeal != "" {
+		appeal = tenant.Appeal
+	}
+	if appeal != "" {
+		tmpl, err := template.New("appeal").Option("missingkey=error").Parse(appeal)
+		if err != nil {
+			return e, fmt.Errorf("explain: appeal template: %w", err)
+		}
+		var b bytes.Buffer
+		if err := tmpl.Execute(&b, map[string]string{"RequestID": o.RequestID, "Outcome": e.Outcome, "TenantID": o.TenantID}); err != nil {
+			return e, fmt.Errorf("explain: appeal template: %w", err)
+		}
+		e.Appeal = b.String()
+	}
+	return e, nil
+}
+
+// Recent keeps the outcomes of recent requests in process, bounded, so
+// explanations needn't read the audit log. When full, the oldest entry
+// is evicted. A nil *Recent keeps nothing.
+type Recent struct {
+	mu      sync.Mutex
+	max     int
+	ttl     time.Duration
+	entries map[string]Outcome
+	order   []string
+}
+
+// NewRecent keeps at most max outcomes for ttl each.
+func NewRecent(max int, ttl time.Duration) *Recent {
+	return &Recent{max: max, ttl: ttl, entries: make(map[string]Outcome)}
+}
+
+// TTL is how long outcomes are kept.
+func (r *Recent) TTL() time.Duration {
+	if r == nil {
+		return 0
+	}
+	return r.ttl
+}
+
+func recentKey(tenantID, requestID string) string {
+	return tenantID + "\x00" + requestID
+}
+
+// Put keeps o, replacing an earlier outcome of the same request ID.
+func (r *Recent) Put(o Outcome) {
+	if r == nil || r.max <= 0 {
+		return
+	}
+	k := recentKey(o.TenantID, o.RequestID)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.entries[k]; !ok {
+		for len(r.order) >= r.max {
+			delete(r.entries, r.order[0])
+			r.order = r.order[1:]
+		}
+		r.order = append(r.order, k)
+	}
+	r.entries[k] = o
+}
+
+// Get returns the outcome of requestID of tenantID, if kept and fresh.
+func (r *Recent) Get(tenantID, requestID string) (Outcome, bool) {
+	if r == nil {
+		return Outcome{}, false
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	o, ok := r.entries[recentKey(tenantID, requestID)]
+	if !ok || time.Since(o.Time) > r.ttl {
+		return Outcome{}, false
+	}
+	return o, true
+}
@@ -0,0 +1,241 @@
+// Package features exports one anonymized feature record per chat request,
+// and one per feedback label, so the risk and output models can be
+// retrained on what the gateway saw without scraping logs. Records never
+// carry prompt or answer text; tenant, user and session IDs are replaced
+// by keyed hashes.
+//
+// Schema (version 1), one JSON object per record:
+//
+//	schema_version  1
+//	id              "ftr_…"; a request's ID is returned to the client as
+//	                feature_id so it can send feedback
+//	kind            "request" or "feedback"
+//	time            UTC, truncated to the minute
+//	tenant, user, session
+//	                keyed hashes (hex), stable for one salt
+//	disposition     success, invalid_request, error, timeout, client_abandoned
+//	risk            {level, flags, self_check_required}
+//	path            "fast" or "slow"
+//	input           {message_chars, history_turns, external_blocks,
+//	                dangerous_blocks, classes, truncated}
+//	masking         count of masked values by kind (card, email, phone,
+//	                secret)
+//	output          {answer_chars, modified, blocked, withheld, flags}
+//	techniques      attack techniques the flags and detections show (see
+//	                internal/taxonomy), e.g. ["direct_jailbreak"]
+//	request_id      (feedback) the id of the request record labelled
+//	label           (feedback) e.g. "helpful", "false_refusal", "unsafe_answer"
+package features
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"log"
+	"regexp"
+	"time"
+
+	"github.com/shivansh-source/nopass/internal/metrics"
+	"github.com/shivansh-source/nopass/internal/types"
+)
+
+// SchemaVersion is the version of Record.
+const SchemaVersion = 1
+
+// Record kinds.
+const (
+	KindRequest  = "request"
+	KindFeedback = "feedback"
+)
+
+// Record is one exported feature record.
+type Record struct {
+	SchemaVersion int            `json:"schema_version"`
+	ID            string         `json:"id"`
+	Kind          string         `json:"kind"`
+	Time          time.Time      `json:"time"`
+	Tenant        string         `json:"tenant,omitempty"`
+	User          string         `json:"user,omitempty"`
+	Session       string         `json:"session,omitempty"`
+	Disposition   string         `json:"disposition,omitempty"`
+	Risk          *Risk          `json:"risk,omitempty"`
+	Path          types.Path     `json:"path,omitempty"`
+	Input         *Input         `json:"input,omitempty"`
+	Masking       map[string]int `json:"masking,omitempty"`
+	Output        *Output        `json:"output,omitempty"`
+	Techniques    []string       `json:"techniques,omitempty"`
+	RequestID     string         `json:"request_id,omitempty"`
+	Label         string         `json:"label,omitempty"`
+}
+
+// Risk is the risk stage's verdict.
+type Risk struct {
+	Level             types.RiskLevel `json:"level"`
+	Flags             []string        `json:"flags,omitempty"`
+	SelfCheckRequired bool            `json:"self_check_required,omitempty"`
+}
+
+// Input describes the request's shape.
+type Input struct {
+	MessageChars    int      `json:"message_chars"`
+	HistoryTurns    int      `json:"history_turns"`
+	ExternalBlocks  int      `json:"external_blocks"`
+	DangerousBlocks int      `json:"dangerous_blocks"`
+	Classes         []string `json:"classes,omitempty"` // DLP data classes
+	Truncated       bool     `json:"truncated,omitempty"`
+}
+
+// Output is the output stage's verdict.
+type Output struct {
+	AnswerChars int      `json:"answer_chars"`
+	Modified    bool     `json:"modified"`
+	Blocked     bool     `json:"blocked"`  // refused by output review
+	Withheld    bool     `json:"withheld"` // not released, for any reason
+	Flags       []string `json:"flags,omitempty"`
+}
+
+// NewID returns a new record ID.
+func NewID() string {
+	var b [12]byte
+	_, _ = rand.Read(b[:])
+	return "ftr_" + hex.EncodeToString(b[:])
+}
+
+// maskToken matches the placeholders the sandbox's built-in masking
+// writes.
+var maskToken = regexp.MustCompile(`\b(CARD|EMAIL|PHONE|SECRET)_TOKEN_\d+\b`)
+
+// MaskCounts counts the masked values in text by kind.
+func MaskCounts(text string) map[string]int {
+	var counts map[string]int
+	for _, m := range maskToken.FindAllStringSubmatch(text, -1) {
+		if counts == nil {
+			counts = make(map[string]int)
+		}
+		switch m[1] {
+		case "CARD":
+			counts["card"]++
+		case "EMAIL":
+			counts["email"]++
+		case "PHONE":
+			counts["phone"]++
+		case "SECRET":
+			counts["secret"]++
+		}
+	}
+	return counts
+}
+
+// Sink receives batches of records.
+type Sink interface {
+	Write(ctx context.Context, records []Record) error
+}
+
+// Exporter anonymizes records and hands them to a Sink in batches from a
+// background goroutine, so exporting never slows a request down. When the
+// queue is full records are dropped. A nil Exporter exports nothing.
+type Exporter struct {
+	Sink Sink
+	// Salt keys the ID hashes; without it, hashes of guessable IDs could
+	// be reversed.
+	Salt []byte
+	// BatchSize and FlushInterval bound how long records wait (0 = 100
+	// records, 5s).
+	BatchSize     int
+	FlushInterval time.Duration
+
+	queue chan Record
+}
+
+var exported = metrics.NewCounterVec(
+	"nopass_feature_records_total",
+	"Feature records by export result.",
+	"result",
+)
+
+// queueSize is how many records may wait for the sink.
+const queueSize = 10000
+
+// NewExporter creates an Exporter; Run must be started for records to
+// leave the queue.
+func NewExporter(sink Sink, salt []byte) *Exporter {
+	return &Exporter{Sink: sink, Salt: salt, queue: make(chan Record, queueSize)}
+}
+
+// Anonymize returns the keyed hash of id, or "" for an empty id.
+func (e *Exporter) Anonymize(id string) string {
+	if id == "" {
+		return ""
+	}
+	mac := hmac.New(sha256.New, e.Salt)
+	mac.Write([]byte(id))
+	return hex.EncodeToString(mac.Sum(nil)[:16])
+}
+
+// Emit queues r. Tenant, User and Session hold raw IDs and are replaced by
+// their hashes here.
+func (e *Exporter) Emit(r Record) {
+	if e == nil {
+		return
+	}
+	r.SchemaVersion = SchemaVersion
+	r.Time = r.Time.UTC().Truncate(time.Minute)
+	r.Tenant, r.User, r.Session = e.Anonymize(r.Tenant), e.Anonymize(r.User), e.Anonymize(r.Session)
+	select {
+	case e.queue <- r:
+	default:
+		exported.Inc("dropped")
+	}
+}
+
+// Run writes queued records to the sink until ctx is done, then flushes
+// what is left.
+func (e *Exporter) Run(ctx context.Context) {
+	size, every := e.BatchSize, e.FlushInterval
+	if size <= 0 {
+		size = 100
+	}
+	if every <= 0 {
+		every = 5 * time.Second
+	}
+	ticker := time.NewTicker(every)
+	defer ticker.Stop()
+
+	var batch []Record
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		wctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 10*time.Second)
+		defer cancel()
+		if err := e.Sink.Write(wctx, batch); err != nil {
+			log.Printf("feature export error (%d records): %v", len(batch), err)
+			exported.Add(uint64(len(batch)), "failed")
+		} else {
+			exported.Add(uint64(len(batch)), "exported")
+		}
+		batch = nil
+	}
+	for {
+		select {
+		case r := <-e.queue:
+			if batch = append(batch, r); len(batch) >= size {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		case <-ctx.Done():
+			for {
+				select {
+				case r := <-e.queue:
+					batch = append(batch, r)
+				default:
+					flush()
+					return
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package features
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// FileSink appends records as JSON lines to a file.
+type FileSink struct {
+	Path string
+
+	mu sync.Mutex
+}
+
+// Write implements Sink.
+func (s *FileSink) Write(_ context.Context, records []Record) error {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, r := range records {
+		if err := enc.Encode(r); err != nil {
+			return fmt.Errorf("encode feature record: %w", err)
+		}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	f, err := os.OpenFile(s.Path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("open feature file: %w", err)
+	}
+	if _, err := f.Write(buf.Bytes()); err != nil {
+		f.Close()
+		return fmt.Errorf("write feature file: %w", err)
+	}
+	return f.Close()
+}
+
+// WebhookSink POSTs each batch as a JSON array to a URL.
+type WebhookSink struct {
+	URL        string
+	HTTPClient *http.Client
+}
+
+// NewWebhookSink creates a WebhookSink.
+func NewWebhookSink(url string) *WebhookSink {
+	return &WebhookSink{URL: url, HTTPClient: &http.Client{Timeout: 10 * time.Second}}
+}
+
+// Write implements Sink.
+func (s *WebhookSink) Write(ctx context.Context, records []Record) error {
+	data, err := json.Marshal(records)
+	if err != nil {
+		return fmt.Errorf("encode feature records: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.URL, bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("create feature request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := s.HTTPClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("deliver feature records: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("feature webhook returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// ParseSink parses a sink spec: "file:/path/features.jsonl" or an
+// http(s) URL.
+func ParseSink(spec string) (Sink, error) {
+	switch {
+	case strings.HasPrefix(spec, "file:"):
+		return &FileSink{Path: strings.TrimPrefix(spec, "file:")}, nil
+	case strings.HasPrefix(spec, "http://"), strings.HasPrefix(spec, "https://"):
+		return NewWebhookSink(spec), nil
+	default:
+		return nil, fmt.Errorf("unknown feature sink %q", spec)
+	}
+}
@@ -0,0 +1,71 @@
+package gateway
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/shivansh-source/nopass/internal/answercache"
+	"github.com/shivansh-source/nopass/internal/metrics"
+	"github.com/shivansh-source/nopass/internal/sandbox"
+	"github.com/shivansh-source/nopass/internal/types"
+)
+
+var answerCache = metrics.NewCounterVec(
+	"nopass_answer_cache_total",
+	"Answer cache lookups and stores by result (hit, miss, stored, error).",
+	"result",
+)
+
+// answerKey returns the request's answer cache key, or "" when its answer
+// mustn't be shared: the cache is off for the tenant, the request isn't a
+// single low-risk fast-path turn, or its answer depends on more than the
+// masked prompt and data (a conversation, generation settings, restored
+// token values, files).
+func (h *Handler) answerKey(tenantID string, req *types.ChatRequest, risk *types.RiskResponse, path types.Path, in sandbox.SandboxInput, policyID string, restores bool) string {
+	if !h.Answers.Enabled(tenantID) {
+		return ""
+	}
+	if risk.RiskLevel != types.RiskLow || path != types.PathFast {
+		return ""
+	}
+	if req.SessionID != "" || len(in.History) > 0 || in.Memory != "" || req.Generation != nil || restores || h.Artifacts != nil {
+		return ""
+	}
+	data := make([]types.ExternalData, len(in.External))
+	for i, d := range in.External {
+		data[i] = types.ExternalData{Source: d.Source, Type: d.Type, Content: in.Mask(d.Content), IsDangerous: d.IsDangerous}
+	}
+	return answercache.Key(tenantID, policyID, in.Mask(in.UserMessage), data)
+}
+
+// cachedAnswer looks up the answer cached for key.
+func (h *Handler) cachedAnswer(ctx context.Context, key string) (answercache.Entry, bool) {
+	if key == "" {
+		return answercache.Entry{}, false
+	}
+	e, ok, err := h.Answers.Get(ctx, key)
+	switch {
+	case err != nil:
+		slog.ErrorContext(ctx, "answer cache lookup error", "err", err)
+		answerCache.Inc("error")
+	case ok:
+		answerCache.Inc("hit")
+	default:
+		answerCache.Inc("miss")
+	}
+	return e, ok
+}
+
+// cacheAnswer remembers the answer given for key.
+func (h *Handler) cacheAnswer(ctx context.Context, key, answer string, risk types.RiskLevel, path types.Path, flags []string) {
+	if key == "" || h.replay {
+		return
+	}
+	err := h.Answers.Put(ctx, key, answercache.Entry{Answer: answer, RiskLevel: risk, Path: path, Flags: flags})
+	if err != nil {
+		slog.ErrorContext(ctx, "answer cache store error", "err", err)
+		answerCache.Inc("error")
+		return
+	}
+	answerCache.Inc("stored")
+}
@@ -0,0 +1,119 @@
+package gateway
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/shivansh-source/nopass/internal/artifacts"
+	"github.com/shivansh-source/nopass/internal/dlp"
+	"github.com/shivansh-source/nopass/internal/orchestrator"
+	"github.com/shivansh-source/nopass/internal/review"
+	"github.com/shivansh-source/nopass/internal/types"
+)
+
+// keepArtifacts scans and stores the files a run wrote and returns them
+// with their download URLs. Text files go through the answer's checks
+// first: output review (with the prompt canary), secret redaction and the
+// data policy; files those block are dropped, as are binary files unless
+// the store allows them. Files that fail the scan, or that the run
+// produced past the limits, are dropped with a flag and a notice too.
+func (h *Handler) keepArtifacts(ctx context.Context, tenantID string, arts *orchestrator.Artifacts, reviewer review.OutputReviewer, reviewReq types.OutputSafetyRequest, path types.Path, out *outcome, notices *[]string) []types.Artifact {
+	if arts == nil {
+		return nil
+	}
+	if n := len(arts.Dropped); n > 0 {
+		artifacts.Count(n, "dropped")
+		*notices = append(*notices, fmt.Sprintf("%d output file(s) exceeded the artifact limits and were dropped", n))
+	}
+	var kept []types.Artifact
+	for _, f := range arts.Files {
+		data, ok := h.checkArtifact(ctx, f.Name, f.Data, reviewer, reviewReq, path, out, notices)
+		if !ok {
+			continue
+		}
+		a, err := h.Artifacts.Put(ctx, tenantID, f.Name, data)
+		switch {
+		case errors.Is(err, artifacts.ErrInfected):
+			slog.WarnContext(ctx, "artifact failed the malware scan", "name", f.Name, "err", err)
+			out.flags = addFlags(out.flags, "artifact_infected")
+			*notices = append(*notices, "output file "+f.Name+" failed the malware scan and was dropped")
+		case err != nil:
+			slog.ErrorContext(ctx, "store artifact error", "name", f.Name, "err", err)
+			out.flags = addFlags(out.flags, "artifact_scan_failed")
+			*notices = append(*notices, "output file "+f.Name+" could not be kept and was dropped")
+		default:
+			kept = append(kept, types.Artifact{
+				Name:        a.Name,
+				ContentType: a.ContentType,
+				Size:        a.Size,
+				SHA256:      a.SHA256,
+				URL:         h.Artifacts.URL(a),
+				ExpiresAt:   a.ExpiresAt,
+			})
+		}
+	}
+	return kept
+}
+
+// checkArtifact runs one output file through the answer's checks and
+// returns what may be kept of it, or false to drop it.
+func (h *Handler) checkArtifact(ctx context.Context, name string, data []byte, reviewer review.OutputReviewer, reviewReq types.OutputSafetyRequest, path types.Path, out *outcome, notices *[]string) ([]byte, bool) {
+	if !isText(data) {
+		if h.Artifacts.AllowBinary {
+			return data, true
+		}
+		artifacts.Count(1, "binary")
+		out.flags = addFlags(out.flags, "artifact_binary")
+		*notices = append(*notices, "output file "+name+" is not text, so it can't be reviewed, and was dropped")
+		return nil, false
+	}
+
+	reviewReq.DraftAnswer = string(data)
+	resp, err := reviewer.Review(ctx, reviewReq)
+	if err == nil && resp == nil {
+		err = errors.New("reviewer returned no verdict")
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "artifact review error", "name", name, "err", err)
+		artifacts.Count(1, "error")
+		out.flags = addFlags(out.flags, "artifact_review_failed")
+		*notices = append(*notices, "output file "+name+" could not be reviewed and was dropped")
+		return nil, false
+	}
+	if resp.Blocked {
+		slog.InfoContext(ctx, "artifact withheld by output review", "name", name, "flags", resp.ReasonFlags)
+		artifacts.Count(1, "blocked")
+		out.flags = addFlags(addFlags(out.flags, resp.ReasonFlags...), "artifact_blocked")
+		*notices = append(*notices, "output file "+name+" was withheld by output review")
+		return nil, false
+	}
+	text := resp.FinalAnswer
+	if resp.WasModified {
+		out.flags = addFlags(out.flags, resp.ReasonFlags...)
+	}
+	if redacted, ok := redactSecrets(text); ok {
+		text = redacted
+		out.flags = addFlags(out.flags, "secret_redacted")
+	}
+
+	if act, classes := h.DLP.Decide(dlp.Output, path, h.DLP.Classify(text)); act == dlp.ActionBlock {
+		slog.InfoContext(ctx, "artifact withheld by data policy", "name", name, "classes", classes)
+		artifacts.Count(1, "blocked")
+		for _, c := range classes {
+			out.flags = addFlags(out.flags, "dlp:"+c)
+		}
+		*notices = append(*notices, "output file "+name+" withheld by data policy: "+strings.Join(classes, ", "))
+		return nil, false
+	}
+	return []byte(text), true
+}
+
+// isText reports whether data is UTF-8 text without NUL bytes.
+func isText(data []byte) bool {
+	return utf8.Valid(data) && !bytes.ContainsRune(data, 0)
+}
@@ -0,0 +1,41 @@
+package gateway
+
+import (
+	"context"
+
+	"github.com/shivansh-source/nopass/internal/audit"
+	"github.com/shivansh-source/nopass/internal/explain"
+	"github.com/shivansh-source/nopass/internal/features"
+	"github.com/shivansh-source/nopass/internal/types"
+)
+
+// dataVerdicts pairs each external data block with its scan outcome.
+func dataVerdicts(data []types.ExternalData, statuses []types.DataBlockStatus) []audit.DataVerdict {
+	out := make([]audit.DataVerdict, len(statuses))
+	j := 0 // excluded blocks are no longer in data
+	for i, st := range statuses {
+		out[i] = audit.DataVerdict{ID: st.ID, Source: st.Source, Status: st.Status, Reason: st.Reason}
+		if st.Status != types.DataExcluded && j < len(data) {
+			out[i].Dangerous = data[j].IsDangerous
+			j++
+		}
+	}
+	return out
+}
+
+// recordTransaction completes tx from the request's feature record,
+// appends it to the audit log and keeps its outcome for explanations.
+func (h *Handler) recordTransaction(ctx context.Context, tx *audit.Transaction, feat *features.Record) {
+	tx.Disposition, tx.Path = feat.Disposition, feat.Path
+	if feat.Risk != nil {
+		tx.Risk = &audit.Risk{Level: feat.Risk.Level, Flags: feat.Risk.Flags, SelfCheckRequired: feat.Risk.SelfCheckRequired}
+	}
+	if feat.Output != nil {
+		tx.Output = &audit.Output{Modified: feat.Output.Modified, Blocked: feat.Output.Blocked, Withheld: feat.Output.Withheld, Flags: feat.Output.Flags}
+	}
+	tx.Techniques = feat.Techniques
+	h.AuditLog.Record(ctx, feat.Tenant, *tx)
+	if h.Explanations != nil {
+		h.RecentOutcomes.Put(explain.FromTransaction(feat.Tenant, feat.Time, tx))
+	}
+}
@@ -0,0 +1,162 @@
+package gateway
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+
+	"github.com/shivansh-source/nopass/internal/config"
+	"github.com/shivansh-source/nopass/internal/metrics"
+	"github.com/shivansh-source/nopass/internal/orchestrator"
+	"github.com/shivansh-source/nopass/internal/receipts"
+	"github.com/shivansh-source/nopass/internal/review"
+	"github.com/shivansh-source/nopass/internal/scheduler"
+	"github.com/shivansh-source/nopass/internal/types"
+)
+
+var answerCandidates = metrics.NewCounterVec(
+	"nopass_answer_candidates_total",
+	"Candidate answers drafted for slow-path requests, by how output safety judged them (passed, modified, blocked), failed, or skipped for want of a sandbox slot.",
+	"result",
+)
+
+// candidateCount is how many answers to draft for a request: more than
+// one only for HIGH-risk requests on the slow path, whose single drafts
+// are the ones most often blocked.
+func candidateCount(p config.Paths, path types.Path, risk *types.RiskResponse) int {
+	if path != types.PathSlow || risk.RiskLevel.Rank() < types.RiskHigh.Rank() || p.Candidates < 2 {
+		return 1
+	}
+	return p.Candidates
+}
+
+// candidate is one drafted answer and its review.
+type candidate struct {
+	draft   string
+	receipt *types.SandboxReceipt
+	arts    *orchestrator.Artifacts
+	review  *types.OutputSafetyResponse // nil if the review failed
+	err     error                       // the sandbox run's
+}
+
+// rank orders reviewed candidates: answers passed as written, then
+// redacted ones, then refusals, then those whose review failed.
+func (c *candidate) rank() int {
+	switch {
+	case c.review == nil:
+		return 0
+	case c.review.Blocked:
+		return 1
+	case c.review.WasModified:
+		return 2
+	}
+	return 3
+}
+
+// better reports whether c ranks above other; of two alike, the one with
+// fewer reason flags wins.
+func (c *candidate) better(other *candidate) bool {
+	if c.rank() != other.rank() {
+		return c.rank() > other.rank()
+	}
+	return c.review != nil && len(c.review.ReasonFlags) < len(other.review.ReasonFlags)
+}
+
+// draftCandidates runs the sandbox up to n times at once on the same
+// prompt and reviews each draft as it finishes, then returns the
+// best-ranked one. The first run uses the request's own admission slot;
+// every other needs a slot of its own free at once, and is skipped
+// without one rather than wait while the request holds its slot.
+// Drafts differ only as far as the model samples, so the request's
+// temperature (or the provider's default) must be above zero to gain
+// anything. Every run's receipt is stored but the winner's, which is
+// returned for the caller to store like a single run's; the winner's
+// artifacts are copied into arts.
+//
+// The winner's review is nil if no review succeeded: the caller then
+// reviews its draft the usual way, with the stage's failure policy. err
+// is set only if every run failed, to the first run's error.
+func (h *Handler) draftCandidates(
+	ctx, runCtx context.Context,
+	tenantID string,
+	prio scheduler.Priority,
+	n int,
+	reviewer review.OutputReviewer,
+	reviewReq types.OutputSafetyRequest,
+	systemPrompt, userContent string,
+	arts *orchestrator.Artifacts,
+) (*candidate, error) {
+	cands := make([]*candidate, n)
+	var wg sync.WaitGroup
+	for i := range cands {
+		release := func() {}
+		if i > 0 && h.Admission != nil {
+			var ok bool
+			if release, ok = h.Admission.TryAcquire(prio); !ok {
+				answerCandidates.Add(uint64(n-i), "skipped")
+				cands = cands[:i]
+				break
+			}
+		}
+		c := &candidate{receipt: &types.SandboxReceipt{ID: receipts.NewID(), TenantID: tenantID}}
+		cands[i] = c
+		cctx := orchestrator.WithReceipt(runCtx, c.receipt)
+		if arts != nil {
+			c.arts = &orchestrator.Artifacts{MaxFiles: arts.MaxFiles, MaxBytes: arts.MaxBytes}
+			cctx = orchestrator.WithArtifacts(cctx, c.arts)
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			c.draft, c.err = h.LLMRunner.RunInSandbox(cctx, systemPrompt, userContent)
+			release()
+			if c.err != nil {
+				answerCandidates.Inc("failed")
+				return
+			}
+			req := reviewReq
+			req.DraftAnswer = c.draft
+			resp, err := reviewer.Review(ctx, req)
+			if err != nil {
+				slog.WarnContext(ctx, "candidate answer review error", "err", err)
+				return
+			}
+			c.review = resp
+			answerCandidates.Inc(candidateResult(resp))
+		}()
+	}
+	wg.Wait()
+
+	var best *candidate
+	for _, c := range cands {
+		if c.err == nil && (best == nil || c.better(best)) {
+			best = c
+		}
+	}
+	for _, c := range cands {
+		if c == best || c.receipt.StartedAt.IsZero() {
+			continue
+		}
+		if err := receipts.Record(h.Receipts, *c.receipt); err != nil {
+			slog.ErrorContext(ctx, "store sandbox receipt error", "err", err)
+		}
+	}
+	if best == nil {
+		return cands[0], cands[0].err
+	}
+	if arts != nil {
+		*arts = *best.arts
+	}
+	return best, nil
+}
+
+// candidateResult is the answerCandidates label for a review.
+func candidateResult(resp *types.OutputSafetyResponse) string {
+	switch {
+	case resp.Blocked:
+		return "blocked"
+	case resp.WasModified:
+		return "modified"
+	}
+	return "passed"
+}
@@ -6,14 +6,21 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"strconv"
 	"time"
 
+	"github.com/shivansh-source/nopass/internal/breaker"
+	"github.com/shivansh-source/nopass/internal/logging"
+	"github.com/shivansh-source/nopass/internal/tracing"
 	"github.com/shivansh-source/nopass/internal/types"
 )
 
 type OutputSafetyClient struct {
 	BaseURL    string
 	HTTPClient *http.Client
+	// Breaker retries transient failures and stops calling a service
+	// that keeps failing; nil calls once, unguarded.
+	Breaker *breaker.Breaker
 }
 
 func NewOutputSafetyClient(baseURL string) *OutputSafetyClient {
@@ -22,34 +29,51 @@ func NewOutputSafetyClient(baseURL string) *OutputSafetyClient {
 		HTTPClient: &http.Client{
 			Timeout: 3 * time.Second,
 		},
+		Breaker: breaker.New("output_safety"),
 	}
 }
 
-// Mode is "fast" or "slow"
-func (c *OutputSafetyClient) Review(
-	ctx context.Context,
-	userPrompt, draftAnswer, riskLevel string,
-	flags []string,
-	mode string,
-) (*types.OutputSafetyResponse, error) {
-	reqBody := types.OutputSafetyRequest{
-		UserPrompt:  userPrompt,
-		DraftAnswer: draftAnswer,
-		RiskLevel:   riskLevel,
-		Flags:       flags,
-		Mode:        mode,
+// Review asks the output safety service to check req.DraftAnswer. It
+// implements review.OutputReviewer; schema version and deadline are filled
+// in here.
+func (c *OutputSafetyClient) Review(ctx context.Context, req types.OutputSafetyRequest) (_ *types.OutputSafetyResponse, err error) {
+	ctx, span := tracing.Start(ctx, "output_safety.review", tracing.Client)
+	defer func() { span.End(err) }()
+	var out *types.OutputSafetyResponse
+	err = c.Breaker.Do(ctx, func(ctx context.Context) (err error) {
+		out, err = c.review(ctx, req)
+		return err
+	})
+	if err != nil {
+		return nil, err
 	}
+	span.SetAttr("nopass.blocked", out.Blocked)
+	span.SetAttr("nopass.modified", out.WasModified)
+	return out, nil
+}
+
+// review makes one call to /v1/output-safety. Errors retrying won't fix
+// are marked breaker.Permanent.
+func (c *OutputSafetyClient) review(ctx context.Context, req types.OutputSafetyRequest) (*types.OutputSafetyResponse, error) {
+	budget := remainingBudget(ctx, c.HTTPClient)
+	reqBody := req
+	reqBody.SchemaVersion = types.SchemaVersion
+	reqBody.DeadlineMs = budget.Milliseconds()
 
 	data, err := json.Marshal(reqBody)
 	if err != nil {
-		return nil, fmt.Errorf("marshal output safety request: %w", err)
+		return nil, breaker.Permanent(fmt.Errorf("marshal output safety request: %w", err))
 	}
 
 	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, c.BaseURL+"/v1/output-safety", bytes.NewReader(data))
 	if err != nil {
-		return nil, fmt.Errorf("create output safety request: %w", err)
+		return nil, breaker.Permanent(fmt.Errorf("create output safety request: %w", err))
 	}
 	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set(types.SchemaHeader, strconv.Itoa(types.SchemaVersion))
+	setDeadlineHeader(httpReq, budget)
+	tracing.Inject(ctx, httpReq.Header)
+	logging.Propagate(ctx, httpReq.Header)
 
 	resp, err := c.HTTPClient.Do(httpReq)
 	if err != nil {
@@ -58,13 +82,15 @@ func (c *OutputSafetyClient) Review(
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("output safety service returned status %d", resp.StatusCode)
+		return nil, statusError("output safety service", resp.StatusCode)
 	}
 
 	var out types.OutputSafetyResponse
 	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
-		return nil, fmt.Errorf("decode output safety response: %w", err)
+		return nil, breaker.Permanent(fmt.Errorf("decode output safety response: %w", err))
+	}
+	if err := types.CheckSchemaVersion(out.SchemaVersion); err != nil {
+		return nil, breaker.Permanent(fmt.Errorf("output safety response: %w", err))
 	}
-
 	return &out, nil
 }
@@ -6,14 +6,26 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"strconv"
+	"sync/atomic"
 	"time"
 
+	"github.com/shivansh-source/nopass/internal/breaker"
+	"github.com/shivansh-source/nopass/internal/logging"
+	"github.com/shivansh-source/nopass/internal/tracing"
 	"github.com/shivansh-source/nopass/internal/types"
 )
 
 type RiskClient struct {
 	BaseURL    string
 	HTTPClient *http.Client
+	// Breaker retries transient failures and stops calling a service
+	// that keeps failing; nil calls once, unguarded.
+	Breaker *breaker.Breaker
+
+	// noBatchUntil holds off ScoreBatch (UnixNano) after the service
+	// turned out not to support it.
+	noBatchUntil atomic.Int64
 }
 
 func NewRiskClient(baseURL string) *RiskClient {
@@ -22,28 +34,53 @@ func NewRiskClient(baseURL string) *RiskClient {
 		HTTPClient: &http.Client{
 			Timeout: 2 * time.Second,
 		},
+		Breaker: breaker.New("risk"),
+	}
+}
+
+func (c *RiskClient) ScorePrompt(ctx context.Context, prompt, userID, sessionID string) (_ *types.RiskResponse, err error) {
+	ctx, span := tracing.Start(ctx, "risk.score", tracing.Client)
+	defer func() { span.End(err) }()
+	var riskResp *types.RiskResponse
+	err = c.Breaker.Do(ctx, func(ctx context.Context) (err error) {
+		riskResp, err = c.scorePrompt(ctx, prompt, userID, sessionID)
+		return err
+	})
+	if err != nil {
+		return nil, err
 	}
+	span.SetAttr("nopass.risk_level", string(riskResp.RiskLevel))
+	return riskResp, nil
 }
 
-func (c *RiskClient) ScorePrompt(ctx context.Context, prompt, userID, sessionID string) (*types.RiskResponse, error) {
+// scorePrompt makes one call to /v1/risk-score. Errors retrying won't fix
+// are marked breaker.Permanent.
+func (c *RiskClient) scorePrompt(ctx context.Context, prompt, userID, sessionID string) (*types.RiskResponse, error) {
+	budget := remainingBudget(ctx, c.HTTPClient)
 	reqBody := types.RiskRequest{
-		Prompt: prompt,
+		SchemaVersion: types.SchemaVersion,
+		Prompt:        prompt,
 		Metadata: map[string]string{
 			"user_id":    userID,
 			"session_id": sessionID,
 		},
+		DeadlineMs: budget.Milliseconds(),
 	}
 
 	data, err := json.Marshal(reqBody)
 	if err != nil {
-		return nil, fmt.Errorf("marshal risk request: %w", err)
+		return nil, breaker.Permanent(fmt.Errorf("marshal risk request: %w", err))
 	}
 
 	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, c.BaseURL+"/v1/risk-score", bytes.NewReader(data))
 	if err != nil {
-		return nil, fmt.Errorf("create risk request: %w", err)
+		return nil, breaker.Permanent(fmt.Errorf("create risk request: %w", err))
 	}
 	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set(types.SchemaHeader, strconv.Itoa(types.SchemaVersion))
+	setDeadlineHeader(httpReq, budget)
+	tracing.Inject(ctx, httpReq.Header)
+	logging.Propagate(ctx, httpReq.Header)
 
 	resp, err := c.HTTPClient.Do(httpReq)
 	if err != nil {
@@ -52,13 +89,25 @@ func (c *RiskClient) ScorePrompt(ctx context.Context, prompt, userID, sessionID
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("risk service returned status %d", resp.StatusCode)
+		return nil, statusError("risk service", resp.StatusCode)
 	}
 
 	var riskResp types.RiskResponse
 	if err := json.NewDecoder(resp.Body).Decode(&riskResp); err != nil {
-		return nil, fmt.Errorf("decode risk response: %w", err)
+		return nil, breaker.Permanent(fmt.Errorf("decode risk response: %w", err))
+	}
+	if err := types.CheckSchemaVersion(riskResp.SchemaVersion); err != nil {
+		return nil, breaker.Permanent(fmt.Errorf("risk response: %w", err))
 	}
-
 	return &riskResp, nil
 }
+
+// statusError reports a non-200 response from service. Overload and
+// server errors may pass and are retried; other statuses are permanent.
+func statusError(service string, code int) error {
+	err := fmt.Errorf("%s returned status %d", service, code)
+	if code == http.StatusTooManyRequests || code >= 500 {
+		return err
+	}
+	return breaker.Permanent(err)
+}
@@ -0,0 +1,82 @@
+package gateway
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/shivansh-source/nopass/internal/logging"
+	"github.com/shivansh-source/nopass/internal/risk"
+	"github.com/shivansh-source/nopass/internal/tracing"
+	"github.com/shivansh-source/nopass/internal/types"
+)
+
+// batchRecheck is how long a service found without the batch endpoint is
+// left alone before it is tried again, in case it was upgraded.
+const batchRecheck = 5 * time.Minute
+
+// ScoreBatch scores contents with one call to /v1/risk-score/batch. It
+// returns risk.ErrBatchUnsupported if the service lacks the endpoint.
+// Documents past StreamThresholdBytes belong in ScoreDocument.
+func (c *RiskClient) ScoreBatch(ctx context.Context, contents []string, userID, sessionID string) (_ []*types.RiskResponse, err error) {
+	if until := c.noBatchUntil.Load(); until != 0 && time.Now().UnixNano() < until {
+		return nil, risk.ErrBatchUnsupported
+	}
+	ctx, span := tracing.Start(ctx, "risk.score_batch", tracing.Client)
+	defer func() { span.End(err) }()
+	span.SetAttr("nopass.documents", len(contents))
+	budget := remainingBudget(ctx, c.HTTPClient)
+	data, err := json.Marshal(types.RiskBatchRequest{
+		SchemaVersion: types.SchemaVersion,
+		Documents:     contents,
+		Metadata:      map[string]string{"user_id": userID, "session_id": sessionID},
+		DeadlineMs:    budget.Milliseconds(),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("marshal risk batch: %w", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, c.BaseURL+"/v1/risk-score/batch", bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("create risk batch request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set(types.SchemaHeader, strconv.Itoa(types.SchemaVersion))
+	setDeadlineHeader(httpReq, budget)
+	tracing.Inject(ctx, httpReq.Header)
+	logging.Propagate(ctx, httpReq.Header)
+
+	resp, err := c.HTTPClient.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("call risk batch: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusMethodNotAllowed {
+		c.noBatchUntil.Store(time.Now().Add(batchRecheck).UnixNano())
+		return nil, risk.ErrBatchUnsupported
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("risk batch returned status %d", resp.StatusCode)
+	}
+
+	var batch types.RiskBatchResponse
+	if err := json.NewDecoder(resp.Body).Decode(&batch); err != nil {
+		return nil, fmt.Errorf("decode risk batch: %w", err)
+	}
+	if err := types.CheckSchemaVersion(batch.SchemaVersion); err != nil {
+		return nil, fmt.Errorf("risk batch: %w", err)
+	}
+	if len(batch.Results) != len(contents) {
+		return nil, fmt.Errorf("risk batch returned %d verdicts for %d documents", len(batch.Results), len(contents))
+	}
+	out := make([]*types.RiskResponse, len(batch.Results))
+	for i := range batch.Results {
+		out[i] = &batch.Results[i]
+	}
+	return out, nil
+}
@@ -0,0 +1,169 @@
+package gateway
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/shivansh-source/nopass/internal/logging"
+	"github.com/shivansh-source/nopass/internal/tracing"
+	"github.com/shivansh-source/nopass/internal/types"
+)
+
+// Streaming scoring (protocol v2) splits large documents into sections and
+// scores them one at a time, so the service never has to parse megabytes of
+// JSON and scoring stops at the first HIGH section.
+const (
+	// StreamThresholdBytes is the content size above which ScoreDocument
+	// switches to the streaming endpoint.
+	StreamThresholdBytes = 32 << 10
+	// streamSectionBytes is the target section size.
+	streamSectionBytes = 16 << 10
+	// streamTimeout bounds a whole stream; sections share it.
+	streamTimeout = 15 * time.Second
+)
+
+// errStreamUnsupported means the risk service predates /v2/risk-score/stream.
+var errStreamUnsupported = errors.New("risk service does not support streaming")
+
+// ScoreDocument scores external content. Small content goes through
+// ScorePrompt; larger content is streamed section by section. Services
+// without the streaming endpoint fall back to a single v1 call.
+func (c *RiskClient) ScoreDocument(ctx context.Context, content, userID, sessionID string) (*types.RiskResponse, error) {
+	if len(content) <= StreamThresholdBytes {
+		return c.ScorePrompt(ctx, content, userID, sessionID)
+	}
+	resp, err := c.scoreStream(ctx, splitSections(content, streamSectionBytes), userID, sessionID)
+	if errors.Is(err, errStreamUnsupported) {
+		return c.ScorePrompt(ctx, content, userID, sessionID)
+	}
+	return resp, err
+}
+
+// scoreStream writes sections as NDJSON while reading verdicts back. The
+// combined verdict carries the highest section risk and every flag; reading
+// stops, and the upload is abandoned, once a section scores HIGH.
+func (c *RiskClient) scoreStream(ctx context.Context, sections []string, userID, sessionID string) (_ *types.RiskResponse, err error) {
+	ctx, span := tracing.Start(ctx, "risk.score_stream", tracing.Client)
+	defer func() { span.End(err) }()
+	span.SetAttr("nopass.sections", len(sections))
+	ctx, cancel := context.WithTimeout(ctx, streamTimeout)
+	defer cancel()
+
+	client := *c.HTTPClient
+	client.Timeout = 0 // bounded by ctx instead; sections share the budget
+	budget := remainingBudget(ctx, nil)
+
+	pr, pw := io.Pipe()
+	go func() {
+		enc := json.NewEncoder(pw)
+		for i, text := range sections {
+			sec := types.RiskSection{SchemaVersion: types.SchemaVersion, Seq: i, Text: text}
+			if i == 0 {
+				sec.Metadata = map[string]string{"user_id": userID, "session_id": sessionID}
+				sec.DeadlineMs = budget.Milliseconds()
+			}
+			if err := enc.Encode(sec); err != nil {
+				pw.CloseWithError(err)
+				return
+			}
+		}
+		pw.Close()
+	}()
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, c.BaseURL+"/v2/risk-score/stream", pr)
+	if err != nil {
+		pr.Close()
+		return nil, fmt.Errorf("create risk stream request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/x-ndjson")
+	httpReq.Header.Set(types.SchemaHeader, strconv.Itoa(types.SchemaVersion))
+	setDeadlineHeader(httpReq, budget)
+	tracing.Inject(ctx, httpReq.Header)
+	logging.Propagate(ctx, httpReq.Header)
+
+	resp, err := client.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("call risk stream: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusMethodNotAllowed {
+		return nil, errStreamUnsupported
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("risk stream returned status %d", resp.StatusCode)
+	}
+
+	combined := &types.RiskResponse{SchemaVersion: types.SchemaVersion, RiskLevel: types.RiskLow}
+	seen := 0
+	sc := bufio.NewScanner(resp.Body)
+	sc.Buffer(make([]byte, 64<<10), 1<<20)
+	for sc.Scan() {
+		var v types.RiskSectionVerdict
+		if err := json.Unmarshal(sc.Bytes(), &v); err != nil {
+			return nil, fmt.Errorf("decode risk stream verdict: %w", err)
+		}
+		if err := types.CheckSchemaVersion(v.SchemaVersion); err != nil {
+			return nil, fmt.Errorf("risk stream verdict: %w", err)
+		}
+		if v.Final && v.Seq < 0 {
+			// End-of-stream marker: every section was scored.
+			break
+		}
+		seen++
+		if v.RiskLevel.Rank() > combined.RiskLevel.Rank() {
+			combined.RiskLevel = v.RiskLevel
+		}
+		for _, f := range v.Flags {
+			combined.Flags = append(combined.Flags, fmt.Sprintf("section_%d:%s", v.Seq, f))
+		}
+		if v.RiskLevel == types.RiskHigh {
+			// Early termination: the document is dangerous whatever the
+			// remaining sections contain.
+			combined.Flags = append(combined.Flags, "stream_stopped_early")
+			combined.SelfCheckRequired = true
+			return combined, nil
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("read risk stream: %w", err)
+	}
+	if seen < len(sections) {
+		return nil, fmt.Errorf("risk stream ended after %d of %d sections", seen, len(sections))
+	}
+	return combined, nil
+}
+
+// splitSections cuts content into pieces of at most size bytes, preferring
+// paragraph and then line boundaries, and never splitting a rune.
+func splitSections(content string, size int) []string {
+	var out []string
+	for len(content) > size {
+		cut := truncateUTF8(content, size)
+		if i := strings.LastIndex(cut, "\n\n"); i > size/2 {
+			cut = cut[:i+2]
+		} else if i := strings.LastIndex(cut, "\n"); i > size/2 {
+			cut = cut[:i+1]
+		}
+		if cut == "" {
+			// size smaller than one rune; take the rune anyway.
+			_, n := utf8.DecodeRuneInString(content)
+			cut = content[:n]
+		}
+		out = append(out, cut)
+		content = content[len(cut):]
+	}
+	if content != "" {
+		out = append(out, content)
+	}
+	return out
+}
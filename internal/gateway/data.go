@@ -0,0 +1,201 @@
+package gateway
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/shivansh-source/nopass/internal/datastore"
+	"github.com/shivansh-source/nopass/internal/sandbox"
+	"github.com/shivansh-source/nopass/internal/scanledger"
+	"github.com/shivansh-source/nopass/internal/types"
+)
+
+// maxDataBodyBytes bounds a single registered document: the JSON body, or
+// the text extracted from an uploaded file.
+const maxDataBodyBytes = 8 << 20
+
+// DataHandler registers an external document (POST /v1/data): it is scanned
+// and masked once, hashed and stored, and can then be referenced from
+// ChatRequest.DataRefs.
+func (h *Handler) DataHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if h.DataStore == nil {
+		http.Error(w, "data registration is not enabled", http.StatusNotFound)
+		return
+	}
+
+	if !isJSON(r) {
+		// Any other content type is the document itself, streamed.
+		h.registerRaw(w, r)
+		return
+	}
+
+	var req types.DataRegistrationRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxDataBodyBytes)).Decode(&req); err != nil {
+		http.Error(w, "invalid JSON body", http.StatusBadRequest)
+		return
+	}
+	req.TenantID = h.tenantID(r, &types.ChatRequest{TenantID: req.TenantID})
+	h.register(w, r.Context(), req)
+}
+
+// register scans, masks and stores a document and answers with its
+// metadata.
+func (h *Handler) register(w http.ResponseWriter, ctx context.Context, req types.DataRegistrationRequest) {
+	if req.Content == "" {
+		http.Error(w, "content is required", http.StatusBadRequest)
+		return
+	}
+
+	v, err := h.scanContent(ctx, req.Content, "", "", false)
+	if err != nil {
+		log.Printf("risk scoring error while registering data: %v", err)
+		http.Error(w, "internal error (risk scoring)", http.StatusInternalServerError)
+		return
+	}
+
+	doc := &datastore.Document{
+		ID:          datastore.NewID(),
+		TenantID:    req.TenantID,
+		Source:      req.Source,
+		Type:        req.Type,
+		Content:     sandbox.MaskSensitiveText(req.Content),
+		ContentHash: datastore.HashContent(req.Content),
+		CreatedAt:   time.Now().UTC(),
+	}
+	applyVerdict(doc, v)
+	if err := h.DataStore.Put(doc); err != nil {
+		log.Printf("store document error: %v", err)
+		http.Error(w, "internal error (data store)", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(doc); err != nil {
+		log.Printf("encode response error: %v", err)
+	}
+}
+
+// DataItemHandler returns the metadata of a registered document
+// (GET /v1/data/{id}). Content is never returned.
+func (h *Handler) DataItemHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if h.DataStore == nil {
+		http.Error(w, "data registration is not enabled", http.StatusNotFound)
+		return
+	}
+
+	doc, err := h.DataStore.Get(h.tenantID(r, &types.ChatRequest{}), r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "document not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(doc); err != nil {
+		log.Printf("encode response error: %v", err)
+	}
+}
+
+// resolveDataRefs appends the registered documents referenced by the
+// request to its external data. They were scanned at registration time and
+// are marked so the chat pipeline doesn't scan them again.
+func (h *Handler) resolveDataRefs(tenantID string, req *types.ChatRequest) error {
+	if len(req.DataRefs) == 0 {
+		return nil
+	}
+	if h.DataStore == nil {
+		return errors.New("data_refs given but data registration is not enabled")
+	}
+	for _, id := range req.DataRefs {
+		doc, err := h.DataStore.Get(tenantID, id)
+		if err != nil {
+			return fmt.Errorf("data ref %s: %w", id, err)
+		}
+		req.ExternalData = append(req.ExternalData, types.ExternalData{
+			ID:          doc.ID,
+			Source:      doc.Source,
+			Type:        doc.Type,
+			Content:     doc.Content,
+			IsDangerous: doc.IsDangerous,
+			Prescanned:  true,
+		})
+	}
+	return nil
+}
+
+type rescanResponse struct {
+	Rescanned bool                `json:"rescanned"`
+	Document  *datastore.Document `json:"document"`
+}
+
+// DataRescanHandler re-scans a registered document (POST
+// /v1/data/{id}/rescan). The scan is skipped when the document was already
+// scanned under the current policy version, unless ?force=1 is given.
+func (h *Handler) DataRescanHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if h.DataStore == nil {
+		http.Error(w, "data registration is not enabled", http.StatusNotFound)
+		return
+	}
+
+	doc, err := h.DataStore.Get(h.tenantID(r, &types.ChatRequest{}), r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "document not found", http.StatusNotFound)
+		return
+	}
+
+	force := r.URL.Query().Get("force") == "1"
+	rescanned := false
+	if force || doc.PolicyVersion != h.PolicyVersion {
+		// Only the masked content is kept, so that is what gets re-scanned.
+		v, err := h.scanContent(r.Context(), doc.Content, "", "", true)
+		if err != nil {
+			log.Printf("risk scoring error while rescanning %s: %v", doc.ID, err)
+			http.Error(w, "internal error (risk scoring)", http.StatusInternalServerError)
+			return
+		}
+		applyVerdict(doc, v)
+		if err := h.DataStore.Put(doc); err != nil {
+			log.Printf("store document error: %v", err)
+			http.Error(w, "internal error (data store)", http.StatusInternalServerError)
+			return
+		}
+		rescanned = true
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(rescanResponse{Rescanned: rescanned, Document: doc}); err != nil {
+		log.Printf("encode response error: %v", err)
+	}
+}
+
+// applyVerdict copies a scan verdict onto a stored document.
+func applyVerdict(doc *datastore.Document, v *scanledger.Verdict) {
+	doc.RiskLevel = v.RiskLevel
+	doc.Flags = v.Flags
+	doc.IsDangerous = v.IsDangerous
+	doc.PolicyVersion = v.PolicyVersion
+	doc.ScannedAt = v.ScannedAt
+}
+
+// RescanContent scans content under the current policy, ignoring cached
+// verdicts. It is the rescan.ScanFunc used by the background rescanner.
+func (h *Handler) RescanContent(ctx context.Context, content string) (*scanledger.Verdict, error) {
+	return h.scanContent(ctx, content, "", "", true)
+}
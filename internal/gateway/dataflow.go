@@ -0,0 +1,86 @@
+package gateway
+
+import (
+	"strings"
+
+	"github.com/shivansh-source/nopass/internal/policy"
+	"github.com/shivansh-source/nopass/internal/sandbox"
+	"github.com/shivansh-source/nopass/internal/types"
+)
+
+// dataSources lists the external data blocks that reached the model.
+func dataSources(docs []types.ExternalData) []types.DataSource {
+	if len(docs) == 0 {
+		return nil
+	}
+	out := make([]types.DataSource, len(docs))
+	for i, d := range docs {
+		out[i] = types.DataSource{ID: d.ID, Source: d.Source, Type: d.Type, Dangerous: d.IsDangerous}
+	}
+	return out
+}
+
+// dataFlowLabels summarizes where the model's input came from, for the
+// output reviewer.
+func dataFlowLabels(in sandbox.SandboxInput) []string {
+	labels := []string{"user_input"}
+	seen := map[string]bool{}
+	add := func(l string) {
+		if !seen[l] {
+			seen[l] = true
+			labels = append(labels, l)
+		}
+	}
+
+	masked := in.Mask(in.UserMessage) != in.UserMessage
+	for _, d := range in.External {
+		switch {
+		case strings.HasPrefix(d.Source, "connector:"):
+			add("external:retrieved")
+		case d.Prescanned:
+			add("external:registered")
+		default:
+			add("external:client")
+		}
+		if d.IsDangerous {
+			add("external:quarantined")
+		}
+		if !masked && in.Mask(d.Content) != d.Content {
+			masked = true
+		}
+	}
+	if len(in.History) > 0 {
+		add("history")
+	}
+	if in.Memory != "" {
+		add("memory_summary")
+	}
+	if in.Truncated != nil {
+		add("truncated_input")
+	}
+	if masked {
+		add("pii_masked")
+	}
+	return labels
+}
+
+// policyID identifies the policy a tenant's request was handled under:
+// the global detection policy version, plus the version of the synced
+// policy set in use, the tenant if it has overrides there and the
+// profile applied and any tuning adjustments.
+func (h *Handler) policyID(tenantID string, set *policy.Set) string {
+	if set == nil {
+		return h.PolicyVersion
+	}
+	id := h.PolicyVersion + "@" + set.Version
+	if set.Tenant != "" {
+		id += "+" + set.Tenant
+	}
+	if set.Profile != "" {
+		id += "/" + set.Profile
+	}
+	if len(set.Tuning) > 0 {
+		id += "~" + strings.Join(set.Tuning, ",")
+	}
+	return id
+}
@@ -0,0 +1,152 @@
+package gateway
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/shivansh-source/nopass/internal/auth"
+	"github.com/shivansh-source/nopass/internal/config"
+)
+
+// DeadlineHeader carries the caller's remaining time budget, in
+// milliseconds, to downstream services.
+const DeadlineHeader = "X-Deadline-Ms"
+
+// deadlineSafetyMargin is reserved for the response to travel back to us, so
+// downstream services finish before our own client timeout fires.
+const deadlineSafetyMargin = 50 * time.Millisecond
+
+// remainingBudget returns how long a downstream call may take: the smaller
+// of the context deadline and the HTTP client timeout, minus a safety margin.
+// It returns 0 when neither bound is set.
+func remainingBudget(ctx context.Context, client *http.Client) time.Duration {
+	var budget time.Duration
+	if dl, ok := ctx.Deadline(); ok {
+		budget = time.Until(dl)
+	}
+	if client != nil && client.Timeout > 0 && (budget == 0 || client.Timeout < budget) {
+		budget = client.Timeout
+	}
+	if budget == 0 {
+		return 0
+	}
+	budget -= deadlineSafetyMargin
+	if budget < time.Millisecond {
+		budget = time.Millisecond
+	}
+	return budget
+}
+
+// setDeadlineHeader stamps req with the remaining budget in milliseconds.
+func setDeadlineHeader(req *http.Request, budget time.Duration) {
+	if budget > 0 {
+		req.Header.Set(DeadlineHeader, strconv.FormatInt(budget.Milliseconds(), 10))
+	}
+}
+
+// RequestDeadlineHeader lets a client set its request's deadline: a
+// duration ("10s", or bare milliseconds) or an RFC 3339 instant. The
+// gRPC-style grpc-timeout ("10S", "1500m") and this gateway's own
+// X-Deadline-Ms, so gateways can be chained, are honored too. Only API
+// keys allowed to (auth.Key.Deadlines) may set one; others are ignored.
+const RequestDeadlineHeader = "X-Request-Deadline"
+
+const (
+	// minClientDeadline is the shortest deadline a client may ask for.
+	minClientDeadline = time.Second
+	// minSandboxBudget is the least time worth starting a sandbox run
+	// with; a request with less left fails up front instead.
+	minSandboxBudget = time.Second
+)
+
+// requestTimeout returns how long the request may run: the client's
+// deadline, if its key may set one, between minClientDeadline and
+// rt.MaxClientDeadline (request_timeout when unset); otherwise
+// rt.RequestTimeout. client reports whether the client's deadline was
+// used.
+func requestTimeout(r *http.Request, key *auth.Key, rt config.Runtime) (timeout time.Duration, client bool, err error) {
+	if key == nil || !key.Deadlines {
+		return rt.RequestTimeout, false, nil
+	}
+	d, ok, err := parseClientDeadline(r.Header, time.Now())
+	if err != nil || !ok {
+		return rt.RequestTimeout, false, err
+	}
+	limit := rt.MaxClientDeadline
+	if limit <= 0 {
+		limit = rt.RequestTimeout
+	}
+	return min(max(d, minClientDeadline), limit), true, nil
+}
+
+// parseClientDeadline reads the first deadline header set.
+func parseClientDeadline(h http.Header, now time.Time) (time.Duration, bool, error) {
+	if v := h.Get(RequestDeadlineHeader); v != "" {
+		if ms, err := strconv.ParseInt(v, 10, 64); err == nil && ms > 0 {
+			return time.Duration(ms) * time.Millisecond, true, nil
+		}
+		if d, err := time.ParseDuration(v); err == nil && d > 0 {
+			return d, true, nil
+		}
+		if t, err := time.Parse(time.RFC3339Nano, v); err == nil {
+			return t.Sub(now), true, nil
+		}
+		return 0, false, fmt.Errorf("invalid %s %q", RequestDeadlineHeader, v)
+	}
+	if v := h.Get("grpc-timeout"); v != "" {
+		d, err := parseGRPCTimeout(v)
+		if err != nil {
+			return 0, false, err
+		}
+		return d, true, nil
+	}
+	if v := h.Get(DeadlineHeader); v != "" {
+		ms, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || ms <= 0 {
+			return 0, false, fmt.Errorf("invalid %s %q", DeadlineHeader, v)
+		}
+		return time.Duration(ms) * time.Millisecond, true, nil
+	}
+	return 0, false, nil
+}
+
+// parseGRPCTimeout parses gRPC's timeout format: up to 8 digits and a
+// unit, H, M, S, m (milli), u (micro) or n (nano).
+func parseGRPCTimeout(v string) (time.Duration, error) {
+	units := map[byte]time.Duration{
+		'H': time.Hour, 'M': time.Minute, 'S': time.Second,
+		'm': time.Millisecond, 'u': time.Microsecond, 'n': time.Nanosecond,
+	}
+	if len(v) < 2 || len(v) > 9 {
+		return 0, fmt.Errorf("invalid grpc-timeout %q", v)
+	}
+	unit, ok := units[v[len(v)-1]]
+	n, err := strconv.ParseInt(v[:len(v)-1], 10, 64)
+	if !ok || err != nil || n <= 0 {
+		return 0, fmt.Errorf("invalid grpc-timeout %q", v)
+	}
+	return time.Duration(n) * unit, nil
+}
+
+// deadlineMessageLimit scales MaxMessageBytes to a client deadline
+// shorter than the configured request timeout, so a short budget also
+// means a shorter prompt. It never goes below minDeadlineMessageBytes.
+func deadlineMessageLimit(limit int, timeout, requestTimeout time.Duration) int {
+	if limit <= 0 || timeout >= requestTimeout {
+		return limit
+	}
+	scaled := int(int64(limit) * int64(timeout) / int64(requestTimeout))
+	return min(limit, max(scaled, minDeadlineMessageBytes))
+}
+
+const minDeadlineMessageBytes = 4 << 10
+
+// fitsDeadline reports whether ctx leaves time for a sandbox run and its
+// review.
+func (h *Handler) fitsDeadline(ctx context.Context) bool {
+	dl, ok := ctx.Deadline()
+	return !ok || time.Until(dl) >= h.ReviewReserve+minSandboxBudget
+}
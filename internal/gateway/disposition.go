@@ -0,0 +1,33 @@
+package gateway
+
+import (
+	"context"
+	"errors"
+	"net/http"
+)
+
+// Disposition is how a chat request ended, as recorded in metrics.
+type Disposition string
+
+const (
+	DispositionSuccess Disposition = "success"
+	DispositionError   Disposition = "error"
+	DispositionTimeout Disposition = "timeout"
+	DispositionInvalid Disposition = "invalid_request"
+	// DispositionClientAbandoned means the client went away before we could
+	// answer; downstream work was cancelled and nothing was written back.
+	DispositionClientAbandoned Disposition = "client_abandoned"
+)
+
+// classifyDisposition refines the disposition at the end of a request.
+// A cancelled client context always wins: whatever error we hit was most
+// likely caused by our own cancellation, not a real failure.
+func classifyDisposition(r *http.Request, ctx context.Context, d Disposition) Disposition {
+	if r.Context().Err() != nil {
+		return DispositionClientAbandoned
+	}
+	if d == DispositionError && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return DispositionTimeout
+	}
+	return d
+}
@@ -0,0 +1,66 @@
+package gateway
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"log/slog"
+	"time"
+
+	"github.com/shivansh-source/nopass/internal/dlp"
+	"github.com/shivansh-source/nopass/internal/storage"
+	"github.com/shivansh-source/nopass/internal/types"
+)
+
+// inputTexts is everything of a request the model will read.
+func inputTexts(req *types.ChatRequest) []string {
+	texts := []string{req.Message}
+	for _, t := range req.History {
+		texts = append(texts, t.Content)
+	}
+	for _, d := range req.ExternalData {
+		texts = append(texts, d.Content)
+	}
+	return texts
+}
+
+// dlpAudit is the Data of a "dlp" audit record.
+type dlpAudit struct {
+	UserID    string     `json:"user_id,omitempty"`
+	SessionID string     `json:"session_id,omitempty"`
+	Path      types.Path `json:"path"`
+	Input     []string   `json:"input,omitempty"`
+	Output    []string   `json:"output,omitempty"`
+	Action    dlp.Action `json:"action,omitempty"`
+	Classes   []string   `json:"classes,omitempty"` // the classes that triggered Action
+}
+
+func newAuditID() string {
+	var b [12]byte
+	_, _ = rand.Read(b[:])
+	return "aud_" + hex.EncodeToString(b[:])
+}
+
+// auditDLP records the data classes seen in a request, if there were any.
+func (h *Handler) auditDLP(ctx context.Context, tenantID string, rec dlpAudit) {
+	if h.Audit == nil || len(rec.Input)+len(rec.Output) == 0 {
+		return
+	}
+	data, err := json.Marshal(rec)
+	if err != nil {
+		slog.ErrorContext(ctx, "encode DLP audit record error", "err", err)
+		return
+	}
+	err = h.Audit.Append(context.WithoutCancel(ctx), storage.AuditRecord{
+		ID:       newAuditID(),
+		TenantID: tenantID,
+		Time:     time.Now().UTC(),
+		Kind:     "dlp",
+		Actor:    rec.UserID,
+		Data:     data,
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "append DLP audit record error", "tenant", tenantID, "err", err)
+	}
+}
@@ -0,0 +1,83 @@
+package gateway
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/shivansh-source/nopass/internal/audit"
+	"github.com/shivansh-source/nopass/internal/auth"
+	"github.com/shivansh-source/nopass/internal/explain"
+	"github.com/shivansh-source/nopass/internal/types"
+)
+
+// flagApprovalPending marks an answer an approval gate withheld, beside
+// the gated flags that sent it there.
+const flagApprovalPending = "approval_pending"
+
+// ExplanationHandler serves GET /v1/requests/{id}/explanation: why the
+// caller's tenant's request id (its X-Request-ID) was blocked or its
+// answer changed, worded for the end user. A token acting for a user may
+// only ask about that user's requests.
+func (h *Handler) ExplanationHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if h.Explanations == nil {
+		http.Error(w, "explanations are not enabled", http.StatusNotFound)
+		return
+	}
+	tenantID := h.tenantID(r, &types.ChatRequest{TenantID: r.URL.Query().Get("tenant_id")})
+	id := r.PathValue("id")
+	o, ok := h.RecentOutcomes.Get(tenantID, id)
+	if !ok {
+		var err error
+		if o, ok, err = h.auditedOutcome(r.Context(), tenantID, id); err != nil {
+			slog.WarnContext(r.Context(), "explanation lookup error", "err", err)
+			http.Error(w, "internal error", http.StatusInternalServerError)
+			return
+		}
+	}
+	if ident := auth.IdentityFrom(r.Context()); ok && ident != nil && o.UserID != ident.UserID {
+		ok = false
+	}
+	if !ok {
+		http.Error(w, "request not found", http.StatusNotFound)
+		return
+	}
+	e, err := h.Explanations.Explain(o)
+	if err != nil {
+		slog.WarnContext(r.Context(), "explanation error", "err", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(e); err != nil {
+		slog.WarnContext(r.Context(), "encode response error", "err", err)
+	}
+}
+
+// auditedOutcome looks request id up in the audit log, for requests
+// another instance answered or RecentOutcomes no longer holds. It looks
+// back as far as RecentOutcomes keeps outcomes.
+func (h *Handler) auditedOutcome(ctx context.Context, tenantID, id string) (explain.Outcome, bool, error) {
+	if h.AuditLog == nil {
+		return explain.Outcome{}, false, nil
+	}
+	src, ok := h.AuditLog.Sink.(audit.Source)
+	if !ok {
+		return explain.Outcome{}, false, nil
+	}
+	f := audit.Filter{TenantID: tenantID, RequestID: id}
+	if ttl := h.RecentOutcomes.TTL(); ttl > 0 {
+		f.Since = time.Now().Add(-ttl)
+	}
+	entries, _, err := audit.Search(ctx, src, f, 1, "")
+	if err != nil || len(entries) == 0 {
+		return explain.Outcome{}, false, err
+	}
+	return explain.FromTransaction(tenantID, entries[0].Time, entries[0].Transaction), true, nil
+}
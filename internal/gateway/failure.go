@@ -0,0 +1,82 @@
+package gateway
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/shivansh-source/nopass/internal/config"
+	"github.com/shivansh-source/nopass/internal/metrics"
+	"github.com/shivansh-source/nopass/internal/review"
+	"github.com/shivansh-source/nopass/internal/risk"
+	"github.com/shivansh-source/nopass/internal/types"
+)
+
+var stageFailures = metrics.NewCounterVec(
+	"nopass_stage_failures_total",
+	"Pipeline stage failures by stage and the failure policy applied.",
+	"stage", "policy",
+)
+
+// Flags marking verdicts that didn't come from the stage's service.
+const (
+	flagRiskUnscored = "risk_unscored"
+	flagRiskLocal    = "risk_degraded_local"
+	flagUnreviewed   = "output_unreviewed"
+	flagReviewLocal  = "output_degraded_local"
+)
+
+// stageFailed applies policy, a stage's failure policy, to the failure
+// of stage. It reports false, leaving the request to fail, for
+// fail_closed or when the request itself is gone.
+func stageFailed(ctx context.Context, stage, policy string, err error, failures *[]types.StageFailure) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	if policy == "" {
+		policy = config.FailClosed
+	}
+	stageFailures.Inc(stage, policy)
+	if policy == config.FailClosed {
+		return false
+	}
+	slog.WarnContext(ctx, "pipeline stage failed; applying failure policy", "stage", stage, "policy", policy, "err", err)
+	*failures = append(*failures, types.StageFailure{Stage: stage, Policy: policy})
+	return true
+}
+
+// riskFallback is the verdict used when risk scoring failed under policy.
+func riskFallback(ctx context.Context, policy string, req *types.ChatRequest) *types.RiskResponse {
+	if policy == config.DegradeToLocal {
+		resp, _ := risk.Engine{}.ScorePrompt(ctx, req.Message, req.UserID, req.SessionID)
+		resp.Flags = append(resp.Flags, flagRiskLocal)
+		return resp
+	}
+	return &types.RiskResponse{
+		SchemaVersion:   types.SchemaVersion,
+		SanitizedPrompt: req.Message,
+		RiskLevel:       types.RiskLow,
+		Flags:           []string{flagRiskUnscored},
+	}
+}
+
+// reviewFallback is the review used when output safety failed under
+// policy. The local engine keeps the prompt canary check, if any.
+func (h *Handler) reviewFallback(ctx context.Context, policy, canary string, req types.OutputSafetyRequest) (*types.OutputSafetyResponse, error) {
+	if policy == config.DegradeToLocal {
+		var local review.OutputReviewer = review.Engine{Policies: h.Policies}
+		if canary != "" {
+			local = review.PromptLeak{Next: local, Canary: canary}
+		}
+		resp, err := local.Review(ctx, req)
+		if err != nil {
+			return nil, err
+		}
+		resp.ReasonFlags = append(resp.ReasonFlags, flagReviewLocal)
+		return resp, nil
+	}
+	return &types.OutputSafetyResponse{
+		SchemaVersion: types.SchemaVersion,
+		FinalAnswer:   req.DraftAnswer,
+		ReasonFlags:   []string{flagUnreviewed},
+	}, nil
+}
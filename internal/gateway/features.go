@@ -0,0 +1,80 @@
+package gateway
+
+import (
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/shivansh-source/nopass/internal/features"
+	"github.com/shivansh-source/nopass/internal/types"
+)
+
+type feedbackRequest struct {
+	FeatureID string `json:"feature_id"`
+	Label     string `json:"label"`
+	TenantID  string `json:"tenant_id,omitempty"`
+}
+
+// feedbackLabel is what a label may look like: a short snake_case word.
+var feedbackLabel = regexp.MustCompile(`^[a-z][a-z0-9_]{0,31}$`)
+
+// FeedbackHandler serves POST /v1/feedback {"feature_id", "label"}: a
+// label (e.g. "helpful", "false_refusal", "unsafe_answer") for the answer
+// whose response carried feature_id, exported as a feedback feature record.
+func (h *Handler) FeedbackHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if h.Features == nil {
+		http.Error(w, "feature export is not enabled", http.StatusNotFound)
+		return
+	}
+	var req feedbackRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 4<<10)).Decode(&req); err != nil {
+		http.Error(w, "invalid JSON body", http.StatusBadRequest)
+		return
+	}
+	if !strings.HasPrefix(req.FeatureID, "ftr_") {
+		http.Error(w, "feature_id is required", http.StatusBadRequest)
+		return
+	}
+	if !feedbackLabel.MatchString(req.Label) {
+		http.Error(w, "label must be a short snake_case word", http.StatusBadRequest)
+		return
+	}
+	tenantID := h.tenantID(r, &types.ChatRequest{TenantID: req.TenantID})
+	h.Tuning.Feedback(tenantID, req.FeatureID, req.Label)
+	h.Features.Emit(features.Record{
+		ID:        features.NewID(),
+		Kind:      features.KindFeedback,
+		Time:      time.Now(),
+		Tenant:    tenantID,
+		RequestID: req.FeatureID,
+		Label:     req.Label,
+	})
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// inputFeatures describes a request's shape for its feature record.
+func inputFeatures(req *types.ChatRequest, truncated bool) *features.Input {
+	return &features.Input{
+		MessageChars:   len([]rune(req.Message)),
+		HistoryTurns:   len(req.History),
+		ExternalBlocks: len(req.ExternalData),
+		Truncated:      truncated,
+	}
+}
+
+// dangerousBlocks counts the external data blocks the scan flagged.
+func dangerousBlocks(data []types.ExternalData) int {
+	n := 0
+	for _, d := range data {
+		if d.IsDangerous {
+			n++
+		}
+	}
+	return n
+}
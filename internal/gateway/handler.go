@@ -2,141 +2,1165 @@ package gateway
 
 import (
 	"context"
-	"encoding/json"
-	"log"
+	"errors"
+	"fmt"
+	"log/slog"
 	"net/http"
+	"slices"
+	"strconv"
+	"strings"
 	"time"
 
+	"github.com/shivansh-source/nopass/internal/answercache"
+	"github.com/shivansh-source/nopass/internal/approval"
+	"github.com/shivansh-source/nopass/internal/artifacts"
+	"github.com/shivansh-source/nopass/internal/audit"
+	"github.com/shivansh-source/nopass/internal/auth"
+	"github.com/shivansh-source/nopass/internal/canary"
+	"github.com/shivansh-source/nopass/internal/config"
+	"github.com/shivansh-source/nopass/internal/datastore"
+	"github.com/shivansh-source/nopass/internal/directory"
+	"github.com/shivansh-source/nopass/internal/dlp"
+	"github.com/shivansh-source/nopass/internal/explain"
+	"github.com/shivansh-source/nopass/internal/features"
+	"github.com/shivansh-source/nopass/internal/ingest"
+	"github.com/shivansh-source/nopass/internal/jobs"
+	"github.com/shivansh-source/nopass/internal/logging"
+	"github.com/shivansh-source/nopass/internal/masksample"
+	"github.com/shivansh-source/nopass/internal/memory"
+	"github.com/shivansh-source/nopass/internal/metrics"
 	"github.com/shivansh-source/nopass/internal/orchestrator"
+	"github.com/shivansh-source/nopass/internal/pii"
+	"github.com/shivansh-source/nopass/internal/policy"
+	"github.com/shivansh-source/nopass/internal/postprocess"
+	"github.com/shivansh-source/nopass/internal/profanity"
+	"github.com/shivansh-source/nopass/internal/receipts"
+	"github.com/shivansh-source/nopass/internal/refusalcache"
+	"github.com/shivansh-source/nopass/internal/retrieval"
+	"github.com/shivansh-source/nopass/internal/review"
+	"github.com/shivansh-source/nopass/internal/risk"
+	"github.com/shivansh-source/nopass/internal/riskledger"
 	"github.com/shivansh-source/nopass/internal/sandbox"
+	"github.com/shivansh-source/nopass/internal/scanledger"
+	"github.com/shivansh-source/nopass/internal/scheduler"
+	"github.com/shivansh-source/nopass/internal/storage"
+	"github.com/shivansh-source/nopass/internal/topic"
+	"github.com/shivansh-source/nopass/internal/tracing"
+	"github.com/shivansh-source/nopass/internal/tuning"
 	"github.com/shivansh-source/nopass/internal/types"
+	"github.com/shivansh-source/nopass/internal/vault"
 )
 
 type Handler struct {
-	RiskClient         *RiskClient
-	LLMRunner          *orchestrator.LLMRunner
-	OutputSafetyClient *OutputSafetyClient
+	// Risk scores prompts and external data: usually the remote
+	// RiskClient, or a risk.Fallback to the in-process rules engine.
+	Risk      risk.Scorer
+	LLMRunner orchestrator.Runner
+	// OutputReviewer is the output safety stage: usually the remote
+	// OutputSafetyClient, or a review.Panel combining several reviewers.
+	OutputReviewer review.OutputReviewer
+
+	// Admission, if set, bounds concurrent sandbox runs and orders waiting
+	// requests by priority.
+	Admission *scheduler.Admission
+	// Sessions, if set, runs each session's turns one at a time, in
+	// order; requests without a session ID aren't queued.
+	Sessions *scheduler.SessionQueue
+	// PromptCanary hides a per-request marker in the system prompt and
+	// withholds answers that repeat it. It costs prompt caching.
+	PromptCanary bool
+	// Artifacts, if set, collects the files the sandboxed model writes to
+	// /app/output and returns them as signed download URLs.
+	Artifacts *artifacts.Store
+	// PromptSource selects which version of the user's message is sent to
+	// the model: PromptSourceRaw (default) or PromptSourceSanitized.
+	PromptSource string
+	// DataStore, if set, enables POST /v1/data and ChatRequest.DataRefs.
+	DataStore datastore.Store
+	// Uploads, if set, enables resumable uploads (/v1/uploads) into
+	// DataStore.
+	Uploads *ingest.Spool
+	// ScanLedger, if set, lets scans be skipped for content already scanned
+	// under the current PolicyVersion.
+	ScanLedger scanledger.Ledger
+	// MaxMessageBytes truncates longer user messages (0 = unlimited).
+	// Client deadlines shorter than the request timeout scale it down.
+	MaxMessageBytes int
+	// ReviewReserve is kept back for the output review from a client's
+	// deadline (X-Request-Deadline) when the sandbox run is bounded.
+	ReviewReserve time.Duration
+	// OverflowToData stores the truncated remainder in DataStore instead of
+	// dropping it.
+	OverflowToData bool
+	// PostProcessors, if set, runs per-tenant transforms on the final
+	// answer after output safety.
+	PostProcessors *postprocess.Registry
+	// Memory, if set, compacts long histories into a summary block.
+	Memory *memory.Compactor
+	// RiskLedger, if set, accumulates risk across a session's turns and
+	// escalates (slow path, then refusal) sessions that keep probing.
+	RiskLedger *riskledger.Ledger
+	// History, if set, stores each session's turns so requests without a
+	// history of their own continue the stored conversation.
+	History *memory.History
+	// Retrieval, if set, serves ChatRequest.Retrieve from the tenant's
+	// connectors.
+	Retrieval *retrieval.Registry
+	// ExcludeOnScanFailure drops external data whose scan failed instead of
+	// passing it to the model quarantined.
+	ExcludeOnScanFailure bool
+	// ScanConcurrency is how many external data blocks a request scans at
+	// once (0 or 1 scans them one by one).
+	ScanConcurrency int
+	// ScanTimeout, if set, bounds each block's scan; a block that runs
+	// over counts as a failed scan.
+	ScanTimeout time.Duration
+	// Approvals, if set, sends answers carrying gated flags to an external
+	// approval service before release.
+	Approvals *approval.Gate
+	// Quarantine, if set, lists every external data block held back from
+	// the model for operators.
+	Quarantine storage.QuarantineStore
+	// Directory, if set, rejects chat requests from tenants and users
+	// deactivated through SCIM provisioning.
+	Directory *directory.Directory
+	// StreamReviewBytes is how much streamed answer text is held back
+	// before each incremental output-safety review (0 = 256 bytes).
+	StreamReviewBytes int
+	// Policies, if set, holds the synced policy set (system prompt, masking
+	// rules, blocklist); without one the built-in defaults apply.
+	Policies *policy.Store
+	// Settings holds the settings a config reload can change; nil uses
+	// the defaults.
+	Settings *config.Live
+	// DLP, if set, labels inputs and answers with data classes and applies
+	// its rules to them (forcing the slow path, refusing, withholding).
+	DLP *dlp.Classifier
+	// Topics, if set, refuses or redirects prompts and answers outside a
+	// tenant's domain.
+	Topics *topic.Guard
+	// Profanity, if set, masks, removes or withholds profanity and
+	// harassment in answers, per tenant.
+	Profanity *profanity.Filter
+	// Audit, if set, records the data classes found in each request.
+	Audit storage.AuditStore
+	// Jobs, if set, runs batch jobs submitted to /v1/jobs.
+	Jobs *jobs.Runner
+	// Refusals, if set, remembers refused requests per user or session and
+	// refuses exact repeats again without scoring or running them.
+	Refusals *refusalcache.Cache
+	// Answers, if set, remembers the reviewed answers to low-risk
+	// single-turn requests and gives them again to identical requests
+	// without a sandbox run.
+	Answers *answercache.Cache
+	// PII, if set, finds personal data the built-in masking patterns
+	// miss (names, addresses, national IDs), e.g. with an entity
+	// recognition service; what it finds is masked too.
+	PII pii.Detector
+	// Vault, if set, keeps the values behind each session's masking
+	// tokens so answers can restore them in later turns too (see
+	// policy.Set.RestoreTokens).
+	Vault *vault.Vault
+	// Features, if set, exports an anonymized feature record of every
+	// request for model retraining.
+	Features *features.Exporter
+	// Tuning, if set, tracks refusal reasons and false-positive feedback
+	// per tenant and applies the threshold adjustments operators approve.
+	// It needs Features, whose IDs feedback cites.
+	Tuning *tuning.Tuner
+	// MaskSamples, if set, keeps a share of user messages with their
+	// masked spans, sealed, for people to label (see package masksample).
+	MaskSamples *masksample.Store
+	// Explanations, if set, serves GET /v1/requests/{id}/explanation from
+	// the outcomes RecentOutcomes keeps, or else the audit log's.
+	Explanations   *explain.Templates
+	RecentOutcomes *explain.Recent
+	// AuditLog, if set, keeps the compliance record of every chat
+	// transaction.
+	AuditLog *audit.Log
+	// Mirror, if set, sends a sample of requests, with the downstream
+	// responses they got, to a canary build for comparison.
+	Mirror *canary.Mirror
+	// Receipts, if set, stores the sandbox receipt of every run.
+	Receipts receipts.Store
+	// PolicyVersion identifies the active detection policy (quarantine
+	// rules, risk model). Changing it invalidates every recorded scan.
+	PolicyVersion string
+
+	// replay is set on the copy serving a canary replay, which must not
+	// write to caches.
+	replay bool
 }
 
 func NewHandler(
-	riskClient *RiskClient,
-	llmRunner *orchestrator.LLMRunner,
-	outputClient *OutputSafetyClient,
+	riskScorer risk.Scorer,
+	llmRunner orchestrator.Runner,
+	outputReviewer review.OutputReviewer,
 ) *Handler {
 	return &Handler{
-		RiskClient:         riskClient,
-		LLMRunner:          llmRunner,
-		OutputSafetyClient: outputClient,
+		Risk:           riskScorer,
+		LLMRunner:      llmRunner,
+		OutputReviewer: outputReviewer,
 	}
 }
 
 func (h *Handler) ChatHandler(w http.ResponseWriter, r *http.Request) {
+	h.serveChat(w, r, nativeFormat{})
+}
+
+// serveChat runs the chat pipeline for a request in wire format f.
+func (h *Handler) serveChat(w http.ResponseWriter, r *http.Request, f wireFormat) {
 	if r.Method != http.MethodPost {
 		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
+	if tape := canary.TapeFrom(r.Context()); tape != nil && tape.Replaying() {
+		h = h.replayHandler()
+	}
+
+	// One settings snapshot for the whole request, even across a reload.
+	settings := h.Settings.Load()
+	// Trusted clients may bring their own deadline (X-Request-Deadline).
+	timeout, clientDeadline, deadlineErr := requestTimeout(r, auth.KeyFrom(r.Context()), settings)
+	ctx, cancel := context.WithTimeout(r.Context(), timeout)
+	defer cancel()
+	ctx, span := tracing.Start(tracing.Extract(ctx, r.Header), "nopass.chat", tracing.Server)
+	requestID := logging.RequestID(r.Header.Get(logging.Header))
+	w.Header().Set(logging.Header, requestID)
+	ctx = logging.WithRequest(ctx, requestID)
+
+	start := time.Now()
+	req := new(types.ChatRequest)
+	disposition := DispositionError
+	var result canary.Result
+	// feat is filled in stage by stage as the request passes them.
+	feat := features.Record{ID: features.NewID(), Kind: features.KindRequest, Time: start}
+	// tx, likewise; even a request refused at once leaves an audit record.
+	tx := &audit.Transaction{RequestID: requestID}
+	// slowLevel is the slow-path threshold the request was routed by.
+	var slowLevel types.RiskLevel
+	var stream answerStream
+	defer func() {
+		// A stage that panics fails the request like any other error: it is
+		// answered, counted and audited. Aborts are passed on once recorded.
+		p := recover()
+		if p != nil && p != http.ErrAbortHandler {
+			chatPanic(r, p)
+			disposition = DispositionError
+			pipelineError(w, stream, "internal error", http.StatusInternalServerError)
+			p = nil
+		}
+		disposition = classifyDisposition(r, ctx, disposition)
+		metrics.ChatDispositions.Inc(string(disposition))
+		requestDuration.ObserveSince(start, string(disposition))
+		if feat.Path != "" {
+			pathTotal.Inc(string(feat.Path))
+		}
+		span.SetAttr("nopass.disposition", string(disposition))
+		span.SetAttr("nopass.path", string(feat.Path))
+		if disposition == DispositionError || disposition == DispositionTimeout {
+			span.End(fmt.Errorf("chat request ended with %s", disposition))
+		} else {
+			span.End(nil)
+		}
+		feat.Disposition = string(disposition)
+		feat.Techniques = requestTechniques(&feat)
+		h.recordTransaction(ctx, tx, &feat)
+		if feat.Output != nil {
+			h.Tuning.Observe(tuning.Observation{
+				Tenant:        feat.Tenant,
+				FeatureID:     feat.ID,
+				Time:          start,
+				Path:          feat.Path,
+				SlowRiskLevel: slowLevel,
+				Withheld:      feat.Output.Withheld,
+				Modified:      feat.Output.Modified,
+				Flags:         feat.Output.Flags,
+				Techniques:    feat.Techniques,
+			})
+		}
+		h.Features.Emit(feat)
+		if disposition == DispositionClientAbandoned {
+			slog.InfoContext(ctx, "client abandoned request; downstream work cancelled")
+		}
+		if tape := canary.TapeFrom(ctx); tape != nil {
+			result.Disposition = string(disposition)
+			result.LatencyMs = time.Since(start).Milliseconds()
+			tape.Finish(result)
+		}
+		if p != nil {
+			panic(p)
+		}
+	}()
+
+	if deadlineErr != nil {
+		disposition = DispositionInvalid
+		http.Error(w, deadlineErr.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := f.decode(r, req); err != nil {
+		disposition = DispositionInvalid
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := validateGeneration(req.Generation); err != nil {
+		disposition = DispositionInvalid
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.Locale != "" && !pii.ValidLocale(req.Locale) {
+		disposition = DispositionInvalid
+		http.Error(w, fmt.Sprintf("invalid locale %q", req.Locale), http.StatusBadRequest)
+		return
+	}
+	// A token's subject is the only user it may act for.
+	if id := auth.IdentityFrom(r.Context()); id != nil {
+		if req.UserID == "" {
+			req.UserID = id.UserID
+		} else if req.UserID != id.UserID {
+			disposition = DispositionInvalid
+			http.Error(w, "user_id does not match the token subject", http.StatusForbidden)
+			return
+		}
+	}
+	key := auth.KeyFrom(r.Context())
+	if key != nil && req.Generation != nil && !key.AllowsModel(req.Generation.Provider, req.Generation.Model) {
+		disposition = DispositionInvalid
+		http.Error(w, "model not allowed for this API key", http.StatusForbidden)
+		return
+	}
+	if req.MaskSpans && (key == nil || !key.MaskSpans) {
+		disposition = DispositionInvalid
+		http.Error(w, "mask_spans not allowed for this API key", http.StatusForbidden)
+		return
+	}
+
+	if wantsStream(r, req) {
+		if stream = f.stream(w, req); stream == nil {
+			http.Error(w, "streaming not supported", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	// Sandbox runs are scheduled onto the tenant's own image/runners.
+	tenantID := h.tenantID(r, req)
+	ctx = orchestrator.WithTenant(ctx, tenantID)
+	ctx = orchestrator.WithUser(ctx, req.UserID)
+	feat.Tenant, feat.User, feat.Session = tenantID, req.UserID, req.SessionID
+	tx.UserID, tx.SessionID = req.UserID, req.SessionID
+	logging.Set(ctx, "tenant_id", tenantID)
+	logging.Set(ctx, "user_id", req.UserID)
+	logging.Set(ctx, "session_id", req.SessionID)
+
+	// A mirrored request records its downstream responses for the canary.
+	// Streamed answers aren't mirrored: their incremental reviews depend on
+	// chunk timing, which a replay can't reproduce.
+	if stream == nil && canary.TapeFrom(ctx) == nil {
+		mirrored := *req
+		mirrored.TenantID = tenantID
+		if tape := h.Mirror.Start(&mirrored); tape != nil {
+			ctx = canary.WithTape(ctx, tape)
+		}
+	}
+
+	if h.Directory != nil {
+		if err := h.Directory.CheckActive(ctx, tenantID, req.UserID); err != nil {
+			if errors.Is(err, directory.ErrInactive) {
+				disposition = DispositionInvalid
+				http.Error(w, err.Error(), http.StatusForbidden)
+				return
+			}
+			slog.ErrorContext(ctx, "directory lookup error", "err", err)
+			http.Error(w, "internal error (directory)", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	if h.Sessions != nil && req.SessionID != "" {
+		release, err := h.Sessions.Acquire(ctx, tenantID+"\x00"+req.SessionID)
+		if errors.Is(err, scheduler.ErrSessionBusy) {
+			disposition = DispositionInvalid
+			http.Error(w, err.Error(), http.StatusTooManyRequests)
+			return
+		}
+		if err != nil {
+			slog.WarnContext(ctx, "session queue wait ended", "err", err)
+			http.Error(w, "timed out waiting for the session's earlier requests", http.StatusServiceUnavailable)
+			return
+		}
+		defer release()
+	}
+
+	if err := h.resolveDataRefs(tenantID, req); err != nil {
+		disposition = DispositionInvalid
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 
-	var req types.ChatRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "invalid JSON body", http.StatusBadRequest)
+	// A request refused before for this user or session is refused again
+	// without spending risk scoring or a sandbox run on it.
+	refusalKey, refusalVersion := h.refusalKey(r, req, tenantID)
+	if e, ok := h.Refusals.Get(refusalKey, refusalVersion); ok {
+		slog.InfoContext(ctx, "repeated refused request", "flags", e.Flags)
+		refusalCache.Inc("hit")
+		resp := types.ChatResponse{
+			SchemaVersion: types.SchemaVersion,
+			Answer:        e.Answer,
+			RiskLevel:     e.RiskLevel,
+			Path:          e.Path,
+			Notices:       []string{"request refused: repeat of a refused request"},
+		}
+		out := outcome{withheld: true, flags: append(append([]string(nil), e.Flags...), "cached_refusal")}
+		feat.Risk = &features.Risk{Level: e.RiskLevel}
+		feat.Path = e.Path
+		feat.Output = &features.Output{AnswerChars: len([]rune(e.Answer)), Withheld: true, Flags: out.flags}
+		if h.Features != nil {
+			resp.FeatureID = feat.ID
+		}
+		disposition = DispositionSuccess
+		tx.AnswerSHA256 = audit.HashAnswer(resp.Answer)
+		result = canary.ResultOf(&resp, out.withheld, out.flags)
+		if stream != nil {
+			if err := stream.finish(&resp, out); err != nil {
+				slog.ErrorContext(ctx, "stream response error", "err", err)
+			}
+			return
+		}
+		f.respond(w, &resp, out)
 		return
 	}
 
-	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
-	defer cancel()
+	var notices []string
+	msgLimit := h.MaxMessageBytes
+	if clientDeadline {
+		// A short budget means a shorter prompt too.
+		msgLimit = deadlineMessageLimit(msgLimit, timeout, settings.RequestTimeout)
+		logging.Set(ctx, "deadline_ms", strconv.FormatInt(timeout.Milliseconds(), 10))
+	}
+	notice, truncation := h.enforceMessageLimit(ctx, tenantID, req, msgLimit)
+	if notice != "" {
+		notices = append(notices, notice)
+	}
+
+	// A request without its own history continues the stored conversation.
+	keepHistory := h.History != nil && req.SessionID != ""
+	if keepHistory {
+		var err error
+		if req.ResetSession {
+			err = h.History.Reset(ctx, req.SessionID)
+		} else {
+			// Loaded even when the client sent its own, so another user's
+			// session is refused before anything is saved to it.
+			var stored []types.Turn
+			if stored, err = h.History.Load(ctx, req.SessionID); len(req.History) == 0 {
+				req.History = stored
+			}
+		}
+		if errors.Is(err, storage.ErrNotOwner) {
+			disposition = DispositionInvalid
+			http.Error(w, "session belongs to another user", http.StatusForbidden)
+			return
+		}
+		if err != nil {
+			slog.ErrorContext(ctx, "session history error", "err", err)
+			http.Error(w, "internal error (session history)", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	// Obfuscation is undone before anything matches patterns on the
+	// message; what was undone is kept as risk flags.
+	normFlags := normalizeMessage(settings.Normalize, req)
 
 	// 1) Risk scoring
-	riskResp, err := h.RiskClient.ScorePrompt(ctx, req.Message, req.UserID, req.SessionID)
+	stageStart := time.Now()
+	// Stages whose service failed but whose failure policy let the
+	// request go on.
+	var failures []types.StageFailure
+	riskResp, err := h.Risk.ScorePrompt(ctx, req.Message, req.UserID, req.SessionID)
+	stageDuration.ObserveSince(stageStart, "risk")
+	if err != nil && stageFailed(ctx, "risk", settings.Failure.Risk, err, &failures) {
+		riskResp, err = riskFallback(ctx, settings.Failure.Risk, req), nil
+	}
 	if err != nil {
-		log.Printf("risk scoring error: %v", err)
+		slog.ErrorContext(ctx, "risk scoring error", "err", err)
 		http.Error(w, "internal error (risk scoring)", http.StatusInternalServerError)
 		return
 	}
+	riskResp.Flags = addFlags(riskResp.Flags, normFlags...)
 
-	// 2) Decide fast vs slow path
-	path := decidePath(riskResp)
-	mode := path // "fast" or "slow"
+	// The policy set is read once so the whole request sees one version,
+	// with the request's profile and the tenant's overrides applied.
+	profile := h.policyProfile(r, req)
+	pol := h.Policies.Current().Resolve(tenantID, profile)
+	if pol != nil && profile != "" && pol.Profile != profile {
+		slog.WarnContext(ctx, "policy profile not defined; using the tenant's", "policy", pol.Version, "profile", profile)
+	}
+	if tuned, err := h.Tuning.Adjust(tenantID, pol); err != nil {
+		slog.ErrorContext(ctx, "tuning adjustment error", "err", err)
+	} else {
+		pol = tuned
+	}
+	if term, ok := pol.Blocked(req.Message); ok {
+		slog.WarnContext(ctx, "blocklisted term in request", "policy", pol.Version, "term", term)
+		riskResp.RiskLevel = types.RiskHigh
+		riskResp.Flags = append(riskResp.Flags, "blocklisted_term")
+	}
+	feat.Risk = &features.Risk{Level: riskResp.RiskLevel, Flags: riskResp.Flags, SelfCheckRequired: riskResp.SelfCheckRequired}
+	logging.Set(ctx, "risk_level", string(riskResp.RiskLevel))
 
-	// 3) Scan External Data (Indirect Prompt Injection Defense)
-	// We scan each chunk. If high risk, we mark it as dangerous.
-	for i := range req.ExternalData {
-		// We use the same RiskClient but maybe we want a different threshold or logic later.
-		// For now, we just check the content.
-		risk, err := h.RiskClient.ScorePrompt(ctx, req.ExternalData[i].Content, req.UserID, req.SessionID)
+	// The session's running risk can escalate a turn that looks harmless
+	// on its own. A ledger failure leaves the turn to its own score.
+	var sessionRisk *types.SessionRisk
+	if h.RiskLedger != nil && req.SessionID != "" {
+		sessionRisk, err = h.RiskLedger.Record(ctx, req.SessionID, riskResp)
+		if errors.Is(err, storage.ErrNotOwner) {
+			disposition = DispositionInvalid
+			http.Error(w, "session belongs to another user", http.StatusForbidden)
+			return
+		}
 		if err != nil {
-			log.Printf("error scanning external data %s: %v", req.ExternalData[i].ID, err)
-			// Fail open or closed? Let's fail open but log it for now, or maybe mark dangerous?
-			// Let's mark dangerous to be safe if we can't scan.
-			req.ExternalData[i].IsDangerous = true
-			continue
+			slog.ErrorContext(ctx, "session risk ledger error", "err", err)
+			sessionRisk = nil
+		}
+	}
+	if sessionRisk != nil && sessionRisk.Action == riskledger.ActionBlock {
+		slog.WarnContext(ctx, "session blocked by risk ledger", "score", sessionRisk.Score)
+		disposition = DispositionInvalid
+		http.Error(w, "session blocked after repeated high-risk requests", http.StatusForbidden)
+		return
+	}
+
+	// 2) Decide fast vs slow path
+	paths := policyPaths(settings.Paths, pol)
+	slowLevel = paths.SlowRiskLevel
+	path := decidePath(riskResp, paths)
+	if sessionRisk != nil && sessionRisk.Action == riskledger.ActionSlow {
+		riskResp.Flags = append(riskResp.Flags, "session_escalated")
+		feat.Risk.Flags = riskResp.Flags
+		path = types.PathSlow
+	}
+	feat.Path = path
+	logging.Set(ctx, "path", string(path))
+
+	// The policy can refuse some risk levels and flags outright.
+	refusal := pol.RefusalMessage(review.DefaultRefusal)
+	if reason, ok := pol.Refuses(riskResp); ok {
+		slog.InfoContext(ctx, "request refused by policy", "policy", pol.Version, "reason", reason)
+		resp := types.ChatResponse{
+			SchemaVersion: types.SchemaVersion,
+			Answer:        refusal,
+			RiskLevel:     riskResp.RiskLevel,
+			Path:          path,
+			Notices:       append(notices, "request refused by policy: "+reason),
+			StageFailures: failures,
+		}
+		out := outcome{withheld: true, flags: []string{"policy_refusal:" + reason}}
+		h.cacheRefusal(refusalKey, refusalVersion, refusal, riskResp.RiskLevel, path, out.flags)
+		feat.Output = &features.Output{AnswerChars: len([]rune(refusal)), Withheld: true, Flags: out.flags}
+		if h.Features != nil {
+			resp.FeatureID = feat.ID
+		}
+		disposition = DispositionSuccess
+		tx.AnswerSHA256 = audit.HashAnswer(resp.Answer)
+		result = canary.ResultOf(&resp, out.withheld, out.flags)
+		if stream != nil {
+			if err := stream.finish(&resp, out); err != nil {
+				slog.ErrorContext(ctx, "stream response error", "err", err)
+			}
+			return
 		}
+		f.respond(w, &resp, out)
+		return
+	}
+
+	// Off-topic prompts never reach the model.
+	if v := h.Topics.CheckPrompt(ctx, tenantID, req.Message); v.OffTopic {
+		slog.InfoContext(ctx, "off-topic prompt", "topic", v.Topic)
+		resp := types.ChatResponse{
+			SchemaVersion: types.SchemaVersion,
+			Answer:        v.Reply,
+			RiskLevel:     riskResp.RiskLevel,
+			Path:          path,
+			Notices:       append(notices, "off-topic request: "+v.Topic),
+			StageFailures: failures,
+		}
+		out := outcome{withheld: true, flags: []string{"off_topic:" + v.Topic}}
+		feat.Output = &features.Output{AnswerChars: len([]rune(v.Reply)), Withheld: true, Flags: out.flags}
+		if h.Features != nil {
+			resp.FeatureID = feat.ID
+		}
+		disposition = DispositionSuccess
+		tx.AnswerSHA256 = audit.HashAnswer(resp.Answer)
+		result = canary.ResultOf(&resp, out.withheld, out.flags)
+		if stream != nil {
+			if err := stream.finish(&resp, out); err != nil {
+				slog.ErrorContext(ctx, "stream response error", "err", err)
+			}
+			return
+		}
+		f.respond(w, &resp, out)
+		return
+	}
+
+	// Server-side retrieval: results join the external data and get the
+	// same scanning and masking as client-supplied documents.
+	if req.Retrieve != nil && h.Retrieval != nil {
+		h.retrieve(ctx, tenantID, req)
+	}
+
+	// Data classes in the input can force the slow path or refuse the
+	// request outright.
+	dlpRec := dlpAudit{UserID: req.UserID, SessionID: req.SessionID}
+	dlpRec.Input = h.DLP.Classify(inputTexts(req)...)
+	feat.Input = inputFeatures(req, truncation != nil)
+	feat.Input.Classes = dlpRec.Input
+	switch act, classes := h.DLP.Decide(dlp.Input, path, dlpRec.Input); act {
+	case dlp.ActionBlock:
+		dlpRec.Path, dlpRec.Action, dlpRec.Classes = path, act, classes
+		h.auditDLP(ctx, tenantID, dlpRec)
+		disposition = DispositionInvalid
+		http.Error(w, "request refused by data policy: "+strings.Join(classes, ", "), http.StatusForbidden)
+		return
+	case dlp.ActionSlow:
+		path = types.PathSlow
+		dlpRec.Action, dlpRec.Classes = act, classes
+	}
+	mode := path
+	feat.Path = path
+	logging.Set(ctx, "path", string(path))
+	if clientDeadline && !h.fitsDeadline(ctx) {
+		// A short deadline never buys a lighter path; fail now rather
+		// than start a run that can't be reviewed in time.
+		slog.InfoContext(ctx, "client deadline too short for the path", "deadline", timeout)
+		disposition = DispositionTimeout
+		pipelineError(w, stream, "deadline too short for the "+string(path)+" path", http.StatusGatewayTimeout)
+		return
+	}
 
-		if risk.RiskLevel == "HIGH" {
-			log.Printf("external data %s flagged as HIGH risk", req.ExternalData[i].ID)
-			req.ExternalData[i].IsDangerous = true
+	// 3) Scan External Data (Indirect Prompt Injection Defense)
+	normalizeData(settings.Normalize, req)
+	dataStatus, scanFailure, err := h.scanExternalData(ctx, req, pol, settings.Failure.ExternalScan)
+	if err != nil {
+		return
+	}
+	if scanFailure != nil {
+		failures = append(failures, *scanFailure)
+	}
+	feat.Input.DangerousBlocks = dangerousBlocks(req.ExternalData)
+	externalBlocks.Add(uint64(feat.Input.DangerousBlocks), "dangerous")
+	externalBlocks.Add(uint64(len(req.ExternalData)-feat.Input.DangerousBlocks), "safe")
+	tx.ExternalData = dataVerdicts(req.ExternalData, dataStatus)
+
+	// Compact long conversations before they blow the prompt budget.
+	memorySummary, history := "", req.History
+	if h.Memory != nil && req.SessionID != "" && len(req.History) > 0 {
+		memorySummary, history, err = h.Memory.Compact(ctx, req.SessionID, req.History)
+		if err != nil {
+			// Fall back to the most recent turns only rather than failing.
+			slog.ErrorContext(ctx, "memory compaction error", "err", err)
+			memorySummary, history = "", lastTurns(req.History, h.Memory.KeepRecent)
 		}
 	}
 
 	// 4) Build Semantic Sandbox prompt
 	sbInput := sandbox.SandboxInput{
-		UserMessage: req.Message,
-		Risk:        riskResp,
-		External:    req.ExternalData,
-		UserID:      req.UserID,
-		SessionID:   req.SessionID,
+		UserMessage:   h.modelPrompt(ctx, req, riskResp, tx),
+		Risk:          riskResp,
+		External:      req.ExternalData,
+		UserID:        req.UserID,
+		SessionID:     req.SessionID,
+		RequestID:     requestID,
+		PolicyVersion: h.PolicyVersion,
+		Truncated:     truncation,
+		Memory:        memorySummary,
+		History:       history,
+		Policy:        pol,
+		Masking: &sandbox.MaskOptions{
+			Cards:   settings.Masking.Cards,
+			Emails:  settings.Masking.Emails,
+			Phones:  settings.Masking.Phones,
+			Secrets: settings.Masking.Secrets,
+			Locale:  settings.Masking.Locale,
+		},
+		Tokens:   newTokens(memorySummary, history),
+		Entities: h.detectPII(ctx, req, memorySummary, history),
+	}
+	if req.Locale != "" {
+		sbInput.Masking.Locale = req.Locale
+	}
+	if h.PromptCanary {
+		sbInput.Canary = sandbox.NewCanary()
 	}
 	sbOutput := sandbox.BuildPrompt(sbInput)
+	h.sampleMasking(ctx, tenantID, sbInput)
+	reviewer := h.OutputReviewer
+	if sbInput.Canary != "" {
+		reviewer = review.PromptLeak{Next: reviewer, Canary: sbInput.Canary}
+	}
+
+	reviewReq := types.OutputSafetyRequest{
+		UserPrompt:     req.Message, // original user prompt
+		RiskLevel:      riskResp.RiskLevel,
+		Flags:          riskResp.Flags,
+		Mode:           mode,
+		MaskedPrompt:   sbInput.Mask(sbInput.UserMessage),
+		Sources:        dataSources(req.ExternalData),
+		PolicyID:       h.policyID(tenantID, pol),
+		DataFlowLabels: dataFlowLabels(sbInput),
+	}
+	if key != nil && key.PolicyProfile != "" && (pol == nil || pol.Profile != key.PolicyProfile) {
+		// A profile the policy set doesn't define may still mean
+		// something to the output safety service.
+		reviewReq.PolicyID += "/" + key.PolicyProfile
+	}
+	for _, c := range dlpRec.Input {
+		reviewReq.DataFlowLabels = append(reviewReq.DataFlowLabels, "class:"+c)
+	}
+	feat.Masking = features.MaskCounts(reviewReq.MaskedPrompt)
+	tx.MaskedPrompt = reviewReq.MaskedPrompt
+	for kind, n := range feat.Masking {
+		maskedTokens.Add(uint64(n), kind)
+	}
+
+	// An identical low-risk request answered before gets the same
+	// reviewed answer without a sandbox run.
+	answerKey := h.answerKey(tenantID, req, riskResp, path, sbInput, reviewReq.PolicyID, restoresTokens(pol))
+	if e, ok := h.cachedAnswer(ctx, answerKey); ok {
+		resp := types.ChatResponse{
+			SchemaVersion: types.SchemaVersion,
+			Answer:        e.Answer,
+			RiskLevel:     riskResp.RiskLevel,
+			Path:          path,
+			Notices:       notices,
+			DataStatus:    dataStatus,
+			StageFailures: failures,
+		}
+		out := outcome{flags: append(append([]string(nil), e.Flags...), "cached_answer")}
+		feat.Output = &features.Output{}
+		dlpRec.Path = path
+		dlpRec.Output = h.DLP.Classify(e.Answer)
+		h.auditDLP(ctx, tenantID, dlpRec)
+		var ok bool
+		if result, ok = h.finishResponse(ctx, w, f, stream, req, tenantID, sbInput, dlpRec, &feat, tx, &resp, out); ok {
+			disposition = DispositionSuccess
+		}
+		return
+	}
 
 	// 4) Run inside Docker sandbox (LLM System Sandbox)
-	draftAnswer, err := h.LLMRunner.RunInSandbox(ctx, sbOutput.SystemPrompt, sbOutput.UserContent)
+	var prio scheduler.Priority
+	if h.Admission != nil {
+		prio, err = h.requestPriority(r, req)
+		if err != nil {
+			disposition = DispositionInvalid
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		release, err := h.Admission.Acquire(ctx, prio)
+		if err != nil {
+			slog.WarnContext(ctx, "sandbox admission timed out", "priority", prio, "err", err)
+			http.Error(w, "sandbox capacity exhausted", http.StatusServiceUnavailable)
+			return
+		}
+		defer release()
+	}
+
+	receipt := &types.SandboxReceipt{ID: receipts.NewID(), TenantID: tenantID}
+	runCtx := orchestrator.WithPromptCacheKey(ctx, sbOutput.CacheKey)
+	runCtx = orchestrator.WithReceipt(runCtx, receipt)
+	runCtx = orchestrator.WithEcho(runCtx, &types.SandboxEcho{RequestID: requestID, PolicyVersion: h.PolicyVersion})
+	if req.Generation != nil {
+		runCtx = orchestrator.WithGeneration(runCtx, req.Generation)
+	}
+	runCtx = orchestrator.WithRouteCheck(runCtx, h.routeCheck(ctx, tenantID, req.UserID, req.SessionID, pol))
+	if clientDeadline && h.ReviewReserve > 0 {
+		// Leave the output review its share of the client's budget.
+		if dl, ok := ctx.Deadline(); ok {
+			var cancelRun context.CancelFunc
+			runCtx, cancelRun = context.WithDeadline(runCtx, dl.Add(-h.ReviewReserve))
+			defer cancelRun()
+		}
+	}
+	var arts *orchestrator.Artifacts
+	if h.Artifacts != nil {
+		arts = &orchestrator.Artifacts{MaxFiles: h.Artifacts.MaxFiles, MaxBytes: h.Artifacts.MaxBytes}
+		runCtx = orchestrator.WithArtifacts(runCtx, arts)
+	}
+	var draftAnswer string
+	var outResp *types.OutputSafetyResponse
+	stageStart = time.Now()
+	if stream != nil && !restoresTokens(pol) && h.streamsLive(tenantID, path, riskResp) {
+		// Stream the answer, releasing it in pieces as they pass review.
+		live := &liveReview{ctx: ctx, reviewer: reviewer, req: reviewReq, stream: stream, step: h.StreamReviewBytes}
+		if live.step <= 0 {
+			live.step = defaultStreamReviewBytes
+		}
+		draftAnswer, err = orchestrator.RunStream(runCtx, h.LLMRunner, sbOutput.SystemPrompt, sbOutput.UserContent, live.write)
+		if errors.Is(err, errWithheld) {
+			draftAnswer, err = live.draft.String(), nil
+		}
+	} else if n := candidateCount(paths, path, riskResp); n > 1 {
+		// Draft several answers and keep the one review likes best.
+		var best *candidate
+		best, err = h.draftCandidates(ctx, runCtx, tenantID, prio, n, reviewer, reviewReq, sbOutput.SystemPrompt, sbOutput.UserContent, arts)
+		draftAnswer, receipt, outResp = best.draft, best.receipt, best.review
+	} else {
+		draftAnswer, err = h.LLMRunner.RunInSandbox(runCtx, sbOutput.SystemPrompt, sbOutput.UserContent)
+	}
+	stageDuration.ObserveSince(stageStart, "sandbox")
+	if receipt.StartedAt.IsZero() {
+		// The runner never got as far as starting a sandbox.
+		receipt = nil
+	} else if rerr := receipts.Record(h.Receipts, *receipt); rerr != nil {
+		slog.ErrorContext(ctx, "store sandbox receipt error", "err", rerr)
+	}
+	if errors.Is(err, orchestrator.ErrUnknownProvider) {
+		disposition = DispositionInvalid
+		pipelineError(w, stream, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if errors.Is(err, orchestrator.ErrModelNotAllowed) {
+		slog.WarnContext(ctx, "run refused by tenant model policy", "err", err)
+		disposition = DispositionInvalid
+		pipelineError(w, stream, err.Error(), http.StatusForbidden)
+		return
+	}
 	if err != nil {
-		log.Printf("LLM sandbox error (path=%s): %v", path, err)
-		http.Error(w, "internal error (llm sandbox)", http.StatusInternalServerError)
+		slog.ErrorContext(ctx, "LLM sandbox error", "err", err)
+		pipelineError(w, stream, "internal error (llm sandbox)", http.StatusInternalServerError)
 		return
 	}
 
 	// 5) Output Safety Layer
-	outResp, err := h.OutputSafetyClient.Review(
-		ctx,
-		req.Message, // original user prompt
-		draftAnswer, // draft answer from LLM sandbox
-		riskResp.RiskLevel,
-		riskResp.Flags,
-		mode,
-	)
+	reviewReq.DraftAnswer = draftAnswer // draft answer from LLM sandbox
+	stageStart = time.Now()
+	switch {
+	case outResp != nil:
+		// Candidates were reviewed as they were drafted.
+	case toolAnswer(pol, riskResp, path, sbInput, dataStatus, draftAnswer):
+		// Nothing the model wrote itself is in the answer to review.
+		outResp = &types.OutputSafetyResponse{SchemaVersion: types.SchemaVersion, FinalAnswer: draftAnswer, ReasonFlags: []string{flagToolAnswer}}
+	default:
+		outResp, err = reviewer.Review(ctx, reviewReq)
+	}
+	stageDuration.ObserveSince(stageStart, "output_safety")
+	if err != nil && stageFailed(ctx, "output_safety", settings.Failure.OutputSafety, err, &failures) {
+		outResp, err = h.reviewFallback(ctx, settings.Failure.OutputSafety, sbInput.Canary, reviewReq)
+	}
 	if err != nil {
-		log.Printf("output safety error (path=%s): %v", path, err)
-		http.Error(w, "internal error (output safety)", http.StatusInternalServerError)
+		slog.ErrorContext(ctx, "output safety error", "err", err)
+		pipelineError(w, stream, "internal error (output safety)", http.StatusInternalServerError)
 		return
 	}
 
+	if r.Context().Err() != nil {
+		// The client disconnected while the pipeline ran; don't bother
+		// writing a response nobody will read.
+		return
+	}
+
+	// Flags routed to an external approval workflow hold the answer until
+	// it is approved.
+	answer := outResp.FinalAnswer
+	if outResp.Blocked && answer == review.DefaultRefusal {
+		answer = refusal
+	}
+	out := outcome{withheld: outResp.Blocked, flags: outResp.ReasonFlags}
+	feat.Output = &features.Output{Modified: outResp.WasModified, Blocked: outResp.Blocked}
+	if !outResp.Blocked {
+		// Credentials are redacted whichever engine reviewed the answer.
+		if redacted, ok := redactSecrets(answer); ok {
+			answer = redacted
+			feat.Output.Modified = true
+			if !slices.Contains(out.flags, "secret_redacted") {
+				out.flags = append(out.flags, "secret_redacted")
+			}
+		}
+		// Output safety reviewed the answer with its tokens; the policy
+		// may put some of the values back now, before the data policy
+		// sees it.
+		answer = h.restoreTokens(ctx, tenantID, req.UserID, req.SessionID, pol, sbInput.Tokens, answer)
+	}
+
+	// Data classes the answer may not carry on this path withhold it.
+	dlpRec.Path = path
+	dlpRec.Output = h.DLP.Classify(answer)
+	if act, classes := h.DLP.Decide(dlp.Output, path, dlpRec.Output); act == dlp.ActionBlock {
+		slog.InfoContext(ctx, "answer withheld by data policy", "classes", classes)
+		answer = refusal
+		out.withheld = true
+		for _, c := range classes {
+			out.flags = append(out.flags, "dlp:"+c)
+		}
+		dlpRec.Action, dlpRec.Classes = act, classes
+		notices = append(notices, "answer withheld by data policy: "+strings.Join(classes, ", "))
+	}
+	h.auditDLP(ctx, tenantID, dlpRec)
+
+	// Answers drifting into a denied topic are replaced too.
+	if v := h.Topics.CheckAnswer(ctx, tenantID, answer); v.OffTopic {
+		slog.InfoContext(ctx, "off-topic answer withheld", "topic", v.Topic)
+		answer = v.Reply
+		out.withheld = true
+		out.flags = append(out.flags, "off_topic:"+v.Topic)
+		notices = append(notices, "off-topic answer withheld: "+v.Topic)
+	}
+
+	// Tenants on consumer-facing surfaces get stricter tone control.
+	if res := h.Profanity.Apply(tenantID, answer); len(res.Categories) > 0 {
+		for _, c := range res.Categories {
+			out.flags = append(out.flags, "tone:"+string(c))
+		}
+		if res.Refuse {
+			slog.InfoContext(ctx, "answer withheld by tone filter", "categories", res.Categories)
+			answer = refusal
+			out.withheld = true
+		} else {
+			answer = res.Text
+		}
+	}
+
+	if h.Approvals != nil {
+		flags := append(append([]string(nil), riskResp.Flags...), outResp.ReasonFlags...)
+		res := h.Approvals.Check(ctx, approval.Request{
+			TenantID:    tenantID,
+			UserID:      req.UserID,
+			SessionID:   req.SessionID,
+			RiskLevel:   riskResp.RiskLevel,
+			UserPrompt:  sbInput.Mask(req.Message),
+			DraftAnswer: answer,
+		}, flags)
+		if !res.Approved {
+			slog.InfoContext(ctx, "answer withheld by approval gate", "flags", res.Flags, "reason", res.Reason)
+			answer = refusal
+			out.withheld = true
+			out.flags = append(append(out.flags, res.Flags...), flagApprovalPending)
+			notices = append(notices, "answer withheld pending approval: "+res.Reason)
+		}
+	}
+
+	if !out.withheld && len(failures) == 0 {
+		h.cacheAnswer(ctx, answerKey, answer, riskResp.RiskLevel, path, out.flags)
+	}
+	if outResp.Blocked {
+		h.cacheRefusal(refusalKey, refusalVersion, answer, riskResp.RiskLevel, path, out.flags)
+	}
+
+	// Files travel with the answer: a withheld answer keeps none.
+	var kept []types.Artifact
+	if !out.withheld {
+		kept = h.keepArtifacts(ctx, tenantID, arts, reviewer, reviewReq, path, &out, &notices)
+	}
+
+	// Store the exchange, masked turn by turn, for the next request.
+	var historyTurns int
+	if keepHistory {
+		turns := make([]types.Turn, 0, len(req.History)+2)
+		for _, t := range req.History {
+			turns = append(turns, types.Turn{Role: t.Role, Content: sbInput.Mask(t.Content)})
+		}
+		turns = append(turns,
+			types.Turn{Role: "user", Content: sbInput.Mask(req.Message)},
+			types.Turn{Role: "assistant", Content: sbInput.Mask(answer)})
+		if historyTurns, err = h.History.Save(ctx, req.SessionID, turns); err != nil {
+			slog.ErrorContext(ctx, "save session history error", "err", err)
+			notices = append(notices, "conversation history was not saved")
+		}
+	}
+
+	// Stored last, so tokens made masking the saved history are kept too.
+	h.storeTokens(ctx, tenantID, req.UserID, req.SessionID, sbInput.Tokens)
+
 	resp := types.ChatResponse{
-		Answer:    outResp.FinalAnswer,
-		RiskLevel: riskResp.RiskLevel,
-		Path:      path,
+		SchemaVersion: types.SchemaVersion,
+		Answer:        answer,
+		RiskLevel:     riskResp.RiskLevel,
+		Path:          path,
+		Notices:       notices,
+		DataStatus:    dataStatus,
+		Receipt:       receipt,
+		HistoryTurns:  historyTurns,
+		SessionRisk:   sessionRisk,
+		Artifacts:     kept,
+		StageFailures: failures,
+	}
+	if keepHistory {
+		resp.SessionID = req.SessionID
+	}
+	var ok bool
+	if result, ok = h.finishResponse(ctx, w, f, stream, req, tenantID, sbInput, dlpRec, &feat, tx, &resp, out); ok {
+		disposition = DispositionSuccess
+	}
+}
+
+// finishResponse completes an answer's response with what every answer
+// carries (data classes, feature ID, mask spans), runs post-processing and
+// writes it, recording the answer in feat and tx. It returns the canary
+// result, and false if post-processing failed and an error was written
+// instead.
+func (h *Handler) finishResponse(
+	ctx context.Context,
+	w http.ResponseWriter,
+	f wireFormat,
+	stream answerStream,
+	req *types.ChatRequest,
+	tenantID string,
+	sbInput sandbox.SandboxInput,
+	dlpRec dlpAudit,
+	feat *features.Record,
+	tx *audit.Transaction,
+	resp *types.ChatResponse,
+	out outcome,
+) (canary.Result, bool) {
+	if len(dlpRec.Input)+len(dlpRec.Output) > 0 {
+		resp.DataClasses = &types.DataClasses{Input: dlpRec.Input, Output: dlpRec.Output}
+	}
+	feat.Output.AnswerChars = len([]rune(resp.Answer))
+	feat.Output.Withheld, feat.Output.Flags = out.withheld, out.flags
+	if h.Features != nil {
+		resp.FeatureID = feat.ID
+	}
+	if req.MaskSpans {
+		_, resp.MaskedSpans = sbInput.MaskSpans(req.Message)
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(w).Encode(resp); err != nil {
-		log.Printf("encode response error: %v", err)
+	// 6) Application-specific post-processing
+	if h.PostProcessors != nil {
+		if err := h.PostProcessors.Run(ctx, tenantID, resp); err != nil {
+			slog.ErrorContext(ctx, "post-processing error", "err", err)
+			pipelineError(w, stream, "internal error (post-processing)", http.StatusInternalServerError)
+			return canary.Result{}, false
+		}
+	}
+
+	tx.AnswerSHA256 = audit.HashAnswer(resp.Answer)
+	result := canary.ResultOf(resp, out.withheld, out.flags)
+	if stream != nil {
+		if err := stream.finish(resp, out); err != nil {
+			slog.ErrorContext(ctx, "stream response error", "err", err)
+		}
+		return result, true
 	}
+	f.respond(w, resp, out)
+	return result, true
+}
+
+// policyPaths applies the policy set's path thresholds over the
+// configured ones.
+func policyPaths(p config.Paths, pol *policy.Set) config.Paths {
+	if pol == nil {
+		return p
+	}
+	if pol.Paths.SlowRiskLevel != "" {
+		p.SlowRiskLevel = pol.Paths.SlowRiskLevel
+	}
+	if pol.Paths.SelfCheckSlow != nil {
+		p.SelfCheckSlow = *pol.Paths.SelfCheckSlow
+	}
+	return p
 }
 
 // decidePath implements fast vs slow path logic based on risk metadata.
-func decidePath(risk *types.RiskResponse) string {
+func decidePath(risk *types.RiskResponse, p config.Paths) types.Path {
 	// default path
-	path := "fast"
+	path := types.PathFast
 
 	// Escalate to slow path if:
-	//   - risk is HIGH
-	//   - OR self_check_required is true
-	if risk.RiskLevel == "HIGH" || risk.SelfCheckRequired {
-		path = "slow"
+	//   - risk is at or above the configured level (HIGH by default)
+	//   - OR self_check_required is true, unless configured otherwise
+	if risk.RiskLevel.Rank() >= p.SlowRiskLevel.Rank() || (risk.SelfCheckRequired && p.SelfCheckSlow) {
+		path = types.PathSlow
 	}
 
 	return path
 }
 
+// Retrieval limits per connector.
+const (
+	defaultRetrieveLimit = 3
+	maxRetrieveLimit     = 10
+)
+
+// retrieve appends connector results to req.ExternalData. Failures are
+// logged and the request continues without retrieved data.
+func (h *Handler) retrieve(ctx context.Context, tenantID string, req *types.ChatRequest) {
+	query := req.Retrieve.Query
+	if query == "" {
+		query = req.Message
+	}
+	limit := req.Retrieve.Limit
+	if limit <= 0 {
+		limit = defaultRetrieveLimit
+	}
+	if limit > maxRetrieveLimit {
+		limit = maxRetrieveLimit
+	}
+
+	docs, err := h.Retrieval.Search(ctx, tenantID, query, req.Retrieve.Sources, limit)
+	if err != nil {
+		slog.ErrorContext(ctx, "retrieval error", "err", err)
+		return
+	}
+	req.ExternalData = append(req.ExternalData, docs...)
+}
+
+// lastTurns returns the final n turns of history.
+func lastTurns(history []types.Turn, n int) []types.Turn {
+	if len(history) <= n {
+		return history
+	}
+	return history[len(history)-n:]
+}
+
+// tenantID resolves the tenant a request belongs to: the X-NoPass-Tenant
+// header wins over the body field.
+func (h *Handler) tenantID(r *http.Request, req *types.ChatRequest) string {
+	if v := r.Header.Get("X-NoPass-Tenant"); v != "" {
+		return v
+	}
+	return req.TenantID
+}
+
+// policyProfile resolves the policy profile a request asks for: its API
+// key's, then the X-NoPass-Policy-Profile header, then the body field.
+// Callers authenticated by token get their tenant's own profile.
+func (h *Handler) policyProfile(r *http.Request, req *types.ChatRequest) string {
+	if key := auth.KeyFrom(r.Context()); key != nil {
+		return key.PolicyProfile
+	}
+	if auth.IdentityFrom(r.Context()) != nil {
+		return ""
+	}
+	if v := r.Header.Get("X-NoPass-Policy-Profile"); v != "" {
+		return v
+	}
+	return req.PolicyProfile
+}
+
+// requestPriority resolves the scheduling class of a request: its API
+// key's Priority, or the X-NoPass-Priority header, then the body field,
+// for keys allowed to choose (auth.Key.Priorities). Requests without a
+// key, or whose key names no class, are batch.
+func (h *Handler) requestPriority(r *http.Request, req *types.ChatRequest) (scheduler.Priority, error) {
+	key := auth.KeyFrom(r.Context())
+	if key == nil {
+		return scheduler.PriorityBatch, nil
+	}
+	if key.Priorities {
+		if v := r.Header.Get("X-NoPass-Priority"); v != "" {
+			return scheduler.ParsePriority(v)
+		}
+		if req.Priority != "" {
+			return scheduler.ParsePriority(req.Priority)
+		}
+	}
+	if key.Priority == "" {
+		return scheduler.PriorityBatch, nil
+	}
+	return scheduler.ParsePriority(key.Priority)
+}
+
 // stubLLMCall simulates calling the LLM.
 // Later this will:
 //   - spin up Docker sandbox
@@ -144,9 +1168,9 @@ func decidePath(risk *types.RiskResponse) string {
 //   - integrate Output Safety
 func (h *Handler) stubLLMCall(
 	_ context.Context,
-	systemPrompt, userContent, path string,
+	systemPrompt, userContent string, path types.Path,
 ) string {
-	return "[NoPass " + path + " path demo]\n\n" +
+	return "[NoPass " + string(path) + " path demo]\n\n" +
 		"--- SYSTEM PROMPT ---\n" + systemPrompt + "\n\n" +
 		"--- USER CONTENT ---\n" + userContent
 }
@@ -0,0 +1,117 @@
+package gateway
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/shivansh-source/nopass/internal/jobs"
+	"github.com/shivansh-source/nopass/internal/types"
+)
+
+type jobRequest struct {
+	TenantID string              `json:"tenant_id,omitempty"`
+	Items    []types.ChatRequest `json:"items"`
+}
+
+type jobResponse struct {
+	*jobs.Job
+	Items []jobs.Item `json:"items,omitempty"`
+}
+
+// maxJobBodyBytes bounds a job submission.
+const maxJobBodyBytes = 256 << 20
+
+// JobsHandler serves POST /v1/jobs {"items": [ChatRequest, ...]}: a batch
+// job answered in the background. The response is the job; poll
+// GET /v1/jobs/{id} for progress and results.
+func (h *Handler) JobsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if h.Jobs == nil {
+		http.Error(w, "batch jobs are not enabled", http.StatusNotFound)
+		return
+	}
+	var req jobRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxJobBodyBytes)).Decode(&req); err != nil {
+		http.Error(w, "invalid JSON body", http.StatusBadRequest)
+		return
+	}
+	if len(req.Items) == 0 || len(req.Items) > jobs.MaxItems {
+		http.Error(w, "a job needs 1 to "+strconv.Itoa(jobs.MaxItems)+" items", http.StatusBadRequest)
+		return
+	}
+	for i, item := range req.Items {
+		if item.Message == "" {
+			http.Error(w, "item "+strconv.Itoa(i)+": message is required", http.StatusBadRequest)
+			return
+		}
+	}
+	job, err := h.Jobs.Submit(r.Context(), h.tenantID(r, &types.ChatRequest{TenantID: req.TenantID}), req.Items)
+	if err != nil {
+		log.Printf("submit batch job error: %v", err)
+		http.Error(w, "internal error (jobs)", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	if err := json.NewEncoder(w).Encode(job); err != nil {
+		log.Printf("encode response error: %v", err)
+	}
+}
+
+// JobHandler serves GET /v1/jobs/{id}?offset=&limit= (progress and the
+// items from offset on, answered so far or not; limit defaults to 100) and
+// DELETE /v1/jobs/{id} (cancel).
+func (h *Handler) JobHandler(w http.ResponseWriter, r *http.Request) {
+	if h.Jobs == nil {
+		http.Error(w, "batch jobs are not enabled", http.StatusNotFound)
+		return
+	}
+	id := r.PathValue("id")
+	job, err := h.Jobs.Get(r.Context(), id)
+	if err == nil && job.TenantID != h.tenantID(r, &types.ChatRequest{TenantID: r.URL.Query().Get("tenant_id")}) {
+		err = jobs.ErrNotFound
+	}
+	if errors.Is(err, jobs.ErrNotFound) {
+		http.Error(w, "job not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("get batch job error (job=%s): %v", id, err)
+		http.Error(w, "internal error (jobs)", http.StatusInternalServerError)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
+		limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+		if limit <= 0 || limit > 1000 {
+			limit = 100
+		}
+		items, err := h.Jobs.Results(r.Context(), id, max(offset, 0), limit)
+		if err != nil {
+			log.Printf("list batch job items error (job=%s): %v", id, err)
+			http.Error(w, "internal error (jobs)", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(jobResponse{Job: job, Items: items}); err != nil {
+			log.Printf("encode response error: %v", err)
+		}
+	case http.MethodDelete:
+		if err := h.Jobs.Cancel(r.Context(), id); err != nil {
+			log.Printf("cancel batch job error (job=%s): %v", id, err)
+			http.Error(w, "internal error (jobs)", http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
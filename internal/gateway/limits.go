@@ -0,0 +1,88 @@
+package gateway
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+	"unicode/utf8"
+
+	"github.com/shivansh-source/nopass/internal/datastore"
+	"github.com/shivansh-source/nopass/internal/sandbox"
+	"github.com/shivansh-source/nopass/internal/types"
+)
+
+// truncateUTF8 cuts s to at most max bytes without splitting a rune.
+func truncateUTF8(s string, max int) string {
+	if len(s) <= max {
+		return s
+	}
+	cut := max
+	for cut > 0 && !utf8.RuneStart(s[cut]) {
+		cut--
+	}
+	return s[:cut]
+}
+
+// enforceMessageLimit truncates pathological user messages to limit
+// bytes. It returns a client-facing notice (empty if nothing was
+// cut) and the truncation details for the prompt builder. When
+// OverflowToData is enabled the cut-off remainder is registered as a
+// scanned, masked document the client can reference later, instead of
+// being dropped.
+func (h *Handler) enforceMessageLimit(ctx context.Context, tenantID string, req *types.ChatRequest, limit int) (string, *sandbox.Truncation) {
+	if limit <= 0 || len(req.Message) <= limit {
+		return "", nil
+	}
+
+	original := len(req.Message)
+	kept := truncateUTF8(req.Message, limit)
+	overflow := req.Message[len(kept):]
+	req.Message = kept
+
+	t := &sandbox.Truncation{OriginalBytes: original, KeptBytes: len(kept)}
+	notice := fmt.Sprintf("message truncated from %d to %d bytes", original, len(kept))
+
+	if h.OverflowToData && h.DataStore != nil {
+		doc := &datastore.Document{
+			ID:          datastore.NewID(),
+			TenantID:    tenantID,
+			Source:      "user:message_overflow",
+			Type:        "message_overflow",
+			Content:     sandbox.MaskSensitiveText(overflow),
+			ContentHash: datastore.HashContent(overflow),
+			// Not scanned yet: treat as dangerous until a rescan says otherwise.
+			IsDangerous: true,
+			CreatedAt:   time.Now().UTC(),
+		}
+		if err := h.DataStore.Put(doc); err != nil {
+			slog.ErrorContext(ctx, "store message overflow error", "err", err)
+		} else {
+			notice += fmt.Sprintf("; remainder stored as %s (rescan via POST /v1/data/%s/rescan before referencing it)", doc.ID, doc.ID)
+		}
+	}
+
+	slog.WarnContext(ctx, "user message truncated", "user", req.UserID, "session", req.SessionID, "original_bytes", original, "kept_bytes", len(kept))
+	return notice, t
+}
+
+// maxStopSequences caps GenerationParams.Stop, as OpenAI does.
+const maxStopSequences = 4
+
+// validateGeneration rejects sampling settings outside the ranges model
+// backends accept.
+func validateGeneration(g *types.GenerationParams) error {
+	switch {
+	case g == nil:
+		return nil
+	case g.Temperature != nil && (*g.Temperature < 0 || *g.Temperature > 2):
+		return fmt.Errorf("temperature must be between 0 and 2")
+	case g.TopP != nil && (*g.TopP < 0 || *g.TopP > 1):
+		return fmt.Errorf("top_p must be between 0 and 1")
+	case g.MaxTokens < 0:
+		return fmt.Errorf("max_tokens must not be negative")
+	case len(g.Stop) > maxStopSequences:
+		return fmt.Errorf("at most %d stop sequences are allowed", maxStopSequences)
+	}
+	return nil
+}
@@ -0,0 +1,24 @@
+package gateway
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/shivansh-source/nopass/internal/sandbox"
+)
+
+// sampleMasking keeps the user message and what masking did to it for
+// labeling, if the message is sampled. It runs on its own copy of in,
+// apart from the request's tokens, and doesn't hold the request up.
+func (h *Handler) sampleMasking(ctx context.Context, tenantID string, in sandbox.SandboxInput) {
+	if h.MaskSamples == nil || in.UserMessage == "" || !h.MaskSamples.Sampled() {
+		return
+	}
+	in.Tokens = nil
+	_, spans := in.MaskSpans(in.UserMessage)
+	go func() {
+		if err := h.MaskSamples.Put(context.WithoutCancel(ctx), tenantID, in.UserMessage, spans); err != nil {
+			slog.ErrorContext(ctx, "mask sample error", "err", err)
+		}
+	}()
+}
@@ -0,0 +1,38 @@
+package gateway
+
+import "github.com/shivansh-source/nopass/internal/metrics"
+
+// Pipeline metrics, exposed with every other registered metric at the
+// admin listener's /metrics.
+var (
+	requestDuration = metrics.NewHistogramVec(
+		"nopass_chat_request_duration_seconds",
+		"Chat request latency by final disposition.",
+		nil, "disposition",
+	)
+	stageDuration = metrics.NewHistogramVec(
+		"nopass_stage_duration_seconds",
+		"Pipeline stage latency: risk, pii, sandbox or output_safety.",
+		nil, "stage",
+	)
+	pathTotal = metrics.NewCounterVec(
+		"nopass_chat_path_total",
+		"Chat requests by the path they were answered on (fast or slow).",
+		"path",
+	)
+	externalBlocks = metrics.NewCounterVec(
+		"nopass_external_data_blocks_total",
+		"External data blocks scanned, by result (dangerous or safe).",
+		"result",
+	)
+	maskedTokens = metrics.NewCounterVec(
+		"nopass_masked_tokens_total",
+		"Sensitive values masked in prompts before they reach the model, by kind.",
+		"kind",
+	)
+	tokensRestored = metrics.NewCounterVec(
+		"nopass_tokens_restored_total",
+		"Masking tokens in answers by outcome (restored, kept, unknown, error).",
+		"result",
+	)
+)
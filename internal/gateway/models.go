@@ -0,0 +1,73 @@
+package gateway
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/shivansh-source/nopass/internal/metrics"
+	"github.com/shivansh-source/nopass/internal/orchestrator"
+	"github.com/shivansh-source/nopass/internal/policy"
+	"github.com/shivansh-source/nopass/internal/storage"
+)
+
+var modelDenials = metrics.NewCounterVec(
+	"nopass_model_denials_total",
+	"Runs refused because tenant policy doesn't allow the backend they were routed to.",
+	"provider",
+)
+
+// modelAudit is the Data of a "model_denied" audit record.
+type modelAudit struct {
+	UserID    string `json:"user_id,omitempty"`
+	SessionID string `json:"session_id,omitempty"`
+	Provider  string `json:"provider"`
+	Model     string `json:"model,omitempty"`
+	Hosted    bool   `json:"hosted"`
+	Reason    string `json:"reason"`
+}
+
+// routeCheck enforces pol's model restrictions where runs are routed,
+// recording each refusal in the audit log.
+func (h *Handler) routeCheck(ctx context.Context, tenantID, userID, sessionID string, pol *policy.Set) orchestrator.RouteCheck {
+	return func(rt orchestrator.Route) error {
+		reason, ok := pol.AllowsModel(rt.Provider, rt.Model, rt.Hosted)
+		if ok {
+			return nil
+		}
+		modelDenials.Inc(rt.Provider)
+		h.auditModelDenial(ctx, tenantID, modelAudit{
+			UserID:    userID,
+			SessionID: sessionID,
+			Provider:  rt.Provider,
+			Model:     rt.Model,
+			Hosted:    rt.Hosted,
+			Reason:    reason,
+		})
+		return errors.New(reason)
+	}
+}
+
+func (h *Handler) auditModelDenial(ctx context.Context, tenantID string, rec modelAudit) {
+	if h.Audit == nil {
+		return
+	}
+	data, err := json.Marshal(rec)
+	if err != nil {
+		slog.WarnContext(ctx, "encode model audit record error", "err", err)
+		return
+	}
+	err = h.Audit.Append(context.WithoutCancel(ctx), storage.AuditRecord{
+		ID:       newAuditID(),
+		TenantID: tenantID,
+		Time:     time.Now().UTC(),
+		Kind:     "model_denied",
+		Actor:    rec.UserID,
+		Data:     data,
+	})
+	if err != nil {
+		slog.WarnContext(ctx, "append model audit record error", "tenant", tenantID, "err", err)
+	}
+}
@@ -0,0 +1,57 @@
+package gateway
+
+import (
+	"slices"
+
+	"github.com/shivansh-source/nopass/internal/config"
+	"github.com/shivansh-source/nopass/internal/metrics"
+	"github.com/shivansh-source/nopass/internal/normalize"
+	"github.com/shivansh-source/nopass/internal/types"
+)
+
+var normalized = metrics.NewCounterVec(
+	"nopass_normalized_total",
+	"Texts normalization rewrote or flagged, by input and flag.",
+	"input", "flag",
+)
+
+func normalizeOptions(n config.Normalize) normalize.Options {
+	return normalize.Options{NFKC: n.NFKC, Homoglyphs: n.Homoglyphs, Invisible: n.Invisible, Decode: n.Decode}
+}
+
+// normalizeMessage rewrites the user message in place and returns the
+// flags for what it changed or found, to add to the risk verdict.
+func normalizeMessage(n config.Normalize, req *types.ChatRequest) []string {
+	text, flags := normalize.Text(req.Message, normalizeOptions(n))
+	req.Message = text
+	for _, f := range flags {
+		normalized.Inc("message", f)
+	}
+	return flags
+}
+
+// normalizeData rewrites the external data blocks in place.
+func normalizeData(n config.Normalize, req *types.ChatRequest) {
+	opts := normalizeOptions(n)
+	if !opts.Enabled() {
+		return
+	}
+	for i := range req.ExternalData {
+		d := &req.ExternalData[i]
+		var flags []string
+		d.Content, flags = normalize.Text(d.Content, opts)
+		for _, f := range flags {
+			normalized.Inc("external_data", f)
+		}
+	}
+}
+
+// addFlags appends the flags flags lacks.
+func addFlags(flags []string, more ...string) []string {
+	for _, f := range more {
+		if !slices.Contains(flags, f) {
+			flags = append(flags, f)
+		}
+	}
+	return flags
+}
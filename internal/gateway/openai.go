@@ -0,0 +1,376 @@
+package gateway
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/shivansh-source/nopass/internal/types"
+)
+
+// CompletionsHandler serves POST /v1/chat/completions, the OpenAI Chat
+// Completions API, on top of the same pipeline as /v1/chat, so existing
+// OpenAI SDK clients can use NoPass by changing their base URL.
+//
+// The last message must come from the user; earlier user and assistant
+// messages become history. System, developer and tool messages are
+// treated as client-supplied external data and scanned like any other
+// document rather than trusted as instructions. The tenant and session
+// come from the X-NoPass-Tenant and X-NoPass-Session headers.
+func (h *Handler) CompletionsHandler(w http.ResponseWriter, r *http.Request) {
+	// OpenAI SDKs send the API key as a bearer token.
+	if r.Header.Get("X-API-Key") == "" {
+		if key, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+			r.Header.Set("X-API-Key", key)
+		}
+	}
+	ew := &openAIErrors{ResponseWriter: w}
+	defer ew.close()
+	h.serveChat(ew, r, &openAIFormat{})
+}
+
+// ----- OpenAI wire types ----- //
+
+type completionRequest struct {
+	Model               string          `json:"model"`
+	Messages            []openAIMessage `json:"messages"`
+	Temperature         *float64        `json:"temperature,omitempty"`
+	TopP                *float64        `json:"top_p,omitempty"`
+	MaxTokens           int             `json:"max_tokens,omitempty"`
+	MaxCompletionTokens int             `json:"max_completion_tokens,omitempty"`
+	Stop                json.RawMessage `json:"stop,omitempty"` // string or []string
+	Stream              bool            `json:"stream,omitempty"`
+	N                   int             `json:"n,omitempty"`
+	User                string          `json:"user,omitempty"`
+}
+
+type openAIMessage struct {
+	Role       string          `json:"role"`
+	Content    json.RawMessage `json:"content"` // string or content parts
+	ToolCallID string          `json:"tool_call_id,omitempty"`
+}
+
+type completion struct {
+	ID      string             `json:"id"`
+	Object  string             `json:"object"` // "chat.completion" or "chat.completion.chunk"
+	Created int64              `json:"created"`
+	Model   string             `json:"model"`
+	Choices []completionChoice `json:"choices"`
+	// NoPass carries the full native response (risk level, notices, data
+	// status, receipt); OpenAI clients ignore it.
+	NoPass *types.ChatResponse `json:"nopass,omitempty"`
+}
+
+type completionChoice struct {
+	Index        int         `json:"index"`
+	Message      *messageOut `json:"message,omitempty"`
+	Delta        *messageOut `json:"delta,omitempty"`
+	FinishReason *string     `json:"finish_reason"`
+}
+
+type messageOut struct {
+	Role    string `json:"role,omitempty"`
+	Content string `json:"content"`
+}
+
+// ----- Request mapping ----- //
+
+// openAIFormat is the wire format of CompletionsHandler. It remembers the
+// requested model and the completion ID for the response.
+type openAIFormat struct {
+	id      string
+	model   string
+	created int64
+}
+
+func (f *openAIFormat) decode(r *http.Request, req *types.ChatRequest) error {
+	var cr completionRequest
+	if err := json.NewDecoder(r.Body).Decode(&cr); err != nil {
+		return errors.New("invalid JSON body")
+	}
+	if cr.N > 1 {
+		return errors.New("n > 1 is not supported")
+	}
+	if len(cr.Messages) == 0 {
+		return errors.New("messages must not be empty")
+	}
+	last := cr.Messages[len(cr.Messages)-1]
+	if last.Role != "user" {
+		return errors.New("the last message must have role user")
+	}
+
+	for i, m := range cr.Messages {
+		text, err := messageText(m.Content)
+		if err != nil {
+			return fmt.Errorf("messages[%d]: %w", i, err)
+		}
+		if i == len(cr.Messages)-1 {
+			req.Message = text
+			break
+		}
+		switch m.Role {
+		case "user", "assistant":
+			req.History = append(req.History, types.Turn{Role: m.Role, Content: text})
+		case "system", "developer":
+			req.ExternalData = append(req.ExternalData, types.ExternalData{
+				ID: fmt.Sprintf("message_%d", i), Source: "client:" + m.Role, Type: "instructions", Content: text,
+			})
+		case "tool":
+			id := m.ToolCallID
+			if id == "" {
+				id = fmt.Sprintf("message_%d", i)
+			}
+			req.ExternalData = append(req.ExternalData, types.ExternalData{
+				ID: id, Source: "client:tool", Type: "tool_result", Content: text,
+			})
+		default:
+			return fmt.Errorf("messages[%d]: unsupported role %q", i, m.Role)
+		}
+	}
+
+	stop, err := stopSequences(cr.Stop)
+	if err != nil {
+		return err
+	}
+	gen := &types.GenerationParams{
+		Model:       cr.Model,
+		Temperature: cr.Temperature,
+		TopP:        cr.TopP,
+		MaxTokens:   cr.MaxTokens,
+		Stop:        stop,
+	}
+	if cr.MaxCompletionTokens > 0 {
+		gen.MaxTokens = cr.MaxCompletionTokens
+	}
+	req.Generation = gen
+	req.UserID = cr.User
+	req.SessionID = r.Header.Get("X-NoPass-Session")
+	req.Stream = cr.Stream
+
+	f.id = newCompletionID()
+	f.model = cr.Model
+	if f.model == "" {
+		f.model = "nopass"
+	}
+	f.created = time.Now().Unix()
+	return nil
+}
+
+// messageText accepts a plain string or an array of text content parts.
+func messageText(raw json.RawMessage) (string, error) {
+	if len(raw) == 0 || string(raw) == "null" {
+		return "", nil
+	}
+	var s string
+	if err := json.Unmarshal(raw, &s); err == nil {
+		return s, nil
+	}
+	var parts []struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	}
+	if err := json.Unmarshal(raw, &parts); err != nil {
+		return "", errors.New("content must be a string or an array of content parts")
+	}
+	var b strings.Builder
+	for _, p := range parts {
+		if p.Type != "text" {
+			return "", fmt.Errorf("unsupported content part type %q", p.Type)
+		}
+		b.WriteString(p.Text)
+	}
+	return b.String(), nil
+}
+
+func stopSequences(raw json.RawMessage) ([]string, error) {
+	if len(raw) == 0 || string(raw) == "null" {
+		return nil, nil
+	}
+	var s string
+	if err := json.Unmarshal(raw, &s); err == nil {
+		return []string{s}, nil
+	}
+	var list []string
+	if err := json.Unmarshal(raw, &list); err != nil {
+		return nil, errors.New("stop must be a string or an array of strings")
+	}
+	return list, nil
+}
+
+func newCompletionID() string {
+	var b [12]byte
+	_, _ = rand.Read(b[:])
+	return "chatcmpl-" + hex.EncodeToString(b[:])
+}
+
+// finishReason is "content_filter" when the answer was refused or
+// withheld, as OpenAI reports filtered completions.
+func finishReason(out outcome) *string {
+	reason := "stop"
+	if out.withheld {
+		reason = "content_filter"
+	}
+	return &reason
+}
+
+func (f *openAIFormat) respond(w http.ResponseWriter, resp *types.ChatResponse, out outcome) {
+	writeJSON(w, http.StatusOK, completion{
+		ID:      f.id,
+		Object:  "chat.completion",
+		Created: f.created,
+		Model:   f.model,
+		Choices: []completionChoice{{
+			Message:      &messageOut{Role: "assistant", Content: resp.Answer},
+			FinishReason: finishReason(out),
+		}},
+		NoPass: resp,
+	})
+}
+
+func (f *openAIFormat) stream(w http.ResponseWriter, _ *types.ChatRequest) answerStream {
+	ew := newEventWriter(w)
+	if ew == nil {
+		return nil
+	}
+	return &openAIStream{events: ew, f: f}
+}
+
+// openAIStream sends chat.completion.chunk events terminated by
+// "data: [DONE]". OpenAI has no way to take back streamed text, so if the
+// final answer no longer extends what was released, the stream ends with
+// finish_reason "content_filter" instead.
+type openAIStream struct {
+	events   *eventWriter
+	f        *openAIFormat
+	released strings.Builder
+}
+
+func (s *openAIStream) chunk(delta *messageOut, finish *string, resp *types.ChatResponse) error {
+	return s.events.send("", completion{
+		ID:      s.f.id,
+		Object:  "chat.completion.chunk",
+		Created: s.f.created,
+		Model:   s.f.model,
+		Choices: []completionChoice{{Delta: delta, FinishReason: finish}},
+		NoPass:  resp,
+	})
+}
+
+func (s *openAIStream) delta(text string) error {
+	if text == "" {
+		return nil
+	}
+	d := &messageOut{Content: text}
+	if !s.events.started {
+		d.Role = "assistant"
+	}
+	if err := s.chunk(d, nil, nil); err != nil {
+		return err
+	}
+	s.released.WriteString(text)
+	return nil
+}
+
+func (s *openAIStream) finish(resp *types.ChatResponse, out outcome) error {
+	released := s.released.String()
+	finish := finishReason(out)
+	if strings.HasPrefix(resp.Answer, released) {
+		if err := s.delta(resp.Answer[len(released):]); err != nil {
+			return err
+		}
+	} else {
+		cf := "content_filter"
+		finish = &cf
+	}
+	if err := s.chunk(&messageOut{}, finish, resp); err != nil {
+		return err
+	}
+	return s.events.send("", []byte("[DONE]"))
+}
+
+func (s *openAIStream) fail(msg string) bool {
+	if !s.events.started {
+		return false
+	}
+	s.events.send("", openAIError(http.StatusInternalServerError, msg))
+	return true
+}
+
+// ----- Errors ----- //
+
+type openAIErrorBody struct {
+	Error struct {
+		Message string `json:"message"`
+		Type    string `json:"type"`
+	} `json:"error"`
+}
+
+func openAIError(status int, msg string) openAIErrorBody {
+	var e openAIErrorBody
+	e.Error.Message = msg
+	switch {
+	case status == http.StatusUnauthorized:
+		e.Error.Type = "authentication_error"
+	case status == http.StatusForbidden:
+		e.Error.Type = "permission_error"
+	case status == http.StatusTooManyRequests:
+		e.Error.Type = "rate_limit_error"
+	case status < 500:
+		e.Error.Type = "invalid_request_error"
+	default:
+		e.Error.Type = "server_error"
+	}
+	return e
+}
+
+// openAIErrors rewrites the pipeline's plain-text HTTP errors into
+// OpenAI's JSON error format, which SDKs parse into exceptions.
+type openAIErrors struct {
+	http.ResponseWriter
+	status int // error status held back until close
+	msg    bytes.Buffer
+}
+
+func (e *openAIErrors) WriteHeader(code int) {
+	if code >= 400 {
+		e.status = code
+		return
+	}
+	e.ResponseWriter.WriteHeader(code)
+}
+
+func (e *openAIErrors) Write(p []byte) (int, error) {
+	if e.status != 0 {
+		return e.msg.Write(p)
+	}
+	return e.ResponseWriter.Write(p)
+}
+
+func (e *openAIErrors) Flush() {
+	if f, ok := e.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (e *openAIErrors) close() {
+	if e.status == 0 {
+		return
+	}
+	e.ResponseWriter.Header().Del("X-Content-Type-Options")
+	writeJSON(e.ResponseWriter, e.status, openAIError(e.status, strings.TrimSpace(e.msg.String())))
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("encode response error: %v", err)
+	}
+}
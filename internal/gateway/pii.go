@@ -0,0 +1,58 @@
+package gateway
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/shivansh-source/nopass/internal/metrics"
+	"github.com/shivansh-source/nopass/internal/pii"
+	"github.com/shivansh-source/nopass/internal/review"
+	"github.com/shivansh-source/nopass/internal/types"
+)
+
+var piiEntities = metrics.NewCounterVec(
+	"nopass_pii_entities_total",
+	"Personal data found by the PII detector, by kind; kind \"error\" counts failed calls.",
+	"kind",
+)
+
+// detectPII runs the PII detector, if any, over everything of the request
+// the model will read, in one call. Only the values found are used, so
+// the texts can be joined. A failure is logged and leaves masking to the
+// built-in rules.
+func (h *Handler) detectPII(ctx context.Context, req *types.ChatRequest, memory string, history []types.Turn) []pii.Entity {
+	if h.PII == nil {
+		return nil
+	}
+	texts := []string{req.Message, memory}
+	for _, t := range history {
+		texts = append(texts, t.Content)
+	}
+	for _, d := range req.ExternalData {
+		texts = append(texts, d.Content)
+	}
+	start := time.Now()
+	ents, err := h.PII.Detect(ctx, strings.Join(texts, "\n\n"))
+	stageDuration.ObserveSince(start, "pii")
+	if err != nil {
+		slog.ErrorContext(ctx, "pii detector error; masking with patterns only", "err", err)
+		piiEntities.Inc("error")
+		return nil
+	}
+	for _, e := range ents {
+		piiEntities.Inc(e.Kind)
+	}
+	return ents
+}
+
+// redactSecrets replaces the credentials in an answer, reporting whether
+// there were any.
+func redactSecrets(answer string) (string, bool) {
+	ents := pii.FindSecrets(answer)
+	if len(ents) == 0 {
+		return answer, false
+	}
+	return pii.Replace(answer, ents, func(string, string) string { return review.RedactedSecret }), true
+}
@@ -0,0 +1,64 @@
+package gateway
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+
+	"github.com/shivansh-source/nopass/internal/audit"
+	"github.com/shivansh-source/nopass/internal/types"
+)
+
+// Prompt sources selectable via Handler.PromptSource.
+const (
+	// PromptSourceRaw sends the client's message (after gateway masking).
+	PromptSourceRaw = "raw"
+	// PromptSourceSanitized sends the risk service's sanitized prompt, with
+	// gateway masking applied on top of it.
+	PromptSourceSanitized = "sanitized"
+)
+
+// ValidatePromptSource rejects unknown prompt source settings.
+func ValidatePromptSource(s string) error {
+	switch s {
+	case "", PromptSourceRaw, PromptSourceSanitized:
+		return nil
+	default:
+		return fmt.Errorf("unknown prompt source %q (want %q or %q)", s, PromptSourceRaw, PromptSourceSanitized)
+	}
+}
+
+// modelPrompt picks which version of the user's message goes to the model
+// and records both versions, by hash, in the log and the audit record. If
+// the risk service returned no sanitized prompt, the raw message is used
+// so a misbehaving service can't blank out the request.
+func (h *Handler) modelPrompt(ctx context.Context, req *types.ChatRequest, risk *types.RiskResponse, tx *audit.Transaction) string {
+	chosen, source := req.Message, PromptSourceRaw
+	if h.PromptSource == PromptSourceSanitized {
+		if risk.SanitizedPrompt != "" {
+			chosen, source = risk.SanitizedPrompt, PromptSourceSanitized
+		} else {
+			slog.WarnContext(ctx, "risk service returned empty sanitized prompt; using raw message")
+		}
+	}
+
+	tx.PromptSource = source
+	tx.RawPromptSHA256 = promptHash(req.Message)
+	if risk.SanitizedPrompt != "" {
+		tx.SanitizedPromptSHA256 = promptHash(risk.SanitizedPrompt)
+	}
+	slog.InfoContext(ctx, "prompt versions", "source", source,
+		"raw_sha256", tx.RawPromptSHA256, "sanitized_sha256", tx.SanitizedPromptSHA256,
+		"sanitized_differs", risk.SanitizedPrompt != req.Message)
+
+	return chosen
+}
+
+// promptHash returns the hex SHA-256 of a prompt version, to correlate
+// versions without keeping their contents.
+func promptHash(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
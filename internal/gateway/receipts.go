@@ -0,0 +1,58 @@
+package gateway
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/shivansh-source/nopass/internal/receipts"
+	"github.com/shivansh-source/nopass/internal/types"
+)
+
+type receiptsResponse struct {
+	TenantID string                 `json:"tenant_id"`
+	Since    time.Time              `json:"since"`
+	Usage    receipts.Usage         `json:"usage"`
+	Receipts []types.SandboxReceipt `json:"receipts"`
+}
+
+// ReceiptsHandler serves GET /v1/receipts?since=<RFC3339>: the caller's
+// tenant's sandbox receipts and their aggregate usage (default: last 24h).
+func (h *Handler) ReceiptsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if h.Receipts == nil {
+		http.Error(w, "receipts are not enabled", http.StatusNotFound)
+		return
+	}
+
+	since := time.Now().Add(-24 * time.Hour)
+	if v := r.URL.Query().Get("since"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			http.Error(w, "since must be an RFC 3339 timestamp", http.StatusBadRequest)
+			return
+		}
+		since = t
+	}
+
+	tenantID := h.tenantID(r, &types.ChatRequest{TenantID: r.URL.Query().Get("tenant_id")})
+	list, err := h.Receipts.List(tenantID, since)
+	if err != nil {
+		log.Printf("list receipts error: %v", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	resp := receiptsResponse{TenantID: tenantID, Since: since.UTC(), Receipts: list}
+	for _, rc := range list {
+		resp.Usage.Add(rc)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		log.Printf("encode response error: %v", err)
+	}
+}
@@ -0,0 +1,42 @@
+package gateway
+
+import (
+	"log/slog"
+	"net/http"
+	"runtime/debug"
+
+	"github.com/shivansh-source/nopass/internal/metrics"
+)
+
+var panics = metrics.NewCounterVec(
+	"nopass_panics_total",
+	"Handler panics recovered, by where they were caught (chat or http).",
+	"where",
+)
+
+// Recover answers a request whose handler panics with a 500 instead of
+// dropping the connection, and logs the panic with its stack.
+// http.ErrAbortHandler is passed on: it aborts a response on purpose.
+func Recover(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			p := recover()
+			if p == nil {
+				return
+			}
+			if p == http.ErrAbortHandler {
+				panic(p)
+			}
+			panics.Inc("http")
+			slog.ErrorContext(r.Context(), "handler panic", "panic", p, "path", r.URL.Path, "stack", string(debug.Stack()))
+			http.Error(w, "internal error", http.StatusInternalServerError)
+		}()
+		next.ServeHTTP(w, r)
+	})
+}
+
+// chatPanic logs a panic the chat pipeline recovered from.
+func chatPanic(r *http.Request, p any) {
+	panics.Inc("chat")
+	slog.ErrorContext(r.Context(), "chat pipeline panic", "panic", p, "stack", string(debug.Stack()))
+}
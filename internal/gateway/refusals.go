@@ -0,0 +1,51 @@
+package gateway
+
+import (
+	"net/http"
+
+	"github.com/shivansh-source/nopass/internal/metrics"
+	"github.com/shivansh-source/nopass/internal/refusalcache"
+	"github.com/shivansh-source/nopass/internal/types"
+)
+
+var refusalCache = metrics.NewCounterVec(
+	"nopass_refusal_cache_total",
+	"Refusal cache lookups and stores by result (hit, stored).",
+	"result",
+)
+
+// refusalKey returns the request's refusal cache key and the policy
+// version an entry must have been made under, or "" when the cache is off
+// or the request names neither a user nor a session to key it by.
+func (h *Handler) refusalKey(r *http.Request, req *types.ChatRequest, tenantID string) (key, version string) {
+	if h.Refusals == nil {
+		return "", ""
+	}
+	subject := req.UserID
+	if subject == "" && req.SessionID != "" {
+		subject = "session:" + req.SessionID
+	}
+	if subject == "" {
+		return "", ""
+	}
+	version = h.PolicyVersion
+	if cur := h.Policies.Current(); cur != nil {
+		version += "@" + cur.Version
+	}
+	return refusalcache.Key(tenantID, subject, h.policyProfile(r, req), req.Message, req.ExternalData), version
+}
+
+// cacheRefusal remembers that the request was refused with answer.
+func (h *Handler) cacheRefusal(key, version, answer string, risk types.RiskLevel, path types.Path, flags []string) {
+	if key == "" || h.replay {
+		return
+	}
+	h.Refusals.Put(key, refusalcache.Entry{
+		Answer:        answer,
+		RiskLevel:     risk,
+		Path:          path,
+		Flags:         flags,
+		PolicyVersion: version,
+	})
+	refusalCache.Inc("stored")
+}
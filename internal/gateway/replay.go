@@ -0,0 +1,63 @@
+package gateway
+
+import (
+	"context"
+	"time"
+
+	"github.com/shivansh-source/nopass/internal/memory"
+	"github.com/shivansh-source/nopass/internal/storage"
+	"github.com/shivansh-source/nopass/internal/vault"
+)
+
+// replayHandler returns a copy of h for replaying a mirrored request on
+// the canary build. It answers as h does but persists nothing: no audit
+// or DLP record, risk ledger or history update, memory block, vault
+// entry, feature record, tuning observation, receipt, quarantine entry,
+// artifact or cache entry. Stored state is still read, so the replay sees
+// what the original request saw as closely as it can; only the canary
+// Result comes out of it.
+func (h *Handler) replayHandler() *Handler {
+	r := *h
+	r.replay = true
+	r.AuditLog, r.Audit, r.RecentOutcomes = nil, nil, nil
+	r.Features, r.Tuning, r.MaskSamples = nil, nil, nil
+	r.Receipts, r.Quarantine, r.Artifacts = nil, nil, nil
+	if h.Vault != nil {
+		r.Vault = vault.New(h.Vault.Keys, readOnlyVault{h.Vault.Store}, h.Vault.TTL)
+	}
+	if h.History != nil {
+		hist := *h.History
+		hist.Sessions = readOnlySessions{hist.Sessions}
+		r.History = &hist
+	}
+	if h.RiskLedger != nil {
+		ledger := *h.RiskLedger
+		ledger.Sessions = readOnlySessions{ledger.Sessions}
+		r.RiskLedger = &ledger
+	}
+	if h.Memory != nil && h.Memory.Store != nil {
+		mem := *h.Memory
+		mem.Store = readOnlyMemory{mem.Store}
+		r.Memory = &mem
+	}
+	return &r
+}
+
+// readOnlySessions drops writes to a session store.
+type readOnlySessions struct{ storage.SessionStore }
+
+func (readOnlySessions) PutSession(context.Context, *storage.Session) error  { return nil }
+func (readOnlySessions) DeleteSession(context.Context, string, string) error { return nil }
+
+// readOnlyVault drops writes to a vault store.
+type readOnlyVault struct{ storage.VaultStore }
+
+func (readOnlyVault) PutSealed(context.Context, storage.VaultKey, []byte, time.Time) error {
+	return nil
+}
+func (readOnlyVault) DeleteVaultSession(context.Context, string, string) error { return nil }
+
+// readOnlyMemory drops writes to a memory block store.
+type readOnlyMemory struct{ memory.Store }
+
+func (readOnlyMemory) Put(context.Context, string, memory.Block) {}
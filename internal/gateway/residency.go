@@ -0,0 +1,68 @@
+package gateway
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+)
+
+// maxTenantPeekBytes bounds how much of a body RequestTenant buffers. A
+// larger body is passed on whole, but its routing fields aren't read:
+// clients sending one name their tenant in the X-NoPass-Tenant header.
+const maxTenantPeekBytes = 8 << 20
+
+// RequestTenant returns the tenant a request belongs to without consuming
+// its body, so routing can happen before the real handler decodes it. The
+// X-NoPass-Tenant header wins over the body's tenant_id, as in the
+// handlers themselves.
+func RequestTenant(r *http.Request) string {
+	if v := r.Header.Get("X-NoPass-Tenant"); v != "" {
+		return v
+	}
+	if r.Body == nil || r.Method != http.MethodPost {
+		return ""
+	}
+	if !isJSON(r) {
+		// Raw document uploads are streamed, never buffered; they name
+		// their tenant in the query.
+		return r.URL.Query().Get("tenant_id")
+	}
+	return peekBody(r).TenantID
+}
+
+// RequestCaller is RequestTenant plus the user and session the body
+// names, for rate limiting before the handler runs.
+func RequestCaller(r *http.Request) (tenantID, userID, sessionID string) {
+	if r.Body == nil || r.Method != http.MethodPost || !isJSON(r) {
+		return RequestTenant(r), "", ""
+	}
+	peek := peekBody(r)
+	if v := r.Header.Get("X-NoPass-Tenant"); v != "" {
+		peek.TenantID = v
+	}
+	return peek.TenantID, peek.UserID, peek.SessionID
+}
+
+type bodyPeek struct {
+	TenantID  string `json:"tenant_id"`
+	UserID    string `json:"user_id"`
+	SessionID string `json:"session_id"`
+}
+
+// peekBody decodes the routing fields of a JSON body of up to
+// maxTenantPeekBytes and puts the body back for the handler: what was
+// read followed by the rest, if there is more.
+func peekBody(r *http.Request) bodyPeek {
+	var peek bodyPeek
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxTenantPeekBytes+1))
+	r.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(body), r.Body), r.Body}
+	if err != nil || len(body) > maxTenantPeekBytes {
+		return peek
+	}
+	_ = json.Unmarshal(body, &peek)
+	return peek
+}
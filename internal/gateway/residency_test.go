@@ -0,0 +1,51 @@
+package gateway
+
+import (
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRequestCallerKeepsBody(t *testing.T) {
+	small := `{"tenant_id":"acme","user_id":"u1","session_id":"s1","message":"hi"}`
+	large := `{"tenant_id":"acme","user_id":"u1","message":"` + strings.Repeat("a", maxTenantPeekBytes) + `"}`
+	tests := []struct {
+		name        string
+		body        string
+		header      string
+		wantTenant  string
+		wantUser    string
+		wantSession string
+	}{
+		{name: "small", body: small, wantTenant: "acme", wantUser: "u1", wantSession: "s1"},
+		{name: "header wins", body: small, header: "globex", wantTenant: "globex", wantUser: "u1", wantSession: "s1"},
+		{name: "at the limit", body: small + strings.Repeat(" ", maxTenantPeekBytes-len(small)), wantTenant: "acme", wantUser: "u1", wantSession: "s1"},
+		{name: "over the limit", body: large},
+		{name: "over the limit with header", body: large, header: "acme", wantTenant: "acme"},
+		{name: "invalid", body: `{"tenant_id":`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("POST", "/v1/chat", strings.NewReader(tt.body))
+			r.Header.Set("Content-Type", "application/json")
+			if tt.header != "" {
+				r.Header.Set("X-NoPass-Tenant", tt.header)
+			}
+			tenant, user, session := RequestCaller(r)
+			if tenant != tt.wantTenant || user != tt.wantUser || session != tt.wantSession {
+				t.Errorf("RequestCaller = %q, %q, %q; want %q, %q, %q", tenant, user, session, tt.wantTenant, tt.wantUser, tt.wantSession)
+			}
+			if got := RequestTenant(r); got != tt.wantTenant {
+				t.Errorf("RequestTenant after RequestCaller = %q, want %q", got, tt.wantTenant)
+			}
+			body, err := io.ReadAll(r.Body)
+			if err != nil {
+				t.Fatalf("reading body: %v", err)
+			}
+			if string(body) != tt.body {
+				t.Errorf("handler got a %d byte body, want the %d bytes sent", len(body), len(tt.body))
+			}
+		})
+	}
+}
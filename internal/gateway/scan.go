@@ -0,0 +1,305 @@
+package gateway
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/shivansh-source/nopass/internal/config"
+	"github.com/shivansh-source/nopass/internal/datastore"
+	"github.com/shivansh-source/nopass/internal/orchestrator"
+	"github.com/shivansh-source/nopass/internal/policy"
+	"github.com/shivansh-source/nopass/internal/risk"
+	"github.com/shivansh-source/nopass/internal/scanledger"
+	"github.com/shivansh-source/nopass/internal/storage"
+	"github.com/shivansh-source/nopass/internal/types"
+)
+
+// scanExternalData scans each external data chunk (indirect prompt
+// injection defense) and marks HIGH-risk ones as dangerous. It returns the
+// per-block status for the client, or ctx.Err() if the request died while
+// scanning. Blocks from sources pol doesn't allow are dropped unscanned.
+// Blocks whose scan failed are quarantined, or dropped entirely when
+// ExcludeOnScanFailure is set, unless onFail, the stage's failure policy,
+// says to use them unscanned or score them locally; failure reports what
+// was done. Blocks are scored in batches when the risk scorer supports
+// it; the rest, up to ScanConcurrency at once, one by one, each bounded
+// by ScanTimeout.
+func (h *Handler) scanExternalData(ctx context.Context, req *types.ChatRequest, pol *policy.Set, onFail string) (_ []types.DataBlockStatus, failure *types.StageFailure, _ error) {
+	statuses := make([]types.DataBlockStatus, len(req.ExternalData))
+	var pending []int
+	for i := range req.ExternalData {
+		d := &req.ExternalData[i]
+		statuses[i] = types.DataBlockStatus{ID: d.ID, Source: d.Source, Status: types.DataScanned}
+		if !pol.AllowsSource(d.Source) {
+			statuses[i].Status = types.DataExcluded
+			statuses[i].Reason = "source not allowed by policy"
+			continue
+		}
+		if d.Prescanned {
+			if d.IsDangerous {
+				statuses[i].Status = types.DataFlagged
+				statuses[i].Reason = "flagged when registered"
+			}
+			continue
+		}
+		pending = append(pending, i)
+	}
+	pending = h.scanBatches(ctx, req, pending, statuses)
+
+	// Workers only touch the block, status and failed entry at the index
+	// they're given.
+	failed := make([]bool, len(req.ExternalData))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < min(max(h.ScanConcurrency, 1), len(pending)); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				failed[i] = h.scanBlock(ctx, req, &req.ExternalData[i], &statuses[i], onFail)
+			}
+		}()
+	}
+feed:
+	for _, i := range pending {
+		select {
+		case jobs <- i:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(jobs)
+	wg.Wait()
+	if ctx.Err() != nil {
+		// Client gone or deadline hit: stop scanning, the request is dead.
+		return nil, nil, ctx.Err()
+	}
+	if n := countTrue(failed); n > 0 {
+		if onFail == "" {
+			onFail = config.FailClosed
+		}
+		stageFailures.Inc("external_scan", onFail)
+		failure = &types.StageFailure{Stage: "external_scan", Policy: onFail, Blocks: n}
+	}
+
+	for i, st := range statuses {
+		if st.Status == types.DataFlagged && req.ExternalData[i].Prescanned {
+			continue // quarantined when it was registered
+		}
+		if st.Status == types.DataFlagged || st.Status == types.DataScanFailed {
+			h.recordQuarantine(ctx, req.ExternalData[i], st)
+		}
+	}
+
+	kept := req.ExternalData[:0]
+	for i, d := range req.ExternalData {
+		if statuses[i].Status == types.DataScanFailed && h.ExcludeOnScanFailure {
+			statuses[i].Status = types.DataExcluded
+		}
+		if statuses[i].Status != types.DataExcluded {
+			kept = append(kept, d)
+		}
+	}
+	req.ExternalData = kept
+	return statuses, failure, nil
+}
+
+// scanBlock scans one block within ScanTimeout and records the verdict,
+// applying onFail if the scan failed. It reports whether it did.
+func (h *Handler) scanBlock(ctx context.Context, req *types.ChatRequest, d *types.ExternalData, st *types.DataBlockStatus, onFail string) bool {
+	sctx := ctx
+	if h.ScanTimeout > 0 {
+		var cancel context.CancelFunc
+		sctx, cancel = context.WithTimeout(ctx, h.ScanTimeout)
+		defer cancel()
+	}
+	v, err := h.scanContent(sctx, d.Content, req.UserID, req.SessionID, false)
+	if err == nil || ctx.Err() != nil {
+		markBlock(ctx, d, st, v, err)
+		return false
+	}
+	switch onFail {
+	case config.FailOpen:
+		slog.WarnContext(ctx, "external data scan error; using it unscanned", "data", d.ID, "err", err)
+		st.Status = types.DataUnscanned
+		st.Reason = "risk service unavailable; used unscanned by policy"
+	case config.DegradeToLocal:
+		slog.WarnContext(ctx, "external data scan error; scoring it locally", "data", d.ID, "err", err)
+		resp, _ := risk.Engine{}.ScoreDocument(ctx, d.Content, req.UserID, req.SessionID)
+		// Not kept in the ledger: the service's verdict may differ.
+		markBlock(ctx, d, st, &scanledger.Verdict{RiskLevel: resp.RiskLevel, IsDangerous: resp.RiskLevel == types.RiskHigh}, nil)
+		if st.Status == types.DataScanned {
+			st.Reason = "scored by the local engine; risk service unavailable"
+		}
+	default:
+		markBlock(ctx, d, st, v, err)
+	}
+	return true
+}
+
+func countTrue(bs []bool) int {
+	n := 0
+	for _, b := range bs {
+		if b {
+			n++
+		}
+	}
+	return n
+}
+
+// markBlock marks d and st with a block's scan result.
+func markBlock(ctx context.Context, d *types.ExternalData, st *types.DataBlockStatus, v *scanledger.Verdict, err error) {
+	if err != nil {
+		slog.ErrorContext(ctx, "external data scan error", "data", d.ID, "err", err)
+		// We can't vouch for content we couldn't scan: quarantine it.
+		d.IsDangerous = true
+		st.Status = types.DataScanFailed
+		st.Reason = "risk service unavailable; not a verdict on the content"
+		return
+	}
+	if v.IsDangerous {
+		slog.WarnContext(ctx, "external data flagged as HIGH risk", "data", d.ID)
+		d.IsDangerous = true
+		st.Status = types.DataFlagged
+		st.Reason = "content scored " + string(v.RiskLevel)
+	}
+}
+
+// recordQuarantine lists a held-back block in the quarantine store for
+// operators. Only the content hash is kept.
+func (h *Handler) recordQuarantine(ctx context.Context, d types.ExternalData, st types.DataBlockStatus) {
+	if h.Quarantine == nil {
+		return
+	}
+	err := h.Quarantine.PutQuarantine(ctx, storage.QuarantineEntry{
+		ID:          datastore.NewID(),
+		TenantID:    orchestrator.TenantFrom(ctx),
+		ContentHash: datastore.HashContent(d.Content),
+		Source:      d.Source,
+		Reason:      string(st.Status) + ": " + st.Reason,
+		CreatedAt:   time.Now().UTC(),
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "record quarantine error", "data", d.ID, "err", err)
+	}
+}
+
+// scanContent scores content with the risk service. When a ScanLedger is
+// configured and already holds a verdict for the same content hash under the
+// current policy version, that verdict is reused unless force is set.
+func (h *Handler) scanContent(ctx context.Context, content, userID, sessionID string, force bool) (*scanledger.Verdict, error) {
+	hash := datastore.HashContent(content)
+	if !force {
+		if v, ok := h.ledgerVerdict(hash); ok {
+			return v, nil
+		}
+	}
+
+	risk, err := h.Risk.ScoreDocument(ctx, content, userID, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	return h.recordVerdict(hash, risk), nil
+}
+
+// ledgerVerdict returns the ScanLedger's verdict for hash, if it is
+// fresh under the current policy version.
+func (h *Handler) ledgerVerdict(hash string) (*scanledger.Verdict, bool) {
+	if h.ScanLedger == nil {
+		return nil, false
+	}
+	v, ok := h.ScanLedger.Get(hash)
+	return v, ok && v.Fresh(hash, h.PolicyVersion)
+}
+
+// recordVerdict turns a risk verdict into a ledger verdict and keeps it.
+func (h *Handler) recordVerdict(hash string, risk *types.RiskResponse) *scanledger.Verdict {
+	v := scanledger.Verdict{
+		ContentHash:   hash,
+		PolicyVersion: h.PolicyVersion,
+		RiskLevel:     risk.RiskLevel,
+		Flags:         risk.Flags,
+		IsDangerous:   risk.RiskLevel == types.RiskHigh,
+		ScannedAt:     time.Now().UTC(),
+	}
+	if h.ScanLedger != nil && !h.replay {
+		h.ScanLedger.Put(v)
+	}
+	return &v
+}
+
+// Bounds on one batch scoring call.
+const (
+	maxBatchDocuments = 64
+	maxBatchBytes     = 1 << 20
+)
+
+// scanBatches scores the pending blocks in batches, when the risk scorer
+// can, and returns the blocks left to score one at a time: those too
+// large for a batch (they are streamed) and, if a batch fails, all of
+// its blocks.
+func (h *Handler) scanBatches(ctx context.Context, req *types.ChatRequest, pending []int, statuses []types.DataBlockStatus) []int {
+	scorer, ok := h.Risk.(risk.BatchScorer)
+	if !ok || len(pending) < 2 {
+		return pending
+	}
+	var rest, batch []int
+	size := 0
+	flush := func() {
+		if len(batch) > 0 {
+			rest = append(rest, h.scanBatch(ctx, scorer, req, batch, statuses)...)
+		}
+		batch, size = nil, 0
+	}
+	for _, i := range pending {
+		d := &req.ExternalData[i]
+		hash := datastore.HashContent(d.Content)
+		if v, ok := h.ledgerVerdict(hash); ok {
+			markBlock(ctx, d, &statuses[i], v, nil)
+			continue
+		}
+		if len(d.Content) > StreamThresholdBytes {
+			rest = append(rest, i)
+			continue
+		}
+		if len(batch) == maxBatchDocuments || size+len(d.Content) > maxBatchBytes {
+			flush()
+		}
+		batch = append(batch, i)
+		size += len(d.Content)
+	}
+	flush()
+	return rest
+}
+
+// scanBatch scores one batch within ScanTimeout. It returns the blocks
+// back if the batch couldn't be scored.
+func (h *Handler) scanBatch(ctx context.Context, scorer risk.BatchScorer, req *types.ChatRequest, batch []int, statuses []types.DataBlockStatus) []int {
+	if ctx.Err() != nil {
+		return batch
+	}
+	if h.ScanTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, h.ScanTimeout)
+		defer cancel()
+	}
+	contents := make([]string, len(batch))
+	for n, i := range batch {
+		contents[n] = req.ExternalData[i].Content
+	}
+	results, err := scorer.ScoreBatch(ctx, contents, req.UserID, req.SessionID)
+	if err != nil {
+		if !errors.Is(err, risk.ErrBatchUnsupported) {
+			slog.WarnContext(ctx, "batch external data scan error; scanning one by one", "blocks", len(batch), "err", err)
+		}
+		return batch
+	}
+	for n, i := range batch {
+		v := h.recordVerdict(datastore.HashContent(contents[n]), results[n])
+		markBlock(ctx, &req.ExternalData[i], &statuses[i], v, nil)
+	}
+	return nil
+}
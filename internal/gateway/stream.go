@@ -0,0 +1,251 @@
+package gateway
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/shivansh-source/nopass/internal/dlp"
+	"github.com/shivansh-source/nopass/internal/review"
+	"github.com/shivansh-source/nopass/internal/types"
+)
+
+// defaultStreamReviewBytes is how much streamed answer text is held back
+// before the next incremental output-safety review.
+const defaultStreamReviewBytes = 256
+
+// errWithheld stops a streaming run once a partial review blocked the
+// answer; the draft so far then goes through the regular final review.
+var errWithheld = errors.New("streamed answer withheld by output review")
+
+// wantsStream reports whether the client asked for Server-Sent Events.
+func wantsStream(r *http.Request, req *types.ChatRequest) bool {
+	return req.Stream || strings.Contains(r.Header.Get("Accept"), "text/event-stream")
+}
+
+// streamsLive reports whether answer text may be released while the model
+// is still running. Slow-path answers get a full self-check first, and
+// answers that may be held for approval are released only once approved;
+// both are sent in one piece when complete.
+func (h *Handler) streamsLive(tenantID string, path types.Path, risk *types.RiskResponse) bool {
+	if path != types.PathFast {
+		return false
+	}
+	// Checks on the complete answer would have to retract text already
+	// shown.
+	if h.DLP.Gates(dlp.Output, path) || h.Profanity.Enabled(tenantID) || h.Topics.Enabled(tenantID) {
+		return false
+	}
+	return h.Approvals == nil || !h.Approvals.Gated(risk.Flags)
+}
+
+// wireFormat adapts the chat pipeline to one client API: the native
+// /v1/chat format or the OpenAI-compatible one.
+type wireFormat interface {
+	// decode reads the request body into req.
+	decode(r *http.Request, req *types.ChatRequest) error
+	// stream returns the answer stream for a streaming request, or nil if w
+	// cannot stream.
+	stream(w http.ResponseWriter, req *types.ChatRequest) answerStream
+	// respond writes a complete, non-streamed response.
+	respond(w http.ResponseWriter, resp *types.ChatResponse, out outcome)
+}
+
+// outcome is how the final answer came about.
+type outcome struct {
+	withheld bool     // refused by review or denied by an approval gate
+	flags    []string // review flags, plus the gated flags of a denial
+}
+
+// answerStream sends the answer while the pipeline is still running.
+type answerStream interface {
+	// delta releases the next piece of reviewed answer text.
+	delta(text string) error
+	// finish sends whatever the client is still missing of resp.
+	finish(resp *types.ChatResponse, out outcome) error
+	// fail reports a pipeline error in-stream. It returns false if nothing
+	// was streamed yet, so a plain HTTP error can still be sent instead.
+	fail(msg string) bool
+}
+
+// eventWriter writes Server-Sent Events. Headers go out with the first
+// event, so failures before that are still reported as plain HTTP errors.
+type eventWriter struct {
+	w       http.ResponseWriter
+	flusher http.Flusher
+	started bool
+}
+
+// newEventWriter returns nil if w cannot flush events as they are written.
+func newEventWriter(w http.ResponseWriter) *eventWriter {
+	f, ok := w.(http.Flusher)
+	if !ok {
+		return nil
+	}
+	return &eventWriter{w: w, flusher: f}
+}
+
+// send writes one event; an empty name sends a data-only event.
+func (e *eventWriter) send(name string, v any) error {
+	data, ok := v.([]byte)
+	if !ok {
+		var err error
+		if data, err = json.Marshal(v); err != nil {
+			return fmt.Errorf("encode %s event: %w", name, err)
+		}
+	}
+	if !e.started {
+		e.w.Header().Set("Content-Type", "text/event-stream")
+		e.w.Header().Set("Cache-Control", "no-cache")
+		e.w.Header().Set("X-Accel-Buffering", "no") // disable proxy buffering (nginx)
+		e.w.WriteHeader(http.StatusOK)
+		e.started = true
+	}
+	if name != "" {
+		if _, err := fmt.Fprintf(e.w, "event: %s\n", name); err != nil {
+			return err
+		}
+	}
+	if _, err := fmt.Fprintf(e.w, "data: %s\n\n", data); err != nil {
+		return err
+	}
+	e.flusher.Flush()
+	return nil
+}
+
+// nativeFormat is the /v1/chat API.
+type nativeFormat struct{}
+
+func (nativeFormat) decode(r *http.Request, req *types.ChatRequest) error {
+	if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+		return errors.New("invalid JSON body")
+	}
+	return nil
+}
+
+func (nativeFormat) stream(w http.ResponseWriter, _ *types.ChatRequest) answerStream {
+	ew := newEventWriter(w)
+	if ew == nil {
+		return nil
+	}
+	return &sseWriter{events: ew}
+}
+
+func (nativeFormat) respond(w http.ResponseWriter, resp *types.ChatResponse, _ outcome) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		log.Printf("encode response error: %v", err)
+	}
+}
+
+// sseWriter is the native chat stream (see types.StreamDelta).
+type sseWriter struct {
+	events   *eventWriter
+	released strings.Builder // deltas sent since the last retract
+}
+
+func (s *sseWriter) delta(text string) error {
+	if text == "" {
+		return nil
+	}
+	if err := s.events.send("delta", types.StreamDelta{Text: text}); err != nil {
+		return err
+	}
+	s.released.WriteString(text)
+	return nil
+}
+
+// finish retracts the released text if the final answer no longer starts
+// with it, sends the rest of the answer and the done event.
+func (s *sseWriter) finish(resp *types.ChatResponse, out outcome) error {
+	released := s.released.String()
+	if !strings.HasPrefix(resp.Answer, released) {
+		if err := s.events.send("retract", types.StreamRetract{ReasonFlags: out.flags}); err != nil {
+			return err
+		}
+		s.released.Reset()
+		released = ""
+	}
+	if err := s.delta(resp.Answer[len(released):]); err != nil {
+		return err
+	}
+	return s.events.send("done", resp)
+}
+
+func (s *sseWriter) fail(msg string) bool {
+	if !s.events.started {
+		return false
+	}
+	s.events.send("error", types.StreamError{Error: msg})
+	return true
+}
+
+// pipelineError reports a failure in-stream once a stream has started, and
+// as a plain HTTP error otherwise.
+func pipelineError(w http.ResponseWriter, stream answerStream, msg string, status int) {
+	if stream != nil && stream.fail(msg) {
+		return
+	}
+	http.Error(w, msg, status)
+}
+
+// liveReview releases a streamed draft in reviewed pieces. Text is held
+// back until at least step bytes are pending; the draft up to the last
+// word boundary is then reviewed as a partial answer and, if the reviewer
+// passed it unchanged, sent as a delta. A redaction holds everything after
+// it for the final review; a block stops the run with errWithheld.
+type liveReview struct {
+	ctx      context.Context
+	reviewer review.OutputReviewer
+	req      types.OutputSafetyRequest // everything but the draft
+	stream   answerStream
+	step     int
+
+	draft    strings.Builder
+	released int  // bytes of draft sent as deltas
+	held     bool // a partial review modified the draft
+}
+
+func (l *liveReview) write(chunk string) error {
+	l.draft.WriteString(chunk)
+	if l.held {
+		return nil
+	}
+	draft := l.draft.String()
+	pending := draft[l.released:]
+	if len(pending) < l.step {
+		return nil
+	}
+	// Cut at whitespace so a token the reviewer would redact (an email, a
+	// card number) is never split across two reviews.
+	cut := strings.LastIndexAny(pending, " \t\n") + 1
+	if cut == 0 {
+		if len(pending) < 4*l.step {
+			return nil
+		}
+		cut = len(pending)
+	}
+
+	req := l.req
+	req.DraftAnswer = draft[:l.released+cut]
+	req.Partial = true
+	resp, err := l.reviewer.Review(l.ctx, req)
+	switch {
+	case err != nil:
+		return fmt.Errorf("review streamed answer: %w", err)
+	case resp.Blocked:
+		return errWithheld
+	case resp.WasModified:
+		l.held = true
+		return nil
+	}
+	if err := l.stream.delta(pending[:cut]); err != nil {
+		return err
+	}
+	l.released += cut
+	return nil
+}
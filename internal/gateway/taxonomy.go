@@ -0,0 +1,38 @@
+package gateway
+
+import (
+	"github.com/shivansh-source/nopass/internal/features"
+	"github.com/shivansh-source/nopass/internal/metrics"
+	"github.com/shivansh-source/nopass/internal/taxonomy"
+)
+
+var attackTechniques = metrics.NewCounterVec(
+	"nopass_attack_techniques_total",
+	"Chat requests showing an attack technique, by technique and whether the answer was withheld.",
+	"technique", "withheld",
+)
+
+// requestTechniques maps what the request's stages flagged and detected
+// onto the attack technique taxonomy and counts them.
+func requestTechniques(feat *features.Record) []string {
+	var risk, output, events []string
+	if feat.Risk != nil {
+		risk = feat.Risk.Flags
+	}
+	withheld := "false"
+	if feat.Output != nil {
+		output = feat.Output.Flags
+		if feat.Output.Withheld {
+			withheld = "true"
+		}
+	}
+	if feat.Input != nil && feat.Input.DangerousBlocks > 0 {
+		events = append(events, taxonomy.EventDangerousData)
+	}
+	var out []string
+	for _, t := range taxonomy.Classify(risk, output, events) {
+		attackTechniques.Inc(string(t), withheld)
+		out = append(out, string(t))
+	}
+	return out
+}
@@ -0,0 +1,81 @@
+package gateway
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+
+	"github.com/shivansh-source/nopass/internal/policy"
+	"github.com/shivansh-source/nopass/internal/sandbox"
+	"github.com/shivansh-source/nopass/internal/types"
+	"github.com/shivansh-source/nopass/internal/vault"
+)
+
+// newTokens starts the request's token map after the tokens already in
+// the conversation.
+func newTokens(memory string, history []types.Turn) *sandbox.Tokens {
+	seen := []string{memory}
+	for _, t := range history {
+		seen = append(seen, t.Content)
+	}
+	return sandbox.NewTokens(seen...)
+}
+
+// restoresTokens reports whether answers under pol have tokens restored
+// after review. Such answers change at the end, so they aren't streamed
+// live.
+func restoresTokens(pol *policy.Set) bool {
+	return pol != nil && len(pol.RestoreTokens) > 0
+}
+
+// storeTokens keeps the values behind this request's masking tokens in
+// the vault under the user's session, so a later turn's answer can still
+// restore a token it picked up from the history. Failures are logged: the
+// answer then keeps those tokens masked.
+func (h *Handler) storeTokens(ctx context.Context, tenantID, userID, sessionID string, tokens *sandbox.Tokens) {
+	if h.Vault == nil || sessionID == "" {
+		return
+	}
+	tokens.Each(func(token, value string) {
+		if err := h.Vault.Put(ctx, tenantID, userID, sessionID, token, value); err != nil {
+			slog.ErrorContext(ctx, "token vault put error", "token", token, "err", err)
+		}
+	})
+}
+
+// restoreTokens puts back the original values of the tokens in answer
+// whose kind the policy restores, from this request's tokens or else the
+// vault entries this user stored for the session. Other tokens, and any
+// the vault doesn't have, stay masked.
+func (h *Handler) restoreTokens(ctx context.Context, tenantID, userID, sessionID string, pol *policy.Set, tokens *sandbox.Tokens, answer string) string {
+	if !restoresTokens(pol) {
+		return answer
+	}
+	return sandbox.TokenPattern.ReplaceAllStringFunc(answer, func(token string) string {
+		m := sandbox.TokenPattern.FindStringSubmatch(token)
+		if !pol.Restores(m[1]) {
+			tokensRestored.Inc("kept")
+			return token
+		}
+		if v, ok := tokens.Value(token); ok {
+			tokensRestored.Inc("restored")
+			return v
+		}
+		if h.Vault == nil || sessionID == "" {
+			tokensRestored.Inc("unknown")
+			return token
+		}
+		v, err := h.Vault.Get(ctx, tenantID, userID, sessionID, token)
+		switch {
+		case errors.Is(err, vault.ErrNotFound):
+			tokensRestored.Inc("unknown")
+			return token
+		case err != nil:
+			slog.ErrorContext(ctx, "token vault get error", "token", token, "err", err)
+			tokensRestored.Inc("error")
+			return token
+		}
+		tokensRestored.Inc("restored")
+		return v
+	})
+}
@@ -0,0 +1,139 @@
+package gateway
+
+import (
+	"encoding/json"
+	"io"
+	"strings"
+
+	"github.com/shivansh-source/nopass/internal/metrics"
+	"github.com/shivansh-source/nopass/internal/policy"
+	"github.com/shivansh-source/nopass/internal/review"
+	"github.com/shivansh-source/nopass/internal/sandbox"
+	"github.com/shivansh-source/nopass/internal/types"
+)
+
+// flagToolAnswer marks an answer that skipped output safety because it
+// was made only of trusted tool output.
+const flagToolAnswer = "tool_answer_unreviewed"
+
+var reviewBypasses = metrics.NewCounterVec(
+	"nopass_review_bypasses_total",
+	"Answers made only of trusted tool output that skipped output safety, by form (json or value).",
+	"form",
+)
+
+// toolAnswer reports whether answer may skip output safety (policy
+// tool_answers): the request is low risk and on the fast path, and the
+// answer is a value, or JSON made only of keys and values, taken from
+// the scanned, well-formed JSON tool results pol trusts. Anything the
+// model wrote itself disqualifies it.
+func toolAnswer(pol *policy.Set, risk *types.RiskResponse, path types.Path, in sandbox.SandboxInput, statuses []types.DataBlockStatus, answer string) bool {
+	if risk.RiskLevel != types.RiskLow || path != types.PathFast {
+		return false
+	}
+	if in.Canary != "" && review.ContainsCanary(answer, in.Canary) {
+		return false
+	}
+	scanned := make(map[string]bool, len(statuses))
+	for _, st := range statuses {
+		if st.Status == types.DataScanned {
+			scanned[st.ID] = true
+		}
+	}
+	keys, values := map[string]bool{}, map[string]bool{}
+	for _, d := range in.External {
+		if d.Type != "tool_result" || d.IsDangerous || !scanned[d.ID] || !pol.TrustsToolSource(d.Source) {
+			continue
+		}
+		// The model saw the masked result, so its tokens are what the
+		// answer carries.
+		v, ok := decodeStrict(in.Mask(d.Content))
+		if ok {
+			collectLeaves(v, keys, values)
+		}
+	}
+	if len(values) == 0 {
+		return false
+	}
+	answer = strings.TrimSpace(answer)
+	if v, ok := decodeStrict(answer); ok && onlyLeaves(v, keys, values) {
+		reviewBypasses.Inc("json")
+		return true
+	}
+	if values[answer] {
+		reviewBypasses.Inc("value")
+		return true
+	}
+	return false
+}
+
+// decodeStrict decodes s as exactly one JSON value.
+func decodeStrict(s string) (any, bool) {
+	dec := json.NewDecoder(strings.NewReader(s))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return nil, false
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return nil, false
+	}
+	return v, true
+}
+
+func collectLeaves(v any, keys, values map[string]bool) {
+	switch v := v.(type) {
+	case map[string]any:
+		for k, e := range v {
+			keys[k] = true
+			collectLeaves(e, keys, values)
+		}
+	case []any:
+		for _, e := range v {
+			collectLeaves(e, keys, values)
+		}
+	default:
+		if s, ok := leaf(v); ok {
+			values[s] = true
+		}
+	}
+}
+
+func onlyLeaves(v any, keys, values map[string]bool) bool {
+	switch v := v.(type) {
+	case map[string]any:
+		for k, e := range v {
+			if !keys[k] || !onlyLeaves(e, keys, values) {
+				return false
+			}
+		}
+		return true
+	case []any:
+		for _, e := range v {
+			if !onlyLeaves(e, keys, values) {
+				return false
+			}
+		}
+		return true
+	case nil:
+		return true
+	default:
+		s, ok := leaf(v)
+		return ok && values[s]
+	}
+}
+
+func leaf(v any) (string, bool) {
+	switch v := v.(type) {
+	case string:
+		return v, true
+	case json.Number:
+		return v.String(), true
+	case bool:
+		if v {
+			return "true", true
+		}
+		return "false", true
+	}
+	return "", false
+}
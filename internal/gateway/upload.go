@@ -0,0 +1,228 @@
+package gateway
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"strconv"
+
+	"github.com/shivansh-source/nopass/internal/ingest"
+	"github.com/shivansh-source/nopass/internal/types"
+)
+
+// Large documents don't have to fit in one JSON body:
+//
+//   - POST /v1/data with the document itself as the body (Content-Type
+//     text/*, application/xml or application/pdf) and
+//     ?source=&type=&tenant_id= streams it through text extraction;
+//     chunked transfer encoding is fine.
+//   - POST /v1/uploads starts a resumable upload; chunks are sent with
+//     PATCH /v1/uploads/{id} and an Upload-Offset header, HEAD returns the
+//     offset to resume from, and POST /v1/uploads/{id}/complete extracts,
+//     scans and registers the document.
+//
+// Either way the file itself is never held in memory.
+
+// defaultMaxUploadBytes bounds a raw or resumable upload when no Uploads
+// spool sets a limit.
+const defaultMaxUploadBytes = 256 << 20
+
+func isJSON(r *http.Request) bool {
+	mt, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	return err != nil || mt == "application/json"
+}
+
+func (h *Handler) maxUploadBytes() int64 {
+	if h.Uploads != nil && h.Uploads.MaxBytes > 0 {
+		return h.Uploads.MaxBytes
+	}
+	return defaultMaxUploadBytes
+}
+
+// registerRaw handles POST /v1/data with a raw document body.
+func (h *Handler) registerRaw(w http.ResponseWriter, r *http.Request) {
+	ct := r.Header.Get("Content-Type")
+	if !ingest.Supported(ct) {
+		http.Error(w, "unsupported content type "+strconv.Quote(ct), http.StatusUnsupportedMediaType)
+		return
+	}
+	q := r.URL.Query()
+	tenantID := r.Header.Get("X-NoPass-Tenant")
+	if tenantID == "" {
+		tenantID = q.Get("tenant_id")
+	}
+	body := http.MaxBytesReader(w, r.Body, h.maxUploadBytes())
+	text, ok := h.extract(w, body, ct)
+	if !ok {
+		return
+	}
+	h.register(w, r.Context(), types.DataRegistrationRequest{
+		TenantID: tenantID,
+		Source:   q.Get("source"),
+		Type:     q.Get("type"),
+		Content:  text,
+	})
+}
+
+// extract pulls the text out of a document, answering with an error
+// status when it can't.
+func (h *Handler) extract(w http.ResponseWriter, r io.Reader, contentType string) (string, bool) {
+	text, err := ingest.Extract(r, contentType, maxDataBodyBytes)
+	var tooBig *http.MaxBytesError
+	switch {
+	case err == nil:
+		return text, true
+	case errors.As(err, &tooBig), errors.Is(err, ingest.ErrTextTooLarge):
+		http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+	default:
+		log.Printf("document extraction error: %v", err)
+		http.Error(w, "could not extract text: "+err.Error(), http.StatusUnprocessableEntity)
+	}
+	return "", false
+}
+
+type uploadRequest struct {
+	TenantID    string `json:"tenant_id,omitempty"`
+	Source      string `json:"source"`
+	Type        string `json:"type"`
+	ContentType string `json:"content_type"`
+	Size        int64  `json:"size,omitempty"`
+}
+
+// UploadHandler starts a resumable upload (POST /v1/uploads).
+func (h *Handler) UploadHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if h.Uploads == nil || h.DataStore == nil {
+		http.Error(w, "uploads are not enabled", http.StatusNotFound)
+		return
+	}
+	var req uploadRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 64<<10)).Decode(&req); err != nil {
+		http.Error(w, "invalid JSON body", http.StatusBadRequest)
+		return
+	}
+	if !ingest.Supported(req.ContentType) {
+		http.Error(w, "unsupported content_type "+strconv.Quote(req.ContentType), http.StatusUnsupportedMediaType)
+		return
+	}
+	u, err := h.Uploads.Create(ingest.Upload{
+		TenantID:    h.tenantID(r, &types.ChatRequest{TenantID: req.TenantID}),
+		Source:      req.Source,
+		Type:        req.Type,
+		ContentType: req.ContentType,
+		Size:        req.Size,
+	})
+	if err != nil {
+		uploadError(w, err)
+		return
+	}
+	w.Header().Set("Location", "/v1/uploads/"+u.ID)
+	writeUpload(w, http.StatusCreated, u)
+}
+
+// UploadItemHandler serves HEAD/GET (current offset) and PATCH (append a
+// chunk at Upload-Offset) on /v1/uploads/{id}, and DELETE to abandon it.
+func (h *Handler) UploadItemHandler(w http.ResponseWriter, r *http.Request) {
+	if h.Uploads == nil {
+		http.Error(w, "uploads are not enabled", http.StatusNotFound)
+		return
+	}
+	tenantID, id := h.tenantID(r, &types.ChatRequest{}), r.PathValue("id")
+	switch r.Method {
+	case http.MethodHead, http.MethodGet:
+		u, err := h.Uploads.Get(tenantID, id)
+		if err != nil {
+			uploadError(w, err)
+			return
+		}
+		writeUpload(w, http.StatusOK, u)
+	case http.MethodPatch:
+		offset, err := strconv.ParseInt(r.Header.Get("Upload-Offset"), 10, 64)
+		if err != nil || offset < 0 {
+			http.Error(w, "Upload-Offset header is required", http.StatusBadRequest)
+			return
+		}
+		n, err := h.Uploads.Append(tenantID, id, offset, r.Body)
+		if n > 0 || err == nil {
+			w.Header().Set("Upload-Offset", strconv.FormatInt(n, 10))
+		}
+		if err != nil {
+			uploadError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	case http.MethodDelete:
+		if _, err := h.Uploads.Get(tenantID, id); err != nil {
+			uploadError(w, err)
+			return
+		}
+		h.Uploads.Remove(id)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// UploadCompleteHandler extracts, scans and registers a finished upload
+// (POST /v1/uploads/{id}/complete) and answers like POST /v1/data.
+func (h *Handler) UploadCompleteHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if h.Uploads == nil || h.DataStore == nil {
+		http.Error(w, "uploads are not enabled", http.StatusNotFound)
+		return
+	}
+	f, u, err := h.Uploads.Open(h.tenantID(r, &types.ChatRequest{}), r.PathValue("id"))
+	if err != nil {
+		uploadError(w, err)
+		return
+	}
+	text, ok := h.extract(w, f, u.ContentType)
+	f.Close()
+	// Success or not, the spooled file has served its purpose; a failed
+	// extraction won't go better on a retry.
+	h.Uploads.Remove(u.ID)
+	if !ok {
+		return
+	}
+	h.register(w, r.Context(), types.DataRegistrationRequest{
+		TenantID: u.TenantID,
+		Source:   u.Source,
+		Type:     u.Type,
+		Content:  text,
+	})
+}
+
+func writeUpload(w http.ResponseWriter, status int, u ingest.Upload) {
+	w.Header().Set("Upload-Offset", strconv.FormatInt(u.Offset, 10))
+	if u.Size > 0 {
+		w.Header().Set("Upload-Length", strconv.FormatInt(u.Size, 10))
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(u); err != nil {
+		log.Printf("encode response error: %v", err)
+	}
+}
+
+func uploadError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ingest.ErrNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, ingest.ErrOffset), errors.Is(err, ingest.ErrBusy), errors.Is(err, ingest.ErrIncomplete):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case errors.Is(err, ingest.ErrTooLarge):
+		http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+	default:
+		log.Printf("upload error: %v", err)
+		http.Error(w, "internal error (upload)", http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,138 @@
+package grpcapi
+
+import (
+	"strings"
+	"time"
+
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	nopassv1 "github.com/shivansh-source/nopass/internal/pb/nopass/v1"
+	"github.com/shivansh-source/nopass/internal/types"
+)
+
+func chatRequest(in *nopassv1.ChatRequest) types.ChatRequest {
+	req := types.ChatRequest{
+		TenantID:      in.GetTenantId(),
+		PolicyProfile: in.GetPolicyProfile(),
+		UserID:        in.GetUserId(),
+		SessionID:     in.GetSessionId(),
+		Message:       in.GetMessage(),
+		DataRefs:      in.GetDataRefs(),
+		ResetSession:  in.GetResetSession(),
+		Priority:      in.GetPriority(),
+		MaskSpans:     in.GetMaskSpans(),
+		Locale:        in.GetLocale(),
+	}
+	for _, d := range in.GetExternalData() {
+		req.ExternalData = append(req.ExternalData, types.ExternalData{ID: d.GetId(), Source: d.GetSource(), Type: d.GetType(), Content: d.GetContent()})
+	}
+	for _, t := range in.GetHistory() {
+		req.History = append(req.History, types.Turn{Role: t.GetRole(), Content: t.GetContent()})
+	}
+	if r := in.GetRetrieve(); r != nil {
+		req.Retrieve = &types.RetrieveSpec{Query: r.GetQuery(), Sources: r.GetSources(), Limit: int(r.GetLimit())}
+	}
+	if g := in.GetGeneration(); g != nil {
+		req.Generation = &types.GenerationParams{
+			Provider:    g.GetProvider(),
+			Model:       g.GetModel(),
+			Temperature: g.Temperature,
+			TopP:        g.TopP,
+			MaxTokens:   int(g.GetMaxTokens()),
+			Stop:        g.GetStop(),
+		}
+	}
+	return req
+}
+
+func chatResponse(resp *types.ChatResponse) *nopassv1.ChatResponse {
+	out := &nopassv1.ChatResponse{
+		SchemaVersion: int32(resp.SchemaVersion),
+		Answer:        resp.Answer,
+		RiskLevel:     riskLevel(resp.RiskLevel),
+		Path:          path(resp.Path),
+		Notices:       resp.Notices,
+		SessionId:     resp.SessionID,
+		HistoryTurns:  int32(resp.HistoryTurns),
+		FeatureId:     resp.FeatureID,
+	}
+	for _, c := range resp.Citations {
+		out.Citations = append(out.Citations, &nopassv1.Citation{Index: int32(c.Index), Url: c.URL, Title: c.Title})
+	}
+	for _, s := range resp.DataStatus {
+		out.DataStatus = append(out.DataStatus, &nopassv1.DataBlockStatus{Id: s.ID, Source: s.Source, Status: string(s.Status), Reason: s.Reason})
+	}
+	if r := resp.Receipt; r != nil {
+		out.Receipt = &nopassv1.SandboxReceipt{
+			Id:              r.ID,
+			TenantId:        r.TenantID,
+			RunnerId:        r.RunnerID,
+			ContainerId:     r.ContainerID,
+			Image:           r.Image,
+			ImageDigest:     r.ImageDigest,
+			StartedAt:       timestamp(r.StartedAt),
+			WallTimeMs:      r.WallTimeMs,
+			CpuTimeMs:       r.CPUTimeMs,
+			PeakMemoryBytes: r.PeakMemoryBytes,
+			ExitCode:        int32(r.ExitCode),
+			OomKilled:       r.OOMKilled,
+			OutputBytes:     int32(r.OutputBytes),
+			OutputProtocol:  r.OutputProtocol,
+			Model:           r.Model,
+			InputTokens:     int32(r.InputTokens),
+			OutputTokens:    int32(r.OutputTokens),
+			Warnings:        r.Warnings,
+		}
+	}
+	if c := resp.DataClasses; c != nil {
+		out.DataClasses = &nopassv1.DataClasses{Input: c.Input, Output: c.Output}
+	}
+	if r := resp.SessionRisk; r != nil {
+		out.SessionRisk = &nopassv1.SessionRisk{
+			Level:       riskLevel(r.Level),
+			Score:       int32(r.Score),
+			Action:      r.Action,
+			Turns:       int32(r.Turns),
+			MediumTurns: int32(r.MediumTurns),
+			HighTurns:   int32(r.HighTurns),
+			UpdatedAt:   timestamp(r.UpdatedAt),
+		}
+		for _, n := range r.Recent {
+			out.SessionRisk.Recent = append(out.SessionRisk.Recent, int32(n))
+		}
+	}
+	for _, s := range resp.MaskedSpans {
+		out.MaskedSpans = append(out.MaskedSpans, &nopassv1.MaskedSpan{Start: int32(s.Start), End: int32(s.End), Kind: s.Kind, Token: s.Token})
+	}
+	for _, a := range resp.Artifacts {
+		out.Artifacts = append(out.Artifacts, &nopassv1.Artifact{
+			Name:        a.Name,
+			ContentType: a.ContentType,
+			Size:        a.Size,
+			Sha256:      a.SHA256,
+			Url:         a.URL,
+			ExpiresAt:   timestamp(a.ExpiresAt),
+		})
+	}
+	for _, f := range resp.StageFailures {
+		out.StageFailures = append(out.StageFailures, &nopassv1.StageFailure{Stage: f.Stage, Policy: f.Policy, Blocks: int32(f.Blocks)})
+	}
+	return out
+}
+
+// riskLevel maps "LOW" to RISK_LEVEL_LOW and so on.
+func riskLevel(l types.RiskLevel) nopassv1.RiskLevel {
+	return nopassv1.RiskLevel(nopassv1.RiskLevel_value["RISK_LEVEL_"+strings.ToUpper(string(l))])
+}
+
+// path maps "fast" to PATH_FAST and so on.
+func path(p types.Path) nopassv1.Path {
+	return nopassv1.Path(nopassv1.Path_value["PATH_"+strings.ToUpper(string(p))])
+}
+
+func timestamp(t time.Time) *timestamppb.Timestamp {
+	if t.IsZero() {
+		return nil
+	}
+	return timestamppb.New(t)
+}
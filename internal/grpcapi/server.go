@@ -0,0 +1,119 @@
+// Package grpcapi serves the chat API over gRPC (nopass.v1.ChatService),
+// an opt-in alternative to POST /v1/chat. Each call is handed to the
+// gateway's own HTTP handler as a /v1/chat request, so authentication,
+// rate limits, residency routing and maintenance mode apply to it exactly
+// as they do to JSON requests.
+package grpcapi
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+
+	nopassv1 "github.com/shivansh-source/nopass/internal/pb/nopass/v1"
+	"github.com/shivansh-source/nopass/internal/types"
+)
+
+// forwardedMetadata are the call metadata passed on as request headers.
+var forwardedMetadata = []string{"authorization", "x-api-key", "x-nopass-tenant", "x-request-id"}
+
+// Server implements ChatService on top of the gateway's HTTP handler.
+type Server struct {
+	nopassv1.UnimplementedChatServiceServer
+	// HTTP is the handler the gateway serves its clients with; calls go
+	// to its /v1/chat route.
+	HTTP http.Handler
+}
+
+// Chat answers one chat request. Streamed answers are only available
+// over the JSON API.
+func (s *Server) Chat(ctx context.Context, in *nopassv1.ChatRequest) (*nopassv1.ChatResponse, error) {
+	if in.GetStream() {
+		return nil, status.Error(codes.InvalidArgument, "streaming is only available over the JSON API")
+	}
+	body, err := json.Marshal(chatRequest(in))
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	r, err := http.NewRequestWithContext(ctx, http.MethodPost, "/v1/chat", bytes.NewReader(body))
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	r.Header.Set("Content-Type", "application/json")
+	md, _ := metadata.FromIncomingContext(ctx)
+	for _, k := range forwardedMetadata {
+		if v := md.Get(k); len(v) > 0 {
+			r.Header.Set(k, v[0])
+		}
+	}
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		r.RemoteAddr = p.Addr.String()
+	}
+
+	w := &recorder{header: make(http.Header), code: http.StatusOK}
+	s.HTTP.ServeHTTP(w, r)
+	if w.code != http.StatusOK {
+		return nil, status.Error(code(w.code), strings.TrimSpace(w.body.String()))
+	}
+	var resp types.ChatResponse
+	if err := json.Unmarshal(w.body.Bytes(), &resp); err != nil {
+		return nil, status.Errorf(codes.Internal, "decode response: %v", err)
+	}
+	return chatResponse(&resp), nil
+}
+
+// code maps the HTTP status of a failed request to a gRPC code.
+func code(httpStatus int) codes.Code {
+	switch httpStatus {
+	case http.StatusBadRequest, http.StatusRequestEntityTooLarge, http.StatusUnsupportedMediaType:
+		return codes.InvalidArgument
+	case http.StatusUnauthorized:
+		return codes.Unauthenticated
+	case http.StatusForbidden:
+		return codes.PermissionDenied
+	case http.StatusNotFound:
+		return codes.NotFound
+	case http.StatusConflict:
+		return codes.Aborted
+	case http.StatusMisdirectedRequest, http.StatusUnprocessableEntity:
+		return codes.FailedPrecondition
+	case http.StatusTooManyRequests:
+		return codes.ResourceExhausted
+	case http.StatusServiceUnavailable, http.StatusBadGateway:
+		return codes.Unavailable
+	case http.StatusGatewayTimeout:
+		return codes.DeadlineExceeded
+	case http.StatusNotImplemented:
+		return codes.Unimplemented
+	default:
+		return codes.Internal
+	}
+}
+
+// recorder buffers the HTTP handler's response.
+type recorder struct {
+	header http.Header
+	code   int
+	body   bytes.Buffer
+	wrote  bool
+}
+
+func (w *recorder) Header() http.Header { return w.header }
+
+func (w *recorder) WriteHeader(code int) {
+	if !w.wrote {
+		w.code, w.wrote = code, true
+	}
+}
+
+func (w *recorder) Write(p []byte) (int, error) {
+	w.wrote = true
+	return w.body.Write(p)
+}
@@ -0,0 +1,64 @@
+package grpcapi
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	nopassv1 "github.com/shivansh-source/nopass/internal/pb/nopass/v1"
+	"github.com/shivansh-source/nopass/internal/types"
+)
+
+func TestChat(t *testing.T) {
+	var got types.ChatRequest
+	var gotKey string
+	chat := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotKey = r.Header.Get("X-API-Key")
+		if gotKey == "" {
+			http.Error(w, "missing API key", http.StatusUnauthorized)
+			return
+		}
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			http.Error(w, "invalid JSON", http.StatusBadRequest)
+			return
+		}
+		json.NewEncoder(w).Encode(types.ChatResponse{
+			SchemaVersion: 1,
+			Answer:        "hello",
+			RiskLevel:     types.RiskMedium,
+			Path:          types.PathSlow,
+		})
+	})
+	s := &Server{HTTP: chat}
+	in := &nopassv1.ChatRequest{
+		UserId:  "u1",
+		Message: "hi",
+		History: []*nopassv1.Turn{{Role: "user", Content: "earlier"}},
+	}
+
+	if _, err := s.Chat(context.Background(), in); status.Code(err) != codes.Unauthenticated {
+		t.Fatalf("Chat without a key: error = %v, want Unauthenticated", err)
+	}
+
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("x-api-key", "k1"))
+	resp, err := s.Chat(ctx, in)
+	if err != nil {
+		t.Fatalf("Chat: %v", err)
+	}
+	if gotKey != "k1" || got.UserID != "u1" || got.Message != "hi" || len(got.History) != 1 || got.History[0].Content != "earlier" {
+		t.Errorf("handler got key %q, request %+v", gotKey, got)
+	}
+	if resp.GetAnswer() != "hello" || resp.GetRiskLevel() != nopassv1.RiskLevel_RISK_LEVEL_MEDIUM || resp.GetPath() != nopassv1.Path_PATH_SLOW {
+		t.Errorf("Chat = %v", resp)
+	}
+
+	in.Stream = true
+	if _, err := s.Chat(ctx, in); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("Chat with stream: error = %v, want InvalidArgument", err)
+	}
+}
@@ -0,0 +1,100 @@
+package ingest
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"mime"
+	"strings"
+	"unicode/utf8"
+)
+
+// ErrUnsupported means there is no extractor for the content type.
+var ErrUnsupported = errors.New("unsupported content type")
+
+// ErrTextTooLarge means the extracted text exceeds the caller's limit.
+var ErrTextTooLarge = errors.New("extracted text exceeds the size limit")
+
+// Supported reports whether Extract handles contentType.
+func Supported(contentType string) bool {
+	mt, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	return isText(mt) || mt == "application/pdf"
+}
+
+func isText(mt string) bool {
+	return strings.HasPrefix(mt, "text/") || mt == "application/json" || mt == "application/xml"
+}
+
+// Extract reads the document from r and returns its text, at most limit
+// bytes of it. r is consumed as a stream; nothing but the text is kept.
+func Extract(r io.Reader, contentType string, limit int) (string, error) {
+	mt, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return "", ErrUnsupported
+	}
+	out := &textBuffer{limit: limit}
+	switch {
+	case isText(mt):
+		err = copyText(out, r)
+	case mt == "application/pdf":
+		err = extractPDF(out, r)
+	default:
+		return "", ErrUnsupported
+	}
+	if err != nil {
+		return "", err
+	}
+	return out.String(), nil
+}
+
+// copyText copies UTF-8 text, replacing invalid sequences, without
+// splitting a character across reads.
+func copyText(out *textBuffer, r io.Reader) error {
+	br := bufio.NewReaderSize(r, 32<<10)
+	buf := make([]byte, 32<<10)
+	var carry []byte
+	for {
+		n, err := br.Read(buf)
+		chunk := append(carry, buf[:n]...)
+		cut := len(chunk)
+		// Hold back an incomplete trailing sequence for the next read.
+		for i := 1; i < utf8.UTFMax && i <= len(chunk); i++ {
+			if utf8.RuneStart(chunk[len(chunk)-i]) {
+				if !utf8.FullRune(chunk[len(chunk)-i:]) {
+					cut = len(chunk) - i
+				}
+				break
+			}
+		}
+		if err == io.EOF {
+			cut = len(chunk)
+		}
+		if werr := out.WriteString(strings.ToValidUTF8(string(chunk[:cut]), "�")); werr != nil {
+			return werr
+		}
+		carry = append(carry[:0:0], chunk[cut:]...)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// textBuffer collects extracted text up to limit bytes.
+type textBuffer struct {
+	strings.Builder
+	limit int
+}
+
+func (t *textBuffer) WriteString(s string) error {
+	if t.Len()+len(s) > t.limit {
+		return ErrTextTooLarge
+	}
+	t.Builder.WriteString(s)
+	return nil
+}
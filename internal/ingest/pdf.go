@@ -0,0 +1,282 @@
+package ingest
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"errors"
+	"io"
+	"strings"
+)
+
+// PDF extraction is deliberately small: it walks the file once, inflates
+// each Flate (or unfiltered) stream that isn't an image, and collects the
+// literal strings shown between BT and ET. That recovers the text of most
+// generated PDFs (reports, exports, invoices). Text in CID-keyed fonts,
+// which needs the font's CMap, and scanned pages aren't recovered.
+
+// maxInflatedStream bounds the decompressed size of one stream, against
+// compression bombs.
+const maxInflatedStream = 64 << 20
+
+var (
+	streamKeyword    = []byte("stream")
+	endstreamKeyword = []byte("endstream")
+)
+
+func extractPDF(out *textBuffer, r io.Reader) error {
+	br := bufio.NewReaderSize(r, 64<<10)
+	header := make([]byte, 5)
+	if _, err := io.ReadFull(br, header); err != nil || string(header) != "%PDF-" {
+		return errors.New("not a PDF file")
+	}
+
+	// window holds the bytes since the last stream, enough to see the
+	// dictionary in front of the next one.
+	var window []byte
+	for {
+		b, err := br.ReadByte()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		window = append(window, b)
+		if len(window) > 4096 {
+			window = append(window[:0], window[len(window)-2048:]...)
+		}
+		if !bytes.HasSuffix(window, streamKeyword) || bytes.HasSuffix(window, endstreamKeyword) {
+			continue
+		}
+		dict := string(window[bytes.LastIndex(window, []byte("obj"))+1:])
+		window = window[:0]
+		if err := skipEOL(br); err != nil {
+			return err
+		}
+
+		var content io.Reader
+		switch {
+		case strings.Contains(dict, "/Image"):
+		case strings.Contains(dict, "/FlateDecode"):
+			zr, err := zlib.NewReader(br)
+			if err != nil {
+				break // damaged stream; skip it
+			}
+			content = io.LimitReader(zr, maxInflatedStream)
+		case !strings.Contains(dict, "/Filter"):
+			content = &untilReader{r: br, end: endstreamKeyword}
+		}
+		if content != nil {
+			if err := contentText(out, content); err != nil {
+				return err
+			}
+		}
+		if err := skipPast(br, endstreamKeyword); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+	}
+}
+
+func skipEOL(br *bufio.Reader) error {
+	b, err := br.ReadByte()
+	if err != nil {
+		return err
+	}
+	if b == '\r' {
+		if next, err := br.Peek(1); err == nil && next[0] == '\n' {
+			br.ReadByte()
+		}
+		return nil
+	}
+	if b != '\n' {
+		return br.UnreadByte()
+	}
+	return nil
+}
+
+// skipPast consumes input up to and including token.
+func skipPast(br *bufio.Reader, token []byte) error {
+	matched := 0
+	for matched < len(token) {
+		b, err := br.ReadByte()
+		if err != nil {
+			return err
+		}
+		switch {
+		case b == token[matched]:
+			matched++
+		case b == token[0]:
+			matched = 1
+		default:
+			matched = 0
+		}
+	}
+	return nil
+}
+
+// untilReader reads an unfiltered stream: everything before end. The end
+// keyword is left for skipPast.
+type untilReader struct {
+	r   *bufio.Reader
+	end []byte
+}
+
+func (u *untilReader) Read(p []byte) (int, error) {
+	n := 0
+	for n < len(p) {
+		peek, err := u.r.Peek(len(u.end))
+		if bytes.Equal(peek, u.end) || (err != nil && len(peek) == 0) {
+			if n == 0 {
+				return 0, io.EOF
+			}
+			break
+		}
+		b, _ := u.r.ReadByte()
+		p[n] = b
+		n++
+	}
+	return n, nil
+}
+
+// contentText collects the strings shown by a content stream's text
+// operators. Line-moving operators become newlines; large negative kerning
+// in TJ arrays becomes a space.
+func contentText(out *textBuffer, r io.Reader) error {
+	br := bufio.NewReader(r)
+	inText := false
+	var token []byte
+	var line strings.Builder
+	flush := func(sep string) error {
+		if line.Len() == 0 {
+			return nil
+		}
+		err := out.WriteString(strings.TrimRight(line.String(), " ") + sep)
+		line.Reset()
+		return err
+	}
+	endToken := func() error {
+		t := string(token)
+		token = token[:0]
+		switch t {
+		case "BT":
+			inText = true
+		case "ET":
+			inText = false
+			return flush("\n")
+		case "Td", "TD", "T*", "'", `"`:
+			return flush("\n")
+		case "Tj", "TJ":
+			line.WriteByte(' ')
+		default:
+			if inText && len(t) > 1 && t[0] == '-' && t[1] >= '0' && t[1] <= '9' && len(strings.SplitN(t, ".", 2)[0]) >= 4 {
+				// TJ kerning of -100 or more (in thousandths of an em) is a gap.
+				line.WriteByte(' ')
+			}
+		}
+		return nil
+	}
+
+	for {
+		b, err := br.ReadByte()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			// A truncated or corrupt stream still yields what was read.
+			break
+		}
+		switch b {
+		case '(':
+			if err := endToken(); err != nil {
+				return err
+			}
+			s := readLiteral(br)
+			if inText {
+				line.WriteString(s)
+			}
+		case '<':
+			if err := endToken(); err != nil {
+				return err
+			}
+			if next, _ := br.Peek(1); len(next) == 1 && next[0] == '<' {
+				br.ReadByte()
+				continue
+			}
+			skipPast(br, []byte(">"))
+		case ' ', '\t', '\r', '\n', '\f', 0, '[', ']', '>', '{', '}', '/':
+			if err := endToken(); err != nil {
+				return err
+			}
+		case '%':
+			if err := endToken(); err != nil {
+				return err
+			}
+			br.ReadString('\n')
+		default:
+			token = append(token, b)
+		}
+	}
+	if err := endToken(); err != nil {
+		return err
+	}
+	return flush("\n")
+}
+
+// readLiteral reads a literal string after its opening parenthesis,
+// decoding escapes. Bytes map to Latin-1, which matches PDFDocEncoding for
+// the printable range.
+func readLiteral(br *bufio.Reader) string {
+	var s strings.Builder
+	depth := 1
+	for {
+		b, err := br.ReadByte()
+		if err != nil {
+			return s.String()
+		}
+		switch b {
+		case '(':
+			depth++
+		case ')':
+			if depth--; depth == 0 {
+				return s.String()
+			}
+		case '\\':
+			e, err := br.ReadByte()
+			if err != nil {
+				return s.String()
+			}
+			switch e {
+			case 'n':
+				b = '\n'
+			case 'r':
+				b = '\r'
+			case 't':
+				b = '\t'
+			case 'b':
+				b = '\b'
+			case 'f':
+				b = '\f'
+			case '\r', '\n':
+				continue // line continuation
+			case '0', '1', '2', '3', '4', '5', '6', '7':
+				v := int(e - '0')
+				for i := 0; i < 2; i++ {
+					next, err := br.Peek(1)
+					if err != nil || next[0] < '0' || next[0] > '7' {
+						break
+					}
+					br.ReadByte()
+					v = v*8 + int(next[0]-'0')
+				}
+				b = byte(v)
+			default:
+				b = e
+			}
+		}
+		s.WriteRune(rune(b))
+	}
+}
@@ -0,0 +1,234 @@
+// Package ingest accepts large external documents without holding them in
+// memory. An upload is written to a spool file chunk by chunk, so a client
+// can resume after a dropped connection from the last acknowledged offset,
+// and text is extracted from the file (or straight from a request body) as
+// a stream; only the extracted text, which the datastore keeps anyway, is
+// ever held whole.
+package ingest
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+var (
+	ErrNotFound = errors.New("upload not found")
+	// ErrOffset means a chunk didn't start where the upload ends; the
+	// client should ask for the current offset and resume from there.
+	ErrOffset = errors.New("chunk offset does not match upload offset")
+	// ErrBusy means another chunk for the same upload is still being
+	// written.
+	ErrBusy     = errors.New("upload is busy")
+	ErrTooLarge = errors.New("upload exceeds the size limit")
+	// ErrIncomplete means fewer bytes than the declared size were received.
+	ErrIncomplete = errors.New("upload is incomplete")
+)
+
+// Upload describes one resumable upload.
+type Upload struct {
+	ID          string    `json:"id"`
+	TenantID    string    `json:"tenant_id,omitempty"`
+	Source      string    `json:"source"`
+	Type        string    `json:"type"`
+	ContentType string    `json:"content_type"`
+	Size        int64     `json:"size,omitempty"` // declared total; 0 if unknown
+	Offset      int64     `json:"offset"`         // bytes received so far
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+type entry struct {
+	Upload
+	path string
+	busy bool
+}
+
+// Spool keeps in-progress uploads as files under Dir. Uploads untouched
+// for TTL are deleted by Run.
+type Spool struct {
+	Dir      string
+	MaxBytes int64
+	TTL      time.Duration
+
+	mu      sync.Mutex
+	uploads map[string]*entry
+}
+
+// NewSpool creates dir if needed. Leftover files from a previous process
+// are removed: their upload state was in memory and is gone.
+func NewSpool(dir string, maxBytes int64, ttl time.Duration) (*Spool, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("ingest: create spool dir: %w", err)
+	}
+	stale, _ := filepath.Glob(filepath.Join(dir, "upl_*"))
+	for _, f := range stale {
+		os.Remove(f)
+	}
+	return &Spool{Dir: dir, MaxBytes: maxBytes, TTL: ttl, uploads: make(map[string]*entry)}, nil
+}
+
+// Create starts an upload described by u (ID, Offset and times are set
+// here).
+func (s *Spool) Create(u Upload) (Upload, error) {
+	if u.Size < 0 || u.Size > s.MaxBytes {
+		return Upload{}, ErrTooLarge
+	}
+	var b [12]byte
+	_, _ = rand.Read(b[:])
+	u.ID = "upl_" + hex.EncodeToString(b[:])
+	u.Offset = 0
+	u.CreatedAt = time.Now().UTC()
+	u.UpdatedAt = u.CreatedAt
+
+	e := &entry{Upload: u, path: filepath.Join(s.Dir, u.ID)}
+	f, err := os.OpenFile(e.path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+	if err != nil {
+		return Upload{}, fmt.Errorf("ingest: %w", err)
+	}
+	f.Close()
+
+	s.mu.Lock()
+	s.uploads[u.ID] = e
+	s.mu.Unlock()
+	return u, nil
+}
+
+// Get returns the upload if it exists and belongs to tenantID.
+func (s *Spool) Get(tenantID, id string) (Upload, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, ok := s.uploads[id]
+	if !ok || e.TenantID != tenantID {
+		return Upload{}, ErrNotFound
+	}
+	return e.Upload, nil
+}
+
+// acquire marks the upload busy so chunks are written one at a time.
+func (s *Spool) acquire(tenantID, id string) (*entry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, ok := s.uploads[id]
+	if !ok || e.TenantID != tenantID {
+		return nil, ErrNotFound
+	}
+	if e.busy {
+		return nil, ErrBusy
+	}
+	e.busy = true
+	return e, nil
+}
+
+func (s *Spool) release(e *entry) {
+	s.mu.Lock()
+	e.busy = false
+	e.UpdatedAt = time.Now().UTC()
+	s.mu.Unlock()
+}
+
+// Append writes the chunk read from r at offset, which must equal the
+// upload's current offset. The offset advances by whatever was written
+// before an error, so a client whose connection dropped mid-chunk resumes
+// from there. It returns the new offset.
+func (s *Spool) Append(tenantID, id string, offset int64, r io.Reader) (int64, error) {
+	e, err := s.acquire(tenantID, id)
+	if err != nil {
+		return 0, err
+	}
+	defer s.release(e)
+	if offset != e.Offset {
+		return e.Offset, ErrOffset
+	}
+
+	limit := s.MaxBytes
+	if e.Size > 0 {
+		limit = e.Size
+	}
+	f, err := os.OpenFile(e.path, os.O_WRONLY, 0)
+	if err != nil {
+		return e.Offset, fmt.Errorf("ingest: %w", err)
+	}
+	defer f.Close()
+	if _, err := f.Seek(e.Offset, io.SeekStart); err != nil {
+		return e.Offset, fmt.Errorf("ingest: %w", err)
+	}
+	// One byte past the limit tells an oversized chunk from an exact fit.
+	n, err := io.Copy(f, io.LimitReader(r, limit-e.Offset+1))
+	if e.Offset+n > limit {
+		f.Truncate(e.Offset)
+		return e.Offset, ErrTooLarge
+	}
+	e.Offset += n
+	if err != nil {
+		return e.Offset, fmt.Errorf("ingest: chunk interrupted: %w", err)
+	}
+	return e.Offset, nil
+}
+
+// Open returns the complete upload's file for reading. The upload stays
+// busy until Remove, so no chunk can change it while it is read.
+func (s *Spool) Open(tenantID, id string) (*os.File, Upload, error) {
+	e, err := s.acquire(tenantID, id)
+	if err != nil {
+		return nil, Upload{}, err
+	}
+	if e.Size > 0 && e.Offset != e.Size {
+		s.release(e)
+		return nil, e.Upload, ErrIncomplete
+	}
+	f, err := os.Open(e.path)
+	if err != nil {
+		s.release(e)
+		return nil, e.Upload, fmt.Errorf("ingest: %w", err)
+	}
+	return f, e.Upload, nil
+}
+
+// Remove deletes the upload and its file.
+func (s *Spool) Remove(id string) {
+	s.mu.Lock()
+	e, ok := s.uploads[id]
+	delete(s.uploads, id)
+	s.mu.Unlock()
+	if ok {
+		os.Remove(e.path)
+	}
+}
+
+// Run deletes abandoned uploads until ctx is done.
+func (s *Spool) Run(ctx context.Context) {
+	if s.TTL <= 0 {
+		return
+	}
+	t := time.NewTicker(s.TTL / 2)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			cutoff := time.Now().Add(-s.TTL)
+			var expired []string
+			s.mu.Lock()
+			for id, e := range s.uploads {
+				if !e.busy && e.UpdatedAt.Before(cutoff) {
+					expired = append(expired, id)
+				}
+			}
+			s.mu.Unlock()
+			for _, id := range expired {
+				log.Printf("ingest: removing abandoned upload %s", id)
+				s.Remove(id)
+			}
+		}
+	}
+}
@@ -0,0 +1,423 @@
+// Package jobs runs batch jobs: many chat requests submitted at once and
+// answered in the background through the gateway's own pipeline. Every
+// item's result is checkpointed in the storage backend as soon as it is
+// known, so a gateway restart resumes a job at its first unanswered item
+// instead of starting over, and clients can read partial results while
+// the job runs.
+//
+// With a shared storage backend, run the job runner on one instance only:
+// every runner resumes every unfinished job it finds.
+package jobs
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/shivansh-source/nopass/internal/auth"
+	"github.com/shivansh-source/nopass/internal/metrics"
+	"github.com/shivansh-source/nopass/internal/storage"
+	"github.com/shivansh-source/nopass/internal/types"
+)
+
+// ErrNotFound is returned for a job that doesn't exist.
+var ErrNotFound = errors.New("job not found")
+
+// Status is where a job or item is.
+type Status string
+
+const (
+	StatusQueued    Status = "queued"
+	StatusRunning   Status = "running"
+	StatusDone      Status = "done"
+	StatusCancelled Status = "cancelled"
+
+	// Item statuses; a pending item has not been answered yet.
+	StatusPending Status = "pending"
+	StatusFailed  Status = "failed"
+)
+
+// Job is a batch job's progress.
+type Job struct {
+	ID        string    `json:"id"`
+	TenantID  string    `json:"tenant_id"`
+	Status    Status    `json:"status"`
+	Total     int       `json:"total"`
+	Completed int       `json:"completed"` // answered, including failed
+	Failed    int       `json:"failed"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Item is one request of a job and, once answered, its result.
+type Item struct {
+	Index    int                 `json:"index"`
+	Status   Status              `json:"status"`
+	Request  types.ChatRequest   `json:"request"`
+	HTTPCode int                 `json:"http_code,omitempty"`
+	Response *types.ChatResponse `json:"response,omitempty"`
+	Error    string              `json:"error,omitempty"`
+}
+
+// record is what the jobs collection stores: the job and the credentials
+// its items run with.
+type record struct {
+	Job
+	Key      *auth.Key      `json:"key,omitempty"`
+	Identity *auth.Identity `json:"identity,omitempty"`
+}
+
+// Record collections.
+const (
+	jobsCollection = "jobs"
+	itemsPrefix    = "job_items:" // + job ID; items keyed by zero-padded index
+)
+
+// MaxItems bounds the size of one job.
+const MaxItems = 100000
+
+// Runner runs jobs through Chat, the gateway's /v1/chat handler without
+// authentication (the job was authenticated when submitted; its key or
+// token identity goes along with every item).
+type Runner struct {
+	Chat    http.Handler
+	Records storage.RecordStore
+	// Concurrency is how many items of a job run at once (0 = 4).
+	Concurrency int
+
+	mu      sync.Mutex
+	running map[string]context.CancelCauseFunc
+}
+
+// errCancelled is the cause of a running job's cancellation by Cancel.
+var errCancelled = errors.New("job cancelled")
+
+var itemResults = metrics.NewCounterVec(
+	"nopass_job_items_total",
+	"Batch job items answered, by result.",
+	"result",
+)
+
+func newJobID() string {
+	var b [12]byte
+	_, _ = rand.Read(b[:])
+	return "job_" + hex.EncodeToString(b[:])
+}
+
+// Submit stores a new job for tenantID and starts it. Items run at batch
+// priority unless they name another, and never stream.
+func (r *Runner) Submit(ctx context.Context, tenantID string, items []types.ChatRequest) (*Job, error) {
+	if len(items) == 0 || len(items) > MaxItems {
+		return nil, fmt.Errorf("a job needs 1 to %d items", MaxItems)
+	}
+	now := time.Now().UTC()
+	rec := record{
+		Job:      Job{ID: newJobID(), TenantID: tenantID, Status: StatusQueued, Total: len(items), CreatedAt: now, UpdatedAt: now},
+		Key:      auth.KeyFrom(ctx),
+		Identity: auth.IdentityFrom(ctx),
+	}
+	for i, req := range items {
+		req.TenantID, req.Stream = tenantID, false
+		if req.Priority == "" {
+			req.Priority = "batch"
+		}
+		if err := r.putItem(ctx, rec.ID, Item{Index: i, Status: StatusPending, Request: req}); err != nil {
+			return nil, err
+		}
+	}
+	if err := r.put(ctx, rec); err != nil {
+		return nil, err
+	}
+	r.start(rec)
+	return &rec.Job, nil
+}
+
+// Get returns a job.
+func (r *Runner) Get(ctx context.Context, id string) (*Job, error) {
+	rec, err := r.get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return &rec.Job, nil
+}
+
+// Results returns up to limit items of a job from offset on, answered or
+// not.
+func (r *Runner) Results(ctx context.Context, id string, offset, limit int) ([]Item, error) {
+	raw, err := r.Records.ListRecords(ctx, itemsPrefix+id)
+	if err != nil {
+		return nil, err
+	}
+	if offset >= len(raw) {
+		return nil, nil
+	}
+	raw = raw[offset:]
+	if limit > 0 && limit < len(raw) {
+		raw = raw[:limit]
+	}
+	items := make([]Item, len(raw))
+	for i, data := range raw {
+		if err := json.Unmarshal(data, &items[i]); err != nil {
+			return nil, fmt.Errorf("decode job item: %w", err)
+		}
+	}
+	return items, nil
+}
+
+// Cancel stops a job; items already answered keep their results.
+func (r *Runner) Cancel(ctx context.Context, id string) error {
+	rec, err := r.get(ctx, id)
+	if err != nil {
+		return err
+	}
+	r.mu.Lock()
+	stop, running := r.running[id]
+	r.mu.Unlock()
+	if running {
+		// The job's own goroutine records the cancellation once its
+		// in-flight items have stopped.
+		stop(errCancelled)
+		return nil
+	}
+	if rec.Status == StatusDone {
+		return nil
+	}
+	rec.Status, rec.UpdatedAt = StatusCancelled, time.Now().UTC()
+	return r.put(ctx, rec)
+}
+
+// Resume restarts every job a previous run left queued or running. Call
+// it once at startup.
+func (r *Runner) Resume(ctx context.Context) error {
+	raw, err := r.Records.ListRecords(ctx, jobsCollection)
+	if err != nil {
+		return err
+	}
+	for _, data := range raw {
+		var rec record
+		if err := json.Unmarshal(data, &rec); err != nil {
+			return fmt.Errorf("decode job: %w", err)
+		}
+		if rec.Status == StatusQueued || rec.Status == StatusRunning {
+			log.Printf("resuming batch job %s (%d/%d items done)", rec.ID, rec.Completed, rec.Total)
+			r.start(rec)
+		}
+	}
+	return nil
+}
+
+func (r *Runner) start(rec record) {
+	ctx, cancel := context.WithCancelCause(context.Background())
+	r.mu.Lock()
+	if r.running == nil {
+		r.running = make(map[string]context.CancelCauseFunc)
+	}
+	if _, ok := r.running[rec.ID]; ok {
+		r.mu.Unlock()
+		cancel(nil)
+		return
+	}
+	r.running[rec.ID] = cancel
+	r.mu.Unlock()
+
+	go func() {
+		defer func() {
+			r.mu.Lock()
+			delete(r.running, rec.ID)
+			r.mu.Unlock()
+			cancel(nil)
+		}()
+		if err := r.run(ctx, rec); err != nil && ctx.Err() == nil {
+			log.Printf("batch job %s stopped: %v", rec.ID, err)
+		}
+	}()
+}
+
+// run answers the job's pending items, checkpointing each result and the
+// job's counters as it goes.
+func (r *Runner) run(ctx context.Context, rec record) error {
+	raw, err := r.Records.ListRecords(ctx, itemsPrefix+rec.ID)
+	if err != nil {
+		return err
+	}
+	var pending []Item
+	rec.Completed, rec.Failed = 0, 0
+	for _, data := range raw {
+		var it Item
+		if err := json.Unmarshal(data, &it); err != nil {
+			return fmt.Errorf("decode job item: %w", err)
+		}
+		switch it.Status {
+		case StatusPending:
+			pending = append(pending, it)
+		case StatusFailed:
+			rec.Failed++
+			rec.Completed++
+		default:
+			rec.Completed++
+		}
+	}
+	rec.Status, rec.UpdatedAt = StatusRunning, time.Now().UTC()
+	if err := r.put(ctx, rec); err != nil {
+		return err
+	}
+
+	workers := r.Concurrency
+	if workers <= 0 {
+		workers = 4
+	}
+	var (
+		mu       sync.Mutex
+		firstErr error
+		wg       sync.WaitGroup
+	)
+	queue := make(chan Item)
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for it := range queue {
+				r.answer(ctx, rec, &it)
+				if ctx.Err() != nil {
+					return // cancelled mid-item: leave it pending
+				}
+				err := r.putItem(ctx, rec.ID, it)
+				mu.Lock()
+				if err == nil {
+					rec.Completed++
+					if it.Status == StatusFailed {
+						rec.Failed++
+					}
+					rec.UpdatedAt = time.Now().UTC()
+					err = r.put(ctx, rec)
+				}
+				if err != nil && firstErr == nil {
+					firstErr = err
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+feed:
+	for _, it := range pending {
+		select {
+		case queue <- it:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(queue)
+	wg.Wait()
+	if firstErr != nil {
+		return firstErr
+	}
+	if errors.Is(context.Cause(ctx), errCancelled) {
+		rec.Status, rec.UpdatedAt = StatusCancelled, time.Now().UTC()
+		return r.put(ctx, rec)
+	}
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	rec.Status, rec.UpdatedAt = StatusDone, time.Now().UTC()
+	return r.put(ctx, rec)
+}
+
+// answer runs one item through the chat pipeline and fills in its result.
+func (r *Runner) answer(ctx context.Context, rec record, it *Item) {
+	body, err := json.Marshal(it.Request)
+	if err != nil {
+		it.Status, it.Error = StatusFailed, err.Error()
+		return
+	}
+	if rec.Key != nil {
+		ctx = auth.WithKey(ctx, rec.Key)
+	}
+	if rec.Identity != nil {
+		ctx = auth.WithIdentity(ctx, rec.Identity)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, "/v1/chat", bytes.NewReader(body))
+	if err != nil {
+		it.Status, it.Error = StatusFailed, err.Error()
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-NoPass-Tenant", rec.TenantID)
+	w := &recorder{header: make(http.Header), code: http.StatusOK}
+	r.Chat.ServeHTTP(w, req)
+
+	it.HTTPCode = w.code
+	if w.code != http.StatusOK {
+		it.Status, it.Error = StatusFailed, string(bytes.TrimSpace(w.body.Bytes()))
+		itemResults.Inc("failed")
+		return
+	}
+	var resp types.ChatResponse
+	if err := json.Unmarshal(w.body.Bytes(), &resp); err != nil {
+		it.Status, it.Error = StatusFailed, "decode response: "+err.Error()
+		itemResults.Inc("failed")
+		return
+	}
+	it.Status, it.Response = StatusDone, &resp
+	itemResults.Inc("done")
+}
+
+func (r *Runner) get(ctx context.Context, id string) (record, error) {
+	data, err := r.Records.GetRecord(ctx, jobsCollection, id)
+	if errors.Is(err, storage.ErrNotFound) {
+		return record{}, ErrNotFound
+	}
+	if err != nil {
+		return record{}, err
+	}
+	var rec record
+	if err := json.Unmarshal(data, &rec); err != nil {
+		return record{}, fmt.Errorf("decode job: %w", err)
+	}
+	return rec, nil
+}
+
+func (r *Runner) put(ctx context.Context, rec record) error {
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	// A cancelled job still records how far it got.
+	return r.Records.PutRecord(context.WithoutCancel(ctx), jobsCollection, rec.ID, data)
+}
+
+func (r *Runner) putItem(ctx context.Context, jobID string, it Item) error {
+	data, err := json.Marshal(it)
+	if err != nil {
+		return err
+	}
+	return r.Records.PutRecord(context.WithoutCancel(ctx), itemsPrefix+jobID, fmt.Sprintf("%08d", it.Index), data)
+}
+
+// recorder captures the chat handler's response to one item.
+type recorder struct {
+	header http.Header
+	code   int
+	body   bytes.Buffer
+	wrote  bool
+}
+
+func (w *recorder) Header() http.Header { return w.header }
+
+func (w *recorder) WriteHeader(code int) {
+	if !w.wrote {
+		w.code, w.wrote = code, true
+	}
+}
+
+func (w *recorder) Write(p []byte) (int, error) {
+	w.wrote = true
+	return w.body.Write(p)
+}
@@ -0,0 +1,196 @@
+// Package kube is a client for the Kubernetes API covering what the
+// sandbox needs: Jobs, their Pods and logs, Secrets and NetworkPolicies.
+// Like package docker it speaks the REST API directly, so the gateway
+// doesn't carry client-go's dependency tree.
+package kube
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ServiceAccountDir is where a pod's service account credentials are
+// mounted.
+const ServiceAccountDir = "/var/run/secrets/kubernetes.io/serviceaccount"
+
+// tokenTTL is how long a read token is used before the file is read
+// again: the kubelet rotates projected tokens well before they expire.
+const tokenTTL = time.Minute
+
+// Client is a connection to the API server. It is safe for concurrent
+// use.
+type Client struct {
+	hc        *http.Client
+	base      string // e.g. "https://10.0.0.1:443"
+	namespace string
+	tokenFile string
+
+	mu      sync.Mutex
+	token   string
+	tokenAt time.Time
+}
+
+// InCluster returns a client for the cluster the process runs in, with
+// the pod's service account: the API server from KUBERNETES_SERVICE_HOST
+// and KUBERNETES_SERVICE_PORT, the credentials from ServiceAccountDir.
+func InCluster() (*Client, error) {
+	host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+	if host == "" || port == "" {
+		return nil, errors.New("kube: not running in a cluster (KUBERNETES_SERVICE_HOST unset)")
+	}
+	ca, err := os.ReadFile(filepath.Join(ServiceAccountDir, "ca.crt"))
+	if err != nil {
+		return nil, fmt.Errorf("kube: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(ca) {
+		return nil, fmt.Errorf("kube: no certificates in %s", filepath.Join(ServiceAccountDir, "ca.crt"))
+	}
+	ns, err := os.ReadFile(filepath.Join(ServiceAccountDir, "namespace"))
+	if err != nil {
+		return nil, fmt.Errorf("kube: %w", err)
+	}
+	c := &Client{
+		hc: &http.Client{Transport: &http.Transport{
+			TLSClientConfig:     &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12},
+			MaxIdleConnsPerHost: 16,
+			IdleConnTimeout:     90 * time.Second,
+		}},
+		base:      "https://" + net.JoinHostPort(host, port),
+		namespace: strings.TrimSpace(string(ns)),
+		tokenFile: filepath.Join(ServiceAccountDir, "token"),
+	}
+	if _, err := c.bearer(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Namespace is the namespace the process runs in.
+func (c *Client) Namespace() string {
+	return c.namespace
+}
+
+// bearer returns the service account token, re-read once it is tokenTTL
+// old.
+func (c *Client) bearer() (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.token != "" && time.Since(c.tokenAt) < tokenTTL {
+		return c.token, nil
+	}
+	data, err := os.ReadFile(c.tokenFile)
+	if err != nil {
+		if c.token != "" {
+			// Keep using the last one; it is likely still valid.
+			return c.token, nil
+		}
+		return "", fmt.Errorf("kube: %w", err)
+	}
+	c.token, c.tokenAt = strings.TrimSpace(string(data)), time.Now()
+	return c.token, nil
+}
+
+// Error is a request the API server refused.
+type Error struct {
+	Status int
+	// Reason is the Status object's, e.g. NotFound or AlreadyExists.
+	Reason  string
+	Message string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("kube: %s (HTTP %d)", e.Message, e.Status)
+}
+
+// IsNotFound reports whether err is the API server saying the object
+// doesn't exist.
+func IsNotFound(err error) bool {
+	var e *Error
+	return errors.As(err, &e) && e.Status == http.StatusNotFound
+}
+
+// IsAlreadyExists reports whether err is the API server refusing to
+// create an object that exists.
+func IsAlreadyExists(err error) bool {
+	var e *Error
+	return errors.As(err, &e) && e.Status == http.StatusConflict && e.Reason == "AlreadyExists"
+}
+
+// do sends a request with a JSON body, if not nil, and decodes the JSON
+// response into out, if not nil.
+func (c *Client) do(ctx context.Context, method, path string, query url.Values, body, out any) error {
+	resp, err := c.send(ctx, method, path, query, body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if out == nil {
+		_, _ = io.Copy(io.Discard, resp.Body)
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("kube: decode %s %s: %w", method, path, err)
+	}
+	return nil
+}
+
+// send sends a request and returns the response if it succeeded.
+func (c *Client) send(ctx context.Context, method, path string, query url.Values, body any) (*http.Response, error) {
+	var rd io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return nil, fmt.Errorf("kube: encode %s %s: %w", method, path, err)
+		}
+		rd = bytes.NewReader(data)
+	}
+	u := c.base + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u, rd)
+	if err != nil {
+		return nil, fmt.Errorf("kube: %w", err)
+	}
+	token, err := c.bearer()
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := c.hc.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("kube: %w", err)
+	}
+	if resp.StatusCode >= 400 {
+		defer resp.Body.Close()
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+		var status struct {
+			Reason  string `json:"reason"`
+			Message string `json:"message"`
+		}
+		if json.Unmarshal(data, &status) != nil || status.Message == "" {
+			status.Message = strings.TrimSpace(string(data))
+		}
+		return nil, &Error{Status: resp.StatusCode, Reason: status.Reason, Message: status.Message}
+	}
+	return resp, nil
+}
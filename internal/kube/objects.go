@@ -0,0 +1,259 @@
+package kube
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+)
+
+// The objects below carry only the fields the sandbox sets or reads;
+// field names are the API's.
+
+// ObjectMeta is an object's name, labels and owners.
+type ObjectMeta struct {
+	Name            string            `json:"name,omitempty"`
+	Namespace       string            `json:"namespace,omitempty"`
+	UID             string            `json:"uid,omitempty"`
+	Labels          map[string]string `json:"labels,omitempty"`
+	OwnerReferences []OwnerReference  `json:"ownerReferences,omitempty"`
+}
+
+// OwnerReference makes an object garbage collected with its owner.
+type OwnerReference struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	Name       string `json:"name"`
+	UID        string `json:"uid"`
+}
+
+// Job runs a pod to completion.
+type Job struct {
+	APIVersion string     `json:"apiVersion"`
+	Kind       string     `json:"kind"`
+	Metadata   ObjectMeta `json:"metadata"`
+	Spec       JobSpec    `json:"spec"`
+}
+
+// JobSpec is how a Job runs. BackoffLimit 0 means a single attempt.
+type JobSpec struct {
+	BackoffLimit            int32           `json:"backoffLimit"`
+	ActiveDeadlineSeconds   int64           `json:"activeDeadlineSeconds,omitempty"`
+	TTLSecondsAfterFinished *int32          `json:"ttlSecondsAfterFinished,omitempty"`
+	Template                PodTemplateSpec `json:"template"`
+}
+
+// PodTemplateSpec is the pod a Job creates.
+type PodTemplateSpec struct {
+	Metadata ObjectMeta `json:"metadata"`
+	Spec     PodSpec    `json:"spec"`
+}
+
+// PodSpec is a pod's containers, volumes and isolation.
+type PodSpec struct {
+	RestartPolicy                string      `json:"restartPolicy"`
+	AutomountServiceAccountToken bool        `json:"automountServiceAccountToken"`
+	EnableServiceLinks           bool        `json:"enableServiceLinks"`
+	RuntimeClassName             string      `json:"runtimeClassName,omitempty"`
+	Containers                   []Container `json:"containers"`
+	Volumes                      []Volume    `json:"volumes,omitempty"`
+}
+
+// Container is one container of a pod.
+type Container struct {
+	Name            string           `json:"name"`
+	Image           string           `json:"image"`
+	Resources       Resources        `json:"resources"`
+	SecurityContext *SecurityContext `json:"securityContext,omitempty"`
+	VolumeMounts    []VolumeMount    `json:"volumeMounts,omitempty"`
+}
+
+// Resources are a container's limits, e.g. {"memory": "512Mi", "cpu":
+// "500m"}; with no requests, the limits are requested.
+type Resources struct {
+	Limits map[string]string `json:"limits,omitempty"`
+}
+
+// SecurityContext is a container's privilege settings.
+type SecurityContext struct {
+	AllowPrivilegeEscalation *bool `json:"allowPrivilegeEscalation,omitempty"`
+	ReadOnlyRootFilesystem   bool  `json:"readOnlyRootFilesystem,omitempty"`
+}
+
+// VolumeMount mounts a pod volume into a container.
+type VolumeMount struct {
+	Name      string `json:"name"`
+	MountPath string `json:"mountPath"`
+	ReadOnly  bool   `json:"readOnly,omitempty"`
+}
+
+// Volume is a Secret's files or a scratch directory.
+type Volume struct {
+	Name     string        `json:"name"`
+	Secret   *SecretVolume `json:"secret,omitempty"`
+	EmptyDir *EmptyDir     `json:"emptyDir,omitempty"`
+}
+
+// SecretVolume mounts a Secret's keys as files.
+type SecretVolume struct {
+	SecretName string `json:"secretName"`
+}
+
+// EmptyDir is scratch space for the pod's lifetime; Medium "Memory"
+// makes it a tmpfs.
+type EmptyDir struct {
+	Medium    string `json:"medium,omitempty"`
+	SizeLimit string `json:"sizeLimit,omitempty"`
+}
+
+// Secret holds files, base64-encoded on the wire.
+type Secret struct {
+	APIVersion string            `json:"apiVersion"`
+	Kind       string            `json:"kind"`
+	Metadata   ObjectMeta        `json:"metadata"`
+	Data       map[string][]byte `json:"data"`
+}
+
+// Pod is a pod as it runs.
+type Pod struct {
+	Metadata ObjectMeta `json:"metadata"`
+	Status   PodStatus  `json:"status"`
+}
+
+// PodStatus is where a pod is in its life.
+type PodStatus struct {
+	Phase             string            `json:"phase"` // Pending, Running, Succeeded, Failed
+	Message           string            `json:"message,omitempty"`
+	ContainerStatuses []ContainerStatus `json:"containerStatuses,omitempty"`
+}
+
+// ContainerStatus is how one container is doing.
+type ContainerStatus struct {
+	Name    string         `json:"name"`
+	ImageID string         `json:"imageID"`
+	State   ContainerState `json:"state"`
+}
+
+// ContainerState is set in one of its fields.
+type ContainerState struct {
+	Waiting *struct {
+		Reason  string `json:"reason"`
+		Message string `json:"message"`
+	} `json:"waiting,omitempty"`
+	Running *struct {
+		StartedAt string `json:"startedAt"`
+	} `json:"running,omitempty"`
+	Terminated *struct {
+		ExitCode int    `json:"exitCode"`
+		Reason   string `json:"reason"` // e.g. Completed, Error, OOMKilled
+		Message  string `json:"message"`
+	} `json:"terminated,omitempty"`
+}
+
+// Container returns the status of the named container, or nil.
+func (s PodStatus) Container(name string) *ContainerStatus {
+	for i := range s.ContainerStatuses {
+		if s.ContainerStatuses[i].Name == name {
+			return &s.ContainerStatuses[i]
+		}
+	}
+	return nil
+}
+
+// NetworkPolicy restricts the traffic of the pods it selects.
+type NetworkPolicy struct {
+	APIVersion string            `json:"apiVersion"`
+	Kind       string            `json:"kind"`
+	Metadata   ObjectMeta        `json:"metadata"`
+	Spec       NetworkPolicySpec `json:"spec"`
+}
+
+// NetworkPolicySpec selects pods and the traffic they may have; a policy
+// type with no rules denies all of it.
+type NetworkPolicySpec struct {
+	PodSelector LabelSelector `json:"podSelector"`
+	PolicyTypes []string      `json:"policyTypes"`
+}
+
+// LabelSelector selects objects by label.
+type LabelSelector struct {
+	MatchLabels map[string]string `json:"matchLabels"`
+}
+
+// Version asks the API server its version, which doubles as a health
+// check.
+func (c *Client) Version(ctx context.Context) (string, error) {
+	var v struct {
+		GitVersion string `json:"gitVersion"`
+	}
+	err := c.do(ctx, http.MethodGet, "/version", nil, nil, &v)
+	return v.GitVersion, err
+}
+
+// CreateJob creates job in namespace ns and returns it as created.
+func (c *Client) CreateJob(ctx context.Context, ns string, job *Job) (*Job, error) {
+	job.APIVersion, job.Kind = "batch/v1", "Job"
+	var created Job
+	if err := c.do(ctx, http.MethodPost, "/apis/batch/v1/namespaces/"+ns+"/jobs", nil, job, &created); err != nil {
+		return nil, err
+	}
+	return &created, nil
+}
+
+// DeleteJob deletes the named Job and, in the background, its pods and
+// the objects it owns.
+func (c *Client) DeleteJob(ctx context.Context, ns, name string) error {
+	return c.do(ctx, http.MethodDelete, "/apis/batch/v1/namespaces/"+ns+"/jobs/"+name, nil,
+		map[string]string{"kind": "DeleteOptions", "apiVersion": "v1", "propagationPolicy": "Background"}, nil)
+}
+
+// CreateSecret creates secret in namespace ns.
+func (c *Client) CreateSecret(ctx context.Context, ns string, secret *Secret) error {
+	secret.APIVersion, secret.Kind = "v1", "Secret"
+	return c.do(ctx, http.MethodPost, "/api/v1/namespaces/"+ns+"/secrets", nil, secret, nil)
+}
+
+// ListPods returns the pods in namespace ns matching the label selector,
+// e.g. "app=x".
+func (c *Client) ListPods(ctx context.Context, ns, selector string) ([]Pod, error) {
+	var list struct {
+		Items []Pod `json:"items"`
+	}
+	err := c.do(ctx, http.MethodGet, "/api/v1/namespaces/"+ns+"/pods", url.Values{"labelSelector": {selector}}, nil, &list)
+	return list.Items, err
+}
+
+// GetPod returns the named pod.
+func (c *Client) GetPod(ctx context.Context, ns, name string) (*Pod, error) {
+	var pod Pod
+	if err := c.do(ctx, http.MethodGet, "/api/v1/namespaces/"+ns+"/pods/"+name, nil, nil, &pod); err != nil {
+		return nil, err
+	}
+	return &pod, nil
+}
+
+// PodLogs follows the log of a pod's container, stdout and stderr
+// interleaved, until the container exits or ctx is done.
+func (c *Client) PodLogs(ctx context.Context, ns, pod, container string) (io.ReadCloser, error) {
+	resp, err := c.send(ctx, http.MethodGet, "/api/v1/namespaces/"+ns+"/pods/"+pod+"/log",
+		url.Values{"container": {container}, "follow": {"true"}}, nil)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// GetNetworkPolicy returns the named NetworkPolicy.
+func (c *Client) GetNetworkPolicy(ctx context.Context, ns, name string) (*NetworkPolicy, error) {
+	var np NetworkPolicy
+	if err := c.do(ctx, http.MethodGet, "/apis/networking.k8s.io/v1/namespaces/"+ns+"/networkpolicies/"+name, nil, nil, &np); err != nil {
+		return nil, err
+	}
+	return &np, nil
+}
+
+// CreateNetworkPolicy creates np in namespace ns.
+func (c *Client) CreateNetworkPolicy(ctx context.Context, ns string, np *NetworkPolicy) error {
+	np.APIVersion, np.Kind = "networking.k8s.io/v1", "NetworkPolicy"
+	return c.do(ctx, http.MethodPost, "/apis/networking.k8s.io/v1/namespaces/"+ns+"/networkpolicies", nil, np, nil)
+}
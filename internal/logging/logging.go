@@ -0,0 +1,129 @@
+// Package logging sets up structured (slog) logging. Each chat request
+// carries its fields — request ID, user, session, and risk level and path
+// once known — in its context, and every record logged with that context
+// gets them, down to the sandbox runner.
+package logging
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"sync"
+)
+
+// Setup installs the default slog logger: format "json" (the default) or
+// "text", at level "debug", "info" (the default), "warn" or "error".
+// Output from the log package goes through it too.
+func Setup(w io.Writer, format, level string) error {
+	var lv slog.Level
+	if level != "" {
+		if err := lv.UnmarshalText([]byte(level)); err != nil {
+			return fmt.Errorf("unknown log level %q", level)
+		}
+	}
+	opts := &slog.HandlerOptions{Level: lv}
+	var h slog.Handler
+	switch format {
+	case "", "json":
+		h = slog.NewJSONHandler(w, opts)
+	case "text":
+		h = slog.NewTextHandler(w, opts)
+	default:
+		return fmt.Errorf("unknown log format %q", format)
+	}
+	slog.SetDefault(slog.New(contextHandler{h}))
+	return nil
+}
+
+// Header carries the request ID, in from clients that set one and back
+// out on every response.
+const Header = "X-Request-ID"
+
+// validID is what a client-supplied request ID may look like.
+var validID = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,128}$`)
+
+// RequestID returns id if it is a usable request ID, or a new random one.
+func RequestID(id string) string {
+	if validID.MatchString(id) {
+		return id
+	}
+	var b [12]byte
+	_, _ = rand.Read(b[:])
+	return "req_" + hex.EncodeToString(b[:])
+}
+
+// Propagate sets the Header of an outgoing request to the request ID in
+// ctx, so downstream services' logs can be matched with ours.
+func Propagate(ctx context.Context, h http.Header) {
+	if id := RequestIDFrom(ctx); id != "" {
+		h.Set(Header, id)
+	}
+}
+
+// fields are the attributes attached to a request's records. They are
+// set as the request progresses, so they are shared and locked.
+type fields struct {
+	mu    sync.Mutex
+	attrs []slog.Attr
+}
+
+type fieldsKey struct{}
+
+// WithRequest returns ctx carrying a new field set holding requestID.
+func WithRequest(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, fieldsKey{}, &fields{attrs: []slog.Attr{slog.String("request_id", requestID)}})
+}
+
+// Set sets a field on the request in ctx, replacing any earlier value.
+// Empty values are not recorded.
+func Set(ctx context.Context, key, value string) {
+	f, _ := ctx.Value(fieldsKey{}).(*fields)
+	if f == nil || value == "" {
+		return
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for i, a := range f.attrs {
+		if a.Key == key {
+			f.attrs[i].Value = slog.StringValue(value)
+			return
+		}
+	}
+	f.attrs = append(f.attrs, slog.String(key, value))
+}
+
+// RequestIDFrom returns the request ID in ctx, or "".
+func RequestIDFrom(ctx context.Context) string {
+	f, _ := ctx.Value(fieldsKey{}).(*fields)
+	if f == nil {
+		return ""
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.attrs[0].Value.String()
+}
+
+// contextHandler adds the request fields in a record's context.
+type contextHandler struct{ slog.Handler }
+
+func (h contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	if f, _ := ctx.Value(fieldsKey{}).(*fields); f != nil {
+		f.mu.Lock()
+		r.AddAttrs(f.attrs...)
+		f.mu.Unlock()
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
+}
@@ -0,0 +1,184 @@
+// Package maintenance holds the switches operators flip from the admin
+// API during incident response and migrations:
+//
+//   - Maintenance rejects new chat traffic with a 503 and the operator's
+//     message, while the admin UI and APIs, audit search, health checks
+//     and metrics stay up.
+//   - ReadOnly refuses configuration changes: config and policy reloads,
+//     policy syncs and rollbacks, and tuning adjustments. Traffic is
+//     served as usual under the configuration in place.
+//
+// With a record store the switches are shared by every gateway using it.
+package maintenance
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/shivansh-source/nopass/internal/metrics"
+	"github.com/shivansh-source/nopass/internal/storage"
+)
+
+// DefaultMessage is what rejected chat requests are told when the
+// operator gave no message.
+const DefaultMessage = "NoPass is down for maintenance; please retry later."
+
+// retryAfter is the Retry-After rejected requests get, in seconds.
+const retryAfter = 60
+
+// record is where the state is kept in the record store.
+const (
+	collection = "maintenance"
+	recordID   = "state"
+)
+
+var rejected = metrics.NewCounterVec(
+	"nopass_maintenance_rejected_total",
+	"Requests refused by a maintenance switch, by mode (maintenance or read_only).",
+	"mode",
+)
+
+// State is the switches' position.
+type State struct {
+	Maintenance bool `json:"maintenance"`
+	// Message is shown to rejected chat clients; empty is DefaultMessage.
+	Message  string `json:"message,omitempty"`
+	ReadOnly bool   `json:"read_only"`
+	// ChangedAt and ChangedBy record the last change.
+	ChangedAt time.Time `json:"changed_at,omitzero"`
+	ChangedBy string    `json:"changed_by,omitempty"`
+}
+
+// Switch holds the current State. The zero value has both switches off
+// and is per gateway. It is safe for concurrent use.
+type Switch struct {
+	// Records, if set, shares the state between gateways: Set writes it
+	// and Run picks up other gateways' changes.
+	Records storage.RecordStore
+
+	state atomic.Pointer[State]
+}
+
+// Load returns the current state.
+func (s *Switch) Load() State {
+	if st := s.state.Load(); st != nil {
+		return *st
+	}
+	return State{}
+}
+
+// ReadOnly reports whether configuration changes are refused. A nil
+// Switch never refuses them.
+func (s *Switch) ReadOnly() bool {
+	return s != nil && s.Load().ReadOnly
+}
+
+// Set changes the state, in the record store too if there is one; the
+// change is refused if it can't be stored there, so gateways don't
+// disagree.
+func (s *Switch) Set(ctx context.Context, st State) error {
+	st.ChangedAt = time.Now().UTC()
+	if s.Records != nil {
+		data, err := json.Marshal(st)
+		if err != nil {
+			return err
+		}
+		if err := s.Records.PutRecord(ctx, collection, recordID, data); err != nil {
+			return err
+		}
+	}
+	s.state.Store(&st)
+	log.Printf("maintenance: maintenance=%t read_only=%t, set by %s", st.Maintenance, st.ReadOnly, st.ChangedBy)
+	return nil
+}
+
+// Refresh loads the state from the record store.
+func (s *Switch) Refresh(ctx context.Context) error {
+	if s.Records == nil {
+		return nil
+	}
+	data, err := s.Records.GetRecord(ctx, collection, recordID)
+	if errors.Is(err, storage.ErrNotFound) {
+		s.state.Store(&State{})
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var st State
+	if err := json.Unmarshal(data, &st); err != nil {
+		return err
+	}
+	if old := s.Load(); old.Maintenance != st.Maintenance || old.ReadOnly != st.ReadOnly {
+		log.Printf("maintenance: maintenance=%t read_only=%t, set by %s", st.Maintenance, st.ReadOnly, st.ChangedBy)
+	}
+	s.state.Store(&st)
+	return nil
+}
+
+// Run refreshes the state every interval until ctx is done. A refresh
+// that fails keeps the last state.
+func (s *Switch) Run(ctx context.Context, interval time.Duration) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			if err := s.Refresh(ctx); err != nil {
+				log.Printf("maintenance: refresh error: %v", err)
+			}
+		}
+	}
+}
+
+// Wrap rejects requests to next with a 503 while maintenance is on.
+func (s *Switch) Wrap(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if st := s.Load(); st.Maintenance {
+			msg := st.Message
+			if msg == "" {
+				msg = DefaultMessage
+			}
+			rejected.Inc("maintenance")
+			w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+			http.Error(w, msg, http.StatusServiceUnavailable)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// GuardWrites is Guard for requests that change something: reads (GET,
+// HEAD, OPTIONS) of next are still served in read-only mode.
+func (s *Switch) GuardWrites(next http.Handler) http.Handler {
+	guarded := s.Guard(next)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			next.ServeHTTP(w, r)
+		default:
+			guarded.ServeHTTP(w, r)
+		}
+	})
+}
+
+// Guard rejects requests to next, which change configuration, with a 503
+// while read-only mode is on.
+func (s *Switch) Guard(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.ReadOnly() {
+			rejected.Inc("read_only")
+			http.Error(w, "NoPass is in read-only mode; configuration changes are disabled", http.StatusServiceUnavailable)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
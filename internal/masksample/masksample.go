@@ -0,0 +1,468 @@
+// Package masksample keeps a small random share of user messages with
+// the spans masking replaced in them, so people can label each span right
+// or wrong and point out values masking missed. The labels give a
+// precision and recall estimate per kind of masked value (CARD, PHONE, a
+// policy rule, a PII detector's NAME...), so masking rules are tuned on
+// evidence rather than anecdotes.
+//
+// Samples hold personal data. Each is sealed with AES-GCM under its
+// tenant's vault key and expires after TTL; only the label counts, which
+// hold none, are stored in the clear.
+package masksample
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	mrand "math/rand/v2"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/shivansh-source/nopass/internal/metrics"
+	"github.com/shivansh-source/nopass/internal/types"
+	"github.com/shivansh-source/nopass/internal/vault"
+)
+
+// MaxText is the most of a message a sample keeps; spans past it are
+// left out.
+const MaxText = 4 << 10
+
+var (
+	// ErrNotFound means the sample doesn't exist, has expired or belongs
+	// to another tenant.
+	ErrNotFound = errors.New("mask sample not found")
+	// ErrBadLabel means a label doesn't fit the sample.
+	ErrBadLabel = errors.New("invalid mask sample label")
+)
+
+var samples = metrics.NewCounterVec(
+	"nopass_mask_samples_total",
+	"Messages sampled for masking review, by outcome (stored, labeled or error).",
+	"result",
+)
+
+// Store keeps samples as files under Dir.
+type Store struct {
+	Dir  string
+	Keys vault.KeyProvider
+	// Rate is the share of messages sampled, between 0 and 1.
+	Rate float64
+	// TTL is how long a sample is kept, labeled or not.
+	TTL time.Duration
+
+	mu sync.Mutex // serializes label writes
+}
+
+// New creates dir if needed.
+func New(dir string, keys vault.KeyProvider, rate float64, ttl time.Duration) (*Store, error) {
+	if rate <= 0 || rate > 1 {
+		return nil, fmt.Errorf("masksample: rate must be above 0 and at most 1, got %v", rate)
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("masksample: %w", err)
+	}
+	return &Store{Dir: dir, Keys: keys, Rate: rate, TTL: ttl}, nil
+}
+
+// Sampled decides whether to sample one message.
+func (s *Store) Sampled() bool {
+	return mrand.Float64() < s.Rate
+}
+
+// Summary is what a sample shows without being opened.
+type Summary struct {
+	ID        string    `json:"id"`
+	TenantID  string    `json:"tenant_id"`
+	CreatedAt time.Time `json:"created_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+	Spans     int       `json:"spans"`
+	Labeled   bool      `json:"labeled"`
+	LabeledBy string    `json:"labeled_by,omitempty"`
+	// Counts are the labels per kind, once labeled.
+	Counts map[string]Counts `json:"counts,omitempty"`
+}
+
+// Counts tallies one kind's labels: spans masked rightly and wrongly, and
+// values that should have been masked but weren't.
+type Counts struct {
+	Correct   int `json:"correct"`
+	Incorrect int `json:"incorrect"`
+	Missed    int `json:"missed"`
+}
+
+// Sample is an opened sample.
+type Sample struct {
+	Summary
+	// Text is the message (up to MaxText); span offsets are in its
+	// Unicode code points.
+	Text  string             `json:"text"`
+	Spans []types.MaskedSpan `json:"masked_spans"`
+	// Verdicts say, per span, whether it was rightly masked.
+	Verdicts []bool   `json:"verdicts,omitempty"`
+	Missed   []Missed `json:"missed,omitempty"`
+}
+
+// Missed is a value a labeler found unmasked, with what it should have
+// been masked as.
+type Missed struct {
+	Kind  string `json:"kind"`
+	Start int    `json:"start"`
+	End   int    `json:"end"`
+}
+
+// Label is a labeler's verdict on a whole sample.
+type Label struct {
+	LabeledBy string   `json:"labeled_by"`
+	Verdicts  []bool   `json:"verdicts"`
+	Missed    []Missed `json:"missed"`
+}
+
+// record is a sample's file. Sealed holds the JSON of content, as nonce
+// || ciphertext.
+type record struct {
+	Summary
+	Sealed []byte `json:"sealed"`
+}
+
+type content struct {
+	Text     string             `json:"text"`
+	Spans    []types.MaskedSpan `json:"spans"`
+	Verdicts []bool             `json:"verdicts,omitempty"`
+	Missed   []Missed           `json:"missed,omitempty"`
+}
+
+var validID = regexp.MustCompile(`^ms_[0-9a-f]{24}$`)
+
+// Put samples text, masked as spans say, for tenantID.
+func (s *Store) Put(ctx context.Context, tenantID, text string, spans []types.MaskedSpan) error {
+	if len(text) > MaxText {
+		cut := MaxText
+		for cut > 0 && !utf8.RuneStart(text[cut]) {
+			cut--
+		}
+		text = text[:cut]
+		n := utf8.RuneCountInString(text)
+		kept := spans[:0:0]
+		for _, sp := range spans {
+			if sp.End <= n {
+				kept = append(kept, sp)
+			}
+		}
+		spans = kept
+	}
+	var b [12]byte
+	_, _ = rand.Read(b[:])
+	now := time.Now().UTC()
+	rec := record{Summary: Summary{
+		ID:        "ms_" + hex.EncodeToString(b[:]),
+		TenantID:  tenantID,
+		CreatedAt: now,
+		ExpiresAt: now.Add(s.TTL),
+		Spans:     len(spans),
+	}}
+	if err := s.seal(ctx, &rec, content{Text: text, Spans: spans}); err != nil {
+		samples.Inc("error")
+		return err
+	}
+	if err := s.write(rec); err != nil {
+		samples.Inc("error")
+		return err
+	}
+	samples.Inc("stored")
+	return nil
+}
+
+// List returns the unexpired samples of tenantID ("" for every tenant),
+// newest first, unopened.
+func (s *Store) List(tenantID string) ([]Summary, error) {
+	recs, err := s.records(tenantID)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]Summary, len(recs))
+	for i, r := range recs {
+		out[i] = r.Summary
+	}
+	return out, nil
+}
+
+// Get opens sample id of tenantID.
+func (s *Store) Get(ctx context.Context, tenantID, id string) (Sample, error) {
+	rec, err := s.read(tenantID, id)
+	if err != nil {
+		return Sample{}, err
+	}
+	c, err := s.open(ctx, rec)
+	if err != nil {
+		return Sample{}, err
+	}
+	return Sample{Summary: rec.Summary, Text: c.Text, Spans: c.Spans, Verdicts: c.Verdicts, Missed: c.Missed}, nil
+}
+
+// Label records l on sample id of tenantID, replacing any earlier label.
+// It needs a verdict for every span.
+func (s *Store) Label(ctx context.Context, tenantID, id string, l Label) (Summary, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	rec, err := s.read(tenantID, id)
+	if err != nil {
+		return Summary{}, err
+	}
+	c, err := s.open(ctx, rec)
+	if err != nil {
+		return Summary{}, err
+	}
+	if l.LabeledBy == "" || len(l.Verdicts) != len(c.Spans) {
+		return Summary{}, fmt.Errorf("%w: labeled_by and one verdict per span (%d) are required", ErrBadLabel, len(c.Spans))
+	}
+	n := utf8.RuneCountInString(c.Text)
+	counts := make(map[string]Counts)
+	for i, sp := range c.Spans {
+		k := counts[sp.Kind]
+		if l.Verdicts[i] {
+			k.Correct++
+		} else {
+			k.Incorrect++
+		}
+		counts[sp.Kind] = k
+	}
+	for _, m := range l.Missed {
+		if m.Kind == "" || m.Start < 0 || m.End <= m.Start || m.End > n {
+			return Summary{}, fmt.Errorf("%w: missed values need a kind and a range within the text", ErrBadLabel)
+		}
+		k := counts[m.Kind]
+		k.Missed++
+		counts[m.Kind] = k
+	}
+	c.Verdicts, c.Missed = l.Verdicts, l.Missed
+	rec.Labeled, rec.LabeledBy, rec.Counts = true, l.LabeledBy, counts
+	if err := s.seal(ctx, &rec, c); err != nil {
+		return Summary{}, err
+	}
+	if err := s.write(rec); err != nil {
+		return Summary{}, err
+	}
+	samples.Inc("labeled")
+	return rec.Summary, nil
+}
+
+// Estimate is what the labels say about one kind of masked value. A rate
+// is missing until there are labels to estimate it from.
+type Estimate struct {
+	Kind string `json:"kind"`
+	Counts
+	// Precision is the share of masked values rightly masked; Recall the
+	// share of values that should be masked that were.
+	Precision *Rate `json:"precision,omitempty"`
+	Recall    *Rate `json:"recall,omitempty"`
+}
+
+// Rate is an estimated proportion with its 95% Wilson score interval.
+type Rate struct {
+	Value float64 `json:"value"`
+	Low   float64 `json:"low"`
+	High  float64 `json:"high"`
+}
+
+// Estimates sums the labels of tenantID's unexpired samples ("" for every
+// tenant) per kind.
+func (s *Store) Estimates(tenantID string) ([]Estimate, error) {
+	recs, err := s.records(tenantID)
+	if err != nil {
+		return nil, err
+	}
+	totals := make(map[string]Counts)
+	for _, r := range recs {
+		for kind, c := range r.Counts {
+			t := totals[kind]
+			t.Correct += c.Correct
+			t.Incorrect += c.Incorrect
+			t.Missed += c.Missed
+			totals[kind] = t
+		}
+	}
+	out := make([]Estimate, 0, len(totals))
+	for kind, c := range totals {
+		out = append(out, Estimate{
+			Kind:      kind,
+			Counts:    c,
+			Precision: wilson(c.Correct, c.Correct+c.Incorrect),
+			Recall:    wilson(c.Correct, c.Correct+c.Missed),
+		})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Kind < out[j].Kind })
+	return out, nil
+}
+
+// wilson estimates k successes of n with a 95% Wilson score interval,
+// which stays sensible for the small n and extreme rates of hand labels.
+func wilson(k, n int) *Rate {
+	if n == 0 {
+		return nil
+	}
+	const z = 1.96
+	p, nf := float64(k)/float64(n), float64(n)
+	d := 1 + z*z/nf
+	mid := (p + z*z/(2*nf)) / d
+	half := z * math.Sqrt(p*(1-p)/nf+z*z/(4*nf*nf)) / d
+	// At k == 0 or k == n one bound is the rate itself; set it exactly
+	// rather than leave rounding error.
+	r := &Rate{Value: p, Low: math.Max(0, mid-half), High: math.Min(1, mid+half)}
+	if k == 0 {
+		r.Low = 0
+	}
+	if k == n {
+		r.High = 1
+	}
+	return r
+}
+
+// Run removes expired samples every few minutes until ctx is done.
+func (s *Store) Run(ctx context.Context) {
+	ticker := time.NewTicker(5 * time.Minute)
+	defer ticker.Stop()
+	for {
+		s.prune()
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (s *Store) prune() {
+	paths, _ := filepath.Glob(filepath.Join(s.Dir, "ms_*.json"))
+	for _, p := range paths {
+		rec, err := readRecord(p)
+		if err == nil && time.Now().Before(rec.ExpiresAt) {
+			continue
+		}
+		if err := os.Remove(p); err != nil && !os.IsNotExist(err) {
+			log.Printf("remove expired mask sample %s: %v", filepath.Base(p), err)
+		}
+	}
+}
+
+func (s *Store) records(tenantID string) ([]record, error) {
+	paths, err := filepath.Glob(filepath.Join(s.Dir, "ms_*.json"))
+	if err != nil {
+		return nil, err
+	}
+	var recs []record
+	for _, p := range paths {
+		rec, err := readRecord(p)
+		if err != nil || time.Now().After(rec.ExpiresAt) || (tenantID != "" && rec.TenantID != tenantID) {
+			continue
+		}
+		recs = append(recs, rec)
+	}
+	sort.Slice(recs, func(i, j int) bool { return recs[i].CreatedAt.After(recs[j].CreatedAt) })
+	return recs, nil
+}
+
+func (s *Store) read(tenantID, id string) (record, error) {
+	if !validID.MatchString(id) {
+		return record{}, ErrNotFound
+	}
+	rec, err := readRecord(filepath.Join(s.Dir, id+".json"))
+	if err != nil || time.Now().After(rec.ExpiresAt) || rec.TenantID != tenantID {
+		return record{}, ErrNotFound
+	}
+	return rec, nil
+}
+
+func readRecord(path string) (record, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return record{}, err
+	}
+	var rec record
+	err = json.Unmarshal(b, &rec)
+	return rec, err
+}
+
+// write replaces the record's file atomically.
+func (s *Store) write(rec record) error {
+	b, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(s.Dir, rec.ID+".json")
+	if err := os.WriteFile(path+".tmp", b, 0o600); err != nil {
+		return fmt.Errorf("masksample: %w", err)
+	}
+	if err := os.Rename(path+".tmp", path); err != nil {
+		os.Remove(path + ".tmp")
+		return fmt.Errorf("masksample: %w", err)
+	}
+	return nil
+}
+
+func (s *Store) seal(ctx context.Context, rec *record, c content) error {
+	aead, err := s.aead(ctx, rec.TenantID)
+	if err != nil {
+		return err
+	}
+	plain, err := json.Marshal(c)
+	if err != nil {
+		return err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return fmt.Errorf("masksample: nonce: %w", err)
+	}
+	rec.Sealed = aead.Seal(nonce, nonce, plain, aad(rec))
+	return nil
+}
+
+func (s *Store) open(ctx context.Context, rec record) (content, error) {
+	aead, err := s.aead(ctx, rec.TenantID)
+	if err != nil {
+		return content{}, err
+	}
+	if len(rec.Sealed) < aead.NonceSize() {
+		return content{}, fmt.Errorf("masksample: corrupt sample %s", rec.ID)
+	}
+	nonce, ciphertext := rec.Sealed[:aead.NonceSize()], rec.Sealed[aead.NonceSize():]
+	plain, err := aead.Open(nil, nonce, ciphertext, aad(&rec))
+	if err != nil {
+		return content{}, fmt.Errorf("masksample: open sample %s: %w", rec.ID, err)
+	}
+	var c content
+	if err := json.Unmarshal(plain, &c); err != nil {
+		return content{}, fmt.Errorf("masksample: sample %s: %w", rec.ID, err)
+	}
+	return c, nil
+}
+
+func (s *Store) aead(ctx context.Context, tenantID string) (cipher.AEAD, error) {
+	key, err := s.Keys.Key(ctx, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("masksample: tenant %q key: %w", tenantID, err)
+	}
+	return cipher.NewGCM(block)
+}
+
+// aad binds the ciphertext to its sample and tenant, so files can't be
+// swapped between them.
+func aad(rec *record) []byte {
+	return []byte(strings.Join([]string{"masksample", rec.TenantID, rec.ID}, "\x00"))
+}
@@ -0,0 +1,91 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/shivansh-source/nopass/internal/orchestrator"
+	"github.com/shivansh-source/nopass/internal/storage"
+	"github.com/shivansh-source/nopass/internal/types"
+)
+
+// History keeps the turns of each conversation server-side, in the session
+// record of the request's tenant, so clients can send just the new message
+// under a SessionID. Callers store turns already masked. A session belongs
+// to the user who started it: any other user is refused with
+// storage.ErrNotOwner.
+type History struct {
+	Sessions storage.SessionStore
+	// MaxTurns bounds the turns kept per session (0 = unlimited); the
+	// oldest are dropped first. A memory summary already covering them is
+	// kept.
+	MaxTurns int
+}
+
+// Load returns the stored turns of a session, oldest first; none for a new
+// session.
+func (h *History) Load(ctx context.Context, sessionID string) ([]types.Turn, error) {
+	sess, err := h.Sessions.GetSession(ctx, orchestrator.TenantFrom(ctx), sessionID)
+	if errors.Is(err, storage.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !sess.OwnedBy(orchestrator.UserFrom(ctx)) {
+		return nil, storage.ErrNotOwner
+	}
+	return sess.Turns, nil
+}
+
+// Save replaces the stored turns of a session and returns how many were
+// kept. The memory block in the same record is preserved, its coverage
+// shifted by any turns dropped.
+func (h *History) Save(ctx context.Context, sessionID string, turns []types.Turn) (int, error) {
+	tenantID, userID := orchestrator.TenantFrom(ctx), orchestrator.UserFrom(ctx)
+	sess, err := h.Sessions.GetSession(ctx, tenantID, sessionID)
+	if errors.Is(err, storage.ErrNotFound) {
+		sess, err = &storage.Session{TenantID: tenantID, ID: sessionID}, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	if !sess.OwnedBy(userID) {
+		return 0, storage.ErrNotOwner
+	}
+	sess.UserID = userID
+	if h.MaxTurns > 0 && len(turns) > h.MaxTurns {
+		dropped := len(turns) - h.MaxTurns
+		turns = turns[dropped:]
+		sess.TurnsCovered = max(sess.TurnsCovered-dropped, 0)
+	}
+	sess.Turns = turns
+	sess.UpdatedAt = time.Now().UTC()
+	if err := h.Sessions.PutSession(ctx, sess); err != nil {
+		return 0, err
+	}
+	return len(turns), nil
+}
+
+// Reset forgets the turns and memory block of a session. Its risk ledger
+// is kept: starting over must not clear an escalation.
+func (h *History) Reset(ctx context.Context, sessionID string) error {
+	tenantID := orchestrator.TenantFrom(ctx)
+	sess, err := h.Sessions.GetSession(ctx, tenantID, sessionID)
+	if errors.Is(err, storage.ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if !sess.OwnedBy(orchestrator.UserFrom(ctx)) {
+		return storage.ErrNotOwner
+	}
+	if sess.Risk == nil {
+		return h.Sessions.DeleteSession(ctx, tenantID, sessionID)
+	}
+	sess.Turns, sess.Summary, sess.TurnsCovered = nil, "", 0
+	sess.UpdatedAt = time.Now().UTC()
+	return h.Sessions.PutSession(ctx, sess)
+}
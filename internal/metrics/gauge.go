@@ -0,0 +1,86 @@
+package metrics
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// GaugeVec is a set of values that go up and down, partitioned by label
+// values.
+type GaugeVec struct {
+	Name   string
+	Help   string
+	Labels []string
+
+	mu     sync.RWMutex
+	values map[string]*atomic.Uint64 // float64 bits
+}
+
+var gauges []*GaugeVec
+
+// NewGaugeVec creates and registers a gauge family.
+func NewGaugeVec(name, help string, labels ...string) *GaugeVec {
+	g := &GaugeVec{
+		Name:   name,
+		Help:   help,
+		Labels: labels,
+		values: make(map[string]*atomic.Uint64),
+	}
+	registryMu.Lock()
+	gauges = append(gauges, g)
+	registryMu.Unlock()
+	return g
+}
+
+// Set sets the gauge for the given label values to v.
+func (g *GaugeVec) Set(v float64, labelValues ...string) {
+	key := strings.Join(labelValues, "\x00")
+
+	g.mu.RLock()
+	bits, ok := g.values[key]
+	g.mu.RUnlock()
+	if !ok {
+		g.mu.Lock()
+		if bits, ok = g.values[key]; !ok {
+			bits = new(atomic.Uint64)
+			g.values[key] = bits
+		}
+		g.mu.Unlock()
+	}
+	bits.Store(math.Float64bits(v))
+}
+
+// GaugeSample is one labelled gauge value.
+type GaugeSample struct {
+	LabelValues []string
+	Value       float64
+}
+
+// Snapshot returns the current values sorted by label values.
+func (g *GaugeVec) Snapshot() []GaugeSample {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	out := make([]GaugeSample, 0, len(g.values))
+	for key, bits := range g.values {
+		var lv []string
+		if len(g.Labels) > 0 {
+			lv = strings.Split(key, "\x00")
+		}
+		out = append(out, GaugeSample{LabelValues: lv, Value: math.Float64frombits(bits.Load())})
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return strings.Join(out[i].LabelValues, "\x00") < strings.Join(out[j].LabelValues, "\x00")
+	})
+	return out
+}
+
+// Gauges returns every registered gauge family.
+func Gauges() []*GaugeVec {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	return append([]*GaugeVec(nil), gauges...)
+}
@@ -0,0 +1,109 @@
+package metrics
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultBuckets are latency bucket bounds in seconds, from 5ms to 60s.
+var DefaultBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60}
+
+// HistogramVec is a set of histograms partitioned by label values.
+type HistogramVec struct {
+	Name    string
+	Help    string
+	Labels  []string
+	Buckets []float64 // upper bounds, ascending; +Inf is implied
+
+	mu     sync.Mutex
+	values map[string]*histogram
+}
+
+type histogram struct {
+	counts []uint64 // per bucket, not cumulative; the last is +Inf
+	sum    float64
+}
+
+var histograms []*HistogramVec
+
+// NewHistogramVec creates and registers a histogram family. nil buckets
+// means DefaultBuckets.
+func NewHistogramVec(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	if buckets == nil {
+		buckets = DefaultBuckets
+	}
+	h := &HistogramVec{
+		Name:    name,
+		Help:    help,
+		Labels:  labels,
+		Buckets: buckets,
+		values:  make(map[string]*histogram),
+	}
+	registryMu.Lock()
+	histograms = append(histograms, h)
+	registryMu.Unlock()
+	return h
+}
+
+// Observe records v for the given label values.
+func (h *HistogramVec) Observe(v float64, labelValues ...string) {
+	key := strings.Join(labelValues, "\x00")
+	i := sort.SearchFloat64s(h.Buckets, v)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	hv, ok := h.values[key]
+	if !ok {
+		hv = &histogram{counts: make([]uint64, len(h.Buckets)+1)}
+		h.values[key] = hv
+	}
+	hv.counts[i]++
+	hv.sum += v
+}
+
+// ObserveSince records the seconds elapsed since start.
+func (h *HistogramVec) ObserveSince(start time.Time, labelValues ...string) {
+	h.Observe(time.Since(start).Seconds(), labelValues...)
+}
+
+// HistogramSample is one labelled histogram: cumulative counts per bucket
+// (the last is +Inf, i.e. the total count) and the sum of observations.
+type HistogramSample struct {
+	LabelValues []string
+	Counts      []uint64
+	Sum         float64
+}
+
+// Snapshot returns the current histograms sorted by label values.
+func (h *HistogramVec) Snapshot() []HistogramSample {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	out := make([]HistogramSample, 0, len(h.values))
+	for key, hv := range h.values {
+		var lv []string
+		if len(h.Labels) > 0 {
+			lv = strings.Split(key, "\x00")
+		}
+		s := HistogramSample{LabelValues: lv, Counts: make([]uint64, len(hv.counts)), Sum: hv.sum}
+		var total uint64
+		for i, c := range hv.counts {
+			total += c
+			s.Counts[i] = total
+		}
+		out = append(out, s)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return strings.Join(out[i].LabelValues, "\x00") < strings.Join(out[j].LabelValues, "\x00")
+	})
+	return out
+}
+
+// Histograms returns every registered histogram family.
+func Histograms() []*HistogramVec {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	return append([]*HistogramVec(nil), histograms...)
+}
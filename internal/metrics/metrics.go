@@ -0,0 +1,100 @@
+package metrics
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// CounterVec is a set of monotonically increasing counters partitioned by
+// label values.
+type CounterVec struct {
+	Name   string
+	Help   string
+	Labels []string
+
+	mu     sync.RWMutex
+	values map[string]*atomic.Uint64
+}
+
+var (
+	registryMu sync.Mutex
+	registry   []*CounterVec
+)
+
+// NewCounterVec creates and registers a counter family.
+func NewCounterVec(name, help string, labels ...string) *CounterVec {
+	c := &CounterVec{
+		Name:   name,
+		Help:   help,
+		Labels: labels,
+		values: make(map[string]*atomic.Uint64),
+	}
+	registryMu.Lock()
+	registry = append(registry, c)
+	registryMu.Unlock()
+	return c
+}
+
+// Inc increments the counter for the given label values.
+func (c *CounterVec) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+// Add increments the counter for the given label values by n.
+func (c *CounterVec) Add(n uint64, labelValues ...string) {
+	key := strings.Join(labelValues, "\x00")
+
+	c.mu.RLock()
+	v, ok := c.values[key]
+	c.mu.RUnlock()
+	if !ok {
+		c.mu.Lock()
+		if v, ok = c.values[key]; !ok {
+			v = new(atomic.Uint64)
+			c.values[key] = v
+		}
+		c.mu.Unlock()
+	}
+	v.Add(n)
+}
+
+// Sample is one labelled counter value.
+type Sample struct {
+	LabelValues []string
+	Value       uint64
+}
+
+// Snapshot returns the current values sorted by label values.
+func (c *CounterVec) Snapshot() []Sample {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	out := make([]Sample, 0, len(c.values))
+	for key, v := range c.values {
+		var lv []string
+		if len(c.Labels) > 0 {
+			lv = strings.Split(key, "\x00")
+		}
+		out = append(out, Sample{LabelValues: lv, Value: v.Load()})
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return strings.Join(out[i].LabelValues, "\x00") < strings.Join(out[j].LabelValues, "\x00")
+	})
+	return out
+}
+
+// Counters returns every registered counter family.
+func Counters() []*CounterVec {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	return append([]*CounterVec(nil), registry...)
+}
+
+// ChatDispositions counts how /v1/chat requests ended.
+var ChatDispositions = NewCounterVec(
+	"nopass_chat_requests_total",
+	"Chat requests by final disposition.",
+	"disposition",
+)
@@ -0,0 +1,81 @@
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// WriteText writes every registered metric in the Prometheus text
+// exposition format.
+func WriteText(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	for _, c := range Counters() {
+		fmt.Fprintf(bw, "# HELP %s %s\n# TYPE %s counter\n", c.Name, escapeHelp(c.Help), c.Name)
+		for _, s := range c.Snapshot() {
+			fmt.Fprintf(bw, "%s%s %d\n", c.Name, labelPairs(c.Labels, s.LabelValues, "", ""), s.Value)
+		}
+	}
+	for _, g := range Gauges() {
+		fmt.Fprintf(bw, "# HELP %s %s\n# TYPE %s gauge\n", g.Name, escapeHelp(g.Help), g.Name)
+		for _, s := range g.Snapshot() {
+			fmt.Fprintf(bw, "%s%s %s\n", g.Name, labelPairs(g.Labels, s.LabelValues, "", ""), strconv.FormatFloat(s.Value, 'g', -1, 64))
+		}
+	}
+	for _, h := range Histograms() {
+		fmt.Fprintf(bw, "# HELP %s %s\n# TYPE %s histogram\n", h.Name, escapeHelp(h.Help), h.Name)
+		for _, s := range h.Snapshot() {
+			for i, n := range s.Counts {
+				le := "+Inf"
+				if i < len(h.Buckets) {
+					le = strconv.FormatFloat(h.Buckets[i], 'g', -1, 64)
+				}
+				fmt.Fprintf(bw, "%s_bucket%s %d\n", h.Name, labelPairs(h.Labels, s.LabelValues, "le", le), n)
+			}
+			labels := labelPairs(h.Labels, s.LabelValues, "", "")
+			fmt.Fprintf(bw, "%s_sum%s %s\n", h.Name, labels, strconv.FormatFloat(s.Sum, 'g', -1, 64))
+			fmt.Fprintf(bw, "%s_count%s %d\n", h.Name, labels, s.Counts[len(s.Counts)-1])
+		}
+	}
+	return bw.Flush()
+}
+
+// Handler serves WriteText, for Prometheus to scrape.
+func Handler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	if err := WriteText(w); err != nil {
+		log.Printf("write metrics error: %v", err)
+	}
+}
+
+// labelPairs formats {name="value",...}, with an extra pair if extraName
+// is set.
+func labelPairs(names, values []string, extraName, extraValue string) string {
+	var pairs []string
+	for i, n := range names {
+		v := ""
+		if i < len(values) {
+			v = values[i]
+		}
+		pairs = append(pairs, n+`="`+escapeLabel(v)+`"`)
+	}
+	if extraName != "" {
+		pairs = append(pairs, extraName+`="`+escapeLabel(extraValue)+`"`)
+	}
+	if len(pairs) == 0 {
+		return ""
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+var (
+	labelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+)
+
+func escapeLabel(s string) string { return labelEscaper.Replace(s) }
+func escapeHelp(s string) string  { return helpEscaper.Replace(s) }
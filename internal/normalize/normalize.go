@@ -0,0 +1,253 @@
+// Package normalize undoes the tricks that hide an injection from pattern
+// matching before a message is scored: compatibility characters
+// (fullwidth and mathematical letters), lookalike letters from other
+// scripts, invisible characters, and payloads encoded as base64 or hex.
+package normalize
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// Options selects the passes Text applies.
+type Options struct {
+	// NFKC applies Unicode compatibility normalization, so ｉｇｎｏｒｅ
+	// and 𝐢𝐠𝐧𝐨𝐫𝐞 read as ignore.
+	NFKC bool
+	// Homoglyphs folds Cyrillic and Greek letters that look Latin into
+	// Latin, in words that mix scripts or in mostly Latin text.
+	Homoglyphs bool
+	// Invisible strips zero-width and bidi control characters and spells
+	// out Unicode tag characters as the ASCII they hide.
+	Invisible bool
+	// Decode replaces base64 and hex payloads that decode to readable
+	// text with that text. They are reported either way.
+	Decode bool
+}
+
+// Enabled reports whether any pass is on.
+func (o Options) Enabled() bool {
+	return o.NFKC || o.Homoglyphs || o.Invisible || o.Decode
+}
+
+// Flags Text reports, named like the risk engine's where they overlap.
+const (
+	FlagInvisible  = "invisible_unicode"
+	FlagBidi       = "bidi_override"
+	FlagTags       = "unicode_tag_smuggling"
+	FlagNFKC       = "nfkc_normalized"
+	FlagHomoglyphs = "homoglyphs_folded"
+	FlagBase64     = "encoded_base64"
+	FlagHex        = "encoded_hex"
+)
+
+// Text applies the passes in opts and returns the result and flags for
+// what it changed or found.
+func Text(text string, opts Options) (string, []string) {
+	if !opts.Enabled() || text == "" {
+		return text, nil
+	}
+	var flags []string
+	add := func(f string) {
+		for _, have := range flags {
+			if have == f {
+				return
+			}
+		}
+		flags = append(flags, f)
+	}
+	if opts.Invisible {
+		text = visible(text, add)
+	}
+	if opts.NFKC && !norm.NFKC.IsNormalString(text) {
+		text = norm.NFKC.String(text)
+		add(FlagNFKC)
+	}
+	if opts.Homoglyphs {
+		if folded := foldHomoglyphs(text); folded != text {
+			text = folded
+			add(FlagHomoglyphs)
+		}
+	}
+	text = payloads(text, opts.Decode, add)
+	return text, flags
+}
+
+// visible removes invisible and bidi control characters and turns tag
+// characters into the ASCII they encode.
+func visible(text string, add func(string)) string {
+	if strings.IndexFunc(text, func(r rune) bool { return invisible(r) || bidi(r) || tag(r) }) < 0 {
+		return text
+	}
+	return strings.Map(func(r rune) rune {
+		switch {
+		case invisible(r):
+			add(FlagInvisible)
+		case bidi(r):
+			add(FlagBidi)
+		case tag(r):
+			add(FlagTags)
+			if r >= 0xE0020 && r <= 0xE007E {
+				return r - 0xE0000
+			}
+		default:
+			return r
+		}
+		return -1
+	}, text)
+}
+
+func invisible(r rune) bool {
+	switch r {
+	case 0x200B, 0x200C, 0x200D, 0x2060, 0xFEFF, 0x00AD, 0x180E:
+		return true
+	}
+	return false
+}
+
+func bidi(r rune) bool {
+	return (r >= 0x202A && r <= 0x202E) || (r >= 0x2066 && r <= 0x2069)
+}
+
+func tag(r rune) bool {
+	return r >= 0xE0000 && r <= 0xE007F
+}
+
+// homoglyphs maps Cyrillic and Greek letters to the Latin letters they
+// are drawn like.
+var homoglyphs = map[rune]rune{
+	// Cyrillic
+	'а': 'a', 'в': 'b', 'е': 'e', 'к': 'k', 'м': 'm', 'н': 'h', 'о': 'o', 'р': 'p', 'с': 'c', 'т': 't',
+	'у': 'y', 'х': 'x', 'ѕ': 's', 'і': 'i', 'ј': 'j', 'ԁ': 'd', 'ԛ': 'q', 'ԝ': 'w', 'ү': 'y', 'һ': 'h',
+	'А': 'A', 'В': 'B', 'Е': 'E', 'К': 'K', 'М': 'M', 'Н': 'H', 'О': 'O', 'Р': 'P', 'С': 'C', 'Т': 'T',
+	'У': 'Y', 'Х': 'X', 'Ѕ': 'S', 'І': 'I', 'Ј': 'J', 'Ԁ': 'D', 'Ԛ': 'Q', 'Ԝ': 'W', 'Ү': 'Y', 'Һ': 'H',
+	// Greek
+	'α': 'a', 'ο': 'o', 'ρ': 'p', 'ι': 'i', 'κ': 'k', 'ν': 'v', 'υ': 'u', 'χ': 'x', 'γ': 'y',
+	'Α': 'A', 'Β': 'B', 'Ε': 'E', 'Ζ': 'Z', 'Η': 'H', 'Ι': 'I', 'Κ': 'K', 'Μ': 'M', 'Ν': 'N', 'Ο': 'O',
+	'Ρ': 'P', 'Τ': 'T', 'Υ': 'Y', 'Χ': 'X',
+}
+
+// foldHomoglyphs folds the lookalikes in each word that mixes Latin with
+// them. In text that is mostly Latin, words made only of lookalikes are
+// folded too, since a real Cyrillic or Greek word there is rare and a
+// disguised English one is the attack; text mostly in those scripts is
+// left alone.
+func foldHomoglyphs(text string) string {
+	latin, other := 0, 0
+	for _, r := range text {
+		switch {
+		case unicode.Is(unicode.Latin, r):
+			latin++
+		case unicode.IsLetter(r):
+			other++
+		}
+	}
+	if other == 0 {
+		return text
+	}
+	mostlyLatin := latin > other
+
+	var b strings.Builder
+	word := make([]rune, 0, 32)
+	flush := func() {
+		hasLatin, allLookalike := false, true
+		for _, r := range word {
+			if unicode.Is(unicode.Latin, r) {
+				hasLatin = true
+			} else if _, ok := homoglyphs[r]; !ok {
+				allLookalike = false
+			}
+		}
+		if allLookalike && (hasLatin || mostlyLatin) {
+			for i, r := range word {
+				if l, ok := homoglyphs[r]; ok {
+					word[i] = l
+				}
+			}
+		}
+		b.WriteString(string(word))
+		word = word[:0]
+	}
+	for _, r := range text {
+		if unicode.IsLetter(r) || unicode.IsMark(r) {
+			word = append(word, r)
+			continue
+		}
+		flush()
+		b.WriteRune(r)
+	}
+	flush()
+	return b.String()
+}
+
+var (
+	base64Run = regexp.MustCompile(`[A-Za-z0-9+/]{16,}={0,2}`)
+	hexRun    = regexp.MustCompile(`\b(?:[0-9a-fA-F]{2}){8,}\b`)
+	hexEscape = regexp.MustCompile(`(?:\\x[0-9a-fA-F]{2}){8,}`)
+)
+
+// maxPayloads bounds the payloads decoded in one text.
+const maxPayloads = 16
+
+// payloads reports base64 and hex runs that decode to readable text and,
+// when decode is set, replaces them with it.
+func payloads(text string, decode bool, add func(string)) string {
+	for _, enc := range []struct {
+		flag   string
+		re     *regexp.Regexp
+		decode func(string) ([]byte, error)
+	}{
+		{FlagHex, hexEscape, func(s string) ([]byte, error) { return hex.DecodeString(strings.ReplaceAll(s, `\x`, "")) }},
+		{FlagHex, hexRun, hex.DecodeString},
+		{FlagBase64, base64Run, decodeBase64},
+	} {
+		n := 0
+		text = enc.re.ReplaceAllStringFunc(text, func(m string) string {
+			if n >= maxPayloads {
+				return m
+			}
+			b, err := enc.decode(m)
+			if err != nil || !readable(b) {
+				return m
+			}
+			n++
+			add(enc.flag)
+			if !decode {
+				return m
+			}
+			return string(b)
+		})
+	}
+	return text
+}
+
+func decodeBase64(s string) ([]byte, error) {
+	if b, err := base64.StdEncoding.DecodeString(s); err == nil {
+		return b, nil
+	}
+	return base64.RawStdEncoding.DecodeString(strings.TrimRight(s, "="))
+}
+
+// readable reports whether b is mostly readable text, which random
+// identifiers, hashes and binary blobs aren't.
+func readable(b []byte) bool {
+	if len(b) < 8 || !utf8.Valid(b) {
+		return false
+	}
+	letters, ok := 0, 0
+	for _, r := range string(b) {
+		if unicode.IsLetter(r) || r == ' ' {
+			letters++
+		}
+		if unicode.IsPrint(r) || r == '\n' || r == '\t' {
+			ok++
+		}
+	}
+	return ok*10 >= len(b)*9 && letters*2 >= len(b)
+}
@@ -0,0 +1,35 @@
+package orchestrator
+
+import "context"
+
+// Artifacts asks the upcoming run for the files the image writes to
+// /app/output, and receives them. Only regular files at the top of the
+// directory are collected, at most MaxFiles of them and MaxBytes in all;
+// the rest are named in Dropped. Runners without an output mount (the
+// fleet, model providers) leave Files empty.
+type Artifacts struct {
+	MaxFiles int
+	MaxBytes int64
+
+	Files   []ArtifactFile
+	Dropped []string
+}
+
+// ArtifactFile is one file a run wrote.
+type ArtifactFile struct {
+	Name string
+	Data []byte
+}
+
+type artifactsKey struct{}
+
+// WithArtifacts asks the upcoming run to collect its output files into a.
+func WithArtifacts(ctx context.Context, a *Artifacts) context.Context {
+	return context.WithValue(ctx, artifactsKey{}, a)
+}
+
+// ArtifactsFrom returns the request attached with WithArtifacts, or nil.
+func ArtifactsFrom(ctx context.Context) *Artifacts {
+	a, _ := ctx.Value(artifactsKey{}).(*Artifacts)
+	return a
+}
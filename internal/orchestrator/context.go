@@ -0,0 +1,88 @@
+package orchestrator
+
+import (
+	"context"
+
+	"github.com/shivansh-source/nopass/internal/types"
+)
+
+type tenantKey struct{}
+
+type userKey struct{}
+
+type cacheKey struct{}
+
+type receiptKey struct{}
+
+type generationKey struct{}
+
+type echoKey struct{}
+
+// WithEcho requires the upcoming run's output to end with a footer
+// echoing e.
+func WithEcho(ctx context.Context, e *types.SandboxEcho) context.Context {
+	return context.WithValue(ctx, echoKey{}, e)
+}
+
+// EchoFrom returns the echo attached with WithEcho, or nil.
+func EchoFrom(ctx context.Context) *types.SandboxEcho {
+	e, _ := ctx.Value(echoKey{}).(*types.SandboxEcho)
+	return e
+}
+
+// WithGeneration attaches the sampling settings for the upcoming run.
+func WithGeneration(ctx context.Context, g *types.GenerationParams) context.Context {
+	return context.WithValue(ctx, generationKey{}, g)
+}
+
+// GenerationFrom returns the settings attached with WithGeneration, or nil.
+func GenerationFrom(ctx context.Context) *types.GenerationParams {
+	g, _ := ctx.Value(generationKey{}).(*types.GenerationParams)
+	return g
+}
+
+// WithReceipt asks the runner to fill in rc with the measured usage of the
+// upcoming run.
+func WithReceipt(ctx context.Context, rc *types.SandboxReceipt) context.Context {
+	return context.WithValue(ctx, receiptKey{}, rc)
+}
+
+// ReceiptFrom returns the receipt attached with WithReceipt, or nil.
+func ReceiptFrom(ctx context.Context) *types.SandboxReceipt {
+	rc, _ := ctx.Value(receiptKey{}).(*types.SandboxReceipt)
+	return rc
+}
+
+// WithPromptCacheKey marks the system prompt of the upcoming run as a
+// stable, cacheable prefix identified by key.
+func WithPromptCacheKey(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, cacheKey{}, key)
+}
+
+// PromptCacheKeyFrom returns the key attached with WithPromptCacheKey, or "".
+func PromptCacheKeyFrom(ctx context.Context) string {
+	k, _ := ctx.Value(cacheKey{}).(string)
+	return k
+}
+
+// WithTenant attaches the tenant a sandbox run belongs to.
+func WithTenant(ctx context.Context, tenantID string) context.Context {
+	return context.WithValue(ctx, tenantKey{}, tenantID)
+}
+
+// TenantFrom returns the tenant attached with WithTenant, or "".
+func TenantFrom(ctx context.Context) string {
+	id, _ := ctx.Value(tenantKey{}).(string)
+	return id
+}
+
+// WithUser attaches the user a request is made by.
+func WithUser(ctx context.Context, userID string) context.Context {
+	return context.WithValue(ctx, userKey{}, userID)
+}
+
+// UserFrom returns the user attached with WithUser, or "".
+func UserFrom(ctx context.Context) string {
+	id, _ := ctx.Value(userKey{}).(string)
+	return id
+}
@@ -0,0 +1,79 @@
+package orchestrator
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/shivansh-source/nopass/internal/types"
+)
+
+// echoMarker prefixes the footer line an OutputText image prints last on
+// stdout, echoing the request it answered. Frame images send an echo
+// frame instead (see python/llm_sandbox/run_llm.py).
+const echoMarker = "NOPASS_ECHO "
+
+// ErrEchoMismatch means a run's output did not echo the request it was
+// given: a stale or reused container answered a different request, or
+// the image doesn't implement the footer.
+var ErrEchoMismatch = errors.New("sandbox output does not echo this request")
+
+// splitEcho separates the echo footer, if the last line of out is one,
+// from the answer before it.
+func splitEcho(out string) (string, *types.SandboxEcho) {
+	body := strings.TrimRight(out, "\r\n")
+	start := strings.LastIndexByte(body, '\n') + 1
+	footer, ok := strings.CutPrefix(body[start:], echoMarker)
+	if !ok {
+		return out, nil
+	}
+	var e types.SandboxEcho
+	if json.Unmarshal([]byte(footer), &e) != nil {
+		return out, nil
+	}
+	return out[:start], &e
+}
+
+// checkEcho strips the echo footer from out and, if ctx expects one
+// (WithEcho), verifies it. With required unset a missing footer passes,
+// for output that was already checked and stripped by a remote runner.
+func checkEcho(ctx context.Context, out string, required bool) (string, error) {
+	answer, got := splitEcho(out)
+	if err := verifyEcho(ctx, got, required); err != nil {
+		return "", err
+	}
+	return answer, nil
+}
+
+// verifyEcho checks the echo a run returned, footer or frame, against the
+// one ctx expects.
+func verifyEcho(ctx context.Context, got *types.SandboxEcho, required bool) error {
+	want := EchoFrom(ctx)
+	switch {
+	case want == nil:
+		return nil
+	case got == nil && !required:
+		return nil
+	case got == nil:
+		return fmt.Errorf("%w: no echo", ErrEchoMismatch)
+	case *got != *want:
+		return fmt.Errorf("%w: got request %q policy %q, want request %q policy %q",
+			ErrEchoMismatch, got.RequestID, got.PolicyVersion, want.RequestID, want.PolicyVersion)
+	}
+	return nil
+}
+
+// holdEcho returns how much of text, up to end, may be streamed: a last
+// line that is or may become the echo footer is held back.
+func holdEcho(text []byte, end int) int {
+	body := bytes.TrimRight(text[:end], "\r\n")
+	start := bytes.LastIndexByte(body, '\n') + 1
+	line := body[start:]
+	if bytes.HasPrefix(line, []byte(echoMarker)) || bytes.HasPrefix([]byte(echoMarker), line) {
+		return start
+	}
+	return end
+}
@@ -0,0 +1,122 @@
+package orchestrator
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/shivansh-source/nopass/internal/scheduler"
+	"github.com/shivansh-source/nopass/internal/types"
+)
+
+// errRunnerBusy means the runner rejected the run because it was at capacity.
+var errRunnerBusy = errors.New("runner at capacity")
+
+// FleetRunner dispatches sandbox runs to remote runner hosts chosen by a
+// Scheduler instead of the local Docker daemon.
+type FleetRunner struct {
+	Scheduler   *scheduler.Scheduler
+	HTTPClient  *http.Client
+	MaxAttempts int
+}
+
+// NewFleetRunner creates a FleetRunner on top of sched.
+func NewFleetRunner(sched *scheduler.Scheduler) *FleetRunner {
+	return &FleetRunner{
+		Scheduler: sched,
+		HTTPClient: &http.Client{
+			Timeout: 20 * time.Second,
+		},
+		MaxAttempts: 3,
+	}
+}
+
+// RunInSandbox picks the least-loaded runner and executes the run there. If
+// the runner is busy or unreachable, the next best runner is tried.
+func (f *FleetRunner) RunInSandbox(ctx context.Context, systemPrompt, userContent string) (string, error) {
+	body, err := json.Marshal(types.RunRequest{
+		SchemaVersion: types.SchemaVersion,
+		SystemPrompt:  systemPrompt,
+		UserContent:   userContent,
+		TenantID:      TenantFrom(ctx),
+		CacheKey:      PromptCacheKeyFrom(ctx),
+		Generation:    GenerationFrom(ctx),
+		Echo:          EchoFrom(ctx),
+	})
+	if err != nil {
+		return "", fmt.Errorf("marshal run request: %w", err)
+	}
+
+	var tried []string
+	var lastErr error
+	for attempt := 0; attempt < f.MaxAttempts; attempt++ {
+		lease, err := f.Scheduler.Acquire(TenantFrom(ctx), tried...)
+		if err != nil {
+			if lastErr != nil {
+				return "", fmt.Errorf("%w (last runner error: %v)", err, lastErr)
+			}
+			return "", err
+		}
+		tried = append(tried, lease.RunnerID)
+
+		answer, receipt, err := f.runOn(ctx, lease.Addr, lease.Token, body)
+		lease.Release()
+		if err == nil {
+			if rc := ReceiptFrom(ctx); rc != nil && receipt != nil {
+				id := rc.ID
+				*rc = *receipt
+				rc.ID = id
+				rc.RunnerID = lease.RunnerID
+			}
+			return answer, nil
+		}
+		if ctx.Err() != nil {
+			return "", ctx.Err()
+		}
+		lastErr = fmt.Errorf("runner %s: %w", lease.RunnerID, err)
+	}
+	return "", lastErr
+}
+
+func (f *FleetRunner) runOn(ctx context.Context, addr, token string, body []byte) (string, *types.SandboxReceipt, error) {
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, addr+"/v1/run", bytes.NewReader(body))
+	if err != nil {
+		return "", nil, fmt.Errorf("create run request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("Authorization", "Bearer "+token)
+	httpReq.Header.Set(types.SchemaHeader, strconv.Itoa(types.SchemaVersion))
+
+	resp, err := f.HTTPClient.Do(httpReq)
+	if err != nil {
+		return "", nil, fmt.Errorf("call runner: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusTooManyRequests {
+		return "", nil, errRunnerBusy
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", nil, fmt.Errorf("runner returned status %d", resp.StatusCode)
+	}
+
+	var out types.RunResponse
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return "", nil, fmt.Errorf("decode run response: %w", err)
+	}
+	if err := types.CheckSchemaVersion(out.SchemaVersion); err != nil {
+		return "", nil, fmt.Errorf("run response: %w", err)
+	}
+	// Runners verify and strip the footer themselves; one that predates it
+	// passes it through.
+	answer, err := checkEcho(ctx, out.Answer, false)
+	if err != nil {
+		return "", nil, err
+	}
+	return answer, out.Receipt, nil
+}
@@ -0,0 +1,218 @@
+package orchestrator
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/shivansh-source/nopass/internal/types"
+)
+
+// Output protocols for SandboxConfig.Output.
+const (
+	// OutputFrames: every stdout line is a JSON frame (see frame). Only
+	// answer frames reach the answer; anything else the image prints is
+	// logged.
+	OutputFrames = "frames"
+	// OutputText: stdout is the answer, ending in the echo footer. For
+	// images that predate frames, e.g. the moderation image.
+	OutputText = "text"
+)
+
+// frame is one line of sandbox output under OutputFrames:
+//
+//	{"type":"answer","text":"Hello"}                   a piece of the answer, in order
+//	{"type":"model","model":"llama-3.1-8b"}            the model that answered
+//	{"type":"usage","input_tokens":812,"output_tokens":40}  totals so far; the last counts
+//	{"type":"warning","message":"context truncated"}   recorded on the receipt
+//	{"type":"log","message":"loaded weights"}          logged only
+//	{"type":"echo","request_id":"…","policy_version":"v1"}  see WithEcho
+//	{"type":"result","status":"ok","input_tokens":812,"output_tokens":40}  ends the output
+//
+// The result frame is the run's envelope: its status is "ok" or "error"
+// (with the reason in "error"), its model and token usage are final, and
+// frames after it are ignored. Images given their input on stdin
+// (InputStdin) must end with one; for others it is optional.
+//
+// Unknown types are ignored, so images can add frames before the gateway
+// understands them.
+type frame struct {
+	Type          string `json:"type"`
+	Text          string `json:"text,omitempty"`
+	Model         string `json:"model,omitempty"`
+	InputTokens   int    `json:"input_tokens,omitempty"`
+	OutputTokens  int    `json:"output_tokens,omitempty"`
+	Message       string `json:"message,omitempty"`
+	RequestID     string `json:"request_id,omitempty"`
+	PolicyVersion string `json:"policy_version,omitempty"`
+	Status        string `json:"status,omitempty"`
+	Error         string `json:"error,omitempty"`
+}
+
+// Result statuses.
+const (
+	resultOK    = "ok"
+	resultError = "error"
+)
+
+// ErrNoResult means a run that had to end its output with a result frame
+// didn't: the image crashed or was cut off part way.
+var ErrNoResult = errors.New("sandbox output ended without a result frame")
+
+const (
+	// maxFrameBytes bounds one line of output, so an image that never
+	// writes a newline can't grow the buffer without limit.
+	maxFrameBytes = 1 << 20
+	// maxWarnings bounds the warnings kept for the receipt.
+	maxWarnings = 20
+)
+
+// frameWriter is the sandbox's stdout under OutputFrames. It keeps the
+// answer and metadata the frames carry and passes answer text to onChunk,
+// if set, as each frame arrives. When onChunk fails, or a line is too
+// long, the run is cancelled and the error kept for the caller.
+type frameWriter struct {
+	ctx     context.Context
+	onChunk func(string) error
+	cancel  context.CancelFunc
+
+	line   []byte // the incomplete last line
+	n      int
+	answer strings.Builder
+
+	model        string
+	inputTokens  int
+	outputTokens int
+	warnings     []string
+	echo         *types.SandboxEcho
+	result       *frame
+	err          error
+}
+
+func (f *frameWriter) Write(p []byte) (int, error) {
+	if f.err != nil {
+		return 0, f.err
+	}
+	f.n += len(p)
+	f.line = append(f.line, p...)
+	for {
+		i := bytes.IndexByte(f.line, '\n')
+		if i < 0 {
+			break
+		}
+		f.handle(f.line[:i])
+		f.line = f.line[i+1:]
+		if f.err != nil {
+			return 0, f.err
+		}
+	}
+	if len(f.line) > maxFrameBytes {
+		f.fail(fmt.Errorf("sandbox output line exceeds %d bytes", maxFrameBytes))
+		return 0, f.err
+	}
+	// Keep the buffer from holding on to every line already handled.
+	f.line = append([]byte(nil), f.line...)
+	return len(p), nil
+}
+
+// close handles a last line without a newline.
+func (f *frameWriter) close() error {
+	if f.err == nil && len(bytes.TrimSpace(f.line)) > 0 {
+		f.handle(f.line)
+	}
+	f.line = nil
+	return f.err
+}
+
+func (f *frameWriter) handle(line []byte) {
+	line = bytes.TrimSpace(line)
+	if len(line) == 0 {
+		return
+	}
+	var fr frame
+	if line[0] != '{' || json.Unmarshal(line, &fr) != nil || fr.Type == "" {
+		slog.DebugContext(f.ctx, "sandbox output outside a frame", "line", truncateLog(line))
+		return
+	}
+	if f.result != nil {
+		slog.DebugContext(f.ctx, "sandbox frame after the result", "type", fr.Type)
+		return
+	}
+	switch fr.Type {
+	case "answer":
+		if fr.Text == "" {
+			return
+		}
+		f.answer.WriteString(fr.Text)
+		if f.onChunk != nil {
+			if err := f.onChunk(fr.Text); err != nil {
+				f.fail(err)
+			}
+		}
+	case "model":
+		f.model = fr.Model
+	case "usage":
+		f.inputTokens, f.outputTokens = fr.InputTokens, fr.OutputTokens
+	case "warning":
+		slog.WarnContext(f.ctx, "sandbox warning", "message", fr.Message)
+		if len(f.warnings) < maxWarnings {
+			f.warnings = append(f.warnings, fr.Message)
+		}
+	case "log":
+		slog.DebugContext(f.ctx, "sandbox log", "message", fr.Message)
+	case "echo":
+		f.echo = &types.SandboxEcho{RequestID: fr.RequestID, PolicyVersion: fr.PolicyVersion}
+	case "result":
+		if fr.Model != "" {
+			f.model = fr.Model
+		}
+		if fr.InputTokens != 0 || fr.OutputTokens != 0 {
+			f.inputTokens, f.outputTokens = fr.InputTokens, fr.OutputTokens
+		}
+		f.result = &fr
+	}
+}
+
+// resultErr checks the result frame: an error result fails the run, as
+// does a missing one if required.
+func (f *frameWriter) resultErr(required bool) error {
+	switch {
+	case f.result == nil && required:
+		return ErrNoResult
+	case f.result == nil || f.result.Status == resultOK:
+		return nil
+	case f.result.Status == resultError && f.result.Error != "":
+		return fmt.Errorf("sandbox reported an error: %s", f.result.Error)
+	}
+	return fmt.Errorf("sandbox result status %q", f.result.Status)
+}
+
+func (f *frameWriter) fail(err error) {
+	f.err = err
+	f.cancel()
+}
+
+// fill records the frames' metadata on rc.
+func (f *frameWriter) fill(rc *types.SandboxReceipt) {
+	rc.Model = f.model
+	rc.InputTokens, rc.OutputTokens = f.inputTokens, f.outputTokens
+	rc.Warnings = f.warnings
+}
+
+// String returns the answer.
+func (f *frameWriter) String() string { return f.answer.String() }
+
+// Len returns how many bytes the sandbox wrote.
+func (f *frameWriter) Len() int { return f.n }
+
+func truncateLog(line []byte) string {
+	const max = 200
+	if len(line) > max {
+		return strings.ToValidUTF8(string(line[:max]), "") + "…"
+	}
+	return string(line)
+}
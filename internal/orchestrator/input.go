@@ -0,0 +1,111 @@
+package orchestrator
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+// Input modes for SandboxConfig.InputMode.
+const (
+	// InputBind bind-mounts the run's input directory into the container.
+	InputBind = "bind"
+	// InputVolume copies the input directory into a named volume created
+	// for the run. It is slower, but works where bind
+	// mounts from temp dirs don't: remote Docker daemons, Docker Desktop
+	// without file sharing for the temp dir, rootless setups.
+	InputVolume = "volume"
+	// InputStdin writes the request to the container's stdin as a JSON
+	// envelope (see sandboxRequest) instead of files: no temp dir, no
+	// input mount. The image must read it, and answer in OutputFrames
+	// ending with a result frame.
+	InputStdin = "stdin"
+)
+
+// stdinEnv tells an image run with InputStdin to read its request from
+// stdin rather than /app/input.
+const stdinEnv = "NOPASS_INPUT=stdin"
+
+// inputFiles returns the files a sandbox image reads from /app/input:
+// system.txt and user.txt, protocol.json telling images that speak both
+// output protocols which one is expected ("auto" leaves it to them) and,
+// if ctx carries them, cache.json and params.json.
+func inputFiles(ctx context.Context, systemPrompt, userContent, protocol string) (map[string][]byte, error) {
+	files := map[string][]byte{
+		"system.txt":    []byte(systemPrompt),
+		"user.txt":      []byte(userContent),
+		"protocol.json": []byte(fmt.Sprintf(`{"output":%q}`, protocol)),
+	}
+	// cache.json tells the model backend that system.txt is a stable prefix
+	// it may cache (vLLM prefix caching, Anthropic cache_control, ...).
+	if key := PromptCacheKeyFrom(ctx); key != "" {
+		files["cache.json"], _ = json.Marshal(map[string]any{"system_prompt_cacheable": true, "cache_key": key})
+	}
+	// params.json carries the client's sampling settings, if any.
+	if g := GenerationFrom(ctx); g != nil {
+		params, err := json.Marshal(g)
+		if err != nil {
+			return nil, fmt.Errorf("marshal generation params: %w", err)
+		}
+		files["params.json"] = params
+	}
+	return files, nil
+}
+
+// exitStatus is how a sandbox process ended.
+type exitStatus struct {
+	code      int // -1 if unknown: it never ran, or was cut off
+	oomKilled bool
+}
+
+func (s exitStatus) err() error {
+	switch {
+	case s.oomKilled:
+		return fmt.Errorf("exit status %d: out of memory", s.code)
+	case s.code != 0:
+		return fmt.Errorf("exit status %d", s.code)
+	}
+	return nil
+}
+
+// requestVersion is the version of the stdin request envelope.
+const requestVersion = 1
+
+// sandboxRequest is the envelope written to a sandbox's stdin under
+// InputStdin, as a single JSON line: what inputFiles would write, in one
+// message.
+//
+//	{"type":"request","version":1,"system_prompt":"…","user_content":"…","output":"frames",
+//	 "cache":{"system_prompt_cacheable":true,"cache_key":"…"},"params":{"temperature":0.2}}
+//
+// The image answers with output frames ending in a result frame.
+type sandboxRequest struct {
+	Type         string          `json:"type"`
+	Version      int             `json:"version"`
+	SystemPrompt string          `json:"system_prompt"`
+	UserContent  string          `json:"user_content"`
+	Output       string          `json:"output"`
+	Cache        json.RawMessage `json:"cache,omitempty"`
+	Params       json.RawMessage `json:"params,omitempty"`
+}
+
+// inputRequest returns the stdin request envelope, newline included.
+func inputRequest(ctx context.Context, systemPrompt, userContent, protocol string) ([]byte, error) {
+	files, err := inputFiles(ctx, systemPrompt, userContent, protocol)
+	if err != nil {
+		return nil, err
+	}
+	data, err := json.Marshal(sandboxRequest{
+		Type:         "request",
+		Version:      requestVersion,
+		SystemPrompt: systemPrompt,
+		UserContent:  userContent,
+		Output:       protocol,
+		Cache:        files["cache.json"],
+		Params:       files["params.json"],
+	})
+	if err != nil {
+		return nil, fmt.Errorf("marshal sandbox request: %w", err)
+	}
+	return append(data, '\n'), nil
+}
@@ -0,0 +1,373 @@
+package orchestrator
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"github.com/shivansh-source/nopass/internal/kube"
+	"github.com/shivansh-source/nopass/internal/metrics"
+	"github.com/shivansh-source/nopass/internal/tracing"
+)
+
+// Labels of the sandbox Jobs and pods.
+const (
+	// KubeAppLabel marks every sandbox pod; the deny-all NetworkPolicy
+	// selects it.
+	KubeAppLabel = "app.kubernetes.io/name"
+	kubeAppName  = "nopass-sandbox"
+	// kubeRunLabel names the run a Job, its pod and its Secret belong to.
+	kubeRunLabel = "nopass.io/run"
+)
+
+// KubeNetworkPolicy is the name of the NetworkPolicy denying sandbox pods
+// all traffic (see KubeRunner.EnsureNetworkPolicy).
+const KubeNetworkPolicy = "nopass-sandbox-deny-all"
+
+// kubePoll is how often a sandbox pod's status is checked.
+const kubePoll = 250 * time.Millisecond
+
+// kubeContainer is the sandbox container's name in its pod.
+const kubeContainer = "sandbox"
+
+var kubeFailures = metrics.NewCounterVec(
+	"nopass_kube_sandbox_failures_total",
+	"Kubernetes sandbox runs that failed, by reason (timeout, oom_killed, image, error or echo_mismatch).",
+	"reason",
+)
+
+// KubeConfig configures KubeRunner.
+type KubeConfig struct {
+	// Namespace is where the Jobs run; empty is the gateway's own.
+	Namespace string
+	ImageName string
+	Timeout   time.Duration
+	// Limits bound each sandbox pod. Kubernetes has no per-pod process
+	// limit, so Pids is left to the kubelet's podPidsLimit.
+	Limits Limits
+	// RuntimeClass is the RuntimeClass sandbox pods run under, e.g. one
+	// for gVisor or Kata Containers; empty uses the cluster's default.
+	RuntimeClass string
+}
+
+// KubeRunner runs each sandboxed LLM call as a Kubernetes Job, for
+// clusters where no Docker socket is available. The prompt files reach
+// the pod as a Secret mounted at /app/input, owned by the Job so both go
+// together; the pod has no service account token, and the NetworkPolicy
+// EnsureNetworkPolicy creates denies it all traffic.
+//
+// Pod logs interleave stdout and stderr, so images must speak
+// OutputFrames: anything else they print is taken for logs. Runs neither
+// collect artifacts nor report CPU time and peak memory.
+type KubeRunner struct {
+	cfg    KubeConfig
+	kube   *kube.Client
+	images *ImagePolicy
+}
+
+// NewKubeRunner returns a KubeRunner using the client c.
+func NewKubeRunner(cfg KubeConfig, c *kube.Client) *KubeRunner {
+	if cfg.Namespace == "" {
+		cfg.Namespace = c.Namespace()
+	}
+	return &KubeRunner{cfg: cfg, kube: c}
+}
+
+// SetTenantImages enables per-tenant image selection, as for LLMRunner.
+func (k *KubeRunner) SetTenantImages(p *ImagePolicy) error {
+	if p.Shared == "" {
+		p.Shared = k.cfg.ImageName
+	}
+	if err := p.Validate(); err != nil {
+		return err
+	}
+	k.images = p
+	return nil
+}
+
+// EnsureNetworkPolicy creates the NetworkPolicy denying sandbox pods all
+// ingress and egress, unless it exists. It only takes effect with a
+// network plugin that enforces NetworkPolicies.
+func (k *KubeRunner) EnsureNetworkPolicy(ctx context.Context) error {
+	_, err := k.kube.GetNetworkPolicy(ctx, k.cfg.Namespace, KubeNetworkPolicy)
+	if !kube.IsNotFound(err) {
+		return err
+	}
+	err = k.kube.CreateNetworkPolicy(ctx, k.cfg.Namespace, &kube.NetworkPolicy{
+		Metadata: kube.ObjectMeta{Name: KubeNetworkPolicy, Labels: map[string]string{KubeAppLabel: kubeAppName}},
+		Spec: kube.NetworkPolicySpec{
+			PodSelector: kube.LabelSelector{MatchLabels: map[string]string{KubeAppLabel: kubeAppName}},
+			PolicyTypes: []string{"Ingress", "Egress"},
+		},
+	})
+	if kube.IsAlreadyExists(err) {
+		// Another replica got there first.
+		return nil
+	}
+	return err
+}
+
+// Ping checks that the API server answers.
+func (k *KubeRunner) Ping(ctx context.Context) error {
+	if _, err := k.kube.Version(ctx); err != nil {
+		return fmt.Errorf("kubernetes api: %w", err)
+	}
+	return nil
+}
+
+// RunInSandbox runs the call as a Job and returns the answer frames in
+// its pod's log. A receipt on ctx (WithReceipt) is filled in as by
+// LLMRunner, with the pod as the container.
+func (k *KubeRunner) RunInSandbox(ctx context.Context, systemPrompt, userContent string) (string, error) {
+	return k.RunInSandboxStream(ctx, systemPrompt, userContent, nil)
+}
+
+// RunInSandboxStream is RunInSandbox, passing answer frames to onChunk as
+// the pod writes them.
+func (k *KubeRunner) RunInSandboxStream(ctx context.Context, systemPrompt, userContent string, onChunk func(string) error) (_ string, err error) {
+	ctx, span := tracing.Start(ctx, "sandbox.run", tracing.Internal)
+	defer func() { span.End(err) }()
+
+	runCtx, cancel := context.WithTimeout(ctx, k.cfg.Timeout)
+	defer cancel()
+	image := k.imageFor(ctx)
+	span.SetAttr("container.image.name", image)
+	files, err := inputFiles(ctx, systemPrompt, userContent, OutputFrames)
+	if err != nil {
+		return "", err
+	}
+
+	name := "nopass-sandbox-" + randomSuffix()
+	job, err := k.kube.CreateJob(runCtx, k.cfg.Namespace, k.job(name, image))
+	if err != nil {
+		return "", fmt.Errorf("create sandbox job: %w", err)
+	}
+	// Deleted even if the run was cut off, which stops the pod.
+	defer func() {
+		dctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 10*time.Second)
+		defer cancel()
+		if err := k.kube.DeleteJob(dctx, k.cfg.Namespace, name); err != nil && !kube.IsNotFound(err) {
+			slog.WarnContext(ctx, "delete sandbox job error", "job", name, "err", err)
+		}
+	}()
+	err = k.kube.CreateSecret(runCtx, k.cfg.Namespace, &kube.Secret{
+		Metadata: kube.ObjectMeta{
+			Name:   name,
+			Labels: map[string]string{KubeAppLabel: kubeAppName, kubeRunLabel: name},
+			OwnerReferences: []kube.OwnerReference{
+				{APIVersion: "batch/v1", Kind: "Job", Name: name, UID: job.Metadata.UID},
+			},
+		},
+		Data: files,
+	})
+	if err != nil {
+		return "", fmt.Errorf("create sandbox input secret: %w", err)
+	}
+
+	stdout := newRunOutput(ctx, OutputFrames, onChunk, cancel)
+	start := time.Now()
+	pod, status, err := k.run(runCtx, name, stdout)
+	if cerr := stdout.close(); err == nil {
+		err = cerr
+	}
+	span.SetAttr("process.exit.code", status.code)
+	slog.DebugContext(ctx, "sandbox job finished", "job", name, "image", image, "exit_code", status.code,
+		"oom_killed", status.oomKilled, "duration_ms", time.Since(start).Milliseconds(), "output_bytes", stdout.Len())
+	if rc := ReceiptFrom(ctx); rc != nil {
+		rc.TenantID = TenantFrom(ctx)
+		rc.Image = image
+		if pod != nil {
+			rc.ContainerID = pod.Metadata.Name
+			if cs := pod.Status.Container(kubeContainer); cs != nil {
+				rc.ImageDigest = digestOf(cs.ImageID)
+			}
+		}
+		rc.StartedAt = start.UTC()
+		rc.WallTimeMs = time.Since(start).Milliseconds()
+		rc.ExitCode = status.code
+		rc.OOMKilled = status.oomKilled
+		stdout.fill(rc)
+	}
+	if err := stdout.err(); err != nil {
+		return "", err
+	}
+	if err != nil {
+		switch {
+		case runCtx.Err() == context.DeadlineExceeded:
+			kubeFailures.Inc("timeout")
+			return "", fmt.Errorf("sandbox job timed out: %w", runCtx.Err())
+		case status.oomKilled:
+			kubeFailures.Inc("oom_killed")
+		case errors.Is(err, errImage):
+			kubeFailures.Inc("image")
+		case ctx.Err() == nil:
+			kubeFailures.Inc("error")
+		}
+		return "", fmt.Errorf("sandbox job error: %w", err)
+	}
+	answer, err := stdout.answer(ctx)
+	if errors.Is(err, ErrEchoMismatch) {
+		kubeFailures.Inc("echo_mismatch")
+	}
+	return answer, err
+}
+
+// errImage is a sandbox pod whose image can't be pulled.
+var errImage = errors.New("sandbox image unavailable")
+
+// run waits for the Job's pod to start, copies its log to out until the
+// container exits and returns how it ended.
+func (k *KubeRunner) run(ctx context.Context, name string, out io.Writer) (*kube.Pod, exitStatus, error) {
+	status := exitStatus{code: -1}
+	pod, err := k.waitPod(ctx, name, func(cs *kube.ContainerStatus) bool {
+		return cs.State.Running != nil || cs.State.Terminated != nil
+	})
+	if err != nil {
+		return pod, status, err
+	}
+	logs, err := k.kube.PodLogs(ctx, k.cfg.Namespace, pod.Metadata.Name, kubeContainer)
+	if err != nil {
+		return pod, status, err
+	}
+	_, err = io.Copy(out, logs)
+	logs.Close()
+	if err != nil {
+		if ctx.Err() != nil {
+			return pod, status, ctx.Err()
+		}
+		return pod, status, err
+	}
+	// The log ends with the container; its status may take a moment to
+	// say so.
+	pod, err = k.waitPod(ctx, name, func(cs *kube.ContainerStatus) bool { return cs.State.Terminated != nil })
+	if err != nil {
+		return pod, status, err
+	}
+	t := pod.Status.Container(kubeContainer).State.Terminated
+	status = exitStatus{code: t.ExitCode, oomKilled: t.Reason == "OOMKilled"}
+	return pod, status, status.err()
+}
+
+// waitPod polls the run's pod until its sandbox container's status
+// satisfies done, and returns the pod. Pods that can't start fail it.
+func (k *KubeRunner) waitPod(ctx context.Context, name string, done func(*kube.ContainerStatus) bool) (*kube.Pod, error) {
+	var last *kube.Pod
+	for {
+		pods, err := k.kube.ListPods(ctx, k.cfg.Namespace, kubeRunLabel+"="+name)
+		if err != nil {
+			if ctx.Err() != nil {
+				return last, ctx.Err()
+			}
+			return last, err
+		}
+		if len(pods) > 0 {
+			last = &pods[0]
+			cs := last.Status.Container(kubeContainer)
+			switch {
+			case cs != nil && done(cs):
+				return last, nil
+			case cs != nil && cs.State.Waiting != nil:
+				switch w := cs.State.Waiting; w.Reason {
+				case "ErrImagePull", "ImagePullBackOff", "InvalidImageName":
+					return last, fmt.Errorf("%w: %s: %s", errImage, w.Reason, w.Message)
+				case "CreateContainerConfigError", "CreateContainerError", "RunContainerError":
+					return last, fmt.Errorf("%s: %s", w.Reason, w.Message)
+				}
+			case last.Status.Phase == "Failed" && cs == nil:
+				return last, fmt.Errorf("sandbox pod failed: %s", last.Status.Message)
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return last, ctx.Err()
+		case <-time.After(kubePoll):
+		}
+	}
+}
+
+// job is the Job of run name.
+func (k *KubeRunner) job(name, image string) *kube.Job {
+	labels := map[string]string{KubeAppLabel: kubeAppName, kubeRunLabel: name}
+	l := k.cfg.Limits
+	container := kube.Container{
+		Name:  kubeContainer,
+		Image: image,
+		VolumeMounts: []kube.VolumeMount{
+			{Name: "input", MountPath: "/app/input", ReadOnly: true},
+		},
+		SecurityContext: &kube.SecurityContext{ReadOnlyRootFilesystem: l.ReadOnlyRootfs},
+	}
+	if l.NoNewPrivileges {
+		no := false
+		container.SecurityContext.AllowPrivilegeEscalation = &no
+	}
+	limits := map[string]string{}
+	if l.Memory > 0 {
+		limits["memory"] = strconv.FormatInt(l.Memory, 10)
+	}
+	if l.CPUs > 0 {
+		limits["cpu"] = strconv.FormatInt(int64(l.CPUs*1000), 10) + "m"
+	}
+	if len(limits) > 0 {
+		container.Resources.Limits = limits
+	}
+	volumes := []kube.Volume{{Name: "input", Secret: &kube.SecretVolume{SecretName: name}}}
+	if l.TmpfsSize > 0 {
+		container.VolumeMounts = append(container.VolumeMounts, kube.VolumeMount{Name: "tmp", MountPath: "/tmp"})
+		volumes = append(volumes, kube.Volume{Name: "tmp", EmptyDir: &kube.EmptyDir{
+			Medium:    "Memory",
+			SizeLimit: strconv.FormatInt(l.TmpfsSize, 10),
+		}})
+	}
+	// Jobs left behind by a crashed gateway go away by themselves.
+	ttl := int32(300)
+	return &kube.Job{
+		Metadata: kube.ObjectMeta{Name: name, Labels: labels},
+		Spec: kube.JobSpec{
+			BackoffLimit:            0,
+			ActiveDeadlineSeconds:   int64(max(k.cfg.Timeout/time.Second, 1)),
+			TTLSecondsAfterFinished: &ttl,
+			Template: kube.PodTemplateSpec{
+				Metadata: kube.ObjectMeta{Labels: labels},
+				Spec: kube.PodSpec{
+					RestartPolicy:    "Never",
+					RuntimeClassName: k.cfg.RuntimeClass,
+					Containers:       []kube.Container{container},
+					Volumes:          volumes,
+				},
+			},
+		},
+	}
+}
+
+// imageFor returns the image to run for the tenant attached to ctx.
+func (k *KubeRunner) imageFor(ctx context.Context) string {
+	if k.images == nil {
+		return k.cfg.ImageName
+	}
+	return k.images.ImageFor(TenantFrom(ctx))
+}
+
+// digestOf returns the digest in a pod's image ID, e.g.
+// "docker.io/library/x@sha256:…", or "".
+func digestOf(imageID string) string {
+	for i := len(imageID) - 1; i >= 0; i-- {
+		if imageID[i] == '@' {
+			return imageID[i+1:]
+		}
+	}
+	return ""
+}
+
+// randomSuffix makes a Job name unique.
+func randomSuffix() string {
+	var b [5]byte
+	_, _ = rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
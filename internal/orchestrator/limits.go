@@ -0,0 +1,35 @@
+package orchestrator
+
+// Limits bound what a sandbox container may use and do, so a runaway or
+// compromised model process can't exhaust the host. Zero values lift a
+// limit or leave an option off.
+type Limits struct {
+	// Memory is the container's memory in bytes, with no swap on top.
+	Memory int64
+	// CPUs is the CPU time it may use, in CPUs: 0.5 is half of one.
+	CPUs float64
+	// Pids bounds its processes and threads, against fork bombs.
+	Pids int64
+	// NoNewPrivileges stops setuid and file-capability binaries from
+	// gaining privileges.
+	NoNewPrivileges bool
+	// ReadOnlyRootfs mounts the image's filesystem read-only; the input
+	// and output mounts and /tmp stay writable.
+	ReadOnlyRootfs bool
+	// TmpfsSize, if set, mounts a tmpfs of that many bytes at /tmp,
+	// counted against Memory.
+	TmpfsSize int64
+}
+
+// DefaultLimits are the limits of a runner made by NewLLMRunner: enough
+// for an API-calling or small local model.
+func DefaultLimits() Limits {
+	return Limits{
+		Memory:          512 << 20,
+		CPUs:            1,
+		Pids:            128,
+		NoNewPrivileges: true,
+		ReadOnlyRootfs:  true,
+		TmpfsSize:       64 << 20,
+	}
+}
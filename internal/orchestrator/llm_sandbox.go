@@ -1,103 +1,457 @@
+//go:build !minimal
+
 package orchestrator
 
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
-	"io/ioutil"
+	"io"
+	"log/slog"
 	"os"
-	"os/exec"
-	"path/filepath"
-	"runtime"
 	"strings"
 	"time"
+
+	"github.com/shivansh-source/nopass/internal/docker"
+	"github.com/shivansh-source/nopass/internal/metrics"
+	"github.com/shivansh-source/nopass/internal/tracing"
 )
 
 // SandboxConfig allows basic configuration if needed later.
 type SandboxConfig struct {
 	ImageName string
 	Timeout   time.Duration
+	// InputMode is how a run's prompt reaches the container: as files,
+	// with InputBind (the default) or InputVolume, or on stdin, with
+	// InputStdin.
+	InputMode string
+	// TempDir is where each run's input directory is created (default
+	// os.TempDir()). With Docker Desktop and InputBind it must be a
+	// directory shared with the Docker VM.
+	TempDir string
+	// Output is the images' stdout protocol: OutputFrames, OutputText or
+	// OutputAuto (the default). ImageOutputs sets it for particular
+	// images (see ParseImageOutputs); otherwise an image's OutputLabel
+	// takes precedence.
+	Output       string
+	ImageOutputs map[string]string
+	// Limits bound every sandbox container, pooled or not.
+	Limits Limits
+	// Runtime is the OCI runtime sandbox containers run under, e.g.
+	// "runsc" for gVisor or "kata" for Kata Containers, as registered
+	// with the daemon; empty uses the daemon's default (runc).
+	Runtime string
 }
 
-// LLMRunner orchestrates LLM calls inside Docker.
+// LLMRunner orchestrates LLM calls inside Docker, through the Engine API
+// of the daemon DOCKER_HOST names (see docker.FromEnv).
 type LLMRunner struct {
-	cfg SandboxConfig
+	cfg    SandboxConfig
+	docker *docker.Client
+	// images, if set, selects a per-tenant private image for each run.
+	images  *ImagePolicy
+	digests digestCache
+	labels  labelCache
+	// pool, if set, keeps warm containers of the shared image (SetPool).
+	pool *containerPool
 }
 
+var outputProtocols = metrics.NewCounterVec(
+	"nopass_sandbox_output_protocol_total",
+	"Docker sandbox runs by the stdout protocol their image spoke (frames or text).",
+	"protocol",
+)
+
+var sandboxFailures = metrics.NewCounterVec(
+	"nopass_sandbox_failures_total",
+	"Docker sandbox runs that failed, by reason (timeout, oom_killed, error, echo_mismatch or no_result).",
+	"reason",
+)
+
 // NewLLMRunner creates a new LLMRunner with a default config.
 func NewLLMRunner() *LLMRunner {
-	return &LLMRunner{
-		cfg: SandboxConfig{
-			ImageName: "nopass-llm-sandbox:latest",
-			Timeout:   15 * time.Second,
-		},
+	return NewLLMRunnerWithConfig(SandboxConfig{
+		ImageName: "nopass-llm-sandbox:latest",
+		Timeout:   15 * time.Second,
+		Limits:    DefaultLimits(),
+	})
+}
+
+// SetOutput sets the images' stdout protocol, OutputFrames, OutputText
+// or OutputAuto.
+func (r *LLMRunner) SetOutput(output string) error {
+	if !validOutput(output) {
+		return fmt.Errorf("sandbox output must be %s, %s or %s, got %q", OutputFrames, OutputText, OutputAuto, output)
 	}
+	r.cfg.Output = output
+	return nil
 }
 
-// RunInSandbox:
-//   - Creates a temp directory
-//   - Writes system/user prompts to files
-//   - Runs Docker with:
-//     --network none
-//     -v tempDir:/app/input:ro
-//   - Returns stdout as the "LLM answer".
-func (r *LLMRunner) RunInSandbox(ctx context.Context, systemPrompt, userContent string) (string, error) {
-	// Create temp dir
-	tempDir, err := os.MkdirTemp("", "nopass-llm-input-*")
+// SetImageOutputs sets the stdout protocol of particular images.
+func (r *LLMRunner) SetImageOutputs(outputs map[string]string) {
+	r.cfg.ImageOutputs = outputs
+}
+
+// SetRuntime sets the OCI runtime sandbox containers run under (see
+// SandboxConfig.Runtime).
+func (r *LLMRunner) SetRuntime(runtime string) {
+	r.cfg.Runtime = runtime
+}
+
+// outputFor returns the stdout protocol to expect from image: configured
+// for the image, declared by its OutputLabel, or the default.
+func (r *LLMRunner) outputFor(ctx context.Context, image string) string {
+	name, _, _ := strings.Cut(image, "@")
+	for _, ref := range []string{image, name} {
+		if p, ok := r.cfg.ImageOutputs[ref]; ok {
+			return p
+		}
+	}
+	if p := r.labels.label(ctx, r.docker, image, OutputLabel); validOutput(p) {
+		return p
+	}
+	if r.cfg.Output == "" {
+		return OutputAuto
+	}
+	return r.cfg.Output
+}
+
+// NewLLMRunnerWithConfig creates an LLMRunner running cfg.ImageName.
+func NewLLMRunnerWithConfig(cfg SandboxConfig) *LLMRunner {
+	return &LLMRunner{cfg: cfg, docker: docker.FromEnv()}
+}
+
+// SetTenantImages enables per-tenant image selection. The policy's shared
+// image replaces the default image.
+func (r *LLMRunner) SetTenantImages(p *ImagePolicy) error {
+	if p.Shared == "" {
+		p.Shared = r.cfg.ImageName
+	}
+	if err := p.Validate(); err != nil {
+		return err
+	}
+	r.images = p
+	return nil
+}
+
+// Warm readies the sandbox for its first requests: every image a run may
+// use is pulled if missing and its digest cached, then one throwaway run
+// of the shared image loads it into the page cache and the runtime.
+func (r *LLMRunner) Warm(ctx context.Context) error {
+	if err := r.checkRuntime(ctx); err != nil {
+		return err
+	}
+	images := []string{r.cfg.ImageName}
+	if r.images != nil {
+		images = []string{r.images.Shared}
+		for _, img := range r.images.Tenants {
+			images = append(images, img.Ref())
+		}
+	}
+	for _, image := range images {
+		if _, err := r.docker.ImageInspect(ctx, image); err != nil {
+			if err := r.docker.ImagePull(ctx, image); err != nil {
+				return err
+			}
+		}
+		r.digests.digest(ctx, r.docker, image)
+	}
+	if _, err := r.RunInSandbox(ctx, "", "warm-up"); err != nil {
+		return fmt.Errorf("warm-up run: %w", err)
+	}
+	return nil
+}
+
+// Ping checks that the Docker daemon answers.
+func (r *LLMRunner) Ping(ctx context.Context) error {
+	if _, err := r.docker.Version(ctx); err != nil {
+		return fmt.Errorf("docker daemon: %w", err)
+	}
+	return r.checkRuntime(ctx)
+}
+
+// checkRuntime checks that the daemon has the configured runtime, so a
+// missing gVisor or Kata install fails health checks rather than every
+// run.
+func (r *LLMRunner) checkRuntime(ctx context.Context) error {
+	if r.cfg.Runtime == "" {
+		return nil
+	}
+	info, err := r.docker.Info(ctx)
 	if err != nil {
-		return "", fmt.Errorf("create temp dir: %w", err)
+		return fmt.Errorf("docker daemon: %w", err)
+	}
+	if _, ok := info.Runtimes[r.cfg.Runtime]; !ok {
+		return fmt.Errorf("docker daemon has no runtime %q", r.cfg.Runtime)
 	}
-	// Clean up after
-	defer os.RemoveAll(tempDir)
+	return nil
+}
 
-	// Write files
-	if err := ioutil.WriteFile(filepath.Join(tempDir, "system.txt"), []byte(systemPrompt), 0o600); err != nil {
-		return "", fmt.Errorf("write system prompt: %w", err)
+// hostConfig is how every sandbox container runs: without a network,
+// within the configured limits, under the configured runtime.
+func (r *LLMRunner) hostConfig() docker.HostConfig {
+	l := r.cfg.Limits
+	host := docker.HostConfig{
+		NetworkMode:    "none",
+		Memory:         l.Memory,
+		NanoCPUs:       int64(l.CPUs * 1e9),
+		PidsLimit:      l.Pids,
+		ReadonlyRootfs: l.ReadOnlyRootfs,
+		Runtime:        r.cfg.Runtime,
 	}
-	if err := ioutil.WriteFile(filepath.Join(tempDir, "user.txt"), []byte(userContent), 0o600); err != nil {
-		return "", fmt.Errorf("write user content: %w", err)
+	if l.Memory > 0 {
+		// No swap beyond the memory limit.
+		host.MemorySwap = l.Memory
 	}
+	if l.NoNewPrivileges {
+		host.SecurityOpt = []string{"no-new-privileges"}
+	}
+	if l.TmpfsSize > 0 {
+		host.Tmpfs = map[string]string{"/tmp": fmt.Sprintf("rw,noexec,nosuid,size=%d", l.TmpfsSize)}
+	}
+	return host
+}
 
-	// On Windows, Docker Desktop expects paths like C:\path or /c/path.
-	// We'll pass the raw path; if needed, you can adjust this to your local Docker setup.
-	vol := fmt.Sprintf("%s:/app/input:ro", r.normalizePathForDocker(tempDir))
+// createContainer creates a container, pulling its image first if it
+// isn't there, as docker run would.
+func (r *LLMRunner) createContainer(ctx context.Context, cfg docker.ContainerConfig) (string, error) {
+	id, err := r.docker.ContainerCreate(ctx, cfg)
+	if docker.IsNotFound(err) {
+		if err := r.docker.ImagePull(ctx, cfg.Image); err != nil {
+			return "", err
+		}
+		id, err = r.docker.ContainerCreate(ctx, cfg)
+	}
+	return id, err
+}
+
+// runContainer starts the created container id, writes stdin, if any,
+// to it, copies its output until it exits and returns how it ended.
+func (r *LLMRunner) runContainer(ctx context.Context, id string, stdin []byte, stdout, stderr io.Writer) (exitStatus, error) {
+	status := exitStatus{code: -1}
+	stream, err := r.docker.ContainerAttach(ctx, id, stdin != nil)
+	if err != nil {
+		return status, err
+	}
+	defer stream.Close()
+	if err := r.docker.ContainerStart(ctx, id); err != nil {
+		return status, err
+	}
+	if stdin != nil {
+		writeStdin(stream, stdin)
+	}
+	if err := stream.Copy(ctx, stdout, stderr); err != nil {
+		return status, err
+	}
+	if _, err := r.docker.ContainerWait(ctx, id); err != nil {
+		return status, err
+	}
+	c, err := r.docker.ContainerInspect(ctx, id)
+	if err != nil {
+		return status, err
+	}
+	status = exitStatus{code: c.State.ExitCode, oomKilled: c.State.OOMKilled}
+	if c.State.Error != "" {
+		return status, errors.New(c.State.Error)
+	}
+	return status, status.err()
+}
+
+// imageFor returns the image to run for the tenant attached to ctx.
+func (r *LLMRunner) imageFor(ctx context.Context) string {
+	if r.images == nil {
+		return r.cfg.ImageName
+	}
+	return r.images.ImageFor(TenantFrom(ctx))
+}
+
+// RunInSandbox:
+//   - Writes system/user prompts (and optional cache hint and sampling
+//     settings) to files in a temp directory or, with InputStdin, into a
+//     request envelope
+//   - Runs Docker with:
+//     --network none
+//     tempDir mounted read-only at /app/input (see SandboxConfig.InputMode),
+//     or the envelope written to stdin
+//     and, if ctx asks for artifacts (WithArtifacts), a writable
+//     /app/output collected after a successful run
+//   - Returns the answer frames on stdout (or, for OutputText images,
+//     stdout less the echo footer), in the protocol configured for the
+//     image, declared by it or detected (see SandboxConfig.Output); the
+//     echo must match the request if ctx expects one (WithEcho).
+//
+// If ctx carries a receipt (WithReceipt), it is filled in with the run's
+// measured usage and the model, token usage and warnings the frames
+// reported, including for failed runs.
+func (r *LLMRunner) RunInSandbox(ctx context.Context, systemPrompt, userContent string) (string, error) {
+	return r.RunInSandboxStream(ctx, systemPrompt, userContent, nil)
+}
+
+// RunInSandboxStream is RunInSandbox, passing the container's stdout to
+// onChunk as it is written. A nil onChunk buffers the whole answer.
+func (r *LLMRunner) RunInSandboxStream(ctx context.Context, systemPrompt, userContent string, onChunk func(string) error) (_ string, err error) {
+	ctx, span := tracing.Start(ctx, "sandbox.run", tracing.Internal)
+	defer func() { span.End(err) }()
 
-	// Prepare Docker command
 	cmdCtx, cancel := context.WithTimeout(ctx, r.cfg.Timeout)
 	defer cancel()
 
-	cmd := exec.CommandContext(
-		cmdCtx,
-		"docker", "run",
-		"--rm",
-		"--network", "none",
-		"-v", vol,
-		r.cfg.ImageName,
-	)
+	image := r.imageFor(ctx)
+	span.SetAttr("container.image.name", image)
+	protocol := r.outputFor(ctx, image)
+	// The request goes to the container as a stdin envelope or as files
+	// in an input directory.
+	var stdin []byte
+	var tempDir string
+	if r.cfg.InputMode == InputStdin {
+		protocol = OutputFrames
+		if stdin, err = inputRequest(ctx, systemPrompt, userContent, protocol); err != nil {
+			return "", err
+		}
+	} else {
+		files, err := inputFiles(ctx, systemPrompt, userContent, protocol)
+		if err != nil {
+			return "", err
+		}
+		if tempDir, err = r.writeInput(files); err != nil {
+			return "", err
+		}
+		defer os.RemoveAll(tempDir)
+	}
+	artifacts := ArtifactsFrom(ctx)
+	// A warm container from the pool, if one is ready; runs collecting
+	// artifacts need the output mount only a fresh container can have.
+	var warm *warmContainer
+	if artifacts == nil {
+		warm = r.pool.take(image)
+	}
+	if warm != nil {
+		// Removed in the background: the answer needn't wait for it.
+		defer func(id string) { go removeContainer(ctx, r.docker, id) }(warm.id)
+		// With InputStdin there is nothing to load: the request goes to
+		// the exec's stdin.
+		if tempDir != "" {
+			if err := warm.load(cmdCtx, r.docker, tempDir); err != nil {
+				slog.WarnContext(ctx, "warm sandbox container unusable; starting a new one", "err", err)
+				poolRuns.Inc("load_failed")
+				warm = nil
+			}
+		}
+	}
+	var containerID string
+	var outputDir string
+	var collect func() error
+	if warm != nil {
+		containerID = warm.id
+	} else {
+		host := r.hostConfig()
+		cfg := docker.ContainerConfig{
+			Image:        image,
+			AttachStdout: true,
+			AttachStderr: true,
+		}
+		if stdin != nil {
+			cfg.Env = []string{stdinEnv}
+			cfg.AttachStdin, cfg.OpenStdin, cfg.StdinOnce = true, true, true
+		} else {
+			mount, release, err := r.inputMount(cmdCtx, tempDir, image)
+			if err != nil {
+				return "", err
+			}
+			defer release()
+			host.Mounts = append(host.Mounts, mount)
+		}
+		// The output dir lives outside the mounted input.
+		if artifacts != nil {
+			outputDir, err = os.MkdirTemp(r.cfg.TempDir, "nopass-llm-output-*")
+			if err != nil {
+				return "", fmt.Errorf("create output dir: %w", err)
+			}
+			defer os.RemoveAll(outputDir)
+			outMount, c, releaseOutput, err := r.outputMount(cmdCtx, outputDir, image)
+			if err != nil {
+				return "", err
+			}
+			defer releaseOutput()
+			collect = c
+			host.Mounts = append(host.Mounts, outMount)
+		}
+		cfg.HostConfig = host
+		containerID, err = r.createContainer(cmdCtx, cfg)
+		if err != nil {
+			return "", fmt.Errorf("create sandbox container: %w", err)
+		}
+		// Removed even if the run was cut off, which stops it.
+		defer removeContainer(ctx, r.docker, containerID)
+	}
 
-	var stdout, stderr bytes.Buffer
-	cmd.Stdout = &stdout
-	cmd.Stderr = &stderr
+	stdout := newRunOutput(ctx, protocol, onChunk, cancel)
+	stdout.requireResult = stdin != nil
+	var stderr bytes.Buffer
 
-	if err := cmd.Run(); err != nil {
+	start := time.Now()
+	var status exitStatus
+	if warm != nil {
+		status, err = warm.run(cmdCtx, r.docker, stdin, stdout, &stderr)
+	} else {
+		status, err = r.runContainer(cmdCtx, containerID, stdin, stdout, &stderr)
+	}
+	if cerr := stdout.close(); err == nil {
+		err = cerr
+	}
+	outputProtocols.Inc(stdout.protocol)
+	span.SetAttr("process.exit.code", status.code)
+	slog.DebugContext(ctx, "sandbox run finished", "image", image, "exit_code", status.code, "oom_killed", status.oomKilled,
+		"duration_ms", time.Since(start).Milliseconds(), "output_bytes", stdout.Len())
+	if rc := ReceiptFrom(ctx); rc != nil {
+		rc.TenantID = TenantFrom(ctx)
+		rc.ContainerID = containerID
+		rc.Image = image
+		rc.ImageDigest = r.digests.digest(ctx, r.docker, image)
+		rc.StartedAt = start.UTC()
+		rc.WallTimeMs = time.Since(start).Milliseconds()
+		rc.ExitCode = status.code // -1 if it never ran or was cut off
+		rc.OOMKilled = status.oomKilled
+		stdout.fill(rc)
+		if u, ok := parseUsage(stderr.String()); ok {
+			rc.CPUTimeMs = u.CPUTimeMs
+			rc.PeakMemoryBytes = u.PeakMemoryBytes
+		}
+	}
+	if err := stdout.err(); err != nil {
+		// The consumer stopped the run, or the output did; the error
+		// explains why.
+		return "", err
+	}
+	if err != nil {
 		// Distinguish between timeout and other errors.
 		if cmdCtx.Err() == context.DeadlineExceeded {
+			sandboxFailures.Inc("timeout")
 			return "", fmt.Errorf("docker run timed out: %w", cmdCtx.Err())
 		}
+		if status.oomKilled {
+			sandboxFailures.Inc("oom_killed")
+		} else if ctx.Err() == nil {
+			sandboxFailures.Inc("error")
+		}
 		return "", fmt.Errorf("docker run error: %v, stderr: %s", err, stderr.String())
 	}
 
-	return stdout.String(), nil
-}
-
-// normalizePathForDocker attempts to adjust host paths for Docker on different OSes.
-func (r *LLMRunner) normalizePathForDocker(p string) string {
-	// Basic implementation:
-	// - On Unix, we can pass as-is.
-	// - On Windows, Docker often supports the same path, or you might need to convert.
-	if runtime.GOOS == "windows" {
-		// Example: convert "C:\Users\me\AppData\Local\Temp\..." to "C:/Users/..."
-		p = strings.ReplaceAll(p, `\`, `/`)
+	answer, err := stdout.answer(ctx)
+	switch {
+	case errors.Is(err, ErrEchoMismatch):
+		sandboxFailures.Inc("echo_mismatch")
+	case errors.Is(err, ErrNoResult):
+		sandboxFailures.Inc("no_result")
+	}
+	if err == nil && artifacts != nil {
+		if err := collect(); err != nil {
+			return "", err
+		}
+		if err := collectArtifacts(outputDir, artifacts); err != nil {
+			return "", err
+		}
 	}
-	return p
+	return answer, err
 }
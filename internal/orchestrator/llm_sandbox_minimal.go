@@ -0,0 +1,100 @@
+//go:build minimal
+
+package orchestrator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrNoDocker is what LLMRunner returns in minimal builds, which have no
+// Docker sandbox; use a model provider (sandbox mode "provider") instead.
+var ErrNoDocker = errors.New("sandbox: this gateway is a minimal build without Docker support")
+
+// SandboxConfig mirrors the full build's, so callers compile unchanged.
+type SandboxConfig struct {
+	ImageName    string
+	Timeout      time.Duration
+	InputMode    string
+	TempDir      string
+	Output       string
+	ImageOutputs map[string]string
+	Limits       Limits
+	Runtime      string
+}
+
+// LLMRunner stands in for the Docker runner: every run fails with
+// ErrNoDocker.
+type LLMRunner struct {
+	cfg    SandboxConfig
+	images *ImagePolicy
+}
+
+// NewLLMRunner creates an LLMRunner.
+func NewLLMRunner() *LLMRunner {
+	return &LLMRunner{}
+}
+
+// SetOutput validates output as the full build does.
+func (r *LLMRunner) SetOutput(output string) error {
+	if !validOutput(output) {
+		return fmt.Errorf("sandbox output must be %s, %s or %s, got %q", OutputFrames, OutputText, OutputAuto, output)
+	}
+	r.cfg.Output = output
+	return nil
+}
+
+// SetImageOutputs records outputs as the full build does.
+func (r *LLMRunner) SetImageOutputs(outputs map[string]string) {
+	r.cfg.ImageOutputs = outputs
+}
+
+// SetRuntime records runtime as the full build does.
+func (r *LLMRunner) SetRuntime(runtime string) {
+	r.cfg.Runtime = runtime
+}
+
+// NewLLMRunnerWithConfig creates an LLMRunner.
+func NewLLMRunnerWithConfig(cfg SandboxConfig) *LLMRunner {
+	return &LLMRunner{cfg: cfg}
+}
+
+// SetTenantImages validates p as the full build does.
+func (r *LLMRunner) SetTenantImages(p *ImagePolicy) error {
+	if p.Shared == "" {
+		p.Shared = r.cfg.ImageName
+	}
+	if err := p.Validate(); err != nil {
+		return err
+	}
+	r.images = p
+	return nil
+}
+
+// SetPool does nothing; there are no containers to pool.
+func (r *LLMRunner) SetPool(size int, maxAge time.Duration) {}
+
+// RunPool returns at once.
+func (r *LLMRunner) RunPool(ctx context.Context) {}
+
+// Warm returns ErrNoDocker.
+func (r *LLMRunner) Warm(ctx context.Context) error {
+	return ErrNoDocker
+}
+
+// Ping returns ErrNoDocker.
+func (r *LLMRunner) Ping(ctx context.Context) error {
+	return ErrNoDocker
+}
+
+// RunInSandbox returns ErrNoDocker.
+func (r *LLMRunner) RunInSandbox(ctx context.Context, systemPrompt, userContent string) (string, error) {
+	return "", ErrNoDocker
+}
+
+// RunInSandboxStream returns ErrNoDocker.
+func (r *LLMRunner) RunInSandboxStream(ctx context.Context, systemPrompt, userContent string, onChunk func(string) error) (string, error) {
+	return "", ErrNoDocker
+}
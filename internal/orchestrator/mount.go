@@ -0,0 +1,133 @@
+//go:build !minimal
+
+package orchestrator
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/shivansh-source/nopass/internal/docker"
+)
+
+// writeInput writes a run's input files to a new directory under TempDir
+// and returns it, for the caller to remove.
+func (r *LLMRunner) writeInput(files map[string][]byte) (string, error) {
+	dir, err := os.MkdirTemp(r.cfg.TempDir, "nopass-llm-input-*")
+	if err != nil {
+		return "", fmt.Errorf("create temp dir: %w", err)
+	}
+	for name, data := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), data, 0o600); err != nil {
+			os.RemoveAll(dir)
+			return "", fmt.Errorf("write %s: %w", name, err)
+		}
+	}
+	return dir, nil
+}
+
+// inputMount makes dir available to a run of image and returns the mount
+// that mounts it read-only at /app/input, and a release func to call once
+// the run is over.
+func (r *LLMRunner) inputMount(ctx context.Context, dir, image string) (docker.Mount, func(), error) {
+	if r.cfg.InputMode != InputVolume {
+		src, err := dockerHostPath(dir)
+		if err != nil {
+			return docker.Mount{}, nil, fmt.Errorf("resolve input dir: %w", err)
+		}
+		return docker.Mount{Type: "bind", Source: src, Target: "/app/input", ReadOnly: true}, func() {}, nil
+	}
+
+	volume := filepath.Base(dir)
+	if err := r.docker.VolumeCreate(ctx, volume); err != nil {
+		return docker.Mount{}, nil, fmt.Errorf("create input volume: %w", err)
+	}
+	release := func() {
+		// The run's context may be gone; removal must still happen.
+		rctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 30*time.Second)
+		defer cancel()
+		r.docker.VolumeRemove(rctx, volume)
+	}
+	if err := r.copyToVolume(ctx, dir, volume, image); err != nil {
+		release()
+		return docker.Mount{}, nil, err
+	}
+	return docker.Mount{Type: "volume", Source: volume, Target: "/app/input", ReadOnly: true}, release, nil
+}
+
+// copyToVolume copies dir's contents into volume through a container of
+// image that is created, never started, and removed.
+func (r *LLMRunner) copyToVolume(ctx context.Context, dir, volume, image string) error {
+	loader, err := r.createContainer(ctx, docker.ContainerConfig{
+		Image: image,
+		HostConfig: docker.HostConfig{
+			NetworkMode: "none",
+			Mounts:      []docker.Mount{{Type: "volume", Source: volume, Target: "/app/input"}},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("create input loader: %w", err)
+	}
+	defer removeContainer(ctx, r.docker, loader)
+	archive := docker.TarDir(dir)
+	defer archive.Close()
+	if err := r.docker.CopyTo(ctx, loader, "/app/input", archive); err != nil {
+		return fmt.Errorf("copy input to volume: %w", err)
+	}
+	return nil
+}
+
+// dockerHostPath returns host path p as the Docker daemon must be given
+// it for a bind mount:
+//   - it is absolute with symlinks resolved, as Docker Desktop's file
+//     sharing matches real paths (macOS's /var is a link to /private/var);
+//   - on Windows it is the native path (C:\Users\...);
+//   - under WSL2 with a Windows docker.exe (no Docker Desktop WSL
+//     integration), /mnt/c/... becomes C:\... and a path inside the
+//     distribution becomes \\wsl.localhost\<distro>\....
+func dockerHostPath(p string) (string, error) {
+	p, err := filepath.Abs(p)
+	if err != nil {
+		return "", err
+	}
+	if resolved, err := filepath.EvalSymlinks(p); err == nil {
+		p = resolved
+	}
+	if runtime.GOOS != "linux" || !windowsDocker() {
+		return p, nil
+	}
+	return wslToWindows(p, os.Getenv("WSL_DISTRO_NAME")), nil
+}
+
+// windowsDocker reports whether this is WSL and "docker" is the Windows
+// CLI: the daemon is then Docker Desktop's, reached from Windows, and
+// resolves paths on the Windows side.
+func windowsDocker() bool {
+	if os.Getenv("WSL_DISTRO_NAME") == "" {
+		return false
+	}
+	path, err := exec.LookPath("docker")
+	return err == nil && strings.HasSuffix(strings.ToLower(path), ".exe")
+}
+
+// wslToWindows translates a WSL path to the Windows path of the same file.
+func wslToWindows(p, distro string) string {
+	if rest, ok := strings.CutPrefix(p, "/mnt/"); ok && len(rest) >= 1 && (len(rest) == 1 || rest[1] == '/') {
+		drive := strings.ToUpper(rest[:1])
+		return drive + `:\` + strings.ReplaceAll(strings.TrimPrefix(rest[1:], "/"), "/", `\`)
+	}
+	return `\\wsl.localhost\` + distro + strings.ReplaceAll(p, "/", `\`)
+}
+
+// writeStdin writes a run's request envelope to its stream. It is written
+// while the output is read, so an image that prints before it reads
+// can't stall the run on a full pipe; a request that doesn't arrive shows
+// as output without a result frame.
+func writeStdin(stream *docker.Stream, request []byte) {
+	go stream.Write(request)
+}
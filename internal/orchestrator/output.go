@@ -0,0 +1,205 @@
+package orchestrator
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/shivansh-source/nopass/internal/types"
+)
+
+// OutputAuto detects the protocol from the first line the image prints: a
+// JSON frame means OutputFrames, anything else OutputText. It lets images
+// of both kinds run side by side while they are migrated.
+const OutputAuto = "auto"
+
+// OutputLabel is the image label declaring its stdout protocol, e.g.
+// LABEL io.nopass.output=frames in the Dockerfile.
+const OutputLabel = "io.nopass.output"
+
+// validOutput reports whether p names an output protocol.
+func validOutput(p string) bool {
+	return p == OutputFrames || p == OutputText || p == OutputAuto
+}
+
+// ParseImageOutputs parses "image=protocol,image2=protocol", giving the
+// stdout protocol of particular images. An image is named as it is run,
+// with or without its @digest.
+func ParseImageOutputs(spec string) (map[string]string, error) {
+	out := make(map[string]string)
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		i := strings.LastIndexByte(entry, '=')
+		if i <= 0 || !validOutput(entry[i+1:]) {
+			return nil, fmt.Errorf("image output entry %q: want image=frames, image=text or image=auto", entry)
+		}
+		out[entry[:i]] = entry[i+1:]
+	}
+	return out, nil
+}
+
+// runOutput is the sandbox's stdout. It hands the output to a frameWriter
+// or, for OutputText, a chunkWriter (streaming) or buffer, deciding which
+// on the first line under OutputAuto.
+type runOutput struct {
+	ctx      context.Context
+	onChunk  func(string) error
+	cancel   context.CancelFunc
+	protocol string
+	// requireResult fails frame output that doesn't end in a result
+	// frame.
+	requireResult bool
+
+	pending []byte // OutputAuto: output before the protocol is known
+	frames  *frameWriter
+	chunks  *chunkWriter
+	text    *bytes.Buffer
+}
+
+func newRunOutput(ctx context.Context, protocol string, onChunk func(string) error, cancel context.CancelFunc) *runOutput {
+	o := &runOutput{ctx: ctx, onChunk: onChunk, cancel: cancel}
+	if protocol != OutputAuto {
+		o.choose(protocol)
+	}
+	return o
+}
+
+func (o *runOutput) choose(protocol string) {
+	o.protocol = protocol
+	switch {
+	case protocol != OutputText:
+		o.protocol = OutputFrames
+		o.frames = &frameWriter{ctx: o.ctx, onChunk: o.onChunk, cancel: o.cancel}
+	case o.onChunk != nil:
+		o.chunks = &chunkWriter{onChunk: o.onChunk, cancel: o.cancel, holdEcho: true}
+	default:
+		o.text = &bytes.Buffer{}
+	}
+}
+
+func (o *runOutput) writer() interface{ Write([]byte) (int, error) } {
+	switch {
+	case o.frames != nil:
+		return o.frames
+	case o.chunks != nil:
+		return o.chunks
+	default:
+		return o.text
+	}
+}
+
+func (o *runOutput) Write(p []byte) (int, error) {
+	if o.protocol != "" {
+		return o.writer().Write(p)
+	}
+	o.pending = append(o.pending, p...)
+	trimmed := bytes.TrimLeft(o.pending, " \t\r\n")
+	i := bytes.IndexByte(trimmed, '\n')
+	if i < 0 && len(o.pending) <= maxFrameBytes {
+		return len(p), nil
+	}
+	if i < 0 {
+		i = len(trimmed)
+	}
+	o.detect(trimmed[:i])
+	if _, err := o.writer().Write(o.pending); err != nil {
+		return 0, err
+	}
+	o.pending = nil
+	return len(p), nil
+}
+
+// detect chooses the protocol from the first non-empty line.
+func (o *runOutput) detect(line []byte) {
+	var fr frame
+	if json.Unmarshal(bytes.TrimSpace(line), &fr) == nil && fr.Type != "" {
+		o.choose(OutputFrames)
+	} else {
+		o.choose(OutputText)
+	}
+}
+
+// close ends the output, deciding the protocol if the image never
+// finished a line.
+func (o *runOutput) close() error {
+	if o.protocol == "" {
+		o.detect(o.pending)
+		if _, err := o.writer().Write(o.pending); err != nil {
+			return err
+		}
+		o.pending = nil
+	}
+	if o.frames != nil {
+		return o.frames.close()
+	}
+	return nil
+}
+
+// err is why the output stopped the run, if it did.
+func (o *runOutput) err() error {
+	switch {
+	case o.frames != nil:
+		return o.frames.err
+	case o.chunks != nil:
+		return o.chunks.err
+	}
+	return nil
+}
+
+// Len returns how many bytes the sandbox wrote.
+func (o *runOutput) Len() int {
+	switch {
+	case o.frames != nil:
+		return o.frames.Len()
+	case o.chunks != nil:
+		return o.chunks.Len()
+	case o.text != nil:
+		return o.text.Len()
+	}
+	return len(o.pending)
+}
+
+// fill records what the output reported on rc.
+func (o *runOutput) fill(rc *types.SandboxReceipt) {
+	rc.OutputBytes = o.Len()
+	rc.OutputProtocol = o.protocol
+	if o.frames != nil {
+		o.frames.fill(rc)
+	}
+}
+
+// answer returns the answer once the run has finished, verifying the
+// echo (see WithEcho) and, when streaming text, delivering what was held
+// back.
+func (o *runOutput) answer(ctx context.Context) (string, error) {
+	if o.frames != nil {
+		if err := o.frames.resultErr(o.requireResult); err != nil {
+			return "", err
+		}
+		if err := verifyEcho(ctx, o.frames.echo, true); err != nil {
+			return "", err
+		}
+		return o.frames.String(), nil
+	}
+	out := ""
+	if o.chunks != nil {
+		out = o.chunks.String()
+	} else if o.text != nil {
+		out = o.text.String()
+	}
+	answer, err := checkEcho(ctx, out, true)
+	if err != nil {
+		return "", err
+	}
+	if o.chunks != nil {
+		if err := o.chunks.flush(len(answer)); err != nil {
+			return "", err
+		}
+	}
+	return answer, nil
+}
@@ -0,0 +1,153 @@
+//go:build !minimal
+
+package orchestrator
+
+import (
+	"archive/tar"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/shivansh-source/nopass/internal/docker"
+)
+
+// outputMount makes dir the run's /app/output, writable, and returns the
+// mount, a func that copies what the run wrote back into dir (a no-op
+// for bind mounts) and a release func to call once the run is over.
+func (r *LLMRunner) outputMount(ctx context.Context, dir, image string) (docker.Mount, func() error, func(), error) {
+	if r.cfg.InputMode != InputVolume {
+		// The image may run as any user; dir is private to this run.
+		if err := os.Chmod(dir, 0o777); err != nil {
+			return docker.Mount{}, nil, nil, fmt.Errorf("open output dir: %w", err)
+		}
+		src, err := dockerHostPath(dir)
+		if err != nil {
+			return docker.Mount{}, nil, nil, fmt.Errorf("resolve output dir: %w", err)
+		}
+		return docker.Mount{Type: "bind", Source: src, Target: "/app/output"}, func() error { return nil }, func() {}, nil
+	}
+
+	volume := filepath.Base(dir)
+	if err := r.docker.VolumeCreate(ctx, volume); err != nil {
+		return docker.Mount{}, nil, nil, fmt.Errorf("create output volume: %w", err)
+	}
+	collect := func() error {
+		rctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 30*time.Second)
+		defer cancel()
+		return r.copyFromVolume(rctx, volume, image, dir)
+	}
+	release := func() {
+		// The run's context may be gone; removal must still happen.
+		rctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 30*time.Second)
+		defer cancel()
+		r.docker.VolumeRemove(rctx, volume)
+	}
+	return docker.Mount{Type: "volume", Source: volume, Target: "/app/output"}, collect, release, nil
+}
+
+// copyFromVolume copies the regular files at the top of volume into dir
+// through a container of image that is created, never started, and
+// removed.
+func (r *LLMRunner) copyFromVolume(ctx context.Context, volume, image, dir string) error {
+	loader, err := r.createContainer(ctx, docker.ContainerConfig{
+		Image: image,
+		HostConfig: docker.HostConfig{
+			NetworkMode: "none",
+			Mounts:      []docker.Mount{{Type: "volume", Source: volume, Target: "/app/output"}},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("create output loader: %w", err)
+	}
+	defer removeContainer(ctx, r.docker, loader)
+	archive, err := r.docker.CopyFrom(ctx, loader, "/app/output")
+	if err != nil {
+		return fmt.Errorf("copy output from volume: %w", err)
+	}
+	defer archive.Close()
+	if err := extractTop(archive, dir); err != nil {
+		return fmt.Errorf("copy output from volume: %w", err)
+	}
+	return nil
+}
+
+// extractTop writes the regular files directly inside the archive's top
+// directory into dir. Everything else, collectArtifacts would drop.
+func extractTop(archive io.Reader, dir string) error {
+	tr := tar.NewReader(archive)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		_, name, ok := strings.Cut(hdr.Name, "/")
+		if !ok || name == "" || strings.Contains(name, "/") || name == "." || name == ".." || hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		f, err := os.OpenFile(filepath.Join(dir, name), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(f, tr)
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// collectArtifacts reads the regular files at the top of dir into a,
+// in name order, within its limits. Links, directories and anything else
+// are dropped unread.
+func collectArtifacts(dir string, a *Artifacts) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return fmt.Errorf("read output dir: %w", err)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+	var total int64
+	for _, e := range entries {
+		name := e.Name()
+		info, err := e.Info()
+		if err != nil || !info.Mode().IsRegular() || len(a.Files) >= a.MaxFiles || total+info.Size() > a.MaxBytes {
+			a.Dropped = append(a.Dropped, name)
+			continue
+		}
+		data, err := readLimited(filepath.Join(dir, name), a.MaxBytes-total)
+		if err != nil {
+			a.Dropped = append(a.Dropped, name)
+			continue
+		}
+		total += int64(len(data))
+		a.Files = append(a.Files, ArtifactFile{Name: name, Data: data})
+	}
+	return nil
+}
+
+// readLimited reads the file at path, failing past max bytes.
+func readLimited(path string, max int64) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	data, err := io.ReadAll(io.LimitReader(f, max+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > max {
+		return nil, fmt.Errorf("%s exceeds %d bytes", path, max)
+	}
+	return data, nil
+}
@@ -0,0 +1,305 @@
+//go:build !minimal
+
+package orchestrator
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/shivansh-source/nopass/internal/docker"
+	"github.com/shivansh-source/nopass/internal/metrics"
+)
+
+// A container pool keeps sandbox containers of the shared image started
+// and idle, so a run skips container creation and start. A pooled
+// container idles on PoolIdleCommand; a run copies its input into the
+// container's own /app/input volume, execs the image's own entrypoint and
+// then removes the container. No
+// container serves two runs, so nothing of one request can reach the
+// next. Runs of tenant images or that collect artifacts start their own
+// container as before.
+
+// PoolLabel marks pooled containers with the host that started them, so
+// ones a crashed gateway left behind can be found and removed without
+// touching another gateway's on a shared daemon.
+const PoolLabel = "io.nopass.pool"
+
+// PoolIdleCommand is what a pooled container runs until it is used; the
+// image must have sleep.
+var PoolIdleCommand = []string{"sleep", "infinity"}
+
+var poolRuns = metrics.NewCounterVec(
+	"nopass_sandbox_pool_total",
+	"Docker sandbox runs by whether a warm container was ready (hit or miss), and pooled containers dropped (expired, unhealthy or load_failed).",
+	"result",
+)
+
+// poolCheckInterval is how often idle containers are checked and the pool
+// refilled.
+const poolCheckInterval = 15 * time.Second
+
+type warmContainer struct {
+	id      string
+	argv    []string // the image's entrypoint and command
+	started time.Time
+}
+
+// containerPool holds up to size idle containers of image.
+type containerPool struct {
+	docker *docker.Client
+	image  string
+	size   int
+	maxAge time.Duration
+	host   docker.HostConfig // shared with cold runs
+
+	mu       sync.Mutex
+	idle     []*warmContainer
+	starting int
+	refill   chan struct{}
+}
+
+// SetPool keeps size containers of the shared image warm, each replaced
+// after maxAge idle. It takes effect once RunPool runs; size 0 disables
+// pooling.
+func (r *LLMRunner) SetPool(size int, maxAge time.Duration) {
+	if size <= 0 {
+		r.pool = nil
+		return
+	}
+	image := r.cfg.ImageName
+	if r.images != nil {
+		image = r.images.Shared
+	}
+	r.pool = &containerPool{
+		docker: r.docker,
+		image:  image,
+		size:   size,
+		maxAge: maxAge,
+		host:   r.hostConfig(),
+		refill: make(chan struct{}, 1),
+	}
+}
+
+// RunPool fills the pool and keeps it full, healthy and fresh until ctx
+// is done, then removes the idle containers.
+func (r *LLMRunner) RunPool(ctx context.Context) {
+	if r.pool == nil {
+		return
+	}
+	r.pool.removeStale(ctx)
+	r.pool.run(ctx)
+}
+
+// poolOwner is the PoolLabel value of this host's containers.
+func poolOwner() string {
+	host, _ := os.Hostname()
+	return host
+}
+
+// removeStale removes pooled containers left by an earlier process.
+func (p *containerPool) removeStale(ctx context.Context) {
+	ids, err := p.docker.ContainerList(ctx, PoolLabel+"="+poolOwner())
+	if err != nil {
+		return
+	}
+	for _, id := range ids {
+		removeContainer(ctx, p.docker, id)
+	}
+}
+
+func (p *containerPool) run(ctx context.Context) {
+	ticker := time.NewTicker(poolCheckInterval)
+	defer ticker.Stop()
+	for {
+		p.fill(ctx)
+		select {
+		case <-ticker.C:
+			p.check(ctx)
+		case <-p.refill:
+		case <-ctx.Done():
+			p.mu.Lock()
+			idle := p.idle
+			p.idle = nil
+			p.mu.Unlock()
+			for _, c := range idle {
+				removeContainer(ctx, p.docker, c.id)
+			}
+			return
+		}
+	}
+}
+
+// take returns an idle container of image, or nil if none is ready.
+func (p *containerPool) take(image string) *warmContainer {
+	if p == nil || image != p.image {
+		return nil
+	}
+	p.mu.Lock()
+	var c *warmContainer
+	for len(p.idle) > 0 && c == nil {
+		c, p.idle = p.idle[0], p.idle[1:]
+		if time.Since(c.started) > p.maxAge {
+			poolRuns.Inc("expired")
+			go removeContainer(context.Background(), p.docker, c.id)
+			c = nil
+		}
+	}
+	p.mu.Unlock()
+	select {
+	case p.refill <- struct{}{}:
+	default:
+	}
+	if c == nil {
+		poolRuns.Inc("miss")
+		return nil
+	}
+	poolRuns.Inc("hit")
+	return c
+}
+
+// fill starts containers until the pool is full.
+func (p *containerPool) fill(ctx context.Context) {
+	p.mu.Lock()
+	n := p.size - len(p.idle) - p.starting
+	p.starting += max(n, 0)
+	p.mu.Unlock()
+	for range n {
+		go func() {
+			c, err := p.start(ctx)
+			p.mu.Lock()
+			defer p.mu.Unlock()
+			p.starting--
+			if err != nil {
+				slog.ErrorContext(ctx, "sandbox pool: start container error", "err", err)
+				return
+			}
+			p.idle = append(p.idle, c)
+		}()
+	}
+}
+
+func (p *containerPool) start(ctx context.Context) (*warmContainer, error) {
+	img, err := p.docker.ImageInspect(ctx, p.image)
+	if err != nil {
+		return nil, fmt.Errorf("inspect %s: %w", p.image, err)
+	}
+	argv := append(append([]string(nil), img.Config.Entrypoint...), img.Config.Cmd...)
+	if len(argv) == 0 {
+		return nil, fmt.Errorf("image %s has no entrypoint or command", p.image)
+	}
+	host := p.host
+	// An anonymous volume, removed with the container, takes the input.
+	host.Mounts = append(append([]docker.Mount(nil), host.Mounts...), docker.Mount{Type: "volume", Target: "/app/input"})
+	id, err := p.docker.ContainerCreate(ctx, docker.ContainerConfig{
+		Image:      p.image,
+		Entrypoint: PoolIdleCommand[:1],
+		Cmd:        PoolIdleCommand[1:],
+		Labels:     map[string]string{PoolLabel: poolOwner()},
+		HostConfig: host,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("create warm container: %w", err)
+	}
+	if err := p.docker.ContainerStart(ctx, id); err != nil {
+		removeContainer(ctx, p.docker, id)
+		return nil, fmt.Errorf("start warm container: %w", err)
+	}
+	return &warmContainer{id: id, argv: argv, started: time.Now()}, nil
+}
+
+// check drops idle containers that have stopped or are past maxAge.
+func (p *containerPool) check(ctx context.Context) {
+	p.mu.Lock()
+	idle := append([]*warmContainer(nil), p.idle...)
+	p.mu.Unlock()
+	var drop []*warmContainer
+	for _, c := range idle {
+		if time.Since(c.started) > p.maxAge {
+			poolRuns.Inc("expired")
+			drop = append(drop, c)
+			continue
+		}
+		ct, err := p.docker.ContainerInspect(ctx, c.id)
+		if err != nil || !ct.State.Running {
+			poolRuns.Inc("unhealthy")
+			drop = append(drop, c)
+		}
+	}
+	if len(drop) == 0 {
+		return
+	}
+	p.mu.Lock()
+	p.idle = without(p.idle, drop)
+	p.mu.Unlock()
+	for _, c := range drop {
+		removeContainer(ctx, p.docker, c.id)
+	}
+}
+
+func without(idle, drop []*warmContainer) []*warmContainer {
+	kept := idle[:0]
+	for _, c := range idle {
+		dropped := false
+		for _, d := range drop {
+			dropped = dropped || c == d
+		}
+		if !dropped {
+			kept = append(kept, c)
+		}
+	}
+	return kept
+}
+
+// load copies the run's input directory into the container.
+func (c *warmContainer) load(ctx context.Context, dc *docker.Client, dir string) error {
+	archive := docker.TarDir(dir)
+	defer archive.Close()
+	if err := dc.CopyTo(ctx, c.id, "/app/input", archive); err != nil {
+		return fmt.Errorf("copy input to warm container: %w", err)
+	}
+	return nil
+}
+
+// run execs the image's entrypoint in the container, writes stdin, if
+// any, to it, copies its output until it exits and returns how it ended.
+func (c *warmContainer) run(ctx context.Context, dc *docker.Client, stdin []byte, stdout, stderr io.Writer) (exitStatus, error) {
+	status := exitStatus{code: -1}
+	cfg := docker.ExecConfig{Cmd: c.argv}
+	if stdin != nil {
+		cfg.Env, cfg.AttachStdin = []string{stdinEnv}, true
+	}
+	execID, err := dc.ExecCreate(ctx, c.id, cfg)
+	if err != nil {
+		return status, err
+	}
+	stream, err := dc.ExecStart(ctx, execID)
+	if err != nil {
+		return status, err
+	}
+	defer stream.Close()
+	if stdin != nil {
+		writeStdin(stream, stdin)
+	}
+	if err := stream.Copy(ctx, stdout, stderr); err != nil {
+		return status, err
+	}
+	if _, status.code, err = dc.ExecInspect(ctx, execID); err != nil {
+		return exitStatus{code: -1}, err
+	}
+	if ct, err := dc.ContainerInspect(ctx, c.id); err == nil {
+		status.oomKilled = ct.State.OOMKilled
+	}
+	return status, status.err()
+}
+
+// removeContainer removes id even if ctx is gone.
+func removeContainer(ctx context.Context, dc *docker.Client, id string) {
+	rctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 30*time.Second)
+	defer cancel()
+	dc.ContainerRemove(rctx, id)
+}
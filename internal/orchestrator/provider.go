@@ -0,0 +1,302 @@
+package orchestrator
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/shivansh-source/nopass/internal/types"
+)
+
+// Provider runners call a hosted or self-hosted model API directly instead
+// of starting a Docker sandbox, for deployments without Docker. The prompt
+// is the same sandbox prompt and the answer still goes through output
+// safety; only the isolation of the model process is given up.
+
+// ErrUnknownProvider means a request asked for a provider that isn't
+// configured.
+var ErrUnknownProvider = errors.New("unknown model provider")
+
+// ProviderConfig configures one model API.
+type ProviderConfig struct {
+	// Kind is "openai", "anthropic", "ollama" or "vllm" (vLLM serves the
+	// OpenAI API).
+	Kind    string
+	URL     string // API base URL, e.g. "https://api.openai.com"
+	APIKey  string
+	Model   string // used when the request doesn't name one
+	Timeout time.Duration
+}
+
+// ProviderRunner runs prompts against a model API.
+type ProviderRunner struct {
+	cfg        ProviderConfig
+	HTTPClient *http.Client
+}
+
+// NewProviderRunner checks cfg and creates a runner for it.
+func NewProviderRunner(cfg ProviderConfig) (*ProviderRunner, error) {
+	switch cfg.Kind {
+	case "openai", "anthropic", "ollama", "vllm":
+	default:
+		return nil, fmt.Errorf("provider kind must be openai, anthropic, ollama or vllm, got %q", cfg.Kind)
+	}
+	u, err := url.Parse(cfg.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("provider url must be an http(s) URL, got %q", cfg.URL)
+	}
+	if cfg.Model == "" {
+		return nil, errors.New("provider model is required")
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 60 * time.Second
+	}
+	cfg.URL = strings.TrimSuffix(cfg.URL, "/")
+	return &ProviderRunner{cfg: cfg, HTTPClient: &http.Client{Timeout: cfg.Timeout}}, nil
+}
+
+// RunInSandbox sends the prompt to the provider. The receipt records wall
+// time and output size; CPU and memory aren't measurable from here.
+func (p *ProviderRunner) RunInSandbox(ctx context.Context, systemPrompt, userContent string) (string, error) {
+	g := GenerationFrom(ctx)
+	if g == nil {
+		g = &types.GenerationParams{}
+	}
+	model := g.Model
+	if model == "" {
+		model = p.cfg.Model
+	}
+
+	start := time.Now()
+	var answer string
+	var err error
+	switch p.cfg.Kind {
+	case "anthropic":
+		answer, err = p.anthropic(ctx, model, systemPrompt, userContent, g)
+	case "ollama":
+		answer, err = p.ollama(ctx, model, systemPrompt, userContent, g)
+	default:
+		answer, err = p.openAI(ctx, model, systemPrompt, userContent, g)
+	}
+	if err != nil {
+		return "", fmt.Errorf("%s provider: %w", p.cfg.Kind, err)
+	}
+	if rc := ReceiptFrom(ctx); rc != nil {
+		rc.Image = p.cfg.Kind + ":" + model
+		rc.StartedAt = start.UTC()
+		rc.WallTimeMs = time.Since(start).Milliseconds()
+		rc.OutputBytes = len(answer)
+	}
+	return answer, nil
+}
+
+type chatMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+func (p *ProviderRunner) openAI(ctx context.Context, model, system, user string, g *types.GenerationParams) (string, error) {
+	body := map[string]any{
+		"model":    model,
+		"messages": []chatMessage{{"system", system}, {"user", user}},
+	}
+	if g.Temperature != nil {
+		body["temperature"] = *g.Temperature
+	}
+	if g.TopP != nil {
+		body["top_p"] = *g.TopP
+	}
+	if g.MaxTokens > 0 {
+		body["max_tokens"] = g.MaxTokens
+	}
+	if len(g.Stop) > 0 {
+		body["stop"] = g.Stop
+	}
+	var resp struct {
+		Choices []struct {
+			Message chatMessage `json:"message"`
+		} `json:"choices"`
+	}
+	header := http.Header{}
+	if p.cfg.APIKey != "" {
+		header.Set("Authorization", "Bearer "+p.cfg.APIKey)
+	}
+	if err := p.post(ctx, "/v1/chat/completions", header, body, &resp); err != nil {
+		return "", err
+	}
+	if len(resp.Choices) == 0 {
+		return "", errors.New("response has no choices")
+	}
+	return resp.Choices[0].Message.Content, nil
+}
+
+// anthropicMaxTokens is sent when the request sets no limit; the Messages
+// API requires one.
+const anthropicMaxTokens = 1024
+
+func (p *ProviderRunner) anthropic(ctx context.Context, model, system, user string, g *types.GenerationParams) (string, error) {
+	body := map[string]any{
+		"model":      model,
+		"system":     system,
+		"messages":   []chatMessage{{"user", user}},
+		"max_tokens": anthropicMaxTokens,
+	}
+	if g.MaxTokens > 0 {
+		body["max_tokens"] = g.MaxTokens
+	}
+	if g.Temperature != nil {
+		// The Messages API accepts 0..1; OpenAI-style values are scaled.
+		body["temperature"] = *g.Temperature / 2
+	}
+	if g.TopP != nil {
+		body["top_p"] = *g.TopP
+	}
+	if len(g.Stop) > 0 {
+		body["stop_sequences"] = g.Stop
+	}
+	var resp struct {
+		Content []struct {
+			Type string `json:"type"`
+			Text string `json:"text"`
+		} `json:"content"`
+	}
+	header := http.Header{}
+	header.Set("x-api-key", p.cfg.APIKey)
+	header.Set("anthropic-version", "2023-06-01")
+	if err := p.post(ctx, "/v1/messages", header, body, &resp); err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	for _, c := range resp.Content {
+		if c.Type == "text" {
+			b.WriteString(c.Text)
+		}
+	}
+	return b.String(), nil
+}
+
+func (p *ProviderRunner) ollama(ctx context.Context, model, system, user string, g *types.GenerationParams) (string, error) {
+	options := map[string]any{}
+	if g.Temperature != nil {
+		options["temperature"] = *g.Temperature
+	}
+	if g.TopP != nil {
+		options["top_p"] = *g.TopP
+	}
+	if g.MaxTokens > 0 {
+		options["num_predict"] = g.MaxTokens
+	}
+	if len(g.Stop) > 0 {
+		options["stop"] = g.Stop
+	}
+	body := map[string]any{
+		"model":    model,
+		"messages": []chatMessage{{"system", system}, {"user", user}},
+		"stream":   false,
+		"options":  options,
+	}
+	var resp struct {
+		Message chatMessage `json:"message"`
+	}
+	if err := p.post(ctx, "/api/chat", nil, body, &resp); err != nil {
+		return "", err
+	}
+	return resp.Message.Content, nil
+}
+
+func (p *ProviderRunner) post(ctx context.Context, path string, header http.Header, body, out any) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return fmt.Errorf("marshal request: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.cfg.URL+path, bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("create request: %w", err)
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := p.HTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("status %d: %s", resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("decode response: %w", err)
+	}
+	return nil
+}
+
+// Hosted reports whether the provider is a third-party API rather than a
+// model the deployment serves itself (Ollama, vLLM).
+func (p *ProviderRunner) Hosted() bool {
+	return p.cfg.Kind == "openai" || p.cfg.Kind == "anthropic"
+}
+
+// ProviderRouter sends each run to the provider named in the request's
+// GenerationParams.Provider, or to Default when it names none ("sandbox"
+// also selects Default explicitly). Runs are vetted against the request's
+// route check (WithRouteCheck) before they start.
+type ProviderRouter struct {
+	Default Runner
+	// DefaultName is the provider Default is, or "" for the Docker
+	// sandbox.
+	DefaultName string
+	Providers   map[string]Runner
+}
+
+func (r *ProviderRouter) pick(ctx context.Context) (Runner, error) {
+	g := GenerationFrom(ctx)
+	name, run := r.DefaultName, r.Default
+	if g != nil && g.Provider != "" && g.Provider != "sandbox" {
+		p, ok := r.Providers[g.Provider]
+		if !ok {
+			return nil, fmt.Errorf("%w %q", ErrUnknownProvider, g.Provider)
+		}
+		name, run = g.Provider, p
+	}
+	rt := Route{Provider: name}
+	if name == "" {
+		rt.Provider = "sandbox"
+	}
+	if p, ok := run.(*ProviderRunner); ok {
+		rt.Hosted, rt.Model = p.Hosted(), p.cfg.Model
+	}
+	if g != nil && g.Model != "" {
+		rt.Model = g.Model
+	}
+	if err := checkRoute(ctx, rt); err != nil {
+		return nil, err
+	}
+	return run, nil
+}
+
+func (r *ProviderRouter) RunInSandbox(ctx context.Context, systemPrompt, userContent string) (string, error) {
+	run, err := r.pick(ctx)
+	if err != nil {
+		return "", err
+	}
+	return run.RunInSandbox(ctx, systemPrompt, userContent)
+}
+
+// RunInSandboxStream keeps the Docker runner's streaming mode; providers
+// deliver their answer as a single chunk.
+func (r *ProviderRouter) RunInSandboxStream(ctx context.Context, systemPrompt, userContent string, onChunk func(string) error) (string, error) {
+	run, err := r.pick(ctx)
+	if err != nil {
+		return "", err
+	}
+	return RunStream(ctx, run, systemPrompt, userContent, onChunk)
+}
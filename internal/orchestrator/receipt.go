@@ -0,0 +1,106 @@
+//go:build !minimal
+
+package orchestrator
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"strings"
+	"sync"
+
+	"github.com/shivansh-source/nopass/internal/docker"
+)
+
+// usageMarker prefixes the resource usage line the sandbox entrypoint
+// prints to stderr just before exiting (see python/llm_sandbox/run_llm.py).
+const usageMarker = "NOPASS_USAGE "
+
+type sandboxUsage struct {
+	CPUTimeMs       int64 `json:"cpu_time_ms"`
+	PeakMemoryBytes int64 `json:"peak_memory_bytes"`
+}
+
+// parseUsage extracts the usage report from the sandbox's stderr. Images
+// that don't report usage leave the fields zero.
+func parseUsage(stderr string) (sandboxUsage, bool) {
+	var u sandboxUsage
+	found := false
+	sc := bufio.NewScanner(strings.NewReader(stderr))
+	for sc.Scan() {
+		line := sc.Text()
+		if !strings.HasPrefix(line, usageMarker) {
+			continue
+		}
+		if json.Unmarshal([]byte(line[len(usageMarker):]), &u) == nil {
+			found = true
+		}
+	}
+	return u, found
+}
+
+// digestCache remembers the image ID behind each image reference, so
+// receipts record exactly what ran without an inspect call per run.
+type digestCache struct {
+	mu sync.Mutex
+	m  map[string]string
+}
+
+// labelCache remembers image labels, so the output protocol an image
+// declares costs one inspect call per image.
+type labelCache struct {
+	mu sync.Mutex
+	m  map[string]string
+}
+
+// label returns the value of image's label key, or "" if it has none or
+// can't be inspected. Failures aren't cached, so a later pull is seen.
+func (c *labelCache) label(ctx context.Context, dc *docker.Client, image, key string) string {
+	k := image + "\x00" + key
+	c.mu.Lock()
+	v, ok := c.m[k]
+	c.mu.Unlock()
+	if ok {
+		return v
+	}
+
+	img, err := dc.ImageInspect(ctx, image)
+	if err != nil {
+		return ""
+	}
+	v = img.Config.Labels[key]
+	c.mu.Lock()
+	if c.m == nil {
+		c.m = make(map[string]string)
+	}
+	c.m[k] = v
+	c.mu.Unlock()
+	return v
+}
+
+// digest returns the content digest of image: the pinned digest if the
+// reference has one, otherwise the local image ID.
+func (c *digestCache) digest(ctx context.Context, dc *docker.Client, image string) string {
+	if _, d, ok := strings.Cut(image, "@"); ok {
+		return d
+	}
+	c.mu.Lock()
+	d, ok := c.m[image]
+	c.mu.Unlock()
+	if ok {
+		return d
+	}
+
+	img, err := dc.ImageInspect(ctx, image)
+	if err != nil {
+		return ""
+	}
+	d = img.ID
+	c.mu.Lock()
+	if c.m == nil {
+		c.m = make(map[string]string)
+	}
+	c.m[image] = d
+	c.mu.Unlock()
+	return d
+}
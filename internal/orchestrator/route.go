@@ -0,0 +1,44 @@
+package orchestrator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// ErrModelNotAllowed means the request's tenant may not use the backend
+// it would have been routed to.
+var ErrModelNotAllowed = errors.New("model not allowed")
+
+// Route is where a run is about to go.
+type Route struct {
+	Provider string // "sandbox" for the Docker sandbox, else a provider's name
+	Model    string // "" when the backend picks, as the sandbox image does
+	// Hosted marks third-party model APIs, as opposed to models the
+	// deployment runs itself.
+	Hosted bool
+}
+
+// RouteCheck vets a route; a non-nil error, wrapped in
+// ErrModelNotAllowed, stops the run.
+type RouteCheck func(Route) error
+
+type routeCheckKey struct{}
+
+// WithRouteCheck has the upcoming run's route vetted by check before it
+// starts.
+func WithRouteCheck(ctx context.Context, check RouteCheck) context.Context {
+	return context.WithValue(ctx, routeCheckKey{}, check)
+}
+
+// checkRoute applies the check attached with WithRouteCheck, if any.
+func checkRoute(ctx context.Context, rt Route) error {
+	check, _ := ctx.Value(routeCheckKey{}).(RouteCheck)
+	if check == nil {
+		return nil
+	}
+	if err := check(rt); err != nil {
+		return fmt.Errorf("%w: %v", ErrModelNotAllowed, err)
+	}
+	return nil
+}
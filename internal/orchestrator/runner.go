@@ -0,0 +1,38 @@
+package orchestrator
+
+import "context"
+
+// Runner is anything that can execute a sandboxed LLM call and return the
+// draft answer. LLMRunner (local Docker) and FleetRunner (remote runner
+// hosts) both implement it.
+type Runner interface {
+	RunInSandbox(ctx context.Context, systemPrompt, userContent string) (string, error)
+}
+
+// StreamingRunner is a Runner that can hand over the draft answer while the
+// model is still producing it. onChunk receives each new piece of output in
+// order; chunks never split a UTF-8 sequence. If onChunk returns an error
+// the run is stopped and that error is returned. The full draft is returned
+// as with RunInSandbox.
+type StreamingRunner interface {
+	Runner
+	RunInSandboxStream(ctx context.Context, systemPrompt, userContent string, onChunk func(string) error) (string, error)
+}
+
+// RunStream runs through r's streaming mode if it has one. Other runners
+// (e.g. FleetRunner) deliver their whole answer as a single chunk.
+func RunStream(ctx context.Context, r Runner, systemPrompt, userContent string, onChunk func(string) error) (string, error) {
+	if sr, ok := r.(StreamingRunner); ok {
+		return sr.RunInSandboxStream(ctx, systemPrompt, userContent, onChunk)
+	}
+	answer, err := r.RunInSandbox(ctx, systemPrompt, userContent)
+	if err != nil {
+		return "", err
+	}
+	if answer != "" {
+		if err := onChunk(answer); err != nil {
+			return "", err
+		}
+	}
+	return answer, nil
+}
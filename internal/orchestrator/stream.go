@@ -0,0 +1,69 @@
+package orchestrator
+
+import (
+	"bytes"
+	"context"
+	"unicode/utf8"
+)
+
+// chunkWriter is the sandbox's stdout in streaming mode: it keeps the full
+// output and passes every complete UTF-8 prefix to onChunk as it arrives.
+// When onChunk fails, the run is cancelled and the error kept for the
+// caller.
+type chunkWriter struct {
+	out     bytes.Buffer
+	sent    int
+	onChunk func(string) error
+	cancel  context.CancelFunc
+	// holdEcho keeps a possible echo footer from being streamed.
+	holdEcho bool
+	err      error
+}
+
+func (c *chunkWriter) Write(p []byte) (int, error) {
+	if c.err != nil {
+		return 0, c.err
+	}
+	c.out.Write(p)
+	pending := c.out.Bytes()[c.sent:]
+	n := len(pending)
+	// Hold back a trailing partial rune until the rest of it arrives.
+	for i := 1; i <= utf8.UTFMax && i <= n; i++ {
+		if utf8.RuneStart(pending[n-i]) {
+			if !utf8.FullRune(pending[n-i:]) {
+				n -= i
+			}
+			break
+		}
+	}
+	if c.holdEcho {
+		n = max(holdEcho(c.out.Bytes(), c.sent+n)-c.sent, 0)
+	}
+	if n == 0 {
+		return len(p), nil
+	}
+	if err := c.onChunk(string(pending[:n])); err != nil {
+		c.err = err
+		c.cancel()
+		return 0, err
+	}
+	c.sent += n
+	return len(p), nil
+}
+
+// flush delivers whatever is left of the first end bytes, e.g. an invalid
+// trailing byte sequence.
+func (c *chunkWriter) flush(end int) error {
+	if c.err != nil || c.sent >= end {
+		return c.err
+	}
+	if err := c.onChunk(string(c.out.Bytes()[c.sent:end])); err != nil {
+		c.err = err
+		return err
+	}
+	c.sent = end
+	return nil
+}
+
+func (c *chunkWriter) String() string { return c.out.String() }
+func (c *chunkWriter) Len() int       { return c.out.Len() }
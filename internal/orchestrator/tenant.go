@@ -0,0 +1,78 @@
+package orchestrator
+
+import (
+	"fmt"
+	"strings"
+)
+
+// TenantImage is a private sandbox image, optionally pinned by digest.
+type TenantImage struct {
+	Image  string // e.g. "registry.example.com/acme/llm"
+	Digest string // e.g. "sha256:…"; when set the image is run by digest
+}
+
+// Ref returns the reference a run's container is created from. Pinning by digest means a
+// re-tagged or tampered image can never be picked up silently.
+func (t TenantImage) Ref() string {
+	if t.Digest == "" {
+		return t.Image
+	}
+	return t.Image + "@" + t.Digest
+}
+
+// ImagePolicy maps tenants to their private sandbox images. Tenants without
+// an entry use the shared default image; a private image is never used for
+// any tenant other than its owner.
+type ImagePolicy struct {
+	Shared  string
+	Tenants map[string]TenantImage
+}
+
+// Validate rejects configurations where isolation could be violated, such
+// as a private image doubling as the shared default or two tenants sharing
+// one private image.
+func (p *ImagePolicy) Validate() error {
+	owners := make(map[string]string)
+	for tenant, img := range p.Tenants {
+		if img.Image == "" {
+			return fmt.Errorf("tenant %s: image is required", tenant)
+		}
+		if img.Image == p.Shared {
+			return fmt.Errorf("tenant %s: private image %s is also the shared image", tenant, img.Image)
+		}
+		if other, ok := owners[img.Image]; ok {
+			return fmt.Errorf("tenants %s and %s share private image %s", other, tenant, img.Image)
+		}
+		owners[img.Image] = tenant
+	}
+	return nil
+}
+
+// ImageFor returns the image reference a tenant's runs must use.
+func (p *ImagePolicy) ImageFor(tenantID string) string {
+	if img, ok := p.Tenants[tenantID]; ok && tenantID != "" {
+		return img.Ref()
+	}
+	return p.Shared
+}
+
+// ParseTenantImages parses "tenant=image[@digest],tenant2=image2@digest".
+func ParseTenantImages(spec string) (map[string]TenantImage, error) {
+	out := make(map[string]TenantImage)
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		tenant, ref, ok := strings.Cut(entry, "=")
+		if !ok || tenant == "" || ref == "" {
+			return nil, fmt.Errorf("tenant image entry %q: want tenant=image[@digest]", entry)
+		}
+		img, digest, _ := strings.Cut(ref, "@")
+		if digest != "" && !strings.HasPrefix(digest, "sha256:") {
+			return nil, fmt.Errorf("tenant image entry %q: digest must be sha256:…", entry)
+		}
+		out[tenant] = TenantImage{Image: img, Digest: digest}
+	}
+	return out, nil
+}
@@ -0,0 +1,70 @@
+package pb_test
+
+import (
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+
+	"google.golang.org/protobuf/proto"
+
+	nopassv1 "github.com/shivansh-source/nopass/internal/pb/nopass/v1"
+	"github.com/shivansh-source/nopass/internal/types"
+)
+
+// TestJSONNamesMatch checks that each hand-written JSON type in
+// internal/types has the same field names as its message in
+// proto/nopass/v1, so a field added to one side only fails here.
+func TestJSONNamesMatch(t *testing.T) {
+	tests := []struct {
+		msg  proto.Message
+		json any
+	}{
+		{&nopassv1.ChatRequest{}, types.ChatRequest{}},
+		{&nopassv1.ExternalData{}, types.ExternalData{}},
+		{&nopassv1.Turn{}, types.Turn{}},
+		{&nopassv1.RetrieveSpec{}, types.RetrieveSpec{}},
+		{&nopassv1.GenerationParams{}, types.GenerationParams{}},
+		{&nopassv1.ChatResponse{}, types.ChatResponse{}},
+		{&nopassv1.Citation{}, types.Citation{}},
+		{&nopassv1.DataBlockStatus{}, types.DataBlockStatus{}},
+		{&nopassv1.SandboxReceipt{}, types.SandboxReceipt{}},
+		{&nopassv1.DataClasses{}, types.DataClasses{}},
+		{&nopassv1.SessionRisk{}, types.SessionRisk{}},
+		{&nopassv1.MaskedSpan{}, types.MaskedSpan{}},
+		{&nopassv1.Artifact{}, types.Artifact{}},
+		{&nopassv1.StageFailure{}, types.StageFailure{}},
+		{&nopassv1.RiskRequest{}, types.RiskRequest{}},
+		{&nopassv1.RiskResponse{}, types.RiskResponse{}},
+		{&nopassv1.RiskBatchRequest{}, types.RiskBatchRequest{}},
+		{&nopassv1.RiskBatchResponse{}, types.RiskBatchResponse{}},
+		{&nopassv1.RiskSection{}, types.RiskSection{}},
+		{&nopassv1.RiskSectionVerdict{}, types.RiskSectionVerdict{}},
+		{&nopassv1.OutputSafetyRequest{}, types.OutputSafetyRequest{}},
+		{&nopassv1.DataSource{}, types.DataSource{}},
+		{&nopassv1.OutputSafetyResponse{}, types.OutputSafetyResponse{}},
+	}
+	for _, tt := range tests {
+		desc := tt.msg.ProtoReflect().Descriptor()
+		t.Run(string(desc.Name()), func(t *testing.T) {
+			var want []string
+			fields := desc.Fields()
+			for i := 0; i < fields.Len(); i++ {
+				want = append(want, fields.Get(i).JSONName())
+			}
+			var got []string
+			typ := reflect.TypeOf(tt.json)
+			for i := 0; i < typ.NumField(); i++ {
+				name, _, _ := strings.Cut(typ.Field(i).Tag.Get("json"), ",")
+				if name != "" && name != "-" {
+					got = append(got, name)
+				}
+			}
+			slices.Sort(want)
+			slices.Sort(got)
+			if !slices.Equal(got, want) {
+				t.Errorf("types.%s JSON fields = %v, proto fields = %v", typ.Name(), got, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,13 @@
+// Package pb holds the Go code generated from the protobuf contracts in
+// proto/nopass/v1, checked in so the gateway builds without buf. The
+// gateway's gRPC transport (ChatService) uses it; the JSON transport is
+// still served by the hand-written structs in internal/types, and a test
+// here checks that both agree on every field's JSON name.
+//
+// Regenerate after changing a .proto file, with buf, protoc-gen-go and
+// protoc-gen-go-grpc on PATH:
+//
+//	go generate ./internal/pb
+package pb
+
+//go:generate sh -c "cd ../.. && buf generate proto --template proto/buf.gen.yaml"
@@ -0,0 +1,1523 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.12
+// 	protoc        (unknown)
+// source: nopass/v1/chat.proto
+
+package nopassv1
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type ChatRequest struct {
+	state    protoimpl.MessageState `protogen:"open.v1"`
+	TenantId string                 `protobuf:"bytes,1,opt,name=tenant_id,proto3" json:"tenant_id,omitempty"`
+	// Selects a policy profile when the gateway doesn't authenticate
+	// callers; an API key's profile always wins.
+	PolicyProfile string          `protobuf:"bytes,2,opt,name=policy_profile,proto3" json:"policy_profile,omitempty"`
+	UserId        string          `protobuf:"bytes,3,opt,name=user_id,proto3" json:"user_id,omitempty"`
+	SessionId     string          `protobuf:"bytes,4,opt,name=session_id,proto3" json:"session_id,omitempty"`
+	Message       string          `protobuf:"bytes,5,opt,name=message,proto3" json:"message,omitempty"`
+	ExternalData  []*ExternalData `protobuf:"bytes,6,rep,name=external_data,proto3" json:"external_data,omitempty"`
+	// IDs from POST /v1/data.
+	DataRefs []string `protobuf:"bytes,7,rep,name=data_refs,proto3" json:"data_refs,omitempty"`
+	// Earlier turns, oldest first.
+	History      []*Turn       `protobuf:"bytes,8,rep,name=history,proto3" json:"history,omitempty"`
+	ResetSession bool          `protobuf:"varint,9,opt,name=reset_session,proto3" json:"reset_session,omitempty"`
+	Retrieve     *RetrieveSpec `protobuf:"bytes,10,opt,name=retrieve,proto3" json:"retrieve,omitempty"`
+	// "interactive", "batch" or "eval"; only keys allowed to choose.
+	Priority string `protobuf:"bytes,11,opt,name=priority,proto3" json:"priority,omitempty"`
+	// Answer as Server-Sent Events; JSON transport only.
+	Stream        bool              `protobuf:"varint,12,opt,name=stream,proto3" json:"stream,omitempty"`
+	Generation    *GenerationParams `protobuf:"bytes,13,opt,name=generation,proto3" json:"generation,omitempty"`
+	MaskSpans     bool              `protobuf:"varint,14,opt,name=mask_spans,proto3" json:"mask_spans,omitempty"`
+	Locale        string            `protobuf:"bytes,15,opt,name=locale,proto3" json:"locale,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ChatRequest) Reset() {
+	*x = ChatRequest{}
+	mi := &file_nopass_v1_chat_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ChatRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ChatRequest) ProtoMessage() {}
+
+func (x *ChatRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_nopass_v1_chat_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ChatRequest.ProtoReflect.Descriptor instead.
+func (*ChatRequest) Descriptor() ([]byte, []int) {
+	return file_nopass_v1_chat_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *ChatRequest) GetTenantId() string {
+	if x != nil {
+		return x.TenantId
+	}
+	return ""
+}
+
+func (x *ChatRequest) GetPolicyProfile() string {
+	if x != nil {
+		return x.PolicyProfile
+	}
+	return ""
+}
+
+func (x *ChatRequest) GetUserId() string {
+	if x != nil {
+		return x.UserId
+	}
+	return ""
+}
+
+func (x *ChatRequest) GetSessionId() string {
+	if x != nil {
+		return x.SessionId
+	}
+	return ""
+}
+
+func (x *ChatRequest) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
+}
+
+func (x *ChatRequest) GetExternalData() []*ExternalData {
+	if x != nil {
+		return x.ExternalData
+	}
+	return nil
+}
+
+func (x *ChatRequest) GetDataRefs() []string {
+	if x != nil {
+		return x.DataRefs
+	}
+	return nil
+}
+
+func (x *ChatRequest) GetHistory() []*Turn {
+	if x != nil {
+		return x.History
+	}
+	return nil
+}
+
+func (x *ChatRequest) GetResetSession() bool {
+	if x != nil {
+		return x.ResetSession
+	}
+	return false
+}
+
+func (x *ChatRequest) GetRetrieve() *RetrieveSpec {
+	if x != nil {
+		return x.Retrieve
+	}
+	return nil
+}
+
+func (x *ChatRequest) GetPriority() string {
+	if x != nil {
+		return x.Priority
+	}
+	return ""
+}
+
+func (x *ChatRequest) GetStream() bool {
+	if x != nil {
+		return x.Stream
+	}
+	return false
+}
+
+func (x *ChatRequest) GetGeneration() *GenerationParams {
+	if x != nil {
+		return x.Generation
+	}
+	return nil
+}
+
+func (x *ChatRequest) GetMaskSpans() bool {
+	if x != nil {
+		return x.MaskSpans
+	}
+	return false
+}
+
+func (x *ChatRequest) GetLocale() string {
+	if x != nil {
+		return x.Locale
+	}
+	return ""
+}
+
+type ExternalData struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Source        string                 `protobuf:"bytes,2,opt,name=source,proto3" json:"source,omitempty"`
+	Type          string                 `protobuf:"bytes,3,opt,name=type,proto3" json:"type,omitempty"`
+	Content       string                 `protobuf:"bytes,4,opt,name=content,proto3" json:"content,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ExternalData) Reset() {
+	*x = ExternalData{}
+	mi := &file_nopass_v1_chat_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ExternalData) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ExternalData) ProtoMessage() {}
+
+func (x *ExternalData) ProtoReflect() protoreflect.Message {
+	mi := &file_nopass_v1_chat_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ExternalData.ProtoReflect.Descriptor instead.
+func (*ExternalData) Descriptor() ([]byte, []int) {
+	return file_nopass_v1_chat_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *ExternalData) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *ExternalData) GetSource() string {
+	if x != nil {
+		return x.Source
+	}
+	return ""
+}
+
+func (x *ExternalData) GetType() string {
+	if x != nil {
+		return x.Type
+	}
+	return ""
+}
+
+func (x *ExternalData) GetContent() string {
+	if x != nil {
+		return x.Content
+	}
+	return ""
+}
+
+type Turn struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// "user" or "assistant".
+	Role          string `protobuf:"bytes,1,opt,name=role,proto3" json:"role,omitempty"`
+	Content       string `protobuf:"bytes,2,opt,name=content,proto3" json:"content,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Turn) Reset() {
+	*x = Turn{}
+	mi := &file_nopass_v1_chat_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Turn) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Turn) ProtoMessage() {}
+
+func (x *Turn) ProtoReflect() protoreflect.Message {
+	mi := &file_nopass_v1_chat_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Turn.ProtoReflect.Descriptor instead.
+func (*Turn) Descriptor() ([]byte, []int) {
+	return file_nopass_v1_chat_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *Turn) GetRole() string {
+	if x != nil {
+		return x.Role
+	}
+	return ""
+}
+
+func (x *Turn) GetContent() string {
+	if x != nil {
+		return x.Content
+	}
+	return ""
+}
+
+type RetrieveSpec struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Query         string                 `protobuf:"bytes,1,opt,name=query,proto3" json:"query,omitempty"`
+	Sources       []string               `protobuf:"bytes,2,rep,name=sources,proto3" json:"sources,omitempty"`
+	Limit         int32                  `protobuf:"varint,3,opt,name=limit,proto3" json:"limit,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RetrieveSpec) Reset() {
+	*x = RetrieveSpec{}
+	mi := &file_nopass_v1_chat_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RetrieveSpec) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RetrieveSpec) ProtoMessage() {}
+
+func (x *RetrieveSpec) ProtoReflect() protoreflect.Message {
+	mi := &file_nopass_v1_chat_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RetrieveSpec.ProtoReflect.Descriptor instead.
+func (*RetrieveSpec) Descriptor() ([]byte, []int) {
+	return file_nopass_v1_chat_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *RetrieveSpec) GetQuery() string {
+	if x != nil {
+		return x.Query
+	}
+	return ""
+}
+
+func (x *RetrieveSpec) GetSources() []string {
+	if x != nil {
+		return x.Sources
+	}
+	return nil
+}
+
+func (x *RetrieveSpec) GetLimit() int32 {
+	if x != nil {
+		return x.Limit
+	}
+	return 0
+}
+
+type GenerationParams struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Provider      string                 `protobuf:"bytes,1,opt,name=provider,proto3" json:"provider,omitempty"`
+	Model         string                 `protobuf:"bytes,2,opt,name=model,proto3" json:"model,omitempty"`
+	Temperature   *float64               `protobuf:"fixed64,3,opt,name=temperature,proto3,oneof" json:"temperature,omitempty"`
+	TopP          *float64               `protobuf:"fixed64,4,opt,name=top_p,proto3,oneof" json:"top_p,omitempty"`
+	MaxTokens     int32                  `protobuf:"varint,5,opt,name=max_tokens,proto3" json:"max_tokens,omitempty"`
+	Stop          []string               `protobuf:"bytes,6,rep,name=stop,proto3" json:"stop,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GenerationParams) Reset() {
+	*x = GenerationParams{}
+	mi := &file_nopass_v1_chat_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GenerationParams) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GenerationParams) ProtoMessage() {}
+
+func (x *GenerationParams) ProtoReflect() protoreflect.Message {
+	mi := &file_nopass_v1_chat_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GenerationParams.ProtoReflect.Descriptor instead.
+func (*GenerationParams) Descriptor() ([]byte, []int) {
+	return file_nopass_v1_chat_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *GenerationParams) GetProvider() string {
+	if x != nil {
+		return x.Provider
+	}
+	return ""
+}
+
+func (x *GenerationParams) GetModel() string {
+	if x != nil {
+		return x.Model
+	}
+	return ""
+}
+
+func (x *GenerationParams) GetTemperature() float64 {
+	if x != nil && x.Temperature != nil {
+		return *x.Temperature
+	}
+	return 0
+}
+
+func (x *GenerationParams) GetTopP() float64 {
+	if x != nil && x.TopP != nil {
+		return *x.TopP
+	}
+	return 0
+}
+
+func (x *GenerationParams) GetMaxTokens() int32 {
+	if x != nil {
+		return x.MaxTokens
+	}
+	return 0
+}
+
+func (x *GenerationParams) GetStop() []string {
+	if x != nil {
+		return x.Stop
+	}
+	return nil
+}
+
+type ChatResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	SchemaVersion int32                  `protobuf:"varint,1,opt,name=schema_version,proto3" json:"schema_version,omitempty"`
+	Answer        string                 `protobuf:"bytes,2,opt,name=answer,proto3" json:"answer,omitempty"`
+	RiskLevel     RiskLevel              `protobuf:"varint,3,opt,name=risk_level,proto3,enum=nopass.v1.RiskLevel" json:"risk_level,omitempty"`
+	Path          Path                   `protobuf:"varint,4,opt,name=path,proto3,enum=nopass.v1.Path" json:"path,omitempty"`
+	Notices       []string               `protobuf:"bytes,5,rep,name=notices,proto3" json:"notices,omitempty"`
+	Citations     []*Citation            `protobuf:"bytes,6,rep,name=citations,proto3" json:"citations,omitempty"`
+	DataStatus    []*DataBlockStatus     `protobuf:"bytes,7,rep,name=data_status,proto3" json:"data_status,omitempty"`
+	Receipt       *SandboxReceipt        `protobuf:"bytes,8,opt,name=receipt,proto3" json:"receipt,omitempty"`
+	DataClasses   *DataClasses           `protobuf:"bytes,9,opt,name=data_classes,proto3" json:"data_classes,omitempty"`
+	SessionId     string                 `protobuf:"bytes,10,opt,name=session_id,proto3" json:"session_id,omitempty"`
+	HistoryTurns  int32                  `protobuf:"varint,11,opt,name=history_turns,proto3" json:"history_turns,omitempty"`
+	SessionRisk   *SessionRisk           `protobuf:"bytes,12,opt,name=session_risk,proto3" json:"session_risk,omitempty"`
+	FeatureId     string                 `protobuf:"bytes,13,opt,name=feature_id,proto3" json:"feature_id,omitempty"`
+	MaskedSpans   []*MaskedSpan          `protobuf:"bytes,14,rep,name=masked_spans,proto3" json:"masked_spans,omitempty"`
+	Artifacts     []*Artifact            `protobuf:"bytes,15,rep,name=artifacts,proto3" json:"artifacts,omitempty"`
+	StageFailures []*StageFailure        `protobuf:"bytes,16,rep,name=stage_failures,proto3" json:"stage_failures,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ChatResponse) Reset() {
+	*x = ChatResponse{}
+	mi := &file_nopass_v1_chat_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ChatResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ChatResponse) ProtoMessage() {}
+
+func (x *ChatResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_nopass_v1_chat_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ChatResponse.ProtoReflect.Descriptor instead.
+func (*ChatResponse) Descriptor() ([]byte, []int) {
+	return file_nopass_v1_chat_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *ChatResponse) GetSchemaVersion() int32 {
+	if x != nil {
+		return x.SchemaVersion
+	}
+	return 0
+}
+
+func (x *ChatResponse) GetAnswer() string {
+	if x != nil {
+		return x.Answer
+	}
+	return ""
+}
+
+func (x *ChatResponse) GetRiskLevel() RiskLevel {
+	if x != nil {
+		return x.RiskLevel
+	}
+	return RiskLevel_RISK_LEVEL_UNSPECIFIED
+}
+
+func (x *ChatResponse) GetPath() Path {
+	if x != nil {
+		return x.Path
+	}
+	return Path_PATH_UNSPECIFIED
+}
+
+func (x *ChatResponse) GetNotices() []string {
+	if x != nil {
+		return x.Notices
+	}
+	return nil
+}
+
+func (x *ChatResponse) GetCitations() []*Citation {
+	if x != nil {
+		return x.Citations
+	}
+	return nil
+}
+
+func (x *ChatResponse) GetDataStatus() []*DataBlockStatus {
+	if x != nil {
+		return x.DataStatus
+	}
+	return nil
+}
+
+func (x *ChatResponse) GetReceipt() *SandboxReceipt {
+	if x != nil {
+		return x.Receipt
+	}
+	return nil
+}
+
+func (x *ChatResponse) GetDataClasses() *DataClasses {
+	if x != nil {
+		return x.DataClasses
+	}
+	return nil
+}
+
+func (x *ChatResponse) GetSessionId() string {
+	if x != nil {
+		return x.SessionId
+	}
+	return ""
+}
+
+func (x *ChatResponse) GetHistoryTurns() int32 {
+	if x != nil {
+		return x.HistoryTurns
+	}
+	return 0
+}
+
+func (x *ChatResponse) GetSessionRisk() *SessionRisk {
+	if x != nil {
+		return x.SessionRisk
+	}
+	return nil
+}
+
+func (x *ChatResponse) GetFeatureId() string {
+	if x != nil {
+		return x.FeatureId
+	}
+	return ""
+}
+
+func (x *ChatResponse) GetMaskedSpans() []*MaskedSpan {
+	if x != nil {
+		return x.MaskedSpans
+	}
+	return nil
+}
+
+func (x *ChatResponse) GetArtifacts() []*Artifact {
+	if x != nil {
+		return x.Artifacts
+	}
+	return nil
+}
+
+func (x *ChatResponse) GetStageFailures() []*StageFailure {
+	if x != nil {
+		return x.StageFailures
+	}
+	return nil
+}
+
+type Citation struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Index         int32                  `protobuf:"varint,1,opt,name=index,proto3" json:"index,omitempty"`
+	Url           string                 `protobuf:"bytes,2,opt,name=url,proto3" json:"url,omitempty"`
+	Title         string                 `protobuf:"bytes,3,opt,name=title,proto3" json:"title,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Citation) Reset() {
+	*x = Citation{}
+	mi := &file_nopass_v1_chat_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Citation) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Citation) ProtoMessage() {}
+
+func (x *Citation) ProtoReflect() protoreflect.Message {
+	mi := &file_nopass_v1_chat_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Citation.ProtoReflect.Descriptor instead.
+func (*Citation) Descriptor() ([]byte, []int) {
+	return file_nopass_v1_chat_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *Citation) GetIndex() int32 {
+	if x != nil {
+		return x.Index
+	}
+	return 0
+}
+
+func (x *Citation) GetUrl() string {
+	if x != nil {
+		return x.Url
+	}
+	return ""
+}
+
+func (x *Citation) GetTitle() string {
+	if x != nil {
+		return x.Title
+	}
+	return ""
+}
+
+// DataBlockStatus is what happened to one external data block: scanned,
+// flagged, scan_failed, excluded or unscanned.
+type DataBlockStatus struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Source        string                 `protobuf:"bytes,2,opt,name=source,proto3" json:"source,omitempty"`
+	Status        string                 `protobuf:"bytes,3,opt,name=status,proto3" json:"status,omitempty"`
+	Reason        string                 `protobuf:"bytes,4,opt,name=reason,proto3" json:"reason,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DataBlockStatus) Reset() {
+	*x = DataBlockStatus{}
+	mi := &file_nopass_v1_chat_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DataBlockStatus) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DataBlockStatus) ProtoMessage() {}
+
+func (x *DataBlockStatus) ProtoReflect() protoreflect.Message {
+	mi := &file_nopass_v1_chat_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DataBlockStatus.ProtoReflect.Descriptor instead.
+func (*DataBlockStatus) Descriptor() ([]byte, []int) {
+	return file_nopass_v1_chat_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *DataBlockStatus) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *DataBlockStatus) GetSource() string {
+	if x != nil {
+		return x.Source
+	}
+	return ""
+}
+
+func (x *DataBlockStatus) GetStatus() string {
+	if x != nil {
+		return x.Status
+	}
+	return ""
+}
+
+func (x *DataBlockStatus) GetReason() string {
+	if x != nil {
+		return x.Reason
+	}
+	return ""
+}
+
+type SandboxReceipt struct {
+	state           protoimpl.MessageState `protogen:"open.v1"`
+	Id              string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	TenantId        string                 `protobuf:"bytes,2,opt,name=tenant_id,proto3" json:"tenant_id,omitempty"`
+	RunnerId        string                 `protobuf:"bytes,3,opt,name=runner_id,proto3" json:"runner_id,omitempty"`
+	ContainerId     string                 `protobuf:"bytes,4,opt,name=container_id,proto3" json:"container_id,omitempty"`
+	Image           string                 `protobuf:"bytes,5,opt,name=image,proto3" json:"image,omitempty"`
+	ImageDigest     string                 `protobuf:"bytes,6,opt,name=image_digest,proto3" json:"image_digest,omitempty"`
+	StartedAt       *timestamppb.Timestamp `protobuf:"bytes,7,opt,name=started_at,proto3" json:"started_at,omitempty"`
+	WallTimeMs      int64                  `protobuf:"varint,8,opt,name=wall_time_ms,proto3" json:"wall_time_ms,omitempty"`
+	CpuTimeMs       int64                  `protobuf:"varint,9,opt,name=cpu_time_ms,proto3" json:"cpu_time_ms,omitempty"`
+	PeakMemoryBytes int64                  `protobuf:"varint,10,opt,name=peak_memory_bytes,proto3" json:"peak_memory_bytes,omitempty"`
+	ExitCode        int32                  `protobuf:"varint,11,opt,name=exit_code,proto3" json:"exit_code,omitempty"`
+	OomKilled       bool                   `protobuf:"varint,12,opt,name=oom_killed,proto3" json:"oom_killed,omitempty"`
+	OutputBytes     int32                  `protobuf:"varint,13,opt,name=output_bytes,proto3" json:"output_bytes,omitempty"`
+	OutputProtocol  string                 `protobuf:"bytes,14,opt,name=output_protocol,proto3" json:"output_protocol,omitempty"`
+	Model           string                 `protobuf:"bytes,15,opt,name=model,proto3" json:"model,omitempty"`
+	InputTokens     int32                  `protobuf:"varint,16,opt,name=input_tokens,proto3" json:"input_tokens,omitempty"`
+	OutputTokens    int32                  `protobuf:"varint,17,opt,name=output_tokens,proto3" json:"output_tokens,omitempty"`
+	Warnings        []string               `protobuf:"bytes,18,rep,name=warnings,proto3" json:"warnings,omitempty"`
+	unknownFields   protoimpl.UnknownFields
+	sizeCache       protoimpl.SizeCache
+}
+
+func (x *SandboxReceipt) Reset() {
+	*x = SandboxReceipt{}
+	mi := &file_nopass_v1_chat_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SandboxReceipt) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SandboxReceipt) ProtoMessage() {}
+
+func (x *SandboxReceipt) ProtoReflect() protoreflect.Message {
+	mi := &file_nopass_v1_chat_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SandboxReceipt.ProtoReflect.Descriptor instead.
+func (*SandboxReceipt) Descriptor() ([]byte, []int) {
+	return file_nopass_v1_chat_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *SandboxReceipt) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *SandboxReceipt) GetTenantId() string {
+	if x != nil {
+		return x.TenantId
+	}
+	return ""
+}
+
+func (x *SandboxReceipt) GetRunnerId() string {
+	if x != nil {
+		return x.RunnerId
+	}
+	return ""
+}
+
+func (x *SandboxReceipt) GetContainerId() string {
+	if x != nil {
+		return x.ContainerId
+	}
+	return ""
+}
+
+func (x *SandboxReceipt) GetImage() string {
+	if x != nil {
+		return x.Image
+	}
+	return ""
+}
+
+func (x *SandboxReceipt) GetImageDigest() string {
+	if x != nil {
+		return x.ImageDigest
+	}
+	return ""
+}
+
+func (x *SandboxReceipt) GetStartedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.StartedAt
+	}
+	return nil
+}
+
+func (x *SandboxReceipt) GetWallTimeMs() int64 {
+	if x != nil {
+		return x.WallTimeMs
+	}
+	return 0
+}
+
+func (x *SandboxReceipt) GetCpuTimeMs() int64 {
+	if x != nil {
+		return x.CpuTimeMs
+	}
+	return 0
+}
+
+func (x *SandboxReceipt) GetPeakMemoryBytes() int64 {
+	if x != nil {
+		return x.PeakMemoryBytes
+	}
+	return 0
+}
+
+func (x *SandboxReceipt) GetExitCode() int32 {
+	if x != nil {
+		return x.ExitCode
+	}
+	return 0
+}
+
+func (x *SandboxReceipt) GetOomKilled() bool {
+	if x != nil {
+		return x.OomKilled
+	}
+	return false
+}
+
+func (x *SandboxReceipt) GetOutputBytes() int32 {
+	if x != nil {
+		return x.OutputBytes
+	}
+	return 0
+}
+
+func (x *SandboxReceipt) GetOutputProtocol() string {
+	if x != nil {
+		return x.OutputProtocol
+	}
+	return ""
+}
+
+func (x *SandboxReceipt) GetModel() string {
+	if x != nil {
+		return x.Model
+	}
+	return ""
+}
+
+func (x *SandboxReceipt) GetInputTokens() int32 {
+	if x != nil {
+		return x.InputTokens
+	}
+	return 0
+}
+
+func (x *SandboxReceipt) GetOutputTokens() int32 {
+	if x != nil {
+		return x.OutputTokens
+	}
+	return 0
+}
+
+func (x *SandboxReceipt) GetWarnings() []string {
+	if x != nil {
+		return x.Warnings
+	}
+	return nil
+}
+
+type DataClasses struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Input         []string               `protobuf:"bytes,1,rep,name=input,proto3" json:"input,omitempty"`
+	Output        []string               `protobuf:"bytes,2,rep,name=output,proto3" json:"output,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DataClasses) Reset() {
+	*x = DataClasses{}
+	mi := &file_nopass_v1_chat_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DataClasses) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DataClasses) ProtoMessage() {}
+
+func (x *DataClasses) ProtoReflect() protoreflect.Message {
+	mi := &file_nopass_v1_chat_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DataClasses.ProtoReflect.Descriptor instead.
+func (*DataClasses) Descriptor() ([]byte, []int) {
+	return file_nopass_v1_chat_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *DataClasses) GetInput() []string {
+	if x != nil {
+		return x.Input
+	}
+	return nil
+}
+
+func (x *DataClasses) GetOutput() []string {
+	if x != nil {
+		return x.Output
+	}
+	return nil
+}
+
+type SessionRisk struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Level RiskLevel              `protobuf:"varint,1,opt,name=level,proto3,enum=nopass.v1.RiskLevel" json:"level,omitempty"`
+	Score int32                  `protobuf:"varint,2,opt,name=score,proto3" json:"score,omitempty"`
+	// "slow" or "block" once escalated.
+	Action        string                 `protobuf:"bytes,3,opt,name=action,proto3" json:"action,omitempty"`
+	Turns         int32                  `protobuf:"varint,4,opt,name=turns,proto3" json:"turns,omitempty"`
+	MediumTurns   int32                  `protobuf:"varint,5,opt,name=medium_turns,proto3" json:"medium_turns,omitempty"`
+	HighTurns     int32                  `protobuf:"varint,6,opt,name=high_turns,proto3" json:"high_turns,omitempty"`
+	Recent        []int32                `protobuf:"varint,7,rep,packed,name=recent,proto3" json:"recent,omitempty"`
+	UpdatedAt     *timestamppb.Timestamp `protobuf:"bytes,8,opt,name=updated_at,proto3" json:"updated_at,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SessionRisk) Reset() {
+	*x = SessionRisk{}
+	mi := &file_nopass_v1_chat_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SessionRisk) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SessionRisk) ProtoMessage() {}
+
+func (x *SessionRisk) ProtoReflect() protoreflect.Message {
+	mi := &file_nopass_v1_chat_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SessionRisk.ProtoReflect.Descriptor instead.
+func (*SessionRisk) Descriptor() ([]byte, []int) {
+	return file_nopass_v1_chat_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *SessionRisk) GetLevel() RiskLevel {
+	if x != nil {
+		return x.Level
+	}
+	return RiskLevel_RISK_LEVEL_UNSPECIFIED
+}
+
+func (x *SessionRisk) GetScore() int32 {
+	if x != nil {
+		return x.Score
+	}
+	return 0
+}
+
+func (x *SessionRisk) GetAction() string {
+	if x != nil {
+		return x.Action
+	}
+	return ""
+}
+
+func (x *SessionRisk) GetTurns() int32 {
+	if x != nil {
+		return x.Turns
+	}
+	return 0
+}
+
+func (x *SessionRisk) GetMediumTurns() int32 {
+	if x != nil {
+		return x.MediumTurns
+	}
+	return 0
+}
+
+func (x *SessionRisk) GetHighTurns() int32 {
+	if x != nil {
+		return x.HighTurns
+	}
+	return 0
+}
+
+func (x *SessionRisk) GetRecent() []int32 {
+	if x != nil {
+		return x.Recent
+	}
+	return nil
+}
+
+func (x *SessionRisk) GetUpdatedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.UpdatedAt
+	}
+	return nil
+}
+
+type MaskedSpan struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Start         int32                  `protobuf:"varint,1,opt,name=start,proto3" json:"start,omitempty"`
+	End           int32                  `protobuf:"varint,2,opt,name=end,proto3" json:"end,omitempty"`
+	Kind          string                 `protobuf:"bytes,3,opt,name=kind,proto3" json:"kind,omitempty"`
+	Token         string                 `protobuf:"bytes,4,opt,name=token,proto3" json:"token,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *MaskedSpan) Reset() {
+	*x = MaskedSpan{}
+	mi := &file_nopass_v1_chat_proto_msgTypes[11]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *MaskedSpan) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*MaskedSpan) ProtoMessage() {}
+
+func (x *MaskedSpan) ProtoReflect() protoreflect.Message {
+	mi := &file_nopass_v1_chat_proto_msgTypes[11]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use MaskedSpan.ProtoReflect.Descriptor instead.
+func (*MaskedSpan) Descriptor() ([]byte, []int) {
+	return file_nopass_v1_chat_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *MaskedSpan) GetStart() int32 {
+	if x != nil {
+		return x.Start
+	}
+	return 0
+}
+
+func (x *MaskedSpan) GetEnd() int32 {
+	if x != nil {
+		return x.End
+	}
+	return 0
+}
+
+func (x *MaskedSpan) GetKind() string {
+	if x != nil {
+		return x.Kind
+	}
+	return ""
+}
+
+func (x *MaskedSpan) GetToken() string {
+	if x != nil {
+		return x.Token
+	}
+	return ""
+}
+
+type Artifact struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Name          string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	ContentType   string                 `protobuf:"bytes,2,opt,name=content_type,proto3" json:"content_type,omitempty"`
+	Size          int64                  `protobuf:"varint,3,opt,name=size,proto3" json:"size,omitempty"`
+	Sha256        string                 `protobuf:"bytes,4,opt,name=sha256,proto3" json:"sha256,omitempty"`
+	Url           string                 `protobuf:"bytes,5,opt,name=url,proto3" json:"url,omitempty"`
+	ExpiresAt     *timestamppb.Timestamp `protobuf:"bytes,6,opt,name=expires_at,proto3" json:"expires_at,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Artifact) Reset() {
+	*x = Artifact{}
+	mi := &file_nopass_v1_chat_proto_msgTypes[12]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Artifact) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Artifact) ProtoMessage() {}
+
+func (x *Artifact) ProtoReflect() protoreflect.Message {
+	mi := &file_nopass_v1_chat_proto_msgTypes[12]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Artifact.ProtoReflect.Descriptor instead.
+func (*Artifact) Descriptor() ([]byte, []int) {
+	return file_nopass_v1_chat_proto_rawDescGZIP(), []int{12}
+}
+
+func (x *Artifact) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *Artifact) GetContentType() string {
+	if x != nil {
+		return x.ContentType
+	}
+	return ""
+}
+
+func (x *Artifact) GetSize() int64 {
+	if x != nil {
+		return x.Size
+	}
+	return 0
+}
+
+func (x *Artifact) GetSha256() string {
+	if x != nil {
+		return x.Sha256
+	}
+	return ""
+}
+
+func (x *Artifact) GetUrl() string {
+	if x != nil {
+		return x.Url
+	}
+	return ""
+}
+
+func (x *Artifact) GetExpiresAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.ExpiresAt
+	}
+	return nil
+}
+
+type StageFailure struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// "risk", "external_scan" or "output_safety".
+	Stage string `protobuf:"bytes,1,opt,name=stage,proto3" json:"stage,omitempty"`
+	// fail_closed, fail_open or degrade_to_local.
+	Policy        string `protobuf:"bytes,2,opt,name=policy,proto3" json:"policy,omitempty"`
+	Blocks        int32  `protobuf:"varint,3,opt,name=blocks,proto3" json:"blocks,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *StageFailure) Reset() {
+	*x = StageFailure{}
+	mi := &file_nopass_v1_chat_proto_msgTypes[13]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *StageFailure) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StageFailure) ProtoMessage() {}
+
+func (x *StageFailure) ProtoReflect() protoreflect.Message {
+	mi := &file_nopass_v1_chat_proto_msgTypes[13]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StageFailure.ProtoReflect.Descriptor instead.
+func (*StageFailure) Descriptor() ([]byte, []int) {
+	return file_nopass_v1_chat_proto_rawDescGZIP(), []int{13}
+}
+
+func (x *StageFailure) GetStage() string {
+	if x != nil {
+		return x.Stage
+	}
+	return ""
+}
+
+func (x *StageFailure) GetPolicy() string {
+	if x != nil {
+		return x.Policy
+	}
+	return ""
+}
+
+func (x *StageFailure) GetBlocks() int32 {
+	if x != nil {
+		return x.Blocks
+	}
+	return 0
+}
+
+var File_nopass_v1_chat_proto protoreflect.FileDescriptor
+
+const file_nopass_v1_chat_proto_rawDesc = "" +
+	"\n" +
+	"\x14nopass/v1/chat.proto\x12\tnopass.v1\x1a\x1fgoogle/protobuf/timestamp.proto\x1a\x16nopass/v1/common.proto\"\xb3\x04\n" +
+	"\vChatRequest\x12\x1c\n" +
+	"\ttenant_id\x18\x01 \x01(\tR\ttenant_id\x12&\n" +
+	"\x0epolicy_profile\x18\x02 \x01(\tR\x0epolicy_profile\x12\x18\n" +
+	"\auser_id\x18\x03 \x01(\tR\auser_id\x12\x1e\n" +
+	"\n" +
+	"session_id\x18\x04 \x01(\tR\n" +
+	"session_id\x12\x18\n" +
+	"\amessage\x18\x05 \x01(\tR\amessage\x12=\n" +
+	"\rexternal_data\x18\x06 \x03(\v2\x17.nopass.v1.ExternalDataR\rexternal_data\x12\x1c\n" +
+	"\tdata_refs\x18\a \x03(\tR\tdata_refs\x12)\n" +
+	"\ahistory\x18\b \x03(\v2\x0f.nopass.v1.TurnR\ahistory\x12$\n" +
+	"\rreset_session\x18\t \x01(\bR\rreset_session\x123\n" +
+	"\bretrieve\x18\n" +
+	" \x01(\v2\x17.nopass.v1.RetrieveSpecR\bretrieve\x12\x1a\n" +
+	"\bpriority\x18\v \x01(\tR\bpriority\x12\x16\n" +
+	"\x06stream\x18\f \x01(\bR\x06stream\x12;\n" +
+	"\n" +
+	"generation\x18\r \x01(\v2\x1b.nopass.v1.GenerationParamsR\n" +
+	"generation\x12\x1e\n" +
+	"\n" +
+	"mask_spans\x18\x0e \x01(\bR\n" +
+	"mask_spans\x12\x16\n" +
+	"\x06locale\x18\x0f \x01(\tR\x06locale\"d\n" +
+	"\fExternalData\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12\x16\n" +
+	"\x06source\x18\x02 \x01(\tR\x06source\x12\x12\n" +
+	"\x04type\x18\x03 \x01(\tR\x04type\x12\x18\n" +
+	"\acontent\x18\x04 \x01(\tR\acontent\"4\n" +
+	"\x04Turn\x12\x12\n" +
+	"\x04role\x18\x01 \x01(\tR\x04role\x12\x18\n" +
+	"\acontent\x18\x02 \x01(\tR\acontent\"T\n" +
+	"\fRetrieveSpec\x12\x14\n" +
+	"\x05query\x18\x01 \x01(\tR\x05query\x12\x18\n" +
+	"\asources\x18\x02 \x03(\tR\asources\x12\x14\n" +
+	"\x05limit\x18\x03 \x01(\x05R\x05limit\"\xd4\x01\n" +
+	"\x10GenerationParams\x12\x1a\n" +
+	"\bprovider\x18\x01 \x01(\tR\bprovider\x12\x14\n" +
+	"\x05model\x18\x02 \x01(\tR\x05model\x12%\n" +
+	"\vtemperature\x18\x03 \x01(\x01H\x00R\vtemperature\x88\x01\x01\x12\x19\n" +
+	"\x05top_p\x18\x04 \x01(\x01H\x01R\x05top_p\x88\x01\x01\x12\x1e\n" +
+	"\n" +
+	"max_tokens\x18\x05 \x01(\x05R\n" +
+	"max_tokens\x12\x12\n" +
+	"\x04stop\x18\x06 \x03(\tR\x04stopB\x0e\n" +
+	"\f_temperatureB\b\n" +
+	"\x06_top_p\"\xf6\x05\n" +
+	"\fChatResponse\x12&\n" +
+	"\x0eschema_version\x18\x01 \x01(\x05R\x0eschema_version\x12\x16\n" +
+	"\x06answer\x18\x02 \x01(\tR\x06answer\x124\n" +
+	"\n" +
+	"risk_level\x18\x03 \x01(\x0e2\x14.nopass.v1.RiskLevelR\n" +
+	"risk_level\x12#\n" +
+	"\x04path\x18\x04 \x01(\x0e2\x0f.nopass.v1.PathR\x04path\x12\x18\n" +
+	"\anotices\x18\x05 \x03(\tR\anotices\x121\n" +
+	"\tcitations\x18\x06 \x03(\v2\x13.nopass.v1.CitationR\tcitations\x12<\n" +
+	"\vdata_status\x18\a \x03(\v2\x1a.nopass.v1.DataBlockStatusR\vdata_status\x123\n" +
+	"\areceipt\x18\b \x01(\v2\x19.nopass.v1.SandboxReceiptR\areceipt\x12:\n" +
+	"\fdata_classes\x18\t \x01(\v2\x16.nopass.v1.DataClassesR\fdata_classes\x12\x1e\n" +
+	"\n" +
+	"session_id\x18\n" +
+	" \x01(\tR\n" +
+	"session_id\x12$\n" +
+	"\rhistory_turns\x18\v \x01(\x05R\rhistory_turns\x12:\n" +
+	"\fsession_risk\x18\f \x01(\v2\x16.nopass.v1.SessionRiskR\fsession_risk\x12\x1e\n" +
+	"\n" +
+	"feature_id\x18\r \x01(\tR\n" +
+	"feature_id\x129\n" +
+	"\fmasked_spans\x18\x0e \x03(\v2\x15.nopass.v1.MaskedSpanR\fmasked_spans\x121\n" +
+	"\tartifacts\x18\x0f \x03(\v2\x13.nopass.v1.ArtifactR\tartifacts\x12?\n" +
+	"\x0estage_failures\x18\x10 \x03(\v2\x17.nopass.v1.StageFailureR\x0estage_failures\"H\n" +
+	"\bCitation\x12\x14\n" +
+	"\x05index\x18\x01 \x01(\x05R\x05index\x12\x10\n" +
+	"\x03url\x18\x02 \x01(\tR\x03url\x12\x14\n" +
+	"\x05title\x18\x03 \x01(\tR\x05title\"i\n" +
+	"\x0fDataBlockStatus\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12\x16\n" +
+	"\x06source\x18\x02 \x01(\tR\x06source\x12\x16\n" +
+	"\x06status\x18\x03 \x01(\tR\x06status\x12\x16\n" +
+	"\x06reason\x18\x04 \x01(\tR\x06reason\"\xf2\x04\n" +
+	"\x0eSandboxReceipt\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12\x1c\n" +
+	"\ttenant_id\x18\x02 \x01(\tR\ttenant_id\x12\x1c\n" +
+	"\trunner_id\x18\x03 \x01(\tR\trunner_id\x12\"\n" +
+	"\fcontainer_id\x18\x04 \x01(\tR\fcontainer_id\x12\x14\n" +
+	"\x05image\x18\x05 \x01(\tR\x05image\x12\"\n" +
+	"\fimage_digest\x18\x06 \x01(\tR\fimage_digest\x12:\n" +
+	"\n" +
+	"started_at\x18\a \x01(\v2\x1a.google.protobuf.TimestampR\n" +
+	"started_at\x12\"\n" +
+	"\fwall_time_ms\x18\b \x01(\x03R\fwall_time_ms\x12 \n" +
+	"\vcpu_time_ms\x18\t \x01(\x03R\vcpu_time_ms\x12,\n" +
+	"\x11peak_memory_bytes\x18\n" +
+	" \x01(\x03R\x11peak_memory_bytes\x12\x1c\n" +
+	"\texit_code\x18\v \x01(\x05R\texit_code\x12\x1e\n" +
+	"\n" +
+	"oom_killed\x18\f \x01(\bR\n" +
+	"oom_killed\x12\"\n" +
+	"\foutput_bytes\x18\r \x01(\x05R\foutput_bytes\x12(\n" +
+	"\x0foutput_protocol\x18\x0e \x01(\tR\x0foutput_protocol\x12\x14\n" +
+	"\x05model\x18\x0f \x01(\tR\x05model\x12\"\n" +
+	"\finput_tokens\x18\x10 \x01(\x05R\finput_tokens\x12$\n" +
+	"\routput_tokens\x18\x11 \x01(\x05R\routput_tokens\x12\x1a\n" +
+	"\bwarnings\x18\x12 \x03(\tR\bwarnings\";\n" +
+	"\vDataClasses\x12\x14\n" +
+	"\x05input\x18\x01 \x03(\tR\x05input\x12\x16\n" +
+	"\x06output\x18\x02 \x03(\tR\x06output\"\x95\x02\n" +
+	"\vSessionRisk\x12*\n" +
+	"\x05level\x18\x01 \x01(\x0e2\x14.nopass.v1.RiskLevelR\x05level\x12\x14\n" +
+	"\x05score\x18\x02 \x01(\x05R\x05score\x12\x16\n" +
+	"\x06action\x18\x03 \x01(\tR\x06action\x12\x14\n" +
+	"\x05turns\x18\x04 \x01(\x05R\x05turns\x12\"\n" +
+	"\fmedium_turns\x18\x05 \x01(\x05R\fmedium_turns\x12\x1e\n" +
+	"\n" +
+	"high_turns\x18\x06 \x01(\x05R\n" +
+	"high_turns\x12\x16\n" +
+	"\x06recent\x18\a \x03(\x05R\x06recent\x12:\n" +
+	"\n" +
+	"updated_at\x18\b \x01(\v2\x1a.google.protobuf.TimestampR\n" +
+	"updated_at\"^\n" +
+	"\n" +
+	"MaskedSpan\x12\x14\n" +
+	"\x05start\x18\x01 \x01(\x05R\x05start\x12\x10\n" +
+	"\x03end\x18\x02 \x01(\x05R\x03end\x12\x12\n" +
+	"\x04kind\x18\x03 \x01(\tR\x04kind\x12\x14\n" +
+	"\x05token\x18\x04 \x01(\tR\x05token\"\xbc\x01\n" +
+	"\bArtifact\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\x12\"\n" +
+	"\fcontent_type\x18\x02 \x01(\tR\fcontent_type\x12\x12\n" +
+	"\x04size\x18\x03 \x01(\x03R\x04size\x12\x16\n" +
+	"\x06sha256\x18\x04 \x01(\tR\x06sha256\x12\x10\n" +
+	"\x03url\x18\x05 \x01(\tR\x03url\x12:\n" +
+	"\n" +
+	"expires_at\x18\x06 \x01(\v2\x1a.google.protobuf.TimestampR\n" +
+	"expires_at\"T\n" +
+	"\fStageFailure\x12\x14\n" +
+	"\x05stage\x18\x01 \x01(\tR\x05stage\x12\x16\n" +
+	"\x06policy\x18\x02 \x01(\tR\x06policy\x12\x16\n" +
+	"\x06blocks\x18\x03 \x01(\x05R\x06blocks2F\n" +
+	"\vChatService\x127\n" +
+	"\x04Chat\x12\x16.nopass.v1.ChatRequest\x1a\x17.nopass.v1.ChatResponseBBZ@github.com/shivansh-source/nopass/internal/pb/nopass/v1;nopassv1b\x06proto3"
+
+var (
+	file_nopass_v1_chat_proto_rawDescOnce sync.Once
+	file_nopass_v1_chat_proto_rawDescData []byte
+)
+
+func file_nopass_v1_chat_proto_rawDescGZIP() []byte {
+	file_nopass_v1_chat_proto_rawDescOnce.Do(func() {
+		file_nopass_v1_chat_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_nopass_v1_chat_proto_rawDesc), len(file_nopass_v1_chat_proto_rawDesc)))
+	})
+	return file_nopass_v1_chat_proto_rawDescData
+}
+
+var file_nopass_v1_chat_proto_msgTypes = make([]protoimpl.MessageInfo, 14)
+var file_nopass_v1_chat_proto_goTypes = []any{
+	(*ChatRequest)(nil),           // 0: nopass.v1.ChatRequest
+	(*ExternalData)(nil),          // 1: nopass.v1.ExternalData
+	(*Turn)(nil),                  // 2: nopass.v1.Turn
+	(*RetrieveSpec)(nil),          // 3: nopass.v1.RetrieveSpec
+	(*GenerationParams)(nil),      // 4: nopass.v1.GenerationParams
+	(*ChatResponse)(nil),          // 5: nopass.v1.ChatResponse
+	(*Citation)(nil),              // 6: nopass.v1.Citation
+	(*DataBlockStatus)(nil),       // 7: nopass.v1.DataBlockStatus
+	(*SandboxReceipt)(nil),        // 8: nopass.v1.SandboxReceipt
+	(*DataClasses)(nil),           // 9: nopass.v1.DataClasses
+	(*SessionRisk)(nil),           // 10: nopass.v1.SessionRisk
+	(*MaskedSpan)(nil),            // 11: nopass.v1.MaskedSpan
+	(*Artifact)(nil),              // 12: nopass.v1.Artifact
+	(*StageFailure)(nil),          // 13: nopass.v1.StageFailure
+	(RiskLevel)(0),                // 14: nopass.v1.RiskLevel
+	(Path)(0),                     // 15: nopass.v1.Path
+	(*timestamppb.Timestamp)(nil), // 16: google.protobuf.Timestamp
+}
+var file_nopass_v1_chat_proto_depIdxs = []int32{
+	1,  // 0: nopass.v1.ChatRequest.external_data:type_name -> nopass.v1.ExternalData
+	2,  // 1: nopass.v1.ChatRequest.history:type_name -> nopass.v1.Turn
+	3,  // 2: nopass.v1.ChatRequest.retrieve:type_name -> nopass.v1.RetrieveSpec
+	4,  // 3: nopass.v1.ChatRequest.generation:type_name -> nopass.v1.GenerationParams
+	14, // 4: nopass.v1.ChatResponse.risk_level:type_name -> nopass.v1.RiskLevel
+	15, // 5: nopass.v1.ChatResponse.path:type_name -> nopass.v1.Path
+	6,  // 6: nopass.v1.ChatResponse.citations:type_name -> nopass.v1.Citation
+	7,  // 7: nopass.v1.ChatResponse.data_status:type_name -> nopass.v1.DataBlockStatus
+	8,  // 8: nopass.v1.ChatResponse.receipt:type_name -> nopass.v1.SandboxReceipt
+	9,  // 9: nopass.v1.ChatResponse.data_classes:type_name -> nopass.v1.DataClasses
+	10, // 10: nopass.v1.ChatResponse.session_risk:type_name -> nopass.v1.SessionRisk
+	11, // 11: nopass.v1.ChatResponse.masked_spans:type_name -> nopass.v1.MaskedSpan
+	12, // 12: nopass.v1.ChatResponse.artifacts:type_name -> nopass.v1.Artifact
+	13, // 13: nopass.v1.ChatResponse.stage_failures:type_name -> nopass.v1.StageFailure
+	16, // 14: nopass.v1.SandboxReceipt.started_at:type_name -> google.protobuf.Timestamp
+	14, // 15: nopass.v1.SessionRisk.level:type_name -> nopass.v1.RiskLevel
+	16, // 16: nopass.v1.SessionRisk.updated_at:type_name -> google.protobuf.Timestamp
+	16, // 17: nopass.v1.Artifact.expires_at:type_name -> google.protobuf.Timestamp
+	0,  // 18: nopass.v1.ChatService.Chat:input_type -> nopass.v1.ChatRequest
+	5,  // 19: nopass.v1.ChatService.Chat:output_type -> nopass.v1.ChatResponse
+	19, // [19:20] is the sub-list for method output_type
+	18, // [18:19] is the sub-list for method input_type
+	18, // [18:18] is the sub-list for extension type_name
+	18, // [18:18] is the sub-list for extension extendee
+	0,  // [0:18] is the sub-list for field type_name
+}
+
+func init() { file_nopass_v1_chat_proto_init() }
+func file_nopass_v1_chat_proto_init() {
+	if File_nopass_v1_chat_proto != nil {
+		return
+	}
+	file_nopass_v1_common_proto_init()
+	file_nopass_v1_chat_proto_msgTypes[4].OneofWrappers = []any{}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_nopass_v1_chat_proto_rawDesc), len(file_nopass_v1_chat_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   14,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_nopass_v1_chat_proto_goTypes,
+		DependencyIndexes: file_nopass_v1_chat_proto_depIdxs,
+		MessageInfos:      file_nopass_v1_chat_proto_msgTypes,
+	}.Build()
+	File_nopass_v1_chat_proto = out.File
+	file_nopass_v1_chat_proto_goTypes = nil
+	file_nopass_v1_chat_proto_depIdxs = nil
+}
@@ -0,0 +1,193 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.12
+// 	protoc        (unknown)
+// source: nopass/v1/common.proto
+
+package nopassv1
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+// The JSON transport is served and read by hand-written types
+// (internal/types in Go, pydantic models in Python), not protojson: on the
+// wire a RiskLevel is "LOW", "MEDIUM" or "HIGH", where protojson would
+// write "RISK_LEVEL_LOW". The gRPC transport uses these enums as they are.
+type RiskLevel int32
+
+const (
+	RiskLevel_RISK_LEVEL_UNSPECIFIED RiskLevel = 0
+	RiskLevel_RISK_LEVEL_LOW         RiskLevel = 1
+	RiskLevel_RISK_LEVEL_MEDIUM      RiskLevel = 2
+	RiskLevel_RISK_LEVEL_HIGH        RiskLevel = 3
+)
+
+// Enum value maps for RiskLevel.
+var (
+	RiskLevel_name = map[int32]string{
+		0: "RISK_LEVEL_UNSPECIFIED",
+		1: "RISK_LEVEL_LOW",
+		2: "RISK_LEVEL_MEDIUM",
+		3: "RISK_LEVEL_HIGH",
+	}
+	RiskLevel_value = map[string]int32{
+		"RISK_LEVEL_UNSPECIFIED": 0,
+		"RISK_LEVEL_LOW":         1,
+		"RISK_LEVEL_MEDIUM":      2,
+		"RISK_LEVEL_HIGH":        3,
+	}
+)
+
+func (x RiskLevel) Enum() *RiskLevel {
+	p := new(RiskLevel)
+	*p = x
+	return p
+}
+
+func (x RiskLevel) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (RiskLevel) Descriptor() protoreflect.EnumDescriptor {
+	return file_nopass_v1_common_proto_enumTypes[0].Descriptor()
+}
+
+func (RiskLevel) Type() protoreflect.EnumType {
+	return &file_nopass_v1_common_proto_enumTypes[0]
+}
+
+func (x RiskLevel) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use RiskLevel.Descriptor instead.
+func (RiskLevel) EnumDescriptor() ([]byte, []int) {
+	return file_nopass_v1_common_proto_rawDescGZIP(), []int{0}
+}
+
+// Path is the pipeline path; the output-safety mode uses the same values
+// ("fast", "slow" on the wire).
+type Path int32
+
+const (
+	Path_PATH_UNSPECIFIED Path = 0
+	Path_PATH_FAST        Path = 1
+	Path_PATH_SLOW        Path = 2
+)
+
+// Enum value maps for Path.
+var (
+	Path_name = map[int32]string{
+		0: "PATH_UNSPECIFIED",
+		1: "PATH_FAST",
+		2: "PATH_SLOW",
+	}
+	Path_value = map[string]int32{
+		"PATH_UNSPECIFIED": 0,
+		"PATH_FAST":        1,
+		"PATH_SLOW":        2,
+	}
+)
+
+func (x Path) Enum() *Path {
+	p := new(Path)
+	*p = x
+	return p
+}
+
+func (x Path) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (Path) Descriptor() protoreflect.EnumDescriptor {
+	return file_nopass_v1_common_proto_enumTypes[1].Descriptor()
+}
+
+func (Path) Type() protoreflect.EnumType {
+	return &file_nopass_v1_common_proto_enumTypes[1]
+}
+
+func (x Path) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use Path.Descriptor instead.
+func (Path) EnumDescriptor() ([]byte, []int) {
+	return file_nopass_v1_common_proto_rawDescGZIP(), []int{1}
+}
+
+var File_nopass_v1_common_proto protoreflect.FileDescriptor
+
+const file_nopass_v1_common_proto_rawDesc = "" +
+	"\n" +
+	"\x16nopass/v1/common.proto\x12\tnopass.v1*g\n" +
+	"\tRiskLevel\x12\x1a\n" +
+	"\x16RISK_LEVEL_UNSPECIFIED\x10\x00\x12\x12\n" +
+	"\x0eRISK_LEVEL_LOW\x10\x01\x12\x15\n" +
+	"\x11RISK_LEVEL_MEDIUM\x10\x02\x12\x13\n" +
+	"\x0fRISK_LEVEL_HIGH\x10\x03*:\n" +
+	"\x04Path\x12\x14\n" +
+	"\x10PATH_UNSPECIFIED\x10\x00\x12\r\n" +
+	"\tPATH_FAST\x10\x01\x12\r\n" +
+	"\tPATH_SLOW\x10\x02BBZ@github.com/shivansh-source/nopass/internal/pb/nopass/v1;nopassv1b\x06proto3"
+
+var (
+	file_nopass_v1_common_proto_rawDescOnce sync.Once
+	file_nopass_v1_common_proto_rawDescData []byte
+)
+
+func file_nopass_v1_common_proto_rawDescGZIP() []byte {
+	file_nopass_v1_common_proto_rawDescOnce.Do(func() {
+		file_nopass_v1_common_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_nopass_v1_common_proto_rawDesc), len(file_nopass_v1_common_proto_rawDesc)))
+	})
+	return file_nopass_v1_common_proto_rawDescData
+}
+
+var file_nopass_v1_common_proto_enumTypes = make([]protoimpl.EnumInfo, 2)
+var file_nopass_v1_common_proto_goTypes = []any{
+	(RiskLevel)(0), // 0: nopass.v1.RiskLevel
+	(Path)(0),      // 1: nopass.v1.Path
+}
+var file_nopass_v1_common_proto_depIdxs = []int32{
+	0, // [0:0] is the sub-list for method output_type
+	0, // [0:0] is the sub-list for method input_type
+	0, // [0:0] is the sub-list for extension type_name
+	0, // [0:0] is the sub-list for extension extendee
+	0, // [0:0] is the sub-list for field type_name
+}
+
+func init() { file_nopass_v1_common_proto_init() }
+func file_nopass_v1_common_proto_init() {
+	if File_nopass_v1_common_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_nopass_v1_common_proto_rawDesc), len(file_nopass_v1_common_proto_rawDesc)),
+			NumEnums:      2,
+			NumMessages:   0,
+			NumExtensions: 0,
+			NumServices:   0,
+		},
+		GoTypes:           file_nopass_v1_common_proto_goTypes,
+		DependencyIndexes: file_nopass_v1_common_proto_depIdxs,
+		EnumInfos:         file_nopass_v1_common_proto_enumTypes,
+	}.Build()
+	File_nopass_v1_common_proto = out.File
+	file_nopass_v1_common_proto_goTypes = nil
+	file_nopass_v1_common_proto_depIdxs = nil
+}
@@ -0,0 +1,394 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.12
+// 	protoc        (unknown)
+// source: nopass/v1/output_safety.proto
+
+package nopassv1
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+// The output safety service reviews draft answers before they reach the
+// user. JSON transport: POST /v1/output-safety.
+type OutputSafetyRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	SchemaVersion int32                  `protobuf:"varint,1,opt,name=schema_version,proto3" json:"schema_version,omitempty"`
+	UserPrompt    string                 `protobuf:"bytes,2,opt,name=user_prompt,proto3" json:"user_prompt,omitempty"`
+	DraftAnswer   string                 `protobuf:"bytes,3,opt,name=draft_answer,proto3" json:"draft_answer,omitempty"`
+	RiskLevel     RiskLevel              `protobuf:"varint,4,opt,name=risk_level,proto3,enum=nopass.v1.RiskLevel" json:"risk_level,omitempty"`
+	Flags         []string               `protobuf:"bytes,5,rep,name=flags,proto3" json:"flags,omitempty"`
+	Mode          Path                   `protobuf:"varint,6,opt,name=mode,proto3,enum=nopass.v1.Path" json:"mode,omitempty"`
+	DeadlineMs    int64                  `protobuf:"varint,7,opt,name=deadline_ms,proto3" json:"deadline_ms,omitempty"`
+	// What actually went into the model.
+	MaskedPrompt   string        `protobuf:"bytes,8,opt,name=masked_prompt,proto3" json:"masked_prompt,omitempty"`
+	Sources        []*DataSource `protobuf:"bytes,9,rep,name=sources,proto3" json:"sources,omitempty"`
+	PolicyId       string        `protobuf:"bytes,10,opt,name=policy_id,proto3" json:"policy_id,omitempty"`
+	DataFlowLabels []string      `protobuf:"bytes,11,rep,name=data_flow_labels,proto3" json:"data_flow_labels,omitempty"`
+	// The draft is a prefix of an answer still being streamed.
+	Partial       bool `protobuf:"varint,12,opt,name=partial,proto3" json:"partial,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *OutputSafetyRequest) Reset() {
+	*x = OutputSafetyRequest{}
+	mi := &file_nopass_v1_output_safety_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *OutputSafetyRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*OutputSafetyRequest) ProtoMessage() {}
+
+func (x *OutputSafetyRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_nopass_v1_output_safety_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use OutputSafetyRequest.ProtoReflect.Descriptor instead.
+func (*OutputSafetyRequest) Descriptor() ([]byte, []int) {
+	return file_nopass_v1_output_safety_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *OutputSafetyRequest) GetSchemaVersion() int32 {
+	if x != nil {
+		return x.SchemaVersion
+	}
+	return 0
+}
+
+func (x *OutputSafetyRequest) GetUserPrompt() string {
+	if x != nil {
+		return x.UserPrompt
+	}
+	return ""
+}
+
+func (x *OutputSafetyRequest) GetDraftAnswer() string {
+	if x != nil {
+		return x.DraftAnswer
+	}
+	return ""
+}
+
+func (x *OutputSafetyRequest) GetRiskLevel() RiskLevel {
+	if x != nil {
+		return x.RiskLevel
+	}
+	return RiskLevel_RISK_LEVEL_UNSPECIFIED
+}
+
+func (x *OutputSafetyRequest) GetFlags() []string {
+	if x != nil {
+		return x.Flags
+	}
+	return nil
+}
+
+func (x *OutputSafetyRequest) GetMode() Path {
+	if x != nil {
+		return x.Mode
+	}
+	return Path_PATH_UNSPECIFIED
+}
+
+func (x *OutputSafetyRequest) GetDeadlineMs() int64 {
+	if x != nil {
+		return x.DeadlineMs
+	}
+	return 0
+}
+
+func (x *OutputSafetyRequest) GetMaskedPrompt() string {
+	if x != nil {
+		return x.MaskedPrompt
+	}
+	return ""
+}
+
+func (x *OutputSafetyRequest) GetSources() []*DataSource {
+	if x != nil {
+		return x.Sources
+	}
+	return nil
+}
+
+func (x *OutputSafetyRequest) GetPolicyId() string {
+	if x != nil {
+		return x.PolicyId
+	}
+	return ""
+}
+
+func (x *OutputSafetyRequest) GetDataFlowLabels() []string {
+	if x != nil {
+		return x.DataFlowLabels
+	}
+	return nil
+}
+
+func (x *OutputSafetyRequest) GetPartial() bool {
+	if x != nil {
+		return x.Partial
+	}
+	return false
+}
+
+// DataSource describes one external data block included in the prompt.
+type DataSource struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Source        string                 `protobuf:"bytes,2,opt,name=source,proto3" json:"source,omitempty"`
+	Type          string                 `protobuf:"bytes,3,opt,name=type,proto3" json:"type,omitempty"`
+	Dangerous     bool                   `protobuf:"varint,4,opt,name=dangerous,proto3" json:"dangerous,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DataSource) Reset() {
+	*x = DataSource{}
+	mi := &file_nopass_v1_output_safety_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DataSource) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DataSource) ProtoMessage() {}
+
+func (x *DataSource) ProtoReflect() protoreflect.Message {
+	mi := &file_nopass_v1_output_safety_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DataSource.ProtoReflect.Descriptor instead.
+func (*DataSource) Descriptor() ([]byte, []int) {
+	return file_nopass_v1_output_safety_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *DataSource) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *DataSource) GetSource() string {
+	if x != nil {
+		return x.Source
+	}
+	return ""
+}
+
+func (x *DataSource) GetType() string {
+	if x != nil {
+		return x.Type
+	}
+	return ""
+}
+
+func (x *DataSource) GetDangerous() bool {
+	if x != nil {
+		return x.Dangerous
+	}
+	return false
+}
+
+type OutputSafetyResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	SchemaVersion int32                  `protobuf:"varint,1,opt,name=schema_version,proto3" json:"schema_version,omitempty"`
+	FinalAnswer   string                 `protobuf:"bytes,2,opt,name=final_answer,proto3" json:"final_answer,omitempty"`
+	WasModified   bool                   `protobuf:"varint,3,opt,name=was_modified,proto3" json:"was_modified,omitempty"`
+	ReasonFlags   []string               `protobuf:"bytes,4,rep,name=reason_flags,proto3" json:"reason_flags,omitempty"`
+	// Refused outright rather than redacted.
+	Blocked       bool `protobuf:"varint,5,opt,name=blocked,proto3" json:"blocked,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *OutputSafetyResponse) Reset() {
+	*x = OutputSafetyResponse{}
+	mi := &file_nopass_v1_output_safety_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *OutputSafetyResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*OutputSafetyResponse) ProtoMessage() {}
+
+func (x *OutputSafetyResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_nopass_v1_output_safety_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use OutputSafetyResponse.ProtoReflect.Descriptor instead.
+func (*OutputSafetyResponse) Descriptor() ([]byte, []int) {
+	return file_nopass_v1_output_safety_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *OutputSafetyResponse) GetSchemaVersion() int32 {
+	if x != nil {
+		return x.SchemaVersion
+	}
+	return 0
+}
+
+func (x *OutputSafetyResponse) GetFinalAnswer() string {
+	if x != nil {
+		return x.FinalAnswer
+	}
+	return ""
+}
+
+func (x *OutputSafetyResponse) GetWasModified() bool {
+	if x != nil {
+		return x.WasModified
+	}
+	return false
+}
+
+func (x *OutputSafetyResponse) GetReasonFlags() []string {
+	if x != nil {
+		return x.ReasonFlags
+	}
+	return nil
+}
+
+func (x *OutputSafetyResponse) GetBlocked() bool {
+	if x != nil {
+		return x.Blocked
+	}
+	return false
+}
+
+var File_nopass_v1_output_safety_proto protoreflect.FileDescriptor
+
+const file_nopass_v1_output_safety_proto_rawDesc = "" +
+	"\n" +
+	"\x1dnopass/v1/output_safety.proto\x12\tnopass.v1\x1a\x16nopass/v1/common.proto\"\xd1\x03\n" +
+	"\x13OutputSafetyRequest\x12&\n" +
+	"\x0eschema_version\x18\x01 \x01(\x05R\x0eschema_version\x12 \n" +
+	"\vuser_prompt\x18\x02 \x01(\tR\vuser_prompt\x12\"\n" +
+	"\fdraft_answer\x18\x03 \x01(\tR\fdraft_answer\x124\n" +
+	"\n" +
+	"risk_level\x18\x04 \x01(\x0e2\x14.nopass.v1.RiskLevelR\n" +
+	"risk_level\x12\x14\n" +
+	"\x05flags\x18\x05 \x03(\tR\x05flags\x12#\n" +
+	"\x04mode\x18\x06 \x01(\x0e2\x0f.nopass.v1.PathR\x04mode\x12 \n" +
+	"\vdeadline_ms\x18\a \x01(\x03R\vdeadline_ms\x12$\n" +
+	"\rmasked_prompt\x18\b \x01(\tR\rmasked_prompt\x12/\n" +
+	"\asources\x18\t \x03(\v2\x15.nopass.v1.DataSourceR\asources\x12\x1c\n" +
+	"\tpolicy_id\x18\n" +
+	" \x01(\tR\tpolicy_id\x12*\n" +
+	"\x10data_flow_labels\x18\v \x03(\tR\x10data_flow_labels\x12\x18\n" +
+	"\apartial\x18\f \x01(\bR\apartial\"f\n" +
+	"\n" +
+	"DataSource\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12\x16\n" +
+	"\x06source\x18\x02 \x01(\tR\x06source\x12\x12\n" +
+	"\x04type\x18\x03 \x01(\tR\x04type\x12\x1c\n" +
+	"\tdangerous\x18\x04 \x01(\bR\tdangerous\"\xc4\x01\n" +
+	"\x14OutputSafetyResponse\x12&\n" +
+	"\x0eschema_version\x18\x01 \x01(\x05R\x0eschema_version\x12\"\n" +
+	"\ffinal_answer\x18\x02 \x01(\tR\ffinal_answer\x12\"\n" +
+	"\fwas_modified\x18\x03 \x01(\bR\fwas_modified\x12\"\n" +
+	"\freason_flags\x18\x04 \x03(\tR\freason_flags\x12\x18\n" +
+	"\ablocked\x18\x05 \x01(\bR\ablockedBBZ@github.com/shivansh-source/nopass/internal/pb/nopass/v1;nopassv1b\x06proto3"
+
+var (
+	file_nopass_v1_output_safety_proto_rawDescOnce sync.Once
+	file_nopass_v1_output_safety_proto_rawDescData []byte
+)
+
+func file_nopass_v1_output_safety_proto_rawDescGZIP() []byte {
+	file_nopass_v1_output_safety_proto_rawDescOnce.Do(func() {
+		file_nopass_v1_output_safety_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_nopass_v1_output_safety_proto_rawDesc), len(file_nopass_v1_output_safety_proto_rawDesc)))
+	})
+	return file_nopass_v1_output_safety_proto_rawDescData
+}
+
+var file_nopass_v1_output_safety_proto_msgTypes = make([]protoimpl.MessageInfo, 3)
+var file_nopass_v1_output_safety_proto_goTypes = []any{
+	(*OutputSafetyRequest)(nil),  // 0: nopass.v1.OutputSafetyRequest
+	(*DataSource)(nil),           // 1: nopass.v1.DataSource
+	(*OutputSafetyResponse)(nil), // 2: nopass.v1.OutputSafetyResponse
+	(RiskLevel)(0),               // 3: nopass.v1.RiskLevel
+	(Path)(0),                    // 4: nopass.v1.Path
+}
+var file_nopass_v1_output_safety_proto_depIdxs = []int32{
+	3, // 0: nopass.v1.OutputSafetyRequest.risk_level:type_name -> nopass.v1.RiskLevel
+	4, // 1: nopass.v1.OutputSafetyRequest.mode:type_name -> nopass.v1.Path
+	1, // 2: nopass.v1.OutputSafetyRequest.sources:type_name -> nopass.v1.DataSource
+	3, // [3:3] is the sub-list for method output_type
+	3, // [3:3] is the sub-list for method input_type
+	3, // [3:3] is the sub-list for extension type_name
+	3, // [3:3] is the sub-list for extension extendee
+	0, // [0:3] is the sub-list for field type_name
+}
+
+func init() { file_nopass_v1_output_safety_proto_init() }
+func file_nopass_v1_output_safety_proto_init() {
+	if File_nopass_v1_output_safety_proto != nil {
+		return
+	}
+	file_nopass_v1_common_proto_init()
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_nopass_v1_output_safety_proto_rawDesc), len(file_nopass_v1_output_safety_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   3,
+			NumExtensions: 0,
+			NumServices:   0,
+		},
+		GoTypes:           file_nopass_v1_output_safety_proto_goTypes,
+		DependencyIndexes: file_nopass_v1_output_safety_proto_depIdxs,
+		MessageInfos:      file_nopass_v1_output_safety_proto_msgTypes,
+	}.Build()
+	File_nopass_v1_output_safety_proto = out.File
+	file_nopass_v1_output_safety_proto_goTypes = nil
+	file_nopass_v1_output_safety_proto_depIdxs = nil
+}
@@ -0,0 +1,565 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.12
+// 	protoc        (unknown)
+// source: nopass/v1/risk.proto
+
+package nopassv1
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+// The risk scoring service scores prompts and external data for
+// injection/exfiltration risk. JSON transport: POST /v1/risk-score,
+// POST /v1/risk-score/batch for several documents in one call and, for
+// large documents, POST /v2/risk-score/stream (NDJSON in both
+// directions), which scores a document section by section and stops at
+// the first HIGH section.
+type RiskRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	SchemaVersion int32                  `protobuf:"varint,1,opt,name=schema_version,proto3" json:"schema_version,omitempty"`
+	Prompt        string                 `protobuf:"bytes,2,opt,name=prompt,proto3" json:"prompt,omitempty"`
+	Metadata      map[string]string      `protobuf:"bytes,3,rep,name=metadata,proto3" json:"metadata,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	// Remaining time budget; mirrors the X-Deadline-Ms header.
+	DeadlineMs    int64 `protobuf:"varint,4,opt,name=deadline_ms,proto3" json:"deadline_ms,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RiskRequest) Reset() {
+	*x = RiskRequest{}
+	mi := &file_nopass_v1_risk_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RiskRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RiskRequest) ProtoMessage() {}
+
+func (x *RiskRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_nopass_v1_risk_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RiskRequest.ProtoReflect.Descriptor instead.
+func (*RiskRequest) Descriptor() ([]byte, []int) {
+	return file_nopass_v1_risk_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *RiskRequest) GetSchemaVersion() int32 {
+	if x != nil {
+		return x.SchemaVersion
+	}
+	return 0
+}
+
+func (x *RiskRequest) GetPrompt() string {
+	if x != nil {
+		return x.Prompt
+	}
+	return ""
+}
+
+func (x *RiskRequest) GetMetadata() map[string]string {
+	if x != nil {
+		return x.Metadata
+	}
+	return nil
+}
+
+func (x *RiskRequest) GetDeadlineMs() int64 {
+	if x != nil {
+		return x.DeadlineMs
+	}
+	return 0
+}
+
+type RiskResponse struct {
+	state             protoimpl.MessageState `protogen:"open.v1"`
+	SchemaVersion     int32                  `protobuf:"varint,1,opt,name=schema_version,proto3" json:"schema_version,omitempty"`
+	SanitizedPrompt   string                 `protobuf:"bytes,2,opt,name=sanitized_prompt,proto3" json:"sanitized_prompt,omitempty"`
+	RiskLevel         RiskLevel              `protobuf:"varint,3,opt,name=risk_level,proto3,enum=nopass.v1.RiskLevel" json:"risk_level,omitempty"`
+	Flags             []string               `protobuf:"bytes,4,rep,name=flags,proto3" json:"flags,omitempty"`
+	SelfCheckRequired bool                   `protobuf:"varint,5,opt,name=self_check_required,proto3" json:"self_check_required,omitempty"`
+	unknownFields     protoimpl.UnknownFields
+	sizeCache         protoimpl.SizeCache
+}
+
+func (x *RiskResponse) Reset() {
+	*x = RiskResponse{}
+	mi := &file_nopass_v1_risk_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RiskResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RiskResponse) ProtoMessage() {}
+
+func (x *RiskResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_nopass_v1_risk_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RiskResponse.ProtoReflect.Descriptor instead.
+func (*RiskResponse) Descriptor() ([]byte, []int) {
+	return file_nopass_v1_risk_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *RiskResponse) GetSchemaVersion() int32 {
+	if x != nil {
+		return x.SchemaVersion
+	}
+	return 0
+}
+
+func (x *RiskResponse) GetSanitizedPrompt() string {
+	if x != nil {
+		return x.SanitizedPrompt
+	}
+	return ""
+}
+
+func (x *RiskResponse) GetRiskLevel() RiskLevel {
+	if x != nil {
+		return x.RiskLevel
+	}
+	return RiskLevel_RISK_LEVEL_UNSPECIFIED
+}
+
+func (x *RiskResponse) GetFlags() []string {
+	if x != nil {
+		return x.Flags
+	}
+	return nil
+}
+
+func (x *RiskResponse) GetSelfCheckRequired() bool {
+	if x != nil {
+		return x.SelfCheckRequired
+	}
+	return false
+}
+
+// RiskBatchRequest is the body of POST /v1/risk-score/batch. Services
+// without the endpoint answer 404, and the gateway scores the documents
+// one at a time instead.
+type RiskBatchRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	SchemaVersion int32                  `protobuf:"varint,1,opt,name=schema_version,proto3" json:"schema_version,omitempty"`
+	Documents     []string               `protobuf:"bytes,2,rep,name=documents,proto3" json:"documents,omitempty"`
+	Metadata      map[string]string      `protobuf:"bytes,3,rep,name=metadata,proto3" json:"metadata,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	DeadlineMs    int64                  `protobuf:"varint,4,opt,name=deadline_ms,proto3" json:"deadline_ms,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RiskBatchRequest) Reset() {
+	*x = RiskBatchRequest{}
+	mi := &file_nopass_v1_risk_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RiskBatchRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RiskBatchRequest) ProtoMessage() {}
+
+func (x *RiskBatchRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_nopass_v1_risk_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RiskBatchRequest.ProtoReflect.Descriptor instead.
+func (*RiskBatchRequest) Descriptor() ([]byte, []int) {
+	return file_nopass_v1_risk_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *RiskBatchRequest) GetSchemaVersion() int32 {
+	if x != nil {
+		return x.SchemaVersion
+	}
+	return 0
+}
+
+func (x *RiskBatchRequest) GetDocuments() []string {
+	if x != nil {
+		return x.Documents
+	}
+	return nil
+}
+
+func (x *RiskBatchRequest) GetMetadata() map[string]string {
+	if x != nil {
+		return x.Metadata
+	}
+	return nil
+}
+
+func (x *RiskBatchRequest) GetDeadlineMs() int64 {
+	if x != nil {
+		return x.DeadlineMs
+	}
+	return 0
+}
+
+// RiskBatchResponse holds one verdict per document, in request order.
+type RiskBatchResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	SchemaVersion int32                  `protobuf:"varint,1,opt,name=schema_version,proto3" json:"schema_version,omitempty"`
+	Results       []*RiskResponse        `protobuf:"bytes,2,rep,name=results,proto3" json:"results,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RiskBatchResponse) Reset() {
+	*x = RiskBatchResponse{}
+	mi := &file_nopass_v1_risk_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RiskBatchResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RiskBatchResponse) ProtoMessage() {}
+
+func (x *RiskBatchResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_nopass_v1_risk_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RiskBatchResponse.ProtoReflect.Descriptor instead.
+func (*RiskBatchResponse) Descriptor() ([]byte, []int) {
+	return file_nopass_v1_risk_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *RiskBatchResponse) GetSchemaVersion() int32 {
+	if x != nil {
+		return x.SchemaVersion
+	}
+	return 0
+}
+
+func (x *RiskBatchResponse) GetResults() []*RiskResponse {
+	if x != nil {
+		return x.Results
+	}
+	return nil
+}
+
+type RiskSection struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	SchemaVersion int32                  `protobuf:"varint,1,opt,name=schema_version,proto3" json:"schema_version,omitempty"`
+	Seq           int32                  `protobuf:"varint,2,opt,name=seq,proto3" json:"seq,omitempty"`
+	Text          string                 `protobuf:"bytes,3,opt,name=text,proto3" json:"text,omitempty"`
+	// Sent with the first section only.
+	Metadata      map[string]string `protobuf:"bytes,4,rep,name=metadata,proto3" json:"metadata,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	DeadlineMs    int64             `protobuf:"varint,5,opt,name=deadline_ms,proto3" json:"deadline_ms,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RiskSection) Reset() {
+	*x = RiskSection{}
+	mi := &file_nopass_v1_risk_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RiskSection) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RiskSection) ProtoMessage() {}
+
+func (x *RiskSection) ProtoReflect() protoreflect.Message {
+	mi := &file_nopass_v1_risk_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RiskSection.ProtoReflect.Descriptor instead.
+func (*RiskSection) Descriptor() ([]byte, []int) {
+	return file_nopass_v1_risk_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *RiskSection) GetSchemaVersion() int32 {
+	if x != nil {
+		return x.SchemaVersion
+	}
+	return 0
+}
+
+func (x *RiskSection) GetSeq() int32 {
+	if x != nil {
+		return x.Seq
+	}
+	return 0
+}
+
+func (x *RiskSection) GetText() string {
+	if x != nil {
+		return x.Text
+	}
+	return ""
+}
+
+func (x *RiskSection) GetMetadata() map[string]string {
+	if x != nil {
+		return x.Metadata
+	}
+	return nil
+}
+
+func (x *RiskSection) GetDeadlineMs() int64 {
+	if x != nil {
+		return x.DeadlineMs
+	}
+	return 0
+}
+
+type RiskSectionVerdict struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	SchemaVersion int32                  `protobuf:"varint,1,opt,name=schema_version,proto3" json:"schema_version,omitempty"`
+	Seq           int32                  `protobuf:"varint,2,opt,name=seq,proto3" json:"seq,omitempty"`
+	RiskLevel     RiskLevel              `protobuf:"varint,3,opt,name=risk_level,proto3,enum=nopass.v1.RiskLevel" json:"risk_level,omitempty"`
+	Flags         []string               `protobuf:"bytes,4,rep,name=flags,proto3" json:"flags,omitempty"`
+	// Last verdict of the stream: all sections scored, or stopped at HIGH.
+	Final         bool `protobuf:"varint,5,opt,name=final,proto3" json:"final,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RiskSectionVerdict) Reset() {
+	*x = RiskSectionVerdict{}
+	mi := &file_nopass_v1_risk_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RiskSectionVerdict) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RiskSectionVerdict) ProtoMessage() {}
+
+func (x *RiskSectionVerdict) ProtoReflect() protoreflect.Message {
+	mi := &file_nopass_v1_risk_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RiskSectionVerdict.ProtoReflect.Descriptor instead.
+func (*RiskSectionVerdict) Descriptor() ([]byte, []int) {
+	return file_nopass_v1_risk_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *RiskSectionVerdict) GetSchemaVersion() int32 {
+	if x != nil {
+		return x.SchemaVersion
+	}
+	return 0
+}
+
+func (x *RiskSectionVerdict) GetSeq() int32 {
+	if x != nil {
+		return x.Seq
+	}
+	return 0
+}
+
+func (x *RiskSectionVerdict) GetRiskLevel() RiskLevel {
+	if x != nil {
+		return x.RiskLevel
+	}
+	return RiskLevel_RISK_LEVEL_UNSPECIFIED
+}
+
+func (x *RiskSectionVerdict) GetFlags() []string {
+	if x != nil {
+		return x.Flags
+	}
+	return nil
+}
+
+func (x *RiskSectionVerdict) GetFinal() bool {
+	if x != nil {
+		return x.Final
+	}
+	return false
+}
+
+var File_nopass_v1_risk_proto protoreflect.FileDescriptor
+
+const file_nopass_v1_risk_proto_rawDesc = "" +
+	"\n" +
+	"\x14nopass/v1/risk.proto\x12\tnopass.v1\x1a\x16nopass/v1/common.proto\"\xee\x01\n" +
+	"\vRiskRequest\x12&\n" +
+	"\x0eschema_version\x18\x01 \x01(\x05R\x0eschema_version\x12\x16\n" +
+	"\x06prompt\x18\x02 \x01(\tR\x06prompt\x12@\n" +
+	"\bmetadata\x18\x03 \x03(\v2$.nopass.v1.RiskRequest.MetadataEntryR\bmetadata\x12 \n" +
+	"\vdeadline_ms\x18\x04 \x01(\x03R\vdeadline_ms\x1a;\n" +
+	"\rMetadataEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"\xe0\x01\n" +
+	"\fRiskResponse\x12&\n" +
+	"\x0eschema_version\x18\x01 \x01(\x05R\x0eschema_version\x12*\n" +
+	"\x10sanitized_prompt\x18\x02 \x01(\tR\x10sanitized_prompt\x124\n" +
+	"\n" +
+	"risk_level\x18\x03 \x01(\x0e2\x14.nopass.v1.RiskLevelR\n" +
+	"risk_level\x12\x14\n" +
+	"\x05flags\x18\x04 \x03(\tR\x05flags\x120\n" +
+	"\x13self_check_required\x18\x05 \x01(\bR\x13self_check_required\"\xfe\x01\n" +
+	"\x10RiskBatchRequest\x12&\n" +
+	"\x0eschema_version\x18\x01 \x01(\x05R\x0eschema_version\x12\x1c\n" +
+	"\tdocuments\x18\x02 \x03(\tR\tdocuments\x12E\n" +
+	"\bmetadata\x18\x03 \x03(\v2).nopass.v1.RiskBatchRequest.MetadataEntryR\bmetadata\x12 \n" +
+	"\vdeadline_ms\x18\x04 \x01(\x03R\vdeadline_ms\x1a;\n" +
+	"\rMetadataEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"n\n" +
+	"\x11RiskBatchResponse\x12&\n" +
+	"\x0eschema_version\x18\x01 \x01(\x05R\x0eschema_version\x121\n" +
+	"\aresults\x18\x02 \x03(\v2\x17.nopass.v1.RiskResponseR\aresults\"\xfc\x01\n" +
+	"\vRiskSection\x12&\n" +
+	"\x0eschema_version\x18\x01 \x01(\x05R\x0eschema_version\x12\x10\n" +
+	"\x03seq\x18\x02 \x01(\x05R\x03seq\x12\x12\n" +
+	"\x04text\x18\x03 \x01(\tR\x04text\x12@\n" +
+	"\bmetadata\x18\x04 \x03(\v2$.nopass.v1.RiskSection.MetadataEntryR\bmetadata\x12 \n" +
+	"\vdeadline_ms\x18\x05 \x01(\x03R\vdeadline_ms\x1a;\n" +
+	"\rMetadataEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"\xb0\x01\n" +
+	"\x12RiskSectionVerdict\x12&\n" +
+	"\x0eschema_version\x18\x01 \x01(\x05R\x0eschema_version\x12\x10\n" +
+	"\x03seq\x18\x02 \x01(\x05R\x03seq\x124\n" +
+	"\n" +
+	"risk_level\x18\x03 \x01(\x0e2\x14.nopass.v1.RiskLevelR\n" +
+	"risk_level\x12\x14\n" +
+	"\x05flags\x18\x04 \x03(\tR\x05flags\x12\x14\n" +
+	"\x05final\x18\x05 \x01(\bR\x05finalBBZ@github.com/shivansh-source/nopass/internal/pb/nopass/v1;nopassv1b\x06proto3"
+
+var (
+	file_nopass_v1_risk_proto_rawDescOnce sync.Once
+	file_nopass_v1_risk_proto_rawDescData []byte
+)
+
+func file_nopass_v1_risk_proto_rawDescGZIP() []byte {
+	file_nopass_v1_risk_proto_rawDescOnce.Do(func() {
+		file_nopass_v1_risk_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_nopass_v1_risk_proto_rawDesc), len(file_nopass_v1_risk_proto_rawDesc)))
+	})
+	return file_nopass_v1_risk_proto_rawDescData
+}
+
+var file_nopass_v1_risk_proto_msgTypes = make([]protoimpl.MessageInfo, 9)
+var file_nopass_v1_risk_proto_goTypes = []any{
+	(*RiskRequest)(nil),        // 0: nopass.v1.RiskRequest
+	(*RiskResponse)(nil),       // 1: nopass.v1.RiskResponse
+	(*RiskBatchRequest)(nil),   // 2: nopass.v1.RiskBatchRequest
+	(*RiskBatchResponse)(nil),  // 3: nopass.v1.RiskBatchResponse
+	(*RiskSection)(nil),        // 4: nopass.v1.RiskSection
+	(*RiskSectionVerdict)(nil), // 5: nopass.v1.RiskSectionVerdict
+	nil,                        // 6: nopass.v1.RiskRequest.MetadataEntry
+	nil,                        // 7: nopass.v1.RiskBatchRequest.MetadataEntry
+	nil,                        // 8: nopass.v1.RiskSection.MetadataEntry
+	(RiskLevel)(0),             // 9: nopass.v1.RiskLevel
+}
+var file_nopass_v1_risk_proto_depIdxs = []int32{
+	6, // 0: nopass.v1.RiskRequest.metadata:type_name -> nopass.v1.RiskRequest.MetadataEntry
+	9, // 1: nopass.v1.RiskResponse.risk_level:type_name -> nopass.v1.RiskLevel
+	7, // 2: nopass.v1.RiskBatchRequest.metadata:type_name -> nopass.v1.RiskBatchRequest.MetadataEntry
+	1, // 3: nopass.v1.RiskBatchResponse.results:type_name -> nopass.v1.RiskResponse
+	8, // 4: nopass.v1.RiskSection.metadata:type_name -> nopass.v1.RiskSection.MetadataEntry
+	9, // 5: nopass.v1.RiskSectionVerdict.risk_level:type_name -> nopass.v1.RiskLevel
+	6, // [6:6] is the sub-list for method output_type
+	6, // [6:6] is the sub-list for method input_type
+	6, // [6:6] is the sub-list for extension type_name
+	6, // [6:6] is the sub-list for extension extendee
+	0, // [0:6] is the sub-list for field type_name
+}
+
+func init() { file_nopass_v1_risk_proto_init() }
+func file_nopass_v1_risk_proto_init() {
+	if File_nopass_v1_risk_proto != nil {
+		return
+	}
+	file_nopass_v1_common_proto_init()
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_nopass_v1_risk_proto_rawDesc), len(file_nopass_v1_risk_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   9,
+			NumExtensions: 0,
+			NumServices:   0,
+		},
+		GoTypes:           file_nopass_v1_risk_proto_goTypes,
+		DependencyIndexes: file_nopass_v1_risk_proto_depIdxs,
+		MessageInfos:      file_nopass_v1_risk_proto_msgTypes,
+	}.Build()
+	File_nopass_v1_risk_proto = out.File
+	file_nopass_v1_risk_proto_goTypes = nil
+	file_nopass_v1_risk_proto_depIdxs = nil
+}
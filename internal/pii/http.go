@@ -0,0 +1,141 @@
+package pii
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/shivansh-source/nopass/internal/tracing"
+)
+
+// DefaultKinds maps Presidio entity types to the token kinds the built-in
+// rules use; other types are masked under their own name, e.g.
+// US_SSN_TOKEN_1.
+var DefaultKinds = map[string]string{
+	"CREDIT_CARD":   "CARD",
+	"EMAIL_ADDRESS": "EMAIL",
+	"PHONE_NUMBER":  "PHONE",
+	"PERSON":        "NAME",
+	"LOCATION":      "ADDRESS",
+}
+
+// HTTPDetector asks an entity recognition service speaking Presidio's
+// analyzer API:
+//
+//	POST {URL}/analyze {"text": "...", "language": "en", "entities": [...], "score_threshold": 0.5}
+//	→ [{"entity_type": "PERSON", "start": 0, "end": 10, "score": 0.85}, ...]
+type HTTPDetector struct {
+	URL        string
+	HTTPClient *http.Client
+	// Language is the text's language (default "en").
+	Language string
+	// Entities, if set, limits detection to these entity types.
+	Entities []string
+	// MinScore drops entities the service is less sure of (0 = 0.5).
+	MinScore float64
+	// Kinds maps entity types to token kinds (nil = DefaultKinds).
+	Kinds map[string]string
+}
+
+// NewHTTPDetector creates a detector for the analyzer at url.
+func NewHTTPDetector(url string) *HTTPDetector {
+	return &HTTPDetector{
+		URL:        strings.TrimSuffix(url, "/"),
+		HTTPClient: &http.Client{Timeout: 2 * time.Second},
+	}
+}
+
+type analyzeRequest struct {
+	Text           string   `json:"text"`
+	Language       string   `json:"language"`
+	Entities       []string `json:"entities,omitempty"`
+	ScoreThreshold float64  `json:"score_threshold"`
+}
+
+type analyzeResult struct {
+	EntityType string  `json:"entity_type"`
+	Start      int     `json:"start"`
+	End        int     `json:"end"`
+	Score      float64 `json:"score"`
+}
+
+// Detect implements Detector.
+func (d *HTTPDetector) Detect(ctx context.Context, text string) (_ []Entity, err error) {
+	ctx, span := tracing.Start(ctx, "pii.detect", tracing.Client)
+	defer func() { span.End(err) }()
+	lang, minScore := d.Language, d.MinScore
+	if lang == "" {
+		lang = "en"
+	}
+	if minScore <= 0 {
+		minScore = 0.5
+	}
+	data, err := json.Marshal(analyzeRequest{Text: text, Language: lang, Entities: d.Entities, ScoreThreshold: minScore})
+	if err != nil {
+		return nil, fmt.Errorf("marshal pii request: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.URL+"/analyze", bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("create pii request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	tracing.Inject(ctx, req.Header)
+	resp, err := d.HTTPClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("call pii detector: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("pii detector returned status %d", resp.StatusCode)
+	}
+	var results []analyzeResult
+	if err := json.NewDecoder(resp.Body).Decode(&results); err != nil {
+		return nil, fmt.Errorf("decode pii response: %w", err)
+	}
+
+	kinds := d.Kinds
+	if kinds == nil {
+		kinds = DefaultKinds
+	}
+	// Presidio counts offsets in characters, not bytes.
+	runes := []rune(text)
+	ents := make([]Entity, 0, len(results))
+	for _, r := range results {
+		if r.Score < minScore || r.Start < 0 || r.End > len(runes) || r.Start >= r.End {
+			continue
+		}
+		kind, ok := kinds[r.EntityType]
+		if !ok {
+			kind = tokenKind(r.EntityType)
+		}
+		if kind == "" {
+			continue
+		}
+		start := len(string(runes[:r.Start]))
+		value := string(runes[r.Start:r.End])
+		ents = append(ents, Entity{Kind: kind, Start: start, End: start + len(value), Value: value, Score: r.Score})
+	}
+	return ents, nil
+}
+
+// tokenKind turns an entity type into a token kind: upper-case letters,
+// digits and _, starting with a letter.
+func tokenKind(entityType string) string {
+	kind := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z':
+			return r - 'a' + 'A'
+		case r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '_':
+			return r
+		}
+		return '_'
+	}, entityType)
+	if kind == "" || kind[0] < 'A' || kind[0] > 'Z' {
+		return ""
+	}
+	return kind
+}
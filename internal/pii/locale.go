@@ -0,0 +1,193 @@
+package pii
+
+import (
+	"bytes"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// A locale (Regex.Locale) tells the pattern rules how numbers are written
+// in the text, so they neither take a date or an amount for a card number
+// nor miss a phone number grouped the local way. Card and phone numbers
+// are looked for in a view of the text in which Unicode spaces and
+// fullwidth digits are plain ASCII and the locale's dates and amounts
+// are blanked out; what they find is mapped back to the original text.
+
+var validLocale = regexp.MustCompile(`^[A-Za-z]{2,3}(?:[-_][A-Za-z0-9]{2,8})*$`)
+
+// ValidLocale reports whether locale looks like a BCP 47 tag, e.g. de-DE.
+func ValidLocale(locale string) bool {
+	return validLocale.MatchString(locale)
+}
+
+// localeRules is how a locale writes numbers.
+type localeRules struct {
+	// dateOrder is "DMY", "MDY" or "YMD"; ISO dates are recognized in
+	// every locale.
+	dateOrder string
+	// decimalComma: 1.234,56 rather than 1,234.56.
+	decimalComma bool
+	// groupedPhones: numbers outside the North American plan, written in
+	// groups after a country code or trunk prefix (06 12 34 56 78,
+	// +49 30 1234 5678, 0171/1234567).
+	groupedPhones bool
+}
+
+func rulesFor(locale string) localeRules {
+	lang, region, _ := strings.Cut(strings.ReplaceAll(locale, "_", "-"), "-")
+	lang = strings.ToLower(lang)
+	// Drop a script subtag (zh-Hant-TW).
+	if len(region) > 3 {
+		if _, r, ok := strings.Cut(region, "-"); ok {
+			region = r
+		}
+	}
+	region, _, _ = strings.Cut(strings.ToUpper(region), "-")
+
+	r := localeRules{dateOrder: "DMY", groupedPhones: true}
+	switch region {
+	case "US", "CA", "PH", "PR":
+		r.dateOrder, r.groupedPhones = "MDY", false
+	case "CN", "JP", "KR", "TW", "HU", "LT", "MN":
+		r.dateOrder = "YMD"
+	case "":
+		switch lang {
+		case "en":
+			r.dateOrder, r.groupedPhones = "MDY", false
+		case "zh", "ja", "ko", "hu", "lt", "mn":
+			r.dateOrder = "YMD"
+		}
+	}
+	switch lang {
+	case "de", "fr", "es", "it", "nl", "pt", "ru", "pl", "sv", "da", "nb", "nn", "no",
+		"fi", "cs", "sk", "tr", "el", "ro", "hu", "id", "uk", "hr", "sl", "sr", "bg", "lt", "lv", "et":
+		r.decimalComma = true
+	}
+	if region == "CH" || region == "LI" {
+		// Swiss German and Italian write 1'234.56.
+		r.decimalComma = lang == "fr"
+	}
+	return r
+}
+
+var (
+	isoDate    = regexp.MustCompile(`\b(\d{4})[-/.](\d{1,2})[-/.](\d{1,2})\b`)
+	localDate  = regexp.MustCompile(`\b(\d{1,4})([-/.])(\d{1,2})([-/.])(\d{2,4})\b`)
+	commaMoney = regexp.MustCompile(`\b\d{1,3}(?:[ .']\d{3})+,\d{1,2}\b`)
+	dotMoney   = regexp.MustCompile(`\b\d{1,3}(?:[ ,']\d{3})+\.\d{1,2}\b`)
+	// groupedPhone needs a country code or trunk prefix and at least
+	// three groups, to leave most other digit runs alone.
+	groupedPhone = regexp.MustCompile(`(?:\+\d{1,3}[ .]?(?:\(0\)[ ]?)?|\b0)\d{1,4}(?:[ ./-]\d{2,5}){2,5}\b|(?:\+\d{1,3}[ .]?|\b0)\d{2,4}/\d{5,9}\b`)
+)
+
+// localeView returns text with Unicode spaces and digits made ASCII and
+// the dates and amounts of rules blanked, and for each byte of it the
+// offset in text it came from (plus one entry for the end).
+func localeView(text string, rules localeRules) (string, []int) {
+	var b strings.Builder
+	b.Grow(len(text))
+	offs := make([]int, 0, len(text)+1)
+	for i, r := range text {
+		switch {
+		case r == 0x00A0 || r == 0x2007 || r == 0x2009 || r == 0x202F:
+			// No-break, figure, thin and narrow no-break spaces group
+			// digits in many locales.
+			r = ' '
+		case r >= '０' && r <= '９':
+			r = '0' + (r - '０')
+		case r == '＋':
+			r = '+'
+		case r == utf8.RuneError && !strings.HasPrefix(text[i:], "\uFFFD"):
+			// Keep invalid bytes as they are, one for one.
+			b.WriteByte(text[i])
+			offs = append(offs, i)
+			continue
+		}
+		for range utf8.RuneLen(r) {
+			offs = append(offs, i)
+		}
+		b.WriteRune(r)
+	}
+	offs = append(offs, len(text))
+	view := []byte(b.String())
+
+	blank := func(loc []int) {
+		for i := loc[0]; i < loc[1]; i++ {
+			if view[i] >= '0' && view[i] <= '9' {
+				view[i] = '#'
+			}
+		}
+	}
+	for _, loc := range isoDate.FindAllSubmatchIndex(view, -1) {
+		if validDate(view, loc[4:6], loc[6:8]) {
+			blank(loc)
+		}
+	}
+	for _, loc := range localDate.FindAllSubmatchIndex(view, -1) {
+		// Both separators the same: 15.03.2024, not 030/1234-5.
+		if view[loc[4]] != view[loc[8]] {
+			continue
+		}
+		first, second, year := loc[2:4], loc[6:8], loc[10:12]
+		var day, month []int
+		switch rules.dateOrder {
+		case "DMY":
+			day, month = first, second
+		case "MDY":
+			month, day = first, second
+		default:
+			continue // YMD is the ISO pattern's
+		}
+		if n := year[1] - year[0]; (n == 2 || n == 4) && first[1]-first[0] <= 2 && validDate(view, month, day) {
+			blank(loc)
+		}
+	}
+	money := dotMoney
+	if rules.decimalComma {
+		money = commaMoney
+	}
+	for _, loc := range money.FindAllIndex(view, -1) {
+		// Not the tail of a longer number, and not a real card number
+		// grouped like an amount.
+		if s := loc[0]; s >= 2 && strings.IndexByte(" .'", view[s-1]) >= 0 && view[s-2] >= '0' && view[s-2] <= '9' {
+			continue
+		}
+		if !cardDigits(view[loc[0]:loc[1]]) {
+			blank(loc)
+		}
+	}
+	return string(view), offs
+}
+
+// cardDigits reports whether the integer part of amount has a card
+// number's length and passes the Luhn check.
+func cardDigits(amount []byte) bool {
+	var digits []byte
+	for _, c := range amount[:bytes.LastIndexAny(amount, ",.")] {
+		if c >= '0' && c <= '9' {
+			digits = append(digits, c-'0')
+		}
+	}
+	if len(digits) < 13 || len(digits) > 16 {
+		return false
+	}
+	sum := 0
+	for i := range digits {
+		d := int(digits[len(digits)-1-i])
+		if i%2 == 1 {
+			if d *= 2; d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+	}
+	return sum%10 == 0
+}
+
+func validDate(view []byte, month, day []int) bool {
+	m, _ := strconv.Atoi(string(view[month[0]:month[1]]))
+	d, _ := strconv.Atoi(string(view[day[0]:day[1]]))
+	return m >= 1 && m <= 12 && d >= 1 && d <= 31
+}
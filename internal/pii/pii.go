@@ -0,0 +1,158 @@
+// Package pii finds personal data in text so it can be masked before the
+// model sees it. A Detector reports the entities in a text: the built-in
+// Regex detector finds card numbers, email addresses, phone numbers and
+// credentials, and HTTPDetector asks an entity recognition service (e.g. Microsoft
+// Presidio's analyzer) for the names, addresses and national IDs that
+// patterns can't find.
+package pii
+
+import (
+	"context"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Entity is one piece of personal data found in a text.
+type Entity struct {
+	// Kind is what the value is masked as, KIND_TOKEN_n: CARD, EMAIL,
+	// PHONE, or a detector's own kinds such as NAME or ADDRESS.
+	Kind string
+	// Start and End are byte offsets into the text scanned.
+	Start, End int
+	Value      string
+	// Score is the detector's confidence, 1 for patterns.
+	Score float64
+}
+
+// Detector finds personal data in text.
+type Detector interface {
+	Detect(ctx context.Context, text string) ([]Entity, error)
+}
+
+// Regex is the built-in pattern detector, with each rule switchable.
+type Regex struct {
+	Cards  bool
+	Emails bool
+	Phones bool
+	// Secrets masks credentials: API keys, tokens, private keys,
+	// passwords (see FindSecrets).
+	Secrets bool
+	// Locale, e.g. de-DE, is how numbers in the text are written: card
+	// and phone numbers are then looked for with the locale's dates and
+	// amounts set aside and its phone grouping understood (see
+	// ValidLocale). Empty keeps the rough patterns alone.
+	Locale string
+}
+
+// BuiltIn has every pattern rule on.
+var BuiltIn = Regex{Cards: true, Emails: true, Phones: true, Secrets: true}
+
+// Built-in patterns, deliberately rough.
+var (
+	cardPattern  = regexp.MustCompile(`\b(?:\d[ -]*?){13,16}\b`)
+	emailPattern = regexp.MustCompile(`[\w\.\-]+@[\w\.\-]+\.\w+`)
+	phonePattern = regexp.MustCompile(`\b\+?\d{1,3}[- ]?\d{3,5}[- ]?\d{4,10}\b`)
+)
+
+// Detect implements Detector.
+func (r Regex) Detect(_ context.Context, text string) ([]Entity, error) {
+	return r.Find(text), nil
+}
+
+// Find returns the pattern matches in text, in order. Where matches
+// overlap, secrets win over cards, cards over emails and emails over
+// phone numbers.
+func (r Regex) Find(text string) []Entity {
+	var ents []Entity
+	if r.Secrets {
+		ents = FindSecrets(text)
+	}
+	// Numbers are matched in the locale's view of the text, if any.
+	numbers, offs := text, []int(nil)
+	rules := localeRules{}
+	if r.Locale != "" {
+		rules = rulesFor(r.Locale)
+		numbers, offs = localeView(text, rules)
+	}
+	for _, rule := range []struct {
+		on      bool
+		kind    string
+		pattern *regexp.Regexp
+		number  bool
+	}{
+		{r.Cards, "CARD", cardPattern, true},
+		{r.Emails, "EMAIL", emailPattern, false},
+		// The locale's grouping first: the rough pattern would take
+		// +49 30 1234 5678 for 30 1234 5678.
+		{r.Phones && rules.groupedPhones, "PHONE", groupedPhone, true},
+		{r.Phones, "PHONE", phonePattern, true},
+	} {
+		if !rule.on {
+			continue
+		}
+		in := text
+		if rule.number {
+			in = numbers
+		}
+		for _, m := range rule.pattern.FindAllStringIndex(in, -1) {
+			if rule.number && offs != nil {
+				m[0], m[1] = offs[m[0]], offs[m[1]]
+			}
+			e := Entity{Kind: rule.kind, Start: m[0], End: m[1], Value: text[m[0]:m[1]], Score: 1}
+			if !overlaps(ents, e) {
+				ents = append(ents, e)
+			}
+		}
+	}
+	sort.Slice(ents, func(i, j int) bool { return ents[i].Start < ents[j].Start })
+	return ents
+}
+
+func overlaps(ents []Entity, e Entity) bool {
+	for _, o := range ents {
+		if e.Start < o.End && o.Start < e.End {
+			return true
+		}
+	}
+	return false
+}
+
+// Replace replaces each entity in text, which must be the text they were
+// found in, with token(kind, value). Entities overlapping an earlier one
+// are skipped.
+func Replace(text string, ents []Entity, token func(kind, value string) string) string {
+	if len(ents) == 0 {
+		return text
+	}
+	ents = append([]Entity(nil), ents...)
+	sort.SliceStable(ents, func(i, j int) bool { return ents[i].Start < ents[j].Start })
+	var b strings.Builder
+	last := 0
+	for _, e := range ents {
+		if e.Start < last || e.End > len(text) || e.Start >= e.End {
+			continue
+		}
+		b.WriteString(text[last:e.Start])
+		b.WriteString(token(e.Kind, text[e.Start:e.End]))
+		last = e.End
+	}
+	b.WriteString(text[last:])
+	return b.String()
+}
+
+// ReplaceValues replaces every occurrence of the entities' values in
+// text, longest first, with token(kind, value). It masks values found in
+// one text wherever else they appear.
+func ReplaceValues(text string, ents []Entity, token func(kind, value string) string) string {
+	ents = append([]Entity(nil), ents...)
+	sort.SliceStable(ents, func(i, j int) bool { return len(ents[i].Value) > len(ents[j].Value) })
+	for _, e := range ents {
+		// One-character values would mask half the text.
+		if len(e.Value) < 2 || !strings.Contains(text, e.Value) {
+			continue
+		}
+		text = strings.ReplaceAll(text, e.Value, token(e.Kind, e.Value))
+	}
+	return text
+}
@@ -0,0 +1,142 @@
+package policy
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"time"
+)
+
+// A bundle is a gzipped tar of the policy files (at its root, nothing else)
+// with a detached minisign signature next to it. Loading a bundle verifies
+// the signature before anything inside is parsed, so whoever can write to
+// the config store but doesn't hold the signing key can't change policy.
+const (
+	BundleFile      = "policy.tar.gz"
+	SignatureSuffix = ".minisig"
+)
+
+const maxBundleBytes = 4 << 20
+
+var bundleFiles = []string{DocumentFile, SystemPromptFile, MaskingFile, BlocklistFile}
+
+// LoadBundle verifies the bundle at path against path+".minisig" and loads
+// the policy it contains. The version is derived from the bundle's digest.
+func LoadBundle(path string, keys []PublicKey) (*Set, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("policy: %w", err)
+	}
+	sig, err := os.ReadFile(path + SignatureSuffix)
+	if err != nil {
+		return nil, fmt.Errorf("policy: bundle signature: %w", err)
+	}
+	return OpenBundle(data, sig, keys)
+}
+
+// OpenBundle is LoadBundle for a bundle and signature already in memory.
+func OpenBundle(data, sig []byte, keys []PublicKey) (*Set, error) {
+	if len(keys) == 0 {
+		return nil, errors.New("policy: no trusted keys to verify the bundle")
+	}
+	if len(data) > maxBundleBytes {
+		return nil, fmt.Errorf("policy: bundle exceeds %d bytes", maxBundleBytes)
+	}
+	if err := verify(keys, data, sig); err != nil {
+		return nil, err
+	}
+	files, err := unpack(data)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(data)
+	return load(func(name string) ([]byte, error) {
+		return files[name], nil
+	}, "bundle-"+hex.EncodeToString(sum[:6]))
+}
+
+func unpack(data []byte) (map[string][]byte, error) {
+	zr, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("policy: bundle: %w", err)
+	}
+	tr := tar.NewReader(io.LimitReader(zr, maxBundleBytes))
+	files := make(map[string][]byte)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return files, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("policy: bundle: %w", err)
+		}
+		name := path.Clean(hdr.Name)
+		if hdr.Typeflag == tar.TypeDir && name == "." {
+			continue
+		}
+		if hdr.Typeflag != tar.TypeReg || !isBundleFile(name) {
+			return nil, fmt.Errorf("policy: bundle: unexpected entry %q", hdr.Name)
+		}
+		if _, dup := files[name]; dup {
+			return nil, fmt.Errorf("policy: bundle: duplicate entry %q", name)
+		}
+		b, err := io.ReadAll(tr)
+		if err != nil {
+			return nil, fmt.Errorf("policy: bundle: %w", err)
+		}
+		files[name] = b
+	}
+}
+
+func isBundleFile(name string) bool {
+	for _, f := range bundleFiles {
+		if name == f {
+			return true
+		}
+	}
+	return false
+}
+
+// WriteBundle validates the policy files in dir and writes them to w as an
+// unsigned bundle. The output only depends on the file contents, so the
+// same directory always produces the same bundle.
+func WriteBundle(w io.Writer, dir string) error {
+	if _, err := LoadDir(dir, ""); err != nil {
+		return err
+	}
+	zw := gzip.NewWriter(w)
+	tw := tar.NewWriter(zw)
+	for _, name := range bundleFiles {
+		b, err := readOptional(filepath.Join(dir, name))
+		if err != nil {
+			return err
+		}
+		if b == nil {
+			continue
+		}
+		hdr := &tar.Header{
+			Name:    name,
+			Mode:    0o644,
+			Size:    int64(len(b)),
+			ModTime: time.Unix(0, 0),
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if _, err := tw.Write(b); err != nil {
+			return err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return zw.Close()
+}
@@ -0,0 +1,210 @@
+package policy
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// GitSyncer keeps a Store in step with a branch of a Git repository, so
+// every safety configuration change is a reviewed commit. New commits are
+// picked up by polling or through the webhook; each is validated before it
+// is applied, and a commit that fails validation leaves the active set in
+// place.
+type GitSyncer struct {
+	Store  *Store
+	Repo   string // clone URL
+	Branch string // default "main"
+	Path   string // directory of the policy files inside the repository
+	Dir    string // local checkout
+	// Interval between polls; 0 relies on the webhook alone.
+	Interval time.Duration
+	// Secret authenticates webhook calls: GitHub's X-Hub-Signature-256,
+	// GitLab's X-Gitlab-Token or "Authorization: Bearer <secret>".
+	// Rollback requires it.
+	Secret string
+	// Keys, if set, makes the syncer load only a signed bundle
+	// (BundleFile under Path) and ignore loose policy files, so a push
+	// to the repository alone can't change policy.
+	Keys []PublicKey
+	// Hold, if set, skips polls while it returns true, e.g. while the
+	// gateway is read-only.
+	Hold func() bool
+
+	mu    sync.Mutex
+	tried string // last commit attempted, whether or not it was valid
+}
+
+// Sync fetches the branch head and applies it if it is a new commit.
+func (g *GitSyncer) Sync(ctx context.Context) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if err := g.fetch(ctx); err != nil {
+		return err
+	}
+	commit, err := g.git(ctx, "rev-parse", "FETCH_HEAD")
+	if err != nil {
+		return err
+	}
+	if commit == g.tried {
+		return nil
+	}
+	g.tried = commit
+
+	// The active set lives in memory, so checking out a bad commit changes
+	// nothing until it has validated.
+	if _, err := g.git(ctx, "checkout", "--force", "--detach", commit); err != nil {
+		return err
+	}
+	version := commit
+	if len(version) > 12 {
+		version = version[:12]
+	}
+	dir := filepath.Join(g.Dir, g.Path)
+	var set *Set
+	if len(g.Keys) > 0 {
+		set, err = LoadBundle(filepath.Join(dir, BundleFile), g.Keys)
+		if set != nil {
+			set.Version = version + "/" + set.Version
+		}
+	} else {
+		set, err = LoadDir(dir, version)
+	}
+	if err != nil {
+		return fmt.Errorf("policy commit %s rejected: %w", version, err)
+	}
+	g.Store.Apply(set)
+	return nil
+}
+
+func (g *GitSyncer) fetch(ctx context.Context) error {
+	if _, err := os.Stat(filepath.Join(g.Dir, ".git")); errors.Is(err, os.ErrNotExist) {
+		if err := os.MkdirAll(g.Dir, 0o700); err != nil {
+			return fmt.Errorf("policy: create checkout dir: %w", err)
+		}
+		if _, err := g.git(ctx, "init", "--quiet"); err != nil {
+			return err
+		}
+		if _, err := g.git(ctx, "remote", "add", "origin", g.Repo); err != nil {
+			return err
+		}
+	}
+	_, err := g.git(ctx, "fetch", "--quiet", "--depth", "1", "origin", g.branch())
+	return err
+}
+
+func (g *GitSyncer) branch() string {
+	if g.Branch == "" {
+		return "main"
+	}
+	return g.Branch
+}
+
+// Run polls every Interval until ctx is done.
+func (g *GitSyncer) Run(ctx context.Context) {
+	if g.Interval <= 0 {
+		return
+	}
+	t := time.NewTicker(g.Interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			if g.Hold != nil && g.Hold() {
+				continue
+			}
+			if err := g.Sync(ctx); err != nil {
+				log.Printf("policy sync error: %v", err)
+			}
+		}
+	}
+}
+
+// Handler serves POST /internal/policy/sync (the push webhook) and
+// POST /internal/policy/rollback, which restores the previous set until
+// the next new commit.
+func (g *GitSyncer) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /internal/policy/sync", g.syncHandler)
+	mux.HandleFunc("POST /internal/policy/rollback", g.rollbackHandler)
+	return mux
+}
+
+func (g *GitSyncer) syncHandler(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(io.LimitReader(r.Body, 1<<20))
+	if err != nil {
+		http.Error(w, "read body", http.StatusBadRequest)
+		return
+	}
+	if g.Secret != "" && !g.bearer(r) && !g.signed(r, body) {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if err := g.Sync(r.Context()); err != nil {
+		log.Printf("policy sync error: %v", err)
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+	writeVersion(w, g.Store.Current())
+}
+
+func (g *GitSyncer) rollbackHandler(w http.ResponseWriter, r *http.Request) {
+	if g.Secret == "" || !g.bearer(r) {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	set, err := g.Store.Rollback()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	writeVersion(w, set)
+}
+
+func (g *GitSyncer) bearer(r *http.Request) bool {
+	got := r.Header.Get("X-Gitlab-Token")
+	if got == "" {
+		got = strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	}
+	return subtle.ConstantTimeCompare([]byte(got), []byte(g.Secret)) == 1
+}
+
+// signed checks a GitHub-style X-Hub-Signature-256 HMAC of the body.
+func (g *GitSyncer) signed(r *http.Request, body []byte) bool {
+	sig, ok := strings.CutPrefix(r.Header.Get("X-Hub-Signature-256"), "sha256=")
+	if !ok {
+		return false
+	}
+	got, err := hex.DecodeString(sig)
+	if err != nil {
+		return false
+	}
+	mac := hmac.New(sha256.New, []byte(g.Secret))
+	mac.Write(body)
+	return hmac.Equal(got, mac.Sum(nil))
+}
+
+func writeVersion(w http.ResponseWriter, set *Set) {
+	version := ""
+	if set != nil {
+		version = set.Version
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"version": version})
+}
@@ -0,0 +1,14 @@
+//go:build minimal
+
+package policy
+
+import (
+	"context"
+	"errors"
+)
+
+// Minimal builds run no external programs, so Git sync is unavailable;
+// ship policy as a signed bundle instead.
+func (g *GitSyncer) git(ctx context.Context, args ...string) (string, error) {
+	return "", errors.New("policy: git sync is not available in minimal builds")
+}
@@ -0,0 +1,114 @@
+package policy
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/blake2b"
+)
+
+// Bundles are signed with minisign (https://jedisct1.github.io/minisign/);
+// the gateway only needs the public key:
+//
+//	nopass policy bundle ./policies policy.tar.gz
+//	minisign -Sm policy.tar.gz        # writes policy.tar.gz.minisig
+
+var (
+	ErrUnknownKey   = errors.New("policy: signed by an untrusted key")
+	ErrBadSignature = errors.New("policy: signature verification failed")
+)
+
+// PublicKey is a minisign Ed25519 public key.
+type PublicKey struct {
+	ID  [8]byte
+	Key ed25519.PublicKey
+}
+
+// ParsePublicKey accepts the base64 key line of a minisign .pub file, or the
+// whole file including its untrusted comment.
+func ParsePublicKey(s string) (PublicKey, error) {
+	var line string
+	for _, l := range strings.Split(s, "\n") {
+		l = strings.TrimSpace(l)
+		if l != "" && !strings.HasPrefix(l, "untrusted comment:") {
+			line = l
+		}
+	}
+	raw, err := base64.StdEncoding.DecodeString(line)
+	if err != nil || len(raw) != 2+8+ed25519.PublicKeySize || string(raw[:2]) != "Ed" {
+		return PublicKey{}, fmt.Errorf("policy: invalid minisign public key %q", line)
+	}
+	var k PublicKey
+	copy(k.ID[:], raw[2:10])
+	k.Key = ed25519.PublicKey(raw[10:])
+	return k, nil
+}
+
+// ParsePublicKeys parses a comma-separated list of keys, so a new signing
+// key can be rolled out before the old one is retired.
+func ParsePublicKeys(list string) ([]PublicKey, error) {
+	var keys []PublicKey
+	for _, s := range strings.Split(list, ",") {
+		if s = strings.TrimSpace(s); s == "" {
+			continue
+		}
+		k, err := ParsePublicKey(s)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, k)
+	}
+	return keys, nil
+}
+
+// verify checks a .minisig file for msg against the trusted keys. Both the
+// legacy ("Ed") and the prehashed ("ED", minisign's default) signature
+// algorithms are accepted; the trusted comment's global signature is
+// checked too, so the comment can't be swapped either.
+func verify(keys []PublicKey, msg, sigFile []byte) error {
+	lines := strings.Split(strings.ReplaceAll(string(sigFile), "\r\n", "\n"), "\n")
+	if len(lines) < 4 || !strings.HasPrefix(lines[0], "untrusted comment:") {
+		return fmt.Errorf("%w: malformed signature file", ErrBadSignature)
+	}
+	sig, err := base64.StdEncoding.DecodeString(strings.TrimSpace(lines[1]))
+	if err != nil || len(sig) != 2+8+ed25519.SignatureSize {
+		return fmt.Errorf("%w: malformed signature", ErrBadSignature)
+	}
+	trusted, ok := strings.CutPrefix(lines[2], "trusted comment: ")
+	if !ok {
+		return fmt.Errorf("%w: missing trusted comment", ErrBadSignature)
+	}
+	global, err := base64.StdEncoding.DecodeString(strings.TrimSpace(lines[3]))
+	if err != nil || len(global) != ed25519.SignatureSize {
+		return fmt.Errorf("%w: malformed global signature", ErrBadSignature)
+	}
+
+	switch string(sig[:2]) {
+	case "Ed":
+	case "ED":
+		h := blake2b.Sum512(msg)
+		msg = h[:]
+	default:
+		return fmt.Errorf("%w: unsupported algorithm %q", ErrBadSignature, sig[:2])
+	}
+	var key ed25519.PublicKey
+	for _, k := range keys {
+		if bytes.Equal(k.ID[:], sig[2:10]) {
+			key = k.Key
+		}
+	}
+	if key == nil {
+		return ErrUnknownKey
+	}
+	if !ed25519.Verify(key, msg, sig[10:]) {
+		return ErrBadSignature
+	}
+	if !ed25519.Verify(key, append(sig[10:len(sig):len(sig)], trusted...), global) {
+		return fmt.Errorf("%w: trusted comment", ErrBadSignature)
+	}
+	return nil
+}
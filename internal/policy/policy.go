@@ -0,0 +1,256 @@
+// Package policy holds the safety configuration that is meant to change
+// through code review rather than deploys: the sandbox system prompt,
+// the slow-path thresholds, refusal behavior, extra masking rules, the
+// external-data sources allowed and the blocklist, with named profiles and
+// per-tenant overrides. A Set is loaded from a directory
+// (typically a Git checkout, see GitSyncer), validated as a whole, and
+// swapped in atomically through a Store.
+//
+// Directory layout, every file optional:
+//
+//	policy.yaml         prompt rules, path thresholds, refusals, sources, profiles, tenants (see Document)
+//	system_prompt.txt   replaces the built-in sandbox system prompt verbatim
+//	masking.json        [{"name": "IBAN", "pattern": "\\bGB\\d{2}[A-Z]{4}\\d{14}\\b"}]
+//	blocklist.txt       one term per line, matched case-insensitively; # comments
+//
+// The same files can be shipped as a signed bundle instead (see LoadBundle),
+// which the gateway verifies before loading.
+package policy
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// File names inside a policy directory.
+const (
+	DocumentFile     = "policy.yaml"
+	SystemPromptFile = "system_prompt.txt"
+	MaskingFile      = "masking.json"
+	BlocklistFile    = "blocklist.txt"
+)
+
+// maxSystemPromptBytes keeps a bad commit from blowing the prompt budget.
+const maxSystemPromptBytes = 64 << 10
+
+// Set is one validated version of the policy files.
+type Set struct {
+	// Version identifies where the set came from, e.g. a Git commit.
+	Version string
+	// Tenant and Profile are set on the sets Resolve returns with a
+	// tenant's overrides or a profile applied.
+	Tenant       string
+	Profile      string
+	SystemPrompt string // "" keeps the built-in prompt
+	Masking      []MaskRule
+	Blocklist    []string
+	// Paths overrides the configured slow-path thresholds; zero fields
+	// leave them.
+	Paths   Paths
+	Refusal Refusal
+	Sources Sources
+	Models  Models
+	// ToolAnswers are the tool results answers may bypass review with.
+	ToolAnswers ToolAnswers
+	// RestoreTokens are the token kinds restored in answers.
+	RestoreTokens []string
+	// Tuning lists the runtime adjustments applied on top (see Override).
+	Tuning []string
+
+	promptKey  string
+	promptFile string  // where SystemPrompt came from
+	prompt     *Prompt // the rules SystemPrompt was rendered from, if any
+	variants   map[variant]*Set
+}
+
+// MaskRule masks every match of Pattern as NAME_TOKEN_n, like the built-in
+// card, email and phone rules.
+type MaskRule struct {
+	Name    string `json:"name" yaml:"name"`
+	Pattern string `json:"pattern" yaml:"pattern"`
+
+	re *regexp.Regexp
+}
+
+var ruleName = regexp.MustCompile(`^[A-Z][A-Z0-9_]*$`)
+
+// LoadDir reads and validates the policy files in dir.
+func LoadDir(dir, version string) (*Set, error) {
+	return load(func(name string) ([]byte, error) {
+		return readOptional(filepath.Join(dir, name))
+	}, version)
+}
+
+// load builds a Set from the policy files returned by read, which returns
+// nil for a missing file.
+func load(read func(name string) ([]byte, error), version string) (*Set, error) {
+	s := &Set{Version: version}
+
+	prompt, err := read(SystemPromptFile)
+	if err != nil {
+		return nil, err
+	}
+	s.SystemPrompt, s.promptFile = string(prompt), SystemPromptFile
+
+	if raw, err := read(MaskingFile); err != nil {
+		return nil, err
+	} else if raw != nil {
+		if err := json.Unmarshal(raw, &s.Masking); err != nil {
+			return nil, fmt.Errorf("policy: parse %s: %w", MaskingFile, err)
+		}
+	}
+
+	if raw, err := read(BlocklistFile); err != nil {
+		return nil, err
+	} else {
+		for _, line := range strings.Split(string(raw), "\n") {
+			line = strings.TrimSpace(line)
+			if line != "" && !strings.HasPrefix(line, "#") {
+				s.Blocklist = append(s.Blocklist, strings.ToLower(line))
+			}
+		}
+	}
+
+	if raw, err := read(DocumentFile); err != nil {
+		return nil, err
+	} else if raw != nil {
+		if err := s.loadDocument(raw); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := s.validate(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func readOptional(path string) ([]byte, error) {
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("policy: %w", err)
+	}
+	return b, nil
+}
+
+// validate compiles the rules and rejects sets that would weaken the
+// sandbox rather than tune it.
+func (s *Set) validate() error {
+	if err := s.compile(""); err != nil {
+		return err
+	}
+	for v, t := range s.variants {
+		where := "profile " + v.profile
+		if v.tenant != "" {
+			where = "tenant " + v.tenant
+			if v.profile != "" {
+				where += " with profile " + v.profile
+			}
+		}
+		if err := t.compile(where); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// compile checks the prompt and compiles the masking rules of one set;
+// where names a profile or tenant set in errors.
+func (s *Set) compile(where string) error {
+	if err := s.checkPrompt(where); err != nil {
+		return err
+	}
+	if where != "" {
+		where = " (" + where + ")"
+	}
+	seen := make(map[string]bool)
+	for i := range s.Masking {
+		r := &s.Masking[i]
+		if !ruleName.MatchString(r.Name) {
+			return fmt.Errorf("policy: masking rule %q%s: name must be upper-case letters, digits and _", r.Name, where)
+		}
+		if seen[r.Name] {
+			return fmt.Errorf("policy: duplicate masking rule %q%s", r.Name, where)
+		}
+		seen[r.Name] = true
+		re, err := regexp.Compile(r.Pattern)
+		if err != nil {
+			return fmt.Errorf("policy: masking rule %s%s: %w", r.Name, where, err)
+		}
+		if re.MatchString("") {
+			return fmt.Errorf("policy: masking rule %s%s matches the empty string", r.Name, where)
+		}
+		r.re = re
+	}
+	return nil
+}
+
+// checkPrompt checks the system prompt and computes its cache key.
+func (s *Set) checkPrompt(set string) error {
+	if s.SystemPrompt == "" {
+		return nil
+	}
+	where := s.promptFile
+	if set != "" {
+		where += " (" + set + ")"
+	}
+	if len(s.SystemPrompt) > maxSystemPromptBytes {
+		return fmt.Errorf("policy: %s: prompt exceeds %d bytes", where, maxSystemPromptBytes)
+	}
+	// The prompt builder wraps untrusted content in <data> tags; a
+	// prompt that doesn't tell the model so drops that defense.
+	if !strings.Contains(s.SystemPrompt, "<data>") {
+		return fmt.Errorf("policy: %s: prompt must explain the <data> tags", where)
+	}
+	sum := sha256.Sum256([]byte(s.SystemPrompt))
+	s.promptKey = hex.EncodeToString(sum[:16])
+	return nil
+}
+
+// PromptCacheKey identifies SystemPrompt for prompt caching.
+func (s *Set) PromptCacheKey() string { return s.promptKey }
+
+// Mask applies the set's masking rules to text. A nil set leaves text
+// unchanged.
+func (s *Set) Mask(text string) string {
+	next := make(map[string]int)
+	return s.MaskFunc(text, func(name, _ string) string {
+		next[name]++
+		return fmt.Sprintf("%s_TOKEN_%d", name, next[name])
+	})
+}
+
+// MaskFunc is Mask with each match replaced by token(rule name, match).
+func (s *Set) MaskFunc(text string, token func(name, value string) string) string {
+	if s == nil {
+		return text
+	}
+	for _, r := range s.Masking {
+		text = r.re.ReplaceAllStringFunc(text, func(v string) string { return token(r.Name, v) })
+	}
+	return text
+}
+
+// Blocked returns the first blocklisted term text contains.
+func (s *Set) Blocked(text string) (string, bool) {
+	if s == nil || len(s.Blocklist) == 0 {
+		return "", false
+	}
+	lower := strings.ToLower(text)
+	for _, term := range s.Blocklist {
+		if strings.Contains(lower, term) {
+			return term, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,59 @@
+package policy
+
+import (
+	"errors"
+	"log"
+	"sync"
+	"sync/atomic"
+)
+
+// ErrNoPrevious means there is no earlier set to roll back to.
+var ErrNoPrevious = errors.New("policy: no previous version to roll back to")
+
+// historySize is how many superseded sets are kept for rollback.
+const historySize = 10
+
+// Store holds the active Set. Readers never see a partially applied set:
+// Apply swaps the whole set in one step.
+type Store struct {
+	cur atomic.Pointer[Set]
+
+	mu      sync.Mutex
+	history []*Set // superseded sets, oldest first
+}
+
+// Current returns the active set, or nil if none was applied (built-in
+// defaults apply). It is safe to call on a nil Store.
+func (s *Store) Current() *Set {
+	if s == nil {
+		return nil
+	}
+	return s.cur.Load()
+}
+
+// Apply makes set the active set.
+func (s *Store) Apply(set *Set) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if old := s.cur.Swap(set); old != nil {
+		s.history = append(s.history, old)
+		if len(s.history) > historySize {
+			s.history = s.history[1:]
+		}
+	}
+	log.Printf("policy version %s applied", set.Version)
+}
+
+// Rollback restores the set that was active before the current one.
+func (s *Store) Rollback() (*Set, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.history) == 0 {
+		return nil, ErrNoPrevious
+	}
+	prev := s.history[len(s.history)-1]
+	s.history = s.history[:len(s.history)-1]
+	s.cur.Store(prev)
+	log.Printf("policy rolled back to version %s", prev.Version)
+	return prev, nil
+}
@@ -0,0 +1,74 @@
+package postprocess
+
+import (
+	"context"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/shivansh-source/nopass/internal/types"
+)
+
+var (
+	mdLink   = regexp.MustCompile(`\[([^\]]+)\]\((https?://[^)\s]+)\)`)
+	mdBold   = regexp.MustCompile(`\*\*([^*]+)\*\*`)
+	mdHeader = regexp.MustCompile(`(?m)^#{1,6}\s+(.+)$`)
+	mdCode   = regexp.MustCompile("`([^`]+)`")
+	bareURL  = regexp.MustCompile(`https?://[^\s)\]>]+`)
+)
+
+// markdownToSlack rewrites common markdown to Slack mrkdwn.
+func markdownToSlack(_ context.Context, resp *types.ChatResponse) error {
+	s := resp.Answer
+	s = mdLink.ReplaceAllString(s, "<$2|$1>")
+	s = mdBold.ReplaceAllString(s, "*$1*")
+	s = mdHeader.ReplaceAllString(s, "*$1*")
+	resp.Answer = s
+	return nil
+}
+
+// markdownToPlain strips markdown formatting, keeping link targets inline.
+func markdownToPlain(_ context.Context, resp *types.ChatResponse) error {
+	s := resp.Answer
+	s = mdLink.ReplaceAllString(s, "$1 ($2)")
+	s = mdBold.ReplaceAllString(s, "$1")
+	s = mdHeader.ReplaceAllString(s, "$1")
+	s = mdCode.ReplaceAllString(s, "$1")
+	resp.Answer = s
+	return nil
+}
+
+// extractCitations turns markdown links and bare URLs in the answer into
+// numbered citation objects, replacing them in the text with [n] markers.
+func extractCitations(_ context.Context, resp *types.ChatResponse) error {
+	seen := make(map[string]int)
+	cite := func(url, title string) string {
+		n, ok := seen[url]
+		if !ok {
+			n = len(resp.Citations) + 1
+			seen[url] = n
+			resp.Citations = append(resp.Citations, types.Citation{Index: n, URL: url, Title: title})
+		}
+		return "[" + strconv.Itoa(n) + "]"
+	}
+
+	s := mdLink.ReplaceAllStringFunc(resp.Answer, func(m string) string {
+		sub := mdLink.FindStringSubmatch(m)
+		return sub[1] + " " + cite(sub[2], sub[1])
+	})
+	s = bareURL.ReplaceAllStringFunc(s, func(u string) string {
+		return cite(strings.TrimRight(u, ".,;:"), "")
+	})
+	resp.Answer = s
+	return nil
+}
+
+// Disclaimer returns a processor that appends text to every answer.
+func Disclaimer(text string) Processor {
+	return ProcessorFunc(func(_ context.Context, resp *types.ChatResponse) error {
+		if text != "" && !strings.HasSuffix(resp.Answer, text) {
+			resp.Answer = strings.TrimRight(resp.Answer, "\n") + "\n\n" + text
+		}
+		return nil
+	})
+}
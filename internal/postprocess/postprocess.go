@@ -0,0 +1,123 @@
+// Package postprocess runs deployment-specific transforms on the final,
+// already safety-reviewed answer: format conversion, disclaimers, citation
+// extraction and so on. Processors run after output safety, so they must
+// never reintroduce content the reviewer removed.
+package postprocess
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/shivansh-source/nopass/internal/types"
+)
+
+// Processor transforms a chat response in place.
+type Processor interface {
+	Process(ctx context.Context, resp *types.ChatResponse) error
+}
+
+// ProcessorFunc adapts a function to Processor.
+type ProcessorFunc func(ctx context.Context, resp *types.ChatResponse) error
+
+// Process implements Processor.
+func (f ProcessorFunc) Process(ctx context.Context, resp *types.ChatResponse) error {
+	return f(ctx, resp)
+}
+
+// Registry holds named processors and the chain configured per tenant.
+type Registry struct {
+	mu         sync.RWMutex
+	processors map[string]Processor
+	chains     map[string][]string
+}
+
+// DefaultTenant is the chain key used for tenants without their own chain.
+const DefaultTenant = "default"
+
+// NewRegistry returns a Registry with the built-in processors registered.
+func NewRegistry() *Registry {
+	r := &Registry{
+		processors: make(map[string]Processor),
+		chains:     make(map[string][]string),
+	}
+	r.Register("markdown_to_slack", ProcessorFunc(markdownToSlack))
+	r.Register("markdown_to_plain", ProcessorFunc(markdownToPlain))
+	r.Register("citations", ProcessorFunc(extractCitations))
+	return r
+}
+
+// Register adds (or replaces) a named processor.
+func (r *Registry) Register(name string, p Processor) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.processors[name] = p
+}
+
+// SetChain configures the ordered processors run for tenantID.
+func (r *Registry) SetChain(tenantID string, names []string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, n := range names {
+		if _, ok := r.processors[n]; !ok {
+			return fmt.Errorf("unknown post-processor %q (known: %s)", n, strings.Join(r.namesLocked(), ", "))
+		}
+	}
+	r.chains[tenantID] = names
+	return nil
+}
+
+// Run applies the tenant's chain (or the default chain) to resp.
+func (r *Registry) Run(ctx context.Context, tenantID string, resp *types.ChatResponse) error {
+	r.mu.RLock()
+	names, ok := r.chains[tenantID]
+	if !ok {
+		names = r.chains[DefaultTenant]
+	}
+	chain := make([]Processor, 0, len(names))
+	for _, n := range names {
+		chain = append(chain, r.processors[n])
+	}
+	r.mu.RUnlock()
+
+	for i, p := range chain {
+		if err := p.Process(ctx, resp); err != nil {
+			return fmt.Errorf("post-processor %s: %w", names[i], err)
+		}
+	}
+	return nil
+}
+
+// ParseChains parses "default=disclaimer;acme=markdown_to_slack,citations".
+func ParseChains(spec string) (map[string][]string, error) {
+	out := make(map[string][]string)
+	for _, entry := range strings.Split(spec, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		tenant, list, ok := strings.Cut(entry, "=")
+		if !ok || tenant == "" {
+			return nil, fmt.Errorf("post-processor entry %q: want tenant=name[,name]", entry)
+		}
+		var names []string
+		for _, n := range strings.Split(list, ",") {
+			if n = strings.TrimSpace(n); n != "" {
+				names = append(names, n)
+			}
+		}
+		out[tenant] = names
+	}
+	return out, nil
+}
+
+func (r *Registry) namesLocked() []string {
+	names := make([]string, 0, len(r.processors))
+	for n := range r.processors {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,205 @@
+// Package profanity filters profanity and harassment out of answers, with
+// a severity threshold and replacement behaviour chosen per tenant. It is
+// stricter tone control for consumer-facing surfaces than the generic
+// output safety review gives.
+package profanity
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/shivansh-source/nopass/internal/metrics"
+)
+
+// Severity ranks how offensive a term is.
+type Severity int
+
+const (
+	Low Severity = iota + 1
+	Medium
+	High
+)
+
+// ParseSeverity parses "low", "medium" or "high".
+func ParseSeverity(s string) (Severity, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "low":
+		return Low, nil
+	case "medium":
+		return Medium, nil
+	case "high":
+		return High, nil
+	default:
+		return 0, fmt.Errorf("unknown severity %q", s)
+	}
+}
+
+// Category is what kind of content a term is.
+type Category string
+
+const (
+	Profanity  Category = "profanity"
+	Harassment Category = "harassment"
+)
+
+// Action is what happens to an answer with terms at or above the
+// threshold.
+type Action string
+
+const (
+	ActionMask   Action = "mask"   // keep the first letter, star out the rest
+	ActionRemove Action = "remove" // drop the term
+	ActionRefuse Action = "refuse" // withhold the whole answer
+)
+
+// Policy is one tenant's setting.
+type Policy struct {
+	Threshold Severity
+	Action    Action
+}
+
+type term struct {
+	category Category
+	severity Severity
+	re       *regexp.Regexp
+}
+
+func word(category Category, severity Severity, pattern string) term {
+	return term{category, severity, regexp.MustCompile(`(?i)\b(?:` + pattern + `)\b`)}
+}
+
+// terms is deliberately short: common English profanity, with inflections,
+// and harassment phrased at the reader.
+var terms = []term{
+	word(Profanity, Low, `damn(?:ed|it)?|crap(?:py)?|hell|sucks?|bloody|freaking`),
+	word(Profanity, Medium, `shit\w*|bullshit|piss(?:ed)?|bastards?|ass(?:hole)?s?|bitch\w*|dick(?:head)?s?|prick`),
+	word(Profanity, High, `fuck\w*|motherfuck\w*|cunts?`),
+	word(Harassment, Low, `shut up|get lost`),
+	word(Harassment, Medium, `you(?:'re| are) (?:an? )?(?:idiot|moron|stupid|loser|pathetic|worthless|useless)|nobody (?:likes|cares about) you`),
+	word(Harassment, High, `(?:go )?kill yourself|kys|i hope you die|you deserve to die`),
+}
+
+// DefaultTenant is the key used for tenants without their own policy.
+const DefaultTenant = "default"
+
+// Filter applies per-tenant policies. Tenants without a policy, and
+// without a DefaultTenant one, are not filtered. A nil Filter filters
+// nothing.
+type Filter struct {
+	Tenants map[string]Policy
+}
+
+var matches = metrics.NewCounterVec(
+	"nopass_profanity_matches_total",
+	"Answers the profanity filter acted on.",
+	"category", "action",
+)
+
+// ParseTenants parses "default=medium:mask;kids=low:refuse".
+func ParseTenants(spec string) (map[string]Policy, error) {
+	out := make(map[string]Policy)
+	for _, entry := range strings.Split(spec, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		tenant, setting, ok := strings.Cut(entry, "=")
+		level, action, ok2 := strings.Cut(setting, ":")
+		if !ok || !ok2 || tenant == "" {
+			return nil, fmt.Errorf("profanity entry %q: want tenant=severity:action", entry)
+		}
+		sev, err := ParseSeverity(level)
+		if err != nil {
+			return nil, fmt.Errorf("profanity entry %q: %w", entry, err)
+		}
+		p := Policy{Threshold: sev, Action: Action(strings.TrimSpace(action))}
+		switch p.Action {
+		case ActionMask, ActionRemove, ActionRefuse:
+		default:
+			return nil, fmt.Errorf("profanity entry %q: unknown action %q", entry, action)
+		}
+		out[tenant] = p
+	}
+	return out, nil
+}
+
+// policy returns the tenant's policy, falling back to DefaultTenant.
+func (f *Filter) policy(tenantID string) (Policy, bool) {
+	if f == nil {
+		return Policy{}, false
+	}
+	if p, ok := f.Tenants[tenantID]; ok {
+		return p, true
+	}
+	p, ok := f.Tenants[DefaultTenant]
+	return p, ok
+}
+
+// Enabled reports whether tenantID's answers are filtered.
+func (f *Filter) Enabled(tenantID string) bool {
+	_, ok := f.policy(tenantID)
+	return ok
+}
+
+// Result is the outcome of filtering one answer.
+type Result struct {
+	Text       string     // the filtered answer; unchanged when Refuse is set
+	Categories []Category // categories found at or above the threshold, sorted
+	Refuse     bool       // the tenant's policy withholds the answer
+}
+
+// Apply filters text under tenantID's policy.
+func (f *Filter) Apply(tenantID, text string) Result {
+	res := Result{Text: text}
+	p, ok := f.policy(tenantID)
+	if !ok {
+		return res
+	}
+	found := map[Category]bool{}
+	for _, t := range terms {
+		if t.severity < p.Threshold || !t.re.MatchString(res.Text) {
+			continue
+		}
+		found[t.category] = true
+		switch p.Action {
+		case ActionMask:
+			res.Text = t.re.ReplaceAllStringFunc(res.Text, mask)
+		case ActionRemove:
+			res.Text = t.re.ReplaceAllString(res.Text, "")
+		}
+	}
+	for c := range found {
+		res.Categories = append(res.Categories, c)
+		matches.Inc(string(c), string(p.Action))
+	}
+	sort.Slice(res.Categories, func(i, j int) bool { return res.Categories[i] < res.Categories[j] })
+	switch {
+	case len(found) == 0:
+	case p.Action == ActionRefuse:
+		res.Refuse = true
+	case p.Action == ActionRemove:
+		res.Text = doubleSpace.ReplaceAllString(res.Text, " ")
+	}
+	return res
+}
+
+var doubleSpace = regexp.MustCompile(`[ \t]{2,}`)
+
+// mask keeps the first letter of every word of s and stars out the rest.
+func mask(s string) string {
+	b := []byte(s)
+	start := true
+	for i, c := range b {
+		switch {
+		case c == ' ':
+			start = true
+		case start:
+			start = false
+		default:
+			b[i] = '*'
+		}
+	}
+	return string(b)
+}
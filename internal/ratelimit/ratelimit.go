@@ -0,0 +1,189 @@
+// Package ratelimit limits request rates with token buckets per user,
+// session and API key. Buckets live in memory, per gateway instance, or in
+// a storage.BucketStore (Redis) shared by every instance.
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/shivansh-source/nopass/internal/auth"
+	"github.com/shivansh-source/nopass/internal/metrics"
+	"github.com/shivansh-source/nopass/internal/storage"
+)
+
+// Limit is a token bucket's shape: Rate tokens per second, up to Burst.
+type Limit struct {
+	Rate  float64
+	Burst int
+}
+
+// Limits are the buckets each request draws from; zero limits are off.
+type Limits struct {
+	User    Limit
+	Session Limit
+	Key     Limit
+}
+
+// ParseLimits parses "user=60/m:20,session=20/m,key=10/s". A rate is a
+// count per s, m or h; the burst defaults to the count.
+func ParseLimits(spec string) (Limits, error) {
+	var out Limits
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		scope, value, ok := strings.Cut(entry, "=")
+		if !ok {
+			return Limits{}, fmt.Errorf("rate limit %q: want scope=count/unit[:burst]", entry)
+		}
+		l, err := parseLimit(value)
+		if err != nil {
+			return Limits{}, fmt.Errorf("rate limit %q: %w", entry, err)
+		}
+		switch scope {
+		case "user":
+			out.User = l
+		case "session":
+			out.Session = l
+		case "key":
+			out.Key = l
+		default:
+			return Limits{}, fmt.Errorf("rate limit %q: unknown scope %q", entry, scope)
+		}
+	}
+	return out, nil
+}
+
+func parseLimit(s string) (Limit, error) {
+	rate, burst, hasBurst := strings.Cut(s, ":")
+	count, unit, ok := strings.Cut(rate, "/")
+	n, err := strconv.Atoi(count)
+	if !ok || err != nil || n <= 0 {
+		return Limit{}, fmt.Errorf("bad rate %q", rate)
+	}
+	per := map[string]float64{"s": 1, "m": 60, "h": 3600}[unit]
+	if per == 0 {
+		return Limit{}, fmt.Errorf("unknown unit %q", unit)
+	}
+	l := Limit{Rate: float64(n) / per, Burst: n}
+	if hasBurst {
+		if l.Burst, err = strconv.Atoi(burst); err != nil || l.Burst <= 0 {
+			return Limit{}, fmt.Errorf("bad burst %q", burst)
+		}
+	}
+	return l, nil
+}
+
+// Memory is a storage.BucketStore private to this process.
+type Memory struct {
+	mu      sync.Mutex
+	buckets map[string]*bucket
+	swept   time.Time
+}
+
+type bucket struct {
+	tokens float64
+	ts     time.Time
+	full   time.Time // when the bucket is full again and may be dropped
+}
+
+// Take implements storage.BucketStore.
+func (m *Memory) Take(_ context.Context, key string, rate float64, burst int) (bool, time.Duration, error) {
+	now := time.Now()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.buckets == nil {
+		m.buckets = make(map[string]*bucket)
+	}
+	if now.Sub(m.swept) > time.Minute {
+		for k, b := range m.buckets {
+			if now.After(b.full) {
+				delete(m.buckets, k)
+			}
+		}
+		m.swept = now
+	}
+	b, ok := m.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(burst), ts: now}
+		m.buckets[key] = b
+	}
+	b.tokens = math.Min(float64(burst), b.tokens+now.Sub(b.ts).Seconds()*rate)
+	b.ts = now
+	allowed := b.tokens >= 1
+	var wait time.Duration
+	if allowed {
+		b.tokens--
+	} else {
+		wait = time.Duration((1 - b.tokens) / rate * float64(time.Second))
+	}
+	b.full = now.Add(time.Duration((float64(burst) - b.tokens) / rate * float64(time.Second)))
+	return allowed, wait, nil
+}
+
+// Limiter is the rate limiting middleware.
+type Limiter struct {
+	Buckets storage.BucketStore
+	Limits  Limits
+	// Caller returns the tenant, user and session a request is for,
+	// without consuming its body (gateway.RequestCaller).
+	Caller func(r *http.Request) (tenantID, userID, sessionID string)
+}
+
+// check is one bucket a request draws from.
+type check struct {
+	scope, id string
+	limit     Limit
+}
+
+var limited = metrics.NewCounterVec(
+	"nopass_rate_limited_total",
+	"Requests refused by the rate limiter, by the bucket that was empty.",
+	"scope",
+)
+
+// Wrap refuses requests with 429 and Retry-After once any of their
+// buckets is empty. It must run inside the auth middleware, which
+// attaches the API key and the verified user. A bucket store failure lets
+// requests through.
+func (l *Limiter) Wrap(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tenantID, userID, sessionID := l.Caller(r)
+		if id := auth.IdentityFrom(r.Context()); id != nil {
+			userID = id.UserID
+		}
+		checks := []check{
+			{"user", userID, l.Limits.User},
+			{"session", sessionID, l.Limits.Session},
+		}
+		if key := auth.KeyFrom(r.Context()); key != nil {
+			checks = append(checks, check{"key", key.ID, l.Limits.Key})
+		}
+		for _, c := range checks {
+			if c.id == "" || c.limit.Rate <= 0 {
+				continue
+			}
+			ok, wait, err := l.Buckets.Take(r.Context(), c.scope+":"+tenantID+":"+c.id, c.limit.Rate, c.limit.Burst)
+			if err != nil {
+				log.Printf("rate limit error (%s): %v", c.scope, err)
+				continue
+			}
+			if !ok {
+				limited.Inc(c.scope)
+				w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+				http.Error(w, "rate limit exceeded ("+c.scope+")", http.StatusTooManyRequests)
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
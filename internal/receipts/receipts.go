@@ -0,0 +1,125 @@
+// Package receipts keeps the sandbox receipt of every chat request so
+// per-tenant usage can be reported from measurements rather than estimates.
+package receipts
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"sync"
+	"time"
+
+	"github.com/shivansh-source/nopass/internal/metrics"
+	"github.com/shivansh-source/nopass/internal/types"
+)
+
+// Usage is the aggregate of a set of receipts.
+type Usage struct {
+	Runs            int   `json:"runs"`
+	WallTimeMs      int64 `json:"wall_time_ms"`
+	CPUTimeMs       int64 `json:"cpu_time_ms"`
+	PeakMemoryBytes int64 `json:"peak_memory_bytes"` // max over the runs
+	OutputBytes     int64 `json:"output_bytes"`
+	InputTokens     int64 `json:"input_tokens"`
+	OutputTokens    int64 `json:"output_tokens"`
+}
+
+// Add folds rc into u.
+func (u *Usage) Add(rc types.SandboxReceipt) {
+	u.Runs++
+	u.WallTimeMs += rc.WallTimeMs
+	u.CPUTimeMs += rc.CPUTimeMs
+	u.OutputBytes += int64(rc.OutputBytes)
+	u.InputTokens += int64(rc.InputTokens)
+	u.OutputTokens += int64(rc.OutputTokens)
+	if rc.PeakMemoryBytes > u.PeakMemoryBytes {
+		u.PeakMemoryBytes = rc.PeakMemoryBytes
+	}
+}
+
+// Store persists receipts.
+type Store interface {
+	Put(rc types.SandboxReceipt) error
+	// List returns the tenant's receipts started at or after since, newest
+	// first.
+	List(tenantID string, since time.Time) ([]types.SandboxReceipt, error)
+}
+
+// NewID returns a random receipt ID.
+func NewID() string {
+	var b [12]byte
+	_, _ = rand.Read(b[:])
+	return "rcpt_" + hex.EncodeToString(b[:])
+}
+
+// Usage counters, for dashboards that don't query the store.
+var (
+	sandboxRuns = metrics.NewCounterVec(
+		"nopass_sandbox_runs_total",
+		"Sandbox runs by tenant.",
+		"tenant",
+	)
+	sandboxCPU = metrics.NewCounterVec(
+		"nopass_sandbox_cpu_milliseconds_total",
+		"Sandbox CPU time by tenant, measured inside the sandbox.",
+		"tenant",
+	)
+	sandboxWall = metrics.NewCounterVec(
+		"nopass_sandbox_wall_milliseconds_total",
+		"Sandbox wall time by tenant.",
+		"tenant",
+	)
+)
+
+// Record counts rc in the usage metrics and stores it if store is set.
+func Record(store Store, rc types.SandboxReceipt) error {
+	sandboxRuns.Inc(rc.TenantID)
+	sandboxCPU.Add(uint64(max(rc.CPUTimeMs, 0)), rc.TenantID)
+	sandboxWall.Add(uint64(max(rc.WallTimeMs, 0)), rc.TenantID)
+	if store == nil {
+		return nil
+	}
+	return store.Put(rc)
+}
+
+// MemoryStore keeps the most recent receipts in process. When full, the
+// oldest receipt is dropped.
+type MemoryStore struct {
+	mu   sync.RWMutex
+	max  int
+	ring []types.SandboxReceipt
+	next int
+}
+
+// NewMemoryStore creates a store holding at most max receipts.
+func NewMemoryStore(max int) *MemoryStore {
+	return &MemoryStore{max: max}
+}
+
+// Put implements Store.
+func (s *MemoryStore) Put(rc types.SandboxReceipt) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.ring) < s.max {
+		s.ring = append(s.ring, rc)
+		return nil
+	}
+	s.ring[s.next] = rc
+	s.next = (s.next + 1) % s.max
+	return nil
+}
+
+// List implements Store.
+func (s *MemoryStore) List(tenantID string, since time.Time) ([]types.SandboxReceipt, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var out []types.SandboxReceipt
+	n := len(s.ring)
+	for i := 0; i < n; i++ {
+		// Walk backwards from the newest entry.
+		rc := s.ring[(s.next-1-i+2*n)%n]
+		if rc.TenantID == tenantID && !rc.StartedAt.Before(since) {
+			out = append(out, rc)
+		}
+	}
+	return out, nil
+}
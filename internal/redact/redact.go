@@ -0,0 +1,119 @@
+// Package redact applies export redaction profiles to stored
+// conversations. Stored turns are already masked, the values behind their
+// tokens kept in the session's vault; a profile decides how much of them
+// an export shows, so one conversation can go to a support agent, a
+// privacy officer or a privileged auditor:
+//
+//   - Full keeps every token masked and masks, with every built-in
+//     rule, values a turn holds in the clear (masked under laxer settings,
+//     or restored into an answer).
+//   - Partial masks like Full, then shows enough of each card, email and
+//     phone number to recognise it: ****4242, j***@example.com, ***5678.
+//     Other kinds stay tokens.
+//   - None restores every token the vault still has.
+package redact
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/shivansh-source/nopass/internal/pii"
+	"github.com/shivansh-source/nopass/internal/sandbox"
+)
+
+// Profile is how much an export reveals.
+type Profile string
+
+const (
+	Full    Profile = "full"
+	Partial Profile = "partial"
+	None    Profile = "none"
+)
+
+// ParseProfile parses a profile name; empty is Full.
+func ParseProfile(s string) (Profile, error) {
+	switch p := Profile(strings.ToLower(s)); p {
+	case "":
+		return Full, nil
+	case Full, Partial, None:
+		return p, nil
+	}
+	return "", fmt.Errorf("redaction profile must be full, partial or none, got %q", s)
+}
+
+// Lookup returns the value behind a masking token, e.g. from the vault.
+type Lookup func(token string) (string, bool)
+
+// Redactor applies a profile to the texts of one conversation, numbering
+// the tokens it makes after those already in them.
+type Redactor struct {
+	profile Profile
+	lookup  Lookup
+	in      sandbox.SandboxInput
+}
+
+// New returns a Redactor applying p to texts, the conversation's stored
+// turns and summary. lookup may be nil for Full.
+func New(p Profile, lookup Lookup, texts ...string) *Redactor {
+	all := sandbox.MaskOptions(pii.BuiltIn)
+	return &Redactor{
+		profile: p,
+		lookup:  lookup,
+		in:      sandbox.SandboxInput{Masking: &all, Tokens: sandbox.NewTokens(texts...)},
+	}
+}
+
+// Apply returns text as the profile shows it.
+func (r *Redactor) Apply(text string) string {
+	if r.profile != None {
+		text = r.in.Mask(text)
+	}
+	if r.profile == Full {
+		return text
+	}
+	return sandbox.TokenPattern.ReplaceAllStringFunc(text, func(token string) string {
+		value, ok := r.in.Tokens.Value(token)
+		if !ok && r.lookup != nil {
+			value, ok = r.lookup(token)
+		}
+		if !ok {
+			return token
+		}
+		if r.profile == None {
+			return value
+		}
+		kind := sandbox.TokenPattern.FindStringSubmatch(token)[1]
+		if shown, ok := partial(kind, value); ok {
+			return shown
+		}
+		return token
+	})
+}
+
+// partial returns the recognisable part of a card, email or phone number.
+func partial(kind, value string) (string, bool) {
+	switch kind {
+	case "CARD", "PHONE":
+		var digits []byte
+		for i := 0; i < len(value); i++ {
+			if value[i] >= '0' && value[i] <= '9' {
+				digits = append(digits, value[i])
+			}
+		}
+		if len(digits) < 8 {
+			return "", false
+		}
+		stars := "***"
+		if kind == "CARD" {
+			stars = "****"
+		}
+		return stars + string(digits[len(digits)-4:]), true
+	case "EMAIL":
+		local, domain, ok := strings.Cut(value, "@")
+		if !ok || local == "" {
+			return "", false
+		}
+		return local[:1] + "***@" + domain, true
+	}
+	return "", false
+}
@@ -0,0 +1,97 @@
+// Package refusalcache remembers the requests that were refused, per user
+// or session, so an attacker replaying the same jailbreak gets the same
+// refusal at once instead of another pass through risk scoring and the
+// sandbox. An entry is only reused under the policy version it was made
+// under, so a policy change re-evaluates every prompt.
+package refusalcache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/shivansh-source/nopass/internal/types"
+)
+
+// Entry is a cached refusal.
+type Entry struct {
+	Answer        string
+	RiskLevel     types.RiskLevel
+	Path          types.Path
+	Flags         []string
+	PolicyVersion string
+	Expires       time.Time
+}
+
+// Cache is a bounded in-process refusal cache. When full, the oldest entry
+// is evicted. A nil Cache caches nothing.
+type Cache struct {
+	mu      sync.Mutex
+	max     int
+	ttl     time.Duration
+	entries map[string]*Entry
+	order   []string
+}
+
+// New creates a cache holding at most max refusals for ttl each.
+func New(max int, ttl time.Duration) *Cache {
+	return &Cache{max: max, ttl: ttl, entries: make(map[string]*Entry)}
+}
+
+// Empty returns a new, empty cache with c's size and TTL.
+func (c *Cache) Empty() *Cache {
+	if c == nil {
+		return nil
+	}
+	return New(c.max, c.ttl)
+}
+
+// Key identifies a request: its tenant, the user or session it came from
+// and everything that decides the verdict: the policy profile, the prompt
+// and the external data. The prompt is compared ignoring case and runs of
+// whitespace.
+func Key(tenantID, subject, profile, prompt string, data []types.ExternalData) string {
+	h := sha256.New()
+	for _, s := range []string{tenantID, subject, profile, strings.ToLower(strings.Join(strings.Fields(prompt), " "))} {
+		h.Write([]byte(s))
+		h.Write([]byte{0})
+	}
+	for _, d := range data {
+		h.Write([]byte(d.Source + "\x00" + d.Type + "\x00" + d.Content + "\x00"))
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Get returns the refusal cached for key under policyVersion.
+func (c *Cache) Get(key, policyVersion string) (Entry, bool) {
+	if c == nil || key == "" {
+		return Entry{}, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[key]
+	if !ok || e.PolicyVersion != policyVersion || time.Now().After(e.Expires) {
+		return Entry{}, false
+	}
+	return *e, true
+}
+
+// Put caches a refusal for key.
+func (c *Cache) Put(key string, e Entry) {
+	if c == nil || key == "" {
+		return
+	}
+	e.Expires = time.Now().Add(c.ttl)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.entries[key]; !ok {
+		c.order = append(c.order, key)
+	}
+	c.entries[key] = &e
+	for len(c.order) > c.max && c.max > 0 {
+		delete(c.entries, c.order[0])
+		c.order = c.order[1:]
+	}
+}
@@ -0,0 +1,146 @@
+// Package rescan re-scans stored documents in the background whenever the
+// detection policy version changes, so stale "safe" verdicts don't linger.
+package rescan
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/shivansh-source/nopass/internal/datastore"
+	"github.com/shivansh-source/nopass/internal/events"
+	"github.com/shivansh-source/nopass/internal/scanledger"
+)
+
+// ScanFunc scans content under the current policy, bypassing any cached
+// verdict.
+type ScanFunc func(ctx context.Context, content string) (*scanledger.Verdict, error)
+
+// Rescanner periodically finds documents scanned under an older policy
+// version and re-scans them with a bounded worker pool.
+type Rescanner struct {
+	Store datastore.Store
+	Scan  ScanFunc
+	// PolicyVersion returns the current policy version.
+	PolicyVersion func() string
+	Events        events.Sink
+	Interval      time.Duration
+	Workers       int
+
+	trigger chan struct{}
+	once    sync.Once
+}
+
+func (r *Rescanner) init() {
+	r.once.Do(func() { r.trigger = make(chan struct{}, 1) })
+}
+
+// Trigger requests an immediate pass (e.g. right after a policy reload).
+func (r *Rescanner) Trigger() {
+	r.init()
+	select {
+	case r.trigger <- struct{}{}:
+	default:
+	}
+}
+
+// Run blocks, performing a pass every Interval or on Trigger, until ctx is
+// done.
+func (r *Rescanner) Run(ctx context.Context) {
+	r.init()
+	ticker := time.NewTicker(r.Interval)
+	defer ticker.Stop()
+
+	for {
+		if err := r.Pass(ctx); err != nil && ctx.Err() == nil {
+			log.Printf("background rescan pass failed: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-r.trigger:
+		}
+	}
+}
+
+// Pass re-scans every stale document once.
+func (r *Rescanner) Pass(ctx context.Context) error {
+	docs, err := r.Store.List()
+	if err != nil {
+		return err
+	}
+	version := r.PolicyVersion()
+
+	jobs := make(chan *datastore.Document)
+	var wg sync.WaitGroup
+	workers := r.Workers
+	if workers <= 0 {
+		workers = 2
+	}
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for doc := range jobs {
+				r.rescan(ctx, doc)
+			}
+		}()
+	}
+
+	stale := 0
+	for _, doc := range docs {
+		if doc.PolicyVersion == version {
+			continue
+		}
+		stale++
+		select {
+		case jobs <- doc:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	if stale > 0 {
+		log.Printf("background rescan: %d stale documents processed (policy=%s)", stale, version)
+	}
+	return ctx.Err()
+}
+
+func (r *Rescanner) rescan(ctx context.Context, doc *datastore.Document) {
+	v, err := r.Scan(ctx, doc.Content)
+	if err != nil {
+		log.Printf("background rescan of %s failed: %v", doc.ID, err)
+		return
+	}
+
+	wasDangerous := doc.IsDangerous
+	doc.RiskLevel = v.RiskLevel
+	doc.Flags = v.Flags
+	doc.IsDangerous = v.IsDangerous
+	doc.PolicyVersion = v.PolicyVersion
+	doc.ScannedAt = v.ScannedAt
+	if err := r.Store.Put(doc); err != nil {
+		log.Printf("background rescan: store %s failed: %v", doc.ID, err)
+		return
+	}
+
+	if doc.IsDangerous && !wasDangerous && r.Events != nil {
+		_ = r.Events.Emit(ctx, events.Event{
+			Type:     "document.flagged",
+			TenantID: doc.TenantID,
+			Subject:  doc.ID,
+			Time:     time.Now().UTC(),
+			Details: map[string]string{
+				"risk_level":     string(doc.RiskLevel),
+				"policy_version": doc.PolicyVersion,
+				"source":         doc.Source,
+			},
+		})
+	}
+}
@@ -0,0 +1,116 @@
+// Package residency keeps tenants' data inside the region their contract
+// requires. A gateway instance serves one or more regions; requests for a
+// tenant pinned elsewhere are refused rather than processed, and requests
+// it does serve are handed to a handler wired to that region's storage and
+// downstream services.
+package residency
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// Policy describes which regions this instance serves and where each
+// tenant must be processed.
+type Policy struct {
+	// Local is the region this instance runs in. Tenants without a
+	// residency requirement are processed here.
+	Local string
+	// Served lists every region this instance may process data for. Local
+	// is always served.
+	Served []string
+	// Tenants maps tenant ID -> required region.
+	Tenants map[string]string
+}
+
+// Region returns the region a tenant's request must be processed in, or an
+// error if this instance does not serve it.
+func (p *Policy) Region(tenantID string) (string, error) {
+	region, ok := p.Tenants[tenantID]
+	if !ok {
+		return p.Local, nil
+	}
+	if !p.serves(region) {
+		return "", fmt.Errorf("tenant %q requires region %q; this instance serves %s",
+			tenantID, region, strings.Join(p.Regions(), ", "))
+	}
+	return region, nil
+}
+
+func (p *Policy) serves(region string) bool {
+	if region == p.Local {
+		return true
+	}
+	for _, r := range p.Served {
+		if r == region {
+			return true
+		}
+	}
+	return false
+}
+
+// Regions lists every region this instance serves, sorted.
+func (p *Policy) Regions() []string {
+	seen := map[string]bool{p.Local: true}
+	out := []string{p.Local}
+	for _, r := range p.Served {
+		if !seen[r] {
+			seen[r] = true
+			out = append(out, r)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+// ParseTenantRegions parses "acme=eu,globex=us".
+func ParseTenantRegions(spec string) (map[string]string, error) {
+	out := make(map[string]string)
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		tenant, region, ok := strings.Cut(entry, "=")
+		tenant, region = strings.TrimSpace(tenant), strings.TrimSpace(region)
+		if !ok || tenant == "" || region == "" {
+			return nil, fmt.Errorf("tenant region entry %q: want tenant=region", entry)
+		}
+		out[tenant] = region
+	}
+	return out, nil
+}
+
+// Router dispatches each request to the handler for its tenant's region.
+type Router struct {
+	Policy *Policy
+	// Handlers maps region -> handler wired to that region's storage and
+	// services.
+	Handlers map[string]http.Handler
+	// Tenant extracts the tenant ID from a request without consuming it.
+	Tenant func(*http.Request) string
+}
+
+// ServeHTTP refuses requests this instance may not process with 421
+// Misdirected Request, so a global load balancer can retry them against
+// the right region.
+func (rt *Router) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	tenantID := rt.Tenant(r)
+	region, err := rt.Policy.Region(tenantID)
+	if err != nil {
+		log.Printf("residency: refusing request: %v", err)
+		http.Error(w, err.Error(), http.StatusMisdirectedRequest)
+		return
+	}
+	h, ok := rt.Handlers[region]
+	if !ok {
+		log.Printf("residency: no handler configured for region %q (tenant=%s)", region, tenantID)
+		http.Error(w, fmt.Sprintf("region %q is not configured on this instance", region), http.StatusMisdirectedRequest)
+		return
+	}
+	w.Header().Set("X-NoPass-Region", region)
+	h.ServeHTTP(w, r)
+}
@@ -0,0 +1,88 @@
+package retrieval
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/shivansh-source/nopass/internal/types"
+)
+
+// ConfluenceConnector searches a Confluence site with CQL full-text search.
+type ConfluenceConnector struct {
+	name       string
+	baseURL    string // e.g. https://example.atlassian.net/wiki
+	space      string
+	username   string
+	apiToken   string
+	HTTPClient *http.Client
+}
+
+// NewConfluenceConnector creates a connector authenticating with an Atlassian
+// account email and API token. space, if set, restricts the search.
+func NewConfluenceConnector(name, baseURL, space, username, apiToken string) *ConfluenceConnector {
+	return &ConfluenceConnector{
+		name:       name,
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		space:      space,
+		username:   username,
+		apiToken:   apiToken,
+		HTTPClient: &http.Client{Timeout: 5 * time.Second},
+	}
+}
+
+// Name implements Connector.
+func (c *ConfluenceConnector) Name() string { return c.name }
+
+type confluenceSearch struct {
+	Results []struct {
+		ID    string `json:"id"`
+		Title string `json:"title"`
+		Body  struct {
+			Storage struct {
+				Value string `json:"value"`
+			} `json:"storage"`
+		} `json:"body"`
+		Links struct {
+			WebUI string `json:"webui"`
+		} `json:"_links"`
+	} `json:"results"`
+}
+
+// Search implements Connector.
+func (c *ConfluenceConnector) Search(ctx context.Context, query string, limit int) ([]types.ExternalData, error) {
+	cql := fmt.Sprintf(`type=page AND text ~ "%s"`, strings.ReplaceAll(query, `"`, `\"`))
+	if c.space != "" {
+		cql += fmt.Sprintf(` AND space = "%s"`, c.space)
+	}
+	q := url.Values{}
+	q.Set("cql", cql)
+	q.Set("limit", strconv.Itoa(limit))
+	q.Set("expand", "body.storage")
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+"/rest/api/content/search?"+q.Encode(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("create confluence request: %w", err)
+	}
+	req.SetBasicAuth(c.username, c.apiToken)
+
+	var res confluenceSearch
+	if err := doJSON(c.HTTPClient, req, &res); err != nil {
+		return nil, err
+	}
+
+	out := make([]types.ExternalData, 0, len(res.Results))
+	for _, r := range res.Results {
+		out = append(out, types.ExternalData{
+			ID:      "confluence-" + r.ID,
+			Source:  c.baseURL + r.Links.WebUI,
+			Type:    "document",
+			Content: r.Title + "\n\n" + stripHTML(r.Body.Storage.Value),
+		})
+	}
+	return out, nil
+}
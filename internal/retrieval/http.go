@@ -0,0 +1,105 @@
+package retrieval
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/shivansh-source/nopass/internal/types"
+)
+
+// maxFetchBytes bounds any single response body read by a connector.
+const maxFetchBytes = 4 << 20
+
+// HTTPConnector calls a generic search endpoint:
+//
+//	GET {url}?q=<query>&limit=<n>  ->  [{"id","title","content","url"}]
+type HTTPConnector struct {
+	name       string
+	url        string
+	token      string
+	HTTPClient *http.Client
+}
+
+// NewHTTPConnector creates an HTTPConnector; token, if set, is sent as a
+// bearer token.
+func NewHTTPConnector(name, endpoint, token string) *HTTPConnector {
+	return &HTTPConnector{
+		name:       name,
+		url:        endpoint,
+		token:      token,
+		HTTPClient: &http.Client{Timeout: 5 * time.Second},
+	}
+}
+
+// Name implements Connector.
+func (c *HTTPConnector) Name() string { return c.name }
+
+type httpHit struct {
+	ID      string `json:"id"`
+	Title   string `json:"title"`
+	Content string `json:"content"`
+	URL     string `json:"url"`
+}
+
+// Search implements Connector.
+func (c *HTTPConnector) Search(ctx context.Context, query string, limit int) ([]types.ExternalData, error) {
+	u, err := url.Parse(c.url)
+	if err != nil {
+		return nil, fmt.Errorf("parse url: %w", err)
+	}
+	q := u.Query()
+	q.Set("q", query)
+	q.Set("limit", strconv.Itoa(limit))
+	u.RawQuery = q.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("create search request: %w", err)
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+
+	var hits []httpHit
+	if err := doJSON(c.HTTPClient, req, &hits); err != nil {
+		return nil, err
+	}
+
+	out := make([]types.ExternalData, 0, len(hits))
+	for _, h := range hits {
+		if len(out) == limit {
+			break
+		}
+		out = append(out, types.ExternalData{
+			ID:      h.ID,
+			Source:  h.URL,
+			Type:    "document",
+			Content: h.Content,
+		})
+	}
+	return out, nil
+}
+
+// doJSON executes req and decodes a JSON 200 response into v.
+func doJSON(client *http.Client, req *http.Request, v any) error {
+	req.Header.Set("Accept", "application/json")
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("call %s: %w", req.URL.Host, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s returned status %d", req.URL.Host, resp.StatusCode)
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxFetchBytes)).Decode(v); err != nil {
+		return fmt.Errorf("decode %s response: %w", req.URL.Host, err)
+	}
+	return nil
+}
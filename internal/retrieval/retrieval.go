@@ -0,0 +1,215 @@
+// Package retrieval fetches external data server-side from tenant-configured
+// sources (S3, Confluence, SharePoint, generic HTTP, and the Qdrant and
+// pgvector vector stores). Results are returned as ExternalData so they flow through the same scanning and masking as
+// client-supplied documents.
+package retrieval
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/shivansh-source/nopass/internal/types"
+)
+
+// Connector searches one source.
+type Connector interface {
+	Name() string
+	Search(ctx context.Context, query string, limit int) ([]types.ExternalData, error)
+}
+
+// Registry maps tenants to their configured connectors.
+type Registry struct {
+	tenants map[string][]Connector
+}
+
+// Search queries the tenant's connectors concurrently (optionally only the
+// named ones) and returns up to limit results per connector. A failing
+// connector is logged and skipped; its error is returned only if every
+// connector failed.
+func (r *Registry) Search(ctx context.Context, tenantID, query string, sources []string, limit int) ([]types.ExternalData, error) {
+	conns := r.tenants[tenantID]
+	if len(sources) > 0 {
+		var picked []Connector
+		for _, c := range conns {
+			for _, s := range sources {
+				if c.Name() == s {
+					picked = append(picked, c)
+				}
+			}
+		}
+		conns = picked
+	}
+	if len(conns) == 0 {
+		return nil, nil
+	}
+
+	type result struct {
+		docs []types.ExternalData
+		err  error
+	}
+	results := make([]result, len(conns))
+	var wg sync.WaitGroup
+	for i, c := range conns {
+		wg.Add(1)
+		go func(i int, c Connector) {
+			defer wg.Done()
+			docs, err := c.Search(ctx, query, limit)
+			for j := range docs {
+				docs[j].Source = "connector:" + c.Name() + ":" + docs[j].Source
+			}
+			results[i] = result{docs, err}
+		}(i, c)
+	}
+	wg.Wait()
+
+	var out []types.ExternalData
+	var errs []error
+	for i, res := range results {
+		if res.err != nil {
+			log.Printf("retrieval connector %s failed: %v", conns[i].Name(), res.err)
+			errs = append(errs, fmt.Errorf("%s: %w", conns[i].Name(), res.err))
+			continue
+		}
+		out = append(out, res.docs...)
+	}
+	if len(errs) == len(conns) {
+		return nil, errors.Join(errs...)
+	}
+	return out, nil
+}
+
+// ConnectorConfig is one source in the connectors file. Secrets are never
+// stored in the file itself, only the names of environment variables that
+// hold them.
+type ConnectorConfig struct {
+	Name     string `json:"name"`
+	Type     string `json:"type"` // "http", "s3", "confluence", "sharepoint", "qdrant", "pgvector"
+	URL      string `json:"url,omitempty"`
+	Bucket   string `json:"bucket,omitempty"`
+	Prefix   string `json:"prefix,omitempty"`
+	Region   string `json:"region,omitempty"`
+	Space    string `json:"space,omitempty"`
+	SiteID   string `json:"site_id,omitempty"`
+	TenantID string `json:"azure_tenant_id,omitempty"`
+
+	// Vector store collections.
+	Collection     string `json:"collection,omitempty"` // Qdrant collection or pgvector table
+	Driver         string `json:"driver,omitempty"`     // database/sql driver for pgvector
+	DSNEnv         string `json:"dsn_env,omitempty"`
+	EmbeddingURL   string `json:"embedding_url,omitempty"`
+	EmbeddingModel string `json:"embedding_model,omitempty"`
+	EmbeddingKey   string `json:"embedding_key_env,omitempty"`
+
+	UsernameEnv string `json:"username_env,omitempty"`
+	SecretEnv   string `json:"secret_env,omitempty"`
+	KeyIDEnv    string `json:"key_id_env,omitempty"`
+}
+
+// Config is the connectors file: tenant ID -> connectors.
+type Config struct {
+	Tenants map[string][]ConnectorConfig `json:"tenants"`
+}
+
+// LoadFile reads a connectors JSON file and builds a Registry.
+func LoadFile(path string) (*Registry, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read connectors file: %w", err)
+	}
+	var cfg Config
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("parse connectors file: %w", err)
+	}
+	return New(cfg)
+}
+
+// New builds a Registry from cfg.
+func New(cfg Config) (*Registry, error) {
+	r := &Registry{tenants: make(map[string][]Connector)}
+	for tenant, list := range cfg.Tenants {
+		for _, cc := range list {
+			c, err := build(cc)
+			if err != nil {
+				return nil, fmt.Errorf("tenant %s connector %s: %w", tenant, cc.Name, err)
+			}
+			r.tenants[tenant] = append(r.tenants[tenant], c)
+		}
+	}
+	return r, nil
+}
+
+func build(cc ConnectorConfig) (Connector, error) {
+	if cc.Name == "" {
+		return nil, errors.New("name is required")
+	}
+	switch cc.Type {
+	case "http":
+		return NewHTTPConnector(cc.Name, cc.URL, os.Getenv(cc.SecretEnv)), nil
+	case "s3":
+		return NewS3Connector(cc.Name, cc.Bucket, cc.Prefix, cc.Region, os.Getenv(cc.KeyIDEnv), os.Getenv(cc.SecretEnv)), nil
+	case "confluence":
+		return NewConfluenceConnector(cc.Name, cc.URL, cc.Space, os.Getenv(cc.UsernameEnv), os.Getenv(cc.SecretEnv)), nil
+	case "sharepoint":
+		return NewSharePointConnector(cc.Name, cc.SiteID, cc.TenantID, os.Getenv(cc.KeyIDEnv), os.Getenv(cc.SecretEnv)), nil
+	case "qdrant", "pgvector":
+		if cc.Collection == "" || cc.EmbeddingURL == "" {
+			return nil, errors.New("collection and embedding_url are required")
+		}
+		emb := NewHTTPEmbedder(cc.EmbeddingURL, cc.EmbeddingModel, os.Getenv(cc.EmbeddingKey))
+		if cc.Type == "qdrant" {
+			return NewQdrantConnector(cc.Name, cc.URL, cc.Collection, os.Getenv(cc.SecretEnv), emb), nil
+		}
+		driver := cc.Driver
+		if driver == "" {
+			driver = "pgx"
+		}
+		return NewPGVectorConnector(cc.Name, driver, os.Getenv(cc.DSNEnv), cc.Collection, emb)
+	default:
+		return nil, fmt.Errorf("unknown connector type %q", cc.Type)
+	}
+}
+
+var (
+	htmlTag    = regexp.MustCompile(`<[^>]+>`)
+	whitespace = regexp.MustCompile(`\s+`)
+)
+
+// stripHTML reduces HTML/XHTML storage formats to plain text.
+func stripHTML(s string) string {
+	s = htmlTag.ReplaceAllString(s, " ")
+	return strings.TrimSpace(whitespace.ReplaceAllString(s, " "))
+}
+
+// rankByTerms orders docs by how many query terms their content contains,
+// for sources that have no search API of their own.
+func rankByTerms(docs []types.ExternalData, query string, limit int) []types.ExternalData {
+	terms := strings.Fields(strings.ToLower(query))
+	score := func(d types.ExternalData) int {
+		c := strings.ToLower(d.Content)
+		n := 0
+		for _, t := range terms {
+			if len(t) > 2 && strings.Contains(c, t) {
+				n++
+			}
+		}
+		return n
+	}
+	sort.SliceStable(docs, func(i, j int) bool { return score(docs[i]) > score(docs[j]) })
+
+	var out []types.ExternalData
+	for _, d := range docs {
+		if len(out) == limit || score(d) == 0 {
+			break
+		}
+		out = append(out, d)
+	}
+	return out
+}
@@ -0,0 +1,195 @@
+package retrieval
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/shivansh-source/nopass/internal/types"
+)
+
+// S3Connector reads text objects under a bucket prefix. S3 has no content
+// search, so objects are listed, small text objects fetched, and ranked by
+// query term overlap. It is meant for modest, curated prefixes.
+type S3Connector struct {
+	name       string
+	bucket     string
+	prefix     string
+	region     string
+	keyID      string
+	secret     string
+	HTTPClient *http.Client
+	// MaxObjects bounds how many objects are fetched per search.
+	MaxObjects int
+}
+
+// NewS3Connector creates an S3Connector using static credentials.
+func NewS3Connector(name, bucket, prefix, region, keyID, secret string) *S3Connector {
+	if region == "" {
+		region = "us-east-1"
+	}
+	return &S3Connector{
+		name:       name,
+		bucket:     bucket,
+		prefix:     prefix,
+		region:     region,
+		keyID:      keyID,
+		secret:     secret,
+		HTTPClient: &http.Client{Timeout: 10 * time.Second},
+		MaxObjects: 50,
+	}
+}
+
+// Name implements Connector.
+func (c *S3Connector) Name() string { return c.name }
+
+type listBucketResult struct {
+	Contents []struct {
+		Key  string `xml:"Key"`
+		Size int64  `xml:"Size"`
+	} `xml:"Contents"`
+}
+
+var textExtensions = map[string]bool{".txt": true, ".md": true, ".json": true, ".csv": true, ".html": true}
+
+// Search implements Connector.
+func (c *S3Connector) Search(ctx context.Context, query string, limit int) ([]types.ExternalData, error) {
+	q := url.Values{}
+	q.Set("list-type", "2")
+	q.Set("prefix", c.prefix)
+	q.Set("max-keys", "1000")
+
+	body, err := c.get(ctx, "/", q)
+	if err != nil {
+		return nil, fmt.Errorf("list objects: %w", err)
+	}
+	var list listBucketResult
+	if err := xml.Unmarshal(body, &list); err != nil {
+		return nil, fmt.Errorf("decode object list: %w", err)
+	}
+
+	var docs []types.ExternalData
+	for _, obj := range list.Contents {
+		if len(docs) == c.MaxObjects {
+			break
+		}
+		if !textExtensions[strings.ToLower(path.Ext(obj.Key))] || obj.Size > maxFetchBytes {
+			continue
+		}
+		data, err := c.get(ctx, "/"+obj.Key, nil)
+		if err != nil {
+			return nil, fmt.Errorf("get %s: %w", obj.Key, err)
+		}
+		content := string(data)
+		if path.Ext(obj.Key) == ".html" {
+			content = stripHTML(content)
+		}
+		docs = append(docs, types.ExternalData{
+			ID:      "s3-" + obj.Key,
+			Source:  "s3://" + c.bucket + "/" + obj.Key,
+			Type:    "document",
+			Content: content,
+		})
+	}
+	return rankByTerms(docs, query, limit), nil
+}
+
+func (c *S3Connector) get(ctx context.Context, objPath string, query url.Values) ([]byte, error) {
+	host := fmt.Sprintf("%s.s3.%s.amazonaws.com", c.bucket, c.region)
+	u := url.URL{Scheme: "https", Host: host, Path: objPath, RawQuery: canonicalQuery(query)}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	c.sign(req, time.Now().UTC())
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("s3 returned status %d", resp.StatusCode)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, maxFetchBytes))
+}
+
+const emptyPayloadHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+// sign adds an AWS Signature Version 4 Authorization header for an
+// unsigned-body GET request.
+func (c *S3Connector) sign(req *http.Request, now time.Time) {
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+
+	req.Header.Set("x-amz-date", amzDate)
+	req.Header.Set("x-amz-content-sha256", emptyPayloadHash)
+
+	signedHeaders := "host;x-amz-content-sha256;x-amz-date"
+	canonicalHeaders := "host:" + req.URL.Host + "\n" +
+		"x-amz-content-sha256:" + emptyPayloadHash + "\n" +
+		"x-amz-date:" + amzDate + "\n"
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		canonicalHeaders,
+		signedHeaders,
+		emptyPayloadHash,
+	}, "\n")
+
+	scope := date + "/" + c.region + "/s3/aws4_request"
+	crHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(crHash[:])
+
+	key := hmacSHA256([]byte("AWS4"+c.secret), date)
+	key = hmacSHA256(key, c.region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	sig := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		c.keyID, scope, signedHeaders, sig))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	m := hmac.New(sha256.New, key)
+	m.Write([]byte(data))
+	return m.Sum(nil)
+}
+
+// canonicalQuery encodes query parameters sorted by key with RFC 3986
+// escaping, as SigV4 requires.
+func canonicalQuery(q url.Values) string {
+	if len(q) == 0 {
+		return ""
+	}
+	keys := make([]string, 0, len(q))
+	for k := range q {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var parts []string
+	for _, k := range keys {
+		for _, v := range q[k] {
+			parts = append(parts, awsEscape(k)+"="+awsEscape(v))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+func awsEscape(s string) string {
+	return strings.ReplaceAll(url.QueryEscape(s), "+", "%20")
+}
@@ -0,0 +1,142 @@
+package retrieval
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/shivansh-source/nopass/internal/types"
+)
+
+// SharePointConnector searches SharePoint through the Microsoft Graph
+// search API, authenticating with the OAuth2 client-credentials flow.
+type SharePointConnector struct {
+	name         string
+	siteID       string
+	azureTenant  string
+	clientID     string
+	clientSecret string
+	HTTPClient   *http.Client
+
+	mu        sync.Mutex
+	token     string
+	expiresAt time.Time
+}
+
+// NewSharePointConnector creates a connector for an Entra ID app
+// registration with Sites.Read.All (application) permission.
+func NewSharePointConnector(name, siteID, azureTenant, clientID, clientSecret string) *SharePointConnector {
+	return &SharePointConnector{
+		name:         name,
+		siteID:       siteID,
+		azureTenant:  azureTenant,
+		clientID:     clientID,
+		clientSecret: clientSecret,
+		HTTPClient:   &http.Client{Timeout: 5 * time.Second},
+	}
+}
+
+// Name implements Connector.
+func (c *SharePointConnector) Name() string { return c.name }
+
+type graphSearchResponse struct {
+	Value []struct {
+		HitsContainers []struct {
+			Hits []struct {
+				HitID    string `json:"hitId"`
+				Summary  string `json:"summary"`
+				Resource struct {
+					Name   string `json:"name"`
+					WebURL string `json:"webUrl"`
+				} `json:"resource"`
+			} `json:"hits"`
+		} `json:"hitsContainers"`
+	} `json:"value"`
+}
+
+// Search implements Connector. Graph returns hit summaries rather than full
+// documents, which keeps prompts small.
+func (c *SharePointConnector) Search(ctx context.Context, query string, limit int) ([]types.ExternalData, error) {
+	token, err := c.accessToken(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	qs := query
+	if c.siteID != "" {
+		qs = fmt.Sprintf("%s siteId:%s", query, c.siteID)
+	}
+	body, _ := json.Marshal(map[string]any{
+		"requests": []map[string]any{{
+			"entityTypes": []string{"driveItem", "listItem"},
+			"query":       map[string]string{"queryString": qs},
+			"size":        limit,
+		}},
+	})
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, "https://graph.microsoft.com/v1.0/search/query", bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("create graph search request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", "application/json")
+
+	var res graphSearchResponse
+	if err := doJSON(c.HTTPClient, req, &res); err != nil {
+		return nil, err
+	}
+
+	var out []types.ExternalData
+	for _, v := range res.Value {
+		for _, hc := range v.HitsContainers {
+			for _, h := range hc.Hits {
+				out = append(out, types.ExternalData{
+					ID:      "sharepoint-" + h.HitID,
+					Source:  h.Resource.WebURL,
+					Type:    "document",
+					Content: h.Resource.Name + "\n\n" + stripHTML(h.Summary),
+				})
+			}
+		}
+	}
+	return out, nil
+}
+
+// accessToken returns a cached client-credentials token, refreshing it a
+// minute before expiry.
+func (c *SharePointConnector) accessToken(ctx context.Context) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.token != "" && time.Now().Before(c.expiresAt) {
+		return c.token, nil
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "client_credentials")
+	form.Set("client_id", c.clientID)
+	form.Set("client_secret", c.clientSecret)
+	form.Set("scope", "https://graph.microsoft.com/.default")
+
+	tokenURL := fmt.Sprintf("https://login.microsoftonline.com/%s/oauth2/v2.0/token", url.PathEscape(c.azureTenant))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", fmt.Errorf("create token request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	var tok struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	if err := doJSON(c.HTTPClient, req, &tok); err != nil {
+		return "", fmt.Errorf("fetch graph token: %w", err)
+	}
+	c.token = tok.AccessToken
+	c.expiresAt = time.Now().Add(time.Duration(tok.ExpiresIn)*time.Second - time.Minute)
+	return c.token, nil
+}
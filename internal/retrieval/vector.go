@@ -0,0 +1,209 @@
+package retrieval
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/shivansh-source/nopass/internal/types"
+)
+
+// Embedder turns text into a vector.
+type Embedder interface {
+	Embed(ctx context.Context, text string) ([]float32, error)
+}
+
+// HTTPEmbedder calls an OpenAI-compatible /v1/embeddings endpoint (OpenAI,
+// vLLM, Ollama, TEI all speak it).
+type HTTPEmbedder struct {
+	URL        string // e.g. http://localhost:8080/v1/embeddings
+	Model      string
+	APIKey     string
+	HTTPClient *http.Client
+}
+
+// NewHTTPEmbedder creates an HTTPEmbedder.
+func NewHTTPEmbedder(url, model, apiKey string) *HTTPEmbedder {
+	return &HTTPEmbedder{URL: url, Model: model, APIKey: apiKey, HTTPClient: &http.Client{Timeout: 5 * time.Second}}
+}
+
+// Embed implements Embedder.
+func (e *HTTPEmbedder) Embed(ctx context.Context, text string) ([]float32, error) {
+	body, _ := json.Marshal(map[string]any{"model": e.Model, "input": text})
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.URL, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("create embedding request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if e.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+e.APIKey)
+	}
+
+	var res struct {
+		Data []struct {
+			Embedding []float32 `json:"embedding"`
+		} `json:"data"`
+	}
+	if err := doJSON(e.HTTPClient, req, &res); err != nil {
+		return nil, fmt.Errorf("embed query: %w", err)
+	}
+	if len(res.Data) == 0 || len(res.Data[0].Embedding) == 0 {
+		return nil, fmt.Errorf("embed query: empty embedding")
+	}
+	return res.Data[0].Embedding, nil
+}
+
+// QdrantConnector runs a nearest-neighbour search in a Qdrant collection.
+// Points are expected to carry the chunk text in the payload field
+// "content" and, optionally, "source" and "doc_id".
+type QdrantConnector struct {
+	name       string
+	baseURL    string
+	collection string
+	apiKey     string
+	embedder   Embedder
+	HTTPClient *http.Client
+}
+
+// NewQdrantConnector creates a QdrantConnector.
+func NewQdrantConnector(name, baseURL, collection, apiKey string, embedder Embedder) *QdrantConnector {
+	return &QdrantConnector{
+		name:       name,
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		collection: collection,
+		apiKey:     apiKey,
+		embedder:   embedder,
+		HTTPClient: &http.Client{Timeout: 5 * time.Second},
+	}
+}
+
+// Name implements Connector.
+func (c *QdrantConnector) Name() string { return c.name }
+
+// Search implements Connector.
+func (c *QdrantConnector) Search(ctx context.Context, query string, limit int) ([]types.ExternalData, error) {
+	vec, err := c.embedder.Embed(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	body, _ := json.Marshal(map[string]any{"vector": vec, "limit": limit, "with_payload": true})
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost,
+		c.baseURL+"/collections/"+c.collection+"/points/search", bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("create qdrant request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if c.apiKey != "" {
+		req.Header.Set("api-key", c.apiKey)
+	}
+
+	var res struct {
+		Result []struct {
+			ID      any            `json:"id"`
+			Score   float64        `json:"score"`
+			Payload map[string]any `json:"payload"`
+		} `json:"result"`
+	}
+	if err := doJSON(c.HTTPClient, req, &res); err != nil {
+		return nil, err
+	}
+
+	out := make([]types.ExternalData, 0, len(res.Result))
+	for _, p := range res.Result {
+		content, _ := p.Payload["content"].(string)
+		if content == "" {
+			continue
+		}
+		source, _ := p.Payload["source"].(string)
+		out = append(out, types.ExternalData{
+			ID:      fmt.Sprintf("qdrant-%v", p.ID),
+			Source:  source,
+			Type:    "vector_chunk",
+			Content: content,
+		})
+	}
+	return out, nil
+}
+
+// PGVectorConnector searches a pgvector table with cosine distance. The
+// table must have columns id, content, source and embedding (vector). The
+// Postgres database/sql driver is not linked into the gateway by default;
+// builds that use this connector must import one (e.g. pgx's stdlib) and
+// set the config "driver" accordingly.
+type PGVectorConnector struct {
+	name     string
+	db       *sql.DB
+	table    string
+	embedder Embedder
+}
+
+var identPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_.]*$`)
+
+// NewPGVectorConnector opens the database and creates a connector.
+func NewPGVectorConnector(name, driver, dsn, table string, embedder Embedder) (*PGVectorConnector, error) {
+	if !identPattern.MatchString(table) {
+		return nil, fmt.Errorf("invalid table name %q", table)
+	}
+	db, err := sql.Open(driver, dsn)
+	if err != nil {
+		return nil, fmt.Errorf("open %s database: %w", driver, err)
+	}
+	return &PGVectorConnector{name: name, db: db, table: table, embedder: embedder}, nil
+}
+
+// Name implements Connector.
+func (c *PGVectorConnector) Name() string { return c.name }
+
+// Search implements Connector.
+func (c *PGVectorConnector) Search(ctx context.Context, query string, limit int) ([]types.ExternalData, error) {
+	vec, err := c.embedder.Embed(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := c.db.QueryContext(ctx,
+		"SELECT id::text, content, source FROM "+c.table+" ORDER BY embedding <=> $1::vector LIMIT $2",
+		vectorLiteral(vec), limit)
+	if err != nil {
+		return nil, fmt.Errorf("pgvector query: %w", err)
+	}
+	defer rows.Close()
+
+	var out []types.ExternalData
+	for rows.Next() {
+		var id, content string
+		var source sql.NullString
+		if err := rows.Scan(&id, &content, &source); err != nil {
+			return nil, fmt.Errorf("pgvector scan: %w", err)
+		}
+		out = append(out, types.ExternalData{
+			ID:      "pgvector-" + id,
+			Source:  source.String,
+			Type:    "vector_chunk",
+			Content: content,
+		})
+	}
+	return out, rows.Err()
+}
+
+// vectorLiteral formats v as a pgvector text literal, e.g. "[0.1,0.2]".
+func vectorLiteral(v []float32) string {
+	var b strings.Builder
+	b.WriteByte('[')
+	for i, x := range v {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(strconv.FormatFloat(float64(x), 'f', -1, 32))
+	}
+	b.WriteByte(']')
+	return b.String()
+}
@@ -0,0 +1,40 @@
+package review
+
+import (
+	"context"
+	"log"
+
+	"github.com/shivansh-source/nopass/internal/metrics"
+	"github.com/shivansh-source/nopass/internal/types"
+)
+
+// FallbackFlag marks a verdict that came from the Fallback's Secondary.
+const FallbackFlag = "output_safety_fallback"
+
+// Fallback reviews with Primary and, if that fails, with Secondary. A
+// cancelled or expired request is not retried.
+type Fallback struct {
+	Primary   OutputReviewer
+	Secondary OutputReviewer
+}
+
+var fallbacks = metrics.NewCounterVec(
+	"nopass_output_safety_fallbacks_total",
+	"Output safety verdicts served by the fallback reviewer because the primary failed.",
+	"mode",
+)
+
+func (f *Fallback) Review(ctx context.Context, req types.OutputSafetyRequest) (*types.OutputSafetyResponse, error) {
+	resp, err := f.Primary.Review(ctx, req)
+	if err == nil || ctx.Err() != nil {
+		return resp, err
+	}
+	log.Printf("output safety error, using fallback reviewer: %v", err)
+	fallbacks.Inc(string(req.Mode))
+	resp, err = f.Secondary.Review(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	resp.ReasonFlags = append(resp.ReasonFlags, FallbackFlag)
+	return resp, nil
+}
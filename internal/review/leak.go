@@ -0,0 +1,61 @@
+package review
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/shivansh-source/nopass/internal/metrics"
+	"github.com/shivansh-source/nopass/internal/types"
+)
+
+// PromptLeakFlag marks an answer blocked for repeating the request's
+// system prompt canary.
+const PromptLeakFlag = "system_prompt_leak"
+
+// PromptLeak blocks answers that contain Canary, the per-request marker
+// hidden in the system prompt (see sandbox.SandboxInput.Canary): a model
+// that repeats it is repeating its instructions. Other answers go to Next.
+type PromptLeak struct {
+	Next   OutputReviewer
+	Canary string
+}
+
+var promptLeaks = metrics.NewCounterVec(
+	"nopass_prompt_leaks_total",
+	"Answers blocked for carrying the system prompt canary, by whether the draft was partial.",
+	"partial",
+)
+
+func (p PromptLeak) Review(ctx context.Context, req types.OutputSafetyRequest) (*types.OutputSafetyResponse, error) {
+	if p.Canary != "" && ContainsCanary(req.DraftAnswer, p.Canary) {
+		promptLeaks.Inc(strconv.FormatBool(req.Partial))
+		return &types.OutputSafetyResponse{
+			SchemaVersion: types.SchemaVersion,
+			FinalAnswer:   DefaultRefusal,
+			WasModified:   true,
+			Blocked:       true,
+			ReasonFlags:   []string{PromptLeakFlag},
+		}, nil
+	}
+	return p.Next.Review(ctx, req)
+}
+
+// ContainsCanary reports whether text carries canary, even re-cased or
+// with spaces or punctuation put between its characters.
+func ContainsCanary(text, canary string) bool {
+	if strings.Contains(text, canary) {
+		return true
+	}
+	return strings.Contains(alnum(text), alnum(canary))
+}
+
+func alnum(s string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return unicode.ToLower(r)
+		}
+		return -1
+	}, s)
+}
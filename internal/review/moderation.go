@@ -0,0 +1,123 @@
+package review
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/shivansh-source/nopass/internal/orchestrator"
+	"github.com/shivansh-source/nopass/internal/types"
+)
+
+// Moderator is an OutputReviewer backed by a small moderation model (Llama
+// Guard class) run in a sandbox container, so a deployment needs no
+// output-safety service. The model gets the conversation and the hazard
+// taxonomy and answers "safe", or "unsafe" with the violated category codes
+// on the next line.
+type Moderator struct {
+	// Runner runs the moderation image, normally an orchestrator.LLMRunner
+	// configured with it.
+	Runner orchestrator.Runner
+	// Checks, if set, reviews the draft first (e.g. Engine, for secret
+	// redaction and PII re-masking); the model then judges its rewrite.
+	Checks OutputReviewer
+}
+
+// moderationCategories is the Llama Guard 3 hazard taxonomy; verdicts name
+// categories by code and flags by name.
+var moderationCategories = []struct{ code, name string }{
+	{"S1", "violent_crimes"},
+	{"S2", "non_violent_crimes"},
+	{"S3", "sex_crimes"},
+	{"S4", "child_exploitation"},
+	{"S5", "defamation"},
+	{"S6", "specialized_advice"},
+	{"S7", "privacy"},
+	{"S8", "intellectual_property"},
+	{"S9", "indiscriminate_weapons"},
+	{"S10", "hate"},
+	{"S11", "self_harm"},
+	{"S12", "sexual_content"},
+	{"S13", "elections"},
+	{"S14", "code_interpreter_abuse"},
+}
+
+// moderationPrompt is the system prompt of every moderation run.
+var moderationPrompt = func() string {
+	var b strings.Builder
+	b.WriteString("Task: Check if there is unsafe content in 'Agent' messages in conversations according to our safety policy with the below categories.\n\n")
+	b.WriteString("<BEGIN UNSAFE CONTENT CATEGORIES>\n")
+	for _, c := range moderationCategories {
+		fmt.Fprintf(&b, "%s: %s.\n", c.code, c.name)
+	}
+	b.WriteString("<END UNSAFE CONTENT CATEGORIES>\n\n")
+	b.WriteString("Provide your safety assessment for ONLY THE LAST Agent message in the above conversation:\n")
+	b.WriteString("- First line must read 'safe' or 'unsafe'.\n")
+	b.WriteString("- If unsafe, a second line must include a comma-separated list of violated categories.")
+	return b.String()
+}()
+
+func (m *Moderator) Review(ctx context.Context, req types.OutputSafetyRequest) (*types.OutputSafetyResponse, error) {
+	resp := &types.OutputSafetyResponse{SchemaVersion: types.SchemaVersion, FinalAnswer: req.DraftAnswer}
+	if m.Checks != nil {
+		var err error
+		if resp, err = m.Checks.Review(ctx, req); err != nil {
+			return nil, err
+		}
+		if resp.Blocked {
+			return resp, nil
+		}
+	}
+
+	// The moderation model sees the prompt as the answering model did.
+	prompt := req.MaskedPrompt
+	if prompt == "" {
+		prompt = req.UserPrompt
+	}
+	conversation := "<BEGIN CONVERSATION>\n\nUser: " + prompt + "\n\nAgent: " + resp.FinalAnswer + "\n\n<END CONVERSATION>"
+	out, err := m.Runner.RunInSandbox(ctx, moderationPrompt, conversation)
+	if err != nil {
+		return nil, fmt.Errorf("moderation model: %w", err)
+	}
+	unsafe, codes, err := parseModeration(out)
+	if err != nil {
+		return nil, err
+	}
+	if !unsafe {
+		return resp, nil
+	}
+	resp.ReasonFlags = append(resp.ReasonFlags, "moderation_unsafe")
+	for _, code := range codes {
+		resp.ReasonFlags = append(resp.ReasonFlags, "moderation:"+moderationName(code))
+	}
+	resp.FinalAnswer = DefaultRefusal
+	resp.WasModified = true
+	resp.Blocked = true
+	return resp, nil
+}
+
+// parseModeration reads a verdict. Output that is neither "safe" nor
+// "unsafe" is an error, so a broken model never passes an answer.
+func parseModeration(out string) (bool, []string, error) {
+	words := strings.Fields(strings.ReplaceAll(strings.TrimSpace(out), ",", " "))
+	if len(words) == 0 {
+		return false, nil, fmt.Errorf("moderation model returned no verdict")
+	}
+	switch strings.ToLower(words[0]) {
+	case "safe":
+		return false, nil, nil
+	case "unsafe":
+		return true, words[1:], nil
+	default:
+		return false, nil, fmt.Errorf("moderation model returned unexpected verdict %q", words[0])
+	}
+}
+
+func moderationName(code string) string {
+	for _, c := range moderationCategories {
+		if strings.EqualFold(c.code, code) {
+			return c.name
+		}
+	}
+	return strings.ToLower(code)
+}
@@ -0,0 +1,222 @@
+package review
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+
+	"github.com/shivansh-source/nopass/internal/types"
+)
+
+// Named attaches a name to a reviewer for logging and reason flags.
+type Named struct {
+	Name     string
+	Reviewer OutputReviewer
+}
+
+// Panel runs several reviewers concurrently and combines their verdicts
+// with a strategy chosen per risk level. When more than one reviewer
+// rewrote a passing answer, the rewrites are chained: each such reviewer
+// reviews the previous one's output in turn, so every redaction is kept.
+// A reviewer that fails is flagged review_reviewer_unavailable whatever
+// the strategy.
+type Panel struct {
+	Reviewers []Named
+	// Default is used for risk levels without an entry in ByRisk.
+	Default Strategy
+	ByRisk  map[types.RiskLevel]Strategy
+}
+
+type vote struct {
+	name string
+	resp *types.OutputSafetyResponse
+	err  error
+}
+
+// Review implements OutputReviewer.
+func (p *Panel) Review(ctx context.Context, req types.OutputSafetyRequest) (*types.OutputSafetyResponse, error) {
+	if len(p.Reviewers) == 0 {
+		return nil, errors.New("review panel has no reviewers")
+	}
+
+	votes := make([]vote, len(p.Reviewers))
+	var wg sync.WaitGroup
+	for i, rv := range p.Reviewers {
+		wg.Add(1)
+		go func(i int, rv Named) {
+			defer wg.Done()
+			resp, err := rv.Reviewer.Review(ctx, req)
+			if err == nil && resp == nil {
+				err = errors.New("reviewer returned no verdict")
+			}
+			votes[i] = vote{name: rv.Name, resp: resp, err: err}
+		}(i, rv)
+	}
+	wg.Wait()
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	strategy := p.strategyFor(req.RiskLevel)
+	resp, err := combine(ctx, strategy, req.DraftAnswer, votes)
+	if err != nil || resp.Blocked {
+		return resp, err
+	}
+	return p.chain(ctx, req, resp, votes)
+}
+
+// chain runs the reviewers whose rewrites passed one after another, each
+// on the previous one's output, starting from the first rewrite. A
+// reviewer that fails or blocks on the way blocks the answer: its
+// redactions can't be applied otherwise.
+func (p *Panel) chain(ctx context.Context, req types.OutputSafetyRequest, resp *types.OutputSafetyResponse, votes []vote) (*types.OutputSafetyResponse, error) {
+	var rewriters []int
+	for i, v := range votes {
+		if v.err == nil && !v.resp.Blocked && v.resp.WasModified && v.resp.FinalAnswer != req.DraftAnswer {
+			rewriters = append(rewriters, i)
+		}
+	}
+	if len(rewriters) < 2 {
+		return resp, nil
+	}
+
+	flags := appendUnique(resp.ReasonFlags, "review_panel_chained")
+	answer := votes[rewriters[0]].resp.FinalAnswer
+	for _, i := range rewriters[1:] {
+		rv := p.Reviewers[i]
+		next := req
+		next.DraftAnswer = answer
+		out, err := rv.Reviewer.Review(ctx, next)
+		if err == nil && out == nil {
+			err = errors.New("reviewer returned no verdict")
+		}
+		if err != nil {
+			slog.WarnContext(ctx, "output reviewer failed", "reviewer", rv.Name, "err", err)
+			return blocked(DefaultRefusal, appendUnique(flags, "review_reviewer_unavailable")), nil
+		}
+		for _, f := range out.ReasonFlags {
+			flags = appendUnique(flags, f)
+		}
+		if out.Blocked {
+			refusal := DefaultRefusal
+			if out.FinalAnswer != "" {
+				refusal = out.FinalAnswer
+			}
+			return blocked(refusal, flags), nil
+		}
+		answer = out.FinalAnswer
+	}
+	return &types.OutputSafetyResponse{
+		SchemaVersion: types.SchemaVersion,
+		FinalAnswer:   answer,
+		WasModified:   true,
+		ReasonFlags:   flags,
+	}, nil
+}
+
+func blocked(answer string, flags []string) *types.OutputSafetyResponse {
+	return &types.OutputSafetyResponse{
+		SchemaVersion: types.SchemaVersion,
+		FinalAnswer:   answer,
+		WasModified:   true,
+		Blocked:       true,
+		ReasonFlags:   flags,
+	}
+}
+
+func (p *Panel) strategyFor(level types.RiskLevel) Strategy {
+	if st, ok := p.ByRisk[level]; ok {
+		return st
+	}
+	if p.Default != "" {
+		return p.Default
+	}
+	return StrategyStrictestWins
+}
+
+func combine(ctx context.Context, strategy Strategy, draft string, votes []vote) (*types.OutputSafetyResponse, error) {
+	var answered []vote
+	var errs []error
+	blocks := 0
+	for _, v := range votes {
+		if v.err != nil {
+			slog.WarnContext(ctx, "output reviewer failed", "reviewer", v.name, "err", v.err)
+			errs = append(errs, fmt.Errorf("%s: %w", v.name, v.err))
+			continue
+		}
+		answered = append(answered, v)
+		if v.resp.Blocked {
+			blocks++
+		}
+	}
+
+	if len(answered) == 0 {
+		return nil, fmt.Errorf("all output reviewers failed: %w", errors.Join(errs...))
+	}
+
+	strictest := answered[0]
+	for _, v := range answered[1:] {
+		if severity(v.resp) > severity(strictest.resp) {
+			strictest = v
+		}
+	}
+
+	flags := mergeFlags(answered)
+	if len(errs) > 0 {
+		flags = appendUnique(flags, "review_reviewer_unavailable")
+	}
+	var block bool
+	switch strategy {
+	case StrategyAllMustPass:
+		block = blocks > 0 || len(errs) > 0
+	case StrategyMajority:
+		block = (blocks+len(errs))*2 > len(votes)
+	default:
+		block = blocks > 0
+	}
+
+	if block {
+		answer := DefaultRefusal
+		if strictest.resp.Blocked && strictest.resp.FinalAnswer != "" {
+			answer = strictest.resp.FinalAnswer
+		}
+		return blocked(answer, appendUnique(flags, "review_panel_"+string(strategy))), nil
+	}
+
+	// Not blocked: release the strictest non-blocking rewrite, falling back
+	// to the untouched draft. Several rewrites are chained by the caller.
+	best := &types.OutputSafetyResponse{SchemaVersion: types.SchemaVersion, FinalAnswer: draft}
+	for _, v := range answered {
+		if !v.resp.Blocked && severity(v.resp) >= severity(best) {
+			best = v.resp
+		}
+	}
+	return &types.OutputSafetyResponse{
+		SchemaVersion: types.SchemaVersion,
+		FinalAnswer:   best.FinalAnswer,
+		WasModified:   best.WasModified,
+		ReasonFlags:   flags,
+	}, nil
+}
+
+func mergeFlags(votes []vote) []string {
+	var out []string
+	for _, v := range votes {
+		for _, f := range v.resp.ReasonFlags {
+			out = appendUnique(out, f)
+		}
+	}
+	return out
+}
+
+func appendUnique(flags []string, f string) []string {
+	for _, x := range flags {
+		if x == f {
+			return flags
+		}
+	}
+	return append(flags, f)
+}
@@ -0,0 +1,63 @@
+// Package review combines several output-safety reviewers into one verdict.
+// The remote output-safety service is the usual reviewer; Engine runs the
+// same checks in-process, on its own or as the Fallback when the service
+// can't be reached, and Moderator asks a moderation model run in a sandbox
+// container.
+package review
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/shivansh-source/nopass/internal/types"
+)
+
+// OutputReviewer checks a draft answer before it is released to the user.
+// The remote output-safety service, local in-process checks and Panel all
+// implement it.
+type OutputReviewer interface {
+	Review(ctx context.Context, req types.OutputSafetyRequest) (*types.OutputSafetyResponse, error)
+}
+
+// Strategy decides how the verdicts of several reviewers are combined.
+type Strategy string
+
+const (
+	// StrategyAllMustPass blocks unless every reviewer answered and none
+	// blocked. Reviewer errors count as failures.
+	StrategyAllMustPass Strategy = "all_must_pass"
+	// StrategyMajority blocks when more than half of the reviewers block.
+	// Reviewer errors count as block votes.
+	StrategyMajority Strategy = "majority"
+	// StrategyStrictestWins takes the strictest verdict among reviewers
+	// that answered; errors are ignored unless every reviewer failed.
+	StrategyStrictestWins Strategy = "strictest_wins"
+)
+
+// ParseStrategy validates a strategy name.
+func ParseStrategy(s string) (Strategy, error) {
+	switch st := Strategy(strings.ToLower(strings.TrimSpace(s))); st {
+	case StrategyAllMustPass, StrategyMajority, StrategyStrictestWins:
+		return st, nil
+	default:
+		return "", fmt.Errorf("unknown review strategy %q", s)
+	}
+}
+
+// DefaultRefusal is returned when a panel blocks an answer and no reviewer
+// supplied refusal text of its own.
+const DefaultRefusal = "I’m not able to help with that request because it may involve unsafe " +
+	"or disallowed content. If you need help with something else, feel free to ask."
+
+// severity orders verdicts: pass < modified < blocked.
+func severity(r *types.OutputSafetyResponse) int {
+	switch {
+	case r.Blocked:
+		return 2
+	case r.WasModified:
+		return 1
+	default:
+		return 0
+	}
+}
@@ -0,0 +1,239 @@
+package risk
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"net/url"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"github.com/shivansh-source/nopass/internal/types"
+)
+
+// Engine is a rules-based Scorer: injection patterns, jailbreak phrases
+// and hidden or encoded payloads. It needs no model and no network, so it
+// keeps the pipeline scoring when the risk service is down, at the cost of
+// the service's embedding similarity check. Pattern flags match the risk
+// service's where both have the rule.
+type Engine struct{}
+
+type rule struct {
+	re    *regexp.Regexp
+	flag  string
+	level types.RiskLevel
+}
+
+// Patterns run on normalized text: lower case, invisible characters
+// removed, whitespace collapsed.
+var rules = []rule{
+	{regexp.MustCompile(`\b(ignore|disregard|forget|override)( all| any| the| your)?( previous| prior| above| earlier| preceding)? (instructions|rules|prompts?|directions|guidelines)\b`), "regex_ignore_previous_instructions", types.RiskHigh},
+	{regexp.MustCompile(`\b(reveal|show|print|repeat|output|leak)( me)? (your|the)( full| entire| hidden| original)? (system prompt|initial instructions|hidden instructions)\b`), "regex_reveal_system_prompt", types.RiskHigh},
+	{regexp.MustCompile(`\blist all .*passwords`), "regex_password_exfil", types.RiskHigh},
+	{regexp.MustCompile(`\b(api|access|secret) key`), "regex_secret_key", types.RiskMedium},
+	{regexp.MustCompile(`\byou are (now|no longer)\b|\bfrom now on,? you (are|will)\b|\bnew instructions\s*:`), "regex_role_override", types.RiskMedium},
+	{regexp.MustCompile(`(^|\n|\s)#{2,}\s*(system|assistant|instruction)s?\s*:|<\|im_(start|end)\|>|\[/?inst\]|<<\s*sys\s*>>`), "regex_role_markers", types.RiskHigh},
+	{regexp.MustCompile(`</\s*data\s*>|<\s*/?\s*system\s*>`), "data_tag_breakout", types.RiskHigh},
+	{regexp.MustCompile(`\b(bypass|disable|turn off|ignore) (all |any |your )?(safety|content|security) (policies|filters|guidelines|rules|restrictions)\b`), "regex_bypass_safety", types.RiskHigh},
+}
+
+// jailbreakPhrases are well-known persona and mode-switch prompts.
+var jailbreakPhrases = []string{
+	"do anything now",
+	"developer mode enabled",
+	"jailbreak mode",
+	"jailbroken",
+	"unrestricted model",
+	"unfiltered ai",
+	"without any restrictions",
+	"no ethical guidelines",
+	"evil confidant",
+	"pretend you have no rules",
+	"act as dan",
+	"you are dan",
+	"stay in character",
+	"opposite mode",
+	"grandma exploit",
+}
+
+var (
+	base64Run = regexp.MustCompile(`[A-Za-z0-9+/]{24,}={0,2}`)
+	hexEscape = regexp.MustCompile(`(?:\\x[0-9a-fA-F]{2}){8,}`)
+	hexRun    = regexp.MustCompile(`\b(?:[0-9a-fA-F]{2}){16,}\b`)
+	urlRun    = regexp.MustCompile(`(?:%[0-9a-fA-F]{2}){6,}`)
+	spaces    = regexp.MustCompile(`\s+`)
+)
+
+func (Engine) ScorePrompt(_ context.Context, prompt, _, _ string) (*types.RiskResponse, error) {
+	resp := Score(prompt)
+	resp.SanitizedPrompt = sanitize(prompt, resp.Flags)
+	return resp, nil
+}
+
+func (Engine) ScoreDocument(_ context.Context, content, _, _ string) (*types.RiskResponse, error) {
+	resp := Score(content)
+	resp.SanitizedPrompt = content
+	return resp, nil
+}
+
+// Score runs every rule over text.
+func Score(text string) *types.RiskResponse {
+	level, flags := score(text, true)
+	return &types.RiskResponse{
+		SchemaVersion:     types.SchemaVersion,
+		SanitizedPrompt:   text,
+		RiskLevel:         level,
+		Flags:             flags,
+		SelfCheckRequired: level == types.RiskHigh,
+	}
+}
+
+func score(text string, decode bool) (types.RiskLevel, []string) {
+	level := types.RiskLow
+	var flags []string
+	hit := func(flag string, l types.RiskLevel) {
+		for _, f := range flags {
+			if f == flag {
+				return
+			}
+		}
+		flags = append(flags, flag)
+		if l.Rank() > level.Rank() {
+			level = l
+		}
+	}
+
+	norm := normalize(text)
+	for _, r := range rules {
+		if r.re.MatchString(norm) {
+			hit(r.flag, r.level)
+		}
+	}
+	for _, p := range jailbreakPhrases {
+		if strings.Contains(norm, p) {
+			hit("jailbreak_phrase", types.RiskHigh)
+			break
+		}
+	}
+
+	// Hidden characters: zero-width and bidi controls hide or reorder text
+	// for a human reviewer; Unicode tag characters smuggle invisible ASCII.
+	var tags strings.Builder
+	for _, r := range text {
+		switch {
+		case r >= 0xE0020 && r <= 0xE007E:
+			tags.WriteRune(r - 0xE0000)
+		case invisible(r):
+			hit("invisible_unicode", types.RiskMedium)
+		case bidi(r):
+			hit("bidi_override", types.RiskMedium)
+		}
+	}
+	if tags.Len() > 0 {
+		hit("unicode_tag_smuggling", types.RiskHigh)
+		if decode {
+			if l, _ := score(tags.String(), false); l != types.RiskLow {
+				hit("encoded_injection", types.RiskHigh)
+			}
+		}
+	}
+
+	// Encoded payloads are suspicious when they decode to text; they are
+	// HIGH when that text trips a rule.
+	if decode {
+		for _, enc := range []struct {
+			flag   string
+			re     *regexp.Regexp
+			decode func(string) ([]byte, error)
+		}{
+			{"encoded_base64", base64Run, decodeBase64},
+			{"encoded_hex", hexEscape, func(s string) ([]byte, error) { return hex.DecodeString(strings.ReplaceAll(s, `\x`, "")) }},
+			{"encoded_hex", hexRun, hex.DecodeString},
+			{"encoded_url", urlRun, func(s string) ([]byte, error) { u, err := url.PathUnescape(s); return []byte(u), err }},
+		} {
+			for _, m := range enc.re.FindAllString(text, 8) {
+				b, err := enc.decode(m)
+				if err != nil || !printable(b) {
+					continue
+				}
+				hit(enc.flag, types.RiskMedium)
+				if l, _ := score(string(b), false); l != types.RiskLow {
+					hit("encoded_injection", types.RiskHigh)
+				}
+			}
+		}
+	}
+
+	// Several independent weak signals together are treated as strong.
+	if level == types.RiskMedium && len(flags) >= 3 {
+		level = types.RiskHigh
+	}
+	return level, flags
+}
+
+func normalize(text string) string {
+	text = strings.Map(func(r rune) rune {
+		if invisible(r) || bidi(r) || (r >= 0xE0000 && r <= 0xE007F) {
+			return -1
+		}
+		return r
+	}, text)
+	return spaces.ReplaceAllString(strings.ToLower(text), " ")
+}
+
+func invisible(r rune) bool {
+	switch r {
+	case 0x200B, 0x200C, 0x200D, 0x2060, 0xFEFF, 0x00AD, 0x180E:
+		return true
+	}
+	return false
+}
+
+func bidi(r rune) bool {
+	return (r >= 0x202A && r <= 0x202E) || (r >= 0x2066 && r <= 0x2069)
+}
+
+func decodeBase64(s string) ([]byte, error) {
+	if b, err := base64.StdEncoding.DecodeString(s); err == nil {
+		return b, nil
+	}
+	return base64.RawStdEncoding.DecodeString(strings.TrimRight(s, "="))
+}
+
+// printable reports whether b is mostly readable text, which random
+// identifiers and binary blobs aren't.
+func printable(b []byte) bool {
+	if len(b) < 8 {
+		return false
+	}
+	letters, ok := 0, 0
+	for _, r := range string(b) {
+		if unicode.IsLetter(r) || r == ' ' {
+			letters++
+		}
+		if unicode.IsPrint(r) || r == '\n' || r == '\t' {
+			ok++
+		}
+	}
+	return ok*10 >= len(b)*9 && letters*2 >= len(b)
+}
+
+var (
+	ignorePhrase = regexp.MustCompile(`(?i)\b(ignore|disregard|forget|override)( all| any| the| your)?( previous| prior| above| earlier| preceding)? (instructions|rules|prompts?|directions|guidelines)\b`)
+	revealPhrase = regexp.MustCompile(`(?i)\breveal your system prompt\b`)
+)
+
+// sanitize rewrites the phrases the risk service also rewrites, with the
+// same replacements.
+func sanitize(prompt string, flags []string) string {
+	for _, f := range flags {
+		switch f {
+		case "regex_ignore_previous_instructions":
+			prompt = ignorePhrase.ReplaceAllString(prompt, "[removed prompt injection phrase]")
+		case "regex_reveal_system_prompt":
+			prompt = revealPhrase.ReplaceAllString(prompt, "explain at a high level how system prompts work (no secrets)")
+		}
+	}
+	return prompt
+}
@@ -0,0 +1,87 @@
+// Package risk scores prompts and external documents for prompt injection.
+// The remote risk service (gateway.RiskClient) is the primary Scorer;
+// Engine is a rules-only Scorer that runs in-process, either on its own or
+// as the Fallback when the service can't be reached.
+package risk
+
+import (
+	"context"
+	"errors"
+	"log"
+
+	"github.com/shivansh-source/nopass/internal/metrics"
+	"github.com/shivansh-source/nopass/internal/types"
+)
+
+// Scorer scores user prompts and external content.
+type Scorer interface {
+	ScorePrompt(ctx context.Context, prompt, userID, sessionID string) (*types.RiskResponse, error)
+	ScoreDocument(ctx context.Context, content, userID, sessionID string) (*types.RiskResponse, error)
+}
+
+// BatchScorer is a Scorer that can score many documents in one call. The
+// verdicts are in the order of contents.
+type BatchScorer interface {
+	Scorer
+	ScoreBatch(ctx context.Context, contents []string, userID, sessionID string) ([]*types.RiskResponse, error)
+}
+
+// ErrBatchUnsupported means a BatchScorer can't score batches after all,
+// e.g. because the service behind it predates them; score documents one
+// at a time instead.
+var ErrBatchUnsupported = errors.New("risk scorer does not support batches")
+
+// FallbackFlag marks a verdict that came from the Fallback's Secondary.
+const FallbackFlag = "risk_fallback"
+
+// Fallback scores with Primary and, if that fails, with Secondary. A
+// cancelled or expired request is not retried.
+type Fallback struct {
+	Primary   Scorer
+	Secondary Scorer
+}
+
+var fallbacks = metrics.NewCounterVec(
+	"nopass_risk_fallbacks_total",
+	"Risk verdicts served by the fallback scorer because the primary failed.",
+	"call",
+)
+
+func (f *Fallback) ScorePrompt(ctx context.Context, prompt, userID, sessionID string) (*types.RiskResponse, error) {
+	resp, err := f.Primary.ScorePrompt(ctx, prompt, userID, sessionID)
+	if err == nil || ctx.Err() != nil {
+		return resp, err
+	}
+	log.Printf("risk scoring error, using fallback scorer: %v", err)
+	fallbacks.Inc("prompt")
+	return flagged(f.Secondary.ScorePrompt(ctx, prompt, userID, sessionID))
+}
+
+func (f *Fallback) ScoreDocument(ctx context.Context, content, userID, sessionID string) (*types.RiskResponse, error) {
+	resp, err := f.Primary.ScoreDocument(ctx, content, userID, sessionID)
+	if err == nil || ctx.Err() != nil {
+		return resp, err
+	}
+	log.Printf("document scoring error, using fallback scorer: %v", err)
+	fallbacks.Inc("document")
+	return flagged(f.Secondary.ScoreDocument(ctx, content, userID, sessionID))
+}
+
+// ScoreBatch scores with Primary's batch call, if it has one. Failures
+// aren't handed to Secondary here: callers scoring documents one by one
+// after a failed batch get the fallback per document.
+func (f *Fallback) ScoreBatch(ctx context.Context, contents []string, userID, sessionID string) ([]*types.RiskResponse, error) {
+	b, ok := f.Primary.(BatchScorer)
+	if !ok {
+		return nil, ErrBatchUnsupported
+	}
+	return b.ScoreBatch(ctx, contents, userID, sessionID)
+}
+
+func flagged(resp *types.RiskResponse, err error) (*types.RiskResponse, error) {
+	if err != nil {
+		return nil, err
+	}
+	resp.Flags = append(resp.Flags, FallbackFlag)
+	return resp, nil
+}
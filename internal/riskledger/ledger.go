@@ -0,0 +1,135 @@
+// Package riskledger accumulates risk signals across the turns of a
+// session, so a conversation that keeps probing (repeated MEDIUM prompts,
+// a HIGH now and then) is escalated even when no single turn would be.
+package riskledger
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/shivansh-source/nopass/internal/orchestrator"
+	"github.com/shivansh-source/nopass/internal/storage"
+	"github.com/shivansh-source/nopass/internal/types"
+)
+
+// Escalation actions.
+const (
+	ActionSlow  = "slow"  // the session's turns take the slow path
+	ActionBlock = "block" // the session's turns are refused
+)
+
+// Ledger keeps the running risk of each session in its session record,
+// under the request's tenant.
+type Ledger struct {
+	Sessions storage.SessionStore
+	// Window is how many recent turns count towards the score.
+	Window int
+	// SlowAt and BlockAt are the scores that escalate the session. A block
+	// is sticky: it lasts until the session record is deleted.
+	SlowAt  int
+	BlockAt int
+}
+
+// New returns a Ledger with the default thresholds: over the last 10
+// turns, 3 points (three MEDIUM turns, or one HIGH) force the slow path
+// and 6 block the session.
+func New(sessions storage.SessionStore) *Ledger {
+	return &Ledger{Sessions: sessions, Window: 10, SlowAt: 3, BlockAt: 6}
+}
+
+// Configure applies "window=10,slow=3,block=6"; omitted keys keep their
+// values.
+func (l *Ledger) Configure(spec string) error {
+	for _, kv := range strings.Split(spec, ",") {
+		kv = strings.TrimSpace(kv)
+		if kv == "" {
+			continue
+		}
+		k, v, ok := strings.Cut(kv, "=")
+		n, err := strconv.Atoi(v)
+		if !ok || err != nil || n <= 0 {
+			return fmt.Errorf("session risk setting %q: want key=positive integer", kv)
+		}
+		switch k {
+		case "window":
+			l.Window = n
+		case "slow":
+			l.SlowAt = n
+		case "block":
+			l.BlockAt = n
+		default:
+			return fmt.Errorf("unknown session risk setting %q", k)
+		}
+	}
+	return nil
+}
+
+// points is what one turn adds to the score.
+func points(level types.RiskLevel) int {
+	switch level.Rank() {
+	case 0:
+		return 0
+	case 1:
+		return 1
+	default:
+		// HIGH, and anything unrecognized.
+		return 3
+	}
+}
+
+// Record adds a turn scored risk to the session and returns its updated
+// ledger. A session already blocked stays blocked.
+func (l *Ledger) Record(ctx context.Context, sessionID string, risk *types.RiskResponse) (*types.SessionRisk, error) {
+	tenantID, userID := orchestrator.TenantFrom(ctx), orchestrator.UserFrom(ctx)
+	sess, err := l.Sessions.GetSession(ctx, tenantID, sessionID)
+	if errors.Is(err, storage.ErrNotFound) {
+		sess, err = &storage.Session{TenantID: tenantID, ID: sessionID}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !sess.OwnedBy(userID) {
+		return nil, storage.ErrNotOwner
+	}
+	sess.UserID = userID
+	sr := sess.Risk
+	if sr == nil {
+		sr = &types.SessionRisk{}
+	}
+	sr.Turns++
+	switch risk.RiskLevel.Rank() {
+	case 0:
+	case 1:
+		sr.MediumTurns++
+	default:
+		sr.HighTurns++
+	}
+	sr.Recent = append(sr.Recent, points(risk.RiskLevel))
+	if len(sr.Recent) > l.Window {
+		sr.Recent = sr.Recent[len(sr.Recent)-l.Window:]
+	}
+	sr.Score = 0
+	for _, p := range sr.Recent {
+		sr.Score += p
+	}
+	switch {
+	case sr.Action == ActionBlock, sr.Score >= l.BlockAt:
+		sr.Action, sr.Level = ActionBlock, types.RiskHigh
+	case sr.Score >= l.SlowAt:
+		sr.Action, sr.Level = ActionSlow, types.RiskMedium
+	default:
+		sr.Action, sr.Level = "", types.RiskLow
+	}
+	sr.UpdatedAt = time.Now().UTC()
+
+	sess.Risk = sr
+	sess.UpdatedAt = sr.UpdatedAt
+	if err := l.Sessions.PutSession(ctx, sess); err != nil {
+		return nil, err
+	}
+	return sr, nil
+}
@@ -0,0 +1,14 @@
+package sandbox
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+)
+
+// NewCanary returns a random marker for SandboxInput.Canary, unlike any
+// word an answer would contain by chance.
+func NewCanary() string {
+	var b [8]byte
+	_, _ = rand.Read(b[:])
+	return "NPC-" + hex.EncodeToString(b[:])
+}
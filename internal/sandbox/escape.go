@@ -0,0 +1,21 @@
+package sandbox
+
+import (
+	"regexp"
+	"strings"
+)
+
+// controlTag matches what could pass for the prompt's own structure
+// inside content: the builder's tags, opening or closing, however spaced
+// or cased and even unterminated; comment markers, which could forge the
+// builder's warnings; and chat-template role markers.
+var controlTag = regexp.MustCompile(`(?i)<\s*/?\s*(?:data|external_data|context|memory|history|dangerous_content|system|instructions?)\b[^<>]*>?|<!--|-->|<\|`)
+
+var escapeAngles = strings.NewReplacer("<", "&lt;", ">", "&gt;")
+
+// neutralize escapes the control tags in user or external content, so it
+// can't close the block it sits in or open another. Other angle brackets,
+// e.g. in code, are left alone.
+func neutralize(text string) string {
+	return controlTag.ReplaceAllStringFunc(text, escapeAngles.Replace)
+}
@@ -0,0 +1,48 @@
+package sandbox
+
+import (
+	"strings"
+	"unicode/utf8"
+
+	"github.com/shivansh-source/nopass/internal/types"
+)
+
+// MaskSpans masks text as Mask does and also returns where each masked
+// value was in text, with offsets in Unicode code points. A value a later
+// rule masked again along with its surroundings has no span of its own;
+// the outer match does, if it is still literal text.
+func (in SandboxInput) MaskSpans(text string) (string, []types.MaskedSpan) {
+	type replacement struct{ kind, value string }
+	made := make(map[string]replacement)
+	masked := in.mask(text, func(kind, value, token string) {
+		made[token] = replacement{kind, value}
+	})
+
+	// Outside its tokens, masked is text with the values cut out, so
+	// walking both in step finds each value.
+	var spans []types.MaskedSpan
+	pos, last, runes := 0, 0, 0 // pos in text, last in masked, runes in text[:pos]
+	for _, loc := range TokenPattern.FindAllStringIndex(masked, -1) {
+		tok := masked[loc[0]:loc[1]]
+		r, ok := made[tok]
+		if !ok {
+			continue // the caller's own text, not a replacement
+		}
+		between := masked[last:loc[0]]
+		i := strings.Index(text[pos:], between)
+		if i < 0 {
+			break
+		}
+		start := pos + i + len(between)
+		runes += utf8.RuneCountInString(text[pos:start])
+		pos, last = start, loc[1]
+		if !strings.HasPrefix(text[start:], r.value) {
+			continue
+		}
+		n := utf8.RuneCountInString(r.value)
+		spans = append(spans, types.MaskedSpan{Start: runes, End: runes + n, Kind: r.kind, Token: tok})
+		pos += len(r.value)
+		runes += n
+	}
+	return masked, spans
+}
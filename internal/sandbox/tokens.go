@@ -0,0 +1,68 @@
+package sandbox
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+)
+
+// TokenPattern matches a masking token, e.g. EMAIL_TOKEN_3, capturing the
+// kind and number.
+var TokenPattern = regexp.MustCompile(`\b([A-Z][A-Z0-9_]*?)_TOKEN_(\d+)\b`)
+
+// Tokens is the token map of one request. The same value gets the same
+// token wherever it appears in the prompt, and numbering continues after
+// the tokens already in the conversation so an earlier turn's
+// EMAIL_TOKEN_1 is never reused for a different address.
+type Tokens struct {
+	next    map[string]int    // kind → last number used
+	byValue map[string]string // kind + "\x00" + value → token
+	values  map[string]string // token → value
+	order   []string          // tokens in the order they were made
+}
+
+// NewTokens returns an empty token map whose numbering starts after the
+// tokens found in seen (e.g. the masked history).
+func NewTokens(seen ...string) *Tokens {
+	t := &Tokens{next: make(map[string]int), byValue: make(map[string]string), values: make(map[string]string)}
+	for _, text := range seen {
+		for _, m := range TokenPattern.FindAllStringSubmatch(text, -1) {
+			if n, err := strconv.Atoi(m[2]); err == nil && n > t.next[m[1]] {
+				t.next[m[1]] = n
+			}
+		}
+	}
+	return t
+}
+
+func (t *Tokens) token(kind, value string) string {
+	key := kind + "\x00" + value
+	if tok, ok := t.byValue[key]; ok {
+		return tok
+	}
+	t.next[kind]++
+	tok := fmt.Sprintf("%s_TOKEN_%d", kind, t.next[kind])
+	t.byValue[key] = tok
+	t.values[tok] = value
+	t.order = append(t.order, tok)
+	return tok
+}
+
+// Value returns the value token replaced in this request.
+func (t *Tokens) Value(token string) (string, bool) {
+	if t == nil {
+		return "", false
+	}
+	v, ok := t.values[token]
+	return v, ok
+}
+
+// Each calls fn for every token made, in order.
+func (t *Tokens) Each(fn func(token, value string)) {
+	if t == nil {
+		return
+	}
+	for _, tok := range t.order {
+		fn(tok, t.values[tok])
+	}
+}
@@ -0,0 +1,166 @@
+// Package sandboximage builds sandbox images from a template and vets
+// them before a gateway runs them: nopass image build renders a
+// Dockerfile from a base image, a model weights reference and a stdout
+// protocol around an entrypoint directory such as python/llm_sandbox,
+// builds it through the Docker daemon and tags it by its digest; nopass
+// image update then runs the eval cases (see Eval) against the new image
+// and only if they all pass points the config file at it.
+package sandboximage
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/shivansh-source/nopass/internal/docker"
+	"github.com/shivansh-source/nopass/internal/orchestrator"
+)
+
+// Labels a built image carries besides orchestrator.OutputLabel.
+const (
+	WeightsLabel = "io.nopass.model-weights"
+	BaseLabel    = "io.nopass.base"
+)
+
+// Template is what a sandbox image is built from.
+type Template struct {
+	// Base is the image built on (default python:3.11-slim).
+	Base string
+	// Weights references the model weights the entrypoint loads, e.g.
+	// "hf://meta-llama/Llama-3.1-8B-Instruct" or an s3:// URL. It reaches
+	// the container as NOPASS_MODEL_WEIGHTS and is recorded in the
+	// WeightsLabel; empty leaves the entrypoint's own default.
+	Weights string
+	// Protocol is the stdout protocol the entrypoint speaks,
+	// orchestrator.OutputFrames (the default) or OutputText, declared in
+	// the image's OutputLabel.
+	Protocol string
+	// Source is the directory copied to /app: the entrypoint and what it
+	// needs. Its own Dockerfile, if any, is left out.
+	Source string
+	// Entrypoint is the Python script in Source that runs (default
+	// run_llm.py).
+	Entrypoint string
+}
+
+func (t *Template) defaults() error {
+	if t.Base == "" {
+		t.Base = "python:3.11-slim"
+	}
+	if t.Protocol == "" {
+		t.Protocol = orchestrator.OutputFrames
+	}
+	if t.Entrypoint == "" {
+		t.Entrypoint = "run_llm.py"
+	}
+	if t.Protocol != orchestrator.OutputFrames && t.Protocol != orchestrator.OutputText {
+		return fmt.Errorf("sandboximage: protocol must be frames or text, got %q", t.Protocol)
+	}
+	if t.Source == "" {
+		return fmt.Errorf("sandboximage: no source directory")
+	}
+	if _, err := os.Stat(filepath.Join(t.Source, t.Entrypoint)); err != nil {
+		return fmt.Errorf("sandboximage: entrypoint: %w", err)
+	}
+	return nil
+}
+
+// Dockerfile renders the template.
+func (t Template) Dockerfile() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "FROM %s\n\nWORKDIR /app\n\nCOPY . /app/\n\n", t.Base)
+	if t.Weights != "" {
+		fmt.Fprintf(&b, "ENV NOPASS_MODEL_WEIGHTS=%s\n", strconv.Quote(t.Weights))
+	}
+	fmt.Fprintf(&b, "LABEL %s=%s %s=%s", orchestrator.OutputLabel, t.Protocol, BaseLabel, strconv.Quote(t.Base))
+	if t.Weights != "" {
+		fmt.Fprintf(&b, " %s=%s", WeightsLabel, strconv.Quote(t.Weights))
+	}
+	fmt.Fprintf(&b, "\n\nENTRYPOINT [\"python\", %s]\n", strconv.Quote("/app/"+t.Entrypoint))
+	return b.String()
+}
+
+// Build builds the template's image in repository repo, e.g.
+// "registry.example.com/nopass-llm-sandbox", and tags it by its digest,
+// "sha256-" and the first 12 hex digits of the image ID, so a config
+// naming it never silently runs a later build. It returns that
+// reference.
+func Build(ctx context.Context, dc *docker.Client, repo string, t Template) (string, error) {
+	if err := t.defaults(); err != nil {
+		return "", err
+	}
+	buildContext, err := t.archive()
+	if err != nil {
+		return "", err
+	}
+	id, err := dc.ImageBuild(ctx, buildContext, repo+":build")
+	if err != nil {
+		return "", err
+	}
+	tag := DigestTag(id)
+	if err := dc.ImageTag(ctx, id, repo, tag); err != nil {
+		return "", err
+	}
+	return repo + ":" + tag, nil
+}
+
+// DigestTag is the tag Build gives the image with ID id
+// ("sha256:0123…").
+func DigestTag(id string) string {
+	hex := strings.TrimPrefix(id, "sha256:")
+	if len(hex) > 12 {
+		hex = hex[:12]
+	}
+	return "sha256-" + hex
+}
+
+// archive is the build context: Source's files and the rendered
+// Dockerfile.
+func (t Template) archive() (io.Reader, error) {
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	err := filepath.WalkDir(t.Source, func(path string, d os.DirEntry, err error) error {
+		if err != nil || path == t.Source {
+			return err
+		}
+		rel, err := filepath.Rel(t.Source, path)
+		if err != nil {
+			return err
+		}
+		if d.IsDir() && (d.Name() == "__pycache__" || strings.HasPrefix(d.Name(), ".")) {
+			return filepath.SkipDir
+		}
+		if !d.Type().IsRegular() || rel == "Dockerfile" {
+			return nil
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		return writeFile(tw, filepath.ToSlash(rel), data)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("sandboximage: %w", err)
+	}
+	if err := writeFile(tw, "Dockerfile", []byte(t.Dockerfile())); err != nil {
+		return nil, err
+	}
+	if err := tw.Close(); err != nil {
+		return nil, err
+	}
+	return &buf, nil
+}
+
+func writeFile(tw *tar.Writer, name string, data []byte) error {
+	if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0o644, Size: int64(len(data))}); err != nil {
+		return err
+	}
+	_, err := tw.Write(data)
+	return err
+}
@@ -0,0 +1,74 @@
+// Package scanledger records the verdict of every content scan together with
+// the content hash and the policy version it was scanned under. A verdict
+// is only reused when both match, so a policy change (new quarantine rules,
+// new risk model) always forces a fresh scan.
+package scanledger
+
+import (
+	"sync"
+	"time"
+
+	"github.com/shivansh-source/nopass/internal/types"
+)
+
+// Verdict is the result of scanning one piece of content.
+type Verdict struct {
+	ContentHash   string          `json:"content_hash"`
+	PolicyVersion string          `json:"policy_version"`
+	RiskLevel     types.RiskLevel `json:"risk_level"`
+	Flags         []string        `json:"flags,omitempty"`
+	IsDangerous   bool            `json:"is_dangerous"`
+	ScannedAt     time.Time       `json:"scanned_at"`
+}
+
+// Fresh reports whether v can stand in for a scan of content with hash
+// under policyVersion.
+func (v *Verdict) Fresh(hash, policyVersion string) bool {
+	return v != nil && v.ContentHash == hash && v.PolicyVersion == policyVersion
+}
+
+// Ledger stores the latest verdict per content hash.
+type Ledger interface {
+	Get(hash string) (*Verdict, bool)
+	Put(v Verdict)
+}
+
+// MemoryLedger is a bounded in-process Ledger. When full, the oldest entry
+// is evicted.
+type MemoryLedger struct {
+	mu      sync.Mutex
+	max     int
+	entries map[string]*Verdict
+	order   []string
+}
+
+// NewMemoryLedger creates a ledger holding at most max verdicts.
+func NewMemoryLedger(max int) *MemoryLedger {
+	return &MemoryLedger{max: max, entries: make(map[string]*Verdict)}
+}
+
+// Get implements Ledger.
+func (l *MemoryLedger) Get(hash string) (*Verdict, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	v, ok := l.entries[hash]
+	if !ok {
+		return nil, false
+	}
+	cp := *v
+	return &cp, true
+}
+
+// Put implements Ledger.
+func (l *MemoryLedger) Put(v Verdict) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if _, ok := l.entries[v.ContentHash]; !ok {
+		l.order = append(l.order, v.ContentHash)
+	}
+	l.entries[v.ContentHash] = &v
+	for len(l.order) > l.max && l.max > 0 {
+		delete(l.entries, l.order[0])
+		l.order = l.order[1:]
+	}
+}
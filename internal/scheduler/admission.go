@@ -0,0 +1,267 @@
+package scheduler
+
+import (
+	"container/heap"
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Priority is the scheduling class of a request. Lower values are served
+// first.
+type Priority int
+
+const (
+	PriorityInteractive Priority = iota
+	PriorityBatch
+	PriorityEval
+)
+
+func (p Priority) String() string {
+	switch p {
+	case PriorityBatch:
+		return "batch"
+	case PriorityEval:
+		return "eval"
+	default:
+		return "interactive"
+	}
+}
+
+// cold reports whether p is cold-path traffic subject to ColdPathPolicy.
+func (p Priority) cold() bool {
+	return p != PriorityInteractive
+}
+
+// ParsePriority maps "interactive"/"batch"/"eval" (case-insensitive) to a
+// Priority. The empty string means interactive.
+func ParsePriority(s string) (Priority, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "", "interactive":
+		return PriorityInteractive, nil
+	case "batch":
+		return PriorityBatch, nil
+	case "eval":
+		return PriorityEval, nil
+	default:
+		return PriorityInteractive, fmt.Errorf("unknown priority %q", s)
+	}
+}
+
+// Admission gates access to a fixed number of sandbox slots. Waiters are
+// queued by priority (then FIFO), so an interactive request always jumps
+// ahead of queued batch work, and ReservedInteractive slots are never
+// handed to batch requests at all.
+type Admission struct {
+	// ColdPath, if set, further limits batch/eval traffic during
+	// configured windows (e.g. business hours).
+	ColdPath *ColdPathPolicy
+
+	mu       sync.Mutex
+	slots    int
+	reserved int
+	inUse    int
+	inUseBy  map[Priority]int
+	seq      uint64
+	waiters  waitQueue
+	now      func() time.Time
+}
+
+// NewAdmission creates an Admission with slots total capacity, of which
+// reservedInteractive can only be used by interactive requests.
+func NewAdmission(slots, reservedInteractive int) *Admission {
+	if reservedInteractive > slots {
+		reservedInteractive = slots
+	}
+	return &Admission{
+		slots:    slots,
+		reserved: reservedInteractive,
+		inUseBy:  make(map[Priority]int),
+		now:      time.Now,
+	}
+}
+
+// Acquire blocks until a slot is available for p or ctx is done. The
+// returned func releases the slot and must be called exactly once.
+func (a *Admission) Acquire(ctx context.Context, p Priority) (func(), error) {
+	a.mu.Lock()
+	if !a.queuedAhead(p) && a.canAdmit(p) {
+		a.admit(p)
+		a.mu.Unlock()
+		return a.releaseFunc(p), nil
+	}
+
+	w := &waiter{prio: p, seq: a.seq, ready: make(chan struct{})}
+	a.seq++
+	heap.Push(&a.waiters, w)
+	a.mu.Unlock()
+
+	// Cold-path waiters may become admissible because a window closed rather
+	// than because a slot was released, so they re-check periodically.
+	var recheck <-chan time.Time
+	if p.cold() && a.ColdPath != nil {
+		t := time.NewTicker(15 * time.Second)
+		defer t.Stop()
+		recheck = t.C
+	}
+
+	for {
+		select {
+		case <-w.ready:
+			return a.releaseFunc(p), nil
+		case <-recheck:
+			a.mu.Lock()
+			a.dispatch()
+			a.mu.Unlock()
+		case <-ctx.Done():
+			a.mu.Lock()
+			defer a.mu.Unlock()
+			if w.index < 0 {
+				// Admitted concurrently with cancellation: give the slot back.
+				a.release(p)
+			} else {
+				heap.Remove(&a.waiters, w.index)
+				a.dispatch()
+			}
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// TryAcquire takes a slot for p only if one is free now and nobody of p's
+// priority or higher is queued for it; ok is false otherwise. The returned func releases the
+// slot and must be called exactly once. It is for extra work on behalf of
+// a request that already holds a slot, which mustn't wait for another
+// while holding one.
+func (a *Admission) TryAcquire(p Priority) (release func(), ok bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.queuedAhead(p) || !a.canAdmit(p) {
+		return nil, false
+	}
+	a.admit(p)
+	return a.releaseFunc(p), true
+}
+
+// Stats reports current slot usage per priority and the queue length.
+func (a *Admission) Stats() (inUse map[Priority]int, queued int) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	inUse = make(map[Priority]int, len(a.inUseBy))
+	for p, n := range a.inUseBy {
+		inUse[p] = n
+	}
+	return inUse, len(a.waiters)
+}
+
+func (a *Admission) releaseFunc(p Priority) func() {
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			a.mu.Lock()
+			defer a.mu.Unlock()
+			a.release(p)
+		})
+	}
+}
+
+// queuedAhead reports whether a waiter of priority p or higher is queued,
+// which a new request of priority p must not overtake. Lower-priority
+// waiters don't hold it back: batch work queued behind the reserved or
+// cold-path slots mustn't keep an interactive request from a free slot.
+// Caller must hold a.mu.
+func (a *Admission) queuedAhead(p Priority) bool {
+	return len(a.waiters) > 0 && a.waiters[0].prio <= p
+}
+
+// canAdmit reports whether a request of priority p may take a slot now.
+// Caller must hold a.mu.
+func (a *Admission) canAdmit(p Priority) bool {
+	if a.inUse >= a.slots {
+		return false
+	}
+	if !p.cold() {
+		return true
+	}
+	if a.inUse >= a.slots-a.reserved {
+		return false
+	}
+	if a.ColdPath != nil {
+		ceiling := int(a.ColdPath.maxShare(a.now()) * float64(a.slots))
+		cold := 0
+		for q, n := range a.inUseBy {
+			if q.cold() {
+				cold += n
+			}
+		}
+		if cold >= ceiling {
+			return false
+		}
+	}
+	return true
+}
+
+func (a *Admission) admit(p Priority) {
+	a.inUse++
+	a.inUseBy[p]++
+}
+
+func (a *Admission) release(p Priority) {
+	a.inUse--
+	a.inUseBy[p]--
+	a.dispatch()
+}
+
+// dispatch admits queued waiters in priority order while slots allow.
+// Because interactive waiters sort first, queued batch work never delays
+// them. Caller must hold a.mu.
+func (a *Admission) dispatch() {
+	for len(a.waiters) > 0 {
+		w := a.waiters[0]
+		if !a.canAdmit(w.prio) {
+			return
+		}
+		heap.Pop(&a.waiters)
+		a.admit(w.prio)
+		close(w.ready)
+	}
+}
+
+type waiter struct {
+	prio  Priority
+	seq   uint64
+	ready chan struct{}
+	index int
+}
+
+// waitQueue is a min-heap ordered by (priority, arrival).
+type waitQueue []*waiter
+
+func (q waitQueue) Len() int { return len(q) }
+func (q waitQueue) Less(i, j int) bool {
+	if q[i].prio != q[j].prio {
+		return q[i].prio < q[j].prio
+	}
+	return q[i].seq < q[j].seq
+}
+func (q waitQueue) Swap(i, j int) {
+	q[i], q[j] = q[j], q[i]
+	q[i].index = i
+	q[j].index = j
+}
+func (q *waitQueue) Push(x any) {
+	w := x.(*waiter)
+	w.index = len(*q)
+	*q = append(*q, w)
+}
+func (q *waitQueue) Pop() any {
+	old := *q
+	n := len(old)
+	w := old[n-1]
+	old[n-1] = nil
+	w.index = -1
+	*q = old[:n-1]
+	return w
+}
@@ -0,0 +1,156 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// queue starts an Acquire for each priority in ps, in order, and waits
+// until it is queued. The priority of each admitted waiter is sent on the
+// returned channel; waiters are cancelled and slots released when the
+// test ends.
+func queue(t *testing.T, a *Admission, ps ...Priority) <-chan Priority {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	admitted := make(chan Priority, len(ps))
+	for _, p := range ps {
+		_, before := a.Stats()
+		go func() {
+			release, err := a.Acquire(ctx, p)
+			if err != nil {
+				return
+			}
+			admitted <- p
+			<-ctx.Done()
+			release()
+		}()
+		deadline := time.Now().Add(2 * time.Second)
+		for {
+			if _, n := a.Stats(); n > before {
+				break
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("%s request was admitted, want it queued", p)
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+	return admitted
+}
+
+func TestAdmissionAcquire(t *testing.T) {
+	// Windows covering the whole week.
+	closed := &ColdPathPolicy{Windows: []Window{{End: 24 * time.Hour, MaxShare: 0}}}
+	half := &ColdPathPolicy{Windows: []Window{{End: 24 * time.Hour, MaxShare: 0.5}}}
+	tests := []struct {
+		name     string
+		slots    int
+		reserved int
+		coldPath *ColdPathPolicy
+		inUse    []Priority
+		queued   []Priority
+		acquire  Priority
+		want     bool
+	}{
+		{
+			name: "free slot", slots: 2,
+			acquire: PriorityBatch, want: true,
+		},
+		{
+			name: "full", slots: 2,
+			inUse:   []Priority{PriorityInteractive, PriorityInteractive},
+			acquire: PriorityInteractive, want: false,
+		},
+		{
+			name: "batch kept out of reserved slot", slots: 4, reserved: 1,
+			inUse:   []Priority{PriorityBatch, PriorityBatch, PriorityBatch},
+			acquire: PriorityBatch, want: false,
+		},
+		{
+			name: "interactive takes reserved slot", slots: 4, reserved: 1,
+			inUse:   []Priority{PriorityBatch, PriorityBatch, PriorityBatch},
+			acquire: PriorityInteractive, want: true,
+		},
+		{
+			name: "interactive passes queued batch", slots: 4, reserved: 1,
+			inUse:   []Priority{PriorityBatch, PriorityBatch, PriorityBatch},
+			queued:  []Priority{PriorityBatch},
+			acquire: PriorityInteractive, want: true,
+		},
+		{
+			name: "batch doesn't pass queued batch", slots: 4, reserved: 1,
+			inUse:   []Priority{PriorityBatch, PriorityBatch, PriorityBatch},
+			queued:  []Priority{PriorityBatch},
+			acquire: PriorityBatch, want: false,
+		},
+		{
+			name: "cold path closed", slots: 4, coldPath: closed,
+			acquire: PriorityBatch, want: false,
+		},
+		{
+			name: "interactive passes batch held at closed cold path", slots: 4, coldPath: closed,
+			queued:  []Priority{PriorityBatch, PriorityEval},
+			acquire: PriorityInteractive, want: true,
+		},
+		{
+			name: "cold path at ceiling", slots: 4, coldPath: half,
+			inUse:   []Priority{PriorityBatch, PriorityEval},
+			acquire: PriorityBatch, want: false,
+		},
+		{
+			name: "interactive passes batch held at ceiling", slots: 4, coldPath: half,
+			inUse:   []Priority{PriorityBatch, PriorityEval},
+			queued:  []Priority{PriorityBatch},
+			acquire: PriorityInteractive, want: true,
+		},
+		{
+			name: "interactive doesn't pass queued interactive", slots: 2,
+			inUse:   []Priority{PriorityInteractive, PriorityInteractive},
+			queued:  []Priority{PriorityInteractive},
+			acquire: PriorityInteractive, want: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := NewAdmission(tt.slots, tt.reserved)
+			a.ColdPath = tt.coldPath
+			for _, p := range tt.inUse {
+				release, ok := a.TryAcquire(p)
+				if !ok {
+					t.Fatalf("filling slots: %s request not admitted", p)
+				}
+				t.Cleanup(release)
+			}
+			queue(t, a, tt.queued...)
+
+			ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+			defer cancel()
+			release, err := a.Acquire(ctx, tt.acquire)
+			if got := err == nil; got != tt.want {
+				t.Fatalf("Acquire(%s) admitted = %v (err %v), want %v", tt.acquire, got, err, tt.want)
+			}
+			if err != nil && !errors.Is(err, context.DeadlineExceeded) {
+				t.Fatalf("Acquire(%s) error = %v, want deadline exceeded", tt.acquire, err)
+			}
+			if err == nil {
+				release()
+			}
+		})
+	}
+}
+
+func TestAdmissionReleaseAdmitsInteractiveFirst(t *testing.T) {
+	a := NewAdmission(1, 0)
+	release, ok := a.TryAcquire(PriorityBatch)
+	if !ok {
+		t.Fatal("first request not admitted")
+	}
+	admitted := queue(t, a, PriorityBatch, PriorityInteractive)
+	release()
+	if first := <-admitted; first != PriorityInteractive {
+		t.Errorf("first admitted after release = %s, want interactive", first)
+	}
+}
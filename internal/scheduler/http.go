@@ -0,0 +1,75 @@
+package scheduler
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/shivansh-source/nopass/internal/types"
+)
+
+// HeartbeatHandler accepts runner registrations and heartbeats (POST). A
+// heartbeat must carry its runner's token as "Authorization: Bearer
+// <token>", so a host can only register as the runner it holds the secret
+// of.
+func (s *Scheduler) HeartbeatHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var hb types.RunnerHeartbeat
+	if err := json.NewDecoder(r.Body).Decode(&hb); err != nil {
+		http.Error(w, "invalid JSON body", http.StatusBadRequest)
+		return
+	}
+	if token, ok := s.Tokens[hb.ID]; !ok || !validToken(r, token) {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if err := s.Heartbeat(hb); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// RequireToken rejects requests to next that don't carry
+// "Authorization: Bearer <token>".
+func RequireToken(token string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !validToken(r, token) {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func validToken(r *http.Request, token string) bool {
+	got, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return ok && token != "" && subtle.ConstantTimeCompare([]byte(got), []byte(token)) == 1
+}
+
+// ParseTokens parses "runner-a=<secret>,runner-b=<secret>" into
+// Scheduler.Tokens.
+func ParseTokens(spec string) (map[string]string, error) {
+	out := make(map[string]string)
+	for i, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		// Entries are secrets: errors name their position only.
+		id, token, ok := strings.Cut(entry, "=")
+		if !ok || id == "" || token == "" {
+			return nil, fmt.Errorf("runner token entry %d: want id=token", i+1)
+		}
+		out[id] = token
+	}
+	if len(out) == 0 {
+		return nil, fmt.Errorf("no runner tokens")
+	}
+	return out, nil
+}
@@ -0,0 +1,235 @@
+package scheduler
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/shivansh-source/nopass/internal/types"
+)
+
+// ErrNoCapacity is returned when no live runner has a free slot.
+var ErrNoCapacity = errors.New("no sandbox runner capacity available")
+
+// runner is the scheduler's view of a single runner host.
+type runner struct {
+	info     types.RunnerHeartbeat
+	lastSeen time.Time
+	// leased counts slots handed out by this gateway that the runner may not
+	// have reported yet in its heartbeat.
+	leased int
+}
+
+// Scheduler tracks registered runner hosts and hands out slots on the
+// least-loaded one. It is intentionally simple: every gateway replica keeps
+// its own view, fed by runner heartbeats, and runners reject work above
+// their capacity so replicas can never oversubscribe a host for long.
+type Scheduler struct {
+	// Tokens maps each runner ID to the secret it and the gateway share:
+	// its heartbeats must carry it and runs sent to it do. A runner without
+	// one can't register.
+	Tokens map[string]string
+	// Dedicated maps runner IDs to the only tenants they may serve. It is
+	// the gateway's own configuration: what a runner's heartbeat says about
+	// its tenants is ignored.
+	Dedicated map[string][]string
+
+	mu      sync.Mutex
+	runners map[string]*runner
+	ttl     time.Duration
+	now     func() time.Time
+}
+
+// New creates a Scheduler. Runners that have not sent a heartbeat within ttl
+// are considered dead and are not scheduled.
+func New(ttl time.Duration) *Scheduler {
+	return &Scheduler{
+		runners: make(map[string]*runner),
+		ttl:     ttl,
+		now:     time.Now,
+	}
+}
+
+// Heartbeat registers a runner or refreshes its state.
+func (s *Scheduler) Heartbeat(hb types.RunnerHeartbeat) error {
+	if err := types.CheckSchemaVersion(hb.SchemaVersion); err != nil {
+		return err
+	}
+	if hb.ID == "" || hb.Addr == "" {
+		return errors.New("runner id and addr are required")
+	}
+	if hb.Capacity <= 0 {
+		return errors.New("runner capacity must be positive")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	r, ok := s.runners[hb.ID]
+	if !ok {
+		r = &runner{}
+		s.runners[hb.ID] = r
+	}
+	r.info = hb
+	r.info.Tenants = s.Dedicated[hb.ID]
+	r.lastSeen = s.now()
+	return nil
+}
+
+// Remove deregisters a runner immediately.
+func (s *Scheduler) Remove(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.runners, id)
+}
+
+// Lease is a reserved slot on a runner. Release must be called exactly once.
+type Lease struct {
+	RunnerID string
+	Addr     string
+	// Token is the runner's secret, to authenticate the run to it.
+	Token string
+
+	s    *Scheduler
+	once sync.Once
+}
+
+// Release returns the slot to the scheduler.
+func (l *Lease) Release() {
+	l.once.Do(func() {
+		l.s.mu.Lock()
+		defer l.s.mu.Unlock()
+		if r, ok := l.s.runners[l.RunnerID]; ok && r.leased > 0 {
+			r.leased--
+		}
+	})
+}
+
+// Acquire reserves a slot on the least-loaded live runner eligible for
+// tenantID, skipping any IDs in exclude (e.g. runners that just failed for
+// this request). A tenant with dedicated runners is only ever placed on
+// them, and a dedicated runner never serves another tenant.
+func (s *Scheduler) Acquire(tenantID string, exclude ...string) (*Lease, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	dedicated := s.hasDedicated(tenantID)
+	live := s.live(exclude)
+
+	var best *runner
+	bestLoad := 0.0
+	for _, r := range live {
+		if !r.serves(tenantID, dedicated) {
+			continue
+		}
+		used := r.info.InFlight + r.leased
+		if used >= r.info.Capacity {
+			continue
+		}
+		load := float64(used) / float64(r.info.Capacity)
+		if best == nil || load < bestLoad {
+			best, bestLoad = r, load
+		}
+	}
+	if best == nil {
+		return nil, ErrNoCapacity
+	}
+
+	best.leased++
+	return &Lease{RunnerID: best.info.ID, Addr: best.info.Addr, Token: s.Tokens[best.info.ID], s: s}, nil
+}
+
+// serves reports whether r may run work for tenantID. When the tenant has
+// dedicated runners, shared ones are off limits.
+func (r *runner) serves(tenantID string, tenantHasDedicated bool) bool {
+	if len(r.info.Tenants) == 0 {
+		return !tenantHasDedicated
+	}
+	for _, t := range r.info.Tenants {
+		if t == tenantID && tenantID != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// hasDedicated reports whether any runner is dedicated to tenantID, live
+// or not: a tenant whose dedicated runners are down waits for them rather
+// than spilling onto shared ones.
+func (s *Scheduler) hasDedicated(tenantID string) bool {
+	if tenantID == "" {
+		return false
+	}
+	for _, tenants := range s.Dedicated {
+		if slices.Contains(tenants, tenantID) {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseDedicated parses "runner-a=acme,globex;runner-b=initech" into
+// Scheduler.Dedicated.
+func ParseDedicated(spec string) (map[string][]string, error) {
+	out := make(map[string][]string)
+	for _, entry := range strings.Split(spec, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		id, list, ok := strings.Cut(entry, "=")
+		id = strings.TrimSpace(id)
+		if !ok || id == "" {
+			return nil, fmt.Errorf("dedicated runner entry %q: want runner=tenant,...", entry)
+		}
+		for _, t := range strings.Split(list, ",") {
+			if t = strings.TrimSpace(t); t != "" {
+				out[id] = append(out[id], t)
+			}
+		}
+	}
+	return out, nil
+}
+
+// Snapshot returns the current state of every live runner, sorted by ID.
+func (s *Scheduler) Snapshot() []types.RunnerHeartbeat {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var out []types.RunnerHeartbeat
+	for _, r := range s.live(nil) {
+		info := r.info
+		info.InFlight += r.leased
+		out = append(out, info)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ID < out[j].ID })
+	return out
+}
+
+// live returns runners with a recent heartbeat that are not draining.
+// Stale runners are dropped. Caller must hold s.mu.
+func (s *Scheduler) live(exclude []string) []*runner {
+	now := s.now()
+	var out []*runner
+outer:
+	for id, r := range s.runners {
+		if now.Sub(r.lastSeen) > s.ttl {
+			delete(s.runners, id)
+			continue
+		}
+		if r.info.Draining {
+			continue
+		}
+		for _, ex := range exclude {
+			if ex == id {
+				continue outer
+			}
+		}
+		out = append(out, r)
+	}
+	return out
+}
@@ -0,0 +1,111 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/shivansh-source/nopass/internal/metrics"
+)
+
+// ErrSessionBusy is returned by SessionQueue.Acquire when the session
+// already has the most turns allowed waiting.
+var ErrSessionBusy = errors.New("too many requests waiting for this session")
+
+var sessionTurns = metrics.NewCounterVec(
+	"nopass_session_queue_total",
+	"Chat turns by how the per-session queue admitted them.",
+	"result",
+)
+
+// SessionQueue runs the turns of each session one at a time, in arrival
+// order, so concurrent turns don't race on the session's history and
+// risk state. Sessions are independent. It orders turns within one
+// gateway only; replicas need session affinity for the same guarantee.
+type SessionQueue struct {
+	// MaxWaiting bounds the turns of one session waiting behind the
+	// running one.
+	MaxWaiting int
+
+	mu       sync.Mutex
+	sessions map[string]*sessionLine
+}
+
+// sessionLine is one session's running turn and the turns behind it.
+type sessionLine struct {
+	waiting []chan struct{}
+}
+
+// NewSessionQueue creates a SessionQueue letting maxWaiting turns wait
+// per session.
+func NewSessionQueue(maxWaiting int) *SessionQueue {
+	return &SessionQueue{MaxWaiting: maxWaiting, sessions: make(map[string]*sessionLine)}
+}
+
+// Acquire blocks until every earlier turn of session has finished, ctx
+// is done, or returns ErrSessionBusy at once if the queue is full. The
+// returned func ends the turn and must be called exactly once.
+func (q *SessionQueue) Acquire(ctx context.Context, session string) (func(), error) {
+	q.mu.Lock()
+	line, ok := q.sessions[session]
+	if !ok {
+		q.sessions[session] = &sessionLine{}
+		q.mu.Unlock()
+		sessionTurns.Inc("immediate")
+		return q.releaseFunc(session), nil
+	}
+	if len(line.waiting) >= q.MaxWaiting {
+		q.mu.Unlock()
+		sessionTurns.Inc("busy")
+		return nil, ErrSessionBusy
+	}
+	ready := make(chan struct{})
+	line.waiting = append(line.waiting, ready)
+	q.mu.Unlock()
+
+	select {
+	case <-ready:
+		sessionTurns.Inc("waited")
+		return q.releaseFunc(session), nil
+	case <-ctx.Done():
+	}
+	q.mu.Lock()
+	select {
+	case <-ready:
+		// The turn came up as ctx ended; pass it on.
+		q.mu.Unlock()
+		q.release(session)
+	default:
+		for i, c := range line.waiting {
+			if c == ready {
+				line.waiting = append(line.waiting[:i], line.waiting[i+1:]...)
+				break
+			}
+		}
+		q.mu.Unlock()
+	}
+	sessionTurns.Inc("cancelled")
+	return nil, ctx.Err()
+}
+
+func (q *SessionQueue) releaseFunc(session string) func() {
+	var once sync.Once
+	return func() { once.Do(func() { q.release(session) }) }
+}
+
+// release ends the running turn of session, starting the next.
+func (q *SessionQueue) release(session string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	line := q.sessions[session]
+	if line == nil {
+		return
+	}
+	if len(line.waiting) == 0 {
+		delete(q.sessions, session)
+		return
+	}
+	next := line.waiting[0]
+	line.waiting = line.waiting[1:]
+	close(next)
+}
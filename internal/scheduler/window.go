@@ -0,0 +1,149 @@
+package scheduler
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Window restricts cold-path (batch/eval) traffic during part of the week.
+// While a window is active, cold traffic may use at most MaxShare of the
+// sandbox slots; a MaxShare of 0 closes the cold path entirely.
+type Window struct {
+	Days     []time.Weekday
+	Start    time.Duration // offset from midnight
+	End      time.Duration // offset from midnight, exclusive
+	MaxShare float64
+}
+
+// contains reports whether t falls inside the window.
+func (w Window) contains(t time.Time) bool {
+	dayOK := len(w.Days) == 0
+	for _, d := range w.Days {
+		if d == t.Weekday() {
+			dayOK = true
+			break
+		}
+	}
+	if !dayOK {
+		return false
+	}
+	tod := time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute + time.Duration(t.Second())*time.Second
+	return tod >= w.Start && tod < w.End
+}
+
+// ColdPathPolicy is the set of windows applied to batch/eval traffic.
+type ColdPathPolicy struct {
+	Windows  []Window
+	Location *time.Location
+}
+
+// maxShare returns the slot share cold traffic may use at t. Outside every
+// window the share is 1 (no extra limit). Overlapping windows take the
+// strictest share.
+func (p *ColdPathPolicy) maxShare(t time.Time) float64 {
+	if p == nil {
+		return 1
+	}
+	if p.Location != nil {
+		t = t.In(p.Location)
+	}
+	share := 1.0
+	for _, w := range p.Windows {
+		if w.contains(t) && w.MaxShare < share {
+			share = w.MaxShare
+		}
+	}
+	return share
+}
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+// ParseWindows parses a semicolon-separated list of windows of the form
+//
+//	"mon-fri 09:00-18:00 20%; sat 10:00-14:00 0%"
+//
+// The day spec may be a single day, a range, or "*" for every day.
+func ParseWindows(spec string) ([]Window, error) {
+	var out []Window
+	for _, raw := range strings.Split(spec, ";") {
+		raw = strings.TrimSpace(raw)
+		if raw == "" {
+			continue
+		}
+		fields := strings.Fields(raw)
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("window %q: want \"<days> <HH:MM-HH:MM> <N%%>\"", raw)
+		}
+
+		days, err := parseDays(fields[0])
+		if err != nil {
+			return nil, fmt.Errorf("window %q: %w", raw, err)
+		}
+
+		from, to, ok := strings.Cut(fields[1], "-")
+		if !ok {
+			return nil, fmt.Errorf("window %q: bad time range", raw)
+		}
+		start, err := parseClock(from)
+		if err != nil {
+			return nil, fmt.Errorf("window %q: %w", raw, err)
+		}
+		end, err := parseClock(to)
+		if err != nil {
+			return nil, fmt.Errorf("window %q: %w", raw, err)
+		}
+		if end <= start {
+			return nil, fmt.Errorf("window %q: end must be after start", raw)
+		}
+
+		pct, err := strconv.ParseFloat(strings.TrimSuffix(fields[2], "%"), 64)
+		if err != nil || pct < 0 || pct > 100 {
+			return nil, fmt.Errorf("window %q: bad share %q", raw, fields[2])
+		}
+
+		out = append(out, Window{Days: days, Start: start, End: end, MaxShare: pct / 100})
+	}
+	return out, nil
+}
+
+func parseDays(s string) ([]time.Weekday, error) {
+	if s == "*" {
+		return nil, nil
+	}
+	from, to, isRange := strings.Cut(strings.ToLower(s), "-")
+	a, ok := weekdays[from]
+	if !ok {
+		return nil, fmt.Errorf("unknown day %q", from)
+	}
+	if !isRange {
+		return []time.Weekday{a}, nil
+	}
+	b, ok := weekdays[to]
+	if !ok {
+		return nil, fmt.Errorf("unknown day %q", to)
+	}
+	var days []time.Weekday
+	for d := a; ; d = (d + 1) % 7 {
+		days = append(days, d)
+		if d == b {
+			break
+		}
+	}
+	return days, nil
+}
+
+func parseClock(s string) (time.Duration, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		if s == "24:00" {
+			return 24 * time.Hour, nil
+		}
+		return 0, fmt.Errorf("bad time %q", s)
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
@@ -0,0 +1,173 @@
+package scim
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/shivansh-source/nopass/internal/directory"
+)
+
+// Groups are NoPass tenants: the group's id is the tenant ID used in chat
+// requests, and its displayName the tenant's name.
+
+func (s *Server) groupResource(t *directory.Tenant) groupResource {
+	return groupResource{
+		Schemas:     []string{schemaGroup, schemaTenantExt},
+		ID:          t.ID,
+		ExternalID:  t.ExternalID,
+		DisplayName: t.Name,
+		Ext:         &tenantExt{Active: boolPtr(t.Active), PolicyID: t.PolicyID},
+		Meta: &meta{
+			ResourceType: "Group",
+			Created:      t.Created,
+			LastModified: t.Modified,
+			Location:     s.BaseURL + "/Groups/" + t.ID,
+		},
+	}
+}
+
+func toTenant(res *groupResource, t *directory.Tenant) error {
+	if res.DisplayName == "" {
+		return errors.New("displayName is required")
+	}
+	t.ExternalID = res.ExternalID
+	t.Name = res.DisplayName
+	t.Active = true
+	t.PolicyID = ""
+	if res.Ext != nil {
+		t.Active = res.Ext.Active == nil || *res.Ext.Active
+		t.PolicyID = res.Ext.PolicyID
+	}
+	t.Modified = time.Now().UTC()
+	return nil
+}
+
+func (s *Server) listGroups(w http.ResponseWriter, r *http.Request) {
+	attr, value, err := parseFilter(r.URL.Query().Get("filter"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	tenants, err := s.Directory.ListTenants(r.Context())
+	if err != nil {
+		s.storeError(w, err)
+		return
+	}
+	var items []any
+	for i := range tenants {
+		t := &tenants[i]
+		switch strings.ToLower(attr) {
+		case "":
+		case "displayname":
+			if t.Name != value {
+				continue
+			}
+		case "externalid":
+			if t.ExternalID != value {
+				continue
+			}
+		default:
+			writeError(w, http.StatusBadRequest, "unsupported filter attribute "+attr)
+			return
+		}
+		items = append(items, s.groupResource(t))
+	}
+	writeJSON(w, http.StatusOK, page(r, items))
+}
+
+// createGroup provisions a tenant. The tenant ID is the group's id if the
+// IdP supplies one (so it matches existing tenant IDs), else generated.
+func (s *Server) createGroup(w http.ResponseWriter, r *http.Request) {
+	var res groupResource
+	if err := json.NewDecoder(r.Body).Decode(&res); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid JSON body")
+		return
+	}
+	id := res.ID
+	if id == "" {
+		id = newID()
+	}
+	if _, err := s.Directory.GetTenant(r.Context(), id); err == nil {
+		writeError(w, http.StatusConflict, "tenant already exists")
+		return
+	}
+	t := &directory.Tenant{ID: id, Created: time.Now().UTC()}
+	if err := toTenant(&res, t); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err := s.Directory.PutTenant(r.Context(), t); err != nil {
+		s.storeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, s.groupResource(t))
+}
+
+func (s *Server) getGroup(w http.ResponseWriter, r *http.Request) {
+	t, err := s.Directory.GetTenant(r.Context(), r.PathValue("id"))
+	if err != nil {
+		s.storeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, s.groupResource(t))
+}
+
+func (s *Server) replaceGroup(w http.ResponseWriter, r *http.Request) {
+	t, err := s.Directory.GetTenant(r.Context(), r.PathValue("id"))
+	if err != nil {
+		s.storeError(w, err)
+		return
+	}
+	var res groupResource
+	if err := json.NewDecoder(r.Body).Decode(&res); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid JSON body")
+		return
+	}
+	s.saveGroup(w, r, &res, t)
+}
+
+func (s *Server) patchGroup(w http.ResponseWriter, r *http.Request) {
+	t, err := s.Directory.GetTenant(r.Context(), r.PathValue("id"))
+	if err != nil {
+		s.storeError(w, err)
+		return
+	}
+	var req patchRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid JSON body")
+		return
+	}
+	res := s.groupResource(t)
+	if err := applyPatch(&res, req); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	s.saveGroup(w, r, &res, t)
+}
+
+func (s *Server) saveGroup(w http.ResponseWriter, r *http.Request, res *groupResource, t *directory.Tenant) {
+	if err := toTenant(res, t); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err := s.Directory.PutTenant(r.Context(), t); err != nil {
+		s.storeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, s.groupResource(t))
+}
+
+func (s *Server) deleteGroup(w http.ResponseWriter, r *http.Request) {
+	if _, err := s.Directory.GetTenant(r.Context(), r.PathValue("id")); err != nil {
+		s.storeError(w, err)
+		return
+	}
+	if err := s.Directory.DeleteTenant(r.Context(), r.PathValue("id")); err != nil {
+		s.storeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,115 @@
+package scim
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// applyPatch applies SCIM PatchOp operations to resource (a *userResource
+// or *groupResource) by way of its JSON form. Supported: add/replace/remove
+// on top-level attributes and on extension attributes addressed as
+// "<schema URN>:<attr>", plus path-less add/replace with an object value.
+func applyPatch(resource any, req patchRequest) error {
+	data, err := json.Marshal(resource)
+	if err != nil {
+		return err
+	}
+	var doc map[string]any
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return err
+	}
+
+	for _, op := range req.Operations {
+		var value any
+		if len(op.Value) > 0 {
+			if err := json.Unmarshal(op.Value, &value); err != nil {
+				return fmt.Errorf("invalid value for %s: %w", op.Path, err)
+			}
+		}
+		switch strings.ToLower(op.Op) {
+		case "add", "replace":
+			if op.Path == "" {
+				obj, ok := value.(map[string]any)
+				if !ok {
+					return fmt.Errorf("%s without path needs an object value", op.Op)
+				}
+				for k, v := range obj {
+					if err := set(doc, k, v); err != nil {
+						return err
+					}
+				}
+				continue
+			}
+			if err := set(doc, op.Path, value); err != nil {
+				return err
+			}
+		case "remove":
+			if op.Path == "" {
+				return fmt.Errorf("remove needs a path")
+			}
+			parent, attr := locate(doc, op.Path)
+			delete(parent, attr)
+		default:
+			return fmt.Errorf("unsupported patch op %q", op.Op)
+		}
+	}
+
+	data, err = json.Marshal(doc)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, resource)
+}
+
+// set assigns value at path, merging extension objects and coercing the
+// "True"/"False" strings some IdPs send for booleans.
+func set(doc map[string]any, path string, value any) error {
+	if strings.Contains(path, "[") {
+		return fmt.Errorf("unsupported patch path %q", path)
+	}
+	if path == schemaUserExt || path == schemaTenantExt {
+		obj, ok := value.(map[string]any)
+		if !ok {
+			return fmt.Errorf("%s needs an object value", path)
+		}
+		ext, _ := doc[path].(map[string]any)
+		if ext == nil {
+			ext = make(map[string]any)
+		}
+		for k, v := range obj {
+			ext[k] = coerce(k, v)
+		}
+		doc[path] = ext
+		return nil
+	}
+	parent, attr := locate(doc, path)
+	parent[attr] = coerce(attr, value)
+	return nil
+}
+
+// locate resolves "urn:...:Schema:attr" to the extension object and the
+// attribute name; anything else is a top-level attribute.
+func locate(doc map[string]any, path string) (map[string]any, string) {
+	for _, urn := range []string{schemaUserExt, schemaTenantExt} {
+		if attr, ok := strings.CutPrefix(path, urn+":"); ok {
+			ext, _ := doc[urn].(map[string]any)
+			if ext == nil {
+				ext = make(map[string]any)
+				doc[urn] = ext
+			}
+			return ext, attr
+		}
+	}
+	return doc, path
+}
+
+func coerce(attr string, v any) any {
+	if attr != "active" {
+		return v
+	}
+	if s, ok := v.(string); ok {
+		return strings.EqualFold(s, "true")
+	}
+	return v
+}
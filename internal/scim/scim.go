@@ -0,0 +1,224 @@
+// Package scim implements the subset of SCIM 2.0 (RFC 7643/7644) that
+// identity providers use for provisioning: Users, and Groups standing for
+// NoPass tenants. NoPass-specific attributes (tenant, trust level, policy)
+// travel in an extension schema.
+package scim
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/shivansh-source/nopass/internal/directory"
+)
+
+const (
+	schemaUser         = "urn:ietf:params:scim:schemas:core:2.0:User"
+	schemaGroup        = "urn:ietf:params:scim:schemas:core:2.0:Group"
+	schemaUserExt      = "urn:nopass:params:scim:schemas:extension:2.0:User"
+	schemaTenantExt    = "urn:nopass:params:scim:schemas:extension:2.0:Tenant"
+	schemaListResponse = "urn:ietf:params:scim:api:messages:2.0:ListResponse"
+	schemaPatchOp      = "urn:ietf:params:scim:api:messages:2.0:PatchOp"
+	schemaError        = "urn:ietf:params:scim:api:messages:2.0:Error"
+	schemaSPConfig     = "urn:ietf:params:scim:schemas:core:2.0:ServiceProviderConfig"
+
+	contentType = "application/scim+json"
+)
+
+// Server serves /scim/v2/.
+type Server struct {
+	Directory *directory.Directory
+	// Token is the bearer token the IdP presents. Required.
+	Token string
+	// BaseURL is the externally visible URL prefix for meta.location,
+	// e.g. "https://nopass.example.com/scim/v2".
+	BaseURL string
+}
+
+// Handler returns the SCIM mux, to be mounted at /scim/v2/.
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /scim/v2/ServiceProviderConfig", s.serviceProviderConfig)
+	mux.HandleFunc("GET /scim/v2/Users", s.listUsers)
+	mux.HandleFunc("POST /scim/v2/Users", s.createUser)
+	mux.HandleFunc("GET /scim/v2/Users/{id}", s.getUser)
+	mux.HandleFunc("PUT /scim/v2/Users/{id}", s.replaceUser)
+	mux.HandleFunc("PATCH /scim/v2/Users/{id}", s.patchUser)
+	mux.HandleFunc("DELETE /scim/v2/Users/{id}", s.deleteUser)
+	mux.HandleFunc("GET /scim/v2/Groups", s.listGroups)
+	mux.HandleFunc("POST /scim/v2/Groups", s.createGroup)
+	mux.HandleFunc("GET /scim/v2/Groups/{id}", s.getGroup)
+	mux.HandleFunc("PUT /scim/v2/Groups/{id}", s.replaceGroup)
+	mux.HandleFunc("PATCH /scim/v2/Groups/{id}", s.patchGroup)
+	mux.HandleFunc("DELETE /scim/v2/Groups/{id}", s.deleteGroup)
+	return s.auth(mux)
+}
+
+func (s *Server) auth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if s.Token == "" || subtle.ConstantTimeCompare([]byte(got), []byte(s.Token)) != 1 {
+			writeError(w, http.StatusUnauthorized, "invalid bearer token")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// ----- wire formats ----- //
+
+type meta struct {
+	ResourceType string    `json:"resourceType"`
+	Created      time.Time `json:"created"`
+	LastModified time.Time `json:"lastModified"`
+	Location     string    `json:"location,omitempty"`
+}
+
+type email struct {
+	Value   string `json:"value"`
+	Primary bool   `json:"primary,omitempty"`
+}
+
+type userExt struct {
+	TenantID   string `json:"tenantId"`
+	TrustLevel string `json:"trustLevel,omitempty"`
+	PolicyID   string `json:"policyId,omitempty"`
+}
+
+type userResource struct {
+	Schemas     []string `json:"schemas"`
+	ID          string   `json:"id,omitempty"`
+	ExternalID  string   `json:"externalId,omitempty"`
+	UserName    string   `json:"userName"`
+	DisplayName string   `json:"displayName,omitempty"`
+	Active      *bool    `json:"active,omitempty"`
+	Emails      []email  `json:"emails,omitempty"`
+	Ext         *userExt `json:"urn:nopass:params:scim:schemas:extension:2.0:User,omitempty"`
+	Meta        *meta    `json:"meta,omitempty"`
+}
+
+type tenantExt struct {
+	Active   *bool  `json:"active,omitempty"`
+	PolicyID string `json:"policyId,omitempty"`
+}
+
+type groupResource struct {
+	Schemas     []string   `json:"schemas"`
+	ID          string     `json:"id,omitempty"`
+	ExternalID  string     `json:"externalId,omitempty"`
+	DisplayName string     `json:"displayName"`
+	Ext         *tenantExt `json:"urn:nopass:params:scim:schemas:extension:2.0:Tenant,omitempty"`
+	Meta        *meta      `json:"meta,omitempty"`
+}
+
+type listResponse struct {
+	Schemas      []string `json:"schemas"`
+	TotalResults int      `json:"totalResults"`
+	StartIndex   int      `json:"startIndex"`
+	ItemsPerPage int      `json:"itemsPerPage"`
+	Resources    []any    `json:"Resources"`
+}
+
+type patchRequest struct {
+	Schemas    []string `json:"schemas"`
+	Operations []struct {
+		Op    string          `json:"op"`
+		Path  string          `json:"path,omitempty"`
+		Value json.RawMessage `json:"value"`
+	} `json:"Operations"`
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", contentType)
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("scim: encode response error: %v", err)
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, detail string) {
+	writeJSON(w, status, map[string]any{
+		"schemas": []string{schemaError},
+		"status":  strconv.Itoa(status),
+		"detail":  detail,
+	})
+}
+
+func (s *Server) storeError(w http.ResponseWriter, err error) {
+	if errors.Is(err, directory.ErrNotFound) {
+		writeError(w, http.StatusNotFound, "resource not found")
+		return
+	}
+	log.Printf("scim: directory error: %v", err)
+	writeError(w, http.StatusInternalServerError, "internal error")
+}
+
+func newID() string {
+	var b [16]byte
+	_, _ = rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
+
+func (s *Server) serviceProviderConfig(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]any{
+		"schemas":        []string{schemaSPConfig},
+		"patch":          map[string]bool{"supported": true},
+		"bulk":           map[string]any{"supported": false, "maxOperations": 0, "maxPayloadSize": 0},
+		"filter":         map[string]any{"supported": true, "maxResults": 1000},
+		"changePassword": map[string]bool{"supported": false},
+		"sort":           map[string]bool{"supported": false},
+		"etag":           map[string]bool{"supported": false},
+		"authenticationSchemes": []map[string]string{{
+			"type": "oauthbearertoken", "name": "Bearer token", "description": "Static bearer token (NOPASS_SCIM_TOKEN)",
+		}},
+	})
+}
+
+// parseFilter supports the single-clause filters IdPs send to look up an
+// existing resource: `attr eq "value"`.
+func parseFilter(f string) (attr, value string, err error) {
+	if f == "" {
+		return "", "", nil
+	}
+	parts := strings.SplitN(strings.TrimSpace(f), " ", 3)
+	if len(parts) != 3 || !strings.EqualFold(parts[1], "eq") {
+		return "", "", fmt.Errorf("unsupported filter %q (only `attr eq \"value\"`)", f)
+	}
+	value, err = strconv.Unquote(parts[2])
+	if err != nil {
+		return "", "", fmt.Errorf("unsupported filter %q: value must be a quoted string", f)
+	}
+	return parts[0], value, nil
+}
+
+// page applies SCIM startIndex/count pagination.
+func page(r *http.Request, items []any) listResponse {
+	start, _ := strconv.Atoi(r.URL.Query().Get("startIndex"))
+	if start < 1 {
+		start = 1
+	}
+	count, err := strconv.Atoi(r.URL.Query().Get("count"))
+	if err != nil || count < 0 || count > 1000 {
+		count = 1000
+	}
+	total := len(items)
+	from := min(start-1, total)
+	to := min(from+count, total)
+	return listResponse{
+		Schemas:      []string{schemaListResponse},
+		TotalResults: total,
+		StartIndex:   start,
+		ItemsPerPage: to - from,
+		Resources:    append([]any{}, items[from:to]...),
+	}
+}
+
+func boolPtr(b bool) *bool { return &b }
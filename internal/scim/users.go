@@ -0,0 +1,184 @@
+package scim
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/shivansh-source/nopass/internal/directory"
+)
+
+func (s *Server) userResource(u *directory.User) userResource {
+	res := userResource{
+		Schemas:     []string{schemaUser, schemaUserExt},
+		ID:          u.ID,
+		ExternalID:  u.ExternalID,
+		UserName:    u.UserName,
+		DisplayName: u.DisplayName,
+		Active:      boolPtr(u.Active),
+		Ext:         &userExt{TenantID: u.TenantID, TrustLevel: string(u.TrustLevel), PolicyID: u.PolicyID},
+		Meta: &meta{
+			ResourceType: "User",
+			Created:      u.Created,
+			LastModified: u.Modified,
+			Location:     s.BaseURL + "/Users/" + u.ID,
+		},
+	}
+	if u.Email != "" {
+		res.Emails = []email{{Value: u.Email, Primary: true}}
+	}
+	return res
+}
+
+// toUser validates res and copies it onto u (keeping ID and Created).
+func toUser(res *userResource, u *directory.User) error {
+	if res.UserName == "" {
+		return errors.New("userName is required")
+	}
+	if res.Ext == nil || res.Ext.TenantID == "" {
+		return errors.New(schemaUserExt + ":tenantId is required")
+	}
+	trust, err := directory.ParseTrustLevel(res.Ext.TrustLevel)
+	if err != nil {
+		return err
+	}
+	u.ExternalID = res.ExternalID
+	u.UserName = res.UserName
+	u.DisplayName = res.DisplayName
+	u.TenantID = res.Ext.TenantID
+	u.TrustLevel = trust
+	u.PolicyID = res.Ext.PolicyID
+	u.Active = res.Active == nil || *res.Active
+	u.Email = ""
+	for _, e := range res.Emails {
+		if e.Primary || u.Email == "" {
+			u.Email = e.Value
+		}
+	}
+	u.Modified = time.Now().UTC()
+	return nil
+}
+
+func (s *Server) listUsers(w http.ResponseWriter, r *http.Request) {
+	attr, value, err := parseFilter(r.URL.Query().Get("filter"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	users, err := s.Directory.ListUsers(r.Context())
+	if err != nil {
+		s.storeError(w, err)
+		return
+	}
+	var items []any
+	for i := range users {
+		u := &users[i]
+		switch strings.ToLower(attr) {
+		case "":
+		case "username":
+			if !strings.EqualFold(u.UserName, value) {
+				continue
+			}
+		case "externalid":
+			if u.ExternalID != value {
+				continue
+			}
+		default:
+			writeError(w, http.StatusBadRequest, "unsupported filter attribute "+attr)
+			return
+		}
+		items = append(items, s.userResource(u))
+	}
+	writeJSON(w, http.StatusOK, page(r, items))
+}
+
+func (s *Server) createUser(w http.ResponseWriter, r *http.Request) {
+	var res userResource
+	if err := json.NewDecoder(r.Body).Decode(&res); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid JSON body")
+		return
+	}
+	now := time.Now().UTC()
+	u := &directory.User{ID: newID(), Created: now}
+	if err := toUser(&res, u); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if _, err := s.Directory.UserByName(r.Context(), u.TenantID, u.UserName); err == nil {
+		writeError(w, http.StatusConflict, "userName already exists")
+		return
+	}
+	if err := s.Directory.PutUser(r.Context(), u); err != nil {
+		s.storeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, s.userResource(u))
+}
+
+func (s *Server) getUser(w http.ResponseWriter, r *http.Request) {
+	u, err := s.Directory.GetUser(r.Context(), r.PathValue("id"))
+	if err != nil {
+		s.storeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, s.userResource(u))
+}
+
+func (s *Server) replaceUser(w http.ResponseWriter, r *http.Request) {
+	u, err := s.Directory.GetUser(r.Context(), r.PathValue("id"))
+	if err != nil {
+		s.storeError(w, err)
+		return
+	}
+	var res userResource
+	if err := json.NewDecoder(r.Body).Decode(&res); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid JSON body")
+		return
+	}
+	s.saveUser(w, r, &res, u)
+}
+
+func (s *Server) patchUser(w http.ResponseWriter, r *http.Request) {
+	u, err := s.Directory.GetUser(r.Context(), r.PathValue("id"))
+	if err != nil {
+		s.storeError(w, err)
+		return
+	}
+	var req patchRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid JSON body")
+		return
+	}
+	res := s.userResource(u)
+	if err := applyPatch(&res, req); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	s.saveUser(w, r, &res, u)
+}
+
+func (s *Server) saveUser(w http.ResponseWriter, r *http.Request, res *userResource, u *directory.User) {
+	if err := toUser(res, u); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err := s.Directory.PutUser(r.Context(), u); err != nil {
+		if strings.Contains(err.Error(), "already exists") {
+			writeError(w, http.StatusConflict, err.Error())
+			return
+		}
+		s.storeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, s.userResource(u))
+}
+
+func (s *Server) deleteUser(w http.ResponseWriter, r *http.Request) {
+	if err := s.Directory.DeleteUser(r.Context(), r.PathValue("id")); err != nil {
+		s.storeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,60 @@
+// Package sdnotify implements the client side of systemd's service
+// notification protocol (sd_notify(3)), for units with Type=notify and
+// WatchdogSec=. Outside systemd every call is a no-op.
+package sdnotify
+
+import (
+	"net"
+	"os"
+	"strconv"
+	"time"
+)
+
+// States sent by Notify.
+const (
+	Ready    = "READY=1"
+	Stopping = "STOPPING=1"
+	Watchdog = "WATCHDOG=1"
+)
+
+// Notify sends state, one or more newline-separated VAR=value
+// assignments, to the socket in NOTIFY_SOCKET. It reports false without
+// an error when there is no socket to notify.
+func Notify(state string) (bool, error) {
+	name := os.Getenv("NOTIFY_SOCKET")
+	if name == "" {
+		return false, nil
+	}
+	// A leading @ names a socket in the abstract namespace.
+	if name[0] == '@' {
+		name = "\x00" + name[1:]
+	}
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: name, Net: "unixgram"})
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close()
+	if _, err := conn.Write([]byte(state)); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// Status returns a STATUS= assignment, shown by systemctl status.
+func Status(msg string) string {
+	return "STATUS=" + msg
+}
+
+// WatchdogInterval returns how often systemd expects WATCHDOG=1 before
+// it considers the service hung, or 0 if the watchdog is off for this
+// process. Pings should be sent at half this interval.
+func WatchdogInterval() time.Duration {
+	usec, err := strconv.ParseInt(os.Getenv("WATCHDOG_USEC"), 10, 64)
+	if err != nil || usec <= 0 {
+		return 0
+	}
+	if pid := os.Getenv("WATCHDOG_PID"); pid != "" && pid != strconv.Itoa(os.Getpid()) {
+		return 0
+	}
+	return time.Duration(usec) * time.Microsecond
+}
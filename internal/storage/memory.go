@@ -0,0 +1,252 @@
+package storage
+
+import (
+	"github.com/shivansh-source/nopass/internal/types"
+
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// MemoryStore keeps everything in process. It is the reference
+// implementation and the default for single-gateway deployments; nothing
+// survives a restart.
+type MemoryStore struct {
+	mu         sync.RWMutex
+	sessions   map[[2]string]Session
+	audit      []AuditRecord
+	quarantine map[[2]string]QuarantineEntry
+	vault      map[VaultKey]vaultEntry
+	quotas     map[quotaKey]int64
+	records    map[[2]string][]byte
+}
+
+type vaultEntry struct {
+	sealed  []byte
+	expires time.Time
+}
+
+type quotaKey struct {
+	tenant, name string
+	window       time.Time
+}
+
+// NewMemoryStore creates an empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		sessions:   make(map[[2]string]Session),
+		quarantine: make(map[[2]string]QuarantineEntry),
+		vault:      make(map[VaultKey]vaultEntry),
+		quotas:     make(map[quotaKey]int64),
+		records:    make(map[[2]string][]byte),
+	}
+}
+
+func (m *MemoryStore) Sessions() SessionStore      { return m }
+func (m *MemoryStore) Audit() AuditStore           { return m }
+func (m *MemoryStore) Quarantine() QuarantineStore { return m }
+func (m *MemoryStore) Vault() VaultStore           { return m }
+func (m *MemoryStore) Quotas() QuotaStore          { return m }
+func (m *MemoryStore) Records() RecordStore        { return m }
+func (m *MemoryStore) Close() error                { return nil }
+
+// GetSession implements SessionStore.
+func (m *MemoryStore) GetSession(_ context.Context, tenantID, sessionID string) (*Session, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	s, ok := m.sessions[[2]string{tenantID, sessionID}]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	s.Turns = append([]types.Turn(nil), s.Turns...)
+	return &s, nil
+}
+
+// PutSession implements SessionStore.
+func (m *MemoryStore) PutSession(_ context.Context, s *Session) error {
+	cp := *s
+	cp.Turns = append([]types.Turn(nil), s.Turns...)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sessions[[2]string{s.TenantID, s.ID}] = cp
+	return nil
+}
+
+// DeleteSession implements SessionStore.
+func (m *MemoryStore) DeleteSession(_ context.Context, tenantID, sessionID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.sessions, [2]string{tenantID, sessionID})
+	return nil
+}
+
+// Append implements AuditStore.
+func (m *MemoryStore) Append(_ context.Context, r AuditRecord) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.audit = append(m.audit, r)
+	return nil
+}
+
+// Query implements AuditStore.
+func (m *MemoryStore) Query(_ context.Context, q AuditQuery) ([]AuditRecord, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var out []AuditRecord
+	for i := len(m.audit) - 1; i >= 0; i-- {
+		if q.matches(m.audit[i]) {
+			out = append(out, m.audit[i])
+			if q.Limit > 0 && len(out) == q.Limit {
+				break
+			}
+		}
+	}
+	return out, nil
+}
+
+// PruneAudit deletes audit records older than before.
+func (m *MemoryStore) PruneAudit(_ context.Context, before time.Time) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	kept := m.audit[:0]
+	for _, r := range m.audit {
+		if !r.Time.Before(before) {
+			kept = append(kept, r)
+		}
+	}
+	n := int64(len(m.audit) - len(kept))
+	m.audit = kept
+	return n, nil
+}
+
+// PutQuarantine implements QuarantineStore.
+func (m *MemoryStore) PutQuarantine(_ context.Context, e QuarantineEntry) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.quarantine[[2]string{e.TenantID, e.ID}] = e
+	return nil
+}
+
+// GetQuarantine implements QuarantineStore.
+func (m *MemoryStore) GetQuarantine(_ context.Context, tenantID, id string) (*QuarantineEntry, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	e, ok := m.quarantine[[2]string{tenantID, id}]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &e, nil
+}
+
+// ListQuarantine implements QuarantineStore.
+func (m *MemoryStore) ListQuarantine(_ context.Context, tenantID string) ([]QuarantineEntry, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var out []QuarantineEntry
+	for k, e := range m.quarantine {
+		if k[0] == tenantID {
+			out = append(out, e)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].CreatedAt.After(out[j].CreatedAt) })
+	return out, nil
+}
+
+// PutSealed implements VaultStore.
+func (m *MemoryStore) PutSealed(_ context.Context, k VaultKey, sealed []byte, expires time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.vault[k] = vaultEntry{sealed: append([]byte(nil), sealed...), expires: expires}
+	return nil
+}
+
+// GetSealed implements VaultStore.
+func (m *MemoryStore) GetSealed(_ context.Context, k VaultKey) ([]byte, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	e, ok := m.vault[k]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	if !e.expires.IsZero() && time.Now().After(e.expires) {
+		delete(m.vault, k)
+		return nil, ErrNotFound
+	}
+	return e.sealed, nil
+}
+
+// DeleteVaultSession implements VaultStore.
+func (m *MemoryStore) DeleteVaultSession(_ context.Context, tenantID, sessionID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for k := range m.vault {
+		if k.TenantID == tenantID && k.SessionID == sessionID {
+			delete(m.vault, k)
+		}
+	}
+	return nil
+}
+
+// Incr implements QuotaStore. Counters of past windows are dropped as new
+// windows start.
+func (m *MemoryStore) Incr(_ context.Context, tenantID, name string, n int64, window time.Duration) (int64, error) {
+	start := windowStart(time.Now(), window)
+	k := quotaKey{tenantID, name, start}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.quotas[k]; !ok {
+		for old := range m.quotas {
+			if old.tenant == tenantID && old.name == name && old.window.Before(start) {
+				delete(m.quotas, old)
+			}
+		}
+	}
+	m.quotas[k] += n
+	return m.quotas[k], nil
+}
+
+// PutRecord implements RecordStore.
+func (m *MemoryStore) PutRecord(_ context.Context, collection, id string, data []byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.records[[2]string{collection, id}] = append([]byte(nil), data...)
+	return nil
+}
+
+// GetRecord implements RecordStore.
+func (m *MemoryStore) GetRecord(_ context.Context, collection, id string) ([]byte, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	data, ok := m.records[[2]string{collection, id}]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return data, nil
+}
+
+// ListRecords implements RecordStore.
+func (m *MemoryStore) ListRecords(_ context.Context, collection string) ([][]byte, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var ids []string
+	for k := range m.records {
+		if k[0] == collection {
+			ids = append(ids, k[1])
+		}
+	}
+	sort.Strings(ids)
+	out := make([][]byte, len(ids))
+	for i, id := range ids {
+		out[i] = m.records[[2]string{collection, id}]
+	}
+	return out, nil
+}
+
+// DeleteRecord implements RecordStore.
+func (m *MemoryStore) DeleteRecord(_ context.Context, collection, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.records, [2]string{collection, id})
+	return nil
+}
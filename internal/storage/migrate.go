@@ -0,0 +1,256 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Schema migrations are embedded per dialect as
+// migrations/<dialect>/<version>_<name>.{up,down}.sql, golang-migrate
+// style. The applied version is tracked in nopass_schema_migrations.
+//
+//go:embed migrations
+var migrationFS embed.FS
+
+// ErrSchemaMismatch is returned at startup when the database schema is not
+// the version this build expects.
+var ErrSchemaMismatch = errors.New("storage: schema version mismatch")
+
+type migration struct {
+	version  int
+	name     string
+	up, down string
+}
+
+func (s *SQLStore) dialect() string {
+	if s.postgres {
+		return "postgres"
+	}
+	return "sqlite"
+}
+
+// migrations returns the embedded migrations for the store's dialect,
+// ordered by version.
+func (s *SQLStore) migrations() ([]migration, error) {
+	dir := path.Join("migrations", s.dialect())
+	entries, err := fs.ReadDir(migrationFS, dir)
+	if err != nil {
+		return nil, err
+	}
+	byVersion := make(map[int]*migration)
+	for _, e := range entries {
+		name := e.Name()
+		base, direction, ok := strings.Cut(strings.TrimSuffix(name, ".sql"), ".")
+		if !ok || (direction != "up" && direction != "down") {
+			return nil, fmt.Errorf("storage: bad migration file name %q", name)
+		}
+		vs, label, _ := strings.Cut(base, "_")
+		v, err := strconv.Atoi(vs)
+		if err != nil || v <= 0 {
+			return nil, fmt.Errorf("storage: bad migration version in %q", name)
+		}
+		body, err := fs.ReadFile(migrationFS, path.Join(dir, name))
+		if err != nil {
+			return nil, err
+		}
+		m := byVersion[v]
+		if m == nil {
+			m = &migration{version: v, name: label}
+			byVersion[v] = m
+		}
+		if direction == "up" {
+			m.up = string(body)
+		} else {
+			m.down = string(body)
+		}
+	}
+	out := make([]migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		out = append(out, *m)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].version < out[j].version })
+	for i, m := range out {
+		if m.version != i+1 {
+			return nil, fmt.Errorf("storage: migration %d missing", i+1)
+		}
+	}
+	return out, nil
+}
+
+// LatestSchemaVersion is the schema version this build expects.
+func (s *SQLStore) LatestSchemaVersion() (int, error) {
+	ms, err := s.migrations()
+	if err != nil {
+		return 0, err
+	}
+	return len(ms), nil
+}
+
+func (s *SQLStore) ensureVersionTable(ctx context.Context) error {
+	_, err := s.db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS nopass_schema_migrations (
+		version BIGINT NOT NULL, dirty BOOLEAN NOT NULL)`)
+	if err != nil {
+		return fmt.Errorf("storage: create version table: %w", err)
+	}
+	return nil
+}
+
+// SchemaVersion returns the applied schema version (0 for an empty
+// database) and whether a migration failed halfway.
+func (s *SQLStore) SchemaVersion(ctx context.Context) (version int, dirty bool, err error) {
+	if err := s.ensureVersionTable(ctx); err != nil {
+		return 0, false, err
+	}
+	err = s.db.QueryRowContext(ctx, `SELECT version, dirty FROM nopass_schema_migrations`).Scan(&version, &dirty)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, fmt.Errorf("storage: read schema version: %w", err)
+	}
+	return version, dirty, nil
+}
+
+func (s *SQLStore) setVersion(ctx context.Context, tx *sql.Tx, version int, dirty bool) error {
+	if _, err := tx.ExecContext(ctx, `DELETE FROM nopass_schema_migrations`); err != nil {
+		return err
+	}
+	_, err := tx.ExecContext(ctx, s.q(`INSERT INTO nopass_schema_migrations (version, dirty) VALUES (?, ?)`), version, dirty)
+	return err
+}
+
+// Migrate moves the schema to target (-1 = latest), applying up or down
+// migrations one transaction at a time. A migration that fails leaves the
+// version marked dirty; fix the database by hand and use Force.
+func (s *SQLStore) Migrate(ctx context.Context, target int) error {
+	ms, err := s.migrations()
+	if err != nil {
+		return err
+	}
+	if target < 0 {
+		target = len(ms)
+	}
+	if target > len(ms) {
+		return fmt.Errorf("storage: target version %d is newer than this build's latest (%d)", target, len(ms))
+	}
+	current, dirty, err := s.SchemaVersion(ctx)
+	if err != nil {
+		return err
+	}
+	if dirty {
+		return fmt.Errorf("storage: schema version %d is dirty (a migration failed); repair it and force the version", current)
+	}
+	if current > len(ms) {
+		return fmt.Errorf("%w: database is at version %d, newer than this build's latest (%d)", ErrSchemaMismatch, current, len(ms))
+	}
+
+	for current != target {
+		var m migration
+		var script string
+		next := current + 1
+		if target < current {
+			m, script, next = ms[current-1], ms[current-1].down, current-1
+		} else {
+			m = ms[current]
+			script = m.up
+		}
+		if err := s.apply(ctx, script, next); err != nil {
+			return fmt.Errorf("storage: migration %d_%s: %w", m.version, m.name, err)
+		}
+		current = next
+	}
+	return nil
+}
+
+// apply runs one migration script and records the resulting version.
+func (s *SQLStore) apply(ctx context.Context, script string, version int) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	for _, stmt := range splitStatements(script) {
+		if _, err := tx.ExecContext(ctx, stmt); err != nil {
+			tx.Rollback()
+			// Record the failure outside the rolled-back transaction.
+			if vtx, verr := s.db.BeginTx(ctx, nil); verr == nil {
+				if s.setVersion(ctx, vtx, version, true) == nil {
+					vtx.Commit()
+				} else {
+					vtx.Rollback()
+				}
+			}
+			return err
+		}
+	}
+	if err := s.setVersion(ctx, tx, version, false); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// Force records version as applied and clean without running anything.
+func (s *SQLStore) Force(ctx context.Context, version int) error {
+	if err := s.ensureVersionTable(ctx); err != nil {
+		return err
+	}
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if err := s.setVersion(ctx, tx, version, false); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// CheckSchema refuses to run against a schema other than the one this
+// build expects.
+func (s *SQLStore) CheckSchema(ctx context.Context) error {
+	latest, err := s.LatestSchemaVersion()
+	if err != nil {
+		return err
+	}
+	current, dirty, err := s.SchemaVersion(ctx)
+	if err != nil {
+		return err
+	}
+	switch {
+	case dirty:
+		return fmt.Errorf("%w: version %d is dirty", ErrSchemaMismatch, current)
+	case current < latest:
+		return fmt.Errorf("%w: database is at version %d, this build requires %d; run `nopass migrate`", ErrSchemaMismatch, current, latest)
+	case current > latest:
+		return fmt.Errorf("%w: database is at version %d, newer than this build (%d); upgrade the gateway", ErrSchemaMismatch, current, latest)
+	}
+	return nil
+}
+
+// splitStatements splits a migration script on semicolons that end a line.
+func splitStatements(script string) []string {
+	var out []string
+	var cur strings.Builder
+	for _, line := range strings.Split(script, "\n") {
+		cur.WriteString(line)
+		cur.WriteString("\n")
+		if strings.HasSuffix(strings.TrimSpace(line), ";") {
+			if stmt := strings.TrimSpace(cur.String()); stmt != ";" {
+				out = append(out, stmt)
+			}
+			cur.Reset()
+		}
+	}
+	if stmt := strings.TrimSpace(cur.String()); stmt != "" {
+		out = append(out, stmt)
+	}
+	return out
+}
@@ -0,0 +1,6 @@
+DROP TABLE nopass_quotas;
+DROP TABLE nopass_vault;
+DROP TABLE nopass_quarantine;
+DROP INDEX nopass_audit_tenant_ts;
+DROP TABLE nopass_audit;
+DROP TABLE nopass_sessions;
@@ -0,0 +1,43 @@
+CREATE TABLE nopass_sessions (
+	tenant_id TEXT NOT NULL,
+	id TEXT NOT NULL,
+	data TEXT NOT NULL,
+	updated_at BIGINT NOT NULL,
+	PRIMARY KEY (tenant_id, id)
+);
+
+CREATE TABLE nopass_audit (
+	id TEXT PRIMARY KEY,
+	tenant_id TEXT NOT NULL,
+	ts BIGINT NOT NULL,
+	kind TEXT NOT NULL,
+	actor TEXT NOT NULL,
+	data BYTEA
+);
+
+CREATE INDEX nopass_audit_tenant_ts ON nopass_audit (tenant_id, ts);
+
+CREATE TABLE nopass_quarantine (
+	tenant_id TEXT NOT NULL,
+	id TEXT NOT NULL,
+	data TEXT NOT NULL,
+	created_at BIGINT NOT NULL,
+	PRIMARY KEY (tenant_id, id)
+);
+
+CREATE TABLE nopass_vault (
+	tenant_id TEXT NOT NULL,
+	session_id TEXT NOT NULL,
+	token TEXT NOT NULL,
+	sealed BYTEA NOT NULL,
+	expires_at BIGINT NOT NULL,
+	PRIMARY KEY (tenant_id, session_id, token)
+);
+
+CREATE TABLE nopass_quotas (
+	tenant_id TEXT NOT NULL,
+	name TEXT NOT NULL,
+	window_start BIGINT NOT NULL,
+	value BIGINT NOT NULL,
+	PRIMARY KEY (tenant_id, name, window_start)
+);
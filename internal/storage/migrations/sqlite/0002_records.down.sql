@@ -0,0 +1 @@
+DROP TABLE nopass_records;
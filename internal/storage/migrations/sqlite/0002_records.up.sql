@@ -0,0 +1,7 @@
+CREATE TABLE nopass_records (
+	collection TEXT NOT NULL,
+	id TEXT NOT NULL,
+	data TEXT NOT NULL,
+	updated_at BIGINT NOT NULL,
+	PRIMARY KEY (collection, id)
+);
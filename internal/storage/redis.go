@@ -0,0 +1,336 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxAuditList caps each Redis audit list; older records are trimmed.
+const maxAuditList = 1_000_000
+
+// RedisStore implements Store on Redis. Values are JSON strings; tenant and
+// session IDs are escaped into the key names.
+type RedisStore struct {
+	c *redisClient
+}
+
+// OpenRedis connects to redis://[:password@]host:port/db.
+func OpenRedis(ctx context.Context, rawURL string) (*RedisStore, error) {
+	c, err := newRedisClient(rawURL, 8)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := c.Do(ctx, "PING"); err != nil {
+		return nil, fmt.Errorf("storage: connect redis: %w", err)
+	}
+	return &RedisStore{c: c}, nil
+}
+
+func key(parts ...string) string {
+	for i, p := range parts {
+		parts[i] = url.PathEscape(p)
+	}
+	return "nopass:" + strings.Join(parts, ":")
+}
+
+func (s *RedisStore) Sessions() SessionStore      { return s }
+func (s *RedisStore) Audit() AuditStore           { return s }
+func (s *RedisStore) Quarantine() QuarantineStore { return s }
+func (s *RedisStore) Vault() VaultStore           { return s }
+func (s *RedisStore) Quotas() QuotaStore          { return s }
+func (s *RedisStore) Records() RecordStore        { return s }
+func (s *RedisStore) Close() error                { return s.c.Close() }
+
+func (s *RedisStore) getJSON(ctx context.Context, k string, v any) error {
+	raw, err := s.c.Do(ctx, "GET", k)
+	if errors.Is(err, errNil) {
+		return ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal([]byte(raw.(string)), v)
+}
+
+// GetSession implements SessionStore.
+func (s *RedisStore) GetSession(ctx context.Context, tenantID, sessionID string) (*Session, error) {
+	var sess Session
+	if err := s.getJSON(ctx, key("session", tenantID, sessionID), &sess); err != nil {
+		return nil, err
+	}
+	return &sess, nil
+}
+
+// PutSession implements SessionStore.
+func (s *RedisStore) PutSession(ctx context.Context, sess *Session) error {
+	data, err := json.Marshal(sess)
+	if err != nil {
+		return fmt.Errorf("storage: encode session: %w", err)
+	}
+	_, err = s.c.Do(ctx, "SET", key("session", sess.TenantID, sess.ID), string(data))
+	return err
+}
+
+// DeleteSession implements SessionStore.
+func (s *RedisStore) DeleteSession(ctx context.Context, tenantID, sessionID string) error {
+	_, err := s.c.Do(ctx, "DEL", key("session", tenantID, sessionID))
+	return err
+}
+
+// Append implements AuditStore. Records go to a per-tenant list and a
+// global one, newest first.
+func (s *RedisStore) Append(ctx context.Context, r AuditRecord) error {
+	data, err := json.Marshal(r)
+	if err != nil {
+		return fmt.Errorf("storage: encode audit record: %w", err)
+	}
+	for _, k := range []string{key("audit", r.TenantID), key("audit")} {
+		if _, err := s.c.Do(ctx, "LPUSH", k, string(data)); err != nil {
+			return err
+		}
+		if _, err := s.c.Do(ctx, "LTRIM", k, "0", strconv.Itoa(maxAuditList-1)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Query implements AuditStore by scanning the relevant list.
+func (s *RedisStore) Query(ctx context.Context, q AuditQuery) ([]AuditRecord, error) {
+	k := key("audit")
+	if q.TenantID != "" {
+		k = key("audit", q.TenantID)
+	}
+	raw, err := s.c.Do(ctx, "LRANGE", k, "0", "-1")
+	if err != nil {
+		return nil, err
+	}
+	var out []AuditRecord
+	for _, item := range raw.([]any) {
+		str, _ := item.(string)
+		var r AuditRecord
+		if err := json.Unmarshal([]byte(str), &r); err != nil {
+			return nil, fmt.Errorf("storage: decode audit record: %w", err)
+		}
+		if q.matches(r) {
+			out = append(out, r)
+			if q.Limit > 0 && len(out) == q.Limit {
+				break
+			}
+		}
+	}
+	return out, nil
+}
+
+// PutQuarantine implements QuarantineStore.
+func (s *RedisStore) PutQuarantine(ctx context.Context, e QuarantineEntry) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("storage: encode quarantine entry: %w", err)
+	}
+	_, err = s.c.Do(ctx, "HSET", key("quarantine", e.TenantID), e.ID, string(data))
+	return err
+}
+
+// GetQuarantine implements QuarantineStore.
+func (s *RedisStore) GetQuarantine(ctx context.Context, tenantID, id string) (*QuarantineEntry, error) {
+	raw, err := s.c.Do(ctx, "HGET", key("quarantine", tenantID), id)
+	if errors.Is(err, errNil) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	var e QuarantineEntry
+	if err := json.Unmarshal([]byte(raw.(string)), &e); err != nil {
+		return nil, fmt.Errorf("storage: decode quarantine entry: %w", err)
+	}
+	return &e, nil
+}
+
+// ListQuarantine implements QuarantineStore.
+func (s *RedisStore) ListQuarantine(ctx context.Context, tenantID string) ([]QuarantineEntry, error) {
+	raw, err := s.c.Do(ctx, "HVALS", key("quarantine", tenantID))
+	if err != nil {
+		return nil, err
+	}
+	var out []QuarantineEntry
+	for _, item := range raw.([]any) {
+		str, _ := item.(string)
+		var e QuarantineEntry
+		if err := json.Unmarshal([]byte(str), &e); err != nil {
+			return nil, fmt.Errorf("storage: decode quarantine entry: %w", err)
+		}
+		out = append(out, e)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].CreatedAt.After(out[j].CreatedAt) })
+	return out, nil
+}
+
+// PutSealed implements VaultStore; expiry is enforced by Redis itself.
+func (s *RedisStore) PutSealed(ctx context.Context, k VaultKey, sealed []byte, expires time.Time) error {
+	args := []string{"SET", key("vault", k.TenantID, k.SessionID, k.Token), string(sealed)}
+	if !expires.IsZero() {
+		ms := time.Until(expires).Milliseconds()
+		if ms <= 0 {
+			return nil
+		}
+		args = append(args, "PX", strconv.FormatInt(ms, 10))
+	}
+	if _, err := s.c.Do(ctx, args...); err != nil {
+		return err
+	}
+	_, err := s.c.Do(ctx, "SADD", key("vault-session", k.TenantID, k.SessionID), k.Token)
+	return err
+}
+
+// GetSealed implements VaultStore.
+func (s *RedisStore) GetSealed(ctx context.Context, k VaultKey) ([]byte, error) {
+	raw, err := s.c.Do(ctx, "GET", key("vault", k.TenantID, k.SessionID, k.Token))
+	if errors.Is(err, errNil) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return []byte(raw.(string)), nil
+}
+
+// DeleteVaultSession implements VaultStore.
+func (s *RedisStore) DeleteVaultSession(ctx context.Context, tenantID, sessionID string) error {
+	setKey := key("vault-session", tenantID, sessionID)
+	raw, err := s.c.Do(ctx, "SMEMBERS", setKey)
+	if err != nil {
+		return err
+	}
+	args := []string{"DEL", setKey}
+	for _, item := range raw.([]any) {
+		token, _ := item.(string)
+		args = append(args, key("vault", tenantID, sessionID, token))
+	}
+	_, err = s.c.Do(ctx, args...)
+	return err
+}
+
+// Incr implements QuotaStore. Each window's counter expires on its own.
+func (s *RedisStore) Incr(ctx context.Context, tenantID, name string, n int64, window time.Duration) (int64, error) {
+	start := windowStart(time.Now(), window)
+	k := key("quota", tenantID, name, strconv.FormatInt(start.UnixMilli(), 10))
+	raw, err := s.c.Do(ctx, "INCRBY", k, strconv.FormatInt(n, 10))
+	if err != nil {
+		return 0, err
+	}
+	if _, err := s.c.Do(ctx, "PEXPIRE", k, strconv.FormatInt((2*window).Milliseconds(), 10)); err != nil {
+		return 0, err
+	}
+	return raw.(int64), nil
+}
+
+// takeScript refills and takes from a token bucket in one step. Times are
+// the server's, in milliseconds; rate is tokens per millisecond.
+const takeScript = `
+local t = redis.call('TIME')
+local now = t[1] * 1000 + math.floor(t[2] / 1000)
+local rate, burst = tonumber(ARGV[1]), tonumber(ARGV[2])
+local b = redis.call('HMGET', KEYS[1], 'tokens', 'ts')
+local tokens, ts = tonumber(b[1]), tonumber(b[2])
+if tokens == nil then tokens, ts = burst, now end
+tokens = math.min(burst, tokens + math.max(0, now - ts) * rate)
+local ok, wait = 0, 0
+if tokens >= 1 then
+  tokens, ok = tokens - 1, 1
+else
+  wait = math.ceil((1 - tokens) / rate)
+end
+redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'ts', now)
+redis.call('PEXPIRE', KEYS[1], math.ceil(burst / rate) + 1000)
+return {ok, wait}`
+
+// Take implements BucketStore.
+func (s *RedisStore) Take(ctx context.Context, name string, rate float64, burst int) (bool, time.Duration, error) {
+	raw, err := s.c.Do(ctx, "EVAL", takeScript, "1", key("bucket", name),
+		strconv.FormatFloat(rate/1000, 'g', -1, 64), strconv.Itoa(burst))
+	if err != nil {
+		return false, 0, err
+	}
+	res, ok := raw.([]any)
+	if !ok || len(res) != 2 {
+		return false, 0, fmt.Errorf("storage: unexpected bucket reply %v", raw)
+	}
+	allowed, _ := res[0].(int64)
+	wait, _ := res[1].(int64)
+	return allowed == 1, time.Duration(wait) * time.Millisecond, nil
+}
+
+// GetCached implements CacheStore.
+func (s *RedisStore) GetCached(ctx context.Context, name string) ([]byte, error) {
+	raw, err := s.c.Do(ctx, "GET", key("cache", name))
+	if errors.Is(err, errNil) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return []byte(raw.(string)), nil
+}
+
+// PutCached implements CacheStore; expiry is enforced by Redis itself.
+func (s *RedisStore) PutCached(ctx context.Context, name string, data []byte, ttl time.Duration) error {
+	_, err := s.c.Do(ctx, "SET", key("cache", name), string(data), "PX", strconv.FormatInt(ttl.Milliseconds(), 10))
+	return err
+}
+
+// PutRecord implements RecordStore; each collection is one hash.
+func (s *RedisStore) PutRecord(ctx context.Context, collection, id string, data []byte) error {
+	_, err := s.c.Do(ctx, "HSET", key("records", collection), id, string(data))
+	return err
+}
+
+// GetRecord implements RecordStore.
+func (s *RedisStore) GetRecord(ctx context.Context, collection, id string) ([]byte, error) {
+	raw, err := s.c.Do(ctx, "HGET", key("records", collection), id)
+	if errors.Is(err, errNil) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return []byte(raw.(string)), nil
+}
+
+// ListRecords implements RecordStore.
+func (s *RedisStore) ListRecords(ctx context.Context, collection string) ([][]byte, error) {
+	raw, err := s.c.Do(ctx, "HGETALL", key("records", collection))
+	if err != nil {
+		return nil, err
+	}
+	items := raw.([]any)
+	var ids []string
+	byID := make(map[string]string)
+	for i := 0; i+1 < len(items); i += 2 {
+		id, _ := items[i].(string)
+		data, _ := items[i+1].(string)
+		ids = append(ids, id)
+		byID[id] = data
+	}
+	slices.Sort(ids)
+	out := make([][]byte, len(ids))
+	for i, id := range ids {
+		out[i] = []byte(byID[id])
+	}
+	return out, nil
+}
+
+// DeleteRecord implements RecordStore.
+func (s *RedisStore) DeleteRecord(ctx context.Context, collection, id string) error {
+	_, err := s.c.Do(ctx, "HDEL", key("records", collection), id)
+	return err
+}
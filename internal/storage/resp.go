@@ -0,0 +1,186 @@
+package storage
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// redisClient is a minimal RESP2 client: enough for the handful of
+// commands RedisStore uses, with a small connection pool.
+type redisClient struct {
+	addr     string
+	password string
+	db       int
+	pool     chan *redisConn
+}
+
+type redisConn struct {
+	c net.Conn
+	r *bufio.Reader
+}
+
+// redisError is an error reply from the server.
+type redisError string
+
+func (e redisError) Error() string { return "redis: " + string(e) }
+
+// errNil is the RESP null bulk string / null array.
+var errNil = errors.New("redis: nil")
+
+func newRedisClient(rawURL string, poolSize int) (*redisClient, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "redis" && u.Scheme != "") {
+		return nil, fmt.Errorf("storage: invalid redis URL %q", rawURL)
+	}
+	c := &redisClient{addr: u.Host, pool: make(chan *redisConn, poolSize)}
+	if c.addr == "" {
+		c.addr = "localhost:6379"
+	}
+	if p, ok := u.User.Password(); ok {
+		c.password = p
+	}
+	if db := strings.TrimPrefix(u.Path, "/"); db != "" {
+		if c.db, err = strconv.Atoi(db); err != nil {
+			return nil, fmt.Errorf("storage: invalid redis database %q", db)
+		}
+	}
+	return c, nil
+}
+
+func (c *redisClient) get(ctx context.Context) (*redisConn, error) {
+	select {
+	case conn := <-c.pool:
+		return conn, nil
+	default:
+	}
+	var d net.Dialer
+	nc, err := d.DialContext(ctx, "tcp", c.addr)
+	if err != nil {
+		return nil, fmt.Errorf("storage: dial redis: %w", err)
+	}
+	conn := &redisConn{c: nc, r: bufio.NewReader(nc)}
+	if c.password != "" {
+		if _, err := conn.do(ctx, "AUTH", c.password); err != nil {
+			nc.Close()
+			return nil, err
+		}
+	}
+	if c.db != 0 {
+		if _, err := conn.do(ctx, "SELECT", strconv.Itoa(c.db)); err != nil {
+			nc.Close()
+			return nil, err
+		}
+	}
+	return conn, nil
+}
+
+func (c *redisClient) put(conn *redisConn) {
+	select {
+	case c.pool <- conn:
+	default:
+		conn.c.Close()
+	}
+}
+
+// Do runs one command. Connections that saw an I/O error are discarded.
+func (c *redisClient) Do(ctx context.Context, args ...string) (any, error) {
+	conn, err := c.get(ctx)
+	if err != nil {
+		return nil, err
+	}
+	v, err := conn.do(ctx, args...)
+	var rerr redisError
+	if err == nil || errors.Is(err, errNil) || errors.As(err, &rerr) {
+		c.put(conn)
+	} else {
+		conn.c.Close()
+	}
+	return v, err
+}
+
+func (c *redisClient) Close() error {
+	for {
+		select {
+		case conn := <-c.pool:
+			conn.c.Close()
+		default:
+			return nil
+		}
+	}
+}
+
+func (conn *redisConn) do(ctx context.Context, args ...string) (any, error) {
+	if dl, ok := ctx.Deadline(); ok {
+		conn.c.SetDeadline(dl)
+	} else {
+		conn.c.SetDeadline(time.Now().Add(5 * time.Second))
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "*%d\r\n", len(args))
+	for _, a := range args {
+		fmt.Fprintf(&b, "$%d\r\n%s\r\n", len(a), a)
+	}
+	if _, err := io.WriteString(conn.c, b.String()); err != nil {
+		return nil, fmt.Errorf("storage: redis write: %w", err)
+	}
+	return conn.read()
+}
+
+func (conn *redisConn) read() (any, error) {
+	line, err := conn.r.ReadString('\n')
+	if err != nil {
+		return nil, fmt.Errorf("storage: redis read: %w", err)
+	}
+	line = strings.TrimSuffix(line, "\r\n")
+	if line == "" {
+		return nil, errors.New("storage: redis: empty reply")
+	}
+	switch line[0] {
+	case '+':
+		return line[1:], nil
+	case '-':
+		return nil, redisError(line[1:])
+	case ':':
+		return strconv.ParseInt(line[1:], 10, 64)
+	case '$':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, fmt.Errorf("storage: redis: bad bulk length %q", line)
+		}
+		if n < 0 {
+			return nil, errNil
+		}
+		buf := make([]byte, n+2)
+		if _, err := io.ReadFull(conn.r, buf); err != nil {
+			return nil, fmt.Errorf("storage: redis read: %w", err)
+		}
+		return string(buf[:n]), nil
+	case '*':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, fmt.Errorf("storage: redis: bad array length %q", line)
+		}
+		if n < 0 {
+			return nil, errNil
+		}
+		out := make([]any, n)
+		for i := range out {
+			v, err := conn.read()
+			if err != nil && !errors.Is(err, errNil) {
+				return nil, err
+			}
+			out[i] = v
+		}
+		return out, nil
+	default:
+		return nil, fmt.Errorf("storage: redis: unexpected reply %q", line)
+	}
+}
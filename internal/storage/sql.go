@@ -0,0 +1,354 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SQLStore implements Store on SQLite or Postgres through database/sql.
+// Neither driver is linked by default; build the binary with the one you
+// need (e.g. modernc.org/sqlite registers "sqlite", jackc/pgx/v5/stdlib
+// registers "pgx").
+type SQLStore struct {
+	db       *sql.DB
+	postgres bool
+}
+
+// OpenSQL opens cfg.DSN. With cfg.AutoMigrate the schema is brought up
+// to date; otherwise a schema that isn't exactly the version this build
+// expects is refused (see `nopass migrate`).
+func OpenSQL(ctx context.Context, cfg Config) (*SQLStore, error) {
+	s, err := OpenSQLUnchecked(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.AutoMigrate {
+		err = s.Migrate(ctx, -1)
+	} else {
+		err = s.CheckSchema(ctx)
+	}
+	if err != nil {
+		s.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// OpenSQLUnchecked opens cfg.DSN without looking at the schema, for the
+// migration tool.
+func OpenSQLUnchecked(ctx context.Context, cfg Config) (*SQLStore, error) {
+	driver := cfg.Driver
+	if driver == "" {
+		driver = "sqlite"
+		if cfg.Backend == "postgres" {
+			driver = "pgx"
+		}
+	}
+	db, err := sql.Open(driver, cfg.DSN)
+	if err != nil {
+		return nil, fmt.Errorf("storage: open %s: %w", cfg.Backend, err)
+	}
+	if err := db.PingContext(ctx); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("storage: connect %s: %w", cfg.Backend, err)
+	}
+	return &SQLStore{db: db, postgres: cfg.Backend == "postgres"}, nil
+}
+
+// q rewrites ? placeholders to $n for Postgres.
+func (s *SQLStore) q(query string) string {
+	if !s.postgres {
+		return query
+	}
+	var b strings.Builder
+	n := 0
+	for _, r := range query {
+		if r == '?' {
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+func (s *SQLStore) Sessions() SessionStore      { return s }
+func (s *SQLStore) Audit() AuditStore           { return s }
+func (s *SQLStore) Quarantine() QuarantineStore { return s }
+func (s *SQLStore) Vault() VaultStore           { return s }
+func (s *SQLStore) Quotas() QuotaStore          { return s }
+func (s *SQLStore) Records() RecordStore        { return s }
+func (s *SQLStore) Close() error                { return s.db.Close() }
+
+// GetSession implements SessionStore.
+func (s *SQLStore) GetSession(ctx context.Context, tenantID, sessionID string) (*Session, error) {
+	var data string
+	err := s.db.QueryRowContext(ctx, s.q(`SELECT data FROM nopass_sessions WHERE tenant_id = ? AND id = ?`),
+		tenantID, sessionID).Scan(&data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("storage: get session: %w", err)
+	}
+	var sess Session
+	if err := json.Unmarshal([]byte(data), &sess); err != nil {
+		return nil, fmt.Errorf("storage: decode session: %w", err)
+	}
+	return &sess, nil
+}
+
+// PutSession implements SessionStore.
+func (s *SQLStore) PutSession(ctx context.Context, sess *Session) error {
+	data, err := json.Marshal(sess)
+	if err != nil {
+		return fmt.Errorf("storage: encode session: %w", err)
+	}
+	_, err = s.db.ExecContext(ctx, s.q(`INSERT INTO nopass_sessions (tenant_id, id, data, updated_at) VALUES (?, ?, ?, ?)
+		ON CONFLICT (tenant_id, id) DO UPDATE SET data = excluded.data, updated_at = excluded.updated_at`),
+		sess.TenantID, sess.ID, string(data), sess.UpdatedAt.UnixMilli())
+	if err != nil {
+		return fmt.Errorf("storage: put session: %w", err)
+	}
+	return nil
+}
+
+// DeleteSession implements SessionStore.
+func (s *SQLStore) DeleteSession(ctx context.Context, tenantID, sessionID string) error {
+	_, err := s.db.ExecContext(ctx, s.q(`DELETE FROM nopass_sessions WHERE tenant_id = ? AND id = ?`), tenantID, sessionID)
+	if err != nil {
+		return fmt.Errorf("storage: delete session: %w", err)
+	}
+	return nil
+}
+
+// Append implements AuditStore.
+func (s *SQLStore) Append(ctx context.Context, r AuditRecord) error {
+	_, err := s.db.ExecContext(ctx, s.q(`INSERT INTO nopass_audit (id, tenant_id, ts, kind, actor, data) VALUES (?, ?, ?, ?, ?, ?)`),
+		r.ID, r.TenantID, r.Time.UnixMilli(), r.Kind, r.Actor, r.Data)
+	if err != nil {
+		return fmt.Errorf("storage: append audit: %w", err)
+	}
+	return nil
+}
+
+// Query implements AuditStore.
+func (s *SQLStore) Query(ctx context.Context, q AuditQuery) ([]AuditRecord, error) {
+	var where []string
+	var args []any
+	if q.TenantID != "" {
+		where, args = append(where, "tenant_id = ?"), append(args, q.TenantID)
+	}
+	if q.Kind != "" {
+		where, args = append(where, "kind = ?"), append(args, q.Kind)
+	}
+	if !q.Since.IsZero() {
+		where, args = append(where, "ts >= ?"), append(args, q.Since.UnixMilli())
+	}
+	if !q.Until.IsZero() {
+		where, args = append(where, "ts < ?"), append(args, q.Until.UnixMilli())
+	}
+	query := `SELECT id, tenant_id, ts, kind, actor, data FROM nopass_audit`
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+	query += " ORDER BY ts DESC"
+	if q.Limit > 0 {
+		query += " LIMIT " + strconv.Itoa(q.Limit)
+	}
+
+	rows, err := s.db.QueryContext(ctx, s.q(query), args...)
+	if err != nil {
+		return nil, fmt.Errorf("storage: query audit: %w", err)
+	}
+	defer rows.Close()
+	var out []AuditRecord
+	for rows.Next() {
+		var r AuditRecord
+		var ts int64
+		if err := rows.Scan(&r.ID, &r.TenantID, &ts, &r.Kind, &r.Actor, &r.Data); err != nil {
+			return nil, fmt.Errorf("storage: scan audit: %w", err)
+		}
+		r.Time = time.UnixMilli(ts).UTC()
+		out = append(out, r)
+	}
+	return out, rows.Err()
+}
+
+// PruneAudit deletes audit records older than before.
+func (s *SQLStore) PruneAudit(ctx context.Context, before time.Time) (int64, error) {
+	res, err := s.db.ExecContext(ctx, s.q(`DELETE FROM nopass_audit WHERE ts < ?`), before.UnixMilli())
+	if err != nil {
+		return 0, fmt.Errorf("storage: prune audit: %w", err)
+	}
+	return res.RowsAffected()
+}
+
+// PutQuarantine implements QuarantineStore.
+func (s *SQLStore) PutQuarantine(ctx context.Context, e QuarantineEntry) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("storage: encode quarantine entry: %w", err)
+	}
+	_, err = s.db.ExecContext(ctx, s.q(`INSERT INTO nopass_quarantine (tenant_id, id, data, created_at) VALUES (?, ?, ?, ?)
+		ON CONFLICT (tenant_id, id) DO UPDATE SET data = excluded.data`),
+		e.TenantID, e.ID, string(data), e.CreatedAt.UnixMilli())
+	if err != nil {
+		return fmt.Errorf("storage: put quarantine entry: %w", err)
+	}
+	return nil
+}
+
+// GetQuarantine implements QuarantineStore.
+func (s *SQLStore) GetQuarantine(ctx context.Context, tenantID, id string) (*QuarantineEntry, error) {
+	var data string
+	err := s.db.QueryRowContext(ctx, s.q(`SELECT data FROM nopass_quarantine WHERE tenant_id = ? AND id = ?`),
+		tenantID, id).Scan(&data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("storage: get quarantine entry: %w", err)
+	}
+	var e QuarantineEntry
+	if err := json.Unmarshal([]byte(data), &e); err != nil {
+		return nil, fmt.Errorf("storage: decode quarantine entry: %w", err)
+	}
+	return &e, nil
+}
+
+// ListQuarantine implements QuarantineStore.
+func (s *SQLStore) ListQuarantine(ctx context.Context, tenantID string) ([]QuarantineEntry, error) {
+	rows, err := s.db.QueryContext(ctx, s.q(`SELECT data FROM nopass_quarantine WHERE tenant_id = ? ORDER BY created_at DESC`), tenantID)
+	if err != nil {
+		return nil, fmt.Errorf("storage: list quarantine: %w", err)
+	}
+	defer rows.Close()
+	var out []QuarantineEntry
+	for rows.Next() {
+		var data string
+		if err := rows.Scan(&data); err != nil {
+			return nil, fmt.Errorf("storage: scan quarantine entry: %w", err)
+		}
+		var e QuarantineEntry
+		if err := json.Unmarshal([]byte(data), &e); err != nil {
+			return nil, fmt.Errorf("storage: decode quarantine entry: %w", err)
+		}
+		out = append(out, e)
+	}
+	return out, rows.Err()
+}
+
+// PutSealed implements VaultStore. A zero expiry is stored as 0 (never).
+func (s *SQLStore) PutSealed(ctx context.Context, k VaultKey, sealed []byte, expires time.Time) error {
+	var exp int64
+	if !expires.IsZero() {
+		exp = expires.UnixMilli()
+	}
+	_, err := s.db.ExecContext(ctx, s.q(`INSERT INTO nopass_vault (tenant_id, session_id, token, sealed, expires_at) VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT (tenant_id, session_id, token) DO UPDATE SET sealed = excluded.sealed, expires_at = excluded.expires_at`),
+		k.TenantID, k.SessionID, k.Token, sealed, exp)
+	if err != nil {
+		return fmt.Errorf("storage: put vault entry: %w", err)
+	}
+	return nil
+}
+
+// GetSealed implements VaultStore.
+func (s *SQLStore) GetSealed(ctx context.Context, k VaultKey) ([]byte, error) {
+	var sealed []byte
+	var exp int64
+	err := s.db.QueryRowContext(ctx, s.q(`SELECT sealed, expires_at FROM nopass_vault WHERE tenant_id = ? AND session_id = ? AND token = ?`),
+		k.TenantID, k.SessionID, k.Token).Scan(&sealed, &exp)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("storage: get vault entry: %w", err)
+	}
+	if exp != 0 && time.Now().UnixMilli() > exp {
+		return nil, ErrNotFound
+	}
+	return sealed, nil
+}
+
+// DeleteVaultSession implements VaultStore.
+func (s *SQLStore) DeleteVaultSession(ctx context.Context, tenantID, sessionID string) error {
+	_, err := s.db.ExecContext(ctx, s.q(`DELETE FROM nopass_vault WHERE tenant_id = ? AND session_id = ?`), tenantID, sessionID)
+	if err != nil {
+		return fmt.Errorf("storage: delete vault session: %w", err)
+	}
+	return nil
+}
+
+// Incr implements QuotaStore atomically with an upsert.
+func (s *SQLStore) Incr(ctx context.Context, tenantID, name string, n int64, window time.Duration) (int64, error) {
+	start := windowStart(time.Now(), window).UnixMilli()
+	var total int64
+	err := s.db.QueryRowContext(ctx, s.q(`INSERT INTO nopass_quotas (tenant_id, name, window_start, value) VALUES (?, ?, ?, ?)
+		ON CONFLICT (tenant_id, name, window_start) DO UPDATE SET value = nopass_quotas.value + excluded.value
+		RETURNING value`), tenantID, name, start, n).Scan(&total)
+	if err != nil {
+		return 0, fmt.Errorf("storage: increment quota: %w", err)
+	}
+	return total, nil
+}
+
+// PutRecord implements RecordStore.
+func (s *SQLStore) PutRecord(ctx context.Context, collection, id string, data []byte) error {
+	_, err := s.db.ExecContext(ctx, s.q(`INSERT INTO nopass_records (collection, id, data, updated_at) VALUES (?, ?, ?, ?)
+		ON CONFLICT (collection, id) DO UPDATE SET data = excluded.data, updated_at = excluded.updated_at`),
+		collection, id, string(data), time.Now().UnixMilli())
+	if err != nil {
+		return fmt.Errorf("storage: put record: %w", err)
+	}
+	return nil
+}
+
+// GetRecord implements RecordStore.
+func (s *SQLStore) GetRecord(ctx context.Context, collection, id string) ([]byte, error) {
+	var data string
+	err := s.db.QueryRowContext(ctx, s.q(`SELECT data FROM nopass_records WHERE collection = ? AND id = ?`),
+		collection, id).Scan(&data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("storage: get record: %w", err)
+	}
+	return []byte(data), nil
+}
+
+// ListRecords implements RecordStore.
+func (s *SQLStore) ListRecords(ctx context.Context, collection string) ([][]byte, error) {
+	rows, err := s.db.QueryContext(ctx, s.q(`SELECT data FROM nopass_records WHERE collection = ? ORDER BY id`), collection)
+	if err != nil {
+		return nil, fmt.Errorf("storage: list records: %w", err)
+	}
+	defer rows.Close()
+	var out [][]byte
+	for rows.Next() {
+		var data string
+		if err := rows.Scan(&data); err != nil {
+			return nil, fmt.Errorf("storage: scan record: %w", err)
+		}
+		out = append(out, []byte(data))
+	}
+	return out, rows.Err()
+}
+
+// DeleteRecord implements RecordStore.
+func (s *SQLStore) DeleteRecord(ctx context.Context, collection, id string) error {
+	_, err := s.db.ExecContext(ctx, s.q(`DELETE FROM nopass_records WHERE collection = ? AND id = ?`), collection, id)
+	if err != nil {
+		return fmt.Errorf("storage: delete record: %w", err)
+	}
+	return nil
+}
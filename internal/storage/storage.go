@@ -0,0 +1,197 @@
+// Package storage is the one persistence abstraction shared by every
+// feature that keeps state beyond a request: sessions, audit records,
+// quarantined content, token-vault entries and quota counters. Backends
+// (in-memory, SQLite, Postgres, Redis) are chosen by configuration; callers
+// only see the interfaces below.
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/shivansh-source/nopass/internal/types"
+)
+
+// ErrNotFound is returned when a record does not exist (or has expired).
+var ErrNotFound = errors.New("storage: not found")
+
+// ErrNotOwner is returned for a session another user owns.
+var ErrNotOwner = errors.New("storage: session belongs to another user")
+
+// Store groups the per-feature stores of one backend.
+type Store interface {
+	Sessions() SessionStore
+	Audit() AuditStore
+	Quarantine() QuarantineStore
+	Vault() VaultStore
+	Quotas() QuotaStore
+	Records() RecordStore
+	Close() error
+}
+
+// Session is the server-side state of a conversation.
+type Session struct {
+	TenantID string `json:"tenant_id"`
+	ID       string `json:"id"`
+	// UserID is the user who started the session; no other user may read
+	// or change it. Sessions stored before owners were kept have none and
+	// are claimed by the next user to write them.
+	UserID string       `json:"user_id,omitempty"`
+	Turns  []types.Turn `json:"turns,omitempty"`
+	// Summary and TurnsCovered are the compacted memory block.
+	Summary      string `json:"summary,omitempty"`
+	TurnsCovered int    `json:"turns_covered,omitempty"`
+	// Risk is the cumulative risk ledger of the session.
+	Risk      *types.SessionRisk `json:"risk,omitempty"`
+	UpdatedAt time.Time          `json:"updated_at"`
+}
+
+// OwnedBy reports whether userID may use the session.
+func (s *Session) OwnedBy(userID string) bool {
+	return s.UserID == "" || s.UserID == userID
+}
+
+// SessionStore persists sessions.
+type SessionStore interface {
+	GetSession(ctx context.Context, tenantID, sessionID string) (*Session, error)
+	PutSession(ctx context.Context, s *Session) error
+	DeleteSession(ctx context.Context, tenantID, sessionID string) error
+}
+
+// AuditRecord is one append-only audit entry. Data is the kind-specific
+// payload, already redacted by the caller.
+type AuditRecord struct {
+	ID       string    `json:"id"`
+	TenantID string    `json:"tenant_id"`
+	Time     time.Time `json:"time"`
+	Kind     string    `json:"kind"`
+	Actor    string    `json:"actor,omitempty"`
+	Data     []byte    `json:"data,omitempty"`
+}
+
+// AuditQuery filters audit records; zero fields match everything.
+type AuditQuery struct {
+	TenantID string
+	Kind     string
+	Since    time.Time
+	Until    time.Time
+	Limit    int
+}
+
+func (q AuditQuery) matches(r AuditRecord) bool {
+	return (q.TenantID == "" || r.TenantID == q.TenantID) &&
+		(q.Kind == "" || r.Kind == q.Kind) &&
+		(q.Since.IsZero() || !r.Time.Before(q.Since)) &&
+		(q.Until.IsZero() || r.Time.Before(q.Until))
+}
+
+// AuditStore appends and queries audit records.
+type AuditStore interface {
+	Append(ctx context.Context, r AuditRecord) error
+	// Query returns matching records, newest first.
+	Query(ctx context.Context, q AuditQuery) ([]AuditRecord, error)
+}
+
+// QuarantineEntry records content held back from the model.
+type QuarantineEntry struct {
+	ID          string    `json:"id"`
+	TenantID    string    `json:"tenant_id"`
+	ContentHash string    `json:"content_hash"`
+	Source      string    `json:"source,omitempty"`
+	Reason      string    `json:"reason"`
+	Flags       []string  `json:"flags,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// QuarantineStore persists quarantine entries.
+type QuarantineStore interface {
+	PutQuarantine(ctx context.Context, e QuarantineEntry) error
+	GetQuarantine(ctx context.Context, tenantID, id string) (*QuarantineEntry, error)
+	ListQuarantine(ctx context.Context, tenantID string) ([]QuarantineEntry, error)
+}
+
+// VaultKey addresses one token-vault entry.
+type VaultKey struct {
+	TenantID, SessionID, Token string
+}
+
+// VaultStore keeps sealed (already encrypted) token-vault entries. It never
+// sees plaintext.
+type VaultStore interface {
+	PutSealed(ctx context.Context, k VaultKey, sealed []byte, expires time.Time) error
+	GetSealed(ctx context.Context, k VaultKey) ([]byte, error)
+	DeleteVaultSession(ctx context.Context, tenantID, sessionID string) error
+}
+
+// QuotaStore keeps fixed-window usage counters.
+type QuotaStore interface {
+	// Incr adds n to the counter for (tenantID, name) in the window
+	// containing now and returns the new total.
+	Incr(ctx context.Context, tenantID, name string, n int64, window time.Duration) (int64, error)
+}
+
+// BucketStore keeps token buckets shared by every gateway instance. Only
+// backends that can update a bucket atomically and cheaply implement it
+// (Redis); callers fall back to per-instance buckets otherwise.
+type BucketStore interface {
+	// Take removes a token from the bucket named key, which refills at
+	// rate tokens per second up to burst. If the bucket is empty it
+	// returns false and how long until the next token.
+	Take(ctx context.Context, key string, rate float64, burst int) (bool, time.Duration, error)
+}
+
+// CacheStore keeps values that expire on their own, shared by every
+// gateway instance. Only backends with native expiry implement it (Redis);
+// callers keep per-instance caches otherwise.
+type CacheStore interface {
+	// GetCached returns ErrNotFound for a missing or expired value.
+	GetCached(ctx context.Context, key string) ([]byte, error)
+	PutCached(ctx context.Context, key string, data []byte, ttl time.Duration) error
+}
+
+// RecordStore keeps small JSON documents by collection and ID, for
+// features whose data is just a handful of records per tenant (directory
+// entries, settings).
+type RecordStore interface {
+	PutRecord(ctx context.Context, collection, id string, data []byte) error
+	GetRecord(ctx context.Context, collection, id string) ([]byte, error)
+	// ListRecords returns every record of the collection, ordered by ID.
+	ListRecords(ctx context.Context, collection string) ([][]byte, error)
+	DeleteRecord(ctx context.Context, collection, id string) error
+}
+
+// windowStart aligns t to the start of its quota window.
+func windowStart(t time.Time, window time.Duration) time.Time {
+	return t.Truncate(window)
+}
+
+// Config selects and configures a backend.
+type Config struct {
+	// Backend is "memory" (default), "sqlite", "postgres" or "redis".
+	Backend string `json:"backend"`
+	// DSN is the database DSN or redis://[:password@]host:port/db URL.
+	DSN string `json:"dsn,omitempty"`
+	// Driver overrides the database/sql driver name ("sqlite" for SQLite,
+	// "pgx" for Postgres by default). The driver must be linked into the
+	// binary.
+	Driver string `json:"driver,omitempty"`
+	// AutoMigrate applies pending schema migrations at startup instead of
+	// refusing to start.
+	AutoMigrate bool `json:"auto_migrate,omitempty"`
+}
+
+// Open connects to the configured backend.
+func Open(ctx context.Context, cfg Config) (Store, error) {
+	switch cfg.Backend {
+	case "", "memory":
+		return NewMemoryStore(), nil
+	case "sqlite", "postgres":
+		return OpenSQL(ctx, cfg)
+	case "redis":
+		return OpenRedis(ctx, cfg.DSN)
+	default:
+		return nil, fmt.Errorf("storage: unknown backend %q", cfg.Backend)
+	}
+}
@@ -0,0 +1,116 @@
+// Package taxonomy maps the gateway's risk flags and detection events
+// onto a small, fixed vocabulary of attack techniques, so the security
+// team can report trends without knowing every flag each scorer and
+// reviewer emits. A request can show several techniques; flags that
+// describe no attack (deadline skips, degraded stages, tone filters) map
+// to none.
+package taxonomy
+
+import (
+	"slices"
+	"strings"
+)
+
+// Technique is an attack technique.
+type Technique string
+
+// Techniques.
+const (
+	// DirectJailbreak asks the model outright to drop its rules: ignore
+	// your instructions, act as DAN, bypass your safety filters.
+	DirectJailbreak Technique = "direct_jailbreak"
+	// RoleInjection forges conversation structure: chat-template role
+	// markers, "you are now…", closing the prompt's data tags.
+	RoleInjection Technique = "role_injection"
+	// IndirectInjection hides instructions in external data: documents,
+	// web pages, retrieval results.
+	IndirectInjection Technique = "indirect_injection"
+	// PromptExtraction tries to get the system prompt out.
+	PromptExtraction Technique = "prompt_extraction"
+	// DataExfiltration fishes for credentials or personal data, in the
+	// prompt or caught in the answer.
+	DataExfiltration Technique = "data_exfiltration"
+	// Obfuscation disguises the attack from pattern matching: encodings,
+	// homoglyphs, invisible or bidi characters, Unicode tag smuggling.
+	Obfuscation Technique = "obfuscation"
+	// MultiTurnEscalation builds up risk over a conversation.
+	MultiTurnEscalation Technique = "multi_turn_escalation"
+	// HarmfulContent asks for content that is harmful in itself: self
+	// harm, weapons, crime.
+	HarmfulContent Technique = "harmful_content"
+)
+
+// All lists the techniques in reporting order.
+var All = []Technique{
+	DirectJailbreak, RoleInjection, IndirectInjection, PromptExtraction,
+	DataExfiltration, Obfuscation, MultiTurnEscalation, HarmfulContent,
+}
+
+// EventDangerousData is the detection event of an external data block
+// the scan found dangerous; it has no risk flag of its own.
+const EventDangerousData = "dangerous_external_data"
+
+// flags maps exact flags to their technique; every "encoded_*" flag is
+// Obfuscation too.
+var flags = map[string]Technique{
+	"regex_ignore_previous_instructions": DirectJailbreak,
+	"regex_bypass_safety":                DirectJailbreak,
+	"jailbreak_phrase":                   DirectJailbreak,
+	"embedding_jailbreak_similar":        DirectJailbreak,
+
+	"regex_role_override": RoleInjection,
+	"regex_role_markers":  RoleInjection,
+	"data_tag_breakout":   RoleInjection,
+
+	EventDangerousData:  IndirectInjection,
+	"dangerous_context": IndirectInjection,
+
+	"regex_reveal_system_prompt": PromptExtraction,
+	"system_prompt_leak":         PromptExtraction,
+
+	"regex_password_exfil": DataExfiltration,
+	"regex_secret_key":     DataExfiltration,
+	"secret_redacted":      DataExfiltration,
+	"pii_remasked":         DataExfiltration,
+
+	"invisible_unicode":     Obfuscation,
+	"bidi_override":         Obfuscation,
+	"unicode_tag_smuggling": Obfuscation,
+	"homoglyphs_folded":     Obfuscation,
+
+	"session_escalated": MultiTurnEscalation,
+
+	"self_harm_content":      HarmfulContent,
+	"violence_or_explosives": HarmfulContent,
+	"illegal_instructions":   HarmfulContent,
+}
+
+// Of returns the technique a flag is evidence of. Flags the gateway adds
+// a reason to ("policy_refusal:<flag>") are looked up by that flag.
+func Of(flag string) (Technique, bool) {
+	flag = strings.TrimPrefix(flag, "policy_refusal:")
+	if t, ok := flags[flag]; ok {
+		return t, true
+	}
+	if strings.HasPrefix(flag, "encoded_") {
+		return Obfuscation, true
+	}
+	return "", false
+}
+
+// Classify returns the techniques the flags are evidence of, each once,
+// in the order of All.
+func Classify(sets ...[]string) []Technique {
+	var out []Technique
+	for _, fs := range sets {
+		for _, f := range fs {
+			if t, ok := Of(f); ok && !slices.Contains(out, t) {
+				out = append(out, t)
+			}
+		}
+	}
+	slices.SortFunc(out, func(a, b Technique) int {
+		return slices.Index(All, a) - slices.Index(All, b)
+	})
+	return out
+}
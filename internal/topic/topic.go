@@ -0,0 +1,295 @@
+// Package topic keeps tenants' conversations on their domain: a banking
+// assistant must not give medical advice, whatever the user asks. Each
+// tenant describes what it is for and which topics are denied; prompts and
+// answers are checked with embeddings, when an embedding endpoint is
+// configured, and with per-topic keywords.
+package topic
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/shivansh-source/nopass/internal/metrics"
+)
+
+// Embedder turns text into a vector; retrieval.HTTPEmbedder is one.
+type Embedder interface {
+	Embed(ctx context.Context, text string) ([]float32, error)
+}
+
+// Denied is a topic a tenant must not discuss.
+type Denied struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description,omitempty"` // compared by embedding
+	Keywords    []string `json:"keywords,omitempty"`    // matched case-insensitively on word boundaries
+}
+
+// Policy is one tenant's topic restriction.
+type Policy struct {
+	// Allowed describes what the assistant is for; Examples are typical
+	// on-topic requests.
+	Allowed  string   `json:"allowed"`
+	Examples []string `json:"examples,omitempty"`
+	Denied   []Denied `json:"denied,omitempty"`
+	// MinSimilarity, if set, also refuses prompts whose embedding is less
+	// similar than this to every Allowed text. Answers are only checked
+	// against Denied, so small talk in an answer is never refused.
+	MinSimilarity float64 `json:"min_similarity,omitempty"`
+	// DenySimilarity is how similar to a denied topic's description text
+	// must be, and more similar than to Allowed, to be refused (0 = 0.5).
+	DenySimilarity float64 `json:"deny_similarity,omitempty"`
+	// Action is "refuse" (the default) or "redirect", which answers with
+	// Redirect instead.
+	Action   string `json:"action,omitempty"`
+	Redirect string `json:"redirect,omitempty"`
+}
+
+// File is the topics file.
+type File struct {
+	EmbeddingURL    string            `json:"embedding_url,omitempty"`
+	EmbeddingModel  string            `json:"embedding_model,omitempty"`
+	EmbeddingKeyEnv string            `json:"embedding_key_env,omitempty"`
+	Tenants         map[string]Policy `json:"tenants"`
+}
+
+// LoadFile reads and validates a topics JSON file.
+func LoadFile(path string) (*File, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read topics file: %w", err)
+	}
+	var f File
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("parse topics file: %w", err)
+	}
+	for tenant, p := range f.Tenants {
+		if err := p.validate(); err != nil {
+			return nil, fmt.Errorf("tenant %s: %w", tenant, err)
+		}
+	}
+	return &f, nil
+}
+
+func (p Policy) validate() error {
+	if p.Allowed == "" {
+		return fmt.Errorf("allowed is required")
+	}
+	switch p.Action {
+	case "", "refuse":
+	case "redirect":
+		if p.Redirect == "" {
+			return fmt.Errorf("redirect action needs redirect text")
+		}
+	default:
+		return fmt.Errorf("unknown action %q", p.Action)
+	}
+	for _, d := range p.Denied {
+		if d.Name == "" || (d.Description == "" && len(d.Keywords) == 0) {
+			return fmt.Errorf("denied topic %q needs a name and a description or keywords", d.Name)
+		}
+	}
+	return nil
+}
+
+// OffDomain is the topic reported for a prompt too far from Allowed.
+const OffDomain = "off_domain"
+
+// DefaultRefusal answers an off-topic request under the "refuse" action.
+const DefaultRefusal = "I can’t help with that topic here. Please ask something related to what this assistant is for."
+
+// Verdict is the outcome of one check.
+type Verdict struct {
+	OffTopic bool
+	Topic    string // the denied topic, or OffDomain
+	Reply    string // what to answer instead
+}
+
+type tenant struct {
+	Policy
+	keywords []*regexp.Regexp // per Denied entry; nil without keywords
+}
+
+// Guard checks prompts and answers against tenant policies. Tenants
+// without a policy are unrestricted; a nil Guard restricts nothing.
+type Guard struct {
+	tenants  map[string]*tenant
+	embedder Embedder
+
+	mu      sync.Mutex
+	vectors map[string][]float32 // embeddings of policy texts
+}
+
+var offTopic = metrics.NewCounterVec(
+	"nopass_topic_violations_total",
+	"Prompts and answers refused or redirected as off-topic.",
+	"check", "topic",
+)
+
+// New builds a Guard. embedder may be nil: only keywords are checked then.
+func New(policies map[string]Policy, embedder Embedder) *Guard {
+	g := &Guard{tenants: make(map[string]*tenant), embedder: embedder, vectors: make(map[string][]float32)}
+	for id, p := range policies {
+		t := &tenant{Policy: p, keywords: make([]*regexp.Regexp, len(p.Denied))}
+		for i, d := range p.Denied {
+			if len(d.Keywords) == 0 {
+				continue
+			}
+			quoted := make([]string, len(d.Keywords))
+			for j, k := range d.Keywords {
+				quoted[j] = regexp.QuoteMeta(k)
+			}
+			t.keywords[i] = regexp.MustCompile(`(?i)\b(?:` + strings.Join(quoted, "|") + `)\b`)
+		}
+		g.tenants[id] = t
+	}
+	return g
+}
+
+// Warm embeds every tenant's policy texts ahead of the first checks, which
+// would otherwise pay for them. Without an embedder it does nothing.
+func (g *Guard) Warm(ctx context.Context) error {
+	if g == nil || g.embedder == nil {
+		return nil
+	}
+	for _, t := range g.tenants {
+		texts := append([]string{t.Allowed}, t.Examples...)
+		for _, d := range t.Denied {
+			if d.Description != "" {
+				texts = append(texts, d.Description)
+			}
+		}
+		for _, s := range texts {
+			if _, err := g.vector(ctx, s); err != nil {
+				return fmt.Errorf("embed policy text: %w", err)
+			}
+		}
+	}
+	return nil
+}
+
+// Enabled reports whether tenantID has a topic policy.
+func (g *Guard) Enabled(tenantID string) bool {
+	return g != nil && g.tenants[tenantID] != nil
+}
+
+// CheckPrompt checks a user prompt against denied topics and, if the
+// policy sets MinSimilarity, against the tenant's domain.
+func (g *Guard) CheckPrompt(ctx context.Context, tenantID, text string) Verdict {
+	return g.check(ctx, "prompt", tenantID, text, true)
+}
+
+// CheckAnswer checks a final answer against denied topics.
+func (g *Guard) CheckAnswer(ctx context.Context, tenantID, text string) Verdict {
+	return g.check(ctx, "answer", tenantID, text, false)
+}
+
+func (g *Guard) check(ctx context.Context, check, tenantID, text string, domain bool) Verdict {
+	if !g.Enabled(tenantID) {
+		return Verdict{}
+	}
+	t := g.tenants[tenantID]
+	name, off := g.classify(ctx, t, text, domain)
+	if !off {
+		return Verdict{}
+	}
+	offTopic.Inc(check, name)
+	v := Verdict{OffTopic: true, Topic: name, Reply: DefaultRefusal}
+	if t.Action == "redirect" {
+		v.Reply = t.Redirect
+	}
+	return v
+}
+
+func (g *Guard) classify(ctx context.Context, t *tenant, text string, domain bool) (string, bool) {
+	for i, re := range t.keywords {
+		if re != nil && re.MatchString(text) {
+			return t.Denied[i].Name, true
+		}
+	}
+	if g.embedder == nil {
+		return "", false
+	}
+
+	// Embedding failures leave the keyword verdict standing.
+	vec, err := g.embedder.Embed(ctx, text)
+	if err != nil {
+		log.Printf("topic check: embed text: %v", err)
+		return "", false
+	}
+	allowed := 0.0
+	for _, s := range append([]string{t.Allowed}, t.Examples...) {
+		ref, err := g.vector(ctx, s)
+		if err != nil {
+			log.Printf("topic check: embed policy text: %v", err)
+			return "", false
+		}
+		allowed = math.Max(allowed, cosine(vec, ref))
+	}
+	for _, d := range t.Denied {
+		if d.Description == "" {
+			continue
+		}
+		ref, err := g.vector(ctx, d.Description)
+		if err != nil {
+			log.Printf("topic check: embed policy text: %v", err)
+			return "", false
+		}
+		if s := cosine(vec, ref); s > allowed && s >= t.denyAt() {
+			return d.Name, true
+		}
+	}
+	if domain && t.MinSimilarity > 0 && allowed < t.MinSimilarity {
+		return OffDomain, true
+	}
+	return "", false
+}
+
+func (t *tenant) denyAt() float64 {
+	if t.DenySimilarity > 0 {
+		return t.DenySimilarity
+	}
+	return defaultDenySimilarity
+}
+
+const defaultDenySimilarity = 0.5
+
+// vector returns the cached embedding of a policy text.
+func (g *Guard) vector(ctx context.Context, text string) ([]float32, error) {
+	g.mu.Lock()
+	v, ok := g.vectors[text]
+	g.mu.Unlock()
+	if ok {
+		return v, nil
+	}
+	v, err := g.embedder.Embed(ctx, text)
+	if err != nil {
+		return nil, err
+	}
+	g.mu.Lock()
+	g.vectors[text] = v
+	g.mu.Unlock()
+	return v, nil
+}
+
+func cosine(a, b []float32) float64 {
+	if len(a) != len(b) || len(a) == 0 {
+		return 0
+	}
+	var dot, na, nb float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		na += float64(a[i]) * float64(a[i])
+		nb += float64(b[i]) * float64(b[i])
+	}
+	if na == 0 || nb == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(na) * math.Sqrt(nb))
+}
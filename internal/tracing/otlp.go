@@ -0,0 +1,209 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/shivansh-source/nopass/internal/metrics"
+)
+
+// Exporter batches finished spans and POSTs them to an OTLP/HTTP
+// collector's /v1/traces as JSON.
+type Exporter struct {
+	// Endpoint is the collector's base URL, e.g. http://otel-collector:4318.
+	Endpoint    string
+	ServiceName string
+	HTTPClient  *http.Client
+
+	queue chan *Span
+}
+
+var exportedSpans = metrics.NewCounterVec(
+	"nopass_trace_spans_total",
+	"Trace spans by export result.",
+	"result",
+)
+
+// queueSize is how many spans may wait for the collector.
+const queueSize = 4096
+
+// NewExporter creates an Exporter; Run must be started for spans to leave
+// the queue.
+func NewExporter(endpoint, serviceName string) *Exporter {
+	return &Exporter{
+		Endpoint:    strings.TrimSuffix(endpoint, "/"),
+		ServiceName: serviceName,
+		HTTPClient:  &http.Client{Timeout: 10 * time.Second},
+		queue:       make(chan *Span, queueSize),
+	}
+}
+
+func (e *Exporter) enqueue(s *Span) {
+	select {
+	case e.queue <- s:
+	default:
+		exportedSpans.Inc("dropped")
+	}
+}
+
+// Run exports queued spans in batches of up to 512, at least every five
+// seconds, until ctx is done, then flushes what is left.
+func (e *Exporter) Run(ctx context.Context) {
+	ticker := time.NewTicker(5 * time.Second)
+	defer ticker.Stop()
+
+	var batch []*Span
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		wctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 10*time.Second)
+		defer cancel()
+		if err := e.export(wctx, batch); err != nil {
+			log.Printf("trace export error (%d spans): %v", len(batch), err)
+			exportedSpans.Add(uint64(len(batch)), "failed")
+		} else {
+			exportedSpans.Add(uint64(len(batch)), "exported")
+		}
+		batch = nil
+	}
+	for {
+		select {
+		case s := <-e.queue:
+			if batch = append(batch, s); len(batch) >= 512 {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		case <-ctx.Done():
+			for {
+				select {
+				case s := <-e.queue:
+					batch = append(batch, s)
+				default:
+					flush()
+					return
+				}
+			}
+		}
+	}
+}
+
+func (e *Exporter) export(ctx context.Context, spans []*Span) error {
+	data, err := json.Marshal(e.request(spans))
+	if err != nil {
+		return fmt.Errorf("encode spans: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.Endpoint+"/v1/traces", bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("create trace request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := e.HTTPClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("deliver spans: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("collector returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// OTLP JSON encoding of ExportTraceServiceRequest. IDs are hex, times are
+// decimal strings of Unix nanoseconds.
+type (
+	otlpRequest struct {
+		ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+	}
+	otlpResourceSpans struct {
+		Resource   otlpResource     `json:"resource"`
+		ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+	}
+	otlpResource struct {
+		Attributes []otlpAttr `json:"attributes"`
+	}
+	otlpScopeSpans struct {
+		Scope otlpScope  `json:"scope"`
+		Spans []otlpSpan `json:"spans"`
+	}
+	otlpScope struct {
+		Name string `json:"name"`
+	}
+	otlpSpan struct {
+		TraceID           string     `json:"traceId"`
+		SpanID            string     `json:"spanId"`
+		ParentSpanID      string     `json:"parentSpanId,omitempty"`
+		Name              string     `json:"name"`
+		Kind              Kind       `json:"kind"`
+		StartTimeUnixNano string     `json:"startTimeUnixNano"`
+		EndTimeUnixNano   string     `json:"endTimeUnixNano"`
+		Attributes        []otlpAttr `json:"attributes,omitempty"`
+		Status            otlpStatus `json:"status"`
+	}
+	otlpStatus struct {
+		Code    int    `json:"code,omitempty"` // 2 is error
+		Message string `json:"message,omitempty"`
+	}
+	otlpAttr struct {
+		Key   string         `json:"key"`
+		Value map[string]any `json:"value"`
+	}
+)
+
+func (e *Exporter) request(spans []*Span) otlpRequest {
+	out := make([]otlpSpan, len(spans))
+	for i, s := range spans {
+		o := otlpSpan{
+			TraceID:           hex.EncodeToString(s.TraceID[:]),
+			SpanID:            hex.EncodeToString(s.SpanID[:]),
+			Name:              s.Name,
+			Kind:              s.Kind,
+			StartTimeUnixNano: strconv.FormatInt(s.StartTime.UnixNano(), 10),
+			EndTimeUnixNano:   strconv.FormatInt(s.EndTime.UnixNano(), 10),
+			Attributes:        attrs(s.Attrs),
+		}
+		if s.Parent != [8]byte{} {
+			o.ParentSpanID = hex.EncodeToString(s.Parent[:])
+		}
+		if s.Err != "" {
+			o.Status = otlpStatus{Code: 2, Message: s.Err}
+		}
+		out[i] = o
+	}
+	return otlpRequest{ResourceSpans: []otlpResourceSpans{{
+		Resource:   otlpResource{Attributes: attrs(map[string]any{"service.name": e.ServiceName})},
+		ScopeSpans: []otlpScopeSpans{{Scope: otlpScope{Name: "github.com/shivansh-source/nopass"}, Spans: out}},
+	}}}
+}
+
+func attrs(m map[string]any) []otlpAttr {
+	out := make([]otlpAttr, 0, len(m))
+	for k, v := range m {
+		var value map[string]any
+		switch v := v.(type) {
+		case bool:
+			value = map[string]any{"boolValue": v}
+		case int:
+			value = map[string]any{"intValue": strconv.Itoa(v)}
+		case int64:
+			value = map[string]any{"intValue": strconv.FormatInt(v, 10)}
+		case float64:
+			value = map[string]any{"doubleValue": v}
+		default:
+			value = map[string]any{"stringValue": fmt.Sprint(v)}
+		}
+		out = append(out, otlpAttr{Key: k, Value: value})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Key < out[j].Key })
+	return out
+}
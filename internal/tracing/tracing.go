@@ -0,0 +1,183 @@
+// Package tracing records OpenTelemetry spans for the safety pipeline and
+// exports them over OTLP/HTTP (JSON encoding) to a collector. Trace
+// context travels between services in the W3C traceparent header, so the
+// risk and output safety services' spans join the gateway's traces.
+//
+// Tracing is off until Enable is called: Start then returns a nil span,
+// and every Span method is a no-op on nil.
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// Kind is a span's OTLP kind.
+type Kind int
+
+const (
+	Internal Kind = 1
+	Server   Kind = 2
+	Client   Kind = 3
+)
+
+// SpanContext identifies a span within its trace.
+type SpanContext struct {
+	TraceID [16]byte
+	SpanID  [8]byte
+	Sampled bool
+}
+
+// Valid reports whether sc has a trace and span ID.
+func (sc SpanContext) Valid() bool {
+	return sc.TraceID != [16]byte{} && sc.SpanID != [8]byte{}
+}
+
+// Span is one timed operation.
+type Span struct {
+	SpanContext
+	Parent    [8]byte
+	Name      string
+	Kind      Kind
+	StartTime time.Time
+	EndTime   time.Time
+	Attrs     map[string]any
+	Err       string
+
+	tracer *Tracer
+	ended  atomic.Bool
+}
+
+// SetAttr sets a string, bool, integer or float attribute.
+func (s *Span) SetAttr(key string, value any) {
+	if s == nil || !s.Sampled {
+		return
+	}
+	if s.Attrs == nil {
+		s.Attrs = make(map[string]any)
+	}
+	s.Attrs[key] = value
+}
+
+// End finishes the span, marking it failed if err is not nil, and queues
+// it for export. Only the first call counts.
+func (s *Span) End(err error) {
+	if s == nil || !s.ended.CompareAndSwap(false, true) {
+		return
+	}
+	s.EndTime = time.Now()
+	if err != nil {
+		s.Err = err.Error()
+	}
+	if s.Sampled {
+		s.tracer.exporter.enqueue(s)
+	}
+}
+
+// Tracer creates spans and hands finished ones to its exporter.
+type Tracer struct {
+	// SampleRatio is the share of new traces recorded; a trace started
+	// elsewhere keeps its caller's decision.
+	SampleRatio float64
+
+	exporter *Exporter
+}
+
+// NewTracer creates a Tracer exporting through e.
+func NewTracer(e *Exporter, sampleRatio float64) *Tracer {
+	return &Tracer{SampleRatio: sampleRatio, exporter: e}
+}
+
+var current atomic.Pointer[Tracer]
+
+// Enable makes t the process-wide tracer.
+func Enable(t *Tracer) { current.Store(t) }
+
+type spanKey struct{}
+type remoteKey struct{}
+
+// Start begins a span as a child of the span in ctx, or of the remote
+// parent Extract attached, and returns a context carrying it. Without a
+// tracer it returns ctx and a nil span.
+func Start(ctx context.Context, name string, kind Kind) (context.Context, *Span) {
+	t := current.Load()
+	if t == nil {
+		return ctx, nil
+	}
+	s := &Span{Name: name, Kind: kind, StartTime: time.Now(), tracer: t}
+	parent, ok := ctx.Value(spanKey{}).(*Span)
+	switch {
+	case ok:
+		s.TraceID, s.Parent, s.Sampled = parent.TraceID, parent.SpanID, parent.Sampled
+	default:
+		if remote, ok := ctx.Value(remoteKey{}).(SpanContext); ok {
+			s.TraceID, s.Parent, s.Sampled = remote.TraceID, remote.SpanID, remote.Sampled
+		} else {
+			rand.Read(s.TraceID[:])
+			// The low 8 bytes of a random trace ID are uniform, so the
+			// decision is consistent for a trace wherever it is made.
+			s.Sampled = float64(binary.BigEndian.Uint64(s.TraceID[8:])>>11)/(1<<53) < t.SampleRatio
+		}
+	}
+	rand.Read(s.SpanID[:])
+	return context.WithValue(ctx, spanKey{}, s), s
+}
+
+// FromContext returns the span in ctx, or nil.
+func FromContext(ctx context.Context) *Span {
+	s, _ := ctx.Value(spanKey{}).(*Span)
+	return s
+}
+
+// Inject writes the traceparent of the span in ctx into h, for an
+// outgoing request.
+func Inject(ctx context.Context, h http.Header) {
+	s := FromContext(ctx)
+	if s == nil {
+		return
+	}
+	flags := "00"
+	if s.Sampled {
+		flags = "01"
+	}
+	h.Set("traceparent", "00-"+hex.EncodeToString(s.TraceID[:])+"-"+hex.EncodeToString(s.SpanID[:])+"-"+flags)
+}
+
+// Extract attaches the remote parent named by h's traceparent, if valid,
+// to ctx.
+func Extract(ctx context.Context, h http.Header) context.Context {
+	sc, err := ParseTraceparent(h.Get("traceparent"))
+	if err != nil {
+		return ctx
+	}
+	return context.WithValue(ctx, remoteKey{}, sc)
+}
+
+// ParseTraceparent parses a W3C traceparent header value.
+func ParseTraceparent(v string) (SpanContext, error) {
+	var sc SpanContext
+	parts := strings.Split(strings.TrimSpace(v), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" || (parts[0] == "00" && len(parts) != 4) {
+		return sc, fmt.Errorf("invalid traceparent %q", v)
+	}
+	tid, err1 := hex.DecodeString(parts[1])
+	sid, err2 := hex.DecodeString(parts[2])
+	flags, err3 := hex.DecodeString(parts[3])
+	if err1 != nil || err2 != nil || err3 != nil || len(tid) != 16 || len(sid) != 8 || len(flags) != 1 {
+		return sc, fmt.Errorf("invalid traceparent %q", v)
+	}
+	copy(sc.TraceID[:], tid)
+	copy(sc.SpanID[:], sid)
+	sc.Sampled = flags[0]&1 == 1
+	if !sc.Valid() {
+		return sc, fmt.Errorf("invalid traceparent %q", v)
+	}
+	return sc, nil
+}
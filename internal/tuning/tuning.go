@@ -0,0 +1,624 @@
+// Package tuning closes the loop between answer feedback and policy. It
+// counts, per tenant and hour, why answers were refused or modified and
+// which attack techniques requests showed, joins the false-positive
+// feedback clients send for them (POST /v1/feedback with e.g. label
+// "false_refusal"), and suggests a threshold adjustment for a reason
+// whose false-positive rate passes a limit:
+//
+//	policy_refusal:risk_<level>  drop the level from refusal.risk_levels
+//	policy_refusal:<flag>        drop the flag from refusal.flags
+//	anything else, mostly refused on the slow path
+//	                             raise paths.slow_risk_level one step
+//
+// A suggestion only takes effect once an operator approves it (see
+// Tuner.Apply); approved adjustments are layered over the tenant's policy
+// set on every request until reverted, and should be folded into
+// policy.yaml through review in due course.
+package tuning
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/shivansh-source/nopass/internal/metrics"
+	"github.com/shivansh-source/nopass/internal/policy"
+	"github.com/shivansh-source/nopass/internal/types"
+)
+
+// Change kinds.
+const (
+	ChangeAllowRiskLevel = "allow_risk_level"
+	ChangeAllowFlag      = "allow_flag"
+	ChangeSlowRiskLevel  = "slow_risk_level"
+)
+
+// policyRefusal prefixes the reason the gateway gives policy refusals.
+const policyRefusal = "policy_refusal:"
+
+// Errors returned by Apply and Revert.
+var (
+	ErrNoSuggestion = errors.New("tuning: no such suggestion")
+	ErrNoAdjustment = errors.New("tuning: no such adjustment")
+	ErrNoApprover   = errors.New("tuning: approved_by is required")
+)
+
+// Observation is the outcome of one chat request.
+type Observation struct {
+	Tenant    string
+	FeatureID string
+	Time      time.Time
+	Path      types.Path
+	// SlowRiskLevel is the slow-path threshold the request was routed by.
+	SlowRiskLevel types.RiskLevel
+	Withheld      bool
+	Modified      bool
+	// Flags are the reasons the answer was withheld or modified.
+	Flags []string
+	// Techniques are the attack techniques the request showed.
+	Techniques []string
+}
+
+// Count is how often a reason refused or modified an answer, and how
+// many of those answers were labelled false positives.
+type Count struct {
+	Refused        int `json:"refused"`
+	Modified       int `json:"modified"`
+	FalsePositives int `json:"false_positives"`
+	// SlowRefused is how many of the refusals were on the slow path.
+	SlowRefused int `json:"slow_refused,omitempty"`
+}
+
+func (c *Count) add(o Count) {
+	c.Refused += o.Refused
+	c.Modified += o.Modified
+	c.FalsePositives += o.FalsePositives
+	c.SlowRefused += o.SlowRefused
+}
+
+// Rate is the share of refusals labelled false positives.
+func (c Count) Rate() float64 {
+	if c.Refused == 0 {
+		return 0
+	}
+	return float64(c.FalsePositives) / float64(c.Refused)
+}
+
+// Bucket is one hour of a tenant's outcomes.
+type Bucket struct {
+	Start    time.Time         `json:"start"`
+	Requests int               `json:"requests"`
+	Reasons  map[string]*Count `json:"reasons,omitempty"`
+	// Techniques counts requests by attack technique.
+	Techniques map[string]int `json:"techniques,omitempty"`
+}
+
+// Change is a threshold adjustment.
+type Change struct {
+	Kind  string `json:"kind"`
+	Value string `json:"value"`
+}
+
+// Suggestion proposes a Change for a tenant whose refusals for Reason are
+// too often labelled false positives.
+type Suggestion struct {
+	ID                string  `json:"id"`
+	Tenant            string  `json:"tenant"`
+	Reason            string  `json:"reason"`
+	Refused           int     `json:"refused"`
+	FalsePositives    int     `json:"false_positives"`
+	FalsePositiveRate float64 `json:"false_positive_rate"`
+	Change            Change  `json:"change"`
+	Description       string  `json:"description"`
+}
+
+// Adjustment is an approved Suggestion in effect.
+type Adjustment struct {
+	Suggestion
+	ApprovedBy string    `json:"approved_by"`
+	AppliedAt  time.Time `json:"applied_at"`
+}
+
+// Tuner tracks outcomes and feedback per tenant and holds the approved
+// adjustments. A nil Tuner tracks nothing and adjusts nothing.
+type Tuner struct {
+	// Window is how far back stats and suggestions look (0 = 7 days).
+	Window time.Duration
+	// MinRefusals is how many refusals a reason needs in the window before
+	// it is judged (0 = 20).
+	MinRefusals int
+	// Rate is the false-positive rate at which an adjustment is suggested
+	// (0 = 0.1).
+	Rate float64
+	// Labels are the feedback labels that mark a false positive (nil =
+	// "false_refusal", "false_positive").
+	Labels []string
+	// StatePath, if set, is the JSON file approved adjustments are kept
+	// in across restarts.
+	StatePath string
+
+	mu      sync.Mutex
+	tenants map[string][]*Bucket       // oldest first
+	recent  map[string]observed        // feature ID → outcome, for feedback
+	order   []string                   // feature IDs in recent, oldest first
+	slow    map[string]types.RiskLevel // the tenant's last slow-path threshold
+	applied map[string][]Adjustment
+}
+
+// observed is what feedback needs to find a request's counts again.
+type observed struct {
+	tenant  string
+	hour    time.Time
+	reasons []string
+}
+
+// maxRecent bounds how many requests feedback can still be joined to.
+const maxRecent = 100000
+
+var adjustments = metrics.NewCounterVec(
+	"nopass_tuning_adjustments_total",
+	"Threshold adjustments by action (applied, reverted).",
+	"action",
+)
+
+// Load reads the approved adjustments from StatePath, if it exists.
+func (t *Tuner) Load() error {
+	if t.StatePath == "" {
+		return nil
+	}
+	raw, err := os.ReadFile(t.StatePath)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("tuning: read state: %w", err)
+	}
+	var list []Adjustment
+	if err := json.Unmarshal(raw, &list); err != nil {
+		return fmt.Errorf("tuning: parse %s: %w", t.StatePath, err)
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.applied = make(map[string][]Adjustment)
+	for _, a := range list {
+		t.applied[a.Tenant] = append(t.applied[a.Tenant], a)
+	}
+	return nil
+}
+
+// save writes the approved adjustments to StatePath; t.mu is held.
+func (t *Tuner) save() error {
+	if t.StatePath == "" {
+		return nil
+	}
+	list := []Adjustment{}
+	for _, as := range t.applied {
+		list = append(list, as...)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].AppliedAt.Before(list[j].AppliedAt) })
+	raw, err := json.MarshalIndent(list, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := t.StatePath + ".tmp"
+	if err := os.WriteFile(tmp, raw, 0o600); err != nil {
+		return fmt.Errorf("tuning: write state: %w", err)
+	}
+	if err := os.Rename(tmp, t.StatePath); err != nil {
+		return fmt.Errorf("tuning: write state: %w", err)
+	}
+	return nil
+}
+
+func (t *Tuner) window() time.Duration {
+	if t.Window > 0 {
+		return t.Window
+	}
+	return 7 * 24 * time.Hour
+}
+
+// Observe counts a request's outcome.
+func (t *Tuner) Observe(o Observation) {
+	if t == nil {
+		return
+	}
+	var reasons []string
+	if o.Withheld || o.Modified {
+		reasons = o.Flags
+		if len(reasons) == 0 {
+			reasons = []string{"unspecified"}
+		}
+	}
+	hour := o.Time.UTC().Truncate(time.Hour)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	b := t.bucket(o.Tenant, hour)
+	b.Requests++
+	for _, tech := range o.Techniques {
+		if b.Techniques == nil {
+			b.Techniques = make(map[string]int)
+		}
+		b.Techniques[tech]++
+	}
+	if o.SlowRiskLevel != "" {
+		if t.slow == nil {
+			t.slow = make(map[string]types.RiskLevel)
+		}
+		t.slow[o.Tenant] = o.SlowRiskLevel
+	}
+	for _, r := range reasons {
+		c := b.Reasons[r]
+		if c == nil {
+			c = &Count{}
+			b.Reasons[r] = c
+		}
+		if o.Withheld {
+			c.Refused++
+			if o.Path == types.PathSlow {
+				c.SlowRefused++
+			}
+		} else {
+			c.Modified++
+		}
+	}
+	if len(reasons) == 0 || o.FeatureID == "" {
+		return
+	}
+	if t.recent == nil {
+		t.recent = make(map[string]observed)
+	}
+	t.recent[o.FeatureID] = observed{tenant: o.Tenant, hour: hour, reasons: reasons}
+	t.order = append(t.order, o.FeatureID)
+	if len(t.order) > maxRecent {
+		delete(t.recent, t.order[0])
+		t.order = t.order[1:]
+	}
+}
+
+// bucket returns the tenant's bucket for hour, dropping buckets older
+// than the window; t.mu is held.
+func (t *Tuner) bucket(tenant string, hour time.Time) *Bucket {
+	if t.tenants == nil {
+		t.tenants = make(map[string][]*Bucket)
+	}
+	bs := t.tenants[tenant]
+	if n := len(bs); n > 0 && bs[n-1].Start.Equal(hour) {
+		return bs[n-1]
+	}
+	cutoff := hour.Add(-t.window())
+	i := 0
+	for i < len(bs) && bs[i].Start.Before(cutoff) {
+		i++
+	}
+	b := &Bucket{Start: hour, Reasons: make(map[string]*Count)}
+	t.tenants[tenant] = append(bs[i:], b)
+	return b
+}
+
+// Feedback counts a label for the request whose feature record was
+// featureID. Only false-positive labels count, and only from the tenant
+// the request was made for.
+func (t *Tuner) Feedback(tenant, featureID, label string) {
+	if t == nil {
+		return
+	}
+	labels := t.Labels
+	if labels == nil {
+		labels = []string{"false_refusal", "false_positive"}
+	}
+	if !slices.Contains(labels, label) {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	o, ok := t.recent[featureID]
+	if !ok || o.tenant != tenant {
+		return
+	}
+	// One label per request.
+	delete(t.recent, featureID)
+	for _, b := range t.tenants[tenant] {
+		if !b.Start.Equal(o.hour) {
+			continue
+		}
+		for _, r := range o.reasons {
+			if c := b.Reasons[r]; c != nil {
+				c.FalsePositives++
+			}
+		}
+	}
+}
+
+// TenantStats is a tenant's outcomes over the window.
+type TenantStats struct {
+	Tenant   string            `json:"tenant"`
+	Requests int               `json:"requests"`
+	Reasons  map[string]*Count `json:"reasons"`
+	// Techniques counts requests by attack technique.
+	Techniques map[string]int `json:"techniques,omitempty"`
+	// Hours is the hour-by-hour series, for hours with requests.
+	Hours []Bucket `json:"hours,omitempty"`
+}
+
+// Stats returns the outcomes of tenant, or of every tenant for "", since
+// the given time (zero for the whole window), busiest tenant first.
+func (t *Tuner) Stats(tenant string, since time.Time, hours bool) []TenantStats {
+	if t == nil {
+		return nil
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	cutoff := time.Now().Add(-t.window())
+	if since.Before(cutoff) {
+		since = cutoff
+	}
+	since = since.UTC().Truncate(time.Hour)
+	var out []TenantStats
+	for id, bs := range t.tenants {
+		if tenant != "" && id != tenant {
+			continue
+		}
+		s := TenantStats{Tenant: id, Reasons: make(map[string]*Count)}
+		for _, b := range bs {
+			if b.Start.Before(since) {
+				continue
+			}
+			s.Requests += b.Requests
+			for tech, n := range b.Techniques {
+				if s.Techniques == nil {
+					s.Techniques = make(map[string]int)
+				}
+				s.Techniques[tech] += n
+			}
+			for r, c := range b.Reasons {
+				if s.Reasons[r] == nil {
+					s.Reasons[r] = &Count{}
+				}
+				s.Reasons[r].add(*c)
+			}
+			if hours {
+				hb := Bucket{Start: b.Start, Requests: b.Requests, Reasons: make(map[string]*Count, len(b.Reasons)), Techniques: maps.Clone(b.Techniques)}
+				for r, c := range b.Reasons {
+					cc := *c
+					hb.Reasons[r] = &cc
+				}
+				s.Hours = append(s.Hours, hb)
+			}
+		}
+		if s.Requests > 0 {
+			out = append(out, s)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Requests != out[j].Requests {
+			return out[i].Requests > out[j].Requests
+		}
+		return out[i].Tenant < out[j].Tenant
+	})
+	return out
+}
+
+// Suggestions returns the adjustments the window's feedback supports for
+// tenant, or every tenant for "", leaving out those already applied.
+func (t *Tuner) Suggestions(tenant string) []Suggestion {
+	if t == nil {
+		return nil
+	}
+	minRefusals, rate := t.MinRefusals, t.Rate
+	if minRefusals <= 0 {
+		minRefusals = 20
+	}
+	if rate <= 0 {
+		rate = 0.1
+	}
+	stats := t.Stats(tenant, time.Time{}, false)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var out []Suggestion
+	for _, s := range stats {
+		for reason, c := range s.Reasons {
+			if c.Refused < minRefusals || c.Rate() < rate {
+				continue
+			}
+			change, ok := t.changeFor(s.Tenant, reason, *c)
+			if !ok {
+				continue
+			}
+			sg := Suggestion{
+				ID:                suggestionID(s.Tenant, change),
+				Tenant:            s.Tenant,
+				Reason:            reason,
+				Refused:           c.Refused,
+				FalsePositives:    c.FalsePositives,
+				FalsePositiveRate: c.Rate(),
+				Change:            change,
+			}
+			if t.isApplied(sg.Tenant, sg.ID) {
+				continue
+			}
+			sg.Description = fmt.Sprintf("%d of %d refusals for %s were reported false; %s", c.FalsePositives, c.Refused, reason, change)
+			out = append(out, sg)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].FalsePositiveRate != out[j].FalsePositiveRate {
+			return out[i].FalsePositiveRate > out[j].FalsePositiveRate
+		}
+		return out[i].ID < out[j].ID
+	})
+	return out
+}
+
+// changeFor maps a refusal reason to the adjustment that would relax it;
+// t.mu is held.
+func (t *Tuner) changeFor(tenant, reason string, c Count) (Change, bool) {
+	if r, ok := strings.CutPrefix(reason, policyRefusal); ok {
+		if level, ok := strings.CutPrefix(r, "risk_"); ok {
+			return Change{Kind: ChangeAllowRiskLevel, Value: strings.ToUpper(level)}, true
+		}
+		return Change{Kind: ChangeAllowFlag, Value: r}, true
+	}
+	if c.SlowRefused*2 < c.Refused {
+		return Change{}, false
+	}
+	next := nextLevel(t.slow[tenant])
+	if next == "" {
+		return Change{}, false
+	}
+	return Change{Kind: ChangeSlowRiskLevel, Value: string(next)}, true
+}
+
+func nextLevel(l types.RiskLevel) types.RiskLevel {
+	switch l {
+	case types.RiskLow:
+		return types.RiskMedium
+	case types.RiskMedium:
+		return types.RiskHigh
+	}
+	return ""
+}
+
+func (c Change) String() string {
+	switch c.Kind {
+	case ChangeAllowRiskLevel:
+		return "stop refusing " + c.Value + " risk outright"
+	case ChangeAllowFlag:
+		return "stop refusing the " + c.Value + " flag outright"
+	case ChangeSlowRiskLevel:
+		return "send only " + c.Value + " risk and above to the slow path"
+	}
+	return c.Kind + "=" + c.Value
+}
+
+func suggestionID(tenant string, c Change) string {
+	sum := sha256.Sum256([]byte(tenant + "\x00" + c.Kind + "\x00" + c.Value))
+	return "tun_" + hex.EncodeToString(sum[:6])
+}
+
+// isApplied reports whether the suggestion is in effect; t.mu is held.
+func (t *Tuner) isApplied(tenant, id string) bool {
+	return slices.ContainsFunc(t.applied[tenant], func(a Adjustment) bool { return a.ID == id })
+}
+
+// Apply puts the suggestion with this ID into effect, approved by
+// approvedBy.
+func (t *Tuner) Apply(id, approvedBy string) (Adjustment, error) {
+	if strings.TrimSpace(approvedBy) == "" {
+		return Adjustment{}, ErrNoApprover
+	}
+	suggestions := t.Suggestions("")
+	i := slices.IndexFunc(suggestions, func(s Suggestion) bool { return s.ID == id })
+	if i < 0 {
+		return Adjustment{}, ErrNoSuggestion
+	}
+	a := Adjustment{Suggestion: suggestions[i], ApprovedBy: approvedBy, AppliedAt: time.Now().UTC()}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.applied == nil {
+		t.applied = make(map[string][]Adjustment)
+	}
+	t.applied[a.Tenant] = append(t.applied[a.Tenant], a)
+	if err := t.save(); err != nil {
+		t.applied[a.Tenant] = t.applied[a.Tenant][:len(t.applied[a.Tenant])-1]
+		return Adjustment{}, err
+	}
+	adjustments.Inc("applied")
+	return a, nil
+}
+
+// Revert takes the adjustment with this ID out of effect.
+func (t *Tuner) Revert(id string) (Adjustment, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for tenant, as := range t.applied {
+		i := slices.IndexFunc(as, func(a Adjustment) bool { return a.ID == id })
+		if i < 0 {
+			continue
+		}
+		a := as[i]
+		t.applied[tenant] = slices.Delete(slices.Clone(as), i, i+1)
+		if err := t.save(); err != nil {
+			t.applied[tenant] = as
+			return Adjustment{}, err
+		}
+		adjustments.Inc("reverted")
+		return a, nil
+	}
+	return Adjustment{}, ErrNoAdjustment
+}
+
+// Applied returns the adjustments in effect for tenant, or every tenant
+// for "".
+func (t *Tuner) Applied(tenant string) []Adjustment {
+	if t == nil {
+		return nil
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	list := []Adjustment{}
+	for id, as := range t.applied {
+		if tenant == "" || id == tenant {
+			list = append(list, as...)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].AppliedAt.Before(list[j].AppliedAt) })
+	return list
+}
+
+// Adjust returns pol, the tenant's resolved policy set (nil for the
+// built-in defaults), with the tenant's adjustments applied.
+func (t *Tuner) Adjust(tenant string, pol *policy.Set) (*policy.Set, error) {
+	if t == nil {
+		return pol, nil
+	}
+	t.mu.Lock()
+	as := t.applied[tenant]
+	t.mu.Unlock()
+	if len(as) == 0 {
+		return pol, nil
+	}
+	base := pol
+	if base == nil {
+		base = policy.Default()
+	}
+	levels := slices.Clone(base.Refusal.RiskLevels)
+	flags := slices.Clone(base.Refusal.Flags)
+	var sec policy.Sections
+	ids := make([]string, 0, len(as))
+	for _, a := range as {
+		ids = append(ids, a.ID)
+		switch a.Change.Kind {
+		case ChangeAllowRiskLevel:
+			levels = slices.DeleteFunc(levels, func(l types.RiskLevel) bool { return string(l) == a.Change.Value })
+			sec.Refusal = &policy.Refusal{RiskLevels: levels, Flags: flags}
+		case ChangeAllowFlag:
+			flags = slices.DeleteFunc(flags, func(f string) bool { return f == a.Change.Value })
+			sec.Refusal = &policy.Refusal{RiskLevels: levels, Flags: flags}
+		case ChangeSlowRiskLevel:
+			// Later adjustments raise it further.
+			sec.Paths = &policy.Paths{SlowRiskLevel: types.RiskLevel(a.Change.Value)}
+		}
+	}
+	if sec.Refusal != nil {
+		// Non-nil, so emptied lists still replace the policy's.
+		if sec.Refusal.RiskLevels == nil {
+			sec.Refusal.RiskLevels = []types.RiskLevel{}
+		}
+		if sec.Refusal.Flags == nil {
+			sec.Refusal.Flags = []string{}
+		}
+	}
+	return base.Override(sec, ids)
+}
@@ -0,0 +1,92 @@
+package types
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// SchemaVersion is the version of the cross-service payloads this build
+// speaks. Bump it only for incompatible changes; additive fields are handled
+// by tolerant decoding (unknown fields are ignored).
+const SchemaVersion = 1
+
+// SchemaHeader advertises the sender's schema version on every
+// cross-service HTTP call.
+const SchemaHeader = "X-NoPass-Schema-Version"
+
+// CheckSchemaVersion validates the schema_version of a received payload.
+// A missing version (0) is treated as the legacy v1 format; anything newer
+// than SchemaVersion is rejected rather than silently misparsed.
+func CheckSchemaVersion(v int) error {
+	if v > SchemaVersion {
+		return fmt.Errorf("unsupported schema version %d (this build supports up to %d)", v, SchemaVersion)
+	}
+	if v < 0 {
+		return fmt.Errorf("invalid schema version %d", v)
+	}
+	return nil
+}
+
+// RiskLevel is the severity assigned by a risk scorer.
+type RiskLevel string
+
+const (
+	RiskLow    RiskLevel = "LOW"
+	RiskMedium RiskLevel = "MEDIUM"
+	RiskHigh   RiskLevel = "HIGH"
+)
+
+// Rank orders risk levels so they can be compared; unknown levels rank
+// above HIGH so they are never treated as safe.
+func (l RiskLevel) Rank() int {
+	switch l {
+	case RiskLow:
+		return 0
+	case RiskMedium:
+		return 1
+	case RiskHigh:
+		return 2
+	default:
+		return 3
+	}
+}
+
+// UnmarshalJSON accepts any casing but rejects unknown levels.
+func (l *RiskLevel) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("risk_level: %w", err)
+	}
+	switch v := RiskLevel(strings.ToUpper(strings.TrimSpace(s))); v {
+	case RiskLow, RiskMedium, RiskHigh:
+		*l = v
+		return nil
+	default:
+		return fmt.Errorf("risk_level: unknown value %q", s)
+	}
+}
+
+// Path is the pipeline path a request takes. The output-safety mode uses
+// the same values.
+type Path string
+
+const (
+	PathFast Path = "fast"
+	PathSlow Path = "slow"
+)
+
+// UnmarshalJSON accepts any casing but rejects unknown paths.
+func (p *Path) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("path: %w", err)
+	}
+	switch v := Path(strings.ToLower(strings.TrimSpace(s))); v {
+	case PathFast, PathSlow:
+		*p = v
+		return nil
+	default:
+		return fmt.Errorf("path: unknown value %q", s)
+	}
+}
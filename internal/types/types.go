@@ -1,52 +1,379 @@
+// Package types defines the JSON payloads exchanged with clients, the
+// Python services and sandbox runners. The chat, risk and output-safety
+// messages are also defined in proto/nopass/v1, from which internal/pb is
+// generated for the gRPC transport: change both together (a test in
+// internal/pb checks the JSON names match, `buf breaking` the wire) so
+// the Go and Python sides don't drift.
 package types
 
+import "time"
+
 type ExternalData struct {
 	ID          string `json:"id"`
 	Source      string `json:"source"` // e.g. "kb:payments", "web:https://..."
 	Type        string `json:"type"`   // e.g. "document", "web_page"
 	Content     string `json:"content"`
 	IsDangerous bool   `json:"-"` // Internal flag
+	Prescanned  bool   `json:"-"` // Scanned at registration (POST /v1/data)
+}
+
+// DataStatus reports what happened to one external data block.
+type DataStatus string
+
+const (
+	DataScanned    DataStatus = "scanned"     // scanned and clean
+	DataFlagged    DataStatus = "flagged"     // scanned and quarantined for its content
+	DataScanFailed DataStatus = "scan_failed" // scanner unavailable; quarantined defensively
+	DataExcluded   DataStatus = "excluded"    // not shown to the model at all
+	DataUnscanned  DataStatus = "unscanned"   // scanner unavailable; used as-is (fail_open)
+)
+
+// DataBlockStatus is the per-block outcome returned to the client, so an
+// operational failure isn't mistaken for a verdict on the content.
+type DataBlockStatus struct {
+	ID     string     `json:"id"`
+	Source string     `json:"source,omitempty"`
+	Status DataStatus `json:"status"`
+	Reason string     `json:"reason,omitempty"`
 }
 
 type ChatRequest struct {
-	UserID       string         `json:"user_id"`
-	SessionID    string         `json:"session_id"`
-	Message      string         `json:"message"`
-	ExternalData []ExternalData `json:"external_data,omitempty"`
+	TenantID string `json:"tenant_id,omitempty"`
+	// PolicyProfile selects a policy profile when the gateway doesn't
+	// authenticate callers; an API key's profile always wins.
+	PolicyProfile string         `json:"policy_profile,omitempty"`
+	UserID        string         `json:"user_id"`
+	SessionID     string         `json:"session_id"`
+	Message       string         `json:"message"`
+	ExternalData  []ExternalData `json:"external_data,omitempty"`
+	DataRefs      []string       `json:"data_refs,omitempty"` // IDs from POST /v1/data
+	History       []Turn         `json:"history,omitempty"`   // earlier turns, oldest first
+	// ResetSession starts the server-side conversation of SessionID over.
+	ResetSession bool              `json:"reset_session,omitempty"`
+	Retrieve     *RetrieveSpec     `json:"retrieve,omitempty"` // server-side retrieval
+	Priority     string            `json:"priority,omitempty"` // "interactive", "batch" or "eval"; keys allowed to choose only
+	Stream       bool              `json:"stream,omitempty"`   // answer as Server-Sent Events
+	Generation   *GenerationParams `json:"generation,omitempty"`
+	// MaskSpans asks for ChatResponse.MaskedSpans; only API keys allowed
+	// to (auth.Key.MaskSpans) may set it.
+	MaskSpans bool `json:"mask_spans,omitempty"`
+	// Locale, e.g. de-DE, is how dates, amounts and phone numbers are
+	// written in the request, so masking reads them the local way; it
+	// defaults to the gateway's masking.locale.
+	Locale string `json:"locale,omitempty"`
+}
+
+// GenerationParams are sampling settings passed through to the model
+// backend inside the sandbox. Unset fields leave the backend's defaults.
+type GenerationParams struct {
+	// Provider selects a configured model API by name instead of the
+	// sandbox; "" or "sandbox" keeps the deployment's default.
+	Provider    string   `json:"provider,omitempty"`
+	Model       string   `json:"model,omitempty"`
+	Temperature *float64 `json:"temperature,omitempty"`
+	TopP        *float64 `json:"top_p,omitempty"`
+	MaxTokens   int      `json:"max_tokens,omitempty"`
+	Stop        []string `json:"stop,omitempty"`
+}
+
+// RetrieveSpec asks the gateway to fetch external data itself from the
+// tenant's configured connectors.
+type RetrieveSpec struct {
+	Query   string   `json:"query,omitempty"`   // defaults to the user message
+	Sources []string `json:"sources,omitempty"` // connector names; empty = all
+	Limit   int      `json:"limit,omitempty"`   // results per connector
+}
+
+// Turn is one message of a conversation.
+type Turn struct {
+	Role    string `json:"role"` // "user" or "assistant"
+	Content string `json:"content"`
 }
 
 type ChatResponse struct {
-	Answer    string `json:"answer"`
-	RiskLevel string `json:"risk_level"`
-	Path      string `json:"path"` // "fast" or "slow"
+	SchemaVersion int               `json:"schema_version"`
+	Answer        string            `json:"answer"`
+	RiskLevel     RiskLevel         `json:"risk_level"`
+	Path          Path              `json:"path"`
+	Notices       []string          `json:"notices,omitempty"` // e.g. message truncation
+	Citations     []Citation        `json:"citations,omitempty"`
+	DataStatus    []DataBlockStatus `json:"data_status,omitempty"` // one per external data block
+	Receipt       *SandboxReceipt   `json:"receipt,omitempty"`
+	DataClasses   *DataClasses      `json:"data_classes,omitempty"`
+	// SessionID and HistoryTurns are set when the gateway keeps the
+	// conversation: HistoryTurns is how many turns it now stores.
+	SessionID    string `json:"session_id,omitempty"`
+	HistoryTurns int    `json:"history_turns,omitempty"`
+	// SessionRisk is the session's cumulative risk, so applications can
+	// react to an escalating conversation.
+	SessionRisk *SessionRisk `json:"session_risk,omitempty"`
+	// FeatureID identifies the request's exported feature record; send
+	// it to POST /v1/feedback to label the answer.
+	FeatureID string `json:"feature_id,omitempty"`
+	// MaskedSpans are where masking replaced values in the message, for
+	// keys allowed to ask for them (ChatRequest.MaskSpans).
+	MaskedSpans []MaskedSpan `json:"masked_spans,omitempty"`
+	// Artifacts are files the model wrote to its output directory, kept
+	// after a malware scan and downloadable until ExpiresAt.
+	Artifacts []Artifact `json:"artifacts,omitempty"`
+	// StageFailures are the stages whose service failed during the
+	// request and the failure policy applied instead.
+	StageFailures []StageFailure `json:"stage_failures,omitempty"`
+}
+
+// StageFailure reports a pipeline stage that couldn't run as configured.
+type StageFailure struct {
+	Stage  string `json:"stage"`            // "risk", "external_scan" or "output_safety"
+	Policy string `json:"policy"`           // fail_closed, fail_open or degrade_to_local
+	Blocks int    `json:"blocks,omitempty"` // external_scan: the blocks affected
+}
+
+// Artifact is a file produced by the sandboxed model. URL is signed and
+// needs no API key, so treat it as a secret until it expires.
+type Artifact struct {
+	Name        string    `json:"name"`
+	ContentType string    `json:"content_type"`
+	Size        int64     `json:"size"`
+	SHA256      string    `json:"sha256"`
+	URL         string    `json:"url"`
+	ExpiresAt   time.Time `json:"expires_at"`
+}
+
+// MaskedSpan is one value masked in the user's message, so a client can
+// highlight what the model never saw. Start and End are offsets in
+// Unicode code points; the value itself is not returned.
+type MaskedSpan struct {
+	Start int    `json:"start"`
+	End   int    `json:"end"`
+	Kind  string `json:"kind"`  // e.g. "EMAIL" or a policy masking rule
+	Token string `json:"token"` // what the model saw instead, e.g. EMAIL_TOKEN_1
+}
+
+// SessionRisk is the running risk of a conversation across its turns.
+type SessionRisk struct {
+	Level       RiskLevel `json:"level"`
+	Score       int       `json:"score"`            // points over the recent turns: MEDIUM 1, HIGH 3
+	Action      string    `json:"action,omitempty"` // "slow" or "block" once escalated
+	Turns       int       `json:"turns"`
+	MediumTurns int       `json:"medium_turns"`
+	HighTurns   int       `json:"high_turns"`
+	Recent      []int     `json:"recent,omitempty"` // per-turn points, oldest first
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// DataClasses are the DLP data classes (e.g. "health", "financial") found
+// in a request's input and in its final answer.
+type DataClasses struct {
+	Input  []string `json:"input,omitempty"`
+	Output []string `json:"output,omitempty"`
+}
+
+// Streamed answers (POST /v1/chat with "stream": true or Accept:
+// text/event-stream) arrive as Server-Sent Events:
+//
+//	event: delta    data: StreamDelta      next piece of the answer
+//	event: retract  data: StreamRetract    discard every delta so far
+//	event: done     data: ChatResponse     authoritative final response
+//	event: error    data: StreamError      the pipeline failed; no done follows
+//
+// Deltas are released only after the output-safety review has passed the
+// answer up to that point, so the deltas after the last retract always
+// add up to the final Answer.
+
+// StreamDelta is the data of a "delta" event.
+type StreamDelta struct {
+	Text string `json:"text"`
+}
+
+// StreamRetract is the data of a "retract" event, sent when a later review
+// (or an approval gate) changed text that was already released.
+type StreamRetract struct {
+	ReasonFlags []string `json:"reason_flags,omitempty"`
+}
+
+// StreamError is the data of an "error" event.
+type StreamError struct {
+	Error string `json:"error"`
+}
+
+// Citation is a UI-friendly link extracted from the answer by the
+// "citations" post-processor; the answer refers to it as [Index].
+type Citation struct {
+	Index int    `json:"index"`
+	URL   string `json:"url"`
+	Title string `json:"title,omitempty"`
+}
+
+type DataRegistrationRequest struct {
+	TenantID string `json:"tenant_id,omitempty"`
+	Source   string `json:"source"`
+	Type     string `json:"type"`
+	Content  string `json:"content"`
 }
 
 // ----- Types used to talk to Python risk service ----- //
 
 type RiskRequest struct {
+	SchemaVersion int `json:"schema_version"`
+
 	Prompt   string            `json:"prompt"`
 	Metadata map[string]string `json:"metadata,omitempty"`
+	// DeadlineMs is the time budget the service has to answer; it mirrors
+	// the X-Deadline-Ms header.
+	DeadlineMs int64 `json:"deadline_ms,omitempty"`
 }
 
 type RiskResponse struct {
-	SanitizedPrompt   string   `json:"sanitized_prompt"`
-	RiskLevel         string   `json:"risk_level"`
-	Flags             []string `json:"flags"`
-	SelfCheckRequired bool     `json:"self_check_required"`
+	SchemaVersion     int       `json:"schema_version"`
+	SanitizedPrompt   string    `json:"sanitized_prompt"`
+	RiskLevel         RiskLevel `json:"risk_level"`
+	Flags             []string  `json:"flags"`
+	SelfCheckRequired bool      `json:"self_check_required"`
+}
+
+// RiskBatchRequest scores several documents in one call
+// (POST /v1/risk-score/batch). Services without the endpoint answer 404,
+// and the gateway scores the documents one at a time instead.
+type RiskBatchRequest struct {
+	SchemaVersion int               `json:"schema_version"`
+	Documents     []string          `json:"documents"`
+	Metadata      map[string]string `json:"metadata,omitempty"`
+	DeadlineMs    int64             `json:"deadline_ms,omitempty"`
+}
+
+// RiskBatchResponse holds one verdict per document, in request order.
+type RiskBatchResponse struct {
+	SchemaVersion int            `json:"schema_version"`
+	Results       []RiskResponse `json:"results"`
+}
+
+// RiskSection is one NDJSON line of a streaming scoring request
+// (POST /v2/risk-score/stream). Large documents are sent section by
+// section instead of as one JSON body.
+type RiskSection struct {
+	SchemaVersion int               `json:"schema_version"`
+	Seq           int               `json:"seq"`
+	Text          string            `json:"text"`
+	Metadata      map[string]string `json:"metadata,omitempty"` // first section only
+	DeadlineMs    int64             `json:"deadline_ms,omitempty"`
+}
+
+// RiskSectionVerdict is one NDJSON line of the streaming response, sent as
+// soon as the section with the same Seq has been scored. Final is set on
+// the last verdict the service will send: the one for a section that hit
+// HIGH (scoring stopped early), or a trailing marker with Seq -1 once every
+// section was scored.
+type RiskSectionVerdict struct {
+	SchemaVersion int       `json:"schema_version"`
+	Seq           int       `json:"seq"`
+	RiskLevel     RiskLevel `json:"risk_level"`
+	Flags         []string  `json:"flags"`
+	Final         bool      `json:"final,omitempty"`
 }
 
 // ----- Output Safety ----- //
 
 type OutputSafetyRequest struct {
-	UserPrompt  string   `json:"user_prompt"`
-	DraftAnswer string   `json:"draft_answer"`
-	RiskLevel   string   `json:"risk_level"`
-	Flags       []string `json:"flags"`
-	Mode        string   `json:"mode"` // "fast" or "slow"
+	SchemaVersion int       `json:"schema_version"`
+	UserPrompt    string    `json:"user_prompt"`
+	DraftAnswer   string    `json:"draft_answer"`
+	RiskLevel     RiskLevel `json:"risk_level"`
+	Flags         []string  `json:"flags"`
+	Mode          Path      `json:"mode"`
+	DeadlineMs    int64     `json:"deadline_ms,omitempty"`
+
+	// What actually went into the model, so the reviewer can judge the
+	// draft against it.
+	MaskedPrompt   string       `json:"masked_prompt,omitempty"` // user message as the model saw it
+	Sources        []DataSource `json:"sources,omitempty"`
+	PolicyID       string       `json:"policy_id,omitempty"`
+	DataFlowLabels []string     `json:"data_flow_labels,omitempty"` // e.g. "external:retrieved", "pii_masked"
+
+	// Partial marks DraftAnswer as the prefix of an answer that is still
+	// being streamed; the full answer is reviewed again once complete.
+	Partial bool `json:"partial,omitempty"`
+}
+
+// DataSource describes one external data block included in the prompt.
+type DataSource struct {
+	ID        string `json:"id"`
+	Source    string `json:"source"`
+	Type      string `json:"type"`
+	Dangerous bool   `json:"dangerous"`
 }
 
 type OutputSafetyResponse struct {
-	FinalAnswer string   `json:"final_answer"`
-	WasModified bool     `json:"was_modified"`
-	ReasonFlags []string `json:"reason_flags"`
+	SchemaVersion int      `json:"schema_version"`
+	FinalAnswer   string   `json:"final_answer"`
+	WasModified   bool     `json:"was_modified"`
+	Blocked       bool     `json:"blocked,omitempty"` // refused outright rather than redacted
+	ReasonFlags   []string `json:"reason_flags"`
+}
+
+// ----- Sandbox runner fleet ----- //
+
+type RunRequest struct {
+	SchemaVersion int               `json:"schema_version"`
+	SystemPrompt  string            `json:"system_prompt"`
+	UserContent   string            `json:"user_content"`
+	TenantID      string            `json:"tenant_id,omitempty"`
+	CacheKey      string            `json:"cache_key,omitempty"` // system prompt is a cacheable prefix
+	Generation    *GenerationParams `json:"generation,omitempty"`
+	Echo          *SandboxEcho      `json:"echo,omitempty"` // footer the output must carry
+}
+
+// SandboxEcho is what a sandbox run is given in its prompt's <context>
+// block and must echo back in a footer after its answer, so output from a
+// stale or reused container is caught.
+type SandboxEcho struct {
+	RequestID     string `json:"request_id"`
+	PolicyVersion string `json:"policy_version,omitempty"`
+}
+
+type RunResponse struct {
+	SchemaVersion int             `json:"schema_version"`
+	Answer        string          `json:"answer"`
+	Receipt       *SandboxReceipt `json:"receipt,omitempty"`
+}
+
+// SandboxReceipt is the measured resource usage of one sandbox run, for
+// capacity planning and per-tenant cost accounting.
+type SandboxReceipt struct {
+	ID              string    `json:"id"`
+	TenantID        string    `json:"tenant_id,omitempty"`
+	RunnerID        string    `json:"runner_id,omitempty"` // fleet mode only
+	ContainerID     string    `json:"container_id,omitempty"`
+	Image           string    `json:"image"`
+	ImageDigest     string    `json:"image_digest,omitempty"`
+	StartedAt       time.Time `json:"started_at"`
+	WallTimeMs      int64     `json:"wall_time_ms"`
+	CPUTimeMs       int64     `json:"cpu_time_ms"`       // user+system, measured inside the sandbox
+	PeakMemoryBytes int64     `json:"peak_memory_bytes"` // max RSS, measured inside the sandbox
+	ExitCode        int       `json:"exit_code"`
+	// OOMKilled: the run hit the container's memory limit.
+	OOMKilled   bool `json:"oom_killed,omitempty"`
+	OutputBytes int  `json:"output_bytes"`
+	// OutputProtocol is the stdout protocol the image spoke, "frames" or
+	// "text". Model, the token counts and Warnings are what it reported
+	// in its output frames, if it did.
+	OutputProtocol string   `json:"output_protocol,omitempty"`
+	Model          string   `json:"model,omitempty"`
+	InputTokens    int      `json:"input_tokens,omitempty"`
+	OutputTokens   int      `json:"output_tokens,omitempty"`
+	Warnings       []string `json:"warnings,omitempty"`
+}
+
+// RunnerHeartbeat is sent periodically by every runner host to the gateways.
+// The first heartbeat doubles as registration.
+type RunnerHeartbeat struct {
+	SchemaVersion int    `json:"schema_version"`
+	ID            string `json:"id"`
+	Addr          string `json:"addr"`      // base URL of the runner, e.g. "http://10.0.0.5:8090"
+	Capacity      int    `json:"capacity"`  // max concurrent sandbox runs
+	InFlight      int    `json:"in_flight"` // sandbox runs currently executing
+	Draining      bool   `json:"draining,omitempty"`
+	// Tenants are the tenants the runner is dedicated to (they have private
+	// images/weights loaded there). Gateways don't take a runner's word for
+	// it: they schedule by their own assignment and report that here.
+	Tenants []string `json:"tenants,omitempty"`
 }
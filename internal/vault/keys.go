@@ -0,0 +1,109 @@
+// Package vault stores the original values behind masking tokens so they
+// can be restored later. Every mapping is sealed with its tenant's own key,
+// and the tenant and token are bound into the ciphertext, so one tenant's
+// key (compromised or not) can never open another tenant's entries.
+package vault
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// KeySize is the AES-256 key length every provider must return.
+const KeySize = 32
+
+// ErrNoKey is returned when no key is available for a tenant.
+var ErrNoKey = errors.New("vault: no key for tenant")
+
+// KeyProvider returns the data key for a tenant. Implementations backed by
+// an external KMS may be slow and should cache.
+type KeyProvider interface {
+	Key(ctx context.Context, tenantID string) ([]byte, error)
+}
+
+// Keyring serves customer-managed keys (BYOK) for the tenants that supplied
+// one and derives a per-tenant key from a master key for everyone else.
+// Either part may be empty; a tenant with neither gets ErrNoKey.
+type Keyring struct {
+	mu     sync.RWMutex
+	master []byte
+	byok   map[string][]byte
+}
+
+// NewKeyring creates a keyring. master may be nil to require BYOK for
+// every tenant.
+func NewKeyring(master []byte) (*Keyring, error) {
+	if master != nil && len(master) < KeySize {
+		return nil, fmt.Errorf("vault: master key must be at least %d bytes", KeySize)
+	}
+	return &Keyring{master: master, byok: make(map[string][]byte)}, nil
+}
+
+// SetTenantKey installs (or rotates) a customer-managed key for tenantID.
+// Entries sealed under the previous key can no longer be opened.
+func (k *Keyring) SetTenantKey(tenantID string, key []byte) error {
+	if len(key) != KeySize {
+		return fmt.Errorf("vault: key for tenant %q must be %d bytes, got %d", tenantID, KeySize, len(key))
+	}
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.byok[tenantID] = append([]byte(nil), key...)
+	return nil
+}
+
+// Key implements KeyProvider.
+func (k *Keyring) Key(_ context.Context, tenantID string) ([]byte, error) {
+	k.mu.RLock()
+	key, ok := k.byok[tenantID]
+	k.mu.RUnlock()
+	if ok {
+		return key, nil
+	}
+	if k.master == nil {
+		return nil, fmt.Errorf("%w %q", ErrNoKey, tenantID)
+	}
+	// HMAC-SHA256 as a KDF: independent keys per tenant from one secret.
+	mac := hmac.New(sha256.New, k.master)
+	mac.Write([]byte("nopass-vault/v1/tenant:" + tenantID))
+	return mac.Sum(nil), nil
+}
+
+// ParseKey decodes a base64 (standard or URL alphabet) key.
+func ParseKey(s string) ([]byte, error) {
+	s = strings.TrimSpace(s)
+	for _, enc := range []*base64.Encoding{base64.StdEncoding, base64.URLEncoding, base64.RawStdEncoding, base64.RawURLEncoding} {
+		if b, err := enc.DecodeString(s); err == nil {
+			return b, nil
+		}
+	}
+	return nil, errors.New("vault: key is not valid base64")
+}
+
+// ParseTenantKeys parses "acme=<base64>,globex=<base64>" into the
+// keyring's BYOK keys.
+func (k *Keyring) ParseTenantKeys(spec string) error {
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		tenant, encoded, ok := strings.Cut(entry, "=")
+		if !ok || tenant == "" {
+			return fmt.Errorf("vault: tenant key entry must be tenant=base64key")
+		}
+		key, err := ParseKey(encoded)
+		if err != nil {
+			return fmt.Errorf("tenant %q: %w", tenant, err)
+		}
+		if err := k.SetTenantKey(tenant, key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,107 @@
+package vault
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/shivansh-source/nopass/internal/storage"
+)
+
+// ErrNotFound is returned for unknown or expired tokens.
+var ErrNotFound = storage.ErrNotFound
+
+// Vault maps masking tokens back to the values they replaced. Values are
+// kept only as AES-GCM ciphertext under the tenant's key; the backing
+// store never sees plaintext. Entries belong to the user whose request
+// made them: another user of the same session ID finds none.
+type Vault struct {
+	Keys  KeyProvider
+	Store storage.VaultStore
+	// TTL bounds how long a mapping is kept (0 = forever).
+	TTL time.Duration
+}
+
+// New creates a vault on top of store.
+func New(keys KeyProvider, store storage.VaultStore, ttl time.Duration) *Vault {
+	return &Vault{Keys: keys, Store: store, TTL: ttl}
+}
+
+// Put seals value under tenantID's key and stores it for token within
+// the user's session.
+func (v *Vault) Put(ctx context.Context, tenantID, userID, sessionID, token, value string) error {
+	aead, err := v.aead(ctx, tenantID)
+	if err != nil {
+		return err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return fmt.Errorf("vault: nonce: %w", err)
+	}
+	k := storage.VaultKey{TenantID: tenantID, SessionID: userSession(userID, sessionID), Token: token}
+	// Stored as nonce || ciphertext.
+	sealed := aead.Seal(nonce, nonce, []byte(value), aad(k))
+	var expires time.Time
+	if v.TTL > 0 {
+		expires = time.Now().Add(v.TTL)
+	}
+	return v.Store.PutSealed(ctx, k, sealed, expires)
+}
+
+// Get returns the value behind token. Decryption fails, rather than
+// returning another tenant's data, if the entry was sealed under a
+// different tenant's key.
+func (v *Vault) Get(ctx context.Context, tenantID, userID, sessionID, token string) (string, error) {
+	k := storage.VaultKey{TenantID: tenantID, SessionID: userSession(userID, sessionID), Token: token}
+	sealed, err := v.Store.GetSealed(ctx, k)
+	if err != nil {
+		return "", err
+	}
+
+	aead, err := v.aead(ctx, tenantID)
+	if err != nil {
+		return "", err
+	}
+	if len(sealed) < aead.NonceSize() {
+		return "", fmt.Errorf("vault: corrupt entry for tenant %q", tenantID)
+	}
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	plain, err := aead.Open(nil, nonce, ciphertext, aad(k))
+	if err != nil {
+		return "", fmt.Errorf("vault: open token for tenant %q: %w", tenantID, err)
+	}
+	return string(plain), nil
+}
+
+// DeleteSession drops every mapping of a user's session.
+func (v *Vault) DeleteSession(ctx context.Context, tenantID, userID, sessionID string) error {
+	return v.Store.DeleteVaultSession(ctx, tenantID, userSession(userID, sessionID))
+}
+
+// userSession is the session ID entries are stored under: the session
+// scoped to its user, length-prefixed so no two pairs collide.
+func userSession(userID, sessionID string) string {
+	return strconv.Itoa(len(userID)) + ":" + userID + ":" + sessionID
+}
+
+func (v *Vault) aead(ctx context.Context, tenantID string) (cipher.AEAD, error) {
+	key, err := v.Keys.Key(ctx, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("vault: tenant %q key: %w", tenantID, err)
+	}
+	return cipher.NewGCM(block)
+}
+
+// aad binds the ciphertext to where it is stored, so entries can't be
+// swapped between tenants, users, sessions or tokens.
+func aad(k storage.VaultKey) []byte {
+	return []byte(k.TenantID + "\x00" + k.SessionID + "\x00" + k.Token)
+}
@@ -0,0 +1,120 @@
+// Package warmup readies a gateway for traffic before it reports ready:
+// connections to downstream services are opened, the sandbox image is
+// pulled and run once, and caches are primed, so the first requests after
+// a deploy don't pay for all of that. /readyz fails until warm-up has finished; /healthz
+// only reports that the process is up.
+package warmup
+
+import (
+	"context"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/shivansh-source/nopass/internal/metrics"
+)
+
+// Step is one warm-up task.
+type Step struct {
+	Name string
+	Run  func(ctx context.Context) error
+}
+
+// Warmer runs warm-up steps and tracks readiness.
+type Warmer struct {
+	Steps []Step
+	// Timeout bounds warm-up (default 2m). A step that fails or runs out
+	// of time is logged and does not hold readiness back: a cold gateway
+	// still beats none.
+	Timeout time.Duration
+
+	ready atomic.Bool
+}
+
+var steps = metrics.NewCounterVec(
+	"nopass_warmup_steps_total",
+	"Warm-up steps run at startup, by step and result.",
+	"step", "result",
+)
+
+// Run runs every step concurrently, then marks the gateway ready.
+func (w *Warmer) Run(ctx context.Context) {
+	timeout := w.Timeout
+	if timeout <= 0 {
+		timeout = 2 * time.Minute
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	start := time.Now()
+	var wg sync.WaitGroup
+	for _, s := range w.Steps {
+		wg.Add(1)
+		go func(s Step) {
+			defer wg.Done()
+			t := time.Now()
+			if err := s.Run(ctx); err != nil {
+				steps.Inc(s.Name, "error")
+				log.Printf("warm-up %s failed after %s: %v", s.Name, time.Since(t).Round(time.Millisecond), err)
+				return
+			}
+			steps.Inc(s.Name, "ok")
+			log.Printf("warm-up %s done in %s", s.Name, time.Since(t).Round(time.Millisecond))
+		}(s)
+	}
+	wg.Wait()
+	w.ready.Store(true)
+	log.Printf("warm-up finished in %s; ready", time.Since(start).Round(time.Millisecond))
+}
+
+// MarkReady marks the gateway ready without warming up.
+func (w *Warmer) MarkReady() { w.ready.Store(true) }
+
+// Ready reports whether warm-up has finished.
+func (w *Warmer) Ready() bool { return w.ready.Load() }
+
+// ReadyHandler serves /readyz: 200 once warm, 503 before.
+func (w *Warmer) ReadyHandler(rw http.ResponseWriter, r *http.Request) {
+	if !w.Ready() {
+		http.Error(rw, "warming up", http.StatusServiceUnavailable)
+		return
+	}
+	rw.Write([]byte("ok\n"))
+}
+
+// HealthHandler serves /healthz: 200 while the process serves HTTP.
+func HealthHandler(rw http.ResponseWriter, r *http.Request) {
+	rw.Write([]byte("ok\n"))
+}
+
+// Connect returns a step that resolves the host of each URL and opens a
+// keep-alive connection to it through client, so the first requests to
+// each downstream service wait on neither DNS nor a TCP and TLS handshake.
+// Any HTTP response counts; empty URLs are skipped.
+func Connect(client *http.Client, urls ...string) Step {
+	return Step{Name: "connect", Run: func(ctx context.Context) error {
+		seen := make(map[string]bool)
+		for _, u := range urls {
+			parsed, err := url.Parse(u)
+			if err != nil || parsed.Host == "" || seen[parsed.Host] {
+				continue
+			}
+			seen[parsed.Host] = true
+			req, err := http.NewRequestWithContext(ctx, http.MethodHead, parsed.Scheme+"://"+parsed.Host+"/", nil)
+			if err != nil {
+				return err
+			}
+			resp, err := client.Do(req)
+			if err != nil {
+				return err
+			}
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+		return nil
+	}}
+}
@@ -1,29 +1,191 @@
+import json
 import os
+import resource
 import sys
 
 INPUT_DIR = "/app/input"
 
+# With NOPASS_INPUT=stdin the gateway writes the request to stdin as one
+# JSON line instead of files in INPUT_DIR:
+#   {"type": "request", "version": 1, "system_prompt": ..., "user_content": ...,
+#    "output": "frames", "cache": {...}, "params": {...}}
+# The output must then end with a "result" frame.
+STDIN_INPUT = os.environ.get("NOPASS_INPUT") == "stdin"
+
 def read_file(path: str) -> str:
     if not os.path.exists(path):
         return ""
     with open(path, "r", encoding="utf-8") as f:
         return f.read()
 
+def read_request() -> dict:
+    """
+    The request envelope from stdin, or, without NOPASS_INPUT=stdin, the
+    same fields read from INPUT_DIR.
+    """
+    if STDIN_INPUT:
+        line = sys.stdin.readline()
+        request = json.loads(line) if line.strip() else {}
+        if request.get("type") != "request":
+            raise ValueError("stdin does not start with a request envelope")
+        return request
+    return {
+        "system_prompt": read_file(os.path.join(INPUT_DIR, "system.txt")),
+        "user_content": read_file(os.path.join(INPUT_DIR, "user.txt")),
+        "output": read_protocol(),
+        "cache": read_cache_hint(),
+        "params": read_params(),
+    }
+
+def read_cache_hint() -> dict:
+    """
+    cache.json marks system.txt as a stable prefix. A real backend would use
+    it to enable prompt caching, e.g. cache_control={"type": "ephemeral"} on
+    the Anthropic system block, or rely on vLLM automatic prefix caching by
+    always sending system.txt first and unchanged.
+    """
+    raw = read_file(os.path.join(INPUT_DIR, "cache.json"))
+    if not raw:
+        return {}
+    try:
+        return json.loads(raw)
+    except json.JSONDecodeError:
+        return {}
+
+def read_params() -> dict:
+    """
+    params.json holds the client's sampling settings (model, temperature,
+    top_p, max_tokens, stop); absent fields keep the backend's defaults.
+    """
+    raw = read_file(os.path.join(INPUT_DIR, "params.json"))
+    if not raw:
+        return {}
+    try:
+        return json.loads(raw)
+    except json.JSONDecodeError:
+        return {}
+
+def read_protocol() -> str:
+    """
+    protocol.json names the stdout protocol the gateway expects: "frames"
+    (JSON frames, this image's own) or "text" (plain answer text ending in
+    the NOPASS_ECHO footer, for gateways that predate frames). "auto" and
+    a missing file mean frames.
+    """
+    raw = read_file(os.path.join(INPUT_DIR, "protocol.json"))
+    try:
+        output = json.loads(raw).get("output") if raw else None
+    except json.JSONDecodeError:
+        output = None
+    return "text" if output == "text" else "frames"
+
+PROTOCOL = "frames"
+
+def read_echo(user_content: str) -> dict:
+    """
+    The request ID and policy version from the <context> block that opens
+    user.txt. The gateway rejects output whose footer doesn't echo them,
+    which catches a stale or reused container answering the wrong request.
+    """
+    echo = {}
+    if not user_content.startswith("<context>\n"):
+        return echo
+    for line in user_content.split("\n")[1:]:
+        if line == "</context>":
+            break
+        key, sep, value = line.partition(": ")
+        if sep and key in ("request_id", "policy_version"):
+            echo[key] = value
+    return echo if "request_id" in echo else {}
+
+def emit_frame(kind: str, **fields):
+    """
+    Write one output frame: a JSON object on its own stdout line. Only
+    "answer" frames reach the user; "model", "usage" and "warning" frames
+    go on the sandbox receipt, "log" frames to the gateway's debug log.
+    Anything else printed to stdout is treated as a log line too.
+
+    Under the text protocol only the answer text and the echo footer are
+    written to stdout; logs and warnings go to stderr.
+    """
+    if PROTOCOL == "text":
+        if kind == "answer":
+            print(fields["text"], end="", flush=True)
+        elif kind == "echo":
+            print("NOPASS_ECHO " + json.dumps(fields), flush=True)
+        elif kind in ("log", "warning"):
+            print(f"[sandbox] {fields.get('message', '')}", file=sys.stderr)
+        return
+    print(json.dumps({"type": kind, **fields}), flush=True)
+
+def emit_echo(echo: dict):
+    """
+    Write the echo frame, which the gateway checks against the request.
+    """
+    if echo:
+        emit_frame("echo", **echo)
+
+def emit(text: str = ""):
+    """
+    Write a line of answer text immediately. The gateway streams answer
+    frames to clients as they arrive, so nothing may sit in Python's
+    buffer.
+    """
+    emit_frame("answer", text=text + "\n")
+
+def log(message: str):
+    emit_frame("log", message=message)
+
+def count_tokens(text: str) -> int:
+    """A rough token count; a real backend reports the model's own."""
+    return len(text.split())
+
 def main():
-    system_path = os.path.join(INPUT_DIR, "system.txt")
-    user_path = os.path.join(INPUT_DIR, "user.txt")
+    global PROTOCOL
+    request = read_request()
+    PROTOCOL = "text" if request.get("output") == "text" else "frames"
 
-    system_prompt = read_file(system_path)
-    user_content = read_file(user_path)
+    system_prompt = request.get("system_prompt") or ""
+    user_content = request.get("user_content") or ""
+    cache_hint = request.get("cache") or {}
+    if cache_hint.get("system_prompt_cacheable"):
+        print(f"[sandbox] system prompt cacheable (key={cache_hint.get('cache_key')})", file=sys.stderr)
+    params = request.get("params") or {}
+    if params:
+        print(f"[sandbox] generation params: {json.dumps(params, sort_keys=True)}", file=sys.stderr)
 
     # Simulated "LLM" – later you can replace this with a real model call.
-    print("NO PASS LLM SANDBOX (SIMULATED)\n")
-    print("=== SYSTEM PROMPT (TRUNCATED) ===")
-    print(system_prompt[:400])
-    print("\n=== USER CONTENT (TRUNCATED) ===")
-    print(user_content[:800])
-    print("\n=== ANSWER ===")
-    print("This is a simulated answer generated inside an isolated Docker sandbox.")
+    # What it was given goes to the log, not the answer.
+    log("system prompt (truncated): " + system_prompt[:400])
+    log("user content (truncated): " + user_content[:800])
+    emit_frame("model", model=params.get("model") or "simulated")
+    answer = "This is a simulated answer generated inside an isolated Docker sandbox."
+    emit(answer)
+    usage = {
+        "input_tokens": count_tokens(system_prompt) + count_tokens(user_content),
+        "output_tokens": count_tokens(answer),
+    }
+    emit_frame("usage", **usage)
+    emit_echo(read_echo(user_content))
+    emit_frame("result", status="ok", **usage)
+
+def report_usage():
+    """
+    Print this run's CPU time and peak memory for the gateway's sandbox
+    receipt. Must be the last thing written to stderr.
+    """
+    usage = [resource.getrusage(resource.RUSAGE_SELF), resource.getrusage(resource.RUSAGE_CHILDREN)]
+    cpu_ms = int(sum(u.ru_utime + u.ru_stime for u in usage) * 1000)
+    peak_kb = max(u.ru_maxrss for u in usage)  # kilobytes on Linux
+    print("NOPASS_USAGE " + json.dumps({"cpu_time_ms": cpu_ms, "peak_memory_bytes": peak_kb * 1024}), file=sys.stderr)
 
 if __name__ == "__main__":
-    main()
+    try:
+        main()
+    except Exception as e:
+        # The result envelope tells the gateway why, rather than leaving it
+        # a missing result and an exit status.
+        emit_frame("result", status="error", error=f"{type(e).__name__}: {e}")
+        raise
+    finally:
+        report_usage()
@@ -1,25 +1,67 @@
-from fastapi import FastAPI
+from fastapi import FastAPI, HTTPException
 from pydantic import BaseModel
 from typing import List, Literal
+import os
 import re
 
 app = FastAPI(title="NoPass Output Safety Service")
 
+# With OTEL_EXPORTER_OTLP_ENDPOINT set, requests are traced and join the
+# gateway's trace through the traceparent header it sends.
+if os.environ.get("OTEL_EXPORTER_OTLP_ENDPOINT"):
+    from opentelemetry import trace
+    from opentelemetry.exporter.otlp.proto.http.trace_exporter import OTLPSpanExporter
+    from opentelemetry.instrumentation.fastapi import FastAPIInstrumentor
+    from opentelemetry.sdk.resources import Resource
+    from opentelemetry.sdk.trace import TracerProvider
+    from opentelemetry.sdk.trace.export import BatchSpanProcessor
+
+    _provider = TracerProvider(resource=Resource.create({"service.name": os.environ.get("OTEL_SERVICE_NAME", "nopass-output-safety")}))
+    _provider.add_span_processor(BatchSpanProcessor(OTLPSpanExporter()))
+    trace.set_tracer_provider(_provider)
+    FastAPIInstrumentor.instrument_app(app)
+
+
 Mode = Literal["fast", "slow"]
 
+# Must match types.SchemaVersion in the Go gateway. A missing schema_version
+# is treated as the legacy v1 format.
+SCHEMA_VERSION = 1
+
+
+class DataSource(BaseModel):
+    id: str
+    source: str
+    type: str
+    dangerous: bool = False
+
 
 class OutputSafetyRequest(BaseModel):
+    schema_version: int = 1
     user_prompt: str
     draft_answer: str
     risk_level: str
     flags: List[str] = []
     mode: Mode = "fast"  # "fast" or "slow"
+    # Remaining time budget from the gateway (mirrors X-Deadline-Ms).
+    deadline_ms: int | None = None
+    # What actually went into the model (all optional for older gateways).
+    masked_prompt: str | None = None
+    sources: List[DataSource] = []
+    policy_id: str | None = None
+    data_flow_labels: List[str] = []
+    # draft_answer is a prefix of an answer still being streamed; the whole
+    # answer is sent again (partial=false) once it is complete.
+    partial: bool = False
 
 
 class OutputSafetyResponse(BaseModel):
+    schema_version: int = SCHEMA_VERSION
     final_answer: str
     was_modified: bool
     reason_flags: List[str]
+    # True when the answer was refused outright rather than redacted.
+    blocked: bool = False
 
 
 # ---------- Fast check patterns ---------- #
@@ -115,13 +157,22 @@ def simulate_self_check(
 
 @app.post("/v1/output-safety", response_model=OutputSafetyResponse)
 def output_safety(req: OutputSafetyRequest) -> OutputSafetyResponse:
+    if req.schema_version > SCHEMA_VERSION:
+        raise HTTPException(
+            status_code=400,
+            detail=f"unsupported schema version {req.schema_version} (supports up to {SCHEMA_VERSION})",
+        )
+
     draft = req.draft_answer
 
     # 1) Fast checks (always on)
     text_after_fast, fast_modified, fast_flags = run_fast_checks(draft)
 
-    # 2) If mode is FAST: just return fast-checked text
-    if req.mode == "fast":
+    # 2) If mode is FAST: just return fast-checked text, unless the model saw
+    #    quarantined external data - then the draft gets the self-check too.
+    if any(s.dangerous for s in req.sources):
+        fast_flags.append("dangerous_context")
+    if req.mode == "fast" and "dangerous_context" not in fast_flags:
         return OutputSafetyResponse(
             final_answer=text_after_fast,
             was_modified=fast_modified,
@@ -140,6 +191,7 @@ def output_safety(req: OutputSafetyRequest) -> OutputSafetyResponse:
         final_answer=final,
         was_modified=fast_modified or selfcheck_modified,
         reason_flags=flags,
+        blocked="self_check_refusal" in flags,
     )
 
 
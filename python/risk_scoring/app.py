@@ -1,7 +1,11 @@
-from fastapi import FastAPI
+import json
+
+from fastapi import FastAPI, HTTPException, Request
+from fastapi.responses import StreamingResponse
 from pydantic import BaseModel
 from typing import Dict, List, Literal
 
+import os
 import re
 import numpy as np
 import faiss
@@ -9,21 +13,75 @@ from sentence_transformers import SentenceTransformer
 
 app = FastAPI(title="NoPass Risk Scoring Service")
 
+# With OTEL_EXPORTER_OTLP_ENDPOINT set, requests are traced and join the
+# gateway's trace through the traceparent header it sends.
+if os.environ.get("OTEL_EXPORTER_OTLP_ENDPOINT"):
+    from opentelemetry import trace
+    from opentelemetry.exporter.otlp.proto.http.trace_exporter import OTLPSpanExporter
+    from opentelemetry.instrumentation.fastapi import FastAPIInstrumentor
+    from opentelemetry.sdk.resources import Resource
+    from opentelemetry.sdk.trace import TracerProvider
+    from opentelemetry.sdk.trace.export import BatchSpanProcessor
+
+    _provider = TracerProvider(resource=Resource.create({"service.name": os.environ.get("OTEL_SERVICE_NAME", "nopass-risk-scoring")}))
+    _provider.add_span_processor(BatchSpanProcessor(OTLPSpanExporter()))
+    trace.set_tracer_provider(_provider)
+    FastAPIInstrumentor.instrument_app(app)
+
+
 RiskLevel = Literal["LOW", "MEDIUM", "HIGH"]
 
+# Must match types.SchemaVersion in the Go gateway. A missing schema_version
+# is treated as the legacy v1 format.
+SCHEMA_VERSION = 1
+
 
 class RiskRequest(BaseModel):
+    schema_version: int = 1
     prompt: str
     metadata: Dict[str, str] | None = None
+    # Remaining time budget from the gateway (mirrors X-Deadline-Ms).
+    deadline_ms: int | None = None
 
 
 class RiskResponse(BaseModel):
+    schema_version: int = SCHEMA_VERSION
     sanitized_prompt: str
     risk_level: RiskLevel
     flags: List[str]
     self_check_required: bool
 
 
+class RiskBatchRequest(BaseModel):
+    """POST /v1/risk-score/batch: several documents in one call."""
+    schema_version: int = 1
+    documents: List[str]
+    metadata: Dict[str, str] | None = None
+    deadline_ms: int | None = None
+
+
+class RiskBatchResponse(BaseModel):
+    schema_version: int = SCHEMA_VERSION
+    results: List[RiskResponse]
+
+
+class RiskSection(BaseModel):
+    """One NDJSON line of POST /v2/risk-score/stream."""
+    schema_version: int = 1
+    seq: int
+    text: str
+    metadata: Dict[str, str] | None = None
+    deadline_ms: int | None = None
+
+
+class RiskSectionVerdict(BaseModel):
+    schema_version: int = SCHEMA_VERSION
+    seq: int
+    risk_level: RiskLevel
+    flags: List[str]
+    final: bool = False
+
+
 # ---------------------------
 # 1) Regex-based rules
 # ---------------------------
@@ -155,25 +213,49 @@ def on_startup():
 # 3) Risk scoring endpoint
 # ---------------------------
 
-@app.post("/v1/risk-score", response_model=RiskResponse)
-def risk_score(req: RiskRequest) -> RiskResponse:
-    prompt = req.prompt
+# Below this budget we skip the embedding lookup and rely on regex rules only,
+# rather than being cut off by the gateway's client timeout mid-work.
+MIN_EMBEDDING_BUDGET_MS = 150
+
+def score_text(text: str, deadline_ms: int | None) -> tuple[RiskLevel, List[str]]:
+    """Regex rules plus embedding similarity for one piece of text."""
     flags: List[str] = []
     risk_level: RiskLevel = "LOW"
 
     # --- 1. Regex-based detection --- #
     for pattern, flag, severity in REGEX_RULES:
-        if pattern.search(prompt):
+        if pattern.search(text):
             flags.append(flag)
             risk_level = combine_severity(risk_level, severity)  # type: ignore
 
     # --- 2. Embedding-based similarity --- #
-    emb_score = embedding_similarity_score(prompt)
-    emb_risk_level, emb_flags = embedding_risk(emb_score)
+    if deadline_ms is not None and deadline_ms < MIN_EMBEDDING_BUDGET_MS:
+        flags.append("embedding_skipped_deadline")
+    else:
+        emb_score = embedding_similarity_score(text)
+        emb_risk_level, emb_flags = embedding_risk(emb_score)
 
-    if emb_flags:
-        flags.extend(emb_flags)
-        risk_level = combine_severity(risk_level, emb_risk_level)  # type: ignore
+        if emb_flags:
+            flags.extend(emb_flags)
+            risk_level = combine_severity(risk_level, emb_risk_level)  # type: ignore
+
+    return risk_level, flags
+
+
+def check_schema_version(version: int) -> None:
+    if version > SCHEMA_VERSION:
+        raise HTTPException(
+            status_code=400,
+            detail=f"unsupported schema version {version} (supports up to {SCHEMA_VERSION})",
+        )
+
+
+@app.post("/v1/risk-score", response_model=RiskResponse)
+def risk_score(req: RiskRequest) -> RiskResponse:
+    check_schema_version(req.schema_version)
+
+    prompt = req.prompt
+    risk_level, flags = score_text(prompt, req.deadline_ms)
 
     # --- 3. Simple sanitization placeholder (regex-based) --- #
     sanitized_prompt = prompt
@@ -206,6 +288,61 @@ def risk_score(req: RiskRequest) -> RiskResponse:
     )
 
 
+@app.post("/v1/risk-score/batch", response_model=RiskBatchResponse)
+def risk_score_batch(req: RiskBatchRequest) -> RiskBatchResponse:
+    """
+    Score a request's external documents in one call, one verdict per
+    document in request order. Each is scored as /v1/risk-score would.
+    """
+    check_schema_version(req.schema_version)
+    results = [
+        risk_score(RiskRequest(prompt=doc, metadata=req.metadata, deadline_ms=req.deadline_ms))
+        for doc in req.documents
+    ]
+    return RiskBatchResponse(results=results)
+
+
+# ---------------------------
+# 4) Streaming scoring (protocol v2)
+# ---------------------------
+
+@app.post("/v2/risk-score/stream")
+async def risk_score_stream(request: Request) -> StreamingResponse:
+    """
+    Score a large document section by section. The request body is NDJSON
+    RiskSection lines; a RiskSectionVerdict line is streamed back as soon as
+    each section is scored. Scoring stops at the first HIGH section, which
+    is marked final, so the client can abandon the rest of the upload.
+    """
+
+    async def sections():
+        buf = b""
+        async for chunk in request.stream():
+            buf += chunk
+            while b"\n" in buf:
+                line, buf = buf.split(b"\n", 1)
+                if line.strip():
+                    yield RiskSection(**json.loads(line))
+        if buf.strip():
+            yield RiskSection(**json.loads(buf))
+
+    async def verdicts():
+        deadline_ms: int | None = None
+        async for section in sections():
+            check_schema_version(section.schema_version)
+            if section.deadline_ms is not None:
+                deadline_ms = section.deadline_ms
+            risk_level, flags = score_text(section.text, deadline_ms)
+            final = risk_level == "HIGH"
+            verdict = RiskSectionVerdict(seq=section.seq, risk_level=risk_level, flags=flags, final=final)
+            yield verdict.model_dump_json() + "\n"
+            if final:
+                return
+        yield RiskSectionVerdict(seq=-1, risk_level="LOW", flags=[], final=True).model_dump_json() + "\n"
+
+    return StreamingResponse(verdicts(), media_type="application/x-ndjson")
+
+
 # For local dev: `python app.py`
 if __name__ == "__main__":
     import uvicorn